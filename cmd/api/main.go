@@ -1,20 +1,36 @@
 package main
 
 import (
+	"NYCU-SDC/deployment-service/internal/adapter/cloudflare"
+	"NYCU-SDC/deployment-service/internal/adapter/deploymentstore"
+	"NYCU-SDC/deployment-service/internal/adapter/envstore"
+	"NYCU-SDC/deployment-service/internal/adapter/fleet"
+	"NYCU-SDC/deployment-service/internal/adapter/hoststore"
+	"NYCU-SDC/deployment-service/internal/adapter/sentry"
+	"NYCU-SDC/deployment-service/internal/adapter/tombstone"
 	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
 	"NYCU-SDC/deployment-service/internal/handler"
+	"NYCU-SDC/deployment-service/internal/hosts"
+	"NYCU-SDC/deployment-service/internal/inflight"
 	"NYCU-SDC/deployment-service/internal/logger"
 	"NYCU-SDC/deployment-service/internal/middleware"
+	"NYCU-SDC/deployment-service/internal/namespace"
+	"NYCU-SDC/deployment-service/internal/resolver"
+	"NYCU-SDC/deployment-service/internal/scheduler"
 	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
@@ -23,6 +39,7 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.6.1"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -47,14 +64,19 @@ func main() {
 	}
 
 	// Initialize logger
-	zapLogger, err := initLogger(cfg)
+	zapLogger, closeLogger, err := logger.New(cfg.Logger, AppName, Version)
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
-	defer zapLogger.Sync()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := closeLogger(ctx); err != nil {
+			log.Printf("Failed to flush logs: %v", err)
+		}
+	}()
 
 	zapLogger.Info("Starting deployment service API",
-		zap.String("version", Version),
 		zap.String("build_time", BuildTime),
 		zap.String("commit_hash", CommitHash),
 	)
@@ -72,33 +94,145 @@ func main() {
 		}
 	}()
 
+	// Report recovered panics to Sentry, if configured
+	var crashReporter domain.CrashReporter
+	if cfg.Sentry.DSN != "" {
+		sentryClient, err := sentry.NewClient(cfg.Sentry.DSN, cfg.Sentry.Environment, Version, zapLogger)
+		if err != nil {
+			zapLogger.Fatal("Failed to create Sentry client", zap.Error(err))
+		}
+		crashReporter = sentryClient
+	}
+
+	// Encrypt the workflow payloads with the keys of their tenant or namespace; the propagator
+	// passes the tenant of a workflow on to its activities and child workflows
+	dataConverters, err := encryption.DataConverters(cfg.Encryption, cfg.Temporal)
+	if err != nil {
+		zapLogger.Fatal("Failed to load encryption keys", zap.Error(err))
+	}
+	propagators := encryption.ContextPropagators()
+	// Encrypt the deployment records kept outside Temporal with the same keys
+	recordCipher, err := encryption.NewRecordCipher(cfg.Encryption, cfg.Temporal)
+	if err != nil {
+		zapLogger.Fatal("Failed to load encryption keys", zap.Error(err))
+	}
+
+	// Track the workflow starts and signals in flight, so shutdown waits for them
+	workflowCalls := inflight.NewTracker()
+	interceptors := []interceptor.ClientInterceptor{inflight.NewClientInterceptor(workflowCalls)}
+
 	// Create Temporal client
 	temporalLogger := logger.NewZapLoggerAdapter(zapLogger)
 	temporalClient, err := client.Dial(client.Options{
-		HostPort:  cfg.Temporal.Address,
-		Namespace: cfg.Temporal.Namespace,
-		Logger:    temporalLogger,
+		HostPort:           cfg.Temporal.Address,
+		Namespace:          cfg.Temporal.Namespace,
+		Logger:             temporalLogger,
+		DataConverter:      dataConverters[cfg.Temporal.Namespace],
+		Interceptors:       interceptors,
+		ContextPropagators: propagators,
 	})
 	if err != nil {
 		zapLogger.Fatal("Failed to create Temporal client", zap.Error(err))
 	}
 	defer temporalClient.Close()
 
+	// Route each environment to its namespace
+	namespaces, err := namespace.NewRouter(temporalClient, cfg.Temporal, dataConverters, interceptors, propagators, temporalLogger)
+	if err != nil {
+		zapLogger.Fatal("Failed to create Temporal namespace clients", zap.Error(err))
+	}
+	defer namespaces.Close()
+
 	// Create validator
 	validator := validator.New()
 
+	// Records of the accepted deployments, migrated to the current schema
+	deploymentStore, err := deploymentstore.Open(context.Background(), cfg.Deployments, recordCipher, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("Failed to open deployment store", zap.Error(err))
+	}
+	defer deploymentStore.Close()
+
+	// Tombstones of deleted deployments, the environments deployments may target, and the
+	// drains and placements of the deploy host group. A SQL deployment store keeps them for
+	// every replica, after importing the files of earlier versions once.
+	var tombstoneStore domain.TombstoneStore = tombstone.NewStore(cfg.Retention.TombstoneFile, recordCipher)
+	var environmentStore domain.EnvironmentStore = envstore.NewStore(cfg.Environments.CatalogFile)
+	var hostStore domain.HostStore = hoststore.NewStore(cfg.SSH.HostStateFile, recordCipher)
+	if sharedState, ok := deploymentStore.(deploymentstore.SharedState); ok {
+		imported, err := sharedState.ImportState(context.Background(), tombstoneStore, hostStore, environmentStore)
+		if err != nil {
+			zapLogger.Fatal("Failed to import state files into the deployment store", zap.Error(err))
+		}
+		if imported {
+			zapLogger.Info("Imported state files into the deployment store",
+				zap.String("tombstone_file", cfg.Retention.TombstoneFile),
+				zap.String("catalog_file", cfg.Environments.CatalogFile),
+				zap.String("host_state_file", cfg.SSH.HostStateFile),
+			)
+		}
+		tombstoneStore = sharedState
+		environmentStore = sharedState
+		hostStore = sharedState
+	}
+
+	// Place deployments on the deploy host group
+	var hostLoad hosts.LoadQuerier
+	if cfg.SSH.HostLoad.Source != "" && len(cfg.SSH.Hosts) > 1 {
+		hostLoad = hosts.NewWorkflowLoadQuerier(namespaces.Client(cfg.GitHub.Preview.Environment), cfg.SSH.HostLoad.Timeout)
+	}
+	hostSelector := hosts.NewSelector(cfg.SSH, hostStore, hostLoad, cfg.GitHub.Preview.Environment, zapLogger)
+
+	// Create worker fleet client, reporting the capabilities of the workers
+	var workerFleet domain.WorkerFleet
+	var notificationFailures domain.NotificationFailureSource
+	var workerHostKeys domain.HostKeyManager
+	var workerCapabilities domain.CapabilityManager
+	if len(cfg.Worker.URLs) > 0 {
+		fleetClient := fleet.NewClient(cfg.Worker.URLs, cfg.Worker.Token, zapLogger)
+		workerFleet = fleetClient
+		notificationFailures = fleetClient
+		workerHostKeys = fleetClient
+		workerCapabilities = fleetClient
+	}
+
+	// Read the traffic of preview environments from Cloudflare, if configured
+	var trafficAnalytics domain.TrafficAnalytics
+	if cfg.Cloudflare.APIToken != "" && cfg.Cloudflare.ZoneID != "" {
+		trafficAnalytics = cloudflare.NewClient(cfg.Cloudflare.APIURL, cfg.Cloudflare.APIToken, cfg.Cloudflare.ZoneID, nil, zapLogger)
+	}
+
 	// Create handlers
-	webhookHandler := handler.NewWebhookHandler(temporalClient, validator, zapLogger)
+	domainPolicy := resolver.NewDomainPolicy(cfg.Cloudflare.AllowedDomains, zapLogger)
+	webhookHandler := handler.NewWebhookHandler(namespaces, environmentStore, hostSelector, deploymentStore, workerFleet, domainPolicy, validator, zapLogger)
+	deploymentHandler := handler.NewDeploymentHandler(namespaces, environmentStore, hostSelector, tombstoneStore, deploymentStore, validator, zapLogger)
+	environmentResolver := resolver.NewEnvironmentResolver(cfg.GitHub.Environments, cfg.GitHub.Preview.Environment, zapLogger)
+	githubHandler := handler.NewGitHubHandler(namespaces, environmentStore, hostSelector, deploymentStore, cfg.GitHub, environmentResolver, zapLogger)
+	adminHandler := handler.NewAdminHandler(temporalClient, environmentStore, workerHostKeys, validator, zapLogger)
+	fleetHandler := handler.NewFleetHandler(workerFleet, zapLogger)
+	capabilityHandler := handler.NewCapabilityHandler(workerCapabilities, zapLogger)
+	notificationHandler := handler.NewNotificationHandler(notificationFailures, zapLogger)
+	hostHandler := handler.NewHostHandler(namespaces, environmentStore, hostSelector, hostStore, deploymentStore, cfg.GitHub.Preview.Environment, zapLogger)
+	environmentHandler := handler.NewEnvironmentHandler(environmentStore, hostSelector, hostStore, zapLogger)
+	previewHandler := handler.NewPreviewHandler(namespaces, hostStore, trafficAnalytics, cfg.GitHub.Preview.Environment, zapLogger)
 
 	// Create middlewares
-	authMiddleware := middleware.NewAuthMiddleware(cfg.Auth.DeployToken, zapLogger)
+	authMiddleware := middleware.NewAuthMiddleware(cfg.Auth, zapLogger)
 	traceMiddleware := middleware.NewTraceMiddleware(zapLogger)
+	recoverMiddleware := middleware.NewRecoverMiddleware(crashReporter, zapLogger)
+	requests := inflight.NewTracker()
+	inFlightMiddleware := middleware.NewInFlightMiddleware(requests)
 
 	// Setup routes
 	mux := http.NewServeMux()
 
 	// Health check
 	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
+		// Fail readiness while shutting down, so no new requests are routed here
+		if inFlightMiddleware.ShuttingDown() {
+			http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
@@ -106,16 +240,274 @@ func main() {
 	// Webhook endpoint
 	mux.HandleFunc("POST /api/webhook/deploy",
 		traceMiddleware.Middleware(
-			authMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleDeploy,
 				webhookHandler.HandleDeploy,
 			),
 		),
 	)
 
+	// GitHub webhook endpoint (authenticated by the webhook signature)
+	mux.HandleFunc("POST /api/webhook/github",
+		traceMiddleware.Middleware(
+			githubHandler.HandleWebhook,
+		),
+	)
+
+	// Redeploy a historical deployment
+	mux.HandleFunc("POST /api/deployments/redeploy",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleDeploy,
+				deploymentHandler.HandleRedeploy,
+			),
+		),
+	)
+
+	// Recorded deployments, newest first
+	mux.HandleFunc("GET /api/deployments",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				deploymentHandler.HandleList,
+			),
+		),
+	)
+
+	// Report of deployments in a period as CSV or JSON
+	mux.HandleFunc("GET /api/deployments/export",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				deploymentHandler.HandleExport,
+			),
+		),
+	)
+
+	// Deployment status and per-step result
+	mux.HandleFunc("GET /api/deployments/{trace_id}",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				deploymentHandler.HandleGetStatus,
+			),
+		),
+	)
+
+	// Signed receipt of a completed deployment
+	mux.HandleFunc("GET /api/deployments/{trace_id}/receipt",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				deploymentHandler.HandleGetReceipt,
+			),
+		),
+	)
+
+	// SBOMs and provenance attestations of a deployment
+	mux.HandleFunc("GET /api/deployments/{trace_id}/artifacts",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				deploymentHandler.HandleGetArtifacts,
+			),
+		),
+	)
+
+	// Soft-delete a finished deployment
+	mux.HandleFunc("DELETE /api/deployments/{trace_id}",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				deploymentHandler.HandleDelete,
+			),
+		),
+	)
+
+	// Restore a soft-deleted deployment
+	mux.HandleFunc("POST /api/deployments/{trace_id}/restore",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				deploymentHandler.HandleRestore,
+			),
+		),
+	)
+
+	// Skip the DNS step of a running deployment
+	mux.HandleFunc("POST /api/deployments/{trace_id}/skip-dns",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleDeploy,
+				deploymentHandler.HandleSkipDNS,
+			),
+		),
+	)
+
+	// Acknowledge the failure notification of a deployment
+	mux.HandleFunc("POST /api/deployments/{trace_id}/ack",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleDeploy,
+				deploymentHandler.HandleAckNotification,
+			),
+		),
+	)
+
+	// Approve a deployment held by an environment requiring approval
+	mux.HandleFunc("POST /api/deployments/{trace_id}/approve",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				deploymentHandler.HandleApprove,
+			),
+		),
+	)
+
+	// Environments catalog
+	mux.HandleFunc("GET /api/environments",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				environmentHandler.HandleList,
+			),
+		),
+	)
+	mux.HandleFunc("GET /api/environments/{name}",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				environmentHandler.HandleGet,
+			),
+		),
+	)
+
+	// Build info, drivers, and adapter health of the worker fleet
+	mux.HandleFunc("GET /api/workers",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				fleetHandler.HandleListWorkers,
+			),
+		),
+	)
+
+	// Turn capabilities and drivers off on every worker, e.g. DNS changes during a Cloudflare incident
+	mux.HandleFunc("PUT /api/admin/workers/capabilities",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				capabilityHandler.HandleSet,
+			),
+		),
+	)
+
+	// Notifications the workers couldn't deliver
+	mux.HandleFunc("GET /api/notifications/failures",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				notificationHandler.HandleListFailures,
+			),
+		),
+	)
+
+	// Resend a notification a worker couldn't deliver
+	mux.HandleFunc("POST /api/notifications/failures/{id}/retry",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleDeploy,
+				notificationHandler.HandleRetry,
+			),
+		),
+	)
+
+	// Deploy hosts with their drain state and environments
+	mux.HandleFunc("GET /api/hosts",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				hostHandler.HandleListHosts,
+			),
+		),
+	)
+
+	// Requests received by the preview environments, to find unused ones
+	mux.HandleFunc("GET /api/previews/usage",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleViewer,
+				previewHandler.HandleUsage,
+			),
+		),
+	)
+
+	// Drain a deploy host for maintenance
+	mux.HandleFunc("PUT /api/admin/hosts/{host}/drain",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				hostHandler.HandleDrain,
+			),
+		),
+	)
+
+	// Make a drained deploy host available again
+	mux.HandleFunc("DELETE /api/admin/hosts/{host}/drain",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				hostHandler.HandleUndrain,
+			),
+		),
+	)
+
+	// Move an environment to another deploy host
+	mux.HandleFunc("POST /api/admin/migrations",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				hostHandler.HandleMigrate,
+			),
+		),
+	)
+
+	// Create or replace an environment of the catalog
+	mux.HandleFunc("PUT /api/admin/environments/{name}",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				environmentHandler.HandlePut,
+			),
+		),
+	)
+
+	// Remove an environment from the catalog
+	mux.HandleFunc("DELETE /api/admin/environments/{name}",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				environmentHandler.HandleDelete,
+			),
+		),
+	)
+
+	// Rotate the pinned SSH host key of the deploy host
+	mux.HandleFunc("PUT /api/admin/ssh/host-key",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				adminHandler.HandleRotateHostKey,
+			),
+		),
+	)
+
+	// Send a sample notification to check a notification channel
+	mux.HandleFunc("POST /api/admin/notify/test",
+		traceMiddleware.Middleware(
+			authMiddleware.Middleware(middleware.RoleAdmin,
+				adminHandler.HandleTestNotification,
+			),
+		),
+	)
+
 	// Create HTTP server
 	srv := &http.Server{
 		Addr:    cfg.Server.Host + ":" + cfg.Server.Port,
-		Handler: mux,
+		Handler: inFlightMiddleware.Middleware(recoverMiddleware.Middleware(mux.ServeHTTP)),
+	}
+
+	// Cancelled on interrupt, which also stops the background schedulers
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	// Run the background schedulers on one replica at a time; a replica is told apart from the
+	// others, and from its own earlier runs, by its hostname and a random suffix
+	hostname, _ := os.Hostname()
+	elector := scheduler.NewLeaderElector(deploymentStore, hostname+"-"+uuid.New().String()[:8], cfg.Scheduler.LeaseTTL, zapLogger)
+	var schedulers sync.WaitGroup
+
+	// Start retention pruner
+	if cfg.Retention.Enable {
+		pruner := scheduler.NewRetentionPruner(namespaces, environmentStore, tombstoneStore, deploymentStore, cfg.Retention, zapLogger)
+		schedulers.Add(1)
+		go func() {
+			defer schedulers.Done()
+			elector.Run(ctx, scheduler.RetentionLease, pruner.Run)
+		}()
 	}
 
 	// Start server in goroutine
@@ -130,38 +522,38 @@ func main() {
 	}()
 
 	// Wait for interrupt signal
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	<-ctx.Done()
+	// A second signal stops the API at once
+	stop()
+
+	zapLogger.Info("Shutting down gracefully...",
+		zap.Int("requests_in_flight", requests.Active()),
+		zap.Int("workflow_calls_in_flight", workflowCalls.Active()),
+	)
 
-	zapLogger.Info("Shutting down gracefully...")
+	// Keep serving while the load balancer notices the failing health check
+	inFlightMiddleware.ShutDown()
+	if cfg.Server.ShutdownDelay > 0 {
+		zapLogger.Info("Waiting before closing the listener", zap.Duration("delay", cfg.Server.ShutdownDelay))
+		time.Sleep(cfg.Server.ShutdownDelay)
+	}
 
-	// Shutdown server
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Stop accepting connections and wait until the accepted requests are answered. The
+	// workflows they start aren't cancelled with them, so wait for those too before the
+	// Temporal clients are closed.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		zapLogger.Error("Server forced to shutdown", zap.Error(err))
+		zapLogger.Error("Server forced to shutdown", zap.Int("requests_in_flight", requests.Active()), zap.Error(err))
 	}
-
-	stop()
-	zapLogger.Info("Server stopped")
-}
-
-func initLogger(cfg *config.Config) (*zap.Logger, error) {
-	var logger *zap.Logger
-	var err error
-
-	if cfg.Logger.Format == "console" {
-		logger, err = zap.NewDevelopment()
-	} else {
-		logger, err = zap.NewProduction()
+	if err := workflowCalls.Wait(shutdownCtx); err != nil {
+		zapLogger.Error("Workflow starts and signals still in flight at shutdown", zap.Int("workflow_calls_in_flight", workflowCalls.Active()), zap.Error(err))
 	}
 
-	if err != nil {
-		return nil, err
-	}
+	// The schedulers stopped with the signal; wait until they released their leases
+	schedulers.Wait()
 
-	// Log level is set via logger config above
-	return logger, nil
+	zapLogger.Info("Server stopped")
 }
 
 func initOpenTelemetry(cfg *config.Config, logger *zap.Logger) (func(context.Context) error, error) {
@@ -2,22 +2,42 @@ package main
 
 import (
 	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/adapter/chaos"
 	"NYCU-SDC/deployment-service/internal/adapter/cloudflare"
 	"NYCU-SDC/deployment-service/internal/adapter/discord"
+	"NYCU-SDC/deployment-service/internal/adapter/github"
 	"NYCU-SDC/deployment-service/internal/adapter/infisical"
+	"NYCU-SDC/deployment-service/internal/adapter/nodeexporter"
+	"NYCU-SDC/deployment-service/internal/adapter/notificationstore"
+	"NYCU-SDC/deployment-service/internal/adapter/sentry"
+	"NYCU-SDC/deployment-service/internal/adapter/signer"
 	"NYCU-SDC/deployment-service/internal/adapter/ssh"
+	"NYCU-SDC/deployment-service/internal/adapter/teams"
+	"NYCU-SDC/deployment-service/internal/adapter/threadstore"
 	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"NYCU-SDC/deployment-service/internal/handler"
 	"NYCU-SDC/deployment-service/internal/logger"
+	"NYCU-SDC/deployment-service/internal/metrics"
+	"NYCU-SDC/deployment-service/internal/middleware"
+	"NYCU-SDC/deployment-service/internal/namespace"
 	"NYCU-SDC/deployment-service/internal/resolver"
 	"NYCU-SDC/deployment-service/internal/workflow"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/go-playground/validator/v10"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
@@ -26,6 +46,7 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.6.1"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -47,14 +68,19 @@ func main() {
 	}
 
 	// Initialize logger
-	zapLogger, err := initLogger(cfg)
+	zapLogger, closeLogger, err := logger.New(cfg.Logger, AppName, Version)
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
-	defer zapLogger.Sync()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := closeLogger(ctx); err != nil {
+			log.Printf("Failed to flush logs: %v", err)
+		}
+	}()
 
 	zapLogger.Info("Starting deployment service worker",
-		zap.String("version", Version),
 		zap.String("build_time", BuildTime),
 		zap.String("commit_hash", CommitHash),
 	)
@@ -72,12 +98,37 @@ func main() {
 		}
 	}()
 
+	// Report recovered panics to Sentry, if configured
+	var crashReporter domain.CrashReporter
+	if cfg.Sentry.DSN != "" {
+		sentryClient, err := sentry.NewClient(cfg.Sentry.DSN, cfg.Sentry.Environment, Version, zapLogger)
+		if err != nil {
+			zapLogger.Fatal("Failed to create Sentry client", zap.Error(err))
+		}
+		crashReporter = sentryClient
+	}
+
+	// Encrypt the workflow payloads with the keys of their tenant or namespace; the propagator
+	// passes the tenant of a workflow on to its activities and child workflows
+	dataConverters, err := encryption.DataConverters(cfg.Encryption, cfg.Temporal)
+	if err != nil {
+		zapLogger.Fatal("Failed to load encryption keys", zap.Error(err))
+	}
+	propagators := encryption.ContextPropagators()
+	// Encrypt the failed notifications kept outside Temporal with the same keys
+	recordCipher, err := encryption.NewRecordCipher(cfg.Encryption, cfg.Temporal)
+	if err != nil {
+		zapLogger.Fatal("Failed to load encryption keys", zap.Error(err))
+	}
+
 	// Create Temporal client
 	temporalLogger := logger.NewZapLoggerAdapter(zapLogger)
 	temporalClient, err := client.Dial(client.Options{
-		HostPort:  cfg.Temporal.Address,
-		Namespace: cfg.Temporal.Namespace,
-		Logger:    temporalLogger,
+		HostPort:           cfg.Temporal.Address,
+		Namespace:          cfg.Temporal.Namespace,
+		Logger:             temporalLogger,
+		DataConverter:      dataConverters[cfg.Temporal.Namespace],
+		ContextPropagators: propagators,
 	})
 	if err != nil {
 		zapLogger.Fatal("Failed to create Temporal client", zap.Error(err))
@@ -87,62 +138,371 @@ func main() {
 	// Create adapters
 	infisicalClient := infisical.NewClient(cfg.Infisical.BaseURL, cfg.Infisical.ServiceToken, zapLogger)
 	sshClient := ssh.NewClient(cfg.SSH, zapLogger)
-	cloudflareClient := cloudflare.NewClient(cfg.Cloudflare.APIToken, cfg.Cloudflare.ZoneID, zapLogger)
-	discordClient := discord.NewClient(cfg.Discord.WebhookURL, zapLogger)
+	cloudflareClient := cloudflare.NewClient(cfg.Cloudflare.APIURL, cfg.Cloudflare.APIToken, cfg.Cloudflare.ZoneID, nil, zapLogger)
+	discordClient := discord.NewClient(cfg.Discord, zapLogger)
+	githubClient := github.NewClient(cfg.GitHub.APIURL, cfg.GitHub.Token, zapLogger)
+	teamsClient := teams.NewClient(cfg.Teams, zapLogger)
+
+	// Create the probe of the deploy host load, if configured
+	var loadProbe domain.LoadProbe
+	switch cfg.SSH.HostLoad.Source {
+	case config.HostLoadSourceSSH:
+		loadProbe = sshClient
+	case config.HostLoadSourceNodeExporter:
+		loadProbe = nodeexporter.NewClient(zapLogger)
+	}
+
+	// The command guard resolves the directories deployments remove against the base path
+	if err := cfg.SSH.ValidateBasePath(); err != nil {
+		zapLogger.Fatal("Invalid SSH configuration", zap.Error(err))
+	}
+
+	// Create the probe of the deploy host health, if configured
+	if err := cfg.SSH.HostHealth.Validate(); err != nil {
+		zapLogger.Fatal("Invalid host health configuration", zap.Error(err))
+	}
+	var healthProbe domain.HostHealthProbe
+	switch cfg.SSH.HostHealth.Source {
+	case config.HostLoadSourceSSH:
+		healthProbe = sshClient
+	case config.HostLoadSourceNodeExporter:
+		healthProbe = nodeexporter.NewClient(zapLogger)
+	}
+
+	// Post the notifications of each deployment into its own Discord thread, if enabled
+	var threadNotifier domain.ThreadNotifier
+	var threadStore domain.ThreadStore
+	if cfg.Discord.Threads.Enable {
+		threadNotifier = discordClient
+		threadStore = threadstore.NewStore(cfg.Discord.Threads.StateFile)
+	}
+
+	// Keep notifications that couldn't be delivered until they are resent
+	notificationFailures := notificationstore.NewStore(cfg.Worker.NotificationFailuresFile, recordCipher)
+
+	// Sign the receipts of completed deployments, if a signing key is configured
+	var receiptSigner domain.ReceiptSigner
+	if cfg.Receipts.SigningKeyFile != "" {
+		receiptSigner, err = signer.NewSigner(cfg.Receipts.SigningKeyFile)
+		if err != nil {
+			zapLogger.Fatal("Failed to load receipt signing key", zap.Error(err))
+		}
+	}
+
+	// Metrics scraped from the worker info server
+	metricsRegistry := metrics.NewRegistry()
 
 	// Create resolvers
 	ipResolver := resolver.NewIPResolver(cfg.IPMappings, zapLogger)
+	var commitAuthors domain.RepositoryProvider
+	if cfg.Discord.Mentions.LookupCommitAuthor {
+		commitAuthors = githubClient
+	}
+	notificationRouter := resolver.NewNotificationRouter(cfg.Notification.Routes, cfg.VerbosityFor, zapLogger)
+
+	// Notification channels with a webhook; failure notifications mention the commit author
+	notificationChannels := make(map[string]activity.NotificationChannel)
+	if cfg.Discord.WebhookURL != "" {
+		notificationChannels[domain.NotificationChannelDiscord] = activity.NotificationChannel{
+			Notifier: discordClient,
+			Mentions: resolver.NewMentionResolver(cfg.Discord.Mentions.Users, commitAuthors, zapLogger),
+		}
+	}
+	if cfg.Teams.WebhookURL != "" {
+		notificationChannels[domain.NotificationChannelTeams] = activity.NotificationChannel{
+			Notifier: teamsClient,
+			Mentions: resolver.NewMentionResolver(cfg.Teams.MentionUsers, commitAuthors, zapLogger),
+		}
+	}
+
+	// Inject failures into SSH executions and DNS changes, if enabled on this (staging) worker;
+	// config validation refuses the namespace of the production environment
+	var sshExecutor domain.SSHExecutor = sshClient
+	var dnsProvider domain.DNSProvider = cloudflareClient
+	var canaryProvider domain.CanaryDNSProvider = cloudflareClient
+	if err := cfg.ValidateChaos(); err != nil {
+		zapLogger.Fatal("Invalid chaos configuration", zap.Error(err))
+	}
+	if cfg.Chaos.Enable {
+		zapLogger.Warn("Chaos failure injection enabled",
+			zap.Strings("namespaces", cfg.Chaos.Namespaces),
+			zap.Float64("ssh_failure_rate", cfg.Chaos.SSHFailureRate),
+			zap.Duration("cloudflare_latency", cfg.Chaos.CloudflareLatency),
+		)
+		sshExecutor = chaos.NewSSHExecutor(sshClient, cfg.Chaos.Namespaces, cfg.Chaos.SSHFailureRate, zapLogger)
+		chaosDNS := chaos.NewDNSProvider(cloudflareClient, cloudflareClient, cfg.Chaos.Namespaces, cfg.Chaos.CloudflareLatency, zapLogger)
+		dnsProvider, canaryProvider = chaosDNS, chaosDNS
+	}
 
 	// Create activities
-	secretActivity := activity.NewSecretActivity(infisicalClient, zapLogger)
-	sshActivity := activity.NewSSHActivity(sshClient, cfg.SSH, zapLogger)
-	dnsActivity := activity.NewDNSActivity(cloudflareClient, ipResolver, zapLogger)
-	notifyActivity := activity.NewNotifyActivity(discordClient, zapLogger)
+	secretActivity := activity.NewSecretActivity(infisicalClient, cfg.Infisical.ChecksumSalt, zapLogger)
+	sshActivity := activity.NewSSHActivity(sshExecutor, cfg.SSH, cfg.Worker.Drivers, zapLogger)
+	domainPolicy := resolver.NewDomainPolicy(cfg.Cloudflare.AllowedDomains, zapLogger)
+	dnsActivity := activity.NewDNSActivity(dnsProvider, canaryProvider, ipResolver, domainPolicy, zapLogger)
+	notifyActivity := activity.NewNotifyActivity(notificationChannels, notificationRouter, discordClient, threadNotifier, threadStore, notificationFailures, metricsRegistry, zapLogger)
+	manifestActivity := activity.NewManifestActivity(githubClient, zapLogger)
+	ciActivity := activity.NewCIActivity(githubClient, zapLogger)
+	healthActivity := activity.NewHealthActivity(ipResolver, zapLogger)
+	hostKeyActivity := activity.NewHostKeyActivity(sshClient, cfg.SSH, zapLogger)
+	loadActivity := activity.NewLoadActivity(loadProbe, cfg.SSH, zapLogger)
+	hostHealthActivity := activity.NewHostHealthActivity(healthProbe, cfg.SSH, zapLogger)
+	receiptActivity := activity.NewReceiptActivity(receiptSigner, zapLogger)
+
+	// Register workflows and activities on a worker
+	capabilities := workflow.Capabilities{
+		DNS:      cfg.Cloudflare.APIToken != "" && cfg.Cloudflare.ZoneID != "",
+		Notifier: len(notificationChannels) > 0,
+	}
+	zapLogger.Info("Detected optional capabilities",
+		zap.Bool("dns", capabilities.DNS),
+		zap.Bool("notifier", capabilities.Notifier),
+		zap.Strings("disabled", cfg.Worker.Disabled),
+	)
 
-	// Create worker
-	w := worker.New(temporalClient, "cd-task-queue", worker.Options{})
+	// Drivers and capabilities can be turned off without restarting the worker
+	if err := cfg.Worker.ValidateCapabilities(); err != nil {
+		zapLogger.Fatal("Invalid worker capabilities", zap.Error(err))
+	}
+	capabilitySwitch := workflow.NewCapabilitySwitch(cfg.Worker.Disabled, cfg.Worker.Drivers)
+
+	// Fail fast on a Cloudflare token that can't edit the zone instead of failing mid-deploy
+	if capabilities.DNS {
+		checkCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := cloudflareClient.CheckHealth(checkCtx)
+		cancel()
+		switch {
+		case err == nil:
+		case errors.Is(err, domain.ErrUnavailable):
+			zapLogger.Warn("Failed to verify the Cloudflare API token, continuing", zap.Error(err))
+		default:
+			zapLogger.Fatal("Cloudflare API token can't manage the DNS records of the zone",
+				zap.String("zone_id", cfg.Cloudflare.ZoneID),
+				zap.Error(err),
+			)
+		}
+	}
+	// Platform of the worker, matched against the worker requirement of deployments
+	hostname, _ := os.Hostname()
+	platform := domain.WorkerPlatform{
+		Hostname: hostname,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Region:   cfg.Worker.Region,
+	}
+	cdWorkflowOptions := workflow.CDWorkflowOptions{
+		Retry:        cfg.Retry,
+		Ack:          cfg.Discord.Ack,
+		Threads:      cfg.Discord.Threads.Enable,
+		Updates:      true,
+		Canary:       cfg.Cloudflare.Canary,
+		Capabilities: capabilities,
+		HostHealth:   cfg.SSH.HostHealth,
+		Receipts:     receiptSigner != nil,
+		Switch:       capabilitySwitch,
+		Platform:     platform,
+	}
+	activities := []any{
+		secretActivity.FetchInfisicalSecrets,
+		sshActivity.RunSSHDeploy,
+		dnsActivity.EnsureDNSRecord,
+		dnsActivity.RemoveDNSRecord,
+		dnsActivity.EnsureCanaryDNSRecord,
+		dnsActivity.RemoveCanaryDNSRecords,
+		notifyActivity.SendDiscordNotification,
+		notifyActivity.SendTrackedDiscordNotification,
+		notifyActivity.SendDiscordProgress,
+		notifyActivity.CheckNotificationAck,
+		notifyActivity.SendNotificationReminder,
+		notifyActivity.SendTestNotification,
+		manifestActivity.FetchDeployManifest,
+		ciActivity.CheckCommitStatus,
+		healthActivity.CheckHealth,
+		healthActivity.CheckHealthAt,
+		hostKeyActivity.PinHostKey,
+		loadActivity.QueryHostLoad,
+		hostHealthActivity.CheckHostHealth,
+		receiptActivity.SignDeploymentReceipt,
+	}
+	register := func(w worker.Worker) {
+		// Register workflows
+		workflow.Register(w, cdWorkflowOptions)
+
+		// Register activities
+		for _, a := range activities {
+			w.RegisterActivity(a)
+		}
+	}
+
+	// Create a worker for each namespace deployments are routed to
+	namespaces, err := namespace.NewRouter(temporalClient, cfg.Temporal, dataConverters, nil, propagators, temporalLogger)
+	if err != nil {
+		zapLogger.Fatal("Failed to create Temporal namespace clients", zap.Error(err))
+	}
+	defer namespaces.Close()
+
+	// Panics of activities fail the activity instead of the worker process;
+	// finished workflows and deployment steps are recorded in the metrics
+	workerOptions := worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{
+			activity.NewRecoverInterceptor(crashReporter, zapLogger),
+			workflow.NewMetricsInterceptor(metricsRegistry),
+		},
+	}
+
+	// Each namespace is polled on the task queue of every worker requirement the platform meets
+	taskQueues := workflow.TaskQueuesOf(platform)
+	var workers []worker.Worker
+	for _, ns := range namespaces.Namespaces() {
+		for _, taskQueue := range taskQueues {
+			w := worker.New(namespaces.NamespaceClient(ns), taskQueue, workerOptions)
+			register(w)
+			workers = append(workers, w)
+		}
+	}
+
+	zapLogger.Info("Worker registered, starting...",
+		zap.Strings("namespaces", namespaces.Namespaces()),
+		zap.Strings("task_queues", taskQueues),
+	)
+
+	// Build info and capabilities reported to the API
+	workerInfo := domain.WorkerInfo{
+		Version:    Version,
+		CommitHash: CommitHash,
+		BuildTime:  BuildTime,
+		GoVersion:  runtime.Version(),
+		Hostname:   hostname,
+		TaskQueue:  workflow.TaskQueue,
+		TaskQueues: taskQueues,
+		OS:         platform.OS,
+		Arch:       platform.Arch,
+		Region:     platform.Region,
+		Namespaces: namespaces.Namespaces(),
+		Workflows:  workflow.Names,
+		Drivers:    cfg.Worker.Drivers,
+		Disabled:   cfg.Worker.Disabled,
+	}
+	for _, a := range activities {
+		workerInfo.Activities = append(workerInfo.Activities, activityName(a))
+	}
+
+	// Adapters without credentials are reported as not configured
+	adapters := map[string]domain.HealthChecker{
+		"infisical":  nil,
+		"cloudflare": nil,
+		"discord":    nil,
+		"github":     githubClient,
+		"ssh":        sshClient,
+	}
+	if cfg.Infisical.ServiceToken != "" {
+		adapters["infisical"] = infisicalClient
+	}
+	if capabilities.DNS {
+		adapters["cloudflare"] = cloudflareClient
+	}
+	if capabilities.Notifier {
+		adapters["discord"] = discordClient
+	}
+	var requiredAdapters []string
+	if capabilities.DNS {
+		requiredAdapters = append(requiredAdapters, "cloudflare")
+	}
+	workerInfoHandler := handler.NewWorkerInfoHandler(workerInfo, adapters, requiredAdapters, zapLogger)
+	notificationHandler := handler.NewNotificationHandler(notifyActivity, zapLogger)
+	hostKeyHandler := handler.NewHostKeyHandler(sshClient, fmt.Sprintf("%s:%d", cfg.SSH.Host, cfg.SSH.Port), validator.New(), zapLogger)
 
-	// Register workflows
-	w.RegisterWorkflow(workflow.CDWorkflow)
+	capabilityHandler := handler.NewCapabilityHandler(capabilitySwitch, zapLogger)
 
-	// Register activities
-	w.RegisterActivity(secretActivity.FetchInfisicalSecrets)
-	w.RegisterActivity(sshActivity.RunSSHDeploy)
-	w.RegisterActivity(dnsActivity.EnsureDNSRecord)
-	w.RegisterActivity(dnsActivity.RemoveDNSRecord)
-	w.RegisterActivity(notifyActivity.SendDiscordNotification)
+	// The SSH activity and the info endpoint follow the switch, whether it's set on SIGHUP or through the API
+	capabilitySwitch.OnChange(func(disabled, drivers []string) {
+		sshActivity.SetDrivers(drivers)
+		workerInfoHandler.SetCapabilities(drivers, disabled)
+	})
+
+	// Reread worker.drivers and worker.disabled on SIGHUP; deployments started afterwards use them
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			reloaded, err := config.ReloadWorkerCapabilities()
+			if err != nil {
+				zapLogger.Error("Failed to reload worker capabilities, keeping the current ones", zap.Error(err))
+				continue
+			}
+
+			capabilitySwitch.Set(reloaded.Disabled, reloaded.Drivers)
+			zapLogger.Info("Reloaded worker capabilities",
+				zap.Strings("drivers", reloaded.Drivers),
+				zap.Strings("disabled", reloaded.Disabled),
+			)
+		}
+	}()
 
-	zapLogger.Info("Worker registered, starting...")
+	// Setup routes
+	recoverMiddleware := middleware.NewRecoverMiddleware(crashReporter, zapLogger)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("GET /api/readyz", workerInfoHandler.HandleReady)
+	mux.HandleFunc("GET /api/info", workerInfoHandler.HandleInfo)
+	// Only the API, holding worker.token, may read and resend the failed notifications and pin host keys
+	if cfg.Worker.Token == "" {
+		zapLogger.Warn("worker.token is empty, the notification failure endpoints reject every request")
+	}
+	workerAuth := middleware.NewAuthMiddleware(config.AuthConfig{AdminToken: cfg.Worker.Token}, zapLogger)
+	mux.HandleFunc("GET /api/notifications/failures", workerAuth.Middleware(middleware.RoleAdmin, notificationHandler.HandleListFailures))
+	mux.HandleFunc("POST /api/notifications/failures/{id}/retry", workerAuth.Middleware(middleware.RoleAdmin, notificationHandler.HandleRetry))
+	mux.HandleFunc("POST /api/hostkeys", workerAuth.Middleware(middleware.RoleAdmin, hostKeyHandler.HandlePin))
+	mux.HandleFunc("PUT /api/capabilities", workerAuth.Middleware(middleware.RoleAdmin, capabilityHandler.HandleSet))
+	mux.HandleFunc("GET /metrics", metricsRegistry.HandleMetrics)
+
+	srv := &http.Server{
+		Addr:    cfg.Server.Host + ":" + cfg.Server.Port,
+		Handler: recoverMiddleware.Middleware(mux.ServeHTTP),
+	}
 
-	// Start worker
+	// Start workers
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	err = w.Run(worker.InterruptCh())
-	if err != nil {
-		zapLogger.Fatal("Worker failed", zap.Error(err))
+	for _, w := range workers {
+		if err := w.Start(); err != nil {
+			zapLogger.Fatal("Worker failed", zap.Error(err))
+		}
 	}
 
-	<-ctx.Done()
-	zapLogger.Info("Worker stopped")
-}
+	// Start info server in goroutine
+	go func() {
+		zapLogger.Info("Starting worker info server",
+			zap.String("host", cfg.Server.Host),
+			zap.String("port", cfg.Server.Port),
+		)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			zapLogger.Error("Worker info server failed", zap.Error(err))
+		}
+	}()
 
-func initLogger(cfg *config.Config) (*zap.Logger, error) {
-	var logger *zap.Logger
-	var err error
+	<-ctx.Done()
 
-	if cfg.Logger.Format == "console" {
-		logger, err = zap.NewDevelopment()
-	} else {
-		logger, err = zap.NewProduction()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		zapLogger.Error("Worker info server forced to shutdown", zap.Error(err))
 	}
 
-	if err != nil {
-		return nil, err
+	for _, w := range workers {
+		w.Stop()
 	}
+	zapLogger.Info("Worker stopped")
+}
 
-	// Log level is set via logger config above
-	return logger, nil
+// activityName returns the name Temporal registers an activity method under
+func activityName(activity any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(activity).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
 }
 
 func initOpenTelemetry(cfg *config.Config, logger *zap.Logger) (func(context.Context) error, error) {
@@ -0,0 +1,263 @@
+//go:build e2e
+
+// Package e2e runs deployments end to end: a worker wired like cmd/worker runs CDWorkflow on a
+// Temporal dev server in a container, deploys over SSH to an sshd container, and sets up DNS
+// records and fetches secrets through mocks of the Cloudflare and Infisical APIs.
+//
+// The suite needs Docker, and nothing else installed:
+//
+//	go test -tags e2e ./e2e/...
+package e2e
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/adapter/cloudflare"
+	"NYCU-SDC/deployment-service/internal/adapter/github"
+	"NYCU-SDC/deployment-service/internal/adapter/infisical"
+	"NYCU-SDC/deployment-service/internal/adapter/ssh"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/logger"
+	"NYCU-SDC/deployment-service/internal/resolver"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+	"go.uber.org/zap/zaptest"
+)
+
+// Settings shared by the worker, the mocks, and the requests
+const (
+	cloudflareZoneID  = "e2e-zone"
+	cloudflareToken   = "e2e-cloudflare-token"
+	infisicalToken    = "e2e-infisical-token"
+	infisicalProject  = "app"
+	infisicalEnv      = "dev"
+	environment       = "e2e"
+	domainName        = "app.e2e.example.com"
+	ipPlaceholder     = "e2e-host"
+	ipAddress         = "203.0.113.10"
+	deploymentsOnHost = "/srv/deployments/" + environment
+)
+
+// appToken is the secret injected into the deploy script; it is quoted for the shell by the
+// command builder, so it survives the remote command unchanged
+const appToken = `it's "quoted" $HOME ` + "`id`" + ` & echo injected; #`
+
+// TestDeployAndCleanup deploys org/app, checks what the script, the DNS record, and the deploy
+// host were left with, and cleans the deployment up again
+func TestDeployAndCleanup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	host := startDeployHost(ctx, t)
+	zone := newCloudflareZone(t, cloudflareZoneID, cloudflareToken)
+	temporalClient := startTemporal(ctx, t)
+	startWorker(t, temporalClient, host, zone)
+
+	// Deploy
+	deploy := deployRequest(domain.MethodDeploy, "e2e-deploy", host.commit)
+	deploy.Post.SetupDomain = domain.DomainConfig{Enable: true, Name: domainName, Value: ipPlaceholder}
+	result := runDeployment(ctx, t, temporalClient, deploy)
+	if result.Status != domain.DeployStatusSucceeded {
+		t.Fatalf("deployment %s: %s, steps %+v", result.Status, result.Error, result.Steps)
+	}
+	if !strings.Contains(result.Output, "deployed org/app to "+environment) {
+		t.Errorf("unexpected script output %q", result.Output)
+	}
+
+	for file, want := range map[string]string{
+		"app_token": appToken,
+		"trace_id":  deploy.TraceID,
+		"commit":    host.commit,
+	} {
+		got, err := host.run(ctx, t, "cat "+deploymentsOnHost+"/"+file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(got) != want {
+			t.Errorf("deploy script recorded %s %q, want %q", file, got, want)
+		}
+	}
+	record, ok := zone.record(domainName)
+	if !ok || record.Content != ipAddress {
+		t.Errorf("DNS record %+v (found %v), want %s pointing at %s", record, ok, domainName, ipAddress)
+	}
+	checkHostCleanedUp(ctx, t, host)
+
+	// Clean up
+	cleanup := deployRequest(domain.MethodCleanup, "e2e-cleanup", host.commit)
+	cleanup.Post.CleanupDomain = domain.DomainConfig{Enable: true, Name: domainName}
+	result = runDeployment(ctx, t, temporalClient, cleanup)
+	if result.Status != domain.DeployStatusSucceeded {
+		t.Fatalf("cleanup %s: %s, steps %+v", result.Status, result.Error, result.Steps)
+	}
+	if !strings.Contains(result.Output, "cleaned up org/app in "+environment) {
+		t.Errorf("unexpected script output %q", result.Output)
+	}
+
+	if host.exists(ctx, t, deploymentsOnHost) {
+		t.Errorf("cleanup script didn't remove %s", deploymentsOnHost)
+	}
+	if record, ok := zone.record(domainName); ok {
+		t.Errorf("DNS record %+v not removed", record)
+	}
+	checkHostCleanedUp(ctx, t, host)
+}
+
+// checkHostCleanedUp checks that a finished deployment left neither its checkout nor the
+// uploaded script, which holds the secrets, on the deploy host
+func checkHostCleanedUp(ctx context.Context, t *testing.T, host *deployHost) {
+	t.Helper()
+
+	checkout := deployBasePath + "/" + environment + "/org/app"
+	if host.exists(ctx, t, checkout) {
+		t.Errorf("checkout %s left on the deploy host", checkout)
+	}
+	scripts, err := host.run(ctx, t, "find /tmp -name 'cd-script.*'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(scripts) != "" {
+		t.Errorf("scripts left on the deploy host: %s", scripts)
+	}
+}
+
+// temporalImage is the Temporal CLI image whose dev server the workflows run on; the CLI
+// version matches the admin tools of docker-compose.temporal.yaml. E2E_TEMPORAL_IMAGE overrides it.
+const temporalImage = "temporalio/temporal:1.5.0"
+
+// startTemporal starts a Temporal dev server in a container and returns a client of its default namespace
+func startTemporal(ctx context.Context, t *testing.T) client.Client {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	image := temporalImage
+	if override := os.Getenv("E2E_TEMPORAL_IMAGE"); override != "" {
+		image = override
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        image,
+			Cmd:          []string{"server", "start-dev", "--ip", "0.0.0.0", "--log-level", "error"},
+			ExposedPorts: []string{"7233/tcp"},
+			WaitingFor:   wait.ForListeningPort("7233/tcp"),
+		},
+		Started: true,
+	})
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("failed to start Temporal: %v", err)
+	}
+	hostPort, err := container.PortEndpoint(ctx, "7233/tcp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	temporalClient, err := client.DialContext(ctx, client.Options{
+		HostPort:  hostPort,
+		Namespace: "default",
+		Logger:    logger.NewZapLoggerAdapter(zaptest.NewLogger(t)),
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to Temporal: %v", err)
+	}
+	t.Cleanup(temporalClient.Close)
+
+	// The port opens before the dev server has registered the default namespace
+	for {
+		_, err := temporalClient.WorkflowService().DescribeNamespace(ctx, &workflowservice.DescribeNamespaceRequest{Namespace: "default"})
+		if err == nil {
+			return temporalClient
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("default namespace not ready: %v", err)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// startWorker starts a worker running the workflows with the activities a deployment needs,
+// wired like cmd/worker to the deploy host and the mocks
+func startWorker(t *testing.T, temporalClient client.Client, host *deployHost, zone *cloudflareZone) {
+	t.Helper()
+
+	zapLogger := zaptest.NewLogger(t)
+	infisicalServer := newInfisical(t, infisicalToken, infisicalProject, infisicalEnv, map[string]string{"TOKEN": appToken})
+	githubServer := newGitHub(t)
+
+	infisicalClient := infisical.NewClient(infisicalServer.URL, infisicalToken, zapLogger)
+	sshClient := ssh.NewClient(host.config, zapLogger)
+	cloudflareClient := cloudflare.NewClient(zone.server.URL, cloudflareToken, cloudflareZoneID, nil, zapLogger)
+	githubClient := github.NewClient(githubServer.URL, "", zapLogger)
+
+	ipResolver := resolver.NewIPResolver(map[string]string{ipPlaceholder: ipAddress}, zapLogger)
+	domainPolicy := resolver.NewDomainPolicy([]string{"*.e2e.example.com"}, zapLogger)
+	secretActivity := activity.NewSecretActivity(infisicalClient, "e2e-salt", zapLogger)
+	sshActivity := activity.NewSSHActivity(sshClient, host.config, []string{domain.DriverScript}, zapLogger)
+	dnsActivity := activity.NewDNSActivity(cloudflareClient, cloudflareClient, ipResolver, domainPolicy, zapLogger)
+	manifestActivity := activity.NewManifestActivity(githubClient, zapLogger)
+
+	w := worker.New(temporalClient, workflow.TaskQueue, worker.Options{})
+	workflow.Register(w, workflow.CDWorkflowOptions{
+		Capabilities: workflow.Capabilities{DNS: true, Drivers: []string{domain.DriverScript}},
+	})
+	for _, a := range []any{
+		secretActivity.FetchInfisicalSecrets,
+		sshActivity.RunSSHDeploy,
+		dnsActivity.EnsureDNSRecord,
+		dnsActivity.RemoveDNSRecord,
+		manifestActivity.FetchDeployManifest,
+	} {
+		w.RegisterActivity(a)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+	t.Cleanup(w.Stop)
+}
+
+// deployRequest returns a request of org/app at commit into the e2e environment, with the
+// secret TOKEN of Infisical injected as APP_TOKEN
+func deployRequest(method domain.DeployMethod, traceID, commit string) domain.DeployRequest {
+	req := domain.DeployRequest{
+		TraceID:  traceID,
+		Method:   method,
+		Source:   domain.SourceInfo{Title: "e2e", Repo: "org/app", Branch: "main", Commit: commit},
+		Metadata: domain.MetadataInfo{ProjectName: "app", Component: "web", Environment: environment},
+	}
+	req.Setup.InjectSecret = domain.InjectSecretConfig{
+		Enable:      true,
+		Project:     infisicalProject,
+		Environment: infisicalEnv,
+		Secrets:     []domain.SecretMapping{{Path: "/", SecretName: "TOKEN", EnvName: "APP_TOKEN"}},
+	}
+	return req
+}
+
+// runDeployment starts CDWorkflow for req the way the API does and waits for its result
+func runDeployment(ctx context.Context, t *testing.T, temporalClient client.Client, req domain.DeployRequest) domain.DeployResult {
+	t.Helper()
+
+	options := client.StartWorkflowOptions{
+		ID:        workflow.CDWorkflowID(req.TraceID),
+		TaskQueue: workflow.TaskQueue,
+	}
+	run, err := temporalClient.ExecuteWorkflow(ctx, options, workflow.WorkflowCD, req)
+	if err != nil {
+		t.Fatalf("failed to start %s: %v", req.Method, err)
+	}
+	var result domain.DeployResult
+	if err := run.Get(ctx, &result); err != nil {
+		t.Fatalf("%s failed: %v", req.Method, err)
+	}
+	return result
+}
@@ -0,0 +1,165 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// deployBasePath is ssh.base_path on the deploy host
+const deployBasePath = "/home/deploy/cd"
+
+// deployHost is the sshd container deployments run on, built from testdata/sshd
+type deployHost struct {
+	container testcontainers.Container
+	// config is the SSH configuration of a worker deploying to the host
+	config config.SSHConfig
+	// commit is the commit of org/app the host clones
+	commit string
+}
+
+// startDeployHost starts the deploy host; it only trusts a key generated for the test,
+// and the worker only trusts the host key of the container
+func startDeployHost(ctx context.Context, t *testing.T) *deployHost {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorizedKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKeyBlock, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			FromDockerfile: testcontainers.FromDockerfile{Context: "testdata/sshd"},
+			Env: map[string]string{
+				"AUTHORIZED_KEY": strings.TrimSpace(string(ssh.MarshalAuthorizedKey(authorizedKey))),
+			},
+			ExposedPorts: []string{"22/tcp"},
+			WaitingFor:   wait.ForListeningPort("22/tcp"),
+		},
+		Started: true,
+	})
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("failed to start deploy host: %v", err)
+	}
+	h := &deployHost{container: container}
+
+	hostname, err := container.Host(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := container.MappedPort(ctx, "22/tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker dials hostname and checks the host key against both the name and the address
+	hostKey, err := h.run(ctx, t, "cat /etc/ssh/ssh_host_ed25519_key.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+	if err != nil {
+		t.Fatalf("failed to parse host key: %v", err)
+	}
+	addresses := []string{knownhosts.Normalize(net.JoinHostPort(hostname, port.Port()))}
+	if ips, err := net.LookupHost(hostname); err == nil {
+		for _, ip := range ips {
+			addresses = append(addresses, knownhosts.Normalize(net.JoinHostPort(ip, port.Port())))
+		}
+	}
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHostsFile, []byte(knownhosts.Line(addresses, key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h.commit, err = h.run(ctx, t, "git -C /srv/git/org/app rev-parse HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.commit = strings.TrimSpace(h.commit)
+
+	h.config = config.SSHConfig{
+		Host:                  hostname,
+		Port:                  port.Int(),
+		User:                  "deploy",
+		BasePath:              deployBasePath,
+		PrivateKey:            string(pem.EncodeToMemory(privateKeyBlock)),
+		KnownHostsFile:        knownHostsFile,
+		StrictHostKeyChecking: true,
+	}
+	return h
+}
+
+// run runs command with sh on the host and returns its output; a non-zero exit status
+// is returned as an error with the output
+func (h *deployHost) run(ctx context.Context, t *testing.T, command string) (string, error) {
+	t.Helper()
+
+	status, reader, err := h.container.Exec(ctx, []string{"sh", "-c", command}, tcexec.Multiplexed())
+	if err != nil {
+		return "", err
+	}
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	if status != 0 {
+		return string(output), &exitError{command: command, status: status, output: string(output)}
+	}
+	return string(output), nil
+}
+
+// exists reports whether path exists on the host
+func (h *deployHost) exists(ctx context.Context, t *testing.T, path string) bool {
+	t.Helper()
+
+	_, err := h.run(ctx, t, "test -e "+path)
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return true
+}
+
+// exitError is a command that exited with a non-zero status on the host
+type exitError struct {
+	command string
+	status  int
+	output  string
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("command %q exited with status %d: %s", e.command, e.status, e.output)
+}
@@ -0,0 +1,160 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"NYCU-SDC/deployment-service/internal/adapter/cloudflare"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// cloudflareZone is a mock of the DNS records API of one Cloudflare zone
+type cloudflareZone struct {
+	server  *httptest.Server
+	zoneID  string
+	token   string
+	mu      sync.Mutex
+	records map[string]cloudflare.DNSRecord
+	nextID  int
+}
+
+// newCloudflareZone starts a mock of the zone zoneID accepting token
+func newCloudflareZone(t *testing.T, zoneID, token string) *cloudflareZone {
+	t.Helper()
+
+	z := &cloudflareZone{zoneID: zoneID, token: token, records: make(map[string]cloudflare.DNSRecord)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /zones/{zone}/dns_records", z.authorized(z.handleList))
+	mux.HandleFunc("POST /zones/{zone}/dns_records", z.authorized(z.handleCreate))
+	mux.HandleFunc("PUT /zones/{zone}/dns_records/{id}", z.authorized(z.handleUpdate))
+	mux.HandleFunc("DELETE /zones/{zone}/dns_records/{id}", z.authorized(z.handleDelete))
+	z.server = httptest.NewServer(mux)
+	t.Cleanup(z.server.Close)
+	return z
+}
+
+// record returns the A record of name
+func (z *cloudflareZone) record(name string) (cloudflare.DNSRecord, bool) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for _, record := range z.records {
+		if record.Type == "A" && record.Name == name {
+			return record, true
+		}
+	}
+	return cloudflare.DNSRecord{}, false
+}
+
+// authorized rejects requests for other zones or without the token, like Cloudflare
+func (z *cloudflareZone) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+z.token || r.PathValue("zone") != z.zoneID {
+			writeCloudflare(w, http.StatusForbidden, false, nil)
+			return
+		}
+		z.mu.Lock()
+		defer z.mu.Unlock()
+		next(w, r)
+	}
+}
+
+func (z *cloudflareZone) handleList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	records := []cloudflare.DNSRecord{}
+	for _, record := range z.records {
+		if (query.Get("name") == "" || record.Name == query.Get("name")) && (query.Get("type") == "" || record.Type == query.Get("type")) {
+			records = append(records, record)
+		}
+	}
+	writeCloudflare(w, http.StatusOK, true, records)
+}
+
+func (z *cloudflareZone) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var record cloudflare.DNSRecord
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		writeCloudflare(w, http.StatusBadRequest, false, nil)
+		return
+	}
+	z.nextID++
+	record.ID = fmt.Sprintf("record-%d", z.nextID)
+	z.records[record.ID] = record
+	writeCloudflare(w, http.StatusOK, true, record)
+}
+
+func (z *cloudflareZone) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := z.records[id]; !ok {
+		writeCloudflare(w, http.StatusNotFound, false, nil)
+		return
+	}
+	var record cloudflare.DNSRecord
+	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+		writeCloudflare(w, http.StatusBadRequest, false, nil)
+		return
+	}
+	record.ID = id
+	z.records[id] = record
+	writeCloudflare(w, http.StatusOK, true, record)
+}
+
+func (z *cloudflareZone) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := z.records[id]; !ok {
+		writeCloudflare(w, http.StatusNotFound, false, nil)
+		return
+	}
+	delete(z.records, id)
+	writeCloudflare(w, http.StatusOK, true, map[string]string{"id": id})
+}
+
+// writeCloudflare writes result in the response envelope of the Cloudflare API
+func writeCloudflare(w http.ResponseWriter, status int, success bool, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": success,
+		"errors":  []any{},
+		"result":  result,
+	})
+}
+
+// newInfisical starts a mock of the Infisical API serving secrets, by name, of one
+// workspace and environment to requests with token
+func newInfisical(t *testing.T, token, workspace, environment string, secrets map[string]string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/secrets/raw/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, `{"message":"invalid token"}`, http.StatusUnauthorized)
+			return
+		}
+		query := r.URL.Query()
+		value, ok := secrets[r.PathValue("name")]
+		if !ok || query.Get("workspaceSlug") != workspace || query.Get("environment") != environment {
+			http.Error(w, `{"message":"secret not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"secret": map[string]string{"key": r.PathValue("name"), "value": value},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newGitHub starts a mock of the GitHub API without any files, so deployments have no
+// deploy manifest
+func newGitHub(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,5 @@
+#!/bin/bash
+# Removes what deploy.sh recorded
+set -euo pipefail
+rm -rf "/srv/deployments/$ENVIRONMENT"
+echo "cleaned up $REPO_NAME in $ENVIRONMENT"
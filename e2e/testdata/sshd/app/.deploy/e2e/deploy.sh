@@ -0,0 +1,8 @@
+#!/bin/bash
+# Records what the deployment ran with, for the end-to-end tests to check
+set -euo pipefail
+mkdir -p "/srv/deployments/$ENVIRONMENT"
+printf '%s' "$APP_TOKEN" > "/srv/deployments/$ENVIRONMENT/app_token"
+printf '%s' "$TRACE_ID" > "/srv/deployments/$ENVIRONMENT/trace_id"
+git rev-parse HEAD > "/srv/deployments/$ENVIRONMENT/commit"
+echo "deployed $REPO_NAME to $ENVIRONMENT"
@@ -0,0 +1,7 @@
+#!/bin/sh
+# Authorizes the key of the test's worker and starts sshd in the foreground
+set -e
+printf '%s\n' "$AUTHORIZED_KEY" > /home/deploy/.ssh/authorized_keys
+chown deploy:deploy /home/deploy/.ssh/authorized_keys
+chmod 600 /home/deploy/.ssh/authorized_keys
+exec /usr/sbin/sshd -D -e
@@ -0,0 +1,89 @@
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"context"
+	"fmt"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+	"go.uber.org/zap"
+)
+
+// CIActivity handles the CI gate of deployments
+type CIActivity struct {
+	statusProvider domain.CommitStatusProvider
+	logger         *zap.Logger
+}
+
+// NewCIActivity creates a new CI activity
+func NewCIActivity(statusProvider domain.CommitStatusProvider, logger *zap.Logger) *CIActivity {
+	return &CIActivity{
+		statusProvider: statusProvider,
+		logger:         logger,
+	}
+}
+
+// CheckCommitStatus checks the CI checks of the deployed commit and returns the ones still
+// pending, including required checks that aren't reported yet; none once they all passed.
+// A failed check is returned as a non-retryable CIError.
+func (a *CIActivity) CheckCommitStatus(ctx context.Context, req domain.DeployRequest) ([]string, error) {
+	logger := applog.WithDeployment(activity.GetLogger(ctx), req)
+
+	checks, err := a.statusProvider.FetchCommitChecks(ctx, req.Source.Repo, req.Source.Commit)
+	if err != nil {
+		logger.Error("Failed to fetch commit checks",
+			zap.Error(err),
+			zap.String("commit", req.Source.Commit),
+		)
+		return nil, classifyError(err)
+	}
+
+	pending, failed := evaluateChecks(checks, req.Setup.WaitForCI.RequiredChecks)
+	if len(failed) > 0 {
+		err := fmt.Errorf("CI checks failed on commit %s: %s", req.Source.Commit, strings.Join(failed, ", "))
+		return nil, newCIError(err.Error(), err)
+	}
+
+	logger.Info("Checked commit status",
+		zap.String("commit", req.Source.Commit),
+		zap.Int("checks", len(checks)),
+		zap.Strings("pending", pending),
+	)
+	return pending, nil
+}
+
+// evaluateChecks sorts the checks a deployment waits for into pending and failed.
+// Without required checks every reported check is waited for, and a commit nothing
+// was reported on yet is pending. The same check reported twice passes if either passed.
+func evaluateChecks(checks []domain.CommitCheck, required []string) (pending, failed []string) {
+	states := make(map[string]string, len(checks))
+	names := make([]string, 0, len(checks))
+	for _, check := range checks {
+		state, seen := states[check.Name]
+		if !seen {
+			names = append(names, check.Name)
+		}
+		if state != domain.CheckStateSuccess {
+			states[check.Name] = check.State
+		}
+	}
+
+	if len(required) == 0 {
+		if len(names) == 0 {
+			return []string{"(no checks reported)"}, nil
+		}
+		required = names
+	}
+	for _, name := range required {
+		switch states[name] {
+		case domain.CheckStateSuccess:
+		case domain.CheckStateFailure:
+			failed = append(failed, name)
+		default:
+			pending = append(pending, name)
+		}
+	}
+	return pending, failed
+}
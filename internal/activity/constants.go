@@ -2,9 +2,24 @@ package activity
 
 // Activity name constants for type-safe activity invocation
 const (
-	ActivityFetchInfisicalSecrets   = "FetchInfisicalSecrets"
-	ActivityRunSSHDeploy            = "RunSSHDeploy"
-	ActivityEnsureDNSRecord         = "EnsureDNSRecord"
-	ActivityRemoveDNSRecord         = "RemoveDNSRecord"
-	ActivitySendDiscordNotification = "SendDiscordNotification"
+	ActivityFetchInfisicalSecrets          = "FetchInfisicalSecrets"
+	ActivityRunSSHDeploy                   = "RunSSHDeploy"
+	ActivityEnsureDNSRecord                = "EnsureDNSRecord"
+	ActivityRemoveDNSRecord                = "RemoveDNSRecord"
+	ActivityEnsureCanaryDNSRecord          = "EnsureCanaryDNSRecord"
+	ActivityRemoveCanaryDNSRecords         = "RemoveCanaryDNSRecords"
+	ActivitySendDiscordNotification        = "SendDiscordNotification"
+	ActivitySendTrackedDiscordNotification = "SendTrackedDiscordNotification"
+	ActivitySendDiscordProgress            = "SendDiscordProgress"
+	ActivityCheckNotificationAck           = "CheckNotificationAck"
+	ActivitySendNotificationReminder       = "SendNotificationReminder"
+	ActivitySendTestNotification           = "SendTestNotification"
+	ActivityFetchDeployManifest            = "FetchDeployManifest"
+	ActivityCheckHealth                    = "CheckHealth"
+	ActivityCheckHealthAt                  = "CheckHealthAt"
+	ActivityPinHostKey                     = "PinHostKey"
+	ActivityQueryHostLoad                  = "QueryHostLoad"
+	ActivityCheckCommitStatus              = "CheckCommitStatus"
+	ActivityCheckHostHealth                = "CheckHostHealth"
+	ActivitySignDeploymentReceipt          = "SignDeploymentReceipt"
 )
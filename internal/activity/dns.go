@@ -12,15 +12,20 @@ import (
 // DNSActivity handles DNS-related activities
 type DNSActivity struct {
 	dnsProvider domain.DNSProvider
+	canaries    domain.CanaryDNSProvider
 	ipResolver  *resolver.IPResolver
-	logger      *zap.Logger
+	// domains keeps names from requests and manifests inside the allowed domains
+	domains *resolver.DomainPolicy
+	logger  *zap.Logger
 }
 
 // NewDNSActivity creates a new DNS activity
-func NewDNSActivity(dnsProvider domain.DNSProvider, ipResolver *resolver.IPResolver, logger *zap.Logger) *DNSActivity {
+func NewDNSActivity(dnsProvider domain.DNSProvider, canaries domain.CanaryDNSProvider, ipResolver *resolver.IPResolver, domains *resolver.DomainPolicy, logger *zap.Logger) *DNSActivity {
 	return &DNSActivity{
 		dnsProvider: dnsProvider,
+		canaries:    canaries,
 		ipResolver:  ipResolver,
+		domains:     domains,
 		logger:      logger,
 	}
 }
@@ -33,6 +38,11 @@ func (a *DNSActivity) EnsureDNSRecord(ctx context.Context, domain, ipPlaceholder
 		zap.String("ip_placeholder", ipPlaceholder),
 	)
 
+	if err := a.domains.Check(domain); err != nil {
+		logger.Error("Refusing to set up DNS record", zap.Error(err))
+		return newValidationError(err.Error(), err)
+	}
+
 	// Resolve IP placeholder to actual IP address
 	ip, err := a.ipResolver.Resolve(ipPlaceholder)
 	if err != nil {
@@ -40,7 +50,7 @@ func (a *DNSActivity) EnsureDNSRecord(ctx context.Context, domain, ipPlaceholder
 			zap.Error(err),
 			zap.String("placeholder", ipPlaceholder),
 		)
-		return err
+		return newValidationError(err.Error(), err)
 	}
 
 	logger.Info("Resolved IP placeholder",
@@ -54,7 +64,7 @@ func (a *DNSActivity) EnsureDNSRecord(ctx context.Context, domain, ipPlaceholder
 			zap.String("domain", domain),
 			zap.String("ip", ip),
 		)
-		return err
+		return classifyError(err)
 	}
 
 	logger.Info("DNS record ensured successfully",
@@ -72,12 +82,17 @@ func (a *DNSActivity) RemoveDNSRecord(ctx context.Context, domain string) error
 		zap.String("domain", domain),
 	)
 
+	if err := a.domains.Check(domain); err != nil {
+		logger.Error("Refusing to remove DNS record", zap.Error(err))
+		return newValidationError(err.Error(), err)
+	}
+
 	if err := a.dnsProvider.RemoveRecord(ctx, domain); err != nil {
 		logger.Error("Failed to remove DNS record",
 			zap.Error(err),
 			zap.String("domain", domain),
 		)
-		return err
+		return classifyError(err)
 	}
 
 	logger.Info("DNS record removed successfully",
@@ -86,3 +101,66 @@ func (a *DNSActivity) RemoveDNSRecord(ctx context.Context, domain string) error
 
 	return nil
 }
+
+// EnsureCanaryDNSRecord ensures the per-deployment canary A record next to the stable record exists,
+// replacing the canary records of earlier deployments
+func (a *DNSActivity) EnsureCanaryDNSRecord(ctx context.Context, name, ipPlaceholder, stable string) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Ensuring canary DNS record",
+		zap.String("domain", name),
+		zap.String("stable", stable),
+		zap.String("ip_placeholder", ipPlaceholder),
+	)
+
+	if err := a.domains.Check(name); err != nil {
+		logger.Error("Refusing to set up canary DNS record", zap.Error(err))
+		return newValidationError(err.Error(), err)
+	}
+
+	ip, err := a.ipResolver.Resolve(ipPlaceholder)
+	if err != nil {
+		logger.Error("Failed to resolve IP placeholder",
+			zap.Error(err),
+			zap.String("placeholder", ipPlaceholder),
+		)
+		return newValidationError(err.Error(), err)
+	}
+
+	if err := a.canaries.EnsureCanaryRecord(ctx, name, ip, stable); err != nil {
+		logger.Error("Failed to ensure canary DNS record",
+			zap.Error(err),
+			zap.String("domain", name),
+			zap.String("ip", ip),
+		)
+		return classifyError(err)
+	}
+
+	logger.Info("Canary DNS record ensured successfully",
+		zap.String("domain", name),
+		zap.String("ip", ip),
+	)
+
+	return nil
+}
+
+// RemoveCanaryDNSRecords removes the canary records of a stable record
+func (a *DNSActivity) RemoveCanaryDNSRecords(ctx context.Context, stable string) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Removing canary DNS records",
+		zap.String("stable", stable),
+	)
+
+	if err := a.canaries.RemoveCanaryRecords(ctx, stable); err != nil {
+		logger.Error("Failed to remove canary DNS records",
+			zap.Error(err),
+			zap.String("stable", stable),
+		)
+		return classifyError(err)
+	}
+
+	logger.Info("Canary DNS records removed successfully",
+		zap.String("stable", stable),
+	)
+
+	return nil
+}
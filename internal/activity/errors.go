@@ -0,0 +1,73 @@
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"errors"
+	"net"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// Application error types returned by activities.
+// Auth, script, and validation errors are non-retryable so bad configuration fails fast;
+// network errors are retried by the workflow's retry policy. Panics are bugs that a retry
+// would hit again, so they are non-retryable too, as are failed CI checks.
+const (
+	ErrorTypeAuth       = "AuthError"
+	ErrorTypeNetwork    = "NetworkError"
+	ErrorTypeScript     = "ScriptError"
+	ErrorTypeValidation = "ValidationError"
+	ErrorTypePanic      = "PanicError"
+	ErrorTypeCI         = "CIError"
+)
+
+// newAuthError wraps an error caused by rejected credentials
+func newAuthError(message string, cause error) error {
+	return temporal.NewNonRetryableApplicationError(message, ErrorTypeAuth, cause)
+}
+
+// newNetworkError wraps a transient error talking to an external service
+func newNetworkError(message string, cause error) error {
+	return temporal.NewApplicationError(message, ErrorTypeNetwork, cause)
+}
+
+// newScriptError wraps a failure of the deploy or cleanup script
+func newScriptError(message string, cause error) error {
+	return temporal.NewNonRetryableApplicationError(message, ErrorTypeScript, cause)
+}
+
+// newValidationError wraps an error caused by an invalid request or configuration
+func newValidationError(message string, cause error) error {
+	return temporal.NewNonRetryableApplicationError(message, ErrorTypeValidation, cause)
+}
+
+// newCIError wraps CI checks of the deployed commit that failed or didn't finish in time
+func newCIError(message string, cause error) error {
+	return temporal.NewNonRetryableApplicationError(message, ErrorTypeCI, cause)
+}
+
+// classifyError maps adapter errors onto the activity error types.
+// Errors that don't match a category are returned unchanged and retried as usual.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) || errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, domain.ErrUnauthorized):
+		return newAuthError(err.Error(), err)
+	case errors.Is(err, domain.ErrInvalidRequest):
+		return newValidationError(err.Error(), err)
+	case errors.Is(err, domain.ErrUnavailable), errors.As(err, &netErr):
+		return newNetworkError(err.Error(), err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,92 @@
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/resolver"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.uber.org/zap"
+)
+
+// healthCheckTimeout is the timeout of a single health check request
+const healthCheckTimeout = 10 * time.Second
+
+// HealthActivity handles post-deployment health check activities
+type HealthActivity struct {
+	httpClient *http.Client
+	ipResolver *resolver.IPResolver
+	logger     *zap.Logger
+}
+
+// NewHealthActivity creates a new health check activity
+func NewHealthActivity(ipResolver *resolver.IPResolver, logger *zap.Logger) *HealthActivity {
+	return &HealthActivity{
+		httpClient: &http.Client{Timeout: healthCheckTimeout},
+		ipResolver: ipResolver,
+		logger:     logger,
+	}
+}
+
+// CheckHealth performs a single health check against the given URL.
+// The workflow retries it until the health check timeout is reached.
+func (a *HealthActivity) CheckHealth(ctx context.Context, url string) error {
+	return a.check(ctx, a.httpClient, url)
+}
+
+// CheckHealthAt performs a single health check against the given URL on the host the IP
+// placeholder resolves to, whatever DNS currently points at, so a deployment on a new host
+// is verified before its DNS record is moved there.
+func (a *HealthActivity) CheckHealthAt(ctx context.Context, url, ipPlaceholder string) error {
+	ip, err := a.ipResolver.Resolve(ipPlaceholder)
+	if err != nil {
+		return newValidationError(err.Error(), err)
+	}
+
+	// Keep the URL, so the Host header and TLS server name stay those of the service
+	dialer := &net.Dialer{Timeout: healthCheckTimeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+	}
+	defer transport.CloseIdleConnections()
+
+	return a.check(ctx, &http.Client{Timeout: healthCheckTimeout, Transport: transport}, url)
+}
+
+func (a *HealthActivity) check(ctx context.Context, httpClient *http.Client, url string) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Checking deployment health",
+		zap.String("url", url),
+		zap.Int32("attempt", activity.GetInfo(ctx).Attempt),
+	)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return newValidationError(fmt.Sprintf("invalid health check URL: %v", err), err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logger.Warn("Health check request failed", zap.Error(err), zap.String("url", url))
+		return newNetworkError(fmt.Sprintf("health check request failed: %v", err), err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logger.Warn("Health check returned unhealthy status", zap.Int("status_code", resp.StatusCode), zap.String("url", url))
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+
+	logger.Info("Deployment is healthy", zap.String("url", url))
+	return nil
+}
@@ -0,0 +1,84 @@
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"go.temporal.io/sdk/activity"
+	"go.uber.org/zap"
+)
+
+// HostHealthActivity handles the health check of the deploy host before a deployment
+type HostHealthActivity struct {
+	probe     domain.HostHealthProbe
+	sshConfig config.SSHConfig
+	logger    *zap.Logger
+}
+
+// NewHostHealthActivity creates a new host health activity; probe is nil when ssh.host_health.source is not set
+func NewHostHealthActivity(probe domain.HostHealthProbe, sshConfig config.SSHConfig, logger *zap.Logger) *HostHealthActivity {
+	return &HostHealthActivity{
+		probe:     probe,
+		sshConfig: sshConfig,
+		logger:    logger,
+	}
+}
+
+// CheckHostHealth queries the resource usage of the deploy host of req and lists the limits
+// of ssh.host_health it exceeds in Problems
+func (a *HostHealthActivity) CheckHostHealth(ctx context.Context, req domain.DeployRequest) (domain.HostHealth, error) {
+	logger := applog.WithDeployment(activity.GetLogger(ctx), req)
+
+	if a.probe == nil {
+		err := fmt.Errorf("ssh.host_health.source is not configured on this worker")
+		return domain.HostHealth{}, newValidationError(err.Error(), err)
+	}
+
+	var hostName string
+	if req.Host != nil {
+		hostName = req.Host.Name
+	}
+	host, ok := a.sshConfig.LookupHost(hostName)
+	if !ok {
+		err := fmt.Errorf("unknown deploy host %q", hostName)
+		return domain.HostHealth{}, newValidationError(err.Error(), err)
+	}
+
+	port := host.Port
+	if a.sshConfig.HostHealth.Source == config.HostLoadSourceNodeExporter {
+		port = a.sshConfig.HostLoad.NodeExporterPort
+	}
+	health, err := a.probe.QueryHealth(ctx, net.JoinHostPort(host.Host, strconv.Itoa(port)), a.sshConfig.HostHealth.Mountpoint)
+	if err != nil {
+		logger.Error("Failed to query host health", zap.Error(err), zap.String("host", host.Name))
+		return domain.HostHealth{}, classifyError(err)
+	}
+	health.Host = host.Name
+	health.Problems = hostProblems(health, a.sshConfig.HostHealth)
+
+	logger.Info("Checked deploy host health",
+		zap.String("host", health.Host),
+		zap.Float64("load1", health.Load1),
+		zap.Int("cpus", health.CPUs),
+		zap.Float64("disk_usage", health.DiskUsage),
+		zap.Strings("problems", health.Problems),
+	)
+	return health, nil
+}
+
+// hostProblems describes the limits the host exceeds
+func hostProblems(health domain.HostHealth, limits config.HostHealthConfig) []string {
+	var problems []string
+	if health.DiskUsage > limits.MaxDiskUsage {
+		problems = append(problems, fmt.Sprintf("disk %s is %.0f%% full (limit %.0f%%)", limits.Mountpoint, health.DiskUsage*100, limits.MaxDiskUsage*100))
+	}
+	if load := (domain.HostLoad{Load1: health.Load1, CPUs: health.CPUs}).Utilization(); load > limits.MaxLoad {
+		problems = append(problems, fmt.Sprintf("load is %.2f per CPU (limit %.2f)", load, limits.MaxLoad))
+	}
+	return problems
+}
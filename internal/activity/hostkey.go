@@ -0,0 +1,57 @@
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKeyActivity handles SSH host key rotation activities
+type HostKeyActivity struct {
+	hostKeyManager domain.HostKeyManager
+	sshConfig      config.SSHConfig
+	logger         *zap.Logger
+}
+
+// NewHostKeyActivity creates a new host key activity
+func NewHostKeyActivity(hostKeyManager domain.HostKeyManager, sshConfig config.SSHConfig, logger *zap.Logger) *HostKeyActivity {
+	return &HostKeyActivity{
+		hostKeyManager: hostKeyManager,
+		sshConfig:      sshConfig,
+		logger:         logger,
+	}
+}
+
+// PinHostKey pins a new host key on this worker. An empty host means the configured deploy host.
+func (a *HostKeyActivity) PinHostKey(ctx context.Context, host, publicKey string, grace time.Duration) (string, error) {
+	logger := activity.GetLogger(ctx)
+
+	if host == "" {
+		host = fmt.Sprintf("%s:%d", a.sshConfig.Host, a.sshConfig.Port)
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return "", newValidationError(fmt.Sprintf("invalid public key: %v", err), err)
+	}
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	logger.Info("Pinning SSH host key",
+		zap.String("host", host),
+		zap.String("fingerprint", fingerprint),
+		zap.Duration("grace", grace),
+	)
+
+	if err := a.hostKeyManager.PinHostKey(ctx, host, publicKey, grace); err != nil {
+		logger.Error("Failed to pin SSH host key", zap.Error(err), zap.String("host", host))
+		return "", fmt.Errorf("failed to pin host key: %w", err)
+	}
+
+	return fingerprint, nil
+}
@@ -0,0 +1,78 @@
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"go.temporal.io/sdk/activity"
+	"go.uber.org/zap"
+)
+
+// LoadActivity handles deploy host load activities
+type LoadActivity struct {
+	probe     domain.LoadProbe
+	sshConfig config.SSHConfig
+	logger    *zap.Logger
+}
+
+// NewLoadActivity creates a new load activity; probe is nil when ssh.host_load.source is not set
+func NewLoadActivity(probe domain.LoadProbe, sshConfig config.SSHConfig, logger *zap.Logger) *LoadActivity {
+	return &LoadActivity{
+		probe:     probe,
+		sshConfig: sshConfig,
+		logger:    logger,
+	}
+}
+
+// QueryHostLoad queries the load of the named deploy hosts concurrently.
+// Hosts whose load can't be queried are reported with an error instead of failing the activity.
+func (a *LoadActivity) QueryHostLoad(ctx context.Context, hosts []string) ([]domain.HostLoad, error) {
+	logger := activity.GetLogger(ctx)
+
+	if a.probe == nil {
+		err := fmt.Errorf("ssh.host_load.source is not configured on this worker")
+		return nil, newValidationError(err.Error(), err)
+	}
+
+	loads := make([]domain.HostLoad, len(hosts))
+	var wg sync.WaitGroup
+	for i, name := range hosts {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			loads[i] = a.query(ctx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	for _, load := range loads {
+		if load.Error != "" {
+			logger.Warn("Failed to query host load", zap.String("host", load.Host), zap.String("error", load.Error))
+		}
+	}
+	return loads, nil
+}
+
+// query queries the load of a single host
+func (a *LoadActivity) query(ctx context.Context, name string) domain.HostLoad {
+	host, ok := a.sshConfig.LookupHost(name)
+	if !ok {
+		return domain.HostLoad{Host: name, Error: "unknown deploy host"}
+	}
+
+	port := host.Port
+	if a.sshConfig.HostLoad.Source == config.HostLoadSourceNodeExporter {
+		port = a.sshConfig.HostLoad.NodeExporterPort
+	}
+	load, err := a.probe.QueryLoad(ctx, net.JoinHostPort(host.Host, strconv.Itoa(port)))
+	load.Host = name
+	if err != nil {
+		load.Error = err.Error()
+	}
+	return load
+}
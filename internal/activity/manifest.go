@@ -0,0 +1,142 @@
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"text/template"
+
+	"go.temporal.io/sdk/activity"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestActivity handles .deploy manifest activities
+type ManifestActivity struct {
+	repositoryProvider domain.RepositoryProvider
+	logger             *zap.Logger
+}
+
+// NewManifestActivity creates a new manifest activity
+func NewManifestActivity(repositoryProvider domain.RepositoryProvider, logger *zap.Logger) *ManifestActivity {
+	return &ManifestActivity{
+		repositoryProvider: repositoryProvider,
+		logger:             logger,
+	}
+}
+
+// manifestTemplateData is the data available to manifest templates
+type manifestTemplateData struct {
+	Repo        string
+	Branch      string
+	Commit      string
+	PRNumber    string
+	ProjectName string
+	Component   string
+	Environment string
+}
+
+// FetchDeployManifest fetches .deploy/<env>/manifest.yaml at the requested commit.
+// Returns nil if the repository has no manifest for the environment.
+func (a *ManifestActivity) FetchDeployManifest(ctx context.Context, req domain.DeployRequest) (*domain.DeployManifest, error) {
+	logger := applog.WithDeployment(activity.GetLogger(ctx), req)
+	path := fmt.Sprintf(".deploy/%s/manifest.yaml", req.Metadata.Environment)
+
+	logger.Info("Fetching deploy manifest",
+		zap.String("commit", req.Source.Commit),
+		zap.String("path", path),
+	)
+
+	content, err := a.repositoryProvider.FetchFile(ctx, req.Source.Repo, req.Source.Commit, path)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			logger.Info("No deploy manifest found", zap.String("path", path))
+			return nil, nil
+		}
+		logger.Error("Failed to fetch deploy manifest",
+			zap.Error(err),
+			zap.String("path", path),
+		)
+		return nil, classifyError(err)
+	}
+
+	var manifest domain.DeployManifest
+	if err := yaml.Unmarshal(content, &manifest); err != nil {
+		return nil, newValidationError(fmt.Sprintf("failed to parse %s: %v", path, err), err)
+	}
+
+	if err := validateManifest(manifest); err != nil {
+		return nil, newValidationError(fmt.Sprintf("invalid %s: %v", path, err), err)
+	}
+
+	data := manifestTemplateData{
+		Repo:        req.Source.Repo,
+		Branch:      req.Source.Branch,
+		Commit:      req.Source.Commit,
+		PRNumber:    req.Source.PRNumber,
+		ProjectName: req.Metadata.ProjectName,
+		Component:   req.Metadata.Component,
+		Environment: req.Metadata.Environment,
+	}
+	if manifest.Domain.Template != "" {
+		name, err := renderManifestTemplate(manifest.Domain.Template, data)
+		if err != nil {
+			return nil, newValidationError(fmt.Sprintf("failed to render domain template in %s: %v", path, err), err)
+		}
+		manifest.Domain.Name = name
+	}
+	if manifest.HealthCheck.URL != "" {
+		url, err := renderManifestTemplate(manifest.HealthCheck.URL, data)
+		if err != nil {
+			return nil, newValidationError(fmt.Sprintf("failed to render health check URL in %s: %v", path, err), err)
+		}
+		manifest.HealthCheck.URL = url
+	}
+
+	logger.Info("Deploy manifest fetched",
+		zap.String("path", path),
+		zap.String("driver", manifest.Driver),
+		zap.Int("secret_count", len(manifest.Secrets.Mappings)),
+		zap.String("domain", manifest.Domain.Name),
+	)
+
+	return &manifest, nil
+}
+
+// validateManifest checks the values that are used to build remote commands
+func validateManifest(manifest domain.DeployManifest) error {
+	switch manifest.Interpreter {
+	case "", "bash", "sh", "python", "node":
+	default:
+		return fmt.Errorf("unsupported interpreter %q", manifest.Interpreter)
+	}
+	switch manifest.Driver {
+	case "", domain.DriverScript, domain.DriverCompose:
+	default:
+		return fmt.Errorf("unsupported driver %q", manifest.Driver)
+	}
+	for i, mapping := range manifest.Secrets.Mappings {
+		if mapping.Path == "" || mapping.SecretName == "" || mapping.EnvName == "" {
+			return fmt.Errorf("secrets.mappings[%d] requires path, secret_name, and env_name", i)
+		}
+		if !domain.ValidEnvName(mapping.EnvName) {
+			return fmt.Errorf("secrets.mappings[%d].env_name %q is not a valid environment variable name", i, mapping.EnvName)
+		}
+	}
+	return nil
+}
+
+func renderManifestTemplate(text string, data manifestTemplateData) (string, error) {
+	tmpl, err := template.New("manifest").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -1,39 +1,98 @@
 package activity
 
 import (
+	"NYCU-SDC/deployment-service/internal/diagnosis"
 	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"NYCU-SDC/deployment-service/internal/metrics"
+	"NYCU-SDC/deployment-service/internal/resolver"
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/log"
 	"go.uber.org/zap"
 )
 
+// NotificationChannel is a configured channel deployments' notifications can be routed to
+type NotificationChannel struct {
+	Notifier domain.Notifier
+	// Mentions resolves the users of the channel to mention on failure; nil mentions nobody
+	Mentions *resolver.MentionResolver
+}
+
 // NotifyActivity handles notification activities
 type NotifyActivity struct {
-	notifier domain.Notifier
-	logger   *zap.Logger
+	channels    map[string]NotificationChannel
+	router      *resolver.NotificationRouter
+	tracker     domain.NotificationTracker
+	threads     domain.ThreadNotifier
+	threadStore domain.ThreadStore
+	failures    domain.NotificationFailureStore
+	logger      *zap.Logger
+
+	// sent and failed count the delivered and failed notifications per channel
+	sent   *metrics.CounterVec
+	failed *metrics.CounterVec
+
+	// threadMu keeps concurrent notifications of a deployment from starting two threads
+	threadMu sync.Mutex
 }
 
-// NewNotifyActivity creates a new notification activity
-func NewNotifyActivity(notifier domain.Notifier, logger *zap.Logger) *NotifyActivity {
+// NewNotifyActivity creates a new notification activity sending to the channels router picks.
+// On Discord, tracked notifications are sent through tracker and notifications are posted into
+// a thread per deployment when threads is not nil.
+// Notifications that can't be delivered are kept in failures until they are resent.
+func NewNotifyActivity(channels map[string]NotificationChannel, router *resolver.NotificationRouter, tracker domain.NotificationTracker, threads domain.ThreadNotifier, threadStore domain.ThreadStore, failures domain.NotificationFailureStore, registry *metrics.Registry, logger *zap.Logger) *NotifyActivity {
 	return &NotifyActivity{
-		notifier: notifier,
-		logger:   logger,
+		channels:    channels,
+		router:      router,
+		tracker:     tracker,
+		threads:     threads,
+		threadStore: threadStore,
+		failures:    failures,
+		logger:      logger,
+		sent:        registry.NewCounterVec("notifications_sent_total", "Notifications delivered, by channel.", "channel"),
+		failed:      registry.NewCounterVec("notifications_failed_total", "Notification delivery attempts that failed, by channel.", "channel"),
 	}
 }
 
-// SendDiscordNotification sends a Discord notification
-// errMsg should be nil or empty string for success, or contain the error message for failures
-func (a *NotifyActivity) SendDiscordNotification(ctx context.Context, req domain.DeployRequest, status string, errMsg *string) error {
-	logger := activity.GetLogger(ctx)
+// notification is a deployment notification ready to be sent
+type notification struct {
+	// kind is one of the domain.NotificationKind constants
+	kind     string
+	title    string
+	message  string
+	success  bool
+	metadata map[string]string
+	// mentions are the users pinged with the notification
+	mentions []string
+}
 
+// notificationStatusStarted is the status of the notification sent when a deployment starts
+const notificationStatusStarted = "Started"
+
+// buildNotification builds the notification of a deployment
+// errMsg should be nil or empty string for success, or contain the error message for failures
+func buildNotification(req domain.DeployRequest, status string, errMsg *string) notification {
 	success := errMsg == nil || *errMsg == ""
+	kind := domain.NotificationKindFinish
+	if !success {
+		kind = domain.NotificationKindFailure
+	} else if status == notificationStatusStarted {
+		kind = domain.NotificationKindStart
+	}
 	title := fmt.Sprintf("Deployment %s", status)
 	message := fmt.Sprintf("Deployment %s for %s", status, req.Metadata.ProjectName)
 
 	if errMsg != nil && *errMsg != "" {
 		message = fmt.Sprintf("%s\nError: %s", message, *errMsg)
+		if cause := diagnosis.Classify(*errMsg); cause != nil {
+			message = fmt.Sprintf("%s\nCause: %s\nHint: %s", message, cause.Cause, cause.Hint)
+		}
 	}
 
 	metadata := map[string]string{
@@ -48,31 +107,347 @@ func (a *NotifyActivity) SendDiscordNotification(ctx context.Context, req domain
 	if req.TraceID != "" {
 		metadata["Trace ID"] = req.TraceID
 	}
+	if req.RedeployOf != "" {
+		metadata["Redeploy Of"] = req.RedeployOf
+	}
+	if req.Source.Author != "" {
+		metadata["Author"] = req.Source.Author
+	}
 
-	logger.Info("Sending Discord notification",
-		zap.String("title", title),
-		zap.Bool("success", success),
+	return notification{
+		kind:     kind,
+		title:    title,
+		message:  message,
+		success:  success,
+		metadata: metadata,
+	}
+}
+
+// SendDiscordNotification sends a deployment notification to the channels the deployment is
+// routed to; the name predates channels other than Discord.
+// errMsg should be nil or empty string for success, or contain the error message for failures
+func (a *NotifyActivity) SendDiscordNotification(ctx context.Context, req domain.DeployRequest, status string, errMsg *string) error {
+	logger := applog.WithDeployment(activity.GetLogger(ctx), req)
+	n := buildNotification(req, status, errMsg)
+
+	logger.Info("Sending notification",
+		zap.String("title", n.title),
+		zap.Bool("success", n.success),
 		zap.String("project", req.Metadata.ProjectName),
-		zap.String("environment", req.Metadata.Environment),
-		zap.String("component", req.Metadata.Component),
 	)
 
-	if notifyErr := a.notifier.SendNotification(ctx, title, message, success, metadata); notifyErr != nil {
-		logger.Error("Failed to send Discord notification",
+	if _, notifyErr := a.deliver(ctx, logger, req, n, false); notifyErr != nil {
+		logger.Error("Failed to send notification",
 			zap.Error(notifyErr),
-			zap.String("title", title),
+			zap.String("title", n.title),
 			zap.String("project", req.Metadata.ProjectName),
 		)
 		// Return error so workflow knows notification failed
 		// Workflow can decide whether to fail or just log
-		return fmt.Errorf("failed to send Discord notification: %w", notifyErr)
+		return classifyError(fmt.Errorf("failed to send notification: %w", notifyErr))
+	}
+
+	logger.Info("Notification sent successfully",
+		zap.String("title", n.title),
+		zap.Bool("success", n.success),
+		zap.String("project", req.Metadata.ProjectName),
+	)
+
+	return nil
+}
+
+// SendTrackedDiscordNotification sends a notification whose acknowledgement is tracked on Discord.
+// Returns the notification ID, or an empty ID if the deployment isn't routed to Discord.
+func (a *NotifyActivity) SendTrackedDiscordNotification(ctx context.Context, req domain.DeployRequest, status string, errMsg *string) (string, error) {
+	logger := applog.WithDeployment(activity.GetLogger(ctx), req)
+	n := buildNotification(req, status, errMsg)
+
+	logger.Info("Sending tracked notification",
+		zap.String("title", n.title),
+		zap.Bool("success", n.success),
+		zap.String("project", req.Metadata.ProjectName),
+	)
+
+	notificationID, err := a.deliver(ctx, logger, req, n, true)
+	if err != nil {
+		logger.Error("Failed to send notification",
+			zap.Error(err),
+			zap.String("title", n.title),
+			zap.String("project", req.Metadata.ProjectName),
+		)
+		return "", classifyError(fmt.Errorf("failed to send notification: %w", err))
+	}
+
+	logger.Info("Tracked notification sent",
+		zap.String("title", n.title),
+		zap.String("notification_id", notificationID),
+	)
+
+	return notificationID, nil
+}
+
+// SendDiscordProgress posts a progress update of a running deployment, if the verbosity of
+// its environment includes progress updates. The update carries no metadata; the
+// deployment's thread starts with it.
+func (a *NotifyActivity) SendDiscordProgress(ctx context.Context, req domain.DeployRequest, title, message string, success bool) error {
+	logger := applog.WithDeployment(activity.GetLogger(ctx), req)
+	n := notification{
+		kind:    domain.NotificationKindProgress,
+		title:   title,
+		message: message,
+		success: success,
+	}
+
+	if _, err := a.deliver(ctx, logger, req, n, false); err != nil {
+		logger.Error("Failed to send Discord progress notification", zap.Error(err), zap.String("title", title))
+		return classifyError(fmt.Errorf("failed to send Discord progress notification: %w", err))
+	}
+	return nil
+}
+
+// SendTestNotification sends a sample notification of req through channel, or the channels
+// req is routed to if channel is empty, so operators can check a channel, the routes, and the
+// notification template without deploying. The notification goes out like a deployment's,
+// including mentions and threads, but regardless of the verbosity, and isn't counted or kept on failure.
+func (a *NotifyActivity) SendTestNotification(ctx context.Context, req domain.DeployRequest, channel string, success bool) error {
+	logger := applog.WithDeployment(activity.GetLogger(ctx), req)
+
+	channels := a.router.Channels(req)
+	if channel != "" {
+		if _, ok := a.channels[channel]; !ok {
+			return newValidationError(fmt.Sprintf("notification channel %q is not configured on this worker", channel), nil)
+		}
+		channels = []string{channel}
+	}
+
+	var errMsg *string
+	if !success {
+		sample := "This is a test of a failure notification; nothing was deployed"
+		errMsg = &sample
+	}
+	n := buildNotification(req, "Test", errMsg)
+
+	var errs []error
+	for _, channel := range channels {
+		cn := n
+		cn.mentions = a.mentionsFor(ctx, req, channel, n)
+
+		logger.Info("Sending test notification", zap.String("channel", channel), zap.Bool("success", success))
+		if _, err := a.send(ctx, logger, req, channel, cn, false); err != nil {
+			logger.Error("Failed to send test notification", zap.Error(err), zap.String("channel", channel))
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+		}
+	}
+	if len(errs) > 0 {
+		return classifyError(fmt.Errorf("failed to send test notification: %w", errors.Join(errs...)))
+	}
+	return nil
+}
+
+// mentionsFor returns the users of channel to mention with a notification: the commit author on failure
+func (a *NotifyActivity) mentionsFor(ctx context.Context, req domain.DeployRequest, channel string, n notification) []string {
+	mentions := a.channels[channel].Mentions
+	if n.kind != domain.NotificationKindFailure || mentions == nil {
+		return nil
+	}
+	return mentions.Resolve(ctx, req)
+}
+
+// deliver sends a notification to every channel the deployment is routed to, which is none
+// if the verbosity of its environment leaves out the notification. It fails only if no
+// channel got the notification; failed channels are kept for a retry either way.
+// Returns the Discord notification ID of tracked and threaded notifications.
+func (a *NotifyActivity) deliver(ctx context.Context, logger log.Logger, req domain.DeployRequest, n notification, tracked bool) (string, error) {
+	var notificationID string
+	var errs []error
+	delivered := 0
+	for _, channel := range a.router.Route(req, n.kind) {
+		cn := n
+		cn.mentions = a.mentionsFor(ctx, req, channel, n)
+
+		id, err := a.deliverTo(ctx, logger, req, channel, cn, tracked)
+		if err != nil {
+			logger.Warn("Failed to deliver notification", zap.Error(err), zap.String("channel", channel))
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+			continue
+		}
+		delivered++
+		if channel == domain.NotificationChannelDiscord {
+			notificationID = id
+		}
+	}
+	if delivered == 0 && len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	return notificationID, nil
+}
+
+// deliverTo sends a notification to a channel, counting the delivery and keeping failed
+// notifications so they can be resent. A delivered notification clears its earlier failures.
+func (a *NotifyActivity) deliverTo(ctx context.Context, logger log.Logger, req domain.DeployRequest, channel string, n notification, tracked bool) (string, error) {
+	id := domain.NotificationFailureID(channel, req.TraceID, n.title)
+
+	notificationID, err := a.send(ctx, logger, req, channel, n, tracked)
+	if err != nil {
+		a.failed.Inc(channel)
+		if a.failures != nil {
+			now := time.Now()
+			failure := domain.NotificationFailure{
+				ID:            id,
+				Channel:       channel,
+				TraceID:       req.TraceID,
+				Title:         n.title,
+				Message:       n.message,
+				Success:       n.success,
+				Metadata:      n.metadata,
+				Mentions:      n.mentions,
+				Request:       req,
+				Error:         err.Error(),
+				Attempts:      1,
+				FirstFailedAt: now,
+				LastFailedAt:  now,
+			}
+			if storeErr := a.failures.RecordFailure(ctx, failure); storeErr != nil {
+				logger.Warn("Failed to store failed notification", zap.Error(storeErr))
+			}
+		}
+		return "", err
+	}
+
+	a.sent.Inc(channel)
+	if a.failures != nil {
+		if storeErr := a.failures.DeleteFailure(ctx, id); storeErr != nil {
+			logger.Warn("Failed to clear failed notification", zap.Error(storeErr))
+		}
+	}
+	return notificationID, nil
+}
+
+// send posts a notification to a channel. On Discord, it goes into the deployment's thread
+// if threads are enabled. Returns the notification ID of tracked and threaded notifications.
+func (a *NotifyActivity) send(ctx context.Context, logger log.Logger, req domain.DeployRequest, channel string, n notification, tracked bool) (string, error) {
+	ch, ok := a.channels[channel]
+	if !ok {
+		return "", fmt.Errorf("notification channel %q is not configured: %w", channel, domain.ErrInvalidRequest)
+	}
+	if channel == domain.NotificationChannelDiscord {
+		if a.threads != nil {
+			return a.sendToThread(ctx, logger, req, n)
+		}
+		if tracked {
+			return a.tracker.SendTrackedNotification(ctx, n.title, n.message, n.success, n.metadata, n.mentions)
+		}
+	}
+	return "", ch.Notifier.SendNotification(ctx, n.title, n.message, n.success, n.metadata, n.mentions)
+}
+
+// sendToThread posts a notification into the deployment's thread, starting the thread with
+// the first notification of a deployment or pull request, or when it was deleted
+func (a *NotifyActivity) sendToThread(ctx context.Context, logger log.Logger, req domain.DeployRequest, n notification) (string, error) {
+	a.threadMu.Lock()
+	defer a.threadMu.Unlock()
+
+	key := domain.NotificationThreadKey(req)
+	stored, ok, err := a.threadStore.GetThread(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	threadID := stored.ThreadID
+	var notificationID string
+	if ok {
+		notificationID, err = a.threads.SendThreadNotification(ctx, threadID, n.title, n.message, n.success, n.metadata, n.mentions)
+		if errors.Is(err, domain.ErrThreadNotFound) {
+			logger.Warn("Notification thread was deleted, starting a new one", zap.String("thread_id", threadID))
+			ok = false
+		} else if err != nil {
+			return "", err
+		}
+	}
+	if !ok {
+		threadID, notificationID, err = a.threads.StartThread(ctx, domain.NotificationThreadName(req), n.title, n.message, n.success, n.metadata, n.mentions)
+		if err != nil {
+			return "", err
+		}
+		logger.Info("Started notification thread", zap.String("thread_id", threadID))
 	}
 
-	logger.Info("Discord notification sent successfully",
-		zap.String("title", title),
-		zap.Bool("success", success),
+	// Refreshed on every notification so threads of active pull requests are kept
+	thread := domain.NotificationThread{Key: key, ThreadID: threadID, UpdatedAt: time.Now()}
+	if err := a.threadStore.PutThread(ctx, thread); err != nil {
+		// The notification is sent; the next one starts another thread
+		logger.Warn("Failed to store notification thread", zap.Error(err), zap.String("thread_id", threadID))
+	}
+	return notificationID, nil
+}
+
+// CheckNotificationAck reports whether a tracked notification has been acknowledged
+func (a *NotifyActivity) CheckNotificationAck(ctx context.Context, notificationID string) (bool, error) {
+	acknowledged, err := a.tracker.IsAcknowledged(ctx, notificationID)
+	if err != nil {
+		activity.GetLogger(ctx).Warn("Failed to check notification acknowledgement",
+			zap.Error(err),
+			zap.String("notification_id", notificationID),
+		)
+		return false, classifyError(err)
+	}
+	return acknowledged, nil
+}
+
+// SendNotificationReminder re-pings about an unacknowledged failure notification
+func (a *NotifyActivity) SendNotificationReminder(ctx context.Context, req domain.DeployRequest, notificationID string, reminder int) error {
+	logger := applog.WithDeployment(activity.GetLogger(ctx), req)
+
+	message := fmt.Sprintf("Reminder %d: the failed deployment of %s (%s) has not been acknowledged. React to the notification or acknowledge trace %s through the API.",
+		reminder, req.Metadata.ProjectName, req.Metadata.Environment, req.TraceID)
+
+	logger.Info("Sending notification reminder",
+		zap.String("notification_id", notificationID),
+		zap.Int("reminder", reminder),
 		zap.String("project", req.Metadata.ProjectName),
 	)
 
+	if err := a.tracker.SendReminder(ctx, notificationID, message); err != nil {
+		logger.Error("Failed to send notification reminder", zap.Error(err), zap.String("notification_id", notificationID))
+		return classifyError(fmt.Errorf("failed to send notification reminder: %w", err))
+	}
 	return nil
 }
+
+// ListNotificationFailures returns the notifications this worker couldn't deliver
+func (a *NotifyActivity) ListNotificationFailures(ctx context.Context) ([]domain.NotificationFailure, error) {
+	if a.failures == nil {
+		return []domain.NotificationFailure{}, nil
+	}
+	return a.failures.ListFailures(ctx)
+}
+
+// RetryNotificationFailure resends a notification this worker couldn't deliver,
+// outside of any workflow. The failure is cleared once the notification is delivered.
+func (a *NotifyActivity) RetryNotificationFailure(ctx context.Context, id string) error {
+	if a.failures == nil {
+		return domain.ErrNotificationFailureNotFound
+	}
+	failure, err := a.failures.GetFailure(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	logger := applog.WithDeployment(applog.NewZapLoggerAdapter(a.logger), failure.Request)
+	logger.Info("Retrying failed notification", zap.String("notification_failure_id", id), zap.String("title", failure.Title))
+
+	n := notification{
+		title:    failure.Title,
+		message:  failure.Message,
+		success:  failure.Success,
+		metadata: failure.Metadata,
+		mentions: failure.Mentions,
+	}
+	if _, err := a.deliverTo(ctx, logger, failure.Request, failure.Channel, n, false); err != nil {
+		return fmt.Errorf("failed to resend notification: %w", err)
+	}
+	return nil
+}
+
+// Ensure NotifyActivity implements domain.NotificationFailureSource
+var _ domain.NotificationFailureSource = (*NotifyActivity)(nil)
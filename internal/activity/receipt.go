@@ -0,0 +1,57 @@
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+	"go.uber.org/zap"
+)
+
+// ReceiptActivity handles the signed receipts of completed deployments
+type ReceiptActivity struct {
+	signer domain.ReceiptSigner
+	logger *zap.Logger
+}
+
+// NewReceiptActivity creates a new receipt activity; signer is nil when receipts.signing_key_file is not set
+func NewReceiptActivity(signer domain.ReceiptSigner, logger *zap.Logger) *ReceiptActivity {
+	return &ReceiptActivity{
+		signer: signer,
+		logger: logger,
+	}
+}
+
+// SignDeploymentReceipt signs the JSON encoding of receipt
+func (a *ReceiptActivity) SignDeploymentReceipt(ctx context.Context, receipt domain.DeploymentReceipt) (domain.SignedReceipt, error) {
+	logger := activity.GetLogger(ctx)
+
+	if a.signer == nil {
+		err := fmt.Errorf("receipts.signing_key_file is not configured on this worker")
+		return domain.SignedReceipt{}, newValidationError(err.Error(), err)
+	}
+
+	payload, err := json.Marshal(receipt)
+	if err != nil {
+		return domain.SignedReceipt{}, newValidationError(fmt.Sprintf("failed to encode receipt: %v", err), err)
+	}
+	signature, err := a.signer.Sign(payload)
+	if err != nil {
+		return domain.SignedReceipt{}, classifyError(err)
+	}
+
+	logger.Info("Signed deployment receipt",
+		zap.String("trace_id", receipt.TraceID),
+		zap.String("commit", receipt.Commit),
+		zap.String("environment", receipt.Environment),
+	)
+	return domain.SignedReceipt{
+		Receipt:   receipt,
+		Payload:   payload,
+		Algorithm: domain.ReceiptAlgorithm,
+		PublicKey: a.signer.PublicKey(),
+		Signature: signature,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.uber.org/zap"
+)
+
+// recoverInterceptor converts panics of activities into ErrorTypePanic errors
+type recoverInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	reporter domain.CrashReporter
+	logger   *zap.Logger
+}
+
+// NewRecoverInterceptor returns a worker interceptor that recovers panics of activities.
+// The panic is logged with its stack trace, reported to reporter unless it is nil, and
+// returned as a non-retryable error carrying the stack trace in its details.
+func NewRecoverInterceptor(reporter domain.CrashReporter, logger *zap.Logger) interceptor.WorkerInterceptor {
+	return &recoverInterceptor{
+		reporter: reporter,
+		logger:   logger,
+	}
+}
+
+func (i *recoverInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	inbound := &recoverActivityInbound{root: i}
+	inbound.Next = next
+	return inbound
+}
+
+type recoverActivityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+	root *recoverInterceptor
+}
+
+func (a *recoverActivityInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (result interface{}, err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+
+		info := activity.GetInfo(ctx)
+		crash := domain.Crash{
+			Message: fmt.Sprint(recovered),
+			Stack:   string(debug.Stack()),
+			Tags: map[string]string{
+				"temporal.activity.type": info.ActivityType.Name,
+				"temporal.workflow.type": info.WorkflowType.Name,
+				"temporal.workflow.id":   info.WorkflowExecution.ID,
+				"temporal.attempt":       strconv.Itoa(int(info.Attempt)),
+			},
+			Time: time.Now(),
+		}
+		a.root.logger.Error("Recovered from panic in activity",
+			zap.String("panic", crash.Message),
+			zap.String("activity_type", info.ActivityType.Name),
+			zap.String("workflow_id", info.WorkflowExecution.ID),
+			zap.String("run_id", info.WorkflowExecution.RunID),
+		)
+		a.root.report(crash)
+
+		result = nil
+		err = temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("activity %s panicked: %s", info.ActivityType.Name, crash.Message),
+			ErrorTypePanic, nil, crash.Stack,
+		)
+	}()
+
+	return a.Next.ExecuteActivity(ctx, in)
+}
+
+// report sends crash to the reporter without the activity context, which may be cancelled
+func (i *recoverInterceptor) report(crash domain.Crash) {
+	if i.reporter == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := i.reporter.ReportCrash(ctx, crash); err != nil {
+		i.logger.Warn("Failed to report panic", zap.Error(err))
+	}
+}
@@ -3,6 +3,9 @@ package activity
 import (
 	"NYCU-SDC/deployment-service/internal/domain"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 
 	"go.temporal.io/sdk/activity"
 	"go.uber.org/zap"
@@ -11,19 +14,22 @@ import (
 // SecretActivity handles secret-related activities
 type SecretActivity struct {
 	secretManager domain.SecretManager
+	checksumSalt  string
 	logger        *zap.Logger
 }
 
 // NewSecretActivity creates a new secret activity
-func NewSecretActivity(secretManager domain.SecretManager, logger *zap.Logger) *SecretActivity {
+// checksumSalt keys the checksums recorded for each secret; checksums are not recorded if it is empty
+func NewSecretActivity(secretManager domain.SecretManager, checksumSalt string, logger *zap.Logger) *SecretActivity {
 	return &SecretActivity{
 		secretManager: secretManager,
+		checksumSalt:  checksumSalt,
 		logger:        logger,
 	}
 }
 
 // FetchInfisicalSecrets fetches secrets from Infisical using secret mappings
-func (a *SecretActivity) FetchInfisicalSecrets(ctx context.Context, project, environment string, mappings []domain.SecretMapping) (map[string]string, error) {
+func (a *SecretActivity) FetchInfisicalSecrets(ctx context.Context, project, environment string, mappings []domain.SecretMapping) (domain.FetchedSecrets, error) {
 	logger := activity.GetLogger(ctx)
 	logger.Info("Fetching secrets from Infisical",
 		zap.String("project", project),
@@ -38,7 +44,7 @@ func (a *SecretActivity) FetchInfisicalSecrets(ctx context.Context, project, env
 			zap.String("project", project),
 			zap.String("environment", environment),
 		)
-		return nil, err
+		return domain.FetchedSecrets{}, classifyError(err)
 	}
 
 	logger.Info("Successfully fetched secrets",
@@ -47,5 +53,26 @@ func (a *SecretActivity) FetchInfisicalSecrets(ctx context.Context, project, env
 		zap.String("environment", environment),
 	)
 
-	return secrets, nil
+	return domain.FetchedSecrets{
+		Values:    secrets,
+		Checksums: a.checksumSecrets(secrets),
+	}, nil
+}
+
+// checksumSecrets computes an HMAC-SHA256 of each secret value keyed with the checksum salt,
+// so deployments can be compared for stale or rotated secrets without storing the values
+func (a *SecretActivity) checksumSecrets(secrets map[string]string) map[string]string {
+	if a.checksumSalt == "" {
+		return nil
+	}
+
+	checksums := make(map[string]string, len(secrets))
+	for key, value := range secrets {
+		mac := hmac.New(sha256.New, []byte(a.checksumSalt))
+		mac.Write([]byte(key))
+		mac.Write([]byte{0})
+		mac.Write([]byte(value))
+		checksums[key] = hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+	return checksums
 }
@@ -2,10 +2,16 @@ package activity
 
 import (
 	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/diagnosis"
 	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"NYCU-SDC/deployment-service/internal/safety"
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
 
 	"go.temporal.io/sdk/activity"
 	"go.uber.org/zap"
@@ -15,49 +21,91 @@ import (
 type SSHActivity struct {
 	sshExecutor domain.SSHExecutor
 	sshConfig   config.SSHConfig
+	guard       *safety.CommandGuard
 	logger      *zap.Logger
+
+	driversMu sync.RWMutex
+	drivers   []string
 }
 
-// NewSSHActivity creates a new SSH activity
-func NewSSHActivity(sshExecutor domain.SSHExecutor, sshConfig config.SSHConfig, logger *zap.Logger) *SSHActivity {
+// NewSSHActivity creates a new SSH activity that accepts the given deployment drivers
+func NewSSHActivity(sshExecutor domain.SSHExecutor, sshConfig config.SSHConfig, drivers []string, logger *zap.Logger) *SSHActivity {
 	return &SSHActivity{
 		sshExecutor: sshExecutor,
 		sshConfig:   sshConfig,
+		guard:       safety.NewCommandGuard(sshConfig.BasePath),
+		drivers:     drivers,
 		logger:      logger,
 	}
 }
 
+// SetDrivers replaces the deployment drivers the activity accepts, e.g. after a config reload
+func (a *SSHActivity) SetDrivers(drivers []string) {
+	a.driversMu.Lock()
+	defer a.driversMu.Unlock()
+	a.drivers = slices.Clone(drivers)
+}
+
+// acceptsDriver reports whether the activity accepts driver
+func (a *SSHActivity) acceptsDriver(driver string) bool {
+	a.driversMu.RLock()
+	defer a.driversMu.RUnlock()
+	return slices.Contains(a.drivers, driver)
+}
+
 // RunSSHDeploy executes deployment via SSH
 func (a *SSHActivity) RunSSHDeploy(ctx context.Context, req domain.DeployRequest, secrets map[string]string) (string, error) {
-	logger := activity.GetLogger(ctx)
+	logger := applog.WithDeployment(activity.GetLogger(ctx), req)
 
 	// Validate request early to provide better error messages
 	if req.Source.Repo == "" {
-		return "", fmt.Errorf("Source.Repo is required but was empty")
+		return "", newValidationError("Source.Repo is required but was empty", nil)
 	}
 	if req.Metadata.Environment == "" {
-		return "", fmt.Errorf("Metadata.Environment is required but was empty")
+		return "", newValidationError("Metadata.Environment is required but was empty", nil)
 	}
 	if req.Source.Branch == "" {
-		return "", fmt.Errorf("Source.Branch is required but was empty")
+		return "", newValidationError("Source.Branch is required but was empty", nil)
 	}
 	if req.Source.Commit == "" {
-		return "", fmt.Errorf("Source.Commit is required but was empty")
+		return "", newValidationError("Source.Commit is required but was empty", nil)
 	}
 	if a.sshConfig.BasePath == "" {
-		return "", fmt.Errorf("SSH BasePath is required but was empty")
+		return "", newValidationError("SSH BasePath is required but was empty", nil)
+	}
+	// The repository and the secret names are used unquoted in the remote command
+	if !domain.ValidRepoName(req.Source.Repo) {
+		return "", newValidationError(fmt.Sprintf("Source.Repo %q must be an owner/name repository", req.Source.Repo), nil)
+	}
+	for key := range secrets {
+		if !domain.ValidEnvName(key) {
+			return "", newValidationError(fmt.Sprintf("secret %q is not a valid environment variable name", key), nil)
+		}
+	}
+	driver := req.Setup.Driver
+	if driver == "" {
+		driver = domain.DriverScript
+	}
+	if !a.acceptsDriver(driver) {
+		return "", newValidationError(fmt.Sprintf("driver %q is not enabled on this worker", driver), nil)
 	}
 
 	logger.Info("Starting SSH deployment",
-		zap.String("repo", req.Source.Repo),
 		zap.String("commit", req.Source.Commit),
-		zap.String("method", string(req.Method)),
-		zap.String("environment", req.Metadata.Environment),
+		zap.String("deploy_method", string(req.Method)),
 		zap.String("branch", req.Source.Branch),
 	)
 
 	// Build host address with port
-	host := fmt.Sprintf("%s:%d", a.sshConfig.Host, a.sshConfig.Port)
+	hostName := ""
+	if req.Host != nil {
+		hostName = req.Host.Name
+	}
+	deployHost, ok := a.sshConfig.LookupHost(hostName)
+	if !ok {
+		return "", newValidationError(fmt.Sprintf("deploy host %q is not configured on this worker", hostName), nil)
+	}
+	host := fmt.Sprintf("%s:%d", deployHost.Host, deployHost.Port)
 	user := a.sshConfig.User
 
 	logger.Info("Using SSH configuration",
@@ -74,18 +122,25 @@ func (a *SSHActivity) RunSSHDeploy(ctx context.Context, req domain.DeployRequest
 		command = a.buildCleanupCommand(req, secrets)
 	}
 
+	// The command holds the secrets and the repository key, so only its length is logged
 	logger.Info("Built deployment command",
-		zap.String("method", string(req.Method)),
-		zap.String("command_preview", a.sanitizeCommand(command)),
+		zap.String("deploy_method", string(req.Method)),
+		zap.Int("command_length", len(command)),
 	)
 
+	// Refuse commands removing anything outside the deployment's directory under the base path
+	if err := a.guard.Check(command, req.Metadata.Environment); err != nil {
+		logger.Error("Deployment command rejected by the command guard", zap.Error(err))
+		return "", newValidationError(fmt.Sprintf("deployment command rejected: %v", err), err)
+	}
+
 	// Get SSH private key from config
 	privateKey, err := a.getSSHPrivateKey()
 	if err != nil {
 		logger.Error("Failed to get SSH private key",
 			zap.Error(err),
 		)
-		return "", fmt.Errorf("failed to get SSH private key: %w", err)
+		return "", newValidationError(fmt.Sprintf("failed to get SSH private key: %v", err), err)
 	}
 
 	// Execute command via SSH
@@ -94,42 +149,81 @@ func (a *SSHActivity) RunSSHDeploy(ctx context.Context, req domain.DeployRequest
 		// Enhanced error logging with command output
 		logger.Error("SSH deployment failed",
 			zap.Error(err),
-			zap.String("repo", req.Source.Repo),
 			zap.String("commit", req.Source.Commit),
-			zap.String("method", string(req.Method)),
+			zap.String("deploy_method", string(req.Method)),
 			zap.String("host", host),
 			zap.String("user", user),
-			zap.String("command_output", output),
-			zap.String("command_preview", a.sanitizeCommand(command)),
+			zap.String("command_output", sanitizeOutput(output, secrets)),
 		)
 
-		// Provide more specific error message based on common Git errors
-		if strings.Contains(output, "fatal:") {
-			// Extract Git fatal error message
-			lines := strings.Split(output, "\n")
-			for _, line := range lines {
-				if strings.Contains(line, "fatal:") {
-					return output, fmt.Errorf("Git operation failed: %s. Full error: %w", strings.TrimSpace(line), err)
-				}
-			}
-		} else if strings.Contains(output, "Permission denied") {
-			return output, fmt.Errorf("SSH authentication failed (Permission denied). Check SSH key permissions and repository access. Error: %w", err)
-		} else if strings.Contains(output, "Host key verification failed") {
-			return output, fmt.Errorf("SSH host key verification failed. Add host to known_hosts or disable strict checking. Error: %w", err)
+		// The command never ran: the executor has already classified the connection failure
+		if errors.Is(err, domain.ErrUnauthorized) || errors.Is(err, domain.ErrUnavailable) || errors.Is(err, domain.ErrInvalidRequest) {
+			return output, classifyError(fmt.Errorf("SSH connection to %s failed: %w", host, err))
 		}
 
-		return output, fmt.Errorf("SSH deployment failed: %w", err)
+		return output, classifyCommandFailure(output, err)
 	}
 
 	logger.Info("SSH deployment completed successfully",
-		zap.String("repo", req.Source.Repo),
-		zap.String("method", string(req.Method)),
+		zap.String("deploy_method", string(req.Method)),
 		zap.String("output_length", fmt.Sprintf("%d", len(output))),
+		zap.String("command_output", sanitizeOutput(output, secrets)),
 	)
 
 	return output, nil
 }
 
+// Output fragments that identify why a remote Git operation failed
+var (
+	gitAuthFailures    = []string{"Permission denied", "Authentication failed", "Repository not found", "could not read Username", "Host key verification failed"}
+	gitNetworkFailures = []string{"Could not resolve host", "Connection timed out", "Connection refused", "Connection reset", "early EOF", "The remote end hung up unexpectedly", "Failed to connect"}
+)
+
+// classifyCommandFailure turns a failed remote command into an activity error.
+// Git failures caused by the network are retried; everything else is reported as is.
+func classifyCommandFailure(output string, err error) error {
+	if strings.Contains(output, "Host key verification failed") {
+		return newAuthError(fmt.Sprintf("SSH host key verification failed. Add host to known_hosts, pin the new key via the admin API, or disable strict checking. Error: %v", err), err)
+	}
+
+	// Provide more specific error message based on common Git errors
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "fatal:") {
+			continue
+		}
+		message := fmt.Sprintf("Git operation failed: %s. Full error: %v", strings.TrimSpace(line), err)
+		switch {
+		case containsAny(output, gitAuthFailures):
+			return newAuthError(message, err)
+		case containsAny(output, gitNetworkFailures):
+			return newNetworkError(message, err)
+		default:
+			return newScriptError(message, err)
+		}
+	}
+
+	if strings.Contains(output, "Permission denied") {
+		return newAuthError(fmt.Sprintf("SSH authentication failed (Permission denied). Check SSH key permissions and repository access. Error: %v", err), err)
+	}
+
+	// Keep the line explaining a common failure, the output itself isn't part of the error
+	if line := diagnosis.Line(output); line != "" {
+		return newScriptError(fmt.Sprintf("SSH deployment failed: %v: %s", err, line), err)
+	}
+	return newScriptError(fmt.Sprintf("SSH deployment failed: %v", err), err)
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildDeployCommand builds the script deploying the request, one step per line
 func (a *SSHActivity) buildDeployCommand(req domain.DeployRequest, secrets map[string]string) string {
 	// Validate required fields to prevent slice bounds errors
 	if req.Source.Repo == "" {
@@ -153,30 +247,8 @@ func (a *SSHActivity) buildDeployCommand(req domain.DeployRequest, secrets map[s
 	repoDir := fmt.Sprintf("%s/repo", tmpDir)
 	deployDir := fmt.Sprintf("%s/.deploy/%s", repoDir, req.Metadata.Environment)
 
-	// Determine if this is a private repo
-	hasPrivateKey := secrets["REPO_PRIVATE_KEY"] != ""
-
-	// Build repo URL
-	repoURL := a.buildRepoURL(req.Source.Repo, hasPrivateKey)
-
 	// Build commands
-	var commands []string
-
-	// Clean up existing directory
-	commands = append(commands, fmt.Sprintf("rm -rf %s", tmpDir))
-	commands = append(commands, fmt.Sprintf("mkdir -p %s", tmpDir))
-	commands = append(commands, fmt.Sprintf("cd %s", tmpDir))
-
-	// Setup SSH config for private repo if needed
-	if hasPrivateKey {
-		sshDir := fmt.Sprintf("%s/.ssh", tmpDir)
-		sshConfig := a.buildPrivateRepoSSHConfig(sshDir, secrets["REPO_PRIVATE_KEY"])
-		commands = append(commands, sshConfig...)
-	}
-
-	// Build clone commands with fallback
-	cloneCommands := a.buildCloneCommands(repoURL, repoDir, req.Source.Branch, req.Source.Commit, hasPrivateKey, tmpDir)
-	commands = append(commands, cloneCommands)
+	commands := a.buildCheckoutCommands(req, secrets, tmpDir)
 
 	// Build script execution command
 	scriptCmd := a.buildScriptExecutionCommand(deployDir, "deploy", req, secrets)
@@ -185,9 +257,10 @@ func (a *SSHActivity) buildDeployCommand(req domain.DeployRequest, secrets map[s
 	// Cleanup
 	commands = append(commands, fmt.Sprintf("rm -rf %s", tmpDir))
 
-	return strings.Join(commands, " && ")
+	return strings.Join(commands, "\n")
 }
 
+// buildCleanupCommand builds the script cleaning up the request, one step per line
 func (a *SSHActivity) buildCleanupCommand(req domain.DeployRequest, secrets map[string]string) string {
 	// Validate required fields to prevent slice bounds errors
 	if req.Source.Repo == "" {
@@ -207,18 +280,52 @@ func (a *SSHActivity) buildCleanupCommand(req domain.DeployRequest, secrets map[
 
 	var commands []string
 
+	// Check out the commit so the cleanup script is available
+	// (the working directory of the deployment was removed after it finished)
+	if req.Source.Branch != "" && req.Source.Commit != "" {
+		commands = append(commands, a.buildCheckoutCommands(req, secrets, tmpDir)...)
+	}
+
 	// Build script execution command
 	scriptCmd := a.buildScriptExecutionCommand(deployDir, "cleanup", req, secrets)
 
 	// Check if deploy directory exists before cleanup
-	// Use semicolons inside the if statement, then && to connect with other commands
 	ifBlock := fmt.Sprintf("if [ -d %s ]; then cd %s && %s; fi", deployDir, deployDir, scriptCmd)
 	commands = append(commands, ifBlock)
 
 	// Cleanup
 	commands = append(commands, fmt.Sprintf("rm -rf %s", tmpDir))
 
-	return strings.Join(commands, " && ")
+	return strings.Join(commands, "\n")
+}
+
+// buildCheckoutCommands builds the commands preparing tmpDir and cloning the requested commit into tmpDir/repo
+func (a *SSHActivity) buildCheckoutCommands(req domain.DeployRequest, secrets map[string]string, tmpDir string) []string {
+	// Determine if this is a private repo
+	hasPrivateKey := secrets["REPO_PRIVATE_KEY"] != ""
+
+	// Build repo URL
+	repoURL := a.buildRepoURL(req.Source.Repo, hasPrivateKey)
+
+	var commands []string
+
+	// Clean up existing directory
+	commands = append(commands, fmt.Sprintf("rm -rf %s", tmpDir))
+	commands = append(commands, fmt.Sprintf("mkdir -p %s", tmpDir))
+	commands = append(commands, fmt.Sprintf("cd %s", tmpDir))
+
+	// Setup SSH config for private repo if needed
+	if hasPrivateKey {
+		sshDir := fmt.Sprintf("%s/.ssh", tmpDir)
+		sshConfig := a.buildPrivateRepoSSHConfig(sshDir, secrets["REPO_PRIVATE_KEY"])
+		commands = append(commands, sshConfig...)
+	}
+
+	// Build clone commands with fallback
+	cloneCommands := a.buildCloneCommands(req, repoURL, hasPrivateKey, tmpDir)
+	commands = append(commands, cloneCommands)
+
+	return commands
 }
 
 // getSSHPrivateKey retrieves SSH private key from config
@@ -275,8 +382,9 @@ func (a *SSHActivity) buildPrivateRepoSSHConfig(sshDir, privateKey string) []str
 }
 
 // buildCloneCommands builds git clone commands with fallback strategy
-func (a *SSHActivity) buildCloneCommands(repoURL, repoDir, branch, commit string, hasPrivateKey bool, tmpDir string) string {
+func (a *SSHActivity) buildCloneCommands(req domain.DeployRequest, repoURL string, hasPrivateKey bool, tmpDir string) string {
 	sshDir := fmt.Sprintf("%s/.ssh", tmpDir)
+	clone := req.Setup.Clone
 
 	// Build git command prefix for private repo
 	gitPrefix := ""
@@ -284,30 +392,128 @@ func (a *SSHActivity) buildCloneCommands(repoURL, repoDir, branch, commit string
 		gitPrefix = fmt.Sprintf("GIT_SSH_COMMAND=\"ssh -F %s/config\" ", sshDir)
 	}
 
-	// Main strategy: shallow clone with branch
-	mainClone := fmt.Sprintf("%sgit clone --depth=1 --branch %s %s repo", gitPrefix, a.quoteShell(branch), repoURL)
+	depth := clone.Depth
+	if depth <= 0 {
+		depth = 1
+	}
+	tagsArg := "--no-tags"
+	if clone.Tags {
+		tagsArg = "--tags"
+	}
+	singleBranchArg := "--single-branch"
+	if clone.SingleBranch != nil && !*clone.SingleBranch {
+		singleBranchArg = "--no-single-branch"
+	}
+	commit := a.quoteShell(req.Source.Commit)
+
+	// Borrow objects from the local mirror when the repository is cached
+	mirrorCommand := ""
+	referenceArg := ""
+	alternatesCommand := ""
+	if mirrorDir := a.mirrorDir(req.Source.Repo); mirrorDir != "" {
+		mirrorCommand = fmt.Sprintf("(%s) && ", a.buildMirrorUpdateCommand(mirrorDir, repoURL, gitPrefix))
+		referenceArg = fmt.Sprintf("--reference-if-able %s ", mirrorDir)
+		alternatesCommand = fmt.Sprintf(" && ([ -d %s/objects ] && echo %s/objects > .git/objects/info/alternates || true)", mirrorDir, mirrorDir)
+	}
+
+	// SHA strategy: fetch only the requested commit
+	shaClone := fmt.Sprintf(
+		"mkdir repo && cd repo && git init -q%s && git remote add origin %s && %sgit fetch --depth=%d %s origin %s && git checkout -q FETCH_HEAD && cd ..",
+		alternatesCommand, repoURL, gitPrefix, depth, tagsArg, commit,
+	)
 
-	// Fallback strategy: full clone + checkout commit
-	fallbackClone := fmt.Sprintf(
-		"%sgit clone %s repo --no-checkout && cd repo && git fetch origin %s && git checkout %s && cd ..",
-		gitPrefix, repoURL, a.quoteShell(commit), a.quoteShell(commit),
+	// Branch strategy: clone the branch, then check out the commit (fetching it if the branch moved)
+	branchClone := fmt.Sprintf(
+		"%sgit clone %s--depth=%d %s %s --branch %s %s repo && cd repo && (git checkout -q %s 2>/dev/null || (%sgit fetch --depth=%d %s origin %s && git checkout -q %s)) && cd ..",
+		gitPrefix, referenceArg, depth, singleBranchArg, tagsArg, a.quoteShell(req.Source.Branch), repoURL,
+		commit, gitPrefix, depth, tagsArg, commit, commit,
 	)
 
-	// Try main strategy first, fallback if it fails
-	// Using shell function to implement try_chain logic
+	// Try the requested strategy first and fall back to the other one
+	mainClone, fallbackClone := shaClone, branchClone
+	if clone.Strategy == domain.CloneStrategyBranch {
+		mainClone, fallbackClone = branchClone, shaClone
+	}
+
 	return fmt.Sprintf(
-		"(%s) || (%s)",
+		"%s(%s) || (cd %s && rm -rf repo && %s)",
+		mirrorCommand,
 		mainClone,
+		tmpDir,
 		fallbackClone,
 	)
 }
 
-// buildScriptExecutionCommand builds the command to execute deploy.sh or cleanup.sh
+// mirrorDir returns the path of the bare mirror of the repository on the target host,
+// or an empty string if the repository isn't cached
+func (a *SSHActivity) mirrorDir(repo string) string {
+	cache := a.sshConfig.RepoCache
+	if !cache.Enable {
+		return ""
+	}
+	if len(cache.Repositories) > 0 && !slices.Contains(cache.Repositories, repo) {
+		return ""
+	}
+
+	cachePath := cache.Path
+	if cachePath == "" {
+		cachePath = fmt.Sprintf("%s/.mirrors", a.sshConfig.BasePath)
+	}
+	return fmt.Sprintf("%s/%s.git", cachePath, repo)
+}
+
+// buildMirrorUpdateCommand builds the command creating or refreshing the bare mirror.
+// A mkdir lock keeps concurrent deployments from updating the same mirror; a deployment
+// that can't take the lock (or fails to update the mirror) still clones normally.
+func (a *SSHActivity) buildMirrorUpdateCommand(mirrorDir, repoURL, gitPrefix string) string {
+	lockDir := mirrorDir + ".lock"
+	update := fmt.Sprintf(
+		"if [ -d %s ]; then %sgit -C %s fetch --prune %s '+refs/heads/*:refs/heads/*' '+refs/tags/*:refs/tags/*'; else %sgit clone --mirror %s %s; fi",
+		mirrorDir, gitPrefix, mirrorDir, repoURL, gitPrefix, repoURL, mirrorDir,
+	)
+	return fmt.Sprintf(
+		"(mkdir -p $(dirname %s) && find $(dirname %s) -maxdepth 1 -name $(basename %s) -mmin +30 -exec rmdir {} \\; ; if mkdir %s 2>/dev/null; then (%s); status=$?; rmdir %s; [ $status -eq 0 ]; fi) || echo 'Warning: failed to update repository mirror, cloning without it'",
+		mirrorDir, lockDir, lockDir, lockDir, update, lockDir,
+	)
+}
+
+// scriptInterpreter describes how a deploy script is invoked
+type scriptInterpreter struct {
+	command   string
+	extension string
+}
+
+// scriptInterpreters lists the supported interpreters in the order they are matched
+var scriptInterpreters = []struct {
+	name        string
+	interpreter scriptInterpreter
+}{
+	{"bash", scriptInterpreter{command: "bash", extension: "sh"}},
+	{"sh", scriptInterpreter{command: "sh", extension: "sh"}},
+	{"python", scriptInterpreter{command: "python3", extension: "py"}},
+	{"node", scriptInterpreter{command: "node", extension: "js"}},
+}
+
+// resolveInterpreter returns the interpreter with the given name, as resolved from the request
+// and the deploy manifest before the deployment, defaulting to bash
+func resolveInterpreter(name string) scriptInterpreter {
+	for _, candidate := range scriptInterpreters {
+		if candidate.name == name {
+			return candidate.interpreter
+		}
+	}
+	return scriptInterpreters[0].interpreter
+}
+
+// buildScriptExecutionCommand builds the command to execute the deploy or cleanup script,
+// or the docker compose command when the compose driver is selected
 func (a *SSHActivity) buildScriptExecutionCommand(deployDir, scriptType string, req domain.DeployRequest, secrets map[string]string) string {
-	scriptName := "deploy.sh"
+	scriptName := "deploy"
 	if scriptType == "cleanup" {
-		scriptName = "cleanup.sh"
+		scriptName = "cleanup"
 	}
+	interpreter := resolveInterpreter(req.Setup.Script.Interpreter)
+	scriptFile := fmt.Sprintf(`"%s.%s"`, scriptName, interpreter.extension)
 
 	// Build environment variables
 	envVars := []string{
@@ -316,6 +522,10 @@ func (a *SSHActivity) buildScriptExecutionCommand(deployDir, scriptType string,
 		fmt.Sprintf("TRACE_ID=%s", a.quoteShell(req.TraceID)),
 		fmt.Sprintf("ENVIRONMENT=%s", a.quoteShell(req.Metadata.Environment)),
 	}
+	if req.Setup.MinimizeDowntime {
+		// Lets deploy scripts prepare the new version before stopping the old one
+		envVars = append(envVars, "MINIMIZE_DOWNTIME=true")
+	}
 
 	// Add secrets as environment variables
 	for key, value := range secrets {
@@ -327,30 +537,78 @@ func (a *SSHActivity) buildScriptExecutionCommand(deployDir, scriptType string,
 
 	// Build command
 	envPrefix := strings.Join(envVars, " ")
+
+	if req.Setup.Driver == domain.DriverCompose {
+		compose := fmt.Sprintf("%s docker compose -p %s", envPrefix, a.quoteShell(composeProjectName(req)))
+		switch {
+		case scriptType == "cleanup":
+			return fmt.Sprintf("cd %s && %s down --remove-orphans", deployDir, compose)
+		case req.Setup.MinimizeDowntime:
+			// Pull and build while the old containers keep serving; up then only recreates them
+			return fmt.Sprintf(
+				"cd %s && %s pull --ignore-buildable && %s build && %s up -d --no-build --remove-orphans",
+				deployDir,
+				compose,
+				compose,
+				compose,
+			)
+		default:
+			return fmt.Sprintf("cd %s && %s up -d --build --remove-orphans", deployDir, compose)
+		}
+	}
+
 	return fmt.Sprintf(
-		"cd %s && chmod +x %s && %s bash ./%s",
+		`cd %s && chmod +x %s && %s %s ./%s`,
 		deployDir,
-		scriptName,
+		scriptFile,
 		envPrefix,
-		scriptName,
+		interpreter.command,
+		scriptFile,
 	)
 }
 
+// composeProjectName builds a Docker Compose project name unique per repo, environment, and PR
+func composeProjectName(req domain.DeployRequest) string {
+	name := fmt.Sprintf("%s-%s", req.Source.Repo, req.Metadata.Environment)
+	if req.Source.PRNumber != "" {
+		name = fmt.Sprintf("%s-pr-%s", name, req.Source.PRNumber)
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, name)
+}
+
 // quoteShell properly quotes a string for shell command
 func (a *SSHActivity) quoteShell(s string) string {
-	// Escape single quotes by replacing ' with '\''
+	// Escape single quotes by replacing ' with '"'"'
 	escaped := strings.ReplaceAll(s, "'", "'\"'\"'")
 	return fmt.Sprintf("'%s'", escaped)
 }
 
-// sanitizeCommand removes sensitive information from command for logging
-func (a *SSHActivity) sanitizeCommand(cmd string) string {
-	// Truncate long commands
-	sanitized := cmd
-	if len(sanitized) > 500 {
-		sanitized = sanitized[:500] + "... (truncated)"
+// maxLoggedOutput is the length of the end of the script output that is logged
+const maxLoggedOutput = 16 * 1024
+
+// sanitizeOutput returns the end of the output of a deployment, with the values of the secrets
+// it was given replaced, for logs that leave the worker, e.g. to Loki
+func sanitizeOutput(output string, secrets map[string]string) string {
+	for _, value := range secrets {
+		// A failing command may print a single line of a multi-line value, e.g. of a key
+		for _, part := range append([]string{value}, strings.Split(value, "\n")...) {
+			// Short values would redact unrelated text, and reveal little
+			if part = strings.TrimSpace(part); len(part) >= 4 {
+				output = strings.ReplaceAll(output, part, "[REDACTED]")
+			}
+		}
+	}
+	if len(output) > maxLoggedOutput {
+		output = "(truncated) ..." + output[len(output)-maxLoggedOutput:]
 	}
-	// Remove private key content from logs
-	sanitized = strings.ReplaceAll(sanitized, "REPO_PRIVATE_KEY", "REPO_PRIVATE_KEY=[REDACTED]")
-	return sanitized
+	return output
 }
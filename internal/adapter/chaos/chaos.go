@@ -0,0 +1,125 @@
+package chaos
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.uber.org/zap"
+)
+
+// inNamespaces reports whether ctx is the context of an activity of a workflow in one of namespaces.
+// Calls outside of activities, e.g. host probes, are never injected into.
+func inNamespaces(ctx context.Context, namespaces []string) bool {
+	return activity.IsActivity(ctx) && slices.Contains(namespaces, activity.GetInfo(ctx).WorkflowNamespace)
+}
+
+// SSHExecutor fails a fraction of the executions of the wrapped executor before they connect.
+// The failures are reported as an unavailable host, so the activity retries them.
+type SSHExecutor struct {
+	next domain.SSHExecutor
+	// namespaces are the namespaces whose activities fail
+	namespaces  []string
+	failureRate float64
+	logger      *zap.Logger
+}
+
+// NewSSHExecutor wraps next, failing failureRate (0 to 1) of its executions by activities of namespaces
+func NewSSHExecutor(next domain.SSHExecutor, namespaces []string, failureRate float64, logger *zap.Logger) *SSHExecutor {
+	return &SSHExecutor{
+		next:        next,
+		namespaces:  namespaces,
+		failureRate: failureRate,
+		logger:      logger,
+	}
+}
+
+// Execute runs command with next, unless the execution is picked to fail
+func (e *SSHExecutor) Execute(ctx context.Context, host string, user string, privateKey []byte, command string, envVars map[string]string) (string, error) {
+	if inNamespaces(ctx, e.namespaces) && rand.Float64() < e.failureRate {
+		e.logger.Warn("Chaos: failing SSH execution", zap.String("host", host))
+		return "", fmt.Errorf("chaos: injected SSH failure on %s: %w", host, domain.ErrUnavailable)
+	}
+	return e.next.Execute(ctx, host, user, privateKey, command, envVars)
+}
+
+// DNSProvider delays each record change of the wrapped providers
+type DNSProvider struct {
+	records  domain.DNSProvider
+	canaries domain.CanaryDNSProvider
+	// namespaces are the namespaces whose activities are delayed
+	namespaces []string
+	latency    time.Duration
+	logger     *zap.Logger
+}
+
+// NewDNSProvider wraps records and canaries, delaying each of their calls by activities of namespaces by latency
+func NewDNSProvider(records domain.DNSProvider, canaries domain.CanaryDNSProvider, namespaces []string, latency time.Duration, logger *zap.Logger) *DNSProvider {
+	return &DNSProvider{
+		records:    records,
+		canaries:   canaries,
+		namespaces: namespaces,
+		latency:    latency,
+		logger:     logger,
+	}
+}
+
+// EnsureRecord ensures the record with the wrapped provider after the latency
+func (p *DNSProvider) EnsureRecord(ctx context.Context, name, ip string) error {
+	if err := p.delay(ctx, name); err != nil {
+		return err
+	}
+	return p.records.EnsureRecord(ctx, name, ip)
+}
+
+// RemoveRecord removes the record with the wrapped provider after the latency
+func (p *DNSProvider) RemoveRecord(ctx context.Context, name string) error {
+	if err := p.delay(ctx, name); err != nil {
+		return err
+	}
+	return p.records.RemoveRecord(ctx, name)
+}
+
+// EnsureCanaryRecord ensures the canary record with the wrapped provider after the latency
+func (p *DNSProvider) EnsureCanaryRecord(ctx context.Context, name, ip, stable string) error {
+	if err := p.delay(ctx, name); err != nil {
+		return err
+	}
+	return p.canaries.EnsureCanaryRecord(ctx, name, ip, stable)
+}
+
+// RemoveCanaryRecords removes the canary records with the wrapped provider after the latency
+func (p *DNSProvider) RemoveCanaryRecords(ctx context.Context, stable string) error {
+	if err := p.delay(ctx, stable); err != nil {
+		return err
+	}
+	return p.canaries.RemoveCanaryRecords(ctx, stable)
+}
+
+// delay waits for the latency, or returns the error of ctx if it is done first
+func (p *DNSProvider) delay(ctx context.Context, name string) error {
+	if p.latency <= 0 || !inNamespaces(ctx, p.namespaces) {
+		return nil
+	}
+	p.logger.Warn("Chaos: delaying DNS call", zap.String("name", name), zap.Duration("latency", p.latency))
+
+	timer := time.NewTimer(p.latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ensure the wrappers implement the ports they wrap
+var (
+	_ domain.SSHExecutor       = (*SSHExecutor)(nil)
+	_ domain.DNSProvider       = (*DNSProvider)(nil)
+	_ domain.CanaryDNSProvider = (*DNSProvider)(nil)
+)
@@ -0,0 +1,116 @@
+package chaos
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+	"go.uber.org/zap"
+)
+
+// testNamespace is the namespace of the activities of a test activity environment
+const testNamespace = "default-test-namespace"
+
+type okExecutor struct{}
+
+func (okExecutor) Execute(ctx context.Context, host string, user string, privateKey []byte, command string, envVars map[string]string) (string, error) {
+	return "ok", nil
+}
+
+type okDNS struct{}
+
+func (okDNS) EnsureRecord(ctx context.Context, name, ip string) error { return nil }
+func (okDNS) RemoveRecord(ctx context.Context, name string) error     { return nil }
+func (okDNS) EnsureCanaryRecord(ctx context.Context, name, ip, stable string) error {
+	return nil
+}
+func (okDNS) RemoveCanaryRecords(ctx context.Context, stable string) error { return nil }
+
+// inActivity runs fn in the context of an activity of testNamespace
+func inActivity(t *testing.T, fn func(ctx context.Context)) {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context) error {
+		if namespace := activity.GetInfo(ctx).WorkflowNamespace; namespace != testNamespace {
+			t.Fatalf("activity runs in namespace %s, want %s", namespace, testNamespace)
+		}
+		fn(ctx)
+		return nil
+	}, activity.RegisterOptions{Name: "chaos"})
+	if _, err := env.ExecuteActivity("chaos"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSSHExecutorFailsTheFailureRateOfExecutions(t *testing.T) {
+	const executions = 10000
+
+	tests := []struct {
+		name        string
+		namespaces  []string
+		failureRate float64
+		want        float64
+	}{
+		{"none", []string{testNamespace}, 0, 0},
+		{"quarter", []string{testNamespace}, 0.25, 0.25},
+		{"all", []string{testNamespace}, 1, 1},
+		{"other namespace", []string{"deploy-staging"}, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewSSHExecutor(okExecutor{}, tt.namespaces, tt.failureRate, zap.NewNop())
+			failures := 0
+			inActivity(t, func(ctx context.Context) {
+				for range executions {
+					_, err := executor.Execute(ctx, "deploy-1:22", "deploy", nil, "true", nil)
+					if err != nil {
+						if !errors.Is(err, domain.ErrUnavailable) {
+							t.Fatalf("injected failure %v isn't an unavailable host", err)
+						}
+						failures++
+					}
+				}
+			})
+			// Five standard deviations of the binomial distribution at a rate of 0.25 are about 0.02
+			if rate := float64(failures) / executions; math.Abs(rate-tt.want) > 0.03 {
+				t.Errorf("failed %.3f of the executions, want %.2f", rate, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSHExecutorDoesntFailOutsideActivities(t *testing.T) {
+	executor := NewSSHExecutor(okExecutor{}, []string{testNamespace}, 1, zap.NewNop())
+	if _, err := executor.Execute(context.Background(), "deploy-1:22", "deploy", nil, "true", nil); err != nil {
+		t.Errorf("host probe failed: %v", err)
+	}
+}
+
+func TestDNSProviderDelaysActivitiesOfNamespaces(t *testing.T) {
+	const latency = 50 * time.Millisecond
+
+	for _, tt := range []struct {
+		namespaces []string
+		delayed    bool
+	}{
+		{[]string{testNamespace}, true},
+		{[]string{"deploy-staging"}, false},
+	} {
+		provider := NewDNSProvider(okDNS{}, okDNS{}, tt.namespaces, latency, zap.NewNop())
+		inActivity(t, func(ctx context.Context) {
+			start := time.Now()
+			if err := provider.EnsureRecord(ctx, "app.dev.sdc.nycu.club", "10.0.0.1"); err != nil {
+				t.Fatal(err)
+			}
+			if delayed := time.Since(start) >= latency; delayed != tt.delayed {
+				t.Errorf("namespaces %v: delayed %v, want %v", tt.namespaces, delayed, tt.delayed)
+			}
+		})
+	}
+}
@@ -3,30 +3,49 @@ package cloudflare
 import (
 	"NYCU-SDC/deployment-service/internal/domain"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+const defaultAPIURL = "https://api.cloudflare.com/client/v4"
+
+// dnsEditPermission is the zone permission needed to create, update, and delete records
+const dnsEditPermission = "#dns_records:edit"
+
+// maxCommentLength is the longest record comment every Cloudflare plan accepts
+const maxCommentLength = 100
+
 // Client implements domain.DNSProvider interface
 type Client struct {
+	apiURL     string
 	apiToken   string
 	zoneID     string
 	httpClient *http.Client
 	logger     *zap.Logger
 }
 
-// NewClient creates a new Cloudflare client
-func NewClient(apiToken, zoneID string, logger *zap.Logger) *Client {
+// NewClient creates a new Cloudflare client.
+// An empty apiURL uses the Cloudflare API; a nil httpClient uses a client with a 30 second timeout.
+func NewClient(apiURL, apiToken, zoneID string, httpClient *http.Client, logger *zap.Logger) *Client {
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
 	return &Client{
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
 		apiToken:   apiToken,
 		zoneID:     zoneID,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpClient,
 		logger:     logger,
 	}
 }
@@ -38,16 +57,16 @@ type DNSRecord struct {
 	Name    string `json:"name"`
 	Content string `json:"content"`
 	TTL     int    `json:"ttl"`
+	Comment string `json:"comment,omitempty"`
 }
 
-type listDNSRecordsResponse struct {
-	Result  []DNSRecord `json:"result"`
-	Success bool        `json:"success"`
+type tokenStatus struct {
+	Status string `json:"status"`
 }
 
-type createDNSRecordResponse struct {
-	Result  DNSRecord `json:"result"`
-	Success bool      `json:"success"`
+type zone struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
 }
 
 // EnsureRecord ensures a DNS A record exists with the given domain and IP
@@ -68,11 +87,11 @@ func (c *Client) EnsureRecord(ctx context.Context, domain, ip string) error {
 			return nil
 		}
 		// IP doesn't match, update the record
-		return c.updateRecord(ctx, existingRecord.ID, domain, ip)
+		return c.updateRecord(ctx, existingRecord.ID, domain, ip, "")
 	}
 
 	// Record doesn't exist, create it
-	return c.createRecord(ctx, domain, ip)
+	return c.createRecord(ctx, domain, ip, "")
 }
 
 // RemoveRecord removes a DNS A record for the given domain
@@ -92,68 +111,85 @@ func (c *Client) RemoveRecord(ctx context.Context, domain string) error {
 	return c.deleteRecord(ctx, record.ID)
 }
 
-func (c *Client) findRecord(ctx context.Context, domain string) (*DNSRecord, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/dns_records", c.zoneID)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// EnsureCanaryRecord ensures the canary A record name exists with the given IP. Canary records
+// are tagged with a comment naming their stable record; the earlier ones are removed.
+func (c *Client) EnsureCanaryRecord(ctx context.Context, name, ip, stable string) error {
+	comment := canaryComment(stable)
+	records, err := c.listRecords(ctx, url.Values{"comment.exact": []string{comment}})
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to list canary records: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	// Add query parameters
-	q := req.URL.Query()
-	q.Set("type", "A")
-	q.Set("name", domain)
-	req.URL.RawQuery = q.Encode()
-
-	// Log request details
-	c.logger.Debug("Sending Cloudflare API request",
-		zap.String("method", "GET"),
-		zap.String("url", req.URL.String()),
-		zap.String("zone_id", c.zoneID),
-		zap.String("domain", domain),
-		zap.String("token_prefix", maskToken(c.apiToken)),
-	)
+	var existingRecord *DNSRecord
+	for i, record := range records {
+		if strings.EqualFold(record.Name, name) {
+			existingRecord = &records[i]
+			continue
+		}
+		if err := c.deleteRecord(ctx, record.ID); err != nil {
+			return fmt.Errorf("failed to remove earlier canary record %s: %w", record.Name, err)
+		}
+	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		c.logger.Error("Failed to send Cloudflare API request",
-			zap.Error(err),
-			zap.String("url", req.URL.String()),
-		)
-		return nil, err
+	if existingRecord == nil {
+		// A record of the same name without the comment is updated and tagged
+		existingRecord, err = c.findRecord(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to find existing record: %w", err)
+		}
+	}
+	if existingRecord != nil {
+		if existingRecord.Content == ip && existingRecord.Comment == comment {
+			c.logger.Info("Canary DNS record already exists with correct IP",
+				zap.String("domain", name),
+				zap.String("stable", stable),
+				zap.String("ip", ip),
+			)
+			return nil
+		}
+		return c.updateRecord(ctx, existingRecord.ID, name, ip, comment)
 	}
-	defer resp.Body.Close()
+	return c.createRecord(ctx, name, ip, comment)
+}
 
-	// Read response body for logging
-	bodyBytes, err := io.ReadAll(resp.Body)
+// RemoveCanaryRecords removes every canary record of the stable record
+func (c *Client) RemoveCanaryRecords(ctx context.Context, stable string) error {
+	records, err := c.listRecords(ctx, url.Values{"comment.exact": []string{canaryComment(stable)}})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return fmt.Errorf("failed to list canary records: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		c.logger.Error("Cloudflare API returned error",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("url", req.URL.String()),
-			zap.String("response_body", string(bodyBytes)),
-			zap.String("token_prefix", maskToken(c.apiToken)),
-		)
-		return nil, fmt.Errorf("Cloudflare API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	for _, record := range records {
+		if err := c.deleteRecord(ctx, record.ID); err != nil {
+			return fmt.Errorf("failed to remove canary record %s: %w", record.Name, err)
+		}
 	}
 
-	var apiResponse listDNSRecordsResponse
-	if err := json.Unmarshal(bodyBytes, &apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	c.logger.Info("Canary DNS records removed",
+		zap.String("stable", stable),
+		zap.Int("count", len(records)),
+	)
+	return nil
+}
+
+// canaryComment returns the comment tagging the canary records of a stable record,
+// hashing names too long for a comment
+func canaryComment(stable string) string {
+	comment := "canary of " + strings.ToLower(stable)
+	if len(comment) <= maxCommentLength {
+		return comment
 	}
+	sum := sha256.Sum256([]byte(strings.ToLower(stable)))
+	return "canary of sha256:" + hex.EncodeToString(sum[:16])
+}
 
-	if !apiResponse.Success {
-		return nil, fmt.Errorf("Cloudflare API returned success=false")
+func (c *Client) findRecord(ctx context.Context, domain string) (*DNSRecord, error) {
+	records, err := c.listRecords(ctx, url.Values{"name": []string{domain}})
+	if err != nil {
+		return nil, err
 	}
 
-	if len(apiResponse.Result) == 0 {
+	if len(records) == 0 {
 		c.logger.Debug("No DNS record found",
 			zap.String("domain", domain),
 		)
@@ -162,77 +198,28 @@ func (c *Client) findRecord(ctx context.Context, domain string) (*DNSRecord, err
 
 	c.logger.Debug("DNS record found",
 		zap.String("domain", domain),
-		zap.String("record_id", apiResponse.Result[0].ID),
-		zap.String("content", apiResponse.Result[0].Content),
+		zap.String("record_id", records[0].ID),
+		zap.String("content", records[0].Content),
 	)
 
-	return &apiResponse.Result[0], nil
+	return &records[0], nil
 }
 
-func (c *Client) createRecord(ctx context.Context, domain, ip string) error {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/dns_records", c.zoneID)
-
-	payload := map[string]interface{}{
-		"type":    "A",
-		"name":    domain,
-		"content": ip,
-		"ttl":     1, // Auto TTL
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
+// listRecords returns the A records of the configured zone matching query
+func (c *Client) listRecords(ctx context.Context, query url.Values) ([]DNSRecord, error) {
+	query.Set("type", "A")
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
-	if err != nil {
-		return err
+	var records []DNSRecord
+	if err := c.do(ctx, http.MethodGet, c.recordsPath(), query, nil, &records); err != nil {
+		return nil, err
 	}
+	return records, nil
+}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	c.logger.Debug("Creating DNS record",
-		zap.String("url", url),
-		zap.String("domain", domain),
-		zap.String("ip", ip),
-		zap.String("token_prefix", maskToken(c.apiToken)),
-	)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		c.logger.Error("Failed to create DNS record",
-			zap.Error(err),
-			zap.String("domain", domain),
-		)
+func (c *Client) createRecord(ctx context.Context, domain, ip, comment string) error {
+	if err := c.do(ctx, http.MethodPost, c.recordsPath(), nil, recordPayload(domain, ip, comment), nil); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		c.logger.Error("Cloudflare API returned error when creating DNS record",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("domain", domain),
-			zap.String("ip", ip),
-			zap.String("response_body", string(bodyBytes)),
-			zap.String("token_prefix", maskToken(c.apiToken)),
-		)
-		return fmt.Errorf("Cloudflare API returned status %d: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var apiResponse createDNSRecordResponse
-	if err := json.Unmarshal(bodyBytes, &apiResponse); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if !apiResponse.Success {
-		return fmt.Errorf("Cloudflare API returned success=false")
-	}
 
 	c.logger.Info("DNS record created",
 		zap.String("domain", domain),
@@ -242,72 +229,11 @@ func (c *Client) createRecord(ctx context.Context, domain, ip string) error {
 	return nil
 }
 
-func (c *Client) updateRecord(ctx context.Context, recordID, domain, ip string) error {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/dns_records/%s", c.zoneID, recordID)
-
-	payload := map[string]interface{}{
-		"type":    "A",
-		"name":    domain,
-		"content": ip,
-		"ttl":     1, // Auto TTL
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
+func (c *Client) updateRecord(ctx context.Context, recordID, domain, ip, comment string) error {
+	if err := c.do(ctx, http.MethodPut, c.recordsPath()+"/"+recordID, nil, recordPayload(domain, ip, comment), nil); err != nil {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", url, strings.NewReader(string(jsonData)))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	c.logger.Debug("Updating DNS record",
-		zap.String("url", url),
-		zap.String("record_id", recordID),
-		zap.String("domain", domain),
-		zap.String("ip", ip),
-		zap.String("token_prefix", maskToken(c.apiToken)),
-	)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		c.logger.Error("Failed to update DNS record",
-			zap.Error(err),
-			zap.String("record_id", recordID),
-		)
-		return err
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		c.logger.Error("Cloudflare API returned error when updating DNS record",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("record_id", recordID),
-			zap.String("domain", domain),
-			zap.String("response_body", string(bodyBytes)),
-			zap.String("token_prefix", maskToken(c.apiToken)),
-		)
-		return fmt.Errorf("Cloudflare API returned status %d: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var apiResponse createDNSRecordResponse
-	if err := json.Unmarshal(bodyBytes, &apiResponse); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if !apiResponse.Success {
-		return fmt.Errorf("Cloudflare API returned success=false")
-	}
-
 	c.logger.Info("DNS record updated",
 		zap.String("domain", domain),
 		zap.String("ip", ip),
@@ -317,72 +243,66 @@ func (c *Client) updateRecord(ctx context.Context, recordID, domain, ip string)
 }
 
 func (c *Client) deleteRecord(ctx context.Context, recordID string) error {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/dns_records/%s", c.zoneID, recordID)
-
-	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
-	if err != nil {
+	if err := c.do(ctx, http.MethodDelete, c.recordsPath()+"/"+recordID, nil, nil, nil); err != nil {
 		return err
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	c.logger.Debug("Deleting DNS record",
-		zap.String("url", url),
+	c.logger.Info("DNS record deleted",
 		zap.String("record_id", recordID),
-		zap.String("token_prefix", maskToken(c.apiToken)),
 	)
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		c.logger.Error("Failed to delete DNS record",
-			zap.Error(err),
-			zap.String("record_id", recordID),
-		)
-		return err
-	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
+// recordsPath returns the path of the DNS records of the configured zone
+func (c *Client) recordsPath() string {
+	return fmt.Sprintf("/zones/%s/dns_records", c.zoneID)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		c.logger.Error("Cloudflare API returned error when deleting DNS record",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("record_id", recordID),
-			zap.String("response_body", string(bodyBytes)),
-			zap.String("token_prefix", maskToken(c.apiToken)),
-		)
-		return fmt.Errorf("Cloudflare API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+// recordPayload returns the body creating or replacing the A record of domain
+func recordPayload(domain, ip, comment string) map[string]interface{} {
+	payload := map[string]interface{}{
+		"type":    "A",
+		"name":    domain,
+		"content": ip,
+		"ttl":     1, // Auto TTL
+	}
+	if comment != "" {
+		payload["comment"] = comment
 	}
+	return payload
+}
 
-	var apiResponse struct {
-		Success bool `json:"success"`
+// CheckHealth verifies that the API token is active and can edit the DNS records of the configured zone
+func (c *Client) CheckHealth(ctx context.Context) error {
+	var token tokenStatus
+	if err := c.do(ctx, http.MethodGet, "/user/tokens/verify", nil, nil, &token); err != nil {
+		return fmt.Errorf("failed to verify API token: %w", err)
 	}
-	if err := json.Unmarshal(bodyBytes, &apiResponse); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
+	if token.Status != "active" {
+		return fmt.Errorf("Cloudflare API token is %s, not active: %w", token.Status, domain.ErrUnauthorized)
 	}
 
-	if !apiResponse.Success {
-		return fmt.Errorf("Cloudflare API returned success=false")
+	var z zone
+	if err := c.do(ctx, http.MethodGet, "/zones/"+c.zoneID, nil, nil, &z); err != nil {
+		return fmt.Errorf("failed to read zone %s: %w", c.zoneID, err)
+	}
+	// Cloudflare lists the permissions of the token on the zone; some tokens get none listed
+	// and are only caught by the first record change
+	if len(z.Permissions) > 0 && !slices.Contains(z.Permissions, dnsEditPermission) {
+		return fmt.Errorf("Cloudflare API token lacks the DNS edit permission on zone %s (%s): %w", z.Name, c.zoneID, domain.ErrUnauthorized)
 	}
 
-	c.logger.Info("DNS record deleted",
-		zap.String("record_id", recordID),
+	c.logger.Debug("Cloudflare API token verified",
+		zap.String("zone_id", c.zoneID),
+		zap.String("zone", z.Name),
+		zap.String("token_prefix", maskToken(c.apiToken)),
 	)
-
 	return nil
 }
 
-// maskToken masks the token for logging (shows first 8 and last 4 characters)
-func maskToken(token string) string {
-	if len(token) <= 12 {
-		return "***"
-	}
-	return token[:8] + "..." + token[len(token)-4:]
-}
-
-// Ensure Client implements domain.DNSProvider
+// Ensure Client implements domain.DNSProvider, domain.CanaryDNSProvider, domain.TrafficAnalytics, and domain.HealthChecker
 var _ domain.DNSProvider = (*Client)(nil)
+var _ domain.CanaryDNSProvider = (*Client)(nil)
+var _ domain.TrafficAnalytics = (*Client)(nil)
+var _ domain.HealthChecker = (*Client)(nil)
@@ -0,0 +1,142 @@
+package cloudflare
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// apiResponse is the envelope of every Cloudflare API v4 response
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Errors  []apiMessage    `json:"errors"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// apiMessage is an error reported in the envelope of a response
+type apiMessage struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// do sends an authenticated request to the Cloudflare API and decodes the result of the
+// response into out, unless out is nil. payload, if not nil, is sent as the JSON body.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, payload, out any) error {
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		body = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, body)
+	if err != nil {
+		return fmt.Errorf("invalid Cloudflare API request: %w: %w", domain.ErrInvalidRequest, err)
+	}
+	if len(query) > 0 {
+		req.URL.RawQuery = query.Encode()
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
+	req.Header.Set("Content-Type", "application/json")
+
+	c.logger.Debug("Sending Cloudflare API request",
+		zap.String("method", method),
+		zap.String("url", req.URL.String()),
+		zap.String("zone_id", c.zoneID),
+		zap.String("token_prefix", maskToken(c.apiToken)),
+	)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("Failed to send Cloudflare API request",
+			zap.Error(err),
+			zap.String("method", method),
+			zap.String("url", req.URL.String()),
+		)
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if err := parseResponse(resp.StatusCode, bodyBytes, out); err != nil {
+		c.logger.Error("Cloudflare API returned error",
+			zap.Error(err),
+			zap.String("method", method),
+			zap.String("url", req.URL.String()),
+			zap.Int("status_code", resp.StatusCode),
+			zap.String("response_body", string(bodyBytes)),
+			zap.String("token_prefix", maskToken(c.apiToken)),
+		)
+		return err
+	}
+	return nil
+}
+
+// parseResponse checks the status and envelope of a Cloudflare API response and decodes
+// its result into out, unless out is nil
+func parseResponse(statusCode int, body []byte, out any) error {
+	if statusCode < 200 || statusCode >= 300 {
+		return statusError(statusCode, body)
+	}
+
+	var envelope apiResponse
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !envelope.Success {
+		if len(envelope.Errors) > 0 {
+			return fmt.Errorf("Cloudflare API returned success=false: %s", formatMessages(envelope.Errors))
+		}
+		return fmt.Errorf("Cloudflare API returned success=false")
+	}
+
+	if out == nil || len(envelope.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Result, out); err != nil {
+		return fmt.Errorf("failed to decode response result: %w", err)
+	}
+	return nil
+}
+
+// formatMessages joins the errors of a response as "code: message"
+func formatMessages(messages []apiMessage) string {
+	parts := make([]string, len(messages))
+	for i, message := range messages {
+		parts[i] = fmt.Sprintf("%d: %s", message.Code, message.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// transportError marks a failed request as transient.
+// It lives outside the methods because their domain parameters shadow the domain package.
+func transportError(err error) error {
+	return fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+}
+
+// statusError classifies an unexpected Cloudflare API response status
+func statusError(statusCode int, body []byte) error {
+	return fmt.Errorf("Cloudflare API returned status %d: %s: %w", statusCode, string(body), domain.ErrorForStatus(statusCode))
+}
+
+// maskToken masks the token for logging (shows first 8 and last 4 characters)
+func maskToken(token string) string {
+	if len(token) <= 12 {
+		return "***"
+	}
+	return token[:8] + "..." + token[len(token)-4:]
+}
@@ -0,0 +1,167 @@
+package cloudflare
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// newTestClient returns a client sending its requests to handler
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, "test-token-0123456789", "zone-id", server.Client(), zap.NewNop())
+}
+
+func TestDoErrorPaths(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantErr    error
+		wantSubstr string
+	}{
+		{
+			name:       "server error is unavailable",
+			status:     http.StatusInternalServerError,
+			body:       `{"success":false}`,
+			wantErr:    domain.ErrUnavailable,
+			wantSubstr: "status 500",
+		},
+		{
+			name:       "rate limit is unavailable",
+			status:     http.StatusTooManyRequests,
+			body:       `rate limited`,
+			wantErr:    domain.ErrUnavailable,
+			wantSubstr: "status 429",
+		},
+		{
+			name:       "forbidden is unauthorized",
+			status:     http.StatusForbidden,
+			body:       `{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`,
+			wantErr:    domain.ErrUnauthorized,
+			wantSubstr: "status 403",
+		},
+		{
+			name:       "bad request is invalid",
+			status:     http.StatusBadRequest,
+			body:       `{"success":false}`,
+			wantErr:    domain.ErrInvalidRequest,
+			wantSubstr: "status 400",
+		},
+		{
+			name:       "success false with errors",
+			status:     http.StatusOK,
+			body:       `{"success":false,"errors":[{"code":1003,"message":"Invalid zone"},{"code":1004,"message":"Record invalid"}],"result":null}`,
+			wantSubstr: "success=false: 1003: Invalid zone; 1004: Record invalid",
+		},
+		{
+			name:       "success false without errors",
+			status:     http.StatusOK,
+			body:       `{"success":false,"errors":[]}`,
+			wantSubstr: "success=false",
+		},
+		{
+			name:       "malformed JSON",
+			status:     http.StatusOK,
+			body:       `{"success":true,"result":[`,
+			wantSubstr: "failed to decode response",
+		},
+		{
+			name:       "result of the wrong type",
+			status:     http.StatusOK,
+			body:       `{"success":true,"result":{"id":"record"}}`,
+			wantSubstr: "failed to decode response result",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			var records []DNSRecord
+			err := client.do(context.Background(), http.MethodGet, client.recordsPath(), nil, nil, &records)
+			if err == nil {
+				t.Fatalf("expected an error, got records %v", records)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error wrapping %v, got %v", tt.wantErr, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantSubstr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantSubstr, err)
+			}
+		})
+	}
+}
+
+func TestDoEmptyResult(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "missing result", body: `{"success":true,"errors":[]}`},
+		{name: "empty result", body: `{"success":true,"errors":[],"result":[]}`},
+		{name: "null result", body: `{"success":true,"errors":[],"result":null}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			})
+
+			record, err := client.findRecord(context.Background(), "app.example.com")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if record != nil {
+				t.Errorf("expected no record, got %+v", record)
+			}
+		})
+	}
+}
+
+func TestDoSendsRequest(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token-0123456789" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		if r.URL.Path != "/zones/zone-id/dns_records" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("name"); got != "app.example.com" {
+			t.Errorf("unexpected name query %q", got)
+		}
+		w.Write([]byte(`{"success":true,"result":[{"id":"record-1","type":"A","name":"app.example.com","content":"192.0.2.1"}]}`))
+	})
+
+	record, err := client.findRecord(context.Background(), "app.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record == nil || record.ID != "record-1" || record.Content != "192.0.2.1" {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestRemoveRecordPropagatesStatus(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"success":false,"errors":[{"code":9109,"message":"Invalid access token"}]}`))
+	})
+
+	err := client.RemoveRecord(context.Background(), "app.example.com")
+	if !errors.Is(err, domain.ErrUnauthorized) {
+		t.Fatalf("expected an unauthorized error, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package deploymentstore
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps deployment records and leases in memory; they are lost when the process
+// exits, and leases only elect among the jobs of one process
+type MemoryStore struct {
+	deployments map[string]domain.Deployment
+	leases      map[string]lease
+	mu          sync.Mutex
+}
+
+// lease is a lease held in a MemoryStore
+type lease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates a new, empty in-memory deployment store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		deployments: make(map[string]domain.Deployment),
+		leases:      make(map[string]lease),
+	}
+}
+
+// SaveDeployment creates or replaces the record of a deployment
+func (s *MemoryStore) SaveDeployment(ctx context.Context, deployment domain.Deployment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.deployments[deployment.TraceID]; ok {
+		deployment.CreatedAt = existing.CreatedAt
+	}
+	s.deployments[deployment.TraceID] = deployment
+	return nil
+}
+
+// GetDeployment returns the record of a deployment and whether it exists
+func (s *MemoryStore) GetDeployment(ctx context.Context, traceID string) (domain.Deployment, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deployment, ok := s.deployments[traceID]
+	return deployment, ok, nil
+}
+
+// ListDeployments returns the records the filter selects, newest first
+func (s *MemoryStore) ListDeployments(ctx context.Context, filter domain.DeploymentFilter) ([]domain.Deployment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]domain.Deployment, 0)
+	for _, deployment := range s.deployments {
+		if filter.Matches(deployment) {
+			list = append(list, deployment)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].TraceID < list[j].TraceID
+	})
+	if filter.Limit > 0 && len(list) > filter.Limit {
+		list = list[:filter.Limit]
+	}
+	return list, nil
+}
+
+// DeleteDeployment removes the record of a deployment
+func (s *MemoryStore) DeleteDeployment(ctx context.Context, traceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.deployments, traceID)
+	return nil
+}
+
+// AcquireLease takes or renews the lease with the given name for holder until ttl from now
+func (s *MemoryStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if current, ok := s.leases[name]; ok && current.holder != holder && now.Before(current.expiresAt) {
+		return false, nil
+	}
+	s.leases[name] = lease{holder: holder, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// ReleaseLease gives up the lease with the given name if holder holds it
+func (s *MemoryStore) ReleaseLease(ctx context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.leases[name]; ok && current.holder == holder {
+		delete(s.leases, name)
+	}
+	return nil
+}
+
+// Close does nothing; the records are released with the store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// Ensure MemoryStore implements Store
+var _ Store = (*MemoryStore)(nil)
@@ -0,0 +1,268 @@
+package deploymentstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is a schema change, applied once in order of its version
+type migration struct {
+	version int
+	name    string
+	// statements are executed in one transaction
+	statements []string
+}
+
+// sqliteMigrations is the schema of the SQLite store
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		name:    "create deployments",
+		statements: []string{
+			`CREATE TABLE deployments (
+				trace_id    TEXT PRIMARY KEY,
+				project     TEXT NOT NULL,
+				component   TEXT NOT NULL,
+				environment TEXT NOT NULL,
+				repo        TEXT NOT NULL,
+				branch      TEXT NOT NULL,
+				commit_sha  TEXT NOT NULL,
+				pr_number   TEXT NOT NULL,
+				method      TEXT NOT NULL,
+				state       TEXT NOT NULL,
+				host        TEXT NOT NULL,
+				namespace   TEXT NOT NULL,
+				redeploy_of TEXT NOT NULL,
+				request     TEXT NOT NULL,
+				created_at  TIMESTAMP NOT NULL,
+				updated_at  TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX deployments_created_at ON deployments (created_at)`,
+			`CREATE INDEX deployments_environment ON deployments (environment, created_at)`,
+			`CREATE INDEX deployments_repo ON deployments (repo, created_at)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "create scheduler_leases",
+		statements: []string{
+			// expires_at is in Unix milliseconds, compared the same way by every dialect
+			`CREATE TABLE scheduler_leases (
+				name       TEXT PRIMARY KEY,
+				holder     TEXT NOT NULL,
+				expires_at BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 3,
+		name:    "create shared state",
+		statements: []string{
+			// Records holding deploy requests are encrypted like deployments.request; the
+			// columns beside them are what the store queries by
+			`CREATE TABLE tombstones (
+				trace_id    TEXT PRIMARY KEY,
+				environment TEXT NOT NULL,
+				deleted_at  TIMESTAMP NOT NULL,
+				record      TEXT NOT NULL
+			)`,
+			`CREATE TABLE host_drains (
+				host       TEXT PRIMARY KEY,
+				drained_at TIMESTAMP NOT NULL,
+				record     TEXT NOT NULL
+			)`,
+			`CREATE TABLE host_placements (
+				placement_key TEXT PRIMARY KEY,
+				host          TEXT NOT NULL,
+				environment   TEXT NOT NULL,
+				trace_id      TEXT NOT NULL,
+				updated_at    TIMESTAMP NOT NULL,
+				record        TEXT NOT NULL
+			)`,
+			`CREATE INDEX host_placements_environment ON host_placements (environment)`,
+			`CREATE TABLE queued_deployments (
+				id        INTEGER PRIMARY KEY AUTOINCREMENT,
+				trace_id  TEXT NOT NULL,
+				queued_at TIMESTAMP NOT NULL,
+				record    TEXT NOT NULL
+			)`,
+			`CREATE TABLE environments (
+				name   TEXT PRIMARY KEY,
+				record TEXT NOT NULL
+			)`,
+			// state_imports records that the state files were imported, see SQLStore.ImportState
+			`CREATE TABLE state_imports (
+				name        TEXT PRIMARY KEY,
+				imported_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "claim queued deployments",
+		statements: []string{
+			// claimed_until is in Unix milliseconds like scheduler_leases.expires_at; a replica
+			// starting a queued deployment claims it until then
+			`ALTER TABLE queued_deployments ADD COLUMN claimed_until BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+}
+
+// postgresMigrations is the schema of the PostgreSQL store
+var postgresMigrations = []migration{
+	{
+		version: 1,
+		name:    "create deployments",
+		statements: []string{
+			`CREATE TABLE deployments (
+				trace_id    TEXT PRIMARY KEY,
+				project     TEXT NOT NULL,
+				component   TEXT NOT NULL,
+				environment TEXT NOT NULL,
+				repo        TEXT NOT NULL,
+				branch      TEXT NOT NULL,
+				commit_sha  TEXT NOT NULL,
+				pr_number   TEXT NOT NULL,
+				method      TEXT NOT NULL,
+				state       TEXT NOT NULL,
+				host        TEXT NOT NULL,
+				namespace   TEXT NOT NULL,
+				redeploy_of TEXT NOT NULL,
+				request     JSONB NOT NULL,
+				created_at  TIMESTAMPTZ NOT NULL,
+				updated_at  TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX deployments_created_at ON deployments (created_at)`,
+			`CREATE INDEX deployments_environment ON deployments (environment, created_at)`,
+			`CREATE INDEX deployments_repo ON deployments (repo, created_at)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "create scheduler_leases",
+		statements: []string{
+			// expires_at is in Unix milliseconds, compared the same way by every dialect
+			`CREATE TABLE scheduler_leases (
+				name       TEXT PRIMARY KEY,
+				holder     TEXT NOT NULL,
+				expires_at BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 3,
+		name:    "create shared state",
+		statements: []string{
+			// Records holding deploy requests are encrypted like deployments.request; the
+			// columns beside them are what the store queries by
+			`CREATE TABLE tombstones (
+				trace_id    TEXT PRIMARY KEY,
+				environment TEXT NOT NULL,
+				deleted_at  TIMESTAMPTZ NOT NULL,
+				record      JSONB NOT NULL
+			)`,
+			`CREATE TABLE host_drains (
+				host       TEXT PRIMARY KEY,
+				drained_at TIMESTAMPTZ NOT NULL,
+				record     JSONB NOT NULL
+			)`,
+			`CREATE TABLE host_placements (
+				placement_key TEXT PRIMARY KEY,
+				host          TEXT NOT NULL,
+				environment   TEXT NOT NULL,
+				trace_id      TEXT NOT NULL,
+				updated_at    TIMESTAMPTZ NOT NULL,
+				record        JSONB NOT NULL
+			)`,
+			`CREATE INDEX host_placements_environment ON host_placements (environment)`,
+			`CREATE TABLE queued_deployments (
+				id        BIGSERIAL PRIMARY KEY,
+				trace_id  TEXT NOT NULL,
+				queued_at TIMESTAMPTZ NOT NULL,
+				record    JSONB NOT NULL
+			)`,
+			`CREATE TABLE environments (
+				name   TEXT PRIMARY KEY,
+				record JSONB NOT NULL
+			)`,
+			// state_imports records that the state files were imported, see SQLStore.ImportState
+			`CREATE TABLE state_imports (
+				name        TEXT PRIMARY KEY,
+				imported_at TIMESTAMPTZ NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "claim queued deployments",
+		statements: []string{
+			// claimed_until is in Unix milliseconds like scheduler_leases.expires_at; a replica
+			// starting a queued deployment claims it until then
+			`ALTER TABLE queued_deployments ADD COLUMN claimed_until BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+}
+
+// migrate applies the migrations of the dialect the database hasn't seen yet. Each migration
+// runs in its own transaction together with its entry in schema_migrations, so a failed
+// migration is retried as a whole on the next start.
+func migrate(ctx context.Context, db *sql.DB, d dialect) (int, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at `+d.timestampType+` NOT NULL
+	)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := 0
+	for _, m := range d.migrations {
+		ok, err := applyMigration(ctx, db, d, m)
+		if err != nil {
+			return applied, fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration applies m unless it was applied before, reporting whether it applied it
+func applyMigration(ctx context.Context, db *sql.DB, d dialect, m migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// API replicas starting together would otherwise apply the same migration twice
+	if d.migrationLock != "" {
+		if _, err := tx.ExecContext(ctx, d.migrationLock); err != nil {
+			return false, fmt.Errorf("failed to lock migrations: %w", err)
+		}
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, d.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`), m.version).Scan(&count); err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return false, err
+		}
+	}
+	_, err = tx.ExecContext(ctx, d.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+		m.version, m.name, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,276 @@
+package deploymentstore
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// dialect holds what differs between the SQL databases the store supports
+type dialect struct {
+	// driverName is the database/sql driver of the database
+	driverName string
+	// numbered replaces the ? placeholders with $1, $2, ...
+	numbered      bool
+	timestampType string
+	// migrationLock is executed at the start of each migration transaction, if set
+	migrationLock string
+	// placementLock is executed with the environment at the start of a placement reservation,
+	// if set, so reservations of one environment don't interleave
+	placementLock string
+	migrations    []migration
+}
+
+var sqliteDialect = dialect{
+	driverName:    "sqlite",
+	timestampType: "TIMESTAMP",
+	migrations:    sqliteMigrations,
+}
+
+var postgresDialect = dialect{
+	driverName:    "pgx",
+	numbered:      true,
+	timestampType: "TIMESTAMPTZ",
+	// Arbitrary key of the transaction-scoped advisory lock serializing migrations
+	migrationLock: "SELECT pg_advisory_xact_lock(7426301)",
+	placementLock: "SELECT pg_advisory_xact_lock(7426302, hashtext(?))",
+	migrations:    postgresMigrations,
+}
+
+// rebind rewrites the ? placeholders of query for the dialect
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// SQLStore keeps deployment records, scheduler leases, and the shared state of the API replicas
+// in a SQLite or PostgreSQL database. The deploy request of a record, which holds its secrets
+// configuration and metadata, is encrypted with the keys of the namespace of the deployment;
+// the other columns are not.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+	cipher  *encryption.RecordCipher
+}
+
+const deploymentColumns = `trace_id, project, component, environment, repo, branch, commit_sha, pr_number,
+	method, state, host, namespace, redeploy_of, request, created_at, updated_at`
+
+// SaveDeployment creates or replaces the record of a deployment. A replaced record keeps its created_at.
+func (s *SQLStore) SaveDeployment(ctx context.Context, deployment domain.Deployment) error {
+	request, err := s.cipher.Seal(deployment.Namespace, deployment.Request.Metadata.ProjectName, deployment.Request)
+	if err != nil {
+		return fmt.Errorf("failed to encode deployment request: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO deployments (`+deploymentColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (trace_id) DO UPDATE SET
+			project = excluded.project,
+			component = excluded.component,
+			environment = excluded.environment,
+			repo = excluded.repo,
+			branch = excluded.branch,
+			commit_sha = excluded.commit_sha,
+			pr_number = excluded.pr_number,
+			method = excluded.method,
+			state = excluded.state,
+			host = excluded.host,
+			namespace = excluded.namespace,
+			redeploy_of = excluded.redeploy_of,
+			request = excluded.request,
+			updated_at = excluded.updated_at`),
+		deployment.TraceID,
+		deployment.Project,
+		deployment.Component,
+		deployment.Environment,
+		deployment.Repo,
+		deployment.Branch,
+		deployment.Commit,
+		deployment.PRNumber,
+		string(deployment.Method),
+		deployment.State,
+		deployment.Host,
+		deployment.Namespace,
+		deployment.RedeployOf,
+		string(request),
+		deployment.CreatedAt.UTC(),
+		deployment.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save deployment: %w", err)
+	}
+	return nil
+}
+
+// GetDeployment returns the record of a deployment and whether it exists
+func (s *SQLStore) GetDeployment(ctx context.Context, traceID string) (domain.Deployment, bool, error) {
+	row := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+deploymentColumns+` FROM deployments WHERE trace_id = ?`), traceID)
+	deployment, err := s.scanDeployment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Deployment{}, false, nil
+	}
+	if err != nil {
+		return domain.Deployment{}, false, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	return deployment, true, nil
+}
+
+// ListDeployments returns the records the filter selects, newest first
+func (s *SQLStore) ListDeployments(ctx context.Context, filter domain.DeploymentFilter) ([]domain.Deployment, error) {
+	var conditions []string
+	var args []any
+	for _, field := range []struct {
+		column string
+		value  string
+	}{
+		{"project", filter.Project},
+		{"component", filter.Component},
+		{"environment", filter.Environment},
+		{"repo", filter.Repo},
+	} {
+		if field.value != "" {
+			conditions = append(conditions, field.column+" = ?")
+			args = append(args, field.value)
+		}
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.CreatedBefore.UTC())
+	}
+
+	query := `SELECT ` + deploymentColumns + ` FROM deployments`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at DESC, trace_id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.Deployment, 0)
+	for rows.Next() {
+		deployment, err := s.scanDeployment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deployments: %w", err)
+		}
+		list = append(list, deployment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	return list, nil
+}
+
+// DeleteDeployment removes the record of a deployment
+func (s *SQLStore) DeleteDeployment(ctx context.Context, traceID string) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM deployments WHERE trace_id = ?`), traceID); err != nil {
+		return fmt.Errorf("failed to delete deployment: %w", err)
+	}
+	return nil
+}
+
+// AcquireLease takes or renews the lease with the given name for holder until ttl from now.
+// The expiry is taken from the clock of the replica, so the clocks of the replicas must agree
+// to well within ttl.
+func (s *SQLStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO scheduler_leases (name, holder, expires_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			holder = excluded.holder,
+			expires_at = excluded.expires_at
+		WHERE scheduler_leases.holder = excluded.holder OR scheduler_leases.expires_at < ?`),
+		name, holder, now.Add(ttl).UnixMilli(), now.UnixMilli(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", name, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", name, err)
+	}
+	return affected == 1, nil
+}
+
+// ReleaseLease gives up the lease with the given name if holder holds it
+func (s *SQLStore) ReleaseLease(ctx context.Context, name, holder string) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM scheduler_leases WHERE name = ? AND holder = ?`), name, holder)
+	if err != nil {
+		return fmt.Errorf("failed to release lease %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// scanDeployment reads a row of deploymentColumns
+func (s *SQLStore) scanDeployment(row interface{ Scan(dest ...any) error }) (domain.Deployment, error) {
+	var deployment domain.Deployment
+	var method string
+	var request []byte
+	var createdAt, updatedAt time.Time
+	err := row.Scan(
+		&deployment.TraceID,
+		&deployment.Project,
+		&deployment.Component,
+		&deployment.Environment,
+		&deployment.Repo,
+		&deployment.Branch,
+		&deployment.Commit,
+		&deployment.PRNumber,
+		&method,
+		&deployment.State,
+		&deployment.Host,
+		&deployment.Namespace,
+		&deployment.RedeployOf,
+		&request,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return deployment, err
+	}
+
+	deployment.Method = domain.DeployMethod(method)
+	deployment.CreatedAt = createdAt.UTC()
+	deployment.UpdatedAt = updatedAt.UTC()
+	if err := s.cipher.Open(request, &deployment.Request); err != nil {
+		return deployment, fmt.Errorf("failed to decode request of deployment %s: %w", deployment.TraceID, err)
+	}
+	return deployment, nil
+}
+
+// Ensure SQLStore implements Store
+var _ Store = (*SQLStore)(nil)
@@ -0,0 +1,104 @@
+package deploymentstore
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// openSQLiteStore opens a new SQLite store encrypting the records of the deploy namespace
+func openSQLiteStore(t *testing.T) *SQLStore {
+	t.Helper()
+	dir := t.TempDir()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := encryption.NewRecordCipher(
+		config.EncryptionConfig{Namespaces: map[string][]config.EncryptionKeyConfig{"deploy": {{ID: "k1", File: keyFile}}}},
+		config.TemporalConfig{Namespace: "deploy"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open(context.Background(), config.DeploymentsConfig{
+		Driver: config.DeploymentsDriverSQLite,
+		DSN:    filepath.Join(dir, "deployments.db"),
+	}, cipher, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store.(*SQLStore)
+}
+
+func TestSQLStoreEncryptsRequest(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+
+	req := domain.DeployRequest{
+		TraceID:  "trace-1",
+		Method:   domain.MethodDeploy,
+		Source:   domain.SourceInfo{Repo: "org/app", Branch: "main", Commit: "abc", PRTitle: "confidential-title"},
+		Metadata: domain.MetadataInfo{ProjectName: "app", Component: "api", Environment: "staging"},
+	}
+	deployment := domain.NewDeployment(req, "started", "deploy", time.Now())
+	if err := store.SaveDeployment(ctx, deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	if err := store.db.QueryRowContext(ctx, `SELECT request FROM deployments WHERE trace_id = ?`, "trace-1").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored, "confidential-title") {
+		t.Fatalf("request column holds the plaintext request: %s", stored)
+	}
+
+	got, ok, err := store.GetDeployment(ctx, "trace-1")
+	if err != nil || !ok {
+		t.Fatalf("GetDeployment: %v, %v", ok, err)
+	}
+	if got.Request.Source.PRTitle != "confidential-title" || got.Environment != "staging" {
+		t.Errorf("unexpected deployment %+v", got)
+	}
+}
+
+func TestSQLStoreReadsPlaintextRequest(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+
+	// Written before encryption was enabled
+	now := time.Now().UTC()
+	_, err := store.db.ExecContext(ctx, `INSERT INTO deployments (`+deploymentColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		"trace-old", "app", "api", "staging", "org/app", "main", "abc", "", "deploy", "started", "", "deploy", "",
+		`{"trace_id":"trace-old","source":{"pr_title":"old-title"}}`, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := store.GetDeployment(ctx, "trace-old")
+	if err != nil || !ok {
+		t.Fatalf("GetDeployment: %v, %v", ok, err)
+	}
+	if got.Request.Source.PRTitle != "old-title" {
+		t.Errorf("unexpected request %+v", got.Request)
+	}
+}
@@ -0,0 +1,546 @@
+package deploymentstore
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SharedState is the state the API replicas share through a SQL store besides the deployment
+// records: the tombstones of deleted deployments, the drains and placements of the deploy
+// hosts, the deployments queued for a host, and the environments catalog. Every write is a
+// single statement or transaction, so replicas never overwrite each other's changes.
+type SharedState interface {
+	domain.TombstoneStore
+	domain.HostStore
+	domain.EnvironmentStore
+
+	// ImportState copies the state of the file stores into the SQL store the first time any
+	// replica calls it, and reports whether this call imported it
+	ImportState(ctx context.Context, tombstones domain.TombstoneStore, hosts domain.HostStore, environments domain.EnvironmentStore) (bool, error)
+}
+
+// execer is a *sql.DB or *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// stateImport is the name of the import of the state files in state_imports
+const stateImport = "state_files"
+
+// GetTombstone returns the tombstone of a deployment and whether it exists
+func (s *SQLStore) GetTombstone(ctx context.Context, traceID string) (domain.Tombstone, bool, error) {
+	var record []byte
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT record FROM tombstones WHERE trace_id = ?`), traceID).Scan(&record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Tombstone{}, false, nil
+	}
+	if err != nil {
+		return domain.Tombstone{}, false, fmt.Errorf("failed to get tombstone: %w", err)
+	}
+
+	var tombstone domain.Tombstone
+	if err := s.cipher.Open(record, &tombstone); err != nil {
+		return domain.Tombstone{}, false, fmt.Errorf("failed to decode tombstone %s: %w", traceID, err)
+	}
+	return tombstone, true, nil
+}
+
+// PutTombstone creates or replaces the tombstone of a deployment
+func (s *SQLStore) PutTombstone(ctx context.Context, tombstone domain.Tombstone) error {
+	return s.putTombstone(ctx, s.db, tombstone)
+}
+
+func (s *SQLStore) putTombstone(ctx context.Context, db execer, tombstone domain.Tombstone) error {
+	record, err := s.cipher.Seal(s.cipher.Namespace(tombstone.Environment), tombstone.Project, tombstone)
+	if err != nil {
+		return fmt.Errorf("failed to encode tombstone %s: %w", tombstone.TraceID, err)
+	}
+
+	_, err = db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO tombstones (trace_id, environment, deleted_at, record)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (trace_id) DO UPDATE SET
+			environment = excluded.environment,
+			deleted_at = excluded.deleted_at,
+			record = excluded.record`),
+		tombstone.TraceID, tombstone.Environment, tombstone.DeletedAt.UTC(), string(record),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save tombstone: %w", err)
+	}
+	return nil
+}
+
+// DeleteTombstone removes the tombstone of a deployment
+func (s *SQLStore) DeleteTombstone(ctx context.Context, traceID string) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM tombstones WHERE trace_id = ?`), traceID); err != nil {
+		return fmt.Errorf("failed to delete tombstone: %w", err)
+	}
+	return nil
+}
+
+// ListTombstones returns all tombstones, oldest deletion first
+func (s *SQLStore) ListTombstones(ctx context.Context) ([]domain.Tombstone, error) {
+	list := make([]domain.Tombstone, 0)
+	err := s.queryRecords(ctx, `SELECT record FROM tombstones ORDER BY deleted_at, trace_id`, func(record []byte) error {
+		var tombstone domain.Tombstone
+		if err := s.cipher.Open(record, &tombstone); err != nil {
+			return err
+		}
+		list = append(list, tombstone)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tombstones: %w", err)
+	}
+	return list, nil
+}
+
+// GetDrain returns the drain of a host and whether the host is draining
+func (s *SQLStore) GetDrain(ctx context.Context, host string) (domain.HostDrain, bool, error) {
+	var record []byte
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT record FROM host_drains WHERE host = ?`), host).Scan(&record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.HostDrain{}, false, nil
+	}
+	if err != nil {
+		return domain.HostDrain{}, false, fmt.Errorf("failed to get host drain: %w", err)
+	}
+
+	var drain domain.HostDrain
+	if err := json.Unmarshal(record, &drain); err != nil {
+		return domain.HostDrain{}, false, fmt.Errorf("failed to decode drain of host %s: %w", host, err)
+	}
+	return drain, true, nil
+}
+
+// PutDrain marks a host as draining
+func (s *SQLStore) PutDrain(ctx context.Context, drain domain.HostDrain) error {
+	return s.putDrain(ctx, s.db, drain)
+}
+
+func (s *SQLStore) putDrain(ctx context.Context, db execer, drain domain.HostDrain) error {
+	record, err := json.Marshal(drain)
+	if err != nil {
+		return fmt.Errorf("failed to encode drain of host %s: %w", drain.Host, err)
+	}
+
+	_, err = db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO host_drains (host, drained_at, record)
+		VALUES (?, ?, ?)
+		ON CONFLICT (host) DO UPDATE SET
+			drained_at = excluded.drained_at,
+			record = excluded.record`),
+		drain.Host, drain.DrainedAt.UTC(), string(record),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save host drain: %w", err)
+	}
+	return nil
+}
+
+// DeleteDrain marks a host as available again
+func (s *SQLStore) DeleteDrain(ctx context.Context, host string) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM host_drains WHERE host = ?`), host); err != nil {
+		return fmt.Errorf("failed to delete host drain: %w", err)
+	}
+	return nil
+}
+
+// ListDrains returns the draining hosts, oldest drain first
+func (s *SQLStore) ListDrains(ctx context.Context) ([]domain.HostDrain, error) {
+	list := make([]domain.HostDrain, 0)
+	err := s.queryRecords(ctx, `SELECT record FROM host_drains ORDER BY drained_at, host`, func(record []byte) error {
+		var drain domain.HostDrain
+		if err := json.Unmarshal(record, &drain); err != nil {
+			return err
+		}
+		list = append(list, drain)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list host drains: %w", err)
+	}
+	return list, nil
+}
+
+// GetPlacement returns the placement of an environment and whether it exists
+func (s *SQLStore) GetPlacement(ctx context.Context, key string) (domain.Placement, bool, error) {
+	var record []byte
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT record FROM host_placements WHERE placement_key = ?`), key).Scan(&record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Placement{}, false, nil
+	}
+	if err != nil {
+		return domain.Placement{}, false, fmt.Errorf("failed to get placement: %w", err)
+	}
+
+	var placement domain.Placement
+	if err := s.cipher.Open(record, &placement); err != nil {
+		return domain.Placement{}, false, fmt.Errorf("failed to decode placement %s: %w", key, err)
+	}
+	return placement, true, nil
+}
+
+// PutPlacement creates or replaces the placement of an environment
+func (s *SQLStore) PutPlacement(ctx context.Context, placement domain.Placement) error {
+	return s.putPlacement(ctx, s.db, placement)
+}
+
+func (s *SQLStore) putPlacement(ctx context.Context, db execer, placement domain.Placement) error {
+	environment := placement.Request.Metadata.Environment
+	record, err := s.cipher.Seal(s.cipher.Namespace(environment), placement.Request.Metadata.ProjectName, placement)
+	if err != nil {
+		return fmt.Errorf("failed to encode placement %s: %w", placement.Key, err)
+	}
+
+	_, err = db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO host_placements (placement_key, host, environment, trace_id, updated_at, record)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (placement_key) DO UPDATE SET
+			host = excluded.host,
+			environment = excluded.environment,
+			trace_id = excluded.trace_id,
+			updated_at = excluded.updated_at,
+			record = excluded.record`),
+		placement.Key, placement.Host, environment, placement.TraceID, placement.UpdatedAt.UTC(), string(record),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save placement: %w", err)
+	}
+	return nil
+}
+
+// DeletePlacement removes the placement of an environment
+func (s *SQLStore) DeletePlacement(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM host_placements WHERE placement_key = ?`), key); err != nil {
+		return fmt.Errorf("failed to delete placement: %w", err)
+	}
+	return nil
+}
+
+// ListPlacements returns the placements of all environments, sorted by key
+func (s *SQLStore) ListPlacements(ctx context.Context) ([]domain.Placement, error) {
+	list := make([]domain.Placement, 0)
+	err := s.queryRecords(ctx, `SELECT record FROM host_placements ORDER BY placement_key`, func(record []byte) error {
+		var placement domain.Placement
+		if err := s.cipher.Open(record, &placement); err != nil {
+			return err
+		}
+		list = append(list, placement)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list placements: %w", err)
+	}
+	return list, nil
+}
+
+// ReservePlacement creates or replaces placement unless quota other environments of its
+// environment are placed already. The count and the write run in one transaction, which
+// PostgreSQL serializes per environment with an advisory lock and SQLite with its single
+// connection, so replicas placing environments concurrently can't exceed the quota.
+func (s *SQLStore) ReservePlacement(ctx context.Context, placement domain.Placement, quota int) (bool, error) {
+	environment := placement.Request.Metadata.Environment
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve placement: %w", err)
+	}
+	defer tx.Rollback()
+
+	if s.dialect.placementLock != "" {
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(s.dialect.placementLock), environment); err != nil {
+			return false, fmt.Errorf("failed to lock placements of %s: %w", environment, err)
+		}
+	}
+
+	var placed int
+	err = tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT COUNT(*) FROM host_placements WHERE environment = ? AND placement_key <> ?`),
+		environment, placement.Key,
+	).Scan(&placed)
+	if err != nil {
+		return false, fmt.Errorf("failed to count placements of %s: %w", environment, err)
+	}
+	if placed >= quota {
+		return false, nil
+	}
+
+	if err := s.putPlacement(ctx, tx, placement); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to reserve placement: %w", err)
+	}
+	return true, nil
+}
+
+// QueueDeployment stores a deployment until a host becomes available
+func (s *SQLStore) QueueDeployment(ctx context.Context, queued domain.QueuedDeployment) error {
+	return s.queueDeployment(ctx, s.db, queued)
+}
+
+func (s *SQLStore) queueDeployment(ctx context.Context, db execer, queued domain.QueuedDeployment) error {
+	record, err := s.cipher.Seal(s.cipher.Namespace(queued.Request.Metadata.Environment), queued.Request.Metadata.ProjectName, queued)
+	if err != nil {
+		return fmt.Errorf("failed to encode queued deployment %s: %w", queued.TraceID, err)
+	}
+
+	_, err = db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO queued_deployments (trace_id, queued_at, record) VALUES (?, ?, ?)`),
+		queued.TraceID, queued.QueuedAt.UTC(), string(record),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to queue deployment: %w", err)
+	}
+	return nil
+}
+
+// queueClaimTTL is how long a replica may take to start a queued deployment it claimed; the
+// deployment becomes available to other replicas again after it, e.g. if the replica exits
+const queueClaimTTL = 5 * time.Minute
+
+// StartQueuedDeployment claims the oldest queued deployment no replica has claimed, passes it to
+// start, and deletes it once start succeeds. A failed start releases the claim.
+func (s *SQLStore) StartQueuedDeployment(ctx context.Context, start func(domain.QueuedDeployment) error) (bool, error) {
+	id, queued, ok, err := s.claimQueuedDeployment(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if err := start(queued); err != nil {
+		if _, releaseErr := s.db.ExecContext(ctx, s.dialect.rebind(`UPDATE queued_deployments SET claimed_until = 0 WHERE id = ?`), id); releaseErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to release queued deployment %s: %w", queued.TraceID, releaseErr))
+		}
+		return true, err
+	}
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM queued_deployments WHERE id = ?`), id); err != nil {
+		return true, fmt.Errorf("failed to dequeue deployment %s: %w", queued.TraceID, err)
+	}
+	return true, nil
+}
+
+// claimQueuedDeployment claims the oldest unclaimed queued deployment and returns it with its id.
+// The claim is a conditional update, so when replicas race for a row only one claims it and the
+// others move on to the next.
+func (s *SQLStore) claimQueuedDeployment(ctx context.Context) (int64, domain.QueuedDeployment, bool, error) {
+	for {
+		now := time.Now()
+		var id int64
+		var record []byte
+		err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT id, record FROM queued_deployments
+			WHERE claimed_until < ? ORDER BY id LIMIT 1`), now.UnixMilli()).Scan(&id, &record)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, domain.QueuedDeployment{}, false, nil
+		}
+		if err != nil {
+			return 0, domain.QueuedDeployment{}, false, fmt.Errorf("failed to claim queued deployment: %w", err)
+		}
+
+		result, err := s.db.ExecContext(ctx, s.dialect.rebind(`UPDATE queued_deployments SET claimed_until = ?
+			WHERE id = ? AND claimed_until < ?`), now.Add(queueClaimTTL).UnixMilli(), id, now.UnixMilli())
+		if err != nil {
+			return 0, domain.QueuedDeployment{}, false, fmt.Errorf("failed to claim queued deployment: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, domain.QueuedDeployment{}, false, fmt.Errorf("failed to claim queued deployment: %w", err)
+		}
+		if affected == 0 {
+			// Another replica claimed it first
+			continue
+		}
+
+		var queued domain.QueuedDeployment
+		if err := s.cipher.Open(record, &queued); err != nil {
+			return 0, domain.QueuedDeployment{}, false, fmt.Errorf("failed to decode queued deployment: %w", err)
+		}
+		return id, queued, true, nil
+	}
+}
+
+// ListQueuedDeployments returns the queued deployments, oldest first
+func (s *SQLStore) ListQueuedDeployments(ctx context.Context) ([]domain.QueuedDeployment, error) {
+	list := make([]domain.QueuedDeployment, 0)
+	err := s.queryRecords(ctx, `SELECT record FROM queued_deployments ORDER BY id`, func(record []byte) error {
+		var queued domain.QueuedDeployment
+		if err := s.cipher.Open(record, &queued); err != nil {
+			return err
+		}
+		list = append(list, queued)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queued deployments: %w", err)
+	}
+	return list, nil
+}
+
+// GetEnvironment returns the environment with the given name and whether it exists
+func (s *SQLStore) GetEnvironment(ctx context.Context, name string) (domain.Environment, bool, error) {
+	var record []byte
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT record FROM environments WHERE name = ?`), name).Scan(&record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Environment{}, false, nil
+	}
+	if err != nil {
+		return domain.Environment{}, false, fmt.Errorf("failed to get environment: %w", err)
+	}
+
+	var environment domain.Environment
+	if err := json.Unmarshal(record, &environment); err != nil {
+		return domain.Environment{}, false, fmt.Errorf("failed to decode environment %s: %w", name, err)
+	}
+	return environment, true, nil
+}
+
+// PutEnvironment creates or replaces an environment
+func (s *SQLStore) PutEnvironment(ctx context.Context, environment domain.Environment) error {
+	return s.putEnvironment(ctx, s.db, environment)
+}
+
+func (s *SQLStore) putEnvironment(ctx context.Context, db execer, environment domain.Environment) error {
+	record, err := json.Marshal(environment)
+	if err != nil {
+		return fmt.Errorf("failed to encode environment %s: %w", environment.Name, err)
+	}
+
+	_, err = db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO environments (name, record)
+		VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET record = excluded.record`),
+		environment.Name, string(record),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save environment: %w", err)
+	}
+	return nil
+}
+
+// DeleteEnvironment removes an environment
+func (s *SQLStore) DeleteEnvironment(ctx context.Context, name string) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM environments WHERE name = ?`), name); err != nil {
+		return fmt.Errorf("failed to delete environment: %w", err)
+	}
+	return nil
+}
+
+// ListEnvironments returns the environments, ordered by name
+func (s *SQLStore) ListEnvironments(ctx context.Context) ([]domain.Environment, error) {
+	list := make([]domain.Environment, 0)
+	err := s.queryRecords(ctx, `SELECT record FROM environments ORDER BY name`, func(record []byte) error {
+		var environment domain.Environment
+		if err := json.Unmarshal(record, &environment); err != nil {
+			return err
+		}
+		list = append(list, environment)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	return list, nil
+}
+
+// ImportState copies the state of the file stores into the SQL store the first time any replica
+// calls it: the import is recorded in state_imports in the same transaction, so replicas
+// starting together import the files once, and an import that failed is retried as a whole.
+// The environment file store holds domain.DefaultEnvironments until its file is written, so a
+// new store starts with the default catalog.
+func (s *SQLStore) ImportState(ctx context.Context, tombstones domain.TombstoneStore, hosts domain.HostStore, environments domain.EnvironmentStore) (bool, error) {
+	// Read the files first to keep the transaction short
+	tombstoneList, err := tombstones.ListTombstones(ctx)
+	if err != nil {
+		return false, err
+	}
+	drains, err := hosts.ListDrains(ctx)
+	if err != nil {
+		return false, err
+	}
+	placements, err := hosts.ListPlacements(ctx)
+	if err != nil {
+		return false, err
+	}
+	queue, err := hosts.ListQueuedDeployments(ctx)
+	if err != nil {
+		return false, err
+	}
+	environmentList, err := environments.ListEnvironments(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to import state: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Blocks until a replica importing concurrently commits, then inserts nothing
+	result, err := tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO state_imports (name, imported_at) VALUES (?, ?)
+		ON CONFLICT (name) DO NOTHING`), stateImport, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to import state: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to import state: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	for _, tombstone := range tombstoneList {
+		if err := s.putTombstone(ctx, tx, tombstone); err != nil {
+			return false, err
+		}
+	}
+	for _, drain := range drains {
+		if err := s.putDrain(ctx, tx, drain); err != nil {
+			return false, err
+		}
+	}
+	for _, placement := range placements {
+		if err := s.putPlacement(ctx, tx, placement); err != nil {
+			return false, err
+		}
+	}
+	for _, queued := range queue {
+		if err := s.queueDeployment(ctx, tx, queued); err != nil {
+			return false, err
+		}
+	}
+	for _, environment := range environmentList {
+		if err := s.putEnvironment(ctx, tx, environment); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to import state: %w", err)
+	}
+	return true, nil
+}
+
+// queryRecords calls fn with the record of every row query returns
+func (s *SQLStore) queryRecords(ctx context.Context, query string, fn func(record []byte) error) error {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record []byte
+		if err := rows.Scan(&record); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Ensure SQLStore implements SharedState
+var _ SharedState = (*SQLStore)(nil)
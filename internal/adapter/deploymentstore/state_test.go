@@ -0,0 +1,224 @@
+package deploymentstore
+
+import (
+	"NYCU-SDC/deployment-service/internal/adapter/envstore"
+	"NYCU-SDC/deployment-service/internal/adapter/hoststore"
+	"NYCU-SDC/deployment-service/internal/adapter/tombstone"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testRequest(traceID, component, title string) domain.DeployRequest {
+	return domain.DeployRequest{
+		TraceID:  traceID,
+		Method:   domain.MethodDeploy,
+		Source:   domain.SourceInfo{Repo: "org/app", PRTitle: title},
+		Metadata: domain.MetadataInfo{ProjectName: "app", Component: component, Environment: "preview"},
+	}
+}
+
+func testPlacement(req domain.DeployRequest, host string) domain.Placement {
+	return domain.Placement{Key: domain.PlacementKey(req), Host: host, TraceID: req.TraceID, Request: req, UpdatedAt: time.Now()}
+}
+
+func TestSQLStoreEncryptsSharedState(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+
+	req := testRequest("trace-1", "api", "confidential-title")
+	if err := store.PutTombstone(ctx, domain.Tombstone{TraceID: "trace-0", Environment: "preview", Reason: "confidential-reason", DeletedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutPlacement(ctx, testPlacement(req, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.QueueDeployment(ctx, domain.QueuedDeployment{TraceID: req.TraceID, Request: req, QueuedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range []string{"tombstones", "host_placements", "queued_deployments"} {
+		var record string
+		if err := store.db.QueryRowContext(ctx, `SELECT record FROM `+table).Scan(&record); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(record, "confidential") {
+			t.Errorf("%s holds the plaintext record: %s", table, record)
+		}
+	}
+
+	tombstone, ok, err := store.GetTombstone(ctx, "trace-0")
+	if err != nil || !ok || tombstone.Reason != "confidential-reason" {
+		t.Errorf("GetTombstone: %+v, %v, %v", tombstone, ok, err)
+	}
+	placement, ok, err := store.GetPlacement(ctx, domain.PlacementKey(req))
+	if err != nil || !ok || placement.Request.Source.PRTitle != "confidential-title" {
+		t.Errorf("GetPlacement: %+v, %v, %v", placement, ok, err)
+	}
+}
+
+func TestSQLStoreReservePlacementEnforcesQuota(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	const quota = 3
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := testRequest(fmt.Sprintf("trace-%d", i), fmt.Sprintf("component-%d", i), "")
+			ok, err := store.ReservePlacement(ctx, testPlacement(req, "a"), quota)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if reserved != quota {
+		t.Fatalf("reserved %d placements, want %d", reserved, quota)
+	}
+	placements, err := store.ListPlacements(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(placements) != quota {
+		t.Fatalf("stored %d placements, want %d", len(placements), quota)
+	}
+
+	// An environment that is placed already doesn't count against its own quota
+	ok, err := store.ReservePlacement(ctx, testPlacement(placements[0].Request, "b"), quota)
+	if err != nil || !ok {
+		t.Errorf("re-reserving a placed environment: %v, %v", ok, err)
+	}
+}
+
+func TestSQLStoreStartQueuedDeployment(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		req := testRequest(fmt.Sprintf("trace-%d", i), "api", "")
+		if err := store.QueueDeployment(ctx, domain.QueuedDeployment{TraceID: req.TraceID, Request: req, QueuedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A deployment that fails to start stays queued
+	failed := errors.New("temporal unavailable")
+	ok, err := store.StartQueuedDeployment(ctx, func(queued domain.QueuedDeployment) error {
+		return failed
+	})
+	if !ok || !errors.Is(err, failed) {
+		t.Fatalf("failed start: %v, %v", ok, err)
+	}
+
+	// A concurrent caller gets the next deployment while the first is being started
+	var started []string
+	ok, err = store.StartQueuedDeployment(ctx, func(queued domain.QueuedDeployment) error {
+		ok, err := store.StartQueuedDeployment(ctx, func(next domain.QueuedDeployment) error {
+			started = append(started, next.TraceID)
+			return nil
+		})
+		if !ok || err != nil {
+			t.Errorf("concurrent start: %v, %v", ok, err)
+		}
+		started = append(started, queued.TraceID)
+		return nil
+	})
+	if !ok || err != nil {
+		t.Fatalf("start: %v, %v", ok, err)
+	}
+	if want := []string{"trace-1", "trace-0"}; !slices.Equal(started, want) {
+		t.Fatalf("started %v, want %v", started, want)
+	}
+
+	queue, err := store.ListQueuedDeployments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || queue[0].TraceID != "trace-2" {
+		t.Fatalf("unexpected queue %+v", queue)
+	}
+	if ok, err := store.StartQueuedDeployment(ctx, func(domain.QueuedDeployment) error { return nil }); !ok || err != nil {
+		t.Fatalf("start: %v, %v", ok, err)
+	}
+	if ok, err := store.StartQueuedDeployment(ctx, func(domain.QueuedDeployment) error {
+		t.Error("start called on an empty queue")
+		return nil
+	}); ok || err != nil {
+		t.Errorf("empty queue: %v, %v", ok, err)
+	}
+}
+
+func TestSQLStoreImportState(t *testing.T) {
+	store := openSQLiteStore(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	tombstones := tombstone.NewStore(filepath.Join(dir, "tombstones.json"), store.cipher)
+	hosts := hoststore.NewStore(filepath.Join(dir, "hosts.json"), store.cipher)
+	// Never written, so it holds the default catalog
+	environments := envstore.NewStore(filepath.Join(dir, "environments.json"))
+
+	req := testRequest("trace-1", "api", "")
+	if err := tombstones.PutTombstone(ctx, domain.Tombstone{TraceID: "trace-0", Environment: "preview", DeletedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.PutDrain(ctx, domain.HostDrain{Host: "b", DrainedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.PutPlacement(ctx, testPlacement(req, "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := store.ImportState(ctx, tombstones, hosts, environments)
+	if err != nil || !imported {
+		t.Fatalf("ImportState: %v, %v", imported, err)
+	}
+
+	if _, ok, err := store.GetTombstone(ctx, "trace-0"); err != nil || !ok {
+		t.Errorf("tombstone not imported: %v, %v", ok, err)
+	}
+	if _, ok, err := store.GetDrain(ctx, "b"); err != nil || !ok {
+		t.Errorf("drain not imported: %v, %v", ok, err)
+	}
+	if placement, ok, err := store.GetPlacement(ctx, domain.PlacementKey(req)); err != nil || !ok || placement.Host != "a" {
+		t.Errorf("placement not imported: %+v, %v, %v", placement, ok, err)
+	}
+	catalog, err := store.ListEnvironments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog) != len(domain.DefaultEnvironments()) {
+		t.Errorf("imported %d environments, want the %d defaults", len(catalog), len(domain.DefaultEnvironments()))
+	}
+
+	// Changes made after the import aren't overwritten by a later start
+	if err := store.DeleteDrain(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	imported, err = store.ImportState(ctx, tombstones, hosts, environments)
+	if err != nil || imported {
+		t.Fatalf("second ImportState: %v, %v", imported, err)
+	}
+	if _, ok, _ := store.GetDrain(ctx, "b"); ok {
+		t.Error("second import restored a deleted drain")
+	}
+}
@@ -0,0 +1,65 @@
+// Package deploymentstore keeps the records of the deployments the API accepted, the leases
+// electing the replica that runs the background schedulers, and, in the SQL stores, the admin
+// state the replicas share (see SharedState). The backend is chosen by
+// deployments.driver: SQLite for a single API replica, PostgreSQL for replicas sharing the
+// records, or memory for tests and throwaway setups.
+package deploymentstore
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Store keeps deployment records and scheduler leases
+type Store interface {
+	domain.DeploymentStore
+	domain.LeaseStore
+}
+
+// Open opens the deployment store of the configured driver and applies the migrations its
+// database is missing. The SQL stores encrypt the deploy requests with cipher.
+func Open(ctx context.Context, deploymentsConfig config.DeploymentsConfig, cipher *encryption.RecordCipher, logger *zap.Logger) (Store, error) {
+	var d dialect
+	switch deploymentsConfig.Driver {
+	case config.DeploymentsDriverMemory:
+		logger.Warn("Deployment records are kept in memory and lost on restart")
+		return NewMemoryStore(), nil
+	case config.DeploymentsDriverSQLite:
+		d = sqliteDialect
+	case config.DeploymentsDriverPostgres:
+		d = postgresDialect
+	default:
+		return nil, fmt.Errorf("unknown deployment store driver %q", deploymentsConfig.Driver)
+	}
+
+	db, err := sql.Open(d.driverName, deploymentsConfig.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s deployment store: %w", deploymentsConfig.Driver, err)
+	}
+	if deploymentsConfig.Driver == config.DeploymentsDriverSQLite {
+		// SQLite allows one writer at a time; a single connection queues writes instead of failing them as busy
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s deployment store: %w", deploymentsConfig.Driver, err)
+	}
+
+	applied, err := migrate(ctx, db, d)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	logger.Info("Deployment store ready",
+		zap.String("driver", deploymentsConfig.Driver),
+		zap.Int("migrations_applied", applied),
+	)
+
+	return &SQLStore{db: db, dialect: d, cipher: cipher}, nil
+}
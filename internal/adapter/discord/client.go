@@ -1,30 +1,38 @@
 package discord
 
 import (
+	"NYCU-SDC/deployment-service/internal/config"
 	"NYCU-SDC/deployment-service/internal/domain"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-// Client implements domain.Notifier interface
+// Client implements domain.Notifier, domain.NotificationTracker, and domain.ThreadNotifier interfaces
 type Client struct {
-	webhookURL string
-	httpClient *http.Client
-	logger     *zap.Logger
+	discordConfig config.DiscordConfig
+	httpClient    *http.Client
+	logger        *zap.Logger
+
+	// queue holds the request being sent, so requests are sent one at a time
+	queue chan struct{}
+	// resumeAt delays the next request until Discord's rate limit resets
+	resumeAt time.Time
 }
 
 // NewClient creates a new Discord client
-func NewClient(webhookURL string, logger *zap.Logger) *Client {
+func NewClient(discordConfig config.DiscordConfig, logger *zap.Logger) *Client {
 	return &Client{
-		webhookURL: webhookURL,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
-		logger:     logger,
+		discordConfig: discordConfig,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		logger:        logger,
+		queue:         make(chan struct{}, 1),
 	}
 }
 
@@ -46,11 +54,128 @@ type Field struct {
 
 // WebhookPayload represents the Discord webhook payload
 type WebhookPayload struct {
-	Embeds []Embed `json:"embeds"`
+	// Content pings the mentioned users; mentions in embeds don't notify anyone
+	Content         string           `json:"content,omitempty"`
+	Embeds          []Embed          `json:"embeds"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+	// ThreadName creates a thread in the webhook's forum channel
+	ThreadName string `json:"thread_name,omitempty"`
+}
+
+// AllowedMentions restricts who a message pings
+type AllowedMentions struct {
+	Users []string `json:"users"`
+}
+
+// maxThreadNameLength is Discord's limit on thread names
+const maxThreadNameLength = 100
+
+// thread selects where a message is posted: the webhook's channel, an existing thread by ID,
+// or a new thread by name
+type thread struct {
+	id   string
+	name string
+}
+
+// webhookMessage represents the fields of a Discord message used by the service
+type webhookMessage struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	Reactions []struct {
+		Count int `json:"count"`
+	} `json:"reactions"`
 }
 
 // SendNotification sends a notification to Discord
-func (c *Client) SendNotification(ctx context.Context, title, message string, success bool, metadata map[string]string) error {
+func (c *Client) SendNotification(ctx context.Context, title, message string, success bool, metadata map[string]string, mentions []string) error {
+	_, err := c.sendEmbed(ctx, thread{}, title, message, success, metadata, mentions, false)
+	return err
+}
+
+// SendTrackedNotification sends a notification to Discord and returns its message ID
+func (c *Client) SendTrackedNotification(ctx context.Context, title, message string, success bool, metadata map[string]string, mentions []string) (string, error) {
+	sent, err := c.sendEmbed(ctx, thread{}, title, message, success, metadata, mentions, true)
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
+
+// StartThread creates a thread in the webhook's forum channel with the notification as its first message
+func (c *Client) StartThread(ctx context.Context, name, title, message string, success bool, metadata map[string]string, mentions []string) (string, string, error) {
+	sent, err := c.sendEmbed(ctx, thread{name: truncate(name, maxThreadNameLength)}, title, message, success, metadata, mentions, true)
+	if err != nil {
+		return "", "", err
+	}
+	// The channel of the first message of a thread is the thread itself
+	return sent.ChannelID, threadNotificationID(sent.ChannelID, sent.ID), nil
+}
+
+// SendThreadNotification posts a notification into a thread
+func (c *Client) SendThreadNotification(ctx context.Context, threadID, title, message string, success bool, metadata map[string]string, mentions []string) (string, error) {
+	sent, err := c.sendEmbed(ctx, thread{id: threadID}, title, message, success, metadata, mentions, true)
+	if err != nil {
+		return "", err
+	}
+	return threadNotificationID(threadID, sent.ID), nil
+}
+
+// threadNotificationID identifies a message in a thread; messages of the channel are identified by their ID
+func threadNotificationID(threadID, messageID string) string {
+	return threadID + "/" + messageID
+}
+
+// parseNotificationID returns the thread, if any, and the message of a notification ID
+func parseNotificationID(notificationID string) (threadID, messageID string) {
+	if threadID, messageID, ok := strings.Cut(notificationID, "/"); ok {
+		return threadID, messageID
+	}
+	return "", notificationID
+}
+
+// IsAcknowledged reports whether anyone reacted to the message
+func (c *Client) IsAcknowledged(ctx context.Context, notificationID string) (bool, error) {
+	msg, err := c.getMessage(ctx, notificationID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, reaction := range msg.Reactions {
+		if reaction.Count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SendReminder posts a reminder that mentions the configured audience and links to the message
+func (c *Client) SendReminder(ctx context.Context, notificationID, message string) error {
+	content := message
+	if c.discordConfig.Ack.Mention != "" {
+		content = c.discordConfig.Ack.Mention + " " + content
+	}
+	threadID, _ := parseNotificationID(notificationID)
+	if link, err := c.messageLink(ctx, notificationID); err != nil {
+		c.logger.Warn("Failed to build Discord message link", zap.Error(err), zap.String("message_id", notificationID))
+	} else {
+		content += "\n" + link
+	}
+
+	payload := map[string]interface{}{
+		"content": content,
+		"allowed_mentions": map[string]interface{}{
+			"parse": []string{"users", "roles", "everyone"},
+		},
+	}
+	if _, err := c.execute(ctx, payload, threadID, false); err != nil {
+		return err
+	}
+
+	c.logger.Info("Discord reminder sent", zap.String("message_id", notificationID))
+	return nil
+}
+
+func (c *Client) sendEmbed(ctx context.Context, target thread, title, message string, success bool, metadata map[string]string, mentions []string, wait bool) (*webhookMessage, error) {
 	color := 0x00FF00 // Green for success
 	if !success {
 		color = 0xFF0000 // Red for failure
@@ -73,39 +198,176 @@ func (c *Client) SendNotification(ctx context.Context, title, message string, su
 		Timestamp:   time.Now().UTC().Format(time.RFC3339),
 	}
 
-	payload := WebhookPayload{
-		Embeds: []Embed{embed},
+	// Embeds over Discord's limits are split across messages; a tracked notification
+	// is tracked by its first message
+	var sent *webhookMessage
+	batches := batchEmbeds(splitEmbed(embed))
+	for i, batch := range batches {
+		payload := WebhookPayload{
+			Embeds:     batch,
+			ThreadName: target.name,
+		}
+		if i == 0 && len(mentions) > 0 {
+			payload.Content = mentionContent(mentions)
+			payload.AllowedMentions = &AllowedMentions{Users: mentions}
+		}
+		// The first message creates the thread, which the rest are posted in
+		message, err := c.execute(ctx, payload, target.id, (wait || target.name != "") && i == 0)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			sent = message
+			if target.name != "" {
+				target = thread{id: message.ChannelID}
+			}
+		}
 	}
 
+	c.logger.Info("Discord notification sent",
+		zap.String("title", title),
+		zap.Bool("success", success),
+		zap.Int("messages", len(batches)),
+	)
+
+	return sent, nil
+}
+
+// mentionContent pings the given users
+func mentionContent(userIDs []string) string {
+	mentions := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		mentions[i] = "<@" + userID + ">"
+	}
+	return strings.Join(mentions, " ")
+}
+
+// execute posts a payload to the webhook, into the thread threadID unless it is empty.
+// With wait, Discord returns the created message.
+func (c *Client) execute(ctx context.Context, payload interface{}, threadID string, wait bool) (*webhookMessage, error) {
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.webhookURL, bytes.NewBuffer(jsonData))
+	query := url.Values{}
+	if wait {
+		query.Set("wait", "true")
+	}
+	if threadID != "" {
+		query.Set("thread_id", threadID)
+	}
+	webhookURL, err := c.webhookURLWith("", query)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, http.MethodPost, webhookURL, jsonData)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound && threadID != "" {
+		return nil, fmt.Errorf("Discord thread %s not found: %w: %w", threadID, domain.ErrThreadNotFound, domain.ErrInvalidRequest)
+	}
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("Discord API returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("Discord API returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
 	}
 
-	c.logger.Info("Discord notification sent",
-		zap.String("title", title),
-		zap.Bool("success", success),
-	)
+	if !wait {
+		return nil, nil
+	}
+
+	var sent webhookMessage
+	if err := json.NewDecoder(resp.Body).Decode(&sent); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &sent, nil
+}
+
+// getMessage fetches a message sent by the webhook
+func (c *Client) getMessage(ctx context.Context, notificationID string) (*webhookMessage, error) {
+	threadID, messageID := parseNotificationID(notificationID)
+	query := url.Values{}
+	if threadID != "" {
+		query.Set("thread_id", threadID)
+	}
+	messageURL, err := c.webhookURLWith("/messages/"+url.PathEscape(messageID), query)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg webhookMessage
+	if err := c.get(ctx, messageURL, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// messageLink returns the discord.com link to a message sent by the webhook
+func (c *Client) messageLink(ctx context.Context, notificationID string) (string, error) {
+	var webhook struct {
+		GuildID   string `json:"guild_id"`
+		ChannelID string `json:"channel_id"`
+	}
+	if err := c.get(ctx, c.discordConfig.WebhookURL, &webhook); err != nil {
+		return "", err
+	}
+	channelID := webhook.ChannelID
+	threadID, messageID := parseNotificationID(notificationID)
+	if threadID != "" {
+		channelID = threadID
+	}
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", webhook.GuildID, channelID, messageID), nil
+}
+
+func (c *Client) get(ctx context.Context, requestURL string, out interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Discord API returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
 	return nil
 }
 
-// Ensure Client implements domain.Notifier
+// webhookURLWith returns the webhook URL with a path suffix and extra query parameters
+func (c *Client) webhookURLWith(pathSuffix string, query url.Values) (string, error) {
+	if c.discordConfig.WebhookURL == "" {
+		return "", fmt.Errorf("discord webhook URL is not configured: %w", domain.ErrInvalidRequest)
+	}
+	u, err := url.Parse(c.discordConfig.WebhookURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid Discord webhook URL: %w: %w", domain.ErrInvalidRequest, err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + pathSuffix
+	q := u.Query()
+	for key, values := range query {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// CheckHealth verifies that the webhook exists
+func (c *Client) CheckHealth(ctx context.Context) error {
+	var webhook struct {
+		ID string `json:"id"`
+	}
+	return c.get(ctx, c.discordConfig.WebhookURL, &webhook)
+}
+
+// Ensure Client implements domain.Notifier, domain.NotificationTracker, domain.ThreadNotifier, and domain.HealthChecker
 var _ domain.Notifier = (*Client)(nil)
+var _ domain.NotificationTracker = (*Client)(nil)
+var _ domain.ThreadNotifier = (*Client)(nil)
+var _ domain.HealthChecker = (*Client)(nil)
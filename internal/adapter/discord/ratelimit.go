@@ -0,0 +1,117 @@
+package discord
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRateLimitRetries is how often a request rate-limited by Discord is retried
+	maxRateLimitRetries = 5
+	// maxRateLimitWait is the longest Retry-After waited for; longer limits fail the request
+	// so the activity is retried by Temporal instead of blocking the worker
+	maxRateLimitWait = time.Minute
+)
+
+// do sends a request to Discord, one at a time and in order. It waits while the webhook's
+// rate limit is exhausted and retries requests rejected with 429 after their Retry-After.
+// The caller closes the body of the returned response.
+func (c *Client) do(ctx context.Context, method, requestURL string, body []byte) (*http.Response, error) {
+	// Queue behind the requests already being sent
+	select {
+	case c.queue <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.queue }()
+
+	for attempt := 1; ; attempt++ {
+		if err := sleep(ctx, time.Until(c.resumeAt)); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+		}
+
+		// Pause before the next request once the bucket is empty, rather than running into a 429
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			if resetAfter, ok := parseSeconds(resp.Header.Get("X-RateLimit-Reset-After")); ok {
+				c.resumeAt = time.Now().Add(resetAfter)
+			}
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+
+		retryAfter := retryAfter(resp)
+		resp.Body.Close()
+		if attempt > maxRateLimitRetries || retryAfter > maxRateLimitWait {
+			return nil, fmt.Errorf("Discord API rate limit exceeded, retry after %s: %w", retryAfter, domain.ErrUnavailable)
+		}
+		c.logger.Warn("Discord API rate limit exceeded, waiting",
+			zap.Duration("retry_after", retryAfter),
+			zap.Int("attempt", attempt),
+		)
+		c.resumeAt = time.Now().Add(retryAfter)
+	}
+}
+
+// retryAfter returns how long to wait before retrying a 429 response
+func retryAfter(resp *http.Response) time.Duration {
+	if wait, ok := parseSeconds(resp.Header.Get("Retry-After")); ok {
+		return wait
+	}
+	var body struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body); err == nil && body.RetryAfter > 0 {
+		return time.Duration(body.RetryAfter * float64(time.Second))
+	}
+	return time.Second
+}
+
+// parseSeconds parses a header value in (fractional) seconds
+func parseSeconds(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
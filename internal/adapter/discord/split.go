@@ -0,0 +1,132 @@
+package discord
+
+import "unicode/utf8"
+
+// Discord's limits on embeds, see https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	maxTitleLength       = 256
+	maxDescriptionLength = 4096
+	maxFieldsPerEmbed    = 25
+	maxFieldNameLength   = 256
+	maxFieldValueLength  = 1024
+	maxEmbedsPerMessage  = 10
+	maxMessageLength     = 6000
+)
+
+// splitEmbed splits an embed exceeding Discord's limits into embeds within them.
+// The description is continued over as many embeds as needed, followed by the fields in
+// chunks within the field count and message length. Titles and fields are truncated.
+func splitEmbed(embed Embed) []Embed {
+	embed.Title = truncate(embed.Title, maxTitleLength)
+	for i := range embed.Fields {
+		embed.Fields[i].Name = truncate(embed.Fields[i].Name, maxFieldNameLength)
+		embed.Fields[i].Value = truncate(embed.Fields[i].Value, maxFieldValueLength)
+	}
+
+	descriptions := chunk(embed.Description, maxDescriptionLength)
+	// Leave room for the title of continued embeds
+	fieldsLength := maxMessageLength - maxTitleLength
+	var fieldChunks [][]Field
+	var fields []Field
+	length := 0
+	for _, field := range embed.Fields {
+		size := utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+		if len(fields) == maxFieldsPerEmbed || length+size > fieldsLength {
+			fieldChunks = append(fieldChunks, fields)
+			fields, length = nil, 0
+		}
+		fields = append(fields, field)
+		length += size
+	}
+	if len(fields) > 0 {
+		fieldChunks = append(fieldChunks, fields)
+	}
+
+	// Continue the description, then add the fields; the first chunk of fields shares
+	// the last embed of the description when it fits
+	var embeds []Embed
+	for _, description := range descriptions {
+		embeds = append(embeds, continuedEmbed(embed, len(embeds), description))
+	}
+	for i, fields := range fieldChunks {
+		if i == 0 && len(embeds) > 0 {
+			last := &embeds[len(embeds)-1]
+			if embedLength(*last)+embedLength(Embed{Fields: fields}) <= maxMessageLength {
+				last.Fields = fields
+				continue
+			}
+		}
+		next := continuedEmbed(embed, len(embeds), "")
+		next.Fields = fields
+		embeds = append(embeds, next)
+	}
+	if len(embeds) == 0 {
+		embeds = append(embeds, continuedEmbed(embed, 0, ""))
+	}
+	return embeds
+}
+
+// batchEmbeds groups embeds into messages within the per-message embed count and length
+func batchEmbeds(embeds []Embed) [][]Embed {
+	var batches [][]Embed
+	var batch []Embed
+	length := 0
+	for _, embed := range embeds {
+		size := embedLength(embed)
+		if len(batch) > 0 && (len(batch) == maxEmbedsPerMessage || length+size > maxMessageLength) {
+			batches = append(batches, batch)
+			batch, length = nil, 0
+		}
+		batch = append(batch, embed)
+		length += size
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// embedLength counts the characters Discord counts towards the message length
+func embedLength(embed Embed) int {
+	length := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	for _, field := range embed.Fields {
+		length += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	return length
+}
+
+// chunk splits s into pieces of at most size characters
+func chunk(s string, size int) []string {
+	if s == "" {
+		return nil
+	}
+	runes := []rune(s)
+	var chunks []string
+	for len(runes) > size {
+		chunks = append(chunks, string(runes[:size]))
+		runes = runes[size:]
+	}
+	return append(chunks, string(runes))
+}
+
+// truncate shortens s to at most size characters, marking the cut with an ellipsis
+func truncate(s string, size int) string {
+	if utf8.RuneCountInString(s) <= size {
+		return s
+	}
+	return string([]rune(s)[:size-1]) + "…"
+}
+
+// continuedEmbed returns the index-th embed of a split embed with the given description
+func continuedEmbed(embed Embed, index int, description string) Embed {
+	title := embed.Title
+	if index > 0 {
+		title = truncate(title+" (continued)", maxTitleLength)
+	}
+	return Embed{
+		Title:       title,
+		Description: description,
+		Color:       embed.Color,
+		Timestamp:   embed.Timestamp,
+	}
+}
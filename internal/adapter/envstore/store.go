@@ -0,0 +1,129 @@
+package envstore
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Store keeps the environments catalog in a JSON file keyed by environment name.
+// Until the file is first written, the catalog holds domain.DefaultEnvironments.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStore creates a new environment store backed by the given file
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// GetEnvironment returns the environment with the given name and whether it exists
+func (s *Store) GetEnvironment(ctx context.Context, name string) (domain.Environment, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	environments, err := s.load()
+	if err != nil {
+		return domain.Environment{}, false, err
+	}
+	environment, ok := environments[name]
+	return environment, ok, nil
+}
+
+// PutEnvironment creates or replaces an environment
+func (s *Store) PutEnvironment(ctx context.Context, environment domain.Environment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	environments, err := s.load()
+	if err != nil {
+		return err
+	}
+	environments[environment.Name] = environment
+	return s.save(environments)
+}
+
+// DeleteEnvironment removes an environment
+func (s *Store) DeleteEnvironment(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	environments, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := environments[name]; !ok {
+		return nil
+	}
+	delete(environments, name)
+	return s.save(environments)
+}
+
+// ListEnvironments returns the environments, ordered by name
+func (s *Store) ListEnvironments(ctx context.Context) ([]domain.Environment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	environments, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]domain.Environment, 0, len(environments))
+	for _, environment := range environments {
+		list = append(list, environment)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+func (s *Store) load() (map[string]domain.Environment, error) {
+	environments := make(map[string]domain.Environment)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			for _, environment := range domain.DefaultEnvironments() {
+				environments[environment.Name] = environment
+			}
+			return environments, nil
+		}
+		return nil, fmt.Errorf("failed to read environment store: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return environments, nil
+	}
+
+	if err := json.Unmarshal(data, &environments); err != nil {
+		return nil, fmt.Errorf("failed to decode environment store: %w", err)
+	}
+	return environments, nil
+}
+
+func (s *Store) save(environments map[string]domain.Environment) error {
+	data, err := json.MarshalIndent(environments, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so a concurrent reader never sees a partial file
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write environment store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace environment store: %w", err)
+	}
+	return nil
+}
+
+// Ensure Store implements domain.EnvironmentStore
+var _ domain.EnvironmentStore = (*Store)(nil)
@@ -0,0 +1,299 @@
+package fleet
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// cacheTTL is how long the fleet status is reused before the workers are polled again
+const cacheTTL = 30 * time.Second
+
+// Client implements domain.WorkerFleet by polling the info endpoints of the workers
+type Client struct {
+	urls []string
+	// token is sent to the workers as x-deploy-token
+	token      string
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	mu       sync.Mutex
+	cached   []domain.WorkerStatus
+	cachedAt time.Time
+}
+
+// NewClient creates a new fleet client for the workers at the given base URLs, authorized by
+// the worker token
+func NewClient(urls []string, token string, logger *zap.Logger) *Client {
+	return &Client{
+		urls:       urls,
+		token:      token,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		logger:     logger,
+	}
+}
+
+// ListWorkers returns the status of every configured worker
+func (c *Client) ListWorkers(ctx context.Context) []domain.WorkerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != nil && time.Since(c.cachedAt) < cacheTTL {
+		return c.cached
+	}
+
+	statuses := make([]domain.WorkerStatus, len(c.urls))
+	var wg sync.WaitGroup
+	for i, workerURL := range c.urls {
+		wg.Add(1)
+		go func(i int, workerURL string) {
+			defer wg.Done()
+			statuses[i] = c.fetch(ctx, workerURL)
+		}(i, workerURL)
+	}
+	wg.Wait()
+
+	c.cached = statuses
+	c.cachedAt = time.Now()
+	return statuses
+}
+
+func (c *Client) fetch(ctx context.Context, workerURL string) domain.WorkerStatus {
+	status := domain.WorkerStatus{URL: workerURL}
+
+	info, err := c.fetchInfo(ctx, workerURL)
+	if err != nil {
+		c.logger.Warn("Failed to fetch worker info", zap.String("url", workerURL), zap.Error(err))
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Reachable = true
+	status.Info = info
+	return status
+}
+
+func (c *Client) fetchInfo(ctx context.Context, workerURL string) (*domain.WorkerInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(workerURL, "/")+"/api/info", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worker returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
+	}
+
+	var info domain.WorkerInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &info, nil
+}
+
+// ListNotificationFailures returns the failed notifications of every reachable worker,
+// most recent first. It fails only if no worker could be reached.
+func (c *Client) ListNotificationFailures(ctx context.Context) ([]domain.NotificationFailure, error) {
+	results := make([][]domain.NotificationFailure, len(c.urls))
+	errs := make([]error, len(c.urls))
+	var wg sync.WaitGroup
+	for i, workerURL := range c.urls {
+		wg.Add(1)
+		go func(i int, workerURL string) {
+			defer wg.Done()
+			results[i], errs[i] = c.fetchFailures(ctx, workerURL)
+		}(i, workerURL)
+	}
+	wg.Wait()
+
+	failures := []domain.NotificationFailure{}
+	reachable := false
+	for i, workerURL := range c.urls {
+		if errs[i] != nil {
+			c.logger.Warn("Failed to fetch worker notification failures", zap.String("url", workerURL), zap.Error(errs[i]))
+			continue
+		}
+		reachable = true
+		for _, failure := range results[i] {
+			failure.Worker = workerURL
+			failures = append(failures, failure)
+		}
+	}
+	if !reachable && len(c.urls) > 0 {
+		return nil, fmt.Errorf("no worker could be reached: %w", errors.Join(errs...))
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].LastFailedAt.After(failures[j].LastFailedAt)
+	})
+	return failures, nil
+}
+
+// RetryNotificationFailure resends a failed notification through the worker storing it
+func (c *Client) RetryNotificationFailure(ctx context.Context, id string) error {
+	failures, err := c.ListNotificationFailures(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, failure := range failures {
+		if failure.ID == id {
+			return c.retryFailure(ctx, failure.Worker, id)
+		}
+	}
+	return domain.ErrNotificationFailureNotFound
+}
+
+func (c *Client) fetchFailures(ctx context.Context, workerURL string) ([]domain.NotificationFailure, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(workerURL, "/")+"/api/notifications/failures", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worker returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
+	}
+
+	var response struct {
+		Failures []domain.NotificationFailure `json:"failures"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return response.Failures, nil
+}
+
+func (c *Client) retryFailure(ctx context.Context, workerURL, id string) error {
+	url := strings.TrimSuffix(workerURL, "/") + "/api/notifications/failures/" + id + "/retry"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return domain.ErrNotificationFailureNotFound
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("worker returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
+
+// PinHostKey pins publicKey for host on every worker, so whichever worker runs the next
+// deployment accepts the new key. An empty host means the deploy host each worker is configured
+// with. Pinning is idempotent, so after a partial failure the rotation can simply be repeated.
+func (c *Client) PinHostKey(ctx context.Context, host, publicKey string, grace time.Duration) error {
+	body, err := json.Marshal(map[string]any{
+		"host":                 host,
+		"public_key":           publicKey,
+		"grace_period_seconds": int(grace / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+	return c.broadcast(ctx, "POST", "/api/hostkeys", body, "Failed to pin host key on worker")
+}
+
+// SetCapabilities turns off the disabled capabilities on every worker and, unless drivers
+// is nil, makes them accept only drivers. Like pinning, it can be repeated after a partial failure.
+func (c *Client) SetCapabilities(ctx context.Context, disabled, drivers []string) error {
+	request := map[string]any{"disabled": disabled}
+	if drivers != nil {
+		request["drivers"] = drivers
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	err = c.broadcast(ctx, "PUT", "/api/capabilities", body, "Failed to set capabilities on worker")
+
+	// The cached fleet status reports the old capabilities
+	c.mu.Lock()
+	c.cached = nil
+	c.mu.Unlock()
+	return err
+}
+
+// broadcast sends body to path on every worker, returning the errors of the workers that failed
+func (c *Client) broadcast(ctx context.Context, method, path string, body []byte, failure string) error {
+	errs := make([]error, len(c.urls))
+	var wg sync.WaitGroup
+	for i, workerURL := range c.urls {
+		wg.Add(1)
+		go func(i int, workerURL string) {
+			defer wg.Done()
+			if err := c.send(ctx, method, workerURL+path, body); err != nil {
+				c.logger.Warn(failure, zap.String("url", workerURL), zap.Error(err))
+				errs[i] = fmt.Errorf("worker %s: %w", workerURL, err)
+			}
+		}(i, strings.TrimSuffix(workerURL, "/"))
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (c *Client) send(ctx context.Context, method, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("worker returned status %d: %s: %w", resp.StatusCode, strings.TrimSpace(string(message)), domain.ErrorForStatus(resp.StatusCode))
+	}
+	return nil
+}
+
+// do sends req to a worker with the worker token
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("x-deploy-token", c.token)
+	}
+	return c.httpClient.Do(req)
+}
+
+// Ensure Client implements domain.WorkerFleet, domain.NotificationFailureSource, domain.HostKeyManager,
+// and domain.CapabilityManager
+var _ domain.WorkerFleet = (*Client)(nil)
+var _ domain.NotificationFailureSource = (*Client)(nil)
+var _ domain.HostKeyManager = (*Client)(nil)
+var _ domain.CapabilityManager = (*Client)(nil)
@@ -0,0 +1,125 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPinHostKeyReachesEveryWorker(t *testing.T) {
+	var mu sync.Mutex
+	pinned := map[string]map[string]any{}
+	newWorker := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" || r.URL.Path != "/api/hostkeys" {
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+			if got := r.Header.Get("x-deploy-token"); got != "worker-token" {
+				t.Errorf("x-deploy-token = %q", got)
+			}
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			pinned[name] = body
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	client := NewClient([]string{newWorker("a").URL, newWorker("b").URL + "/"}, "worker-token", zap.NewNop())
+	if err := client.PinHostKey(context.Background(), "deploy.example.com:22", "ssh-ed25519 AAAA", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pinned) != 2 {
+		t.Fatalf("key pinned on %d workers, want 2", len(pinned))
+	}
+	for name, body := range pinned {
+		if body["host"] != "deploy.example.com:22" || body["public_key"] != "ssh-ed25519 AAAA" || body["grace_period_seconds"] != float64(3600) {
+			t.Errorf("worker %s got %v", name, body)
+		}
+	}
+}
+
+func TestPinHostKeyReportsFailedWorkers(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ok.Close)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "host key store is not configured", http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+
+	client := NewClient([]string{ok.URL, failing.URL}, "", zap.NewNop())
+	err := client.PinHostKey(context.Background(), "", "ssh-ed25519 AAAA", time.Hour)
+	if err == nil {
+		t.Fatal("expected an error when a worker fails")
+	}
+	if !strings.Contains(err.Error(), failing.URL) || strings.Contains(err.Error(), ok.URL+":") {
+		t.Errorf("error should name only the failed worker: %v", err)
+	}
+}
+
+func TestSetCapabilitiesReachesEveryWorker(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]map[string]any{}
+	newWorker := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "PUT" || r.URL.Path != "/api/capabilities" {
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+			if got := r.Header.Get("x-deploy-token"); got != "worker-token" {
+				t.Errorf("x-deploy-token = %q", got)
+			}
+			var body map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			requests[name] = body
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	client := NewClient([]string{newWorker("a").URL, newWorker("b").URL + "/"}, "worker-token", zap.NewNop())
+	if err := client.SetCapabilities(context.Background(), []string{"dns"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("capabilities set on %d workers, want 2", len(requests))
+	}
+	for name, body := range requests {
+		disabled, _ := body["disabled"].([]any)
+		if len(disabled) != 1 || disabled[0] != "dns" {
+			t.Errorf("worker %s got disabled %v", name, body["disabled"])
+		}
+		// Without drivers the workers keep the ones they accept
+		if _, ok := body["drivers"]; ok {
+			t.Errorf("worker %s got drivers %v", name, body["drivers"])
+		}
+	}
+
+	if err := client.SetCapabilities(context.Background(), []string{}, []string{"script"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range requests {
+		if drivers, _ := body["drivers"].([]any); len(drivers) != 1 || drivers[0] != "script" {
+			t.Errorf("worker %s got drivers %v", name, body["drivers"])
+		}
+	}
+}
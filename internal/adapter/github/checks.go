@@ -0,0 +1,112 @@
+package github
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// maxCheckRuns is the number of check runs read per commit, the most GitHub returns in one page
+const maxCheckRuns = 100
+
+// FetchCommitChecks returns the check runs (GitHub Actions and other apps) and the commit
+// statuses reported on a commit. Only the latest status of each context is returned.
+func (c *Client) FetchCommitChecks(ctx context.Context, repo, sha string) ([]domain.CommitCheck, error) {
+	var combined struct {
+		Statuses []struct {
+			Context string `json:"context"`
+			State   string `json:"state"`
+		} `json:"statuses"`
+	}
+	if err := c.getJSON(ctx, fmt.Sprintf("/repos/%s/commits/%s/status", repo, url.PathEscape(sha)), nil, &combined); err != nil {
+		return nil, fmt.Errorf("failed to fetch commit statuses: %w", err)
+	}
+
+	var runs struct {
+		CheckRuns []struct {
+			Name       string `json:"name"`
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	query := url.Values{"per_page": []string{fmt.Sprint(maxCheckRuns)}}
+	if err := c.getJSON(ctx, fmt.Sprintf("/repos/%s/commits/%s/check-runs", repo, url.PathEscape(sha)), query, &runs); err != nil {
+		return nil, fmt.Errorf("failed to fetch check runs: %w", err)
+	}
+
+	checks := make([]domain.CommitCheck, 0, len(combined.Statuses)+len(runs.CheckRuns))
+	for _, status := range combined.Statuses {
+		checks = append(checks, domain.CommitCheck{Name: status.Context, State: statusState(status.State)})
+	}
+	for _, run := range runs.CheckRuns {
+		checks = append(checks, domain.CommitCheck{Name: run.Name, State: checkRunState(run.Status, run.Conclusion)})
+	}
+
+	c.logger.Debug("Fetched commit checks",
+		zap.String("repo", repo),
+		zap.String("sha", sha),
+		zap.Int("count", len(checks)),
+	)
+	return checks, nil
+}
+
+// statusState maps the state of a commit status (error, failure, pending, success)
+func statusState(state string) string {
+	switch state {
+	case "success":
+		return domain.CheckStateSuccess
+	case "pending":
+		return domain.CheckStatePending
+	default:
+		return domain.CheckStateFailure
+	}
+}
+
+// checkRunState maps the status and conclusion of a check run; neutral and skipped runs pass
+func checkRunState(status, conclusion string) string {
+	if status != "completed" {
+		return domain.CheckStatePending
+	}
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return domain.CheckStateSuccess
+	default:
+		return domain.CheckStateFailure
+	}
+}
+
+// getJSON sends a GET request to the GitHub API and decodes the JSON response into out
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if len(query) > 0 {
+		req.URL.RawQuery = query.Encode()
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub API returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package github
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultAPIURL = "https://api.github.com"
+
+// Client implements domain.RepositoryProvider interface
+type Client struct {
+	apiURL     string
+	token      string
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewClient creates a new GitHub client
+// An empty token is allowed for public repositories (subject to lower rate limits)
+func NewClient(apiURL, token string, logger *zap.Logger) *Client {
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	return &Client{
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+	}
+}
+
+// FetchFile fetches the raw content of a file in a repository at the given ref
+func (c *Client) FetchFile(ctx context.Context, repo, ref, path string) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/contents/%s", c.apiURL, repo, strings.TrimPrefix(path, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github.raw+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	if ref != "" {
+		req.URL.RawQuery = url.Values{"ref": []string{ref}}.Encode()
+	}
+
+	c.logger.Debug("Fetching file from GitHub",
+		zap.String("repo", repo),
+		zap.String("ref", ref),
+		zap.String("path", path),
+	)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s not found in %s@%s: %w", path, repo, ref, domain.ErrFileNotFound)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		c.logger.Error("GitHub API returned error",
+			zap.Int("status_code", resp.StatusCode),
+			zap.String("url", req.URL.String()),
+			zap.String("response_body", string(bodyBytes)),
+		)
+		return nil, fmt.Errorf("GitHub API returned status %d: %s: %w", resp.StatusCode, string(bodyBytes), domain.ErrorForStatus(resp.StatusCode))
+	}
+
+	return bodyBytes, nil
+}
+
+// FetchCommitAuthor returns the author of a commit, with the GitHub account linked to the author's email
+func (c *Client) FetchCommitAuthor(ctx context.Context, repo, sha string) (domain.CommitAuthor, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/commits/%s", c.apiURL, repo, url.PathEscape(sha))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return domain.CommitAuthor{}, err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return domain.CommitAuthor{}, fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return domain.CommitAuthor{}, fmt.Errorf("GitHub API returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
+	}
+
+	var commit struct {
+		Commit struct {
+			Author struct {
+				Name  string `json:"name"`
+				Email string `json:"email"`
+			} `json:"author"`
+		} `json:"commit"`
+		// Author is null when the email isn't linked to a GitHub account
+		Author *struct {
+			Login string `json:"login"`
+		} `json:"author"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		return domain.CommitAuthor{}, fmt.Errorf("failed to decode commit: %w", err)
+	}
+
+	author := domain.CommitAuthor{
+		Name:  commit.Commit.Author.Name,
+		Email: commit.Commit.Author.Email,
+	}
+	if commit.Author != nil {
+		author.Login = commit.Author.Login
+	}
+	return author, nil
+}
+
+// CheckHealth verifies that the GitHub API is reachable and accepts the token.
+// The rate limit endpoint doesn't count against the rate limit.
+func (c *Client) CheckHealth(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.apiURL+"/rate_limit", nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub API returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
+	}
+	return nil
+}
+
+// Ensure Client implements domain.RepositoryProvider, domain.CommitStatusProvider, and domain.HealthChecker
+var _ domain.RepositoryProvider = (*Client)(nil)
+var _ domain.CommitStatusProvider = (*Client)(nil)
+var _ domain.HealthChecker = (*Client)(nil)
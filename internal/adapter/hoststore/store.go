@@ -0,0 +1,316 @@
+package hoststore
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// state is the stored state
+type state struct {
+	Drains     map[string]domain.HostDrain
+	Placements map[string]domain.Placement
+	Queue      []domain.QueuedDeployment
+}
+
+// stateFile is the content of the store file. Placements and queued deployments hold deploy
+// requests, so each is encrypted with the keys of the namespace of its environment.
+type stateFile struct {
+	Drains     map[string]domain.HostDrain `json:"drains"`
+	Placements map[string]json.RawMessage  `json:"placements"`
+	Queue      []json.RawMessage           `json:"queue"`
+}
+
+// Store keeps the drain state of deploy hosts, the placement of environments,
+// and deployments waiting for a host in a JSON file
+type Store struct {
+	path   string
+	cipher *encryption.RecordCipher
+	mu     sync.Mutex
+	// claimed holds the trace IDs of the queued deployments being started
+	claimed map[string]bool
+}
+
+// NewStore creates a new host store backed by the given file
+func NewStore(path string, cipher *encryption.RecordCipher) *Store {
+	return &Store{path: path, cipher: cipher, claimed: make(map[string]bool)}
+}
+
+// GetDrain returns the drain of a host and whether the host is draining
+func (s *Store) GetDrain(ctx context.Context, host string) (domain.HostDrain, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return domain.HostDrain{}, false, err
+	}
+	drain, ok := st.Drains[host]
+	return drain, ok, nil
+}
+
+// PutDrain marks a host as draining
+func (s *Store) PutDrain(ctx context.Context, drain domain.HostDrain) error {
+	return s.update(func(st *state) {
+		st.Drains[drain.Host] = drain
+	})
+}
+
+// DeleteDrain marks a host as available again
+func (s *Store) DeleteDrain(ctx context.Context, host string) error {
+	return s.update(func(st *state) {
+		delete(st.Drains, host)
+	})
+}
+
+// ListDrains returns the draining hosts, oldest drain first
+func (s *Store) ListDrains(ctx context.Context) ([]domain.HostDrain, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]domain.HostDrain, 0, len(st.Drains))
+	for _, drain := range st.Drains {
+		list = append(list, drain)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].DrainedAt.Before(list[j].DrainedAt)
+	})
+	return list, nil
+}
+
+// GetPlacement returns the placement of an environment and whether it exists
+func (s *Store) GetPlacement(ctx context.Context, key string) (domain.Placement, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return domain.Placement{}, false, err
+	}
+	placement, ok := st.Placements[key]
+	return placement, ok, nil
+}
+
+// PutPlacement creates or replaces the placement of an environment
+func (s *Store) PutPlacement(ctx context.Context, placement domain.Placement) error {
+	return s.update(func(st *state) {
+		st.Placements[placement.Key] = placement
+	})
+}
+
+// DeletePlacement removes the placement of an environment
+func (s *Store) DeletePlacement(ctx context.Context, key string) error {
+	return s.update(func(st *state) {
+		delete(st.Placements, key)
+	})
+}
+
+// ListPlacements returns the placements of all environments, sorted by key
+func (s *Store) ListPlacements(ctx context.Context) ([]domain.Placement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]domain.Placement, 0, len(st.Placements))
+	for _, placement := range st.Placements {
+		list = append(list, placement)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Key < list[j].Key
+	})
+	return list, nil
+}
+
+// ReservePlacement creates or replaces placement unless quota other environments of its
+// environment are placed already. The file is only locked within the process, so the quota
+// holds for a single API replica.
+func (s *Store) ReservePlacement(ctx context.Context, placement domain.Placement, quota int) (bool, error) {
+	reserved := false
+	err := s.update(func(st *state) {
+		placed := 0
+		for key, other := range st.Placements {
+			if key != placement.Key && other.Request.Metadata.Environment == placement.Request.Metadata.Environment {
+				placed++
+			}
+		}
+		if placed >= quota {
+			return
+		}
+		st.Placements[placement.Key] = placement
+		reserved = true
+	})
+	if err != nil {
+		return false, err
+	}
+	return reserved, nil
+}
+
+// QueueDeployment stores a deployment until a host becomes available
+func (s *Store) QueueDeployment(ctx context.Context, queued domain.QueuedDeployment) error {
+	return s.update(func(st *state) {
+		st.Queue = append(st.Queue, queued)
+	})
+}
+
+// StartQueuedDeployment passes the oldest queued deployment no other call is starting to start,
+// and removes it from the queue once start succeeds
+func (s *Store) StartQueuedDeployment(ctx context.Context, start func(domain.QueuedDeployment) error) (bool, error) {
+	queued, ok, err := s.claimQueuedDeployment()
+	if err != nil || !ok {
+		return false, err
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.claimed, queued.TraceID)
+		s.mu.Unlock()
+	}()
+
+	if err := start(queued); err != nil {
+		return true, err
+	}
+	return true, s.update(func(st *state) {
+		for i := range st.Queue {
+			if st.Queue[i].TraceID == queued.TraceID {
+				st.Queue = append(st.Queue[:i], st.Queue[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// claimQueuedDeployment returns the oldest queued deployment that isn't claimed and claims it
+func (s *Store) claimQueuedDeployment() (domain.QueuedDeployment, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return domain.QueuedDeployment{}, false, err
+	}
+	for _, queued := range st.Queue {
+		if !s.claimed[queued.TraceID] {
+			s.claimed[queued.TraceID] = true
+			return queued, true, nil
+		}
+	}
+	return domain.QueuedDeployment{}, false, nil
+}
+
+// ListQueuedDeployments returns the queued deployments, oldest first
+func (s *Store) ListQueuedDeployments(ctx context.Context) ([]domain.QueuedDeployment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return st.Queue, nil
+}
+
+// update applies fn to the stored state and saves the result
+func (s *Store) update(fn func(st *state)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return err
+	}
+	fn(st)
+	return s.save(st)
+}
+
+func (s *Store) load() (*state, error) {
+	st := &state{
+		Drains:     make(map[string]domain.HostDrain),
+		Placements: make(map[string]domain.Placement),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, fmt.Errorf("failed to read host store: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return st, nil
+	}
+
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode host store: %w", err)
+	}
+	if file.Drains != nil {
+		st.Drains = file.Drains
+	}
+	for key, record := range file.Placements {
+		var placement domain.Placement
+		if err := s.cipher.Open(record, &placement); err != nil {
+			return nil, fmt.Errorf("failed to decode placement %s: %w", key, err)
+		}
+		st.Placements[key] = placement
+	}
+	for i, record := range file.Queue {
+		var queued domain.QueuedDeployment
+		if err := s.cipher.Open(record, &queued); err != nil {
+			return nil, fmt.Errorf("failed to decode queued deployment %d: %w", i, err)
+		}
+		st.Queue = append(st.Queue, queued)
+	}
+	return st, nil
+}
+
+func (s *Store) save(st *state) error {
+	file := stateFile{
+		Drains:     st.Drains,
+		Placements: make(map[string]json.RawMessage, len(st.Placements)),
+	}
+	for key, placement := range st.Placements {
+		record, err := s.cipher.Seal(s.cipher.Namespace(placement.Request.Metadata.Environment), placement.Request.Metadata.ProjectName, placement)
+		if err != nil {
+			return fmt.Errorf("failed to encode placement %s: %w", key, err)
+		}
+		file.Placements[key] = record
+	}
+	for _, queued := range st.Queue {
+		record, err := s.cipher.Seal(s.cipher.Namespace(queued.Request.Metadata.Environment), queued.Request.Metadata.ProjectName, queued)
+		if err != nil {
+			return fmt.Errorf("failed to encode queued deployment %s: %w", queued.TraceID, err)
+		}
+		file.Queue = append(file.Queue, record)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so a concurrent reader never sees a partial file
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write host store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace host store: %w", err)
+	}
+	return nil
+}
+
+// Ensure Store implements domain.HostStore
+var _ domain.HostStore = (*Store)(nil)
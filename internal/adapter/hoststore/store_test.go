@@ -0,0 +1,181 @@
+package hoststore
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStore creates a store in a new directory, encrypting the records of the production namespace
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dir := t.TempDir()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := encryption.NewRecordCipher(
+		config.EncryptionConfig{Namespaces: map[string][]config.EncryptionKeyConfig{"deploy-production": {{ID: "k1", File: keyFile}}}},
+		config.TemporalConfig{Namespace: "deploy", Namespaces: map[string]string{"production": "deploy-production"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(filepath.Join(dir, "hosts.json"), cipher)
+}
+
+func testRequest(environment, title string) domain.DeployRequest {
+	return domain.DeployRequest{
+		TraceID:  "trace-" + environment,
+		Source:   domain.SourceInfo{Repo: "org/app", PRTitle: title},
+		Metadata: domain.MetadataInfo{ProjectName: "app", Component: "api", Environment: environment},
+	}
+}
+
+func TestStoreEncryptsRequests(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	req := testRequest("production", "production-secret-title")
+	if err := store.PutPlacement(ctx, domain.Placement{Key: domain.PlacementKey(req), Host: "a", Request: req, UpdatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.QueueDeployment(ctx, domain.QueuedDeployment{TraceID: req.TraceID, Request: req, QueuedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutDrain(ctx, domain.HostDrain{Host: "b", Reason: "maintenance", DrainedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "production-secret-title") {
+		t.Fatalf("store file holds the plaintext request:\n%s", data)
+	}
+
+	placement, ok, err := store.GetPlacement(ctx, domain.PlacementKey(req))
+	if err != nil || !ok {
+		t.Fatalf("GetPlacement: %v, %v", ok, err)
+	}
+	if placement.Host != "a" || placement.Request.Source.PRTitle != "production-secret-title" {
+		t.Errorf("unexpected placement %+v", placement)
+	}
+	var queued domain.QueuedDeployment
+	ok, err = store.StartQueuedDeployment(ctx, func(deployment domain.QueuedDeployment) error {
+		queued = deployment
+		return nil
+	})
+	if err != nil || !ok {
+		t.Fatalf("StartQueuedDeployment: %v, %v", ok, err)
+	}
+	if queued.Request.Source.PRTitle != "production-secret-title" {
+		t.Errorf("unexpected queued deployment %+v", queued)
+	}
+	if _, ok, err := store.GetDrain(ctx, "b"); err != nil || !ok {
+		t.Errorf("GetDrain: %v, %v", ok, err)
+	}
+}
+
+func TestStoreReadsPlaintextState(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	// Written before encryption was enabled
+	legacy := `{
+		"drains": {"b": {"host": "b", "drained_at": "2026-01-01T00:00:00Z"}},
+		"placements": {"app/api/production": {"key": "app/api/production", "host": "a", "request": {"metadata": {"environment": "production"}}}},
+		"queue": [{"trace_id": "t1", "request": {"metadata": {"environment": "production"}}}]
+	}`
+	if err := os.WriteFile(store.path, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	placements, err := store.ListPlacements(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(placements) != 1 || placements[0].Host != "a" {
+		t.Errorf("unexpected placements %+v", placements)
+	}
+	queue, err := store.ListQueuedDeployments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || queue[0].TraceID != "t1" {
+		t.Errorf("unexpected queue %+v", queue)
+	}
+}
+
+func TestStoreReservePlacementEnforcesQuota(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	first := testRequest("preview", "first")
+	second := testRequest("preview", "second")
+	second.Metadata.Component = "web"
+
+	if ok, err := store.ReservePlacement(ctx, domain.Placement{Key: domain.PlacementKey(first), Host: "a", Request: first}, 1); err != nil || !ok {
+		t.Fatalf("first reservation: %v, %v", ok, err)
+	}
+	if ok, err := store.ReservePlacement(ctx, domain.Placement{Key: domain.PlacementKey(second), Host: "a", Request: second}, 1); err != nil || ok {
+		t.Fatalf("reservation over the quota: %v, %v", ok, err)
+	}
+	// The placed environment itself may still be replaced
+	if ok, err := store.ReservePlacement(ctx, domain.Placement{Key: domain.PlacementKey(first), Host: "b", Request: first}, 1); err != nil || !ok {
+		t.Fatalf("re-reserving a placed environment: %v, %v", ok, err)
+	}
+}
+
+func TestStoreStartQueuedDeploymentKeepsFailedStarts(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	for _, environment := range []string{"first", "second"} {
+		req := testRequest(environment, "")
+		if err := store.QueueDeployment(ctx, domain.QueuedDeployment{TraceID: req.TraceID, Request: req, QueuedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed := errors.New("temporal unavailable")
+	ok, err := store.StartQueuedDeployment(ctx, func(queued domain.QueuedDeployment) error {
+		// The deployment being started is claimed, so another call gets the next one
+		ok, err := store.StartQueuedDeployment(ctx, func(next domain.QueuedDeployment) error {
+			if next.TraceID != "trace-second" {
+				t.Errorf("concurrent start got %s", next.TraceID)
+			}
+			return nil
+		})
+		if !ok || err != nil {
+			t.Errorf("concurrent start: %v, %v", ok, err)
+		}
+		return failed
+	})
+	if !ok || !errors.Is(err, failed) {
+		t.Fatalf("failed start: %v, %v", ok, err)
+	}
+
+	queue, err := store.ListQueuedDeployments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || queue[0].TraceID != "trace-first" {
+		t.Errorf("unexpected queue %+v", queue)
+	}
+}
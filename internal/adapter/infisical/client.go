@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -106,12 +107,12 @@ func (c *Client) fetchFromAPI(ctx context.Context, projectID, environment string
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Infisical API returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("Infisical API returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
 	}
 
 	var apiResponse struct {
@@ -203,7 +204,7 @@ func (c *Client) fetchSecretRaw(ctx context.Context, workspaceSlug, environment,
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
 	}
 	defer resp.Body.Close()
 
@@ -220,7 +221,7 @@ func (c *Client) fetchSecretRaw(ctx context.Context, workspaceSlug, environment,
 			zap.String("response_body", string(bodyBytes)),
 			zap.String("url", req.URL.String()),
 		)
-		return "", fmt.Errorf("Infisical API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", fmt.Errorf("Infisical API returned status %d: %s: %w", resp.StatusCode, string(bodyBytes), domain.ErrorForStatus(resp.StatusCode))
 	}
 
 	// Try to parse as JSON first (expected format)
@@ -282,5 +283,28 @@ func (c *Client) fetchSecretRaw(ctx context.Context, workspaceSlug, environment,
 	return secretValue, nil
 }
 
-// Ensure Client implements domain.SecretManager
+// CheckHealth verifies that Infisical is reachable and accepts the service token
+func (c *Client) CheckHealth(ctx context.Context) error {
+	url := strings.TrimSuffix(c.baseURL, "/") + "/api/v2/service-token"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.serviceToken))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Infisical API returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
+	}
+	return nil
+}
+
+// Ensure Client implements domain.SecretManager and domain.HealthChecker
 var _ domain.SecretManager = (*Client)(nil)
+var _ domain.HealthChecker = (*Client)(nil)
@@ -0,0 +1,173 @@
+package nodeexporter
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Client implements domain.LoadProbe and domain.HostHealthProbe by scraping the metrics of Prometheus node exporter
+type Client struct {
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewClient creates a new node exporter client
+func NewClient(logger *zap.Logger) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+	}
+}
+
+// QueryLoad reads the load average and CPU count from the node exporter at address
+func (c *Client) QueryLoad(ctx context.Context, address string) (domain.HostLoad, error) {
+	metrics, err := c.scrape(ctx, address)
+	if err != nil {
+		return domain.HostLoad{}, err
+	}
+
+	load, err := parseMetrics(bytes.NewReader(metrics))
+	if err != nil {
+		return domain.HostLoad{}, err
+	}
+	c.logger.Debug("Queried host load",
+		zap.String("address", address),
+		zap.Float64("load1", load.Load1),
+		zap.Int("cpus", load.CPUs),
+	)
+	return load, nil
+}
+
+// QueryHealth reads the load and the usage of the filesystem mounted at mountpoint from the
+// node exporter at address
+func (c *Client) QueryHealth(ctx context.Context, address, mountpoint string) (domain.HostHealth, error) {
+	metrics, err := c.scrape(ctx, address)
+	if err != nil {
+		return domain.HostHealth{}, err
+	}
+
+	load, err := parseMetrics(bytes.NewReader(metrics))
+	if err != nil {
+		return domain.HostHealth{}, err
+	}
+	usage, err := parseDiskUsage(bytes.NewReader(metrics), mountpoint)
+	if err != nil {
+		return domain.HostHealth{}, err
+	}
+	c.logger.Debug("Queried host health",
+		zap.String("address", address),
+		zap.Float64("load1", load.Load1),
+		zap.Int("cpus", load.CPUs),
+		zap.Float64("disk_usage", usage),
+	)
+	return domain.HostHealth{Load1: load.Load1, CPUs: load.CPUs, DiskUsage: usage}, nil
+}
+
+// scrape returns the metrics of the node exporter at address
+func (c *Client) scrape(ctx context.Context, address string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/metrics", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape node exporter: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("node exporter returned status %d", resp.StatusCode)
+	}
+
+	metrics, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w: %w", domain.ErrUnavailable, err)
+	}
+	return metrics, nil
+}
+
+// parseMetrics reads node_load1 and counts the CPUs from the idle series of node_cpu_seconds_total
+func parseMetrics(r io.Reader) (domain.HostLoad, error) {
+	var load domain.HostLoad
+	found := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "node_load1 "):
+			value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimPrefix(line, "node_load1 ")), 64)
+			if err != nil {
+				return domain.HostLoad{}, fmt.Errorf("failed to parse node_load1: %w", err)
+			}
+			load.Load1 = value
+			found = true
+		case strings.HasPrefix(line, "node_cpu_seconds_total{") && strings.Contains(line, `mode="idle"`):
+			load.CPUs++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return domain.HostLoad{}, fmt.Errorf("failed to read metrics: %w", err)
+	}
+	if !found {
+		return domain.HostLoad{}, fmt.Errorf("node_load1 is missing from the metrics")
+	}
+	return load, nil
+}
+
+// parseDiskUsage reads node_filesystem_size_bytes and node_filesystem_avail_bytes of the
+// filesystem mounted at mountpoint into the fraction of the filesystem in use
+func parseDiskUsage(r io.Reader, mountpoint string) (float64, error) {
+	label := fmt.Sprintf("mountpoint=%q", mountpoint)
+	size, avail := -1.0, -1.0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var target *float64
+		switch {
+		case strings.HasPrefix(line, "node_filesystem_size_bytes{"):
+			target = &size
+		case strings.HasPrefix(line, "node_filesystem_avail_bytes{"):
+			target = &avail
+		default:
+			continue
+		}
+		if !strings.Contains(line, label) {
+			continue
+		}
+		fields := strings.Fields(line)
+		value, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse filesystem metric: %w", err)
+		}
+		*target = value
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read metrics: %w", err)
+	}
+	if size < 0 || avail < 0 {
+		return 0, fmt.Errorf("no filesystem mounted at %s in the metrics", mountpoint)
+	}
+	if size == 0 {
+		return 0, nil
+	}
+	return 1 - avail/size, nil
+}
+
+// Ensure Client implements domain.LoadProbe and domain.HostHealthProbe
+var _ domain.LoadProbe = (*Client)(nil)
+var _ domain.HostHealthProbe = (*Client)(nil)
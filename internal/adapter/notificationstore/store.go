@@ -0,0 +1,162 @@
+package notificationstore
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxFailureAge drops failures nobody retried for this long, so the file doesn't grow forever
+const maxFailureAge = 30 * 24 * time.Hour
+
+// Store keeps the failed notifications in a JSON file keyed by failure ID, each encrypted
+// with the keys of the namespace of its deployment
+type Store struct {
+	path   string
+	cipher *encryption.RecordCipher
+	mu     sync.Mutex
+}
+
+// NewStore creates a new notification failure store backed by the given file
+func NewStore(path string, cipher *encryption.RecordCipher) *Store {
+	return &Store{path: path, cipher: cipher}
+}
+
+// RecordFailure stores a failed delivery. A failure of a notification already stored
+// replaces its error and counts as another attempt.
+func (s *Store) RecordFailure(ctx context.Context, failure domain.NotificationFailure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures, err := s.load()
+	if err != nil {
+		return err
+	}
+	for id, stored := range failures {
+		if time.Since(stored.LastFailedAt) > maxFailureAge {
+			delete(failures, id)
+		}
+	}
+
+	if stored, ok := failures[failure.ID]; ok {
+		failure.FirstFailedAt = stored.FirstFailedAt
+		failure.Attempts += stored.Attempts
+	}
+	failures[failure.ID] = failure
+	return s.save(failures)
+}
+
+// GetFailure returns a failed notification
+func (s *Store) GetFailure(ctx context.Context, id string) (domain.NotificationFailure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures, err := s.load()
+	if err != nil {
+		return domain.NotificationFailure{}, err
+	}
+	failure, ok := failures[id]
+	if !ok {
+		return domain.NotificationFailure{}, domain.ErrNotificationFailureNotFound
+	}
+	return failure, nil
+}
+
+// DeleteFailure removes a failed notification
+func (s *Store) DeleteFailure(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := failures[id]; !ok {
+		return nil
+	}
+	delete(failures, id)
+	return s.save(failures)
+}
+
+// ListFailures returns the failed notifications, most recent first
+func (s *Store) ListFailures(ctx context.Context) ([]domain.NotificationFailure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]domain.NotificationFailure, 0, len(failures))
+	for _, failure := range failures {
+		result = append(result, failure)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastFailedAt.After(result[j].LastFailedAt)
+	})
+	return result, nil
+}
+
+func (s *Store) load() (map[string]domain.NotificationFailure, error) {
+	failures := make(map[string]domain.NotificationFailure)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return failures, nil
+		}
+		return nil, fmt.Errorf("failed to read notification failure store: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return failures, nil
+	}
+
+	var records map[string]json.RawMessage
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode notification failure store: %w", err)
+	}
+	for id, record := range records {
+		var failure domain.NotificationFailure
+		if err := s.cipher.Open(record, &failure); err != nil {
+			return nil, fmt.Errorf("failed to decode notification failure %s: %w", id, err)
+		}
+		failures[id] = failure
+	}
+	return failures, nil
+}
+
+func (s *Store) save(failures map[string]domain.NotificationFailure) error {
+	records := make(map[string]json.RawMessage, len(failures))
+	for id, failure := range failures {
+		record, err := s.cipher.Seal(s.cipher.Namespace(failure.Request.Metadata.Environment), failure.Request.Metadata.ProjectName, failure)
+		if err != nil {
+			return fmt.Errorf("failed to encode notification failure %s: %w", id, err)
+		}
+		records[id] = record
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so a concurrent reader never sees a partial file
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write notification failure store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace notification failure store: %w", err)
+	}
+	return nil
+}
+
+// Ensure Store implements domain.NotificationFailureStore
+var _ domain.NotificationFailureStore = (*Store)(nil)
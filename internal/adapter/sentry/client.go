@@ -0,0 +1,138 @@
+package sentry
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Client reports crashes to Sentry through its store endpoint
+type Client struct {
+	storeURL    string
+	publicKey   string
+	environment string
+	release     string
+	serverName  string
+	httpClient  *http.Client
+	logger      *zap.Logger
+}
+
+// NewClient creates a new Sentry client from a DSN (https://<key>@<host>/<project_id>)
+func NewClient(dsn, environment, release string, logger *zap.Logger) (*Client, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	if parsed.User == nil || parsed.User.Username() == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing public key")
+	}
+	path := strings.Trim(parsed.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	projectID := path[slash+1:]
+	if projectID == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing project ID")
+	}
+	prefix := ""
+	if slash >= 0 {
+		prefix = "/" + path[:slash]
+	}
+
+	hostname, _ := os.Hostname()
+	return &Client{
+		storeURL:    fmt.Sprintf("%s://%s%s/api/%s/store/", parsed.Scheme, parsed.Host, prefix, projectID),
+		publicKey:   parsed.User.Username(),
+		environment: environment,
+		release:     release,
+		serverName:  hostname,
+		httpClient:  &http.Client{Timeout: 5 * time.Second},
+		logger:      logger,
+	}, nil
+}
+
+// event is the subset of the Sentry event payload sent by the service
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+	Exception   *exceptions       `json:"exception,omitempty"`
+}
+
+type exceptions struct {
+	Values []exception `json:"values"`
+}
+
+type exception struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// ReportCrash sends a recovered panic as a fatal event
+func (c *Client) ReportCrash(ctx context.Context, crash domain.Crash) error {
+	eventID := make([]byte, 16)
+	if _, err := rand.Read(eventID); err != nil {
+		return fmt.Errorf("failed to generate event ID: %w", err)
+	}
+
+	payload := event{
+		EventID:     hex.EncodeToString(eventID),
+		Timestamp:   crash.Time.UTC().Format(time.RFC3339),
+		Level:       "fatal",
+		Platform:    "go",
+		Logger:      "panic",
+		ServerName:  c.serverName,
+		Environment: c.environment,
+		Release:     c.release,
+		Message:     crash.Message,
+		Tags:        crash.Tags,
+		Extra:       map[string]string{"stack_trace": crash.Stack},
+		Exception: &exceptions{Values: []exception{
+			{Type: "panic", Value: crash.Message},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=deployment-service/1.0, sentry_key=%s", c.publicKey))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Sentry returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
+	}
+
+	c.logger.Debug("Reported crash to Sentry", zap.String("event_id", payload.EventID))
+	return nil
+}
+
+// Ensure Client implements domain.CrashReporter
+var _ domain.CrashReporter = (*Client)(nil)
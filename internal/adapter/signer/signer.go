@@ -0,0 +1,51 @@
+package signer
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// Signer implements domain.ReceiptSigner with an Ed25519 private key
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner creates a signer with the Ed25519 private key in the PEM file at path, as
+// written by "openssl genpkey -algorithm ed25519"
+func NewSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("signing key %s is not a PEM encoded PKCS #8 private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	ed25519Key, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is a %T, not an Ed25519 key", path, key)
+	}
+	return &Signer{key: ed25519Key}, nil
+}
+
+// Sign returns the Ed25519 signature of payload
+func (s *Signer) Sign(payload []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, payload), nil
+}
+
+// PublicKey returns the Ed25519 public key verifying the signatures
+func (s *Signer) PublicKey() []byte {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Ensure Signer implements domain.ReceiptSigner
+var _ domain.ReceiptSigner = (*Signer)(nil)
@@ -4,36 +4,63 @@ import (
 	"NYCU-SDC/deployment-service/internal/config"
 	"NYCU-SDC/deployment-service/internal/domain"
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/pkg/sftp"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
 )
 
-// Client implements domain.SSHExecutor interface
+// Client implements domain.SSHExecutor and domain.HostKeyManager interfaces
 type Client struct {
 	sshConfig config.SSHConfig
+	hostKeys  *HostKeyStore
 	logger    *zap.Logger
 }
 
 // NewClient creates a new SSH client
 func NewClient(sshConfig config.SSHConfig, logger *zap.Logger) *Client {
-	return &Client{
+	c := &Client{
 		sshConfig: sshConfig,
 		logger:    logger,
 	}
+
+	storeFile := sshConfig.HostKeyStoreFile
+	if storeFile == "" {
+		// Default to a file next to known_hosts
+		if knownHostsFile, err := c.knownHostsFile(); err == nil {
+			storeFile = knownHostsFile + ".pinned.json"
+		}
+	}
+	if storeFile != "" {
+		c.hostKeys = NewHostKeyStore(storeFile, logger)
+	}
+
+	return c
+}
+
+// PinHostKey pins a new host key for the given host. The old key keeps being
+// accepted until the grace period ends.
+func (c *Client) PinHostKey(ctx context.Context, host, publicKey string, grace time.Duration) error {
+	if c.hostKeys == nil {
+		return fmt.Errorf("host key store is not configured")
+	}
+	return c.hostKeys.Pin(host, publicKey, grace)
 }
 
-// Execute executes a command on a remote host via SSH
+// Execute uploads command as a script to a remote host via SSH and runs it with bash -e
 func (c *Client) Execute(ctx context.Context, host string, user string, privateKey []byte, command string, envVars map[string]string) (string, error) {
 	// Parse private key
 	signer, err := ssh.ParsePrivateKey(privateKey)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse private key: %w", err)
+		return "", fmt.Errorf("failed to parse private key: %w: %w", domain.ErrInvalidRequest, err)
 	}
 
 	// Create host key callback
@@ -53,10 +80,17 @@ func (c *Client) Execute(ctx context.Context, host string, user string, privateK
 	// Connect to SSH server
 	conn, err := ssh.Dial("tcp", host, sshConfig)
 	if err != nil {
-		return "", fmt.Errorf("failed to dial SSH server: %w", err)
+		return "", fmt.Errorf("failed to dial SSH server: %w: %w", dialErrorCategory(err), err)
 	}
 	defer conn.Close()
 
+	// Upload the script over SFTP, so it reaches the host without another layer of quoting
+	sftpClient, err := sftp.NewClient(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+	defer sftpClient.Close()
+
 	// Create session
 	session, err := conn.NewSession()
 	if err != nil {
@@ -65,32 +99,48 @@ func (c *Client) Execute(ctx context.Context, host string, user string, privateK
 	defer session.Close()
 
 	// Set environment variables
+	var refused []string
 	for key, value := range envVars {
 		if err := session.Setenv(key, value); err != nil {
-			// Some SSH servers don't support Setenv, so we'll inject them in the command
-			c.logger.Warn("Failed to set environment variable via Setenv, will inject in command",
+			// Servers only accept the variables of their AcceptEnv, so export the others in the script
+			c.logger.Warn("Failed to set environment variable via Setenv, will export it in the script",
 				zap.String("key", key),
 				zap.Error(err),
 			)
+			refused = append(refused, key)
 		}
 	}
-	// Log the command being executed (without sensitive data)
+	exports, err := exportVariables(envVars, refused)
+	if err != nil {
+		return "", err
+	}
+
+	scriptPath, err := newScriptPath()
+	if err != nil {
+		return "", err
+	}
+	// The script removes itself once bash has opened it; this covers failed uploads and
+	// runs that fail or are cancelled before bash starts
+	defer c.removeScript(sftpClient, scriptPath)
+	if err := c.uploadScript(ctx, sftpClient, scriptPath, exports+command); err != nil {
+		return "", err
+	}
+	// The script holds the secrets, so neither it nor its output is logged here; the caller
+	// logs the output with the secrets redacted
 	c.logger.Info("Executing SSH command",
 		zap.String("host", host),
 		zap.String("user", user),
-		zap.String("command_preview", c.sanitizeCommand(command)),
+		zap.String("script_path", scriptPath),
 	)
 
 	// Execute command with context
-	output, err := c.executeWithContext(ctx, session, command)
+	output, err := c.executeWithContext(ctx, session, scriptPath)
 	if err != nil {
-		// Log full output for debugging
 		c.logger.Error("SSH command execution failed",
 			zap.String("host", host),
 			zap.String("user", user),
 			zap.Error(err),
-			zap.String("output", output),
-			zap.String("command_preview", c.sanitizeCommand(command)),
+			zap.String("output_length", fmt.Sprintf("%d", len(output))),
 		)
 		return output, fmt.Errorf("failed to execute command (exit code may indicate specific error): %w", err)
 	}
@@ -104,7 +154,8 @@ func (c *Client) Execute(ctx context.Context, host string, user string, privateK
 	return output, nil
 }
 
-func (c *Client) executeWithContext(ctx context.Context, session *ssh.Session, command string) (string, error) {
+// executeWithContext runs the uploaded script at scriptPath with bash -e
+func (c *Client) executeWithContext(ctx context.Context, session *ssh.Session, scriptPath string) (string, error) {
 	// Set up environment variables to ensure commands can be found
 	// Set PATH to include common binary locations
 	pathEnv := "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
@@ -113,10 +164,24 @@ func (c *Client) executeWithContext(ctx context.Context, session *ssh.Session, c
 		c.logger.Debug("Failed to set PATH via Setenv, will include in command", zap.Error(err))
 	}
 
-	// Build command with explicit PATH and shell
-	// Use sh -c instead of bash -c for better compatibility
-	shellCommand := fmt.Sprintf("export PATH=%s && %s", pathEnv, command)
+	// Build command with explicit PATH, so commands like rm, git, cd are available;
+	// bash -e stops the script at the first failing step
+	execCommand := fmt.Sprintf("export PATH=%s && bash -e %s", pathEnv, c.quoteCommand(scriptPath))
 
+	return waitWithContext(ctx, func() ([]byte, error) {
+		return session.CombinedOutput(execCommand)
+	}, func() {
+		// Stop the script where the server supports signals; closing the session hangs up on it
+		if err := session.Signal(ssh.SIGTERM); err != nil {
+			c.logger.Debug("Failed to signal cancelled SSH command", zap.Error(err))
+		}
+		session.Close()
+	})
+}
+
+// waitWithContext waits for run to finish. When ctx is done first, it calls abort, if not nil,
+// to stop run, and returns without waiting for it.
+func waitWithContext(ctx context.Context, run func() ([]byte, error), abort func()) (string, error) {
 	// Create a channel to receive output
 	type result struct {
 		output string
@@ -125,10 +190,7 @@ func (c *Client) executeWithContext(ctx context.Context, session *ssh.Session, c
 	resultChan := make(chan result, 1)
 
 	go func() {
-		// Use sh -c to execute the command in a proper shell environment
-		// This ensures commands like rm, git, cd are available
-		execCommand := fmt.Sprintf("sh -c %s", c.quoteCommand(shellCommand))
-		output, err := session.CombinedOutput(execCommand)
+		output, err := run()
 		resultChan <- result{
 			output: string(output),
 			err:    err,
@@ -137,13 +199,16 @@ func (c *Client) executeWithContext(ctx context.Context, session *ssh.Session, c
 
 	select {
 	case <-ctx.Done():
+		if abort != nil {
+			abort()
+		}
 		return "", ctx.Err()
 	case res := <-resultChan:
 		return res.output, res.err
 	}
 }
 
-// quoteCommand properly quotes a command for sh -c
+// quoteCommand properly quotes an argument for the remote shell
 func (c *Client) quoteCommand(command string) string {
 	// Escape single quotes by replacing ' with '\'' and wrapping in single quotes
 	escaped := strings.ReplaceAll(command, "'", "'\\''")
@@ -158,14 +223,9 @@ func (c *Client) createHostKeyCallback() (ssh.HostKeyCallback, error) {
 	}
 
 	// Use known_hosts file for host key verification
-	knownHostsFile := c.sshConfig.KnownHostsFile
-	if knownHostsFile == "" {
-		// Default to standard known_hosts location
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get user home directory: %w", err)
-		}
-		knownHostsFile = fmt.Sprintf("%s/.ssh/known_hosts", homeDir)
+	knownHostsFile, err := c.knownHostsFile()
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if known_hosts file exists
@@ -184,18 +244,70 @@ func (c *Client) createHostKeyCallback() (ssh.HostKeyCallback, error) {
 		return nil, fmt.Errorf("failed to load known_hosts file: %w", err)
 	}
 
+	// Keys pinned through the admin API take precedence over known_hosts
+	if c.hostKeys != nil {
+		return c.hostKeys.Callback(callback), nil
+	}
+
 	return callback, nil
 }
 
-// sanitizeCommand removes sensitive information from command for logging
-func (c *Client) sanitizeCommand(cmd string) string {
-	// Truncate long commands and mask potential secrets
-	sanitized := cmd
-	if len(sanitized) > 500 {
-		sanitized = sanitized[:500] + "... (truncated)"
+// dialErrorCategory tells rejected credentials or host keys apart from an unreachable host
+func dialErrorCategory(err error) error {
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) || errors.Is(err, domain.ErrUnauthorized) || strings.Contains(err.Error(), "unable to authenticate") {
+		return domain.ErrUnauthorized
+	}
+	return domain.ErrUnavailable
+}
+
+// knownHostsFile returns the configured known_hosts file or the default location
+func (c *Client) knownHostsFile() (string, error) {
+	if c.sshConfig.KnownHostsFile != "" {
+		return c.sshConfig.KnownHostsFile, nil
+	}
+
+	// Default to standard known_hosts location
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return fmt.Sprintf("%s/.ssh/known_hosts", homeDir), nil
+}
+
+// CheckHealth verifies that the configured deploy host accepts the host key and the private key
+func (c *Client) CheckHealth(ctx context.Context) error {
+	signer, err := ssh.ParsePrivateKey([]byte(c.sshConfig.PrivateKey))
+	if err != nil {
+		return fmt.Errorf("failed to parse private key: %w: %w", domain.ErrInvalidRequest, err)
+	}
+
+	hostKeyCallback, err := c.createHostKeyCallback()
+	if err != nil {
+		return fmt.Errorf("failed to create host key callback: %w", err)
+	}
+
+	timeout := 10 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	host := net.JoinHostPort(c.sshConfig.Host, strconv.Itoa(c.sshConfig.Port))
+	conn, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            c.sshConfig.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dial SSH server: %w: %w", dialErrorCategory(err), err)
 	}
-	return sanitized
+	return conn.Close()
 }
 
-// Ensure Client implements domain.SSHExecutor
+// Ensure Client implements domain.SSHExecutor, domain.HostKeyManager, domain.HealthChecker, domain.LoadProbe, and domain.HostHealthProbe
 var _ domain.SSHExecutor = (*Client)(nil)
+var _ domain.HostKeyManager = (*Client)(nil)
+var _ domain.HealthChecker = (*Client)(nil)
+var _ domain.LoadProbe = (*Client)(nil)
+var _ domain.HostHealthProbe = (*Client)(nil)
@@ -0,0 +1,121 @@
+package ssh
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// FuzzQuoteCommand checks that a quoted argument reaches the remote shell as a single, unchanged word
+func FuzzQuoteCommand(f *testing.F) {
+	for _, value := range []string{
+		"",
+		"/tmp/cd-script.AbC123",
+		"it's",
+		"'",
+		`'\''`,
+		`'"'"'`,
+		"$(id)",
+		"`id`",
+		"a\nb",
+		"x'; echo injected; echo 'y",
+	} {
+		f.Add(value)
+	}
+	c := NewClient(config.SSHConfig{}, zap.NewNop())
+
+	f.Fuzz(func(t *testing.T, value string) {
+		if strings.ContainsRune(value, 0) {
+			t.Skip("arguments can't contain NUL")
+		}
+		sh, err := exec.LookPath("sh")
+		if err != nil {
+			t.Skip("sh is not installed")
+		}
+
+		output, err := exec.Command(sh, "-c", "printf %s "+c.quoteCommand(value)).Output()
+		if err != nil {
+			t.Fatalf("quoted value %q failed to run: %v", value, err)
+		}
+		if string(output) != value {
+			t.Fatalf("quoted value changed: got %q, want %q", output, value)
+		}
+	})
+}
+
+func TestWaitWithContextAbortsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	aborted := false
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := waitWithContext(ctx, func() ([]byte, error) {
+		<-stopped
+		return nil, errors.New("session closed")
+	}, func() {
+		aborted = true
+		close(stopped)
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if !aborted {
+		t.Error("expected abort to be called")
+	}
+}
+
+func TestWaitWithContextReturnsOutput(t *testing.T) {
+	output, err := waitWithContext(context.Background(), func() ([]byte, error) {
+		return []byte("done"), nil
+	}, func() {
+		t.Error("abort called although run finished")
+	})
+	if err != nil || output != "done" {
+		t.Fatalf("got %q, %v; want done", output, err)
+	}
+}
+
+func TestExportVariables(t *testing.T) {
+	envVars := map[string]string{
+		"DB_PASSWORD": "it's $(id) `id`\nsecond line",
+		"TOKEN":       "abc",
+		"SET_BY_SSH":  "not exported",
+	}
+	exports, err := exportVariables(envVars, []string{"TOKEN", "DB_PASSWORD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(exports, "export DB_PASSWORD=") || strings.Contains(exports, "SET_BY_SSH") {
+		t.Errorf("unexpected exports %q", exports)
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+	output, err := exec.Command(sh, "-c", exports+`printf '%s|%s' "$DB_PASSWORD" "$TOKEN"`).Output()
+	if err != nil {
+		t.Fatalf("exports failed to run: %v", err)
+	}
+	if want := envVars["DB_PASSWORD"] + "|abc"; string(output) != want {
+		t.Errorf("exported values changed: got %q, want %q", output, want)
+	}
+}
+
+func TestExportVariablesRefusesNamesThatArentIdentifiers(t *testing.T) {
+	for _, key := range []string{"", "1ABC", "A-B", "A=B", "A;id"} {
+		if _, err := exportVariables(map[string]string{key: "x"}, []string{key}); err == nil {
+			t.Errorf("name %q exported", key)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package ssh
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// pinnedHost is the pinned host key of a single host
+type pinnedHost struct {
+	// Current is the pinned key in authorized_keys format
+	Current string `json:"current"`
+	// Previous is the key replaced by Current, accepted until GraceUntil
+	Previous string `json:"previous,omitempty"`
+	// GraceUntil is the end of the dual-acceptance period. While it lasts, keys accepted
+	// by known_hosts are also accepted when there is no previous pinned key.
+	GraceUntil time.Time `json:"grace_until,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// HostKeyStore keeps pinned host keys in a JSON file that overrides known_hosts.
+// The file is re-read on every check so keys pinned by another process take effect immediately.
+type HostKeyStore struct {
+	path   string
+	mu     sync.Mutex
+	logger *zap.Logger
+}
+
+// NewHostKeyStore creates a new host key store backed by the given file
+func NewHostKeyStore(path string, logger *zap.Logger) *HostKeyStore {
+	return &HostKeyStore{
+		path:   path,
+		logger: logger,
+	}
+}
+
+// Pin pins publicKey for host. The previously pinned key (or, if there is none,
+// the key in known_hosts) keeps being accepted for the grace period.
+func (s *HostKeyStore) Pin(host string, publicKey string, grace time.Duration) error {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	normalized := string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(key)))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hosts, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	address := knownhosts.Normalize(host)
+	entry := hosts[address]
+	if entry.Current != normalized {
+		entry.Previous = entry.Current
+		entry.Current = normalized
+	}
+	entry.GraceUntil = now.Add(grace)
+	entry.UpdatedAt = now
+	hosts[address] = entry
+
+	if err := s.save(hosts); err != nil {
+		return err
+	}
+
+	s.logger.Info("Pinned SSH host key",
+		zap.String("host", address),
+		zap.String("key_type", key.Type()),
+		zap.String("fingerprint", ssh.FingerprintSHA256(key)),
+		zap.Time("grace_until", entry.GraceUntil),
+	)
+	return nil
+}
+
+// Callback wraps a known_hosts callback so pinned keys take precedence
+func (s *HostKeyStore) Callback(knownHostsCallback ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		s.mu.Lock()
+		hosts, err := s.load()
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		entry, ok := hosts[knownhosts.Normalize(hostname)]
+		if !ok {
+			return knownHostsCallback(hostname, remote, key)
+		}
+
+		presented := string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(key)))
+		if presented == entry.Current {
+			return nil
+		}
+
+		if time.Now().Before(entry.GraceUntil) {
+			if entry.Previous != "" && presented == entry.Previous {
+				return nil
+			}
+			if entry.Previous == "" && knownHostsCallback(hostname, remote, key) == nil {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("ssh: host key for %s (%s) does not match the pinned key: %w", hostname, ssh.FingerprintSHA256(key), domain.ErrUnauthorized)
+	}
+}
+
+func (s *HostKeyStore) load() (map[string]pinnedHost, error) {
+	hosts := make(map[string]pinnedHost)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hosts, nil
+		}
+		return nil, fmt.Errorf("failed to read host key store: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return hosts, nil
+	}
+
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to decode host key store: %w", err)
+	}
+	return hosts, nil
+}
+
+func (s *HostKeyStore) save(hosts map[string]pinnedHost) error {
+	data, err := json.MarshalIndent(hosts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a new file next to the store and rename it over the store, so a concurrent
+	// reader or a crash never leaves a partial file; only the worker's user can read it
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create host key store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to restrict host key store: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write host key store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync host key store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write host key store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace host key store: %w", err)
+	}
+	return nil
+}
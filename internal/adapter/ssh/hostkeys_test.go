@@ -0,0 +1,66 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newHostKey returns a new host key in authorized_keys format
+func newHostKey(t *testing.T) string {
+	t.Helper()
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func TestHostKeyStorePinWritesPrivateFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "known_hosts.pinned.json")
+	store := NewHostKeyStore(path, zap.NewNop())
+
+	first, second := newHostKey(t), newHostKey(t)
+	if err := store.Pin("deploy-1:22", first, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Pin("deploy-1:22", second, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("store mode %v, want 0600", mode)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files left next to the store: %v", entries)
+	}
+
+	hosts, err := store.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host := hosts[knownhosts.Normalize("deploy-1:22")]; host.Current != second || host.Previous != first {
+		t.Errorf("pinned %+v, want current %q and previous %q", host, second, first)
+	}
+}
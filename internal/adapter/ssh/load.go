@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// loadCommand prints the number of CPUs and the load averages of the host
+const loadCommand = "nproc && cat /proc/loadavg"
+
+// QueryLoad reads the load average and CPU count of the host at address over SSH
+func (c *Client) QueryLoad(ctx context.Context, address string) (domain.HostLoad, error) {
+	output, err := c.Execute(ctx, address, c.sshConfig.User, []byte(c.sshConfig.PrivateKey), loadCommand, nil)
+	if err != nil {
+		return domain.HostLoad{}, err
+	}
+	return parseLoad(output)
+}
+
+// parseLoad parses the output of loadCommand, e.g. "4\n0.52 0.58 0.59 1/467 12345\n"
+func parseLoad(output string) (domain.HostLoad, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return domain.HostLoad{}, fmt.Errorf("unexpected load output %q", output)
+	}
+
+	cpus, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return domain.HostLoad{}, fmt.Errorf("failed to parse CPU count: %w", err)
+	}
+	fields := strings.Fields(lines[1])
+	if len(fields) == 0 {
+		return domain.HostLoad{}, fmt.Errorf("unexpected load average %q", lines[1])
+	}
+	load1, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return domain.HostLoad{}, fmt.Errorf("failed to parse load average: %w", err)
+	}
+
+	return domain.HostLoad{Load1: load1, CPUs: cpus}, nil
+}
+
+// QueryHealth reads the load of the host at address and the usage of the filesystem mounted
+// at mountpoint over SSH, with df
+func (c *Client) QueryHealth(ctx context.Context, address, mountpoint string) (domain.HostHealth, error) {
+	output, err := c.Execute(ctx, address, c.sshConfig.User, []byte(c.sshConfig.PrivateKey), loadCommand+" && df -P -- "+c.quoteCommand(mountpoint), nil)
+	if err != nil {
+		return domain.HostHealth{}, err
+	}
+
+	lines := strings.SplitN(strings.TrimSpace(output), "\n", 3)
+	if len(lines) < 3 {
+		return domain.HostHealth{}, fmt.Errorf("unexpected health output %q", output)
+	}
+	load, err := parseLoad(lines[0] + "\n" + lines[1])
+	if err != nil {
+		return domain.HostHealth{}, err
+	}
+	usage, err := parseDiskUsage(lines[2])
+	if err != nil {
+		return domain.HostHealth{}, err
+	}
+	return domain.HostHealth{Load1: load.Load1, CPUs: load.CPUs, DiskUsage: usage}, nil
+}
+
+// parseDiskUsage parses the output of df -P, e.g.
+// "Filesystem 1024-blocks Used Available Capacity Mounted on\n/dev/sda1 1000 970 30 98% /\n",
+// into the fraction of the filesystem in use, counting like df does
+func parseDiskUsage(output string) (float64, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(fields) < 4 {
+		return 0, fmt.Errorf("unexpected df output %q", output)
+	}
+
+	used, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse used blocks: %w", err)
+	}
+	available, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse available blocks: %w", err)
+	}
+	if used+available == 0 {
+		return 0, nil
+	}
+	return used / (used + available), nil
+}
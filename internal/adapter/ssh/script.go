@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"go.uber.org/zap"
+)
+
+// scriptHeader removes the uploaded script as soon as bash has opened it, so the secrets
+// it contains don't stay on the host
+const scriptHeader = "rm -f -- \"$0\"\n"
+
+// shellIdentifier matches the names a script can export
+var shellIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// scriptDir is the directory of the host the scripts are uploaded to
+const scriptDir = "/tmp"
+
+// newScriptPath returns a new, unpredictable path for a script on the host
+func newScriptPath() (string, error) {
+	suffix := make([]byte, 12)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate script name: %w", err)
+	}
+	return fmt.Sprintf("%s/cd-script.%s", scriptDir, hex.EncodeToString(suffix)), nil
+}
+
+// exportVariables returns the lines of a script exporting the variables of envVars named in keys,
+// sorted by name. The values are single-quoted, so they reach the script unchanged; names that
+// aren't shell identifiers are refused, since the script can't export them.
+func exportVariables(envVars map[string]string, keys []string) (string, error) {
+	keys = slices.Sorted(slices.Values(keys))
+	var exports strings.Builder
+	for _, key := range keys {
+		if !shellIdentifier.MatchString(key) {
+			return "", fmt.Errorf("failed to export environment variable %q: not a shell identifier", key)
+		}
+		value := strings.ReplaceAll(envVars[key], "'", "'\\''")
+		fmt.Fprintf(&exports, "export %s='%s'\n", key, value)
+	}
+	return exports.String(), nil
+}
+
+// uploadScript writes script over SFTP to a new file at scriptPath, readable only by the user.
+// The caller removes the file with removeScript, also when the upload fails.
+func (c *Client) uploadScript(ctx context.Context, client *sftp.Client, scriptPath, script string) error {
+	_, err := waitWithContext(ctx, func() ([]byte, error) {
+		// O_EXCL fails on an existing file or symlink planted at the path
+		file, err := client.OpenFile(scriptPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+		if err != nil {
+			return nil, err
+		}
+		// Restrict the file before the secrets are written to it
+		if err := file.Chmod(0o600); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if _, err := file.Write([]byte(scriptHeader + script + "\n")); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return nil, file.Close()
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to upload script: %w", err)
+	}
+	return nil
+}
+
+// removeScript removes the script at scriptPath, unless it already removed itself
+func (c *Client) removeScript(client *sftp.Client, scriptPath string) {
+	if err := client.Remove(scriptPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Warn("Failed to remove uploaded script",
+			zap.String("script_path", scriptPath),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,188 @@
+package teams
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxMessageLength keeps a card well under the 28 KB Teams accepts per message
+const maxMessageLength = 20000
+
+// Client implements domain.Notifier by posting Adaptive Cards to a Teams webhook
+type Client struct {
+	teamsConfig config.TeamsConfig
+	httpClient  *http.Client
+	logger      *zap.Logger
+}
+
+// NewClient creates a new Teams client
+func NewClient(teamsConfig config.TeamsConfig, logger *zap.Logger) *Client {
+	return &Client{
+		teamsConfig: teamsConfig,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		logger:      logger,
+	}
+}
+
+// Message is the payload of a Teams webhook carrying a card
+type Message struct {
+	Type        string       `json:"type"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment wraps the Adaptive Card of a message
+type Attachment struct {
+	ContentType string       `json:"contentType"`
+	Content     AdaptiveCard `json:"content"`
+}
+
+// AdaptiveCard represents the subset of the Adaptive Card schema used by the service
+type AdaptiveCard struct {
+	Schema  string         `json:"$schema"`
+	Type    string         `json:"type"`
+	Version string         `json:"version"`
+	Body    []CardElement  `json:"body"`
+	MSTeams *MSTeamsConfig `json:"msteams,omitempty"`
+}
+
+// CardElement is a TextBlock or FactSet of a card
+type CardElement struct {
+	Type   string `json:"type"`
+	Text   string `json:"text,omitempty"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Color  string `json:"color,omitempty"`
+	Wrap   bool   `json:"wrap,omitempty"`
+	Facts  []Fact `json:"facts,omitempty"`
+}
+
+// Fact is a name/value pair of a FactSet
+type Fact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// MSTeamsConfig holds the Teams extensions of a card
+type MSTeamsConfig struct {
+	Width    string    `json:"width,omitempty"`
+	Entities []Mention `json:"entities,omitempty"`
+}
+
+// Mention pings a user mentioned as <at>text</at> in the card
+type Mention struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text"`
+	Mentioned MentionedAccount `json:"mentioned"`
+}
+
+// MentionedAccount identifies a mentioned user by UPN or Entra object ID
+type MentionedAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SendNotification posts a notification as an Adaptive Card
+func (c *Client) SendNotification(ctx context.Context, title, message string, success bool, metadata map[string]string, mentions []string) error {
+	if c.teamsConfig.WebhookURL == "" {
+		return fmt.Errorf("teams webhook URL is not configured: %w", domain.ErrInvalidRequest)
+	}
+
+	payload := Message{
+		Type: "message",
+		Attachments: []Attachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     buildCard(title, message, success, metadata, mentions),
+		}},
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.teamsConfig.WebhookURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("invalid Teams webhook URL: %w: %w", domain.ErrInvalidRequest, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w: %w", domain.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	// Incoming webhooks answer 200, Workflows webhooks 202
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Teams webhook returned status %d: %w", resp.StatusCode, domain.ErrorForStatus(resp.StatusCode))
+	}
+
+	c.logger.Info("Teams notification sent",
+		zap.String("title", title),
+		zap.Bool("success", success),
+	)
+	return nil
+}
+
+// buildCard renders a notification as an Adaptive Card: the title in the color of the
+// status, the message, the mentions, and the metadata as facts
+func buildCard(title, message string, success bool, metadata map[string]string, mentions []string) AdaptiveCard {
+	color := "Good"
+	if !success {
+		color = "Attention"
+	}
+	if len(message) > maxMessageLength {
+		message = strings.ToValidUTF8(message[:maxMessageLength], "") + "\n… (truncated)"
+	}
+
+	body := []CardElement{
+		{Type: "TextBlock", Text: title, Weight: "Bolder", Size: "Medium", Color: color, Wrap: true},
+		{Type: "TextBlock", Text: message, Wrap: true},
+	}
+
+	card := AdaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		MSTeams: &MSTeamsConfig{Width: "Full"},
+	}
+
+	if len(mentions) > 0 {
+		texts := make([]string, len(mentions))
+		for i, userID := range mentions {
+			texts[i] = "<at>" + userID + "</at>"
+			card.MSTeams.Entities = append(card.MSTeams.Entities, Mention{
+				Type:      "mention",
+				Text:      texts[i],
+				Mentioned: MentionedAccount{ID: userID, Name: userID},
+			})
+		}
+		body = append(body, CardElement{Type: "TextBlock", Text: strings.Join(texts, " "), Wrap: true})
+	}
+
+	if len(metadata) > 0 {
+		facts := make([]Fact, 0, len(metadata))
+		for key, value := range metadata {
+			facts = append(facts, Fact{Title: key, Value: value})
+		}
+		sort.Slice(facts, func(i, j int) bool {
+			return facts[i].Title < facts[j].Title
+		})
+		body = append(body, CardElement{Type: "FactSet", Facts: facts})
+	}
+
+	card.Body = body
+	return card
+}
+
+// Ensure Client implements domain.Notifier
+var _ domain.Notifier = (*Client)(nil)
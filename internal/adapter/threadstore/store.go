@@ -0,0 +1,97 @@
+package threadstore
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxThreadAge drops threads not posted in for this long, so the file doesn't grow forever
+const maxThreadAge = 90 * 24 * time.Hour
+
+// Store keeps the notification threads of deployments in a JSON file keyed by thread key
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStore creates a new thread store backed by the given file
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// GetThread returns the thread of a deployment and whether it exists
+func (s *Store) GetThread(ctx context.Context, key string) (domain.NotificationThread, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	threads, err := s.load()
+	if err != nil {
+		return domain.NotificationThread{}, false, err
+	}
+	thread, ok := threads[key]
+	return thread, ok, nil
+}
+
+// PutThread creates or replaces the thread of a deployment and drops stale threads
+func (s *Store) PutThread(ctx context.Context, thread domain.NotificationThread) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	threads, err := s.load()
+	if err != nil {
+		return err
+	}
+	for key, stored := range threads {
+		if time.Since(stored.UpdatedAt) > maxThreadAge {
+			delete(threads, key)
+		}
+	}
+	threads[thread.Key] = thread
+	return s.save(threads)
+}
+
+func (s *Store) load() (map[string]domain.NotificationThread, error) {
+	threads := make(map[string]domain.NotificationThread)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return threads, nil
+		}
+		return nil, fmt.Errorf("failed to read thread store: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return threads, nil
+	}
+
+	if err := json.Unmarshal(data, &threads); err != nil {
+		return nil, fmt.Errorf("failed to decode thread store: %w", err)
+	}
+	return threads, nil
+}
+
+func (s *Store) save(threads map[string]domain.NotificationThread) error {
+	data, err := json.MarshalIndent(threads, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so a concurrent reader never sees a partial file
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write thread store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace thread store: %w", err)
+	}
+	return nil
+}
+
+// Ensure Store implements domain.ThreadStore
+var _ domain.ThreadStore = (*Store)(nil)
@@ -0,0 +1,144 @@
+package tombstone
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Store keeps tombstones of deleted deployments in a JSON file keyed by trace ID, each
+// encrypted with the keys of the namespace of its environment
+type Store struct {
+	path   string
+	cipher *encryption.RecordCipher
+	mu     sync.Mutex
+}
+
+// NewStore creates a new tombstone store backed by the given file
+func NewStore(path string, cipher *encryption.RecordCipher) *Store {
+	return &Store{path: path, cipher: cipher}
+}
+
+// GetTombstone returns the tombstone of a deployment and whether it exists
+func (s *Store) GetTombstone(ctx context.Context, traceID string) (domain.Tombstone, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tombstones, err := s.load()
+	if err != nil {
+		return domain.Tombstone{}, false, err
+	}
+	tombstone, ok := tombstones[traceID]
+	return tombstone, ok, nil
+}
+
+// PutTombstone creates or replaces the tombstone of a deployment
+func (s *Store) PutTombstone(ctx context.Context, tombstone domain.Tombstone) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tombstones, err := s.load()
+	if err != nil {
+		return err
+	}
+	tombstones[tombstone.TraceID] = tombstone
+	return s.save(tombstones)
+}
+
+// DeleteTombstone removes the tombstone of a deployment
+func (s *Store) DeleteTombstone(ctx context.Context, traceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tombstones, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := tombstones[traceID]; !ok {
+		return nil
+	}
+	delete(tombstones, traceID)
+	return s.save(tombstones)
+}
+
+// ListTombstones returns all tombstones, oldest deletion first
+func (s *Store) ListTombstones(ctx context.Context) ([]domain.Tombstone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tombstones, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]domain.Tombstone, 0, len(tombstones))
+	for _, tombstone := range tombstones {
+		list = append(list, tombstone)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].DeletedAt.Before(list[j].DeletedAt)
+	})
+	return list, nil
+}
+
+func (s *Store) load() (map[string]domain.Tombstone, error) {
+	tombstones := make(map[string]domain.Tombstone)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tombstones, nil
+		}
+		return nil, fmt.Errorf("failed to read tombstone store: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return tombstones, nil
+	}
+
+	var records map[string]json.RawMessage
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode tombstone store: %w", err)
+	}
+	for traceID, record := range records {
+		var tombstone domain.Tombstone
+		if err := s.cipher.Open(record, &tombstone); err != nil {
+			return nil, fmt.Errorf("failed to decode tombstone %s: %w", traceID, err)
+		}
+		tombstones[traceID] = tombstone
+	}
+	return tombstones, nil
+}
+
+func (s *Store) save(tombstones map[string]domain.Tombstone) error {
+	records := make(map[string]json.RawMessage, len(tombstones))
+	for traceID, tombstone := range tombstones {
+		record, err := s.cipher.Seal(s.cipher.Namespace(tombstone.Environment), tombstone.Project, tombstone)
+		if err != nil {
+			return fmt.Errorf("failed to encode tombstone %s: %w", traceID, err)
+		}
+		records[traceID] = record
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so a concurrent reader never sees a partial file
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write tombstone store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace tombstone store: %w", err)
+	}
+	return nil
+}
+
+// Ensure Store implements domain.TombstoneStore
+var _ domain.TombstoneStore = (*Store)(nil)
@@ -4,51 +4,237 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Server     ServerConfig      `yaml:"server"`
-	Temporal   TemporalConfig    `yaml:"temporal"`
-	Auth       AuthConfig        `yaml:"auth"`
-	Infisical  InfisicalConfig   `yaml:"infisical"`
-	Cloudflare CloudflareConfig  `yaml:"cloudflare"`
-	Discord    DiscordConfig     `yaml:"discord"`
-	IPMappings map[string]string `yaml:"ip_mappings"`
-	OTEL       OTELConfig        `yaml:"otel"`
-	Logger     LoggerConfig      `yaml:"logger"`
-	SSH        SSHConfig         `yaml:"ssh"`
+	Server       ServerConfig       `yaml:"server"`
+	Temporal     TemporalConfig     `yaml:"temporal"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Infisical    InfisicalConfig    `yaml:"infisical"`
+	Cloudflare   CloudflareConfig   `yaml:"cloudflare"`
+	Discord      DiscordConfig      `yaml:"discord"`
+	Teams        TeamsConfig        `yaml:"teams"`
+	Notification NotificationConfig `yaml:"notification"`
+	GitHub       GitHubConfig       `yaml:"github"`
+	IPMappings   map[string]string  `yaml:"ip_mappings"`
+	OTEL         OTELConfig         `yaml:"otel"`
+	Logger       LoggerConfig       `yaml:"logger"`
+	Sentry       SentryConfig       `yaml:"sentry"`
+	SSH          SSHConfig          `yaml:"ssh"`
+	Retry        RetryConfig        `yaml:"retry"`
+	Retention    RetentionConfig    `yaml:"retention"`
+	Worker       WorkerConfig       `yaml:"worker"`
+	Receipts     ReceiptsConfig     `yaml:"receipts"`
+	Environments EnvironmentsConfig `yaml:"environments"`
+	Deployments  DeploymentsConfig  `yaml:"deployments"`
+	Scheduler    SchedulerConfig    `yaml:"scheduler"`
+	Encryption   EncryptionConfig   `yaml:"encryption"`
+	Chaos        ChaosConfig        `yaml:"chaos"`
 }
 
 type ServerConfig struct {
 	Host string `yaml:"host" envconfig:"HOST"`
 	Port string `yaml:"port" envconfig:"PORT"`
+	// ShutdownDelay is how long the API keeps serving after SIGTERM with /api/healthz failing,
+	// so the load balancer stops sending requests before the listener closes
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" envconfig:"SERVER_SHUTDOWN_DELAY"`
+	// ShutdownTimeout bounds how long the API waits for requests and workflow starts in flight
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" envconfig:"SERVER_SHUTDOWN_TIMEOUT"`
 }
 
 type TemporalConfig struct {
 	Address   string `yaml:"address" envconfig:"TEMPORAL_ADDRESS"`
 	Namespace string `yaml:"namespace" envconfig:"TEMPORAL_NAMESPACE"`
+	// Namespaces maps environment names to the namespace their workflows run in; unlisted environments use Namespace
+	Namespaces map[string]string `yaml:"namespaces" envconfig:"TEMPORAL_NAMESPACES"`
 }
 
 type AuthConfig struct {
 	DeployToken string `yaml:"deploy_token" envconfig:"DEPLOY_TOKEN"`
+	// AdminToken authorizes the admin API; admin endpoints reject every request when empty
+	AdminToken string `yaml:"admin_token" envconfig:"ADMIN_TOKEN"`
+	// ViewerTokens can only read deployment status, e.g. for dashboards
+	ViewerTokens []string `yaml:"viewer_tokens" envconfig:"VIEWER_TOKENS"`
 }
 
 type InfisicalConfig struct {
 	BaseURL      string `yaml:"base_url" envconfig:"INFISICAL_BASE_URL"`
 	ServiceToken string `yaml:"service_token" envconfig:"INFISICAL_SERVICE_TOKEN"`
+	// ChecksumSalt keys the per-deployment secret checksums; checksums are disabled when empty
+	ChecksumSalt string `yaml:"checksum_salt" envconfig:"SECRET_CHECKSUM_SALT"`
 }
 
 type CloudflareConfig struct {
+	// APIURL is the base URL of the Cloudflare API v4, e.g. of a mock in tests
+	APIURL   string `yaml:"api_url" envconfig:"CLOUDFLARE_API_URL"`
 	APIToken string `yaml:"api_token" envconfig:"CLOUDFLARE_API_TOKEN"`
 	ZoneID   string `yaml:"zone_id" envconfig:"CLOUDFLARE_ZONE_ID"`
+	// AllowedDomains restricts the records deployments may set up or remove, e.g. "*.sdc.nycu.club"
+	// for any subdomain or "sdc.nycu.club" for the name itself; every name is allowed when empty
+	AllowedDomains []string     `yaml:"allowed_domains" envconfig:"CLOUDFLARE_ALLOWED_DOMAINS"`
+	Canary         CanaryConfig `yaml:"canary"`
+}
+
+// CanaryConfig sets up a per-deployment record next to the stable record of a deployment,
+// so testers can reach each deployment by its trace ID
+type CanaryConfig struct {
+	// Domain is the domain canary records are created under as <trace_id>.<domain>; disabled when empty
+	Domain string `yaml:"domain" envconfig:"CLOUDFLARE_CANARY_DOMAIN"`
+	// Environments limits canary records to these environments; empty means every environment
+	Environments []string `yaml:"environments" envconfig:"CLOUDFLARE_CANARY_ENVIRONMENTS"`
+}
+
+// CanaryName returns the canary record name of a deployment, or "" if the environment gets none
+func (c CanaryConfig) CanaryName(traceID, environment string) string {
+	if c.Domain == "" || (len(c.Environments) > 0 && !slices.Contains(c.Environments, environment)) {
+		return ""
+	}
+	return traceID + "." + c.Domain
 }
 
 type DiscordConfig struct {
-	WebhookURL string `yaml:"webhook_url" envconfig:"DISCORD_WEBHOOK_URL"`
+	WebhookURL string               `yaml:"webhook_url" envconfig:"DISCORD_WEBHOOK_URL"`
+	Ack        DiscordAckConfig     `yaml:"ack"`
+	Threads    DiscordThreadsConfig `yaml:"threads"`
+	Mentions   DiscordMentionConfig `yaml:"mentions"`
+}
+
+// DiscordAckConfig configures acknowledgement tracking of failure notifications
+type DiscordAckConfig struct {
+	Enable bool `yaml:"enable" envconfig:"DISCORD_ACK_ENABLE"`
+	// ReminderInterval is how long to wait for an acknowledgement before re-pinging
+	ReminderInterval time.Duration `yaml:"reminder_interval" envconfig:"DISCORD_ACK_REMINDER_INTERVAL"`
+	// MaxReminders stops re-pinging after this many reminders
+	MaxReminders int `yaml:"max_reminders" envconfig:"DISCORD_ACK_MAX_REMINDERS"`
+	// Mention is prepended to reminders, e.g. "@here" or "<@&role_id>"
+	Mention string `yaml:"mention" envconfig:"DISCORD_ACK_MENTION"`
+}
+
+// DiscordThreadsConfig configures posting the notifications of each deployment into its own thread.
+// Webhooks can only create threads in forum channels.
+type DiscordThreadsConfig struct {
+	Enable bool `yaml:"enable" envconfig:"DISCORD_THREADS_ENABLE"`
+	// StateFile keeps the thread of each deployment and pull request (worker)
+	StateFile string `yaml:"state_file" envconfig:"DISCORD_THREADS_STATE_FILE"`
+}
+
+// DiscordMentionConfig configures mentioning the commit author on failure notifications
+type DiscordMentionConfig struct {
+	// Users maps GitHub usernames and commit author emails to Discord user IDs
+	Users map[string]string `yaml:"users" envconfig:"DISCORD_MENTION_USERS"`
+	// LookupCommitAuthor looks up the author of the deployed commit on GitHub when the request doesn't name one
+	LookupCommitAuthor bool `yaml:"lookup_commit_author" envconfig:"DISCORD_MENTION_LOOKUP_COMMIT_AUTHOR"`
+}
+
+// TeamsConfig configures Microsoft Teams notifications, posted as Adaptive Cards
+type TeamsConfig struct {
+	// WebhookURL is the URL of a Teams incoming webhook or Workflows webhook
+	WebhookURL string `yaml:"webhook_url" envconfig:"TEAMS_WEBHOOK_URL"`
+	// MentionUsers maps GitHub usernames and commit author emails to Teams users (UPN or Entra object ID)
+	MentionUsers map[string]string `yaml:"mention_users" envconfig:"TEAMS_MENTION_USERS"`
+}
+
+// NotificationConfig configures which channels the notifications of a deployment are sent to
+type NotificationConfig struct {
+	// Routes are tried in order and the first matching route applies.
+	// Deployments no route matches are notified on Discord.
+	Routes []NotificationRoute `yaml:"routes"`
+	// Verbosity maps environments to the notifications their deployments send
+	Verbosity map[string]string `yaml:"verbosity" envconfig:"NOTIFICATION_VERBOSITY"`
+	// DefaultVerbosity applies to environments without a verbosity; empty means "all" with
+	// Discord threads and "results" otherwise
+	DefaultVerbosity string `yaml:"default_verbosity" envconfig:"NOTIFICATION_DEFAULT_VERBOSITY"`
+}
+
+// Notification verbosity levels, from the most to the least notifications
+const (
+	// NotificationVerbosityAll sends start, progress, result, and failure notifications
+	NotificationVerbosityAll = "all"
+	// NotificationVerbosityNormal sends start, result, and failure notifications
+	NotificationVerbosityNormal = "normal"
+	// NotificationVerbosityResults sends result and failure notifications
+	NotificationVerbosityResults = "results"
+	// NotificationVerbosityFailures sends failure notifications only
+	NotificationVerbosityFailures = "failures"
+)
+
+// VerbosityFor returns the notification verbosity of an environment
+func (c *Config) VerbosityFor(environment string) string {
+	if verbosity, ok := c.Notification.Verbosity[environment]; ok {
+		return verbosity
+	}
+	if c.Notification.DefaultVerbosity != "" {
+		return c.Notification.DefaultVerbosity
+	}
+	if c.Discord.Threads.Enable {
+		return NotificationVerbosityAll
+	}
+	return NotificationVerbosityResults
+}
+
+// NotificationRoute sends the notifications of matching deployments to its channels.
+// Empty match lists match every deployment.
+type NotificationRoute struct {
+	Environments []string `yaml:"environments"`
+	Projects     []string `yaml:"projects"`
+	Repos        []string `yaml:"repos"`
+	// Channels are "discord" and "teams"
+	Channels []string `yaml:"channels"`
+}
+
+type GitHubConfig struct {
+	APIURL        string        `yaml:"api_url" envconfig:"GITHUB_API_URL"`
+	Token         string        `yaml:"token" envconfig:"GITHUB_TOKEN"`
+	WebhookSecret string        `yaml:"webhook_secret" envconfig:"GITHUB_WEBHOOK_SECRET"`
+	Preview       PreviewConfig `yaml:"preview"`
+	// Environments are tried in order and the first rule matching a push or pull request
+	// picks its environment. Pushes no rule matches aren't deployed; pull requests no rule
+	// matches are deployed to Preview.Environment.
+	Environments []EnvironmentRule `yaml:"environments"`
+}
+
+// EnvironmentRule maps the pushes or pull requests of the repositories in Preview.Repositories
+// to a deployment environment. Branch and Tag are glob patterns, e.g. "release/*"; for pull
+// requests, Branch matches the base branch.
+type EnvironmentRule struct {
+	Branch      string `yaml:"branch"`
+	Tag         string `yaml:"tag"`
+	PullRequest bool   `yaml:"pull_request"`
+	Environment string `yaml:"environment"`
+}
+
+// PreviewConfig configures the preview environments created for pull requests
+type PreviewConfig struct {
+	Environment  string                       `yaml:"environment"`
+	Repositories map[string]PreviewRepository `yaml:"repositories"`
+}
+
+// PreviewRepository configures the preview environments of a single repository
+type PreviewRepository struct {
+	ProjectName   string `yaml:"project_name"`
+	Component     string `yaml:"component"`
+	NotifyDiscord bool   `yaml:"notify_discord"`
+	// WaitForCI holds the deployments of pushes and pull requests until their CI checks pass
+	WaitForCI CIGateConfig `yaml:"wait_for_ci"`
+	// Supersede stops the running deployment of a pull request or branch when a newer push is deployed
+	Supersede bool `yaml:"supersede"`
+}
+
+// CIGateConfig configures the CI checks a deployment waits for
+type CIGateConfig struct {
+	Enable bool `yaml:"enable"`
+	// RequiredChecks are the names of the checks that must pass; empty waits for every reported check
+	RequiredChecks []string `yaml:"required_checks"`
+	// Timeout bounds the wait for pending checks (default: 30m, at most 6h)
+	Timeout time.Duration `yaml:"timeout"`
 }
 
 type OTELConfig struct {
@@ -58,31 +244,525 @@ type OTELConfig struct {
 type LoggerConfig struct {
 	Level  string `yaml:"level" envconfig:"LOG_LEVEL"`
 	Format string `yaml:"format" envconfig:"LOG_FORMAT"`
+	// Outputs lists the sinks logs are written to: "stderr" (default), "stdout", "file", "syslog", and "loki"
+	Outputs []string        `yaml:"outputs" envconfig:"LOG_OUTPUTS"`
+	File    LogFileConfig   `yaml:"file"`
+	Syslog  LogSyslogConfig `yaml:"syslog"`
+	Loki    LogLokiConfig   `yaml:"loki"`
+}
+
+// Log sinks
+const (
+	LogOutputStderr = "stderr"
+	LogOutputStdout = "stdout"
+	LogOutputFile   = "file"
+	LogOutputSyslog = "syslog"
+	LogOutputLoki   = "loki"
+)
+
+// LogFileConfig configures the rotating log file
+type LogFileConfig struct {
+	Path string `yaml:"path" envconfig:"LOG_FILE_PATH"`
+	// MaxSizeMB is the size at which the file is rotated (default: 100); 0 never rotates
+	MaxSizeMB int `yaml:"max_size_mb" envconfig:"LOG_FILE_MAX_SIZE_MB"`
+	// MaxAge removes rotated files older than this (default: 7 days); 0 keeps them
+	MaxAge time.Duration `yaml:"max_age" envconfig:"LOG_FILE_MAX_AGE"`
+	// MaxBackups is the number of rotated files kept (default: 5); 0 keeps all
+	MaxBackups int `yaml:"max_backups" envconfig:"LOG_FILE_MAX_BACKUPS"`
+}
+
+// LogSyslogConfig configures the syslog sink
+type LogSyslogConfig struct {
+	// Network is "udp", "tcp", or empty for the local syslog daemon (/dev/log, also read by journald)
+	Network string `yaml:"network" envconfig:"LOG_SYSLOG_NETWORK"`
+	Address string `yaml:"address" envconfig:"LOG_SYSLOG_ADDRESS"`
+	// Tag defaults to the name of the binary
+	Tag string `yaml:"tag" envconfig:"LOG_SYSLOG_TAG"`
+}
+
+// LogLokiConfig configures the Loki sink
+type LogLokiConfig struct {
+	// URL is the push endpoint, e.g. http://loki:3100/loki/api/v1/push
+	URL string `yaml:"url" envconfig:"LOG_LOKI_URL"`
+	// TenantID is sent as X-Scope-OrgID to multi-tenant Loki installs
+	TenantID string `yaml:"tenant_id" envconfig:"LOG_LOKI_TENANT_ID"`
+	// Username and Password authenticate with HTTP basic auth, e.g. to Grafana Cloud
+	Username string `yaml:"username" envconfig:"LOG_LOKI_USERNAME"`
+	Password string `yaml:"password" envconfig:"LOG_LOKI_PASSWORD"`
+	// BatchWait is how long log lines are collected before they are pushed (default: 1s)
+	BatchWait time.Duration `yaml:"batch_wait" envconfig:"LOG_LOKI_BATCH_WAIT"`
+}
+
+// SentryConfig configures the reporting of recovered panics to Sentry
+type SentryConfig struct {
+	// DSN enables reporting when set
+	DSN         string `yaml:"dsn" envconfig:"SENTRY_DSN"`
+	Environment string `yaml:"environment" envconfig:"SENTRY_ENVIRONMENT"`
 }
 
 type SSHConfig struct {
-	Host                  string `yaml:"host" envconfig:"SSH_HOST"`
-	User                  string `yaml:"user" envconfig:"SSH_USER"`
-	BasePath              string `yaml:"base_path" envconfig:"SSH_BASE_PATH"`
-	Port                  int    `yaml:"port" envconfig:"SSH_PORT"`
-	PrivateKey            string `yaml:"private_key" envconfig:"SSH_PRIVATE_KEY"`
-	KnownHostsFile        string `yaml:"known_hosts_file" envconfig:"SSH_KNOWN_HOSTS_FILE"`
-	StrictHostKeyChecking bool   `yaml:"strict_host_key_checking" envconfig:"SSH_STRICT_HOST_KEY_CHECKING"`
+	Host                  string          `yaml:"host" envconfig:"SSH_HOST"`
+	User                  string          `yaml:"user" envconfig:"SSH_USER"`
+	BasePath              string          `yaml:"base_path" envconfig:"SSH_BASE_PATH"`
+	Port                  int             `yaml:"port" envconfig:"SSH_PORT"`
+	PrivateKey            string          `yaml:"private_key" envconfig:"SSH_PRIVATE_KEY"`
+	KnownHostsFile        string          `yaml:"known_hosts_file" envconfig:"SSH_KNOWN_HOSTS_FILE"`
+	HostKeyStoreFile      string          `yaml:"host_key_store_file" envconfig:"SSH_HOST_KEY_STORE_FILE"`
+	StrictHostKeyChecking bool            `yaml:"strict_host_key_checking" envconfig:"SSH_STRICT_HOST_KEY_CHECKING"`
+	RepoCache             RepoCacheConfig `yaml:"repo_cache"`
+	// Hosts is the group of hosts deployments are placed on; empty means the single host above
+	Hosts []DeployHostConfig `yaml:"hosts"`
+	// HostStateFile keeps the drain state of the hosts and where each environment is deployed (API)
+	HostStateFile string `yaml:"host_state_file" envconfig:"SSH_HOST_STATE_FILE"`
+	// HostLoad places new preview environments on the least-loaded host of the group
+	HostLoad HostLoadConfig `yaml:"host_load"`
+	// HostHealth holds deployments to a host while its disk is nearly full or its load is critical (worker)
+	HostHealth HostHealthConfig `yaml:"host_health"`
+}
+
+// Sources of the load of the deploy hosts
+const (
+	HostLoadSourceSSH          = "ssh"
+	HostLoadSourceNodeExporter = "node_exporter"
+)
+
+// HostLoadConfig configures how the load of the deploy hosts is queried
+type HostLoadConfig struct {
+	// Source is "ssh" (read /proc/loadavg on the host), "node_exporter", or empty to use the first host
+	Source string `yaml:"source" envconfig:"SSH_HOST_LOAD_SOURCE"`
+	// NodeExporterPort is the port node exporter listens on on every host (default: 9100)
+	NodeExporterPort int `yaml:"node_exporter_port" envconfig:"SSH_HOST_LOAD_NODE_EXPORTER_PORT"`
+	// Timeout bounds the load query; when it runs out, the first host is used (default: 10s)
+	Timeout time.Duration `yaml:"timeout" envconfig:"SSH_HOST_LOAD_TIMEOUT"`
+}
+
+// HostHealthConfig configures the check of the deploy host before a deployment
+type HostHealthConfig struct {
+	// Source is "ssh" (nproc, /proc/loadavg, and df on the host), "node_exporter" on
+	// host_load.node_exporter_port, or empty to deploy without checking the host
+	Source string `yaml:"source" envconfig:"SSH_HOST_HEALTH_SOURCE"`
+	// Mountpoint is the filesystem whose usage is checked (default: /)
+	Mountpoint string `yaml:"mountpoint" envconfig:"SSH_HOST_HEALTH_MOUNTPOINT"`
+	// MaxDiskUsage is the highest fraction of the filesystem in use (default: 0.95)
+	MaxDiskUsage float64 `yaml:"max_disk_usage" envconfig:"SSH_HOST_HEALTH_MAX_DISK_USAGE"`
+	// MaxLoad is the highest one-minute load average per CPU (default: 4)
+	MaxLoad float64 `yaml:"max_load" envconfig:"SSH_HOST_HEALTH_MAX_LOAD"`
+	// Wait is how long a deployment waits for an unhealthy host to recover; 0 fails it at once
+	Wait time.Duration `yaml:"wait" envconfig:"SSH_HOST_HEALTH_WAIT"`
+}
+
+// ValidateBasePath checks that base_path is absolute; the command guard of the worker resolves
+// the directories deployments remove against it, so a relative path would refuse every deployment
+func (c SSHConfig) ValidateBasePath() error {
+	if !strings.HasPrefix(c.BasePath, "/") {
+		return fmt.Errorf("ssh.base_path must be an absolute path, got %q", c.BasePath)
+	}
+	return nil
+}
+
+// Validate checks the host health settings; workers check them at startup
+func (c HostHealthConfig) Validate() error {
+	switch c.Source {
+	case "", HostLoadSourceSSH, HostLoadSourceNodeExporter:
+	default:
+		return fmt.Errorf("ssh.host_health.source must be %q or %q", HostLoadSourceSSH, HostLoadSourceNodeExporter)
+	}
+	if c.MaxDiskUsage <= 0 || c.MaxDiskUsage > 1 {
+		return fmt.Errorf("ssh.host_health.max_disk_usage must be between 0 and 1")
+	}
+	if c.MaxLoad <= 0 {
+		return fmt.Errorf("ssh.host_health.max_load must be positive")
+	}
+	if c.Wait < 0 {
+		return fmt.Errorf("ssh.host_health.wait must not be negative")
+	}
+	return nil
+}
+
+// DeployHostConfig is a host of the deploy host group
+type DeployHostConfig struct {
+	// Name identifies the host in requests and the API
+	Name string `yaml:"name"`
+	Host string `yaml:"host"`
+	// Port defaults to ssh.port
+	Port int `yaml:"port"`
+	// DNSValue is the ip_mappings placeholder DNS records of services deployed on the host
+	// point at; empty keeps the value of the request
+	DNSValue string `yaml:"dns_value"`
+}
+
+// HostGroup returns the deploy hosts, or the single configured host if no group is configured
+func (c SSHConfig) HostGroup() []DeployHostConfig {
+	if len(c.Hosts) == 0 {
+		return []DeployHostConfig{{Name: c.Host, Host: c.Host, Port: c.Port}}
+	}
+
+	hosts := make([]DeployHostConfig, len(c.Hosts))
+	for i, host := range c.Hosts {
+		if host.Port == 0 {
+			host.Port = c.Port
+		}
+		hosts[i] = host
+	}
+	return hosts
+}
+
+// LookupHost returns the deploy host with the given name; an empty name is the first host of the group
+func (c SSHConfig) LookupHost(name string) (DeployHostConfig, bool) {
+	hosts := c.HostGroup()
+	if name == "" {
+		return hosts[0], true
+	}
+	for _, host := range hosts {
+		if host.Name == name {
+			return host, true
+		}
+	}
+	return DeployHostConfig{}, false
+}
+
+// RepoCacheConfig configures the bare repository mirrors kept on the target host
+type RepoCacheConfig struct {
+	Enable bool `yaml:"enable" envconfig:"SSH_REPO_CACHE_ENABLE"`
+	// Path defaults to <base_path>/.mirrors
+	Path string `yaml:"path" envconfig:"SSH_REPO_CACHE_PATH"`
+	// Repositories limits the cache to the listed repositories (owner/name); empty caches all
+	Repositories []string `yaml:"repositories"`
+}
+
+// RetryConfig bounds the time a deployment spends retrying failed activities
+type RetryConfig struct {
+	// Budget is the maximum total time a deployment spends retrying across all activities; 0 disables the budget
+	Budget time.Duration `yaml:"budget" envconfig:"RETRY_BUDGET"`
+	// Jitter randomizes each backoff interval by up to ±Jitter (0-1); 0 disables jitter
+	Jitter float64 `yaml:"jitter" envconfig:"RETRY_JITTER"`
+}
+
+// EnvironmentsConfig configures the environments catalog (API)
+type EnvironmentsConfig struct {
+	// CatalogFile keeps the environments deployments may target; the default environments
+	// snapshot, dev, stage, and production are used until it is first written
+	CatalogFile string `yaml:"catalog_file" envconfig:"ENVIRONMENTS_CATALOG_FILE"`
+}
+
+// DeploymentsConfig configures the store of the deployment records (API)
+type DeploymentsConfig struct {
+	// Driver is the backend of the store: "sqlite" for a single API replica, "postgres" for
+	// replicas sharing the records, or "memory", which loses them on restart
+	Driver string `yaml:"driver" envconfig:"DEPLOYMENTS_DRIVER"`
+	// DSN is the SQLite database file or the PostgreSQL connection string,
+	// e.g. postgres://deploy:secret@db:5432/deployments
+	DSN string `yaml:"dsn" envconfig:"DEPLOYMENTS_DSN"`
+}
+
+// Backends of the deployment store
+const (
+	DeploymentsDriverSQLite   = "sqlite"
+	DeploymentsDriverPostgres = "postgres"
+	DeploymentsDriverMemory   = "memory"
+)
+
+// SchedulerConfig configures the leader election of the background schedulers among the API replicas
+type SchedulerConfig struct {
+	// LeaseTTL is how long a replica holds the lease of a scheduler without renewing it; a
+	// replica that dies is replaced after at most this long
+	LeaseTTL time.Duration `yaml:"lease_ttl" envconfig:"SCHEDULER_LEASE_TTL"`
+}
+
+// ReceiptsConfig configures the signed receipts of completed deployments (worker)
+type ReceiptsConfig struct {
+	// SigningKeyFile is a PEM file with the Ed25519 private key (PKCS #8) receipts are signed with;
+	// deployments get no receipt when empty
+	SigningKeyFile string `yaml:"signing_key_file" envconfig:"RECEIPTS_SIGNING_KEY_FILE"`
+}
+
+// ChaosConfig injects failures into the adapters of the worker, to exercise retries, rollbacks,
+// and alerting end to end on a staging instance (worker)
+type ChaosConfig struct {
+	Enable bool `yaml:"enable" envconfig:"CHAOS_ENABLE"`
+	// Namespaces are the Temporal namespaces whose activities failures are injected into; the
+	// namespace of the production environment is refused
+	Namespaces []string `yaml:"namespaces" envconfig:"CHAOS_NAMESPACES"`
+	// SSHFailureRate is the fraction of SSH executions failed before connecting, from 0 to 1
+	SSHFailureRate float64 `yaml:"ssh_failure_rate" envconfig:"CHAOS_SSH_FAILURE_RATE"`
+	// CloudflareLatency delays each DNS record change sent to Cloudflare
+	CloudflareLatency time.Duration `yaml:"cloudflare_latency" envconfig:"CHAOS_CLOUDFLARE_LATENCY"`
+}
+
+// ProductionEnvironment is the environment whose namespace failures are never injected into
+const ProductionEnvironment = "production"
+
+// EncryptionConfig configures the encryption of the workflow payloads stored in Temporal,
+// such as deploy outputs and receipts, with the keys of each tenant or namespace (API and worker)
+type EncryptionConfig struct {
+	// Tenants maps tenants, the metadata.project_name of deployments, to their keys. The payloads
+	// and records of a tenant with keys are encrypted with them in every namespace.
+	Tenants map[string][]EncryptionKeyConfig `yaml:"tenants" envconfig:"ENCRYPTION_TENANT_KEYS"`
+	// Namespaces maps Temporal namespaces to the keys of the payloads of tenants without keys of
+	// their own. The first key encrypts new payloads, the others only decrypt older ones; payloads
+	// of namespaces and tenants without keys aren't encrypted.
+	Namespaces map[string][]EncryptionKeyConfig `yaml:"namespaces" envconfig:"ENCRYPTION_KEYS"`
+}
+
+// EncryptionKeyConfig is an AES-256 key encrypting workflow payloads
+type EncryptionKeyConfig struct {
+	// ID is stored with each payload the key encrypts, so the key can be found after a rotation
+	ID string `yaml:"id"`
+	// File holds the 32 byte key, base64 encoded, e.g. written by "openssl rand -base64 32"
+	File string `yaml:"file"`
+}
+
+// RetentionConfig configures how long deployment records are kept
+type RetentionConfig struct {
+	Enable bool `yaml:"enable" envconfig:"RETENTION_ENABLE"`
+	// Interval is the time between pruning runs
+	Interval time.Duration `yaml:"interval" envconfig:"RETENTION_INTERVAL"`
+	// Environments is no longer used: the retention of each environment is set in the
+	// environments catalog. Configs still setting it fail validation, so the move isn't missed.
+	Environments map[string]time.Duration `yaml:"environments"`
+	// GracePeriod is how long a soft-deleted record can be restored before its history is purged
+	GracePeriod time.Duration `yaml:"grace_period" envconfig:"RETENTION_GRACE_PERIOD"`
+	// TombstoneFile stores the tombstones of deleted records
+	TombstoneFile string `yaml:"tombstone_file" envconfig:"RETENTION_TOMBSTONE_FILE"`
+	// TombstoneTTL is how long tombstones are kept after the purge; 0 keeps them forever
+	TombstoneTTL time.Duration `yaml:"tombstone_ttl" envconfig:"RETENTION_TOMBSTONE_TTL"`
+}
+
+// WorkerConfig configures the capabilities of workers and how the API discovers them
+type WorkerConfig struct {
+	// Drivers lists the deployment drivers the worker accepts
+	Drivers []string `yaml:"drivers" envconfig:"WORKER_DRIVERS"`
+	// Disabled lists the capabilities turned off on the worker: "dns" and "notifier".
+	// The worker rereads Drivers and Disabled on SIGHUP, when the config file takes precedence
+	// over the environment variables.
+	Disabled []string `yaml:"disabled" envconfig:"WORKER_DISABLED"`
+	// URLs are the base URLs of the workers' info endpoints, used by the API to check fleet capabilities
+	URLs []string `yaml:"urls" envconfig:"WORKER_URLS"`
+	// Token authorizes the notification failure endpoints of the worker; the API sends it to
+	// the workers of URLs. The endpoints reject every request when it is empty.
+	Token string `yaml:"token" envconfig:"WORKER_TOKEN"`
+	// NotificationFailuresFile stores the notifications the worker couldn't deliver until they are resent
+	NotificationFailuresFile string `yaml:"notification_failures_file" envconfig:"WORKER_NOTIFICATION_FAILURES_FILE"`
+	// Region is the region of the worker, matched against the setup.worker.region of deployments
+	Region string `yaml:"region" envconfig:"WORKER_REGION"`
+}
+
+// defaultWorkerDrivers are the drivers a worker accepts unless worker.drivers says otherwise
+var defaultWorkerDrivers = []string{"script", "compose"}
+
+// Capabilities that can be turned off with WorkerConfig.Disabled
+const (
+	CapabilityDNS      = "dns"
+	CapabilityNotifier = "notifier"
+)
+
+// validateEncryptionKeys checks the keys of a namespace or tenant at path
+func validateEncryptionKeys(path string, keys []EncryptionKeyConfig) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("%s must list at least one key", path)
+	}
+	ids := make(map[string]bool)
+	for i, key := range keys {
+		if key.ID == "" || key.File == "" {
+			return fmt.Errorf("%s[%d]: id and file are required", path, i)
+		}
+		if ids[key.ID] {
+			return fmt.Errorf("%s[%d]: duplicate id %q", path, i, key.ID)
+		}
+		ids[key.ID] = true
+	}
+	return nil
+}
+
+// parseEncryptionKeys parses keys in the format owner=id:file,owner=id:file, where owner is a
+// namespace or tenant; the keys of an owner are kept in order
+func parseEncryptionKeys(keysStr string) map[string][]EncryptionKeyConfig {
+	keys := make(map[string][]EncryptionKeyConfig)
+	for _, entry := range strings.Split(keysStr, ",") {
+		owner, key, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		id, file, ok := strings.Cut(key, ":")
+		if !ok {
+			continue
+		}
+		owner = strings.TrimSpace(owner)
+		keys[owner] = append(keys[owner], EncryptionKeyConfig{ID: strings.TrimSpace(id), File: strings.TrimSpace(file)})
+	}
+	return keys
+}
+
+// ValidateChaos checks the failure injection rates, and that failure injection is limited to
+// namespaces the worker serves, other than the namespace of the production environment
+func (c *Config) ValidateChaos() error {
+	if c.Chaos.SSHFailureRate < 0 || c.Chaos.SSHFailureRate > 1 {
+		return fmt.Errorf("chaos.ssh_failure_rate must be between 0 and 1")
+	}
+	if c.Chaos.CloudflareLatency < 0 {
+		return fmt.Errorf("chaos.cloudflare_latency must not be negative")
+	}
+	if !c.Chaos.Enable {
+		return nil
+	}
+	if len(c.Chaos.Namespaces) == 0 {
+		return fmt.Errorf("chaos.namespaces is required when chaos.enable is set")
+	}
+	production := c.Temporal.Namespace
+	if namespace, ok := c.Temporal.Namespaces[ProductionEnvironment]; ok {
+		production = namespace
+	}
+	for _, namespace := range c.Chaos.Namespaces {
+		if namespace == production {
+			return fmt.Errorf("chaos.namespaces: %s is the namespace of the %s environment; route %s to a namespace of its own with temporal.namespaces", namespace, ProductionEnvironment, ProductionEnvironment)
+		}
+		served := namespace == c.Temporal.Namespace
+		for _, mapped := range c.Temporal.Namespaces {
+			served = served || mapped == namespace
+		}
+		if !served {
+			return fmt.Errorf("chaos.namespaces: the worker doesn't serve namespace %s", namespace)
+		}
+	}
+	return nil
+}
+
+// ReloadWorkerCapabilities rereads the drivers and disabled capabilities for a SIGHUP. Unlike
+// Load, worker.drivers and worker.disabled in the config file take precedence over WORKER_DRIVERS
+// and WORKER_DISABLED, which the process can't change, so editing the file always takes effect;
+// an empty list in the file, e.g. "disabled: []", turns every capability back on. The rest of the
+// config isn't reread, and Load can't run twice as it defines the command line flags.
+func ReloadWorkerCapabilities() (WorkerConfig, error) {
+	worker := WorkerConfig{Drivers: defaultWorkerDrivers}
+	if drivers := os.Getenv("WORKER_DRIVERS"); drivers != "" {
+		worker.Drivers = strings.Split(drivers, ",")
+	}
+	if disabled := os.Getenv("WORKER_DISABLED"); disabled != "" {
+		worker.Disabled = strings.Split(disabled, ",")
+	}
+
+	data, err := os.ReadFile("config.yaml")
+	if err != nil && !os.IsNotExist(err) {
+		return WorkerConfig{}, fmt.Errorf("failed to load config from file: %w", err)
+	}
+	// Pointers tell a list the file leaves out from an empty one
+	var fileConfig struct {
+		Worker struct {
+			Drivers  *[]string `yaml:"drivers"`
+			Disabled *[]string `yaml:"disabled"`
+		} `yaml:"worker"`
+	}
+	if err := yaml.Unmarshal(data, &fileConfig); err != nil {
+		return WorkerConfig{}, fmt.Errorf("failed to load config from file: %w", err)
+	}
+	if fileConfig.Worker.Drivers != nil {
+		worker.Drivers = *fileConfig.Worker.Drivers
+	}
+	if fileConfig.Worker.Disabled != nil {
+		worker.Disabled = *fileConfig.Worker.Disabled
+	}
+
+	if err := worker.ValidateCapabilities(); err != nil {
+		return WorkerConfig{}, err
+	}
+	return worker, nil
+}
+
+// ValidateCapabilities checks the drivers and capabilities a worker rereads on SIGHUP
+func (c WorkerConfig) ValidateCapabilities() error {
+	for _, driver := range c.Drivers {
+		if driver != "script" && driver != "compose" {
+			return fmt.Errorf("worker.drivers: unknown driver %q", driver)
+		}
+	}
+	for _, capability := range c.Disabled {
+		if capability != CapabilityDNS && capability != CapabilityNotifier {
+			return fmt.Errorf("worker.disabled: unknown capability %q", capability)
+		}
+	}
+	return nil
+}
+
+// validate checks that the rule matches either pushes of branches, pushes of tags, or pull requests
+func (r EnvironmentRule) validate() error {
+	switch {
+	case r.Environment == "":
+		return fmt.Errorf("environment is required")
+	case r.Branch == "" && r.Tag == "" && !r.PullRequest:
+		return fmt.Errorf("one of branch, tag, and pull_request is required")
+	case r.Branch != "" && r.Tag != "":
+		return fmt.Errorf("branch and tag can't be combined")
+	case r.Tag != "" && r.PullRequest:
+		return fmt.Errorf("tag and pull_request can't be combined")
+	}
+	for _, pattern := range []string{r.Branch, r.Tag} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
 }
 
 func Load() (*Config, error) {
 	config := &Config{
 		Server: ServerConfig{
-			Host: "localhost",
-			Port: "8080",
+			Host:            "localhost",
+			Port:            "8080",
+			ShutdownTimeout: 30 * time.Second,
 		},
 		Temporal: TemporalConfig{
 			Address:   "localhost:7233",
 			Namespace: "default",
 		},
+		Cloudflare: CloudflareConfig{
+			APIURL: "https://api.cloudflare.com/client/v4",
+		},
+		GitHub: GitHubConfig{
+			APIURL: "https://api.github.com",
+			Preview: PreviewConfig{
+				Environment: "snapshot",
+			},
+		},
+		Discord: DiscordConfig{
+			Ack: DiscordAckConfig{
+				ReminderInterval: 30 * time.Minute,
+				MaxReminders:     3,
+			},
+			Threads: DiscordThreadsConfig{
+				StateFile: "discord-threads.json",
+			},
+		},
 		Logger: LoggerConfig{
-			Level:  "info",
-			Format: "json",
+			Level:   "info",
+			Format:  "json",
+			Outputs: []string{LogOutputStderr},
+			File: LogFileConfig{
+				MaxSizeMB:  100,
+				MaxAge:     7 * 24 * time.Hour,
+				MaxBackups: 5,
+			},
+			Loki: LogLokiConfig{
+				BatchWait: time.Second,
+			},
+		},
+		Retention: RetentionConfig{
+			Interval:      24 * time.Hour,
+			GracePeriod:   7 * 24 * time.Hour,
+			TombstoneFile: "tombstones.json",
+		},
+		Environments: EnvironmentsConfig{
+			CatalogFile: "environments.json",
+		},
+		Deployments: DeploymentsConfig{
+			Driver: DeploymentsDriverSQLite,
+			DSN:    "deployments.db",
+		},
+		Scheduler: SchedulerConfig{
+			LeaseTTL: 30 * time.Second,
+		},
+		Worker: WorkerConfig{
+			Drivers:                  defaultWorkerDrivers,
+			NotificationFailuresFile: "notification-failures.json",
 		},
 		SSH: SSHConfig{
 			Host:                  "",
@@ -92,6 +772,16 @@ func Load() (*Config, error) {
 			PrivateKey:            "",
 			KnownHostsFile:        "",
 			StrictHostKeyChecking: true,
+			HostStateFile:         "hosts.json",
+			HostLoad: HostLoadConfig{
+				NodeExporterPort: 9100,
+				Timeout:          10 * time.Second,
+			},
+			HostHealth: HostHealthConfig{
+				Mountpoint:   "/",
+				MaxDiskUsage: 0.95,
+				MaxLoad:      4,
+			},
 		},
 	}
 
@@ -137,42 +827,249 @@ func loadFromFile(filePath string, config *Config) error {
 	if fileConfig.Server.Port != "" {
 		config.Server.Port = fileConfig.Server.Port
 	}
+	if fileConfig.Server.ShutdownDelay != 0 {
+		config.Server.ShutdownDelay = fileConfig.Server.ShutdownDelay
+	}
+	if fileConfig.Server.ShutdownTimeout != 0 {
+		config.Server.ShutdownTimeout = fileConfig.Server.ShutdownTimeout
+	}
 	if fileConfig.Temporal.Address != "" {
 		config.Temporal.Address = fileConfig.Temporal.Address
 	}
 	if fileConfig.Temporal.Namespace != "" {
 		config.Temporal.Namespace = fileConfig.Temporal.Namespace
 	}
+	if len(fileConfig.Temporal.Namespaces) > 0 {
+		config.Temporal.Namespaces = fileConfig.Temporal.Namespaces
+	}
 	if fileConfig.Auth.DeployToken != "" {
 		config.Auth.DeployToken = fileConfig.Auth.DeployToken
 	}
+	if fileConfig.Auth.AdminToken != "" {
+		config.Auth.AdminToken = fileConfig.Auth.AdminToken
+	}
+	if len(fileConfig.Auth.ViewerTokens) > 0 {
+		config.Auth.ViewerTokens = fileConfig.Auth.ViewerTokens
+	}
 	if fileConfig.Infisical.BaseURL != "" {
 		config.Infisical.BaseURL = fileConfig.Infisical.BaseURL
 	}
 	if fileConfig.Infisical.ServiceToken != "" {
 		config.Infisical.ServiceToken = fileConfig.Infisical.ServiceToken
 	}
+	if fileConfig.Infisical.ChecksumSalt != "" {
+		config.Infisical.ChecksumSalt = fileConfig.Infisical.ChecksumSalt
+	}
+	if fileConfig.Cloudflare.APIURL != "" {
+		config.Cloudflare.APIURL = fileConfig.Cloudflare.APIURL
+	}
 	if fileConfig.Cloudflare.APIToken != "" {
 		config.Cloudflare.APIToken = fileConfig.Cloudflare.APIToken
 	}
 	if fileConfig.Cloudflare.ZoneID != "" {
 		config.Cloudflare.ZoneID = fileConfig.Cloudflare.ZoneID
 	}
+	if len(fileConfig.Cloudflare.AllowedDomains) > 0 {
+		config.Cloudflare.AllowedDomains = fileConfig.Cloudflare.AllowedDomains
+	}
+	if fileConfig.Cloudflare.Canary.Domain != "" {
+		config.Cloudflare.Canary.Domain = fileConfig.Cloudflare.Canary.Domain
+	}
+	if len(fileConfig.Cloudflare.Canary.Environments) > 0 {
+		config.Cloudflare.Canary.Environments = fileConfig.Cloudflare.Canary.Environments
+	}
 	if fileConfig.Discord.WebhookURL != "" {
 		config.Discord.WebhookURL = fileConfig.Discord.WebhookURL
 	}
+	if fileConfig.Discord.Ack.Enable {
+		config.Discord.Ack.Enable = true
+	}
+	if fileConfig.Discord.Ack.ReminderInterval != 0 {
+		config.Discord.Ack.ReminderInterval = fileConfig.Discord.Ack.ReminderInterval
+	}
+	if fileConfig.Discord.Ack.MaxReminders != 0 {
+		config.Discord.Ack.MaxReminders = fileConfig.Discord.Ack.MaxReminders
+	}
+	if fileConfig.Discord.Ack.Mention != "" {
+		config.Discord.Ack.Mention = fileConfig.Discord.Ack.Mention
+	}
+	if fileConfig.Discord.Threads.Enable {
+		config.Discord.Threads.Enable = true
+	}
+	if fileConfig.Discord.Threads.StateFile != "" {
+		config.Discord.Threads.StateFile = fileConfig.Discord.Threads.StateFile
+	}
+	if len(fileConfig.Discord.Mentions.Users) > 0 {
+		config.Discord.Mentions.Users = fileConfig.Discord.Mentions.Users
+	}
+	if fileConfig.Discord.Mentions.LookupCommitAuthor {
+		config.Discord.Mentions.LookupCommitAuthor = true
+	}
+	if fileConfig.Teams.WebhookURL != "" {
+		config.Teams.WebhookURL = fileConfig.Teams.WebhookURL
+	}
+	if len(fileConfig.Teams.MentionUsers) > 0 {
+		config.Teams.MentionUsers = fileConfig.Teams.MentionUsers
+	}
+	if len(fileConfig.Notification.Routes) > 0 {
+		config.Notification.Routes = fileConfig.Notification.Routes
+	}
+	if len(fileConfig.Notification.Verbosity) > 0 {
+		config.Notification.Verbosity = fileConfig.Notification.Verbosity
+	}
+	if fileConfig.Notification.DefaultVerbosity != "" {
+		config.Notification.DefaultVerbosity = fileConfig.Notification.DefaultVerbosity
+	}
+	if fileConfig.GitHub.APIURL != "" {
+		config.GitHub.APIURL = fileConfig.GitHub.APIURL
+	}
+	if fileConfig.GitHub.Token != "" {
+		config.GitHub.Token = fileConfig.GitHub.Token
+	}
+	if fileConfig.GitHub.WebhookSecret != "" {
+		config.GitHub.WebhookSecret = fileConfig.GitHub.WebhookSecret
+	}
+	if fileConfig.GitHub.Preview.Environment != "" {
+		config.GitHub.Preview.Environment = fileConfig.GitHub.Preview.Environment
+	}
+	if len(fileConfig.GitHub.Preview.Repositories) > 0 {
+		config.GitHub.Preview.Repositories = fileConfig.GitHub.Preview.Repositories
+	}
+	if len(fileConfig.GitHub.Environments) > 0 {
+		config.GitHub.Environments = fileConfig.GitHub.Environments
+	}
 	if len(fileConfig.IPMappings) > 0 {
 		config.IPMappings = fileConfig.IPMappings
 	}
 	if fileConfig.OTEL.CollectorURL != "" {
 		config.OTEL.CollectorURL = fileConfig.OTEL.CollectorURL
 	}
+	if fileConfig.Retry.Budget != 0 {
+		config.Retry.Budget = fileConfig.Retry.Budget
+	}
+	if fileConfig.Retry.Jitter != 0 {
+		config.Retry.Jitter = fileConfig.Retry.Jitter
+	}
+	if fileConfig.Retention.Enable {
+		config.Retention.Enable = true
+	}
+	if fileConfig.Retention.Interval != 0 {
+		config.Retention.Interval = fileConfig.Retention.Interval
+	}
+	if len(fileConfig.Retention.Environments) > 0 {
+		config.Retention.Environments = fileConfig.Retention.Environments
+	}
+	if fileConfig.Retention.GracePeriod != 0 {
+		config.Retention.GracePeriod = fileConfig.Retention.GracePeriod
+	}
+	if fileConfig.Retention.TombstoneFile != "" {
+		config.Retention.TombstoneFile = fileConfig.Retention.TombstoneFile
+	}
+	if fileConfig.Retention.TombstoneTTL != 0 {
+		config.Retention.TombstoneTTL = fileConfig.Retention.TombstoneTTL
+	}
+	if fileConfig.Chaos.Enable {
+		config.Chaos.Enable = true
+	}
+	if len(fileConfig.Chaos.Namespaces) > 0 {
+		config.Chaos.Namespaces = fileConfig.Chaos.Namespaces
+	}
+	if fileConfig.Chaos.SSHFailureRate != 0 {
+		config.Chaos.SSHFailureRate = fileConfig.Chaos.SSHFailureRate
+	}
+	if fileConfig.Chaos.CloudflareLatency != 0 {
+		config.Chaos.CloudflareLatency = fileConfig.Chaos.CloudflareLatency
+	}
+	if len(fileConfig.Encryption.Tenants) > 0 {
+		config.Encryption.Tenants = fileConfig.Encryption.Tenants
+	}
+	if len(fileConfig.Encryption.Namespaces) > 0 {
+		config.Encryption.Namespaces = fileConfig.Encryption.Namespaces
+	}
+	if fileConfig.Receipts.SigningKeyFile != "" {
+		config.Receipts.SigningKeyFile = fileConfig.Receipts.SigningKeyFile
+	}
+	if fileConfig.Environments.CatalogFile != "" {
+		config.Environments.CatalogFile = fileConfig.Environments.CatalogFile
+	}
+	if fileConfig.Deployments.Driver != "" {
+		config.Deployments.Driver = fileConfig.Deployments.Driver
+	}
+	if fileConfig.Deployments.DSN != "" {
+		config.Deployments.DSN = fileConfig.Deployments.DSN
+	}
+	if fileConfig.Scheduler.LeaseTTL != 0 {
+		config.Scheduler.LeaseTTL = fileConfig.Scheduler.LeaseTTL
+	}
+	if len(fileConfig.Worker.Drivers) > 0 {
+		config.Worker.Drivers = fileConfig.Worker.Drivers
+	}
+	if len(fileConfig.Worker.Disabled) > 0 {
+		config.Worker.Disabled = fileConfig.Worker.Disabled
+	}
+	if len(fileConfig.Worker.URLs) > 0 {
+		config.Worker.URLs = fileConfig.Worker.URLs
+	}
+	if fileConfig.Worker.Token != "" {
+		config.Worker.Token = fileConfig.Worker.Token
+	}
+	if fileConfig.Worker.NotificationFailuresFile != "" {
+		config.Worker.NotificationFailuresFile = fileConfig.Worker.NotificationFailuresFile
+	}
+	if fileConfig.Worker.Region != "" {
+		config.Worker.Region = fileConfig.Worker.Region
+	}
 	if fileConfig.Logger.Level != "" {
 		config.Logger.Level = fileConfig.Logger.Level
 	}
 	if fileConfig.Logger.Format != "" {
 		config.Logger.Format = fileConfig.Logger.Format
 	}
+	if len(fileConfig.Logger.Outputs) > 0 {
+		config.Logger.Outputs = fileConfig.Logger.Outputs
+	}
+	if fileConfig.Logger.File.Path != "" {
+		config.Logger.File.Path = fileConfig.Logger.File.Path
+	}
+	if fileConfig.Logger.File.MaxSizeMB != 0 {
+		config.Logger.File.MaxSizeMB = fileConfig.Logger.File.MaxSizeMB
+	}
+	if fileConfig.Logger.File.MaxAge != 0 {
+		config.Logger.File.MaxAge = fileConfig.Logger.File.MaxAge
+	}
+	if fileConfig.Logger.File.MaxBackups != 0 {
+		config.Logger.File.MaxBackups = fileConfig.Logger.File.MaxBackups
+	}
+	if fileConfig.Logger.Syslog.Network != "" {
+		config.Logger.Syslog.Network = fileConfig.Logger.Syslog.Network
+	}
+	if fileConfig.Logger.Syslog.Address != "" {
+		config.Logger.Syslog.Address = fileConfig.Logger.Syslog.Address
+	}
+	if fileConfig.Logger.Syslog.Tag != "" {
+		config.Logger.Syslog.Tag = fileConfig.Logger.Syslog.Tag
+	}
+	if fileConfig.Logger.Loki.URL != "" {
+		config.Logger.Loki.URL = fileConfig.Logger.Loki.URL
+	}
+	if fileConfig.Logger.Loki.TenantID != "" {
+		config.Logger.Loki.TenantID = fileConfig.Logger.Loki.TenantID
+	}
+	if fileConfig.Logger.Loki.Username != "" {
+		config.Logger.Loki.Username = fileConfig.Logger.Loki.Username
+	}
+	if fileConfig.Logger.Loki.Password != "" {
+		config.Logger.Loki.Password = fileConfig.Logger.Loki.Password
+	}
+	if fileConfig.Logger.Loki.BatchWait != 0 {
+		config.Logger.Loki.BatchWait = fileConfig.Logger.Loki.BatchWait
+	}
+	if fileConfig.Sentry.DSN != "" {
+		config.Sentry.DSN = fileConfig.Sentry.DSN
+	}
+	if fileConfig.Sentry.Environment != "" {
+		config.Sentry.Environment = fileConfig.Sentry.Environment
+	}
 	if fileConfig.SSH.Host != "" {
 		config.SSH.Host = fileConfig.SSH.Host
 	}
@@ -191,6 +1088,42 @@ func loadFromFile(filePath string, config *Config) error {
 	if fileConfig.SSH.KnownHostsFile != "" {
 		config.SSH.KnownHostsFile = fileConfig.SSH.KnownHostsFile
 	}
+	if fileConfig.SSH.HostKeyStoreFile != "" {
+		config.SSH.HostKeyStoreFile = fileConfig.SSH.HostKeyStoreFile
+	}
+	if fileConfig.SSH.RepoCache.Enable {
+		config.SSH.RepoCache = fileConfig.SSH.RepoCache
+	}
+	if len(fileConfig.SSH.Hosts) > 0 {
+		config.SSH.Hosts = fileConfig.SSH.Hosts
+	}
+	if fileConfig.SSH.HostStateFile != "" {
+		config.SSH.HostStateFile = fileConfig.SSH.HostStateFile
+	}
+	if fileConfig.SSH.HostLoad.Source != "" {
+		config.SSH.HostLoad.Source = fileConfig.SSH.HostLoad.Source
+	}
+	if fileConfig.SSH.HostLoad.NodeExporterPort != 0 {
+		config.SSH.HostLoad.NodeExporterPort = fileConfig.SSH.HostLoad.NodeExporterPort
+	}
+	if fileConfig.SSH.HostLoad.Timeout != 0 {
+		config.SSH.HostLoad.Timeout = fileConfig.SSH.HostLoad.Timeout
+	}
+	if fileConfig.SSH.HostHealth.Source != "" {
+		config.SSH.HostHealth.Source = fileConfig.SSH.HostHealth.Source
+	}
+	if fileConfig.SSH.HostHealth.Mountpoint != "" {
+		config.SSH.HostHealth.Mountpoint = fileConfig.SSH.HostHealth.Mountpoint
+	}
+	if fileConfig.SSH.HostHealth.MaxDiskUsage != 0 {
+		config.SSH.HostHealth.MaxDiskUsage = fileConfig.SSH.HostHealth.MaxDiskUsage
+	}
+	if fileConfig.SSH.HostHealth.MaxLoad != 0 {
+		config.SSH.HostHealth.MaxLoad = fileConfig.SSH.HostHealth.MaxLoad
+	}
+	if fileConfig.SSH.HostHealth.Wait != 0 {
+		config.SSH.HostHealth.Wait = fileConfig.SSH.HostHealth.Wait
+	}
 	// StrictHostKeyChecking: check if SSH config exists (non-zero value struct)
 	// If SSH config exists in file, use its value
 	if fileConfig.SSH.Host != "" || fileConfig.SSH.User != "" {
@@ -207,39 +1140,293 @@ func loadFromEnv(config *Config) {
 	if port := os.Getenv("PORT"); port != "" {
 		config.Server.Port = port
 	}
+	if delayStr := os.Getenv("SERVER_SHUTDOWN_DELAY"); delayStr != "" {
+		if delay, err := time.ParseDuration(delayStr); err == nil {
+			config.Server.ShutdownDelay = delay
+		}
+	}
+	if timeoutStr := os.Getenv("SERVER_SHUTDOWN_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			config.Server.ShutdownTimeout = timeout
+		}
+	}
 	if address := os.Getenv("TEMPORAL_ADDRESS"); address != "" {
 		config.Temporal.Address = address
 	}
 	if namespace := os.Getenv("TEMPORAL_NAMESPACE"); namespace != "" {
 		config.Temporal.Namespace = namespace
 	}
+	if namespacesStr := os.Getenv("TEMPORAL_NAMESPACES"); namespacesStr != "" {
+		// Format: production=deploy-production,stage=deploy-stage
+		namespaces := make(map[string]string)
+		for _, pair := range strings.Split(namespacesStr, ",") {
+			if environment, namespace, ok := strings.Cut(pair, "="); ok {
+				namespaces[strings.TrimSpace(environment)] = strings.TrimSpace(namespace)
+			}
+		}
+		config.Temporal.Namespaces = namespaces
+	}
 	if token := os.Getenv("DEPLOY_TOKEN"); token != "" {
 		config.Auth.DeployToken = token
 	}
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		config.Auth.AdminToken = token
+	}
+	if tokens := os.Getenv("VIEWER_TOKENS"); tokens != "" {
+		config.Auth.ViewerTokens = strings.Split(tokens, ",")
+	}
 	if baseURL := os.Getenv("INFISICAL_BASE_URL"); baseURL != "" {
 		config.Infisical.BaseURL = baseURL
 	}
 	if serviceToken := os.Getenv("INFISICAL_SERVICE_TOKEN"); serviceToken != "" {
 		config.Infisical.ServiceToken = serviceToken
 	}
+	if checksumSalt := os.Getenv("SECRET_CHECKSUM_SALT"); checksumSalt != "" {
+		config.Infisical.ChecksumSalt = checksumSalt
+	}
+	if apiURL := os.Getenv("CLOUDFLARE_API_URL"); apiURL != "" {
+		config.Cloudflare.APIURL = apiURL
+	}
 	if apiToken := os.Getenv("CLOUDFLARE_API_TOKEN"); apiToken != "" {
 		config.Cloudflare.APIToken = apiToken
 	}
 	if zoneID := os.Getenv("CLOUDFLARE_ZONE_ID"); zoneID != "" {
 		config.Cloudflare.ZoneID = zoneID
 	}
+	if allowedDomains := os.Getenv("CLOUDFLARE_ALLOWED_DOMAINS"); allowedDomains != "" {
+		config.Cloudflare.AllowedDomains = strings.Split(allowedDomains, ",")
+	}
+	if canaryDomain := os.Getenv("CLOUDFLARE_CANARY_DOMAIN"); canaryDomain != "" {
+		config.Cloudflare.Canary.Domain = canaryDomain
+	}
+	if environments := os.Getenv("CLOUDFLARE_CANARY_ENVIRONMENTS"); environments != "" {
+		config.Cloudflare.Canary.Environments = strings.Split(environments, ",")
+	}
 	if webhookURL := os.Getenv("DISCORD_WEBHOOK_URL"); webhookURL != "" {
 		config.Discord.WebhookURL = webhookURL
 	}
+	if ackStr := os.Getenv("DISCORD_ACK_ENABLE"); ackStr != "" {
+		config.Discord.Ack.Enable = ackStr == "true" || ackStr == "1"
+	}
+	if intervalStr := os.Getenv("DISCORD_ACK_REMINDER_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			config.Discord.Ack.ReminderInterval = interval
+		}
+	}
+	if maxStr := os.Getenv("DISCORD_ACK_MAX_REMINDERS"); maxStr != "" {
+		if maxReminders, err := strconv.Atoi(maxStr); err == nil {
+			config.Discord.Ack.MaxReminders = maxReminders
+		}
+	}
+	if mention := os.Getenv("DISCORD_ACK_MENTION"); mention != "" {
+		config.Discord.Ack.Mention = mention
+	}
+	if threadsStr := os.Getenv("DISCORD_THREADS_ENABLE"); threadsStr != "" {
+		config.Discord.Threads.Enable = threadsStr == "true" || threadsStr == "1"
+	}
+	if stateFile := os.Getenv("DISCORD_THREADS_STATE_FILE"); stateFile != "" {
+		config.Discord.Threads.StateFile = stateFile
+	}
+	if usersStr := os.Getenv("DISCORD_MENTION_USERS"); usersStr != "" {
+		// Format: alice=123456789012345678,bob@example.com=234567890123456789
+		users := make(map[string]string)
+		for _, pair := range strings.Split(usersStr, ",") {
+			if author, userID, ok := strings.Cut(pair, "="); ok {
+				users[strings.TrimSpace(author)] = strings.TrimSpace(userID)
+			}
+		}
+		config.Discord.Mentions.Users = users
+	}
+	if lookupStr := os.Getenv("DISCORD_MENTION_LOOKUP_COMMIT_AUTHOR"); lookupStr != "" {
+		config.Discord.Mentions.LookupCommitAuthor = lookupStr == "true" || lookupStr == "1"
+	}
+	if webhookURL := os.Getenv("TEAMS_WEBHOOK_URL"); webhookURL != "" {
+		config.Teams.WebhookURL = webhookURL
+	}
+	if usersStr := os.Getenv("TEAMS_MENTION_USERS"); usersStr != "" {
+		// Format: alice=alice@example.edu,bob@example.com=bob@example.edu
+		users := make(map[string]string)
+		for _, pair := range strings.Split(usersStr, ",") {
+			if author, userID, ok := strings.Cut(pair, "="); ok {
+				users[strings.TrimSpace(author)] = strings.TrimSpace(userID)
+			}
+		}
+		config.Teams.MentionUsers = users
+	}
+	if verbosityStr := os.Getenv("NOTIFICATION_VERBOSITY"); verbosityStr != "" {
+		// Format: production=normal,snapshot=failures
+		verbosity := make(map[string]string)
+		for _, pair := range strings.Split(verbosityStr, ",") {
+			if environment, level, ok := strings.Cut(pair, "="); ok {
+				verbosity[strings.TrimSpace(environment)] = strings.TrimSpace(level)
+			}
+		}
+		config.Notification.Verbosity = verbosity
+	}
+	if defaultVerbosity := os.Getenv("NOTIFICATION_DEFAULT_VERBOSITY"); defaultVerbosity != "" {
+		config.Notification.DefaultVerbosity = defaultVerbosity
+	}
+	if apiURL := os.Getenv("GITHUB_API_URL"); apiURL != "" {
+		config.GitHub.APIURL = apiURL
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		config.GitHub.Token = token
+	}
+	if webhookSecret := os.Getenv("GITHUB_WEBHOOK_SECRET"); webhookSecret != "" {
+		config.GitHub.WebhookSecret = webhookSecret
+	}
 	if collectorURL := os.Getenv("OTEL_COLLECTOR_URL"); collectorURL != "" {
 		config.OTEL.CollectorURL = collectorURL
 	}
+	if budgetStr := os.Getenv("RETRY_BUDGET"); budgetStr != "" {
+		if budget, err := time.ParseDuration(budgetStr); err == nil {
+			config.Retry.Budget = budget
+		}
+	}
+	if jitterStr := os.Getenv("RETRY_JITTER"); jitterStr != "" {
+		if jitter, err := strconv.ParseFloat(jitterStr, 64); err == nil {
+			config.Retry.Jitter = jitter
+		}
+	}
+	if retentionStr := os.Getenv("RETENTION_ENABLE"); retentionStr != "" {
+		config.Retention.Enable = retentionStr == "true" || retentionStr == "1"
+	}
+	if intervalStr := os.Getenv("RETENTION_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			config.Retention.Interval = interval
+		}
+	}
+	if graceStr := os.Getenv("RETENTION_GRACE_PERIOD"); graceStr != "" {
+		if grace, err := time.ParseDuration(graceStr); err == nil {
+			config.Retention.GracePeriod = grace
+		}
+	}
+	if tombstoneFile := os.Getenv("RETENTION_TOMBSTONE_FILE"); tombstoneFile != "" {
+		config.Retention.TombstoneFile = tombstoneFile
+	}
+	if ttlStr := os.Getenv("RETENTION_TOMBSTONE_TTL"); ttlStr != "" {
+		if ttl, err := time.ParseDuration(ttlStr); err == nil {
+			config.Retention.TombstoneTTL = ttl
+		}
+	}
+	if chaosStr := os.Getenv("CHAOS_ENABLE"); chaosStr != "" {
+		config.Chaos.Enable = chaosStr == "true" || chaosStr == "1"
+	}
+	if namespaces := os.Getenv("CHAOS_NAMESPACES"); namespaces != "" {
+		config.Chaos.Namespaces = strings.Split(namespaces, ",")
+	}
+	if rateStr := os.Getenv("CHAOS_SSH_FAILURE_RATE"); rateStr != "" {
+		if rate, err := strconv.ParseFloat(rateStr, 64); err == nil {
+			config.Chaos.SSHFailureRate = rate
+		}
+	}
+	if latencyStr := os.Getenv("CHAOS_CLOUDFLARE_LATENCY"); latencyStr != "" {
+		if latency, err := time.ParseDuration(latencyStr); err == nil {
+			config.Chaos.CloudflareLatency = latency
+		}
+	}
+	if keysStr := os.Getenv("ENCRYPTION_TENANT_KEYS"); keysStr != "" {
+		// Format: payments=payments-2:/keys/payments-2,payments=payments-1:/keys/payments-1
+		config.Encryption.Tenants = parseEncryptionKeys(keysStr)
+	}
+	if keysStr := os.Getenv("ENCRYPTION_KEYS"); keysStr != "" {
+		// Format: deploy-production=prod-2:/keys/prod-2,deploy-production=prod-1:/keys/prod-1
+		config.Encryption.Namespaces = parseEncryptionKeys(keysStr)
+	}
+	if signingKeyFile := os.Getenv("RECEIPTS_SIGNING_KEY_FILE"); signingKeyFile != "" {
+		config.Receipts.SigningKeyFile = signingKeyFile
+	}
+	if catalogFile := os.Getenv("ENVIRONMENTS_CATALOG_FILE"); catalogFile != "" {
+		config.Environments.CatalogFile = catalogFile
+	}
+	if driver := os.Getenv("DEPLOYMENTS_DRIVER"); driver != "" {
+		config.Deployments.Driver = driver
+	}
+	if dsn := os.Getenv("DEPLOYMENTS_DSN"); dsn != "" {
+		config.Deployments.DSN = dsn
+	}
+	if leaseTTLStr := os.Getenv("SCHEDULER_LEASE_TTL"); leaseTTLStr != "" {
+		if leaseTTL, err := time.ParseDuration(leaseTTLStr); err == nil {
+			config.Scheduler.LeaseTTL = leaseTTL
+		}
+	}
+	if drivers := os.Getenv("WORKER_DRIVERS"); drivers != "" {
+		config.Worker.Drivers = strings.Split(drivers, ",")
+	}
+	if disabled := os.Getenv("WORKER_DISABLED"); disabled != "" {
+		config.Worker.Disabled = strings.Split(disabled, ",")
+	}
+	if urls := os.Getenv("WORKER_URLS"); urls != "" {
+		config.Worker.URLs = strings.Split(urls, ",")
+	}
+	if token := os.Getenv("WORKER_TOKEN"); token != "" {
+		config.Worker.Token = token
+	}
+	if failuresFile := os.Getenv("WORKER_NOTIFICATION_FAILURES_FILE"); failuresFile != "" {
+		config.Worker.NotificationFailuresFile = failuresFile
+	}
+	if region := os.Getenv("WORKER_REGION"); region != "" {
+		config.Worker.Region = region
+	}
 	if level := os.Getenv("LOG_LEVEL"); level != "" {
 		config.Logger.Level = level
 	}
 	if format := os.Getenv("LOG_FORMAT"); format != "" {
 		config.Logger.Format = format
 	}
+	if outputs := os.Getenv("LOG_OUTPUTS"); outputs != "" {
+		config.Logger.Outputs = strings.Split(outputs, ",")
+	}
+	if path := os.Getenv("LOG_FILE_PATH"); path != "" {
+		config.Logger.File.Path = path
+	}
+	if sizeStr := os.Getenv("LOG_FILE_MAX_SIZE_MB"); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil {
+			config.Logger.File.MaxSizeMB = size
+		}
+	}
+	if ageStr := os.Getenv("LOG_FILE_MAX_AGE"); ageStr != "" {
+		if age, err := time.ParseDuration(ageStr); err == nil {
+			config.Logger.File.MaxAge = age
+		}
+	}
+	if backupsStr := os.Getenv("LOG_FILE_MAX_BACKUPS"); backupsStr != "" {
+		if backups, err := strconv.Atoi(backupsStr); err == nil {
+			config.Logger.File.MaxBackups = backups
+		}
+	}
+	if network := os.Getenv("LOG_SYSLOG_NETWORK"); network != "" {
+		config.Logger.Syslog.Network = network
+	}
+	if address := os.Getenv("LOG_SYSLOG_ADDRESS"); address != "" {
+		config.Logger.Syslog.Address = address
+	}
+	if tag := os.Getenv("LOG_SYSLOG_TAG"); tag != "" {
+		config.Logger.Syslog.Tag = tag
+	}
+	if url := os.Getenv("LOG_LOKI_URL"); url != "" {
+		config.Logger.Loki.URL = url
+	}
+	if tenantID := os.Getenv("LOG_LOKI_TENANT_ID"); tenantID != "" {
+		config.Logger.Loki.TenantID = tenantID
+	}
+	if username := os.Getenv("LOG_LOKI_USERNAME"); username != "" {
+		config.Logger.Loki.Username = username
+	}
+	if password := os.Getenv("LOG_LOKI_PASSWORD"); password != "" {
+		config.Logger.Loki.Password = password
+	}
+	if waitStr := os.Getenv("LOG_LOKI_BATCH_WAIT"); waitStr != "" {
+		if wait, err := time.ParseDuration(waitStr); err == nil {
+			config.Logger.Loki.BatchWait = wait
+		}
+	}
+	if dsn := os.Getenv("SENTRY_DSN"); dsn != "" {
+		config.Sentry.DSN = dsn
+	}
+	if environment := os.Getenv("SENTRY_ENVIRONMENT"); environment != "" {
+		config.Sentry.Environment = environment
+	}
 	if host := os.Getenv("SSH_HOST"); host != "" {
 		config.SSH.Host = host
 	}
@@ -257,12 +1444,58 @@ func loadFromEnv(config *Config) {
 	if knownHostsFile := os.Getenv("SSH_KNOWN_HOSTS_FILE"); knownHostsFile != "" {
 		config.SSH.KnownHostsFile = knownHostsFile
 	}
+	if hostKeyStoreFile := os.Getenv("SSH_HOST_KEY_STORE_FILE"); hostKeyStoreFile != "" {
+		config.SSH.HostKeyStoreFile = hostKeyStoreFile
+	}
 	if privateKey := os.Getenv("SSH_PRIVATE_KEY"); privateKey != "" {
 		config.SSH.PrivateKey = privateKey
 	}
 	if strictStr := os.Getenv("SSH_STRICT_HOST_KEY_CHECKING"); strictStr != "" {
 		config.SSH.StrictHostKeyChecking = strictStr == "true" || strictStr == "1"
 	}
+	if cacheStr := os.Getenv("SSH_REPO_CACHE_ENABLE"); cacheStr != "" {
+		config.SSH.RepoCache.Enable = cacheStr == "true" || cacheStr == "1"
+	}
+	if cachePath := os.Getenv("SSH_REPO_CACHE_PATH"); cachePath != "" {
+		config.SSH.RepoCache.Path = cachePath
+	}
+	if hostStateFile := os.Getenv("SSH_HOST_STATE_FILE"); hostStateFile != "" {
+		config.SSH.HostStateFile = hostStateFile
+	}
+	if loadSource := os.Getenv("SSH_HOST_LOAD_SOURCE"); loadSource != "" {
+		config.SSH.HostLoad.Source = loadSource
+	}
+	if portStr := os.Getenv("SSH_HOST_LOAD_NODE_EXPORTER_PORT"); portStr != "" {
+		if port, err := strconv.Atoi(portStr); err == nil {
+			config.SSH.HostLoad.NodeExporterPort = port
+		}
+	}
+	if timeoutStr := os.Getenv("SSH_HOST_LOAD_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			config.SSH.HostLoad.Timeout = timeout
+		}
+	}
+	if healthSource := os.Getenv("SSH_HOST_HEALTH_SOURCE"); healthSource != "" {
+		config.SSH.HostHealth.Source = healthSource
+	}
+	if mountpoint := os.Getenv("SSH_HOST_HEALTH_MOUNTPOINT"); mountpoint != "" {
+		config.SSH.HostHealth.Mountpoint = mountpoint
+	}
+	if usageStr := os.Getenv("SSH_HOST_HEALTH_MAX_DISK_USAGE"); usageStr != "" {
+		if usage, err := strconv.ParseFloat(usageStr, 64); err == nil {
+			config.SSH.HostHealth.MaxDiskUsage = usage
+		}
+	}
+	if loadStr := os.Getenv("SSH_HOST_HEALTH_MAX_LOAD"); loadStr != "" {
+		if load, err := strconv.ParseFloat(loadStr, 64); err == nil {
+			config.SSH.HostHealth.MaxLoad = load
+		}
+	}
+	if waitStr := os.Getenv("SSH_HOST_HEALTH_WAIT"); waitStr != "" {
+		if wait, err := time.ParseDuration(waitStr); err == nil {
+			config.SSH.HostHealth.Wait = wait
+		}
+	}
 }
 
 func loadFromFlags(config *Config) {
@@ -285,12 +1518,21 @@ func (c *Config) Validate() error {
 	if c.SSH.Host == "" {
 		return fmt.Errorf("ssh.host is required")
 	}
+	if c.Server.ShutdownDelay < 0 {
+		return fmt.Errorf("server.shutdown_delay must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		return fmt.Errorf("server.shutdown_timeout must be positive")
+	}
 	if c.SSH.User == "" {
 		return fmt.Errorf("ssh.user is required")
 	}
 	if c.SSH.Port <= 0 || c.SSH.Port > 65535 {
 		return fmt.Errorf("ssh.port must be between 1 and 65535")
 	}
+	if err := c.SSH.ValidateBasePath(); err != nil {
+		return err
+	}
 	// Validate SSH private key: PrivateKey must be set
 	if c.SSH.PrivateKey == "" {
 		return fmt.Errorf("ssh.private_key is required (set via SSH_PRIVATE_KEY environment variable or config file)")
@@ -300,5 +1542,157 @@ func (c *Config) Validate() error {
 	if c.SSH.StrictHostKeyChecking && c.SSH.KnownHostsFile != "" {
 		// File existence will be checked at runtime
 	}
+	hostNames := make(map[string]bool)
+	for i, host := range c.SSH.Hosts {
+		if host.Name == "" || host.Host == "" {
+			return fmt.Errorf("ssh.hosts[%d]: name and host are required", i)
+		}
+		if hostNames[host.Name] {
+			return fmt.Errorf("ssh.hosts[%d]: duplicate name %q", i, host.Name)
+		}
+		hostNames[host.Name] = true
+		if host.Port < 0 || host.Port > 65535 {
+			return fmt.Errorf("ssh.hosts[%d].port must be between 1 and 65535", i)
+		}
+	}
+	switch c.SSH.HostLoad.Source {
+	case "", HostLoadSourceSSH, HostLoadSourceNodeExporter:
+	default:
+		return fmt.Errorf("ssh.host_load.source must be %q or %q", HostLoadSourceSSH, HostLoadSourceNodeExporter)
+	}
+	if c.SSH.HostLoad.NodeExporterPort <= 0 || c.SSH.HostLoad.NodeExporterPort > 65535 {
+		return fmt.Errorf("ssh.host_load.node_exporter_port must be between 1 and 65535")
+	}
+	if c.SSH.HostLoad.Timeout <= 0 {
+		return fmt.Errorf("ssh.host_load.timeout must be positive")
+	}
+	if err := c.SSH.HostHealth.Validate(); err != nil {
+		return err
+	}
+	for _, output := range c.Logger.Outputs {
+		switch output {
+		case LogOutputStderr, LogOutputStdout, LogOutputSyslog:
+		case LogOutputFile:
+			if c.Logger.File.Path == "" {
+				return fmt.Errorf("logger.file.path is required for the file output")
+			}
+		case LogOutputLoki:
+			if c.Logger.Loki.URL == "" {
+				return fmt.Errorf("logger.loki.url is required for the loki output")
+			}
+			if c.Logger.Loki.BatchWait <= 0 {
+				return fmt.Errorf("logger.loki.batch_wait must be positive")
+			}
+		default:
+			return fmt.Errorf("logger.outputs: unknown output %q", output)
+		}
+	}
+	if c.Logger.File.MaxSizeMB < 0 || c.Logger.File.MaxAge < 0 || c.Logger.File.MaxBackups < 0 {
+		return fmt.Errorf("logger.file limits must not be negative")
+	}
+	switch c.Logger.Syslog.Network {
+	case "":
+	case "udp", "tcp":
+		if c.Logger.Syslog.Address == "" {
+			return fmt.Errorf("logger.syslog.address is required with logger.syslog.network")
+		}
+	default:
+		return fmt.Errorf("logger.syslog.network must be \"udp\" or \"tcp\"")
+	}
+	for i, pattern := range c.Cloudflare.AllowedDomains {
+		if strings.TrimPrefix(pattern, "*.") == "" || strings.Contains(strings.TrimPrefix(pattern, "*."), "*") {
+			return fmt.Errorf("cloudflare.allowed_domains[%d]: %q must be a domain, optionally prefixed with \"*.\"", i, pattern)
+		}
+	}
+	if strings.Contains(c.Cloudflare.Canary.Domain, "*") || strings.HasPrefix(c.Cloudflare.Canary.Domain, ".") {
+		return fmt.Errorf("cloudflare.canary.domain must be a domain such as \"preview.example.com\"")
+	}
+	for i, route := range c.Notification.Routes {
+		if len(route.Channels) == 0 {
+			return fmt.Errorf("notification.routes[%d].channels must not be empty", i)
+		}
+		for _, channel := range route.Channels {
+			if channel != "discord" && channel != "teams" {
+				return fmt.Errorf("notification.routes[%d].channels: unknown channel %q", i, channel)
+			}
+		}
+	}
+	verbosities := []string{NotificationVerbosityAll, NotificationVerbosityNormal, NotificationVerbosityResults, NotificationVerbosityFailures}
+	for environment, verbosity := range c.Notification.Verbosity {
+		if !slices.Contains(verbosities, verbosity) {
+			return fmt.Errorf("notification.verbosity.%s must be one of %s", environment, strings.Join(verbosities, ", "))
+		}
+	}
+	if c.Notification.DefaultVerbosity != "" && !slices.Contains(verbosities, c.Notification.DefaultVerbosity) {
+		return fmt.Errorf("notification.default_verbosity must be one of %s", strings.Join(verbosities, ", "))
+	}
+	for environment, namespace := range c.Temporal.Namespaces {
+		if namespace == "" {
+			return fmt.Errorf("temporal.namespaces.%s must not be empty", environment)
+		}
+	}
+	namespaces := map[string]bool{c.Temporal.Namespace: true}
+	for _, namespace := range c.Temporal.Namespaces {
+		namespaces[namespace] = true
+	}
+	for namespace, keys := range c.Encryption.Namespaces {
+		if !namespaces[namespace] {
+			return fmt.Errorf("encryption.namespaces.%s: namespace is not temporal.namespace or in temporal.namespaces", namespace)
+		}
+		if err := validateEncryptionKeys("encryption.namespaces."+namespace, keys); err != nil {
+			return err
+		}
+	}
+	for tenant, keys := range c.Encryption.Tenants {
+		if tenant == "" {
+			return fmt.Errorf("encryption.tenants: tenant names must not be empty")
+		}
+		if err := validateEncryptionKeys("encryption.tenants."+tenant, keys); err != nil {
+			return err
+		}
+	}
+	if err := c.Worker.ValidateCapabilities(); err != nil {
+		return err
+	}
+	for i, rule := range c.GitHub.Environments {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("github.environments[%d]: %w", i, err)
+		}
+	}
+	for repo, repoConfig := range c.GitHub.Preview.Repositories {
+		if timeout := repoConfig.WaitForCI.Timeout; timeout < 0 || timeout > 6*time.Hour {
+			return fmt.Errorf("github.preview.repositories.%s.wait_for_ci.timeout must be between 0 and 6h", repo)
+		}
+	}
+	if c.Retry.Budget < 0 {
+		return fmt.Errorf("retry.budget must not be negative")
+	}
+	if c.Retry.Jitter < 0 || c.Retry.Jitter > 1 {
+		return fmt.Errorf("retry.jitter must be between 0 and 1")
+	}
+	if err := c.ValidateChaos(); err != nil {
+		return err
+	}
+	if c.Retention.Enable && c.Retention.Interval <= 0 {
+		return fmt.Errorf("retention.interval must be positive")
+	}
+	if c.Retention.GracePeriod < 0 || c.Retention.TombstoneTTL < 0 {
+		return fmt.Errorf("retention.grace_period and retention.tombstone_ttl must not be negative")
+	}
+	if len(c.Retention.Environments) > 0 {
+		return fmt.Errorf("retention.environments was moved to the environments catalog; set the retention of each environment with PUT /api/admin/environments/{name}")
+	}
+	switch c.Deployments.Driver {
+	case DeploymentsDriverSQLite, DeploymentsDriverPostgres:
+		if c.Deployments.DSN == "" {
+			return fmt.Errorf("deployments.dsn is required for the %s driver", c.Deployments.Driver)
+		}
+	case DeploymentsDriverMemory:
+	default:
+		return fmt.Errorf("deployments.driver must be %q, %q, or %q", DeploymentsDriverSQLite, DeploymentsDriverPostgres, DeploymentsDriverMemory)
+	}
+	if c.Scheduler.LeaseTTL < time.Second {
+		return fmt.Errorf("scheduler.lease_ttl must be at least 1s")
+	}
 	return nil
 }
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestReloadWorkerCapabilitiesPrefersFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		env          string
+		wantDisabled []string
+		wantDrivers  []string
+	}{
+		{"defaults", "", "", nil, []string{"script", "compose"}},
+		{"environment", "", "dns", []string{"dns"}, []string{"script", "compose"}},
+		{"file over environment", "worker:\n  disabled: [notifier]\n", "dns", []string{"notifier"}, []string{"script", "compose"}},
+		{"empty list in file", "worker:\n  disabled: []\n  drivers: [script]\n", "dns", []string{}, []string{"script"}},
+		{"file without the list", "worker:\n  urls: [http://worker:8080]\n", "dns", []string{"dns"}, []string{"script", "compose"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			t.Setenv("WORKER_DRIVERS", "")
+			t.Setenv("WORKER_DISABLED", tt.env)
+			if tt.file != "" {
+				if err := os.WriteFile("config.yaml", []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			worker, err := ReloadWorkerCapabilities()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(worker.Disabled, tt.wantDisabled) || !slices.Equal(worker.Drivers, tt.wantDrivers) {
+				t.Errorf("disabled %v and drivers %v, want %v and %v", worker.Disabled, worker.Drivers, tt.wantDisabled, tt.wantDrivers)
+			}
+		})
+	}
+}
+
+func TestReloadWorkerCapabilitiesRejectsUnknownCapabilities(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("WORKER_DRIVERS", "")
+	t.Setenv("WORKER_DISABLED", "")
+	if err := os.WriteFile("config.yaml", []byte("worker:\n  disabled: [dsn]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReloadWorkerCapabilities(); err == nil {
+		t.Error("expected an error for an unknown capability")
+	}
+}
@@ -0,0 +1,110 @@
+package diagnosis
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"regexp"
+	"strings"
+)
+
+// Codes of the failure causes Classify recognizes
+const (
+	CodeHostKey         = "host_key_changed"
+	CodeGitAuth         = "git_auth"
+	CodeDiskFull        = "disk_full"
+	CodePortInUse       = "port_in_use"
+	CodeImagePull       = "image_pull"
+	CodeHealthTimeout   = "health_check_timeout"
+	CodeHealthUnhealthy = "health_check_unhealthy"
+)
+
+// rule maps the errors matching pattern to a cause
+type rule struct {
+	pattern *regexp.Regexp
+	cause   domain.FailureCause
+}
+
+// rules are tried in order; the first match wins
+var rules = []rule{
+	{
+		pattern: regexp.MustCompile(`(?i)host key verification failed|host key mismatch`),
+		cause: domain.FailureCause{
+			Code:  CodeHostKey,
+			Cause: "The SSH host key of the deploy host changed",
+			Hint:  "If the host was rebuilt, pin its new key with PUT /api/admin/ssh/host-key; otherwise check the host before deploying to it",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)permission denied \(publickey|authentication failed|repository not found|could not read username`),
+		cause: domain.FailureCause{
+			Code:  CodeGitAuth,
+			Cause: "Git couldn't authenticate to the repository",
+			Hint:  "Check that the deploy key or the REPO_PRIVATE_KEY secret has read access to the repository",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)no space left on device|disk quota exceeded`),
+		cause: domain.FailureCause{
+			Code:  CodeDiskFull,
+			Cause: "The deploy host ran out of disk space",
+			Hint:  "Free up space on the deploy host, e.g. with docker system prune, or drain it and deploy elsewhere",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)address already in use|port is already allocated`),
+		cause: domain.FailureCause{
+			Code:  CodePortInUse,
+			Cause: "A port the service listens on is already used by another process or container",
+			Hint:  "Stop whatever holds the port (see ss -ltnp and docker ps on the deploy host) or change the port of the service",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)pull access denied|manifest unknown|manifest for \S+ not found`),
+		cause: domain.FailureCause{
+			Code:  CodeImagePull,
+			Cause: "A container image couldn't be pulled",
+			Hint:  "Check the image name and tag, and that the deploy host is logged in to the registry",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)CheckHealth(At)?\b.*timeout|health check request failed:.*(timeout|deadline exceeded)`),
+		cause: domain.FailureCause{
+			Code:  CodeHealthTimeout,
+			Cause: "The service didn't answer its health check in time",
+			Hint:  "Check the logs of the service on the deploy host; raise post.health_check.timeout_seconds if it only starts slowly",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)health check returned status \d+`),
+		cause: domain.FailureCause{
+			Code:  CodeHealthUnhealthy,
+			Cause: "The service answered its health check with an error status",
+			Hint:  "Check the health check URL and the logs of the service on the deploy host",
+		},
+	},
+}
+
+// Classify returns the cause of a failure from its error message, or nil if it isn't one of
+// the common failures Classify recognizes
+func Classify(message string) *domain.FailureCause {
+	if message == "" {
+		return nil
+	}
+	for _, r := range rules {
+		if r.pattern.MatchString(message) {
+			cause := r.cause
+			return &cause
+		}
+	}
+	return nil
+}
+
+// Line returns the first line of output with a failure Classify recognizes, or "" if there is
+// none, so errors can carry the line of a long script output that explains them
+func Line(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); Classify(line) != nil {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,15 @@
+package domain
+
+// States of a CI check of a commit
+const (
+	CheckStateSuccess = "success"
+	CheckStatePending = "pending"
+	CheckStateFailure = "failure"
+)
+
+// CommitCheck is a CI check or status reported on a commit
+type CommitCheck struct {
+	Name string `json:"name"`
+	// State is one of the CheckState constants
+	State string `json:"state"`
+}
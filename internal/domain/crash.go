@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// Crash is a panic recovered by the service instead of letting it kill the process
+type Crash struct {
+	// Message is the panic value
+	Message string
+	// Stack is the stack trace of the panicking goroutine
+	Stack string
+	// Tags identify where the panic happened, e.g. the request path or the activity type
+	Tags map[string]string
+	Time time.Time
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // DeployMethod represents the deployment method
 type DeployMethod string
@@ -18,6 +21,46 @@ type DeployRequest struct {
 	Setup    SetupConfig  `json:"setup"`
 	Post     PostActions  `json:"post"`
 	TraceID  string       `json:"trace_id"`
+	// RedeployOf is the trace ID of the deployment this request replays, if any
+	RedeployOf string `json:"redeploy_of,omitempty"`
+	// Host is the deploy host the request runs on; nil runs it on the first host of the group
+	Host *DeployHost `json:"host,omitempty"`
+	// KeepDomain leaves the DNS record of the environment unchanged: a deployment doesn't
+	// set it up and a cleanup doesn't remove it, e.g. while an environment migrates between hosts
+	KeepDomain bool `json:"keep_domain,omitempty"`
+	// Artifacts are the SBOMs and provenance attestations of the deployed build, kept with the deployment record
+	Artifacts []ArtifactRef `json:"artifacts,omitempty"`
+	// Supersedes lists the trace IDs of the running deployments this one takes over from,
+	// found by the API for requests with Setup.Supersede
+	Supersedes []string `json:"supersedes,omitempty"`
+	// EnvironmentPolicy is the catalog entry of Metadata.Environment when the API accepted
+	// the request; nil for requests recorded before the catalog, which the workflow doesn't restrict
+	EnvironmentPolicy *Environment `json:"environment_policy,omitempty"`
+}
+
+// Artifact types
+const (
+	ArtifactTypeSBOM       = "sbom"
+	ArtifactTypeProvenance = "provenance"
+)
+
+// ArtifactRef references a supply-chain artifact of the deployed build, stored by the caller's CI
+type ArtifactRef struct {
+	// Type is "sbom" or "provenance"
+	Type string `json:"type" validate:"required,oneof=sbom provenance"`
+	// URL is where the artifact can be downloaded from
+	URL string `json:"url" validate:"required,url,max=2048"`
+	// Format is the format of the artifact, e.g. "spdx-json", "cyclonedx-json", or "in-toto"
+	Format string `json:"format,omitempty" validate:"omitempty,max=64,printascii"`
+	// Digest is the "sha256:<hex>" digest of the artifact, so auditors can tell it wasn't replaced
+	Digest string `json:"digest,omitempty"`
+}
+
+// DeployHost identifies the deploy host a request is placed on
+type DeployHost struct {
+	Name string `json:"name"`
+	// DNSValue replaces the value of the DNS record set up for the deployment, if not empty
+	DNSValue string `json:"dns_value,omitempty"`
 }
 
 // SourceInfo contains source code information
@@ -30,25 +73,82 @@ type SourceInfo struct {
 	PRTitle   string `json:"pr_title,omitempty"`
 	PRType    string `json:"pr_type,omitempty"`
 	PRPurpose string `json:"pr_purpose,omitempty"`
+	// Author is the GitHub username or email of the commit author, mentioned on failure notifications
+	Author string `json:"author,omitempty"`
+}
+
+// CommitAuthor identifies the author of a commit
+type CommitAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// Login is the GitHub username, empty if the email isn't linked to an account
+	Login string `json:"login,omitempty"`
 }
 
 // MetadataInfo contains deployment metadata
 type MetadataInfo struct {
 	ProjectName string `json:"project_name" validate:"required"`
 	Component   string `json:"component" validate:"required"`
-	Environment string `json:"environment" validate:"required,oneof=snapshot dev stage production"`
+	Environment string `json:"environment" validate:"required"` // Name of an environment of the catalog
 }
 
 // SetupConfig contains setup configuration
 type SetupConfig struct {
 	InjectSecret InjectSecretConfig `json:"inject_secret"`
+	Script       ScriptConfig       `json:"script"`
+	Clone        CloneConfig        `json:"clone"`
+	// Driver selects how the service is deployed (default: script)
+	Driver string `json:"driver,omitempty" validate:"omitempty,oneof=script compose"`
+	// MinimizeDowntime pulls and builds the images of a compose deployment before the running
+	// containers are replaced, so the switch only recreates them
+	MinimizeDowntime bool `json:"minimize_downtime,omitempty"`
+	// WaitForCI holds the deployment until the CI checks of the commit pass
+	WaitForCI CIGateConfig `json:"wait_for_ci"`
+	// Worker restricts the deployment to the workers of a platform, e.g. an arm64 build host
+	Worker WorkerRequirement `json:"worker"`
+	// Supersede stops the running deployments of the same repository, pull request, and
+	// environment after their current step, and takes over from them
+	Supersede bool `json:"supersede,omitempty"`
+}
+
+// CIGateConfig contains the CI checks a deployment waits for
+type CIGateConfig struct {
+	Enable bool `json:"enable"`
+	// RequiredChecks are the names of the checks that must pass; empty requires every check reported on the commit
+	RequiredChecks []string `json:"required_checks,omitempty"`
+	// TimeoutSeconds bounds the wait for pending checks (default: 1800)
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" validate:"omitempty,min=1,max=21600"`
+}
+
+// ScriptConfig contains deploy script configuration
+type ScriptConfig struct {
+	// Interpreter overrides the interpreter declared in the deploy manifest (default: bash)
+	Interpreter string `json:"interpreter,omitempty" validate:"omitempty,oneof=bash sh python node"`
+}
+
+// Clone strategies
+const (
+	CloneStrategySHA    = "sha"
+	CloneStrategyBranch = "branch"
+)
+
+// CloneConfig contains repository checkout configuration
+type CloneConfig struct {
+	// Strategy is "sha" (fetch only the requested commit, default) or "branch" (clone the branch, then check out the commit)
+	Strategy string `json:"strategy,omitempty" validate:"omitempty,oneof=sha branch"`
+	// Depth is the history depth to fetch (default: 1)
+	Depth int `json:"depth,omitempty" validate:"omitempty,min=1,max=100000"`
+	// SingleBranch limits a branch clone to the requested branch (default: true)
+	SingleBranch *bool `json:"single_branch,omitempty"`
+	// Tags fetches tags along with the commit (default: false)
+	Tags bool `json:"tags,omitempty"`
 }
 
 // SecretMapping represents a single secret mapping configuration
 type SecretMapping struct {
-	Path       string `json:"path" validate:"required"`
-	SecretName string `json:"secret_name" validate:"required"`
-	EnvName    string `json:"env_name" validate:"required"`
+	Path       string `json:"path" yaml:"path" validate:"required"`
+	SecretName string `json:"secret_name" yaml:"secret_name" validate:"required"`
+	EnvName    string `json:"env_name" yaml:"env_name" validate:"required"`
 }
 
 // InjectSecretConfig contains Infisical secret injection configuration
@@ -59,11 +159,20 @@ type InjectSecretConfig struct {
 	Secrets     []SecretMapping `json:"secrets,omitempty"`
 }
 
+// FetchedSecrets contains the secrets fetched for a deployment
+type FetchedSecrets struct {
+	// Values maps environment variable names to secret values
+	Values map[string]string `json:"values"`
+	// Checksums maps environment variable names to salted hashes of the values
+	Checksums map[string]string `json:"checksums,omitempty"`
+}
+
 // PostActions contains post-deployment actions
 type PostActions struct {
-	SetupDomain   DomainConfig  `json:"setup_domain"`
-	CleanupDomain DomainConfig  `json:"cleanup_domain"`
-	NotifyDiscord DiscordConfig `json:"notify_discord"`
+	SetupDomain   DomainConfig      `json:"setup_domain"`
+	CleanupDomain DomainConfig      `json:"cleanup_domain"`
+	NotifyDiscord DiscordConfig     `json:"notify_discord"`
+	HealthCheck   HealthCheckConfig `json:"health_check"`
 }
 
 // DomainConfig contains DNS domain configuration
@@ -74,16 +183,174 @@ type DomainConfig struct {
 	Value  string `json:"value,omitempty"`
 }
 
+// HealthCheckConfig contains post-deployment health check configuration
+type HealthCheckConfig struct {
+	Enable         bool   `json:"enable"`
+	URL            string `json:"url,omitempty" validate:"omitempty,url"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" validate:"omitempty,min=1,max=3600"`
+}
+
 // DiscordConfig contains Discord notification configuration
 type DiscordConfig struct {
 	Enable  bool   `json:"enable"`
 	Channel string `json:"channel,omitempty"`
 }
 
+// DeployStatus represents the overall outcome of a deployment
+type DeployStatus string
+
+const (
+	DeployStatusRunning            DeployStatus = "running"
+	DeployStatusSucceeded          DeployStatus = "succeeded"
+	DeployStatusPartiallySucceeded DeployStatus = "partially_succeeded"
+	DeployStatusFailed             DeployStatus = "failed"
+	// DeployStatusSuperseded is a deployment stopped before its script in favor of a newer one
+	DeployStatusSuperseded DeployStatus = "superseded"
+)
+
+// StepStatus represents the outcome of a single deployment step
+type StepStatus string
+
+const (
+	StepStatusSucceeded StepStatus = "succeeded"
+	StepStatusFailed    StepStatus = "failed"
+	StepStatusSkipped   StepStatus = "skipped"
+)
+
+// Deployment step names
+const (
+	StepWaitForCI     = "wait_for_ci"
+	StepApproval      = "approval"
+	StepFetchManifest = "fetch_manifest"
+	StepHostHealth    = "host_health"
+	StepFetchSecrets  = "fetch_secrets"
+	StepRunScript     = "run_script"
+	StepDNS           = "dns"
+	StepHealthCheck   = "health_check"
+	StepNotify        = "notify"
+)
+
+// StepResult represents the result of a single deployment step
+type StepResult struct {
+	Name      string     `json:"name"`
+	Status    StepStatus `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	ErrorType string     `json:"error_type,omitempty"`
+	// Cause explains a failure with a common cause, e.g. a full disk, and how to fix it
+	Cause      *FailureCause `json:"cause,omitempty"`
+	Detail     string        `json:"detail,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+}
+
+// FailureCause is the human-readable cause of a failed step, with a hint to fix it
+type FailureCause struct {
+	// Code identifies the cause, e.g. "disk_full"
+	Code  string `json:"code"`
+	Cause string `json:"cause"`
+	Hint  string `json:"hint"`
+}
+
 // DeployResult represents the result of a deployment
 type DeployResult struct {
-	Success   bool      `json:"success"`
-	Output    string    `json:"output"`
-	Error     string    `json:"error,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
+	TraceID string       `json:"trace_id"`
+	Method  DeployMethod `json:"method"`
+	Status  DeployStatus `json:"status"`
+	Steps   []StepResult `json:"steps"`
+	// SecretChecksums maps injected environment variable names to salted hashes of their values
+	SecretChecksums map[string]string `json:"secret_checksums,omitempty"`
+	// Domain is the DNS record of the deployment, including one left unchanged with KeepDomain
+	Domain *DomainConfig `json:"domain,omitempty"`
+	// CanaryDomain is the per-deployment record set up next to Domain, if canary records are enabled
+	CanaryDomain string    `json:"canary_domain,omitempty"`
+	Output       string    `json:"output,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+	// Receipt is the signed receipt of a completed deployment, if the worker signs receipts
+	Receipt *SignedReceipt `json:"receipt,omitempty"`
+	// Worker is the platform of the worker that ran the deployment
+	Worker *WorkerPlatform `json:"worker,omitempty"`
+	// Supersedes lists the deployments this one took over from
+	Supersedes []string `json:"supersedes,omitempty"`
+	// SupersededBy is the deployment that took over from a superseded one
+	SupersededBy string `json:"superseded_by,omitempty"`
+	// ApprovedBy is who approved a deployment to an environment requiring approval
+	ApprovedBy string `json:"approved_by,omitempty"`
+}
+
+// AddStep appends a step result
+func (r *DeployResult) AddStep(step StepResult) {
+	r.Steps = append(r.Steps, step)
+}
+
+// FailedSteps returns the steps that failed
+func (r *DeployResult) FailedSteps() []StepResult {
+	var failed []StepResult
+	for _, step := range r.Steps {
+		if step.Status == StepStatusFailed {
+			failed = append(failed, step)
+		}
+	}
+	return failed
+}
+
+// ValidRepoName reports whether repo is an "owner/name" GitHub repository. Repository names
+// end up in paths of remote commands, so only letters, digits, '.', '-', and '_' are accepted.
+func ValidRepoName(repo string) bool {
+	owner, name, ok := strings.Cut(repo, "/")
+	return ok && validRepoPart(owner) && validRepoPart(name)
+}
+
+func validRepoPart(part string) bool {
+	if part == "" || part == "." || part == ".." {
+		return false
+	}
+	for _, c := range part {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '.' && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidArtifactDigest reports whether digest is a "sha256:" digest of 64 lowercase hex digits
+func ValidArtifactDigest(digest string) bool {
+	hash, ok := strings.CutPrefix(digest, "sha256:")
+	if !ok || len(hash) != 64 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidEnvName reports whether name can be used as a shell environment variable name
+func ValidEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && c != '_' && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Tombstone replaces a deleted deployment record, so lookups can tell a deleted
+// deployment from one that never existed
+type Tombstone struct {
+	TraceID      string       `json:"trace_id"`
+	Project      string       `json:"project,omitempty"`
+	Component    string       `json:"component,omitempty"`
+	Environment  string       `json:"environment,omitempty"`
+	Commit       string       `json:"commit,omitempty"`
+	DeployStatus DeployStatus `json:"deploy_status,omitempty"`
+	Reason       string       `json:"reason"`
+	DeletedAt    time.Time    `json:"deleted_at"`
+	// PurgedAt is when the workflow history was removed from Temporal; until then the deployment can be restored
+	PurgedAt *time.Time `json:"purged_at,omitempty"`
 }
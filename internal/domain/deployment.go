@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"time"
+)
+
+// States of a deployment as the API accepted it
+const (
+	// DeploymentStateQueued is a deployment waiting for a deploy host, see QueuedDeployment
+	DeploymentStateQueued = "queued"
+	// DeploymentStateStarted is a deployment whose CDWorkflow was started
+	DeploymentStateStarted = "started"
+	// DeploymentStateRejected is a queued deployment the environments catalog rejected
+	// when a deploy host became available
+	DeploymentStateRejected = "rejected"
+)
+
+// Deployment is the record of a deployment the API accepted. It outlives the workflow
+// history in Temporal, which is purged after the retention of the namespace.
+type Deployment struct {
+	TraceID     string       `json:"trace_id"`
+	Project     string       `json:"project"`
+	Component   string       `json:"component"`
+	Environment string       `json:"environment"`
+	Repo        string       `json:"repo"`
+	Branch      string       `json:"branch"`
+	Commit      string       `json:"commit"`
+	PRNumber    string       `json:"pr_number,omitempty"`
+	Method      DeployMethod `json:"method"`
+	// State is DeploymentStateQueued, DeploymentStateStarted, or DeploymentStateRejected
+	State string `json:"state"`
+	// Host is the deploy host the deployment was placed on
+	Host string `json:"host,omitempty"`
+	// Namespace is the Temporal namespace the workflow runs in
+	Namespace  string `json:"namespace,omitempty"`
+	RedeployOf string `json:"redeploy_of,omitempty"`
+	// Request is the request as it was started, e.g. to redeploy it after its history was purged
+	Request   DeployRequest `json:"request"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// NewDeployment returns the record of req in the given state
+func NewDeployment(req DeployRequest, state, namespace string, now time.Time) Deployment {
+	deployment := Deployment{
+		TraceID:     req.TraceID,
+		Project:     req.Metadata.ProjectName,
+		Component:   req.Metadata.Component,
+		Environment: req.Metadata.Environment,
+		Repo:        req.Source.Repo,
+		Branch:      req.Source.Branch,
+		Commit:      req.Source.Commit,
+		PRNumber:    req.Source.PRNumber,
+		Method:      req.Method,
+		State:       state,
+		Namespace:   namespace,
+		RedeployOf:  req.RedeployOf,
+		Request:     req,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if req.Host != nil {
+		deployment.Host = req.Host.Name
+	}
+	return deployment
+}
+
+// DeploymentFilter selects deployment records; empty fields match every record
+type DeploymentFilter struct {
+	Project     string
+	Component   string
+	Environment string
+	Repo        string
+	// CreatedBefore, if set, selects the records created before it
+	CreatedBefore time.Time
+	// Limit is the maximum number of records returned, newest first; 0 returns all
+	Limit int
+}
+
+// Matches reports whether the filter selects deployment
+func (f DeploymentFilter) Matches(deployment Deployment) bool {
+	return (f.Project == "" || f.Project == deployment.Project) &&
+		(f.Component == "" || f.Component == deployment.Component) &&
+		(f.Environment == "" || f.Environment == deployment.Environment) &&
+		(f.Repo == "" || f.Repo == deployment.Repo) &&
+		(f.CreatedBefore.IsZero() || deployment.CreatedAt.Before(f.CreatedBefore))
+}
@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Errors returned when a request is checked against its environment
+var (
+	ErrUnknownEnvironment = errors.New("unknown environment")
+	ErrEnvironmentFrozen  = errors.New("environment is frozen")
+	ErrQuotaExceeded      = errors.New("environment quota exceeded")
+	ErrDomainNotAllowed   = errors.New("domain is outside the DNS suffix of the environment")
+)
+
+// Environment is an entry of the environments catalog. Requests reference it by name in
+// metadata.environment; the API checks them against it and passes it on to the workflow in
+// DeployRequest.EnvironmentPolicy, so a run enforces the catalog as it was when it started.
+type Environment struct {
+	Name string `json:"name"`
+	// Hosts are the deploy hosts of ssh.hosts the environment may be placed on; empty allows every host
+	Hosts []string `json:"hosts,omitempty"`
+	// DNSSuffix, if set, is the domain the DNS records of the environment must be in, e.g. "dev.sdc.nycu.club"
+	DNSSuffix string `json:"dns_suffix,omitempty"`
+	// Quota is the maximum number of deployed instances of the environment, e.g. preview
+	// environments of pull requests; 0 is unlimited
+	Quota int `json:"quota,omitempty"`
+	// RequireApproval holds deployments (not cleanups) until an admin approves them
+	RequireApproval bool `json:"require_approval,omitempty"`
+	// FreezeWindows are the periods deployments (not cleanups) are rejected in
+	FreezeWindows []FreezeWindow `json:"freeze_windows,omitempty"`
+	// Retention is how long the records of finished deployments of the environment are kept,
+	// e.g. "720h"; empty keeps them forever
+	Retention string    `json:"retention,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// FreezeWindow is a period no deployments are made to an environment, e.g. during an event
+type FreezeWindow struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// DefaultEnvironments are the environments of a catalog that was never edited
+func DefaultEnvironments() []Environment {
+	return []Environment{
+		{Name: "snapshot"},
+		{Name: "dev"},
+		{Name: "stage"},
+		{Name: "production"},
+	}
+}
+
+// Validate checks the environment, returning the problems of its fields
+func (e Environment) Validate() error {
+	// Names end up in metadata.environment, so they have its limits
+	if e.Name == "" || len(e.Name) > MaxEnvironmentLength {
+		return fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidRequest, MaxEnvironmentLength)
+	}
+	if problem := checkEnvironment(e.Name); problem != "" {
+		return fmt.Errorf("%w: name %s", ErrInvalidRequest, problem)
+	}
+	if e.Quota < 0 {
+		return fmt.Errorf("%w: quota must not be negative", ErrInvalidRequest)
+	}
+	if strings.HasPrefix(e.DNSSuffix, ".") || strings.HasPrefix(e.DNSSuffix, "*") {
+		return fmt.Errorf("%w: dns_suffix must be a domain name, e.g. dev.example.com", ErrInvalidRequest)
+	}
+	if e.Retention != "" {
+		if retention, err := time.ParseDuration(e.Retention); err != nil || retention <= 0 {
+			return fmt.Errorf("%w: retention must be a positive duration, e.g. 720h", ErrInvalidRequest)
+		}
+	}
+	for i, window := range e.FreezeWindows {
+		if window.Start.IsZero() || window.End.IsZero() || !window.End.After(window.Start) {
+			return fmt.Errorf("%w: freeze_windows[%d] must end after it starts", ErrInvalidRequest, i)
+		}
+	}
+	return nil
+}
+
+// RetentionPeriod returns the retention of the environment and whether it has one
+func (e Environment) RetentionPeriod() (time.Duration, bool) {
+	retention, err := time.ParseDuration(e.Retention)
+	return retention, err == nil && retention > 0
+}
+
+// FrozenAt returns the freeze window the environment is in at t, if any
+func (e Environment) FrozenAt(t time.Time) (FreezeWindow, bool) {
+	for _, window := range e.FreezeWindows {
+		if !t.Before(window.Start) && t.Before(window.End) {
+			return window, true
+		}
+	}
+	return FreezeWindow{}, false
+}
+
+// CheckFrozen returns ErrEnvironmentFrozen if the environment is in a freeze window at t
+func (e Environment) CheckFrozen(t time.Time) error {
+	window, frozen := e.FrozenAt(t)
+	if !frozen {
+		return nil
+	}
+	var reason string
+	if window.Reason != "" {
+		reason = " (" + window.Reason + ")"
+	}
+	return fmt.Errorf("%w: %s until %s%s", ErrEnvironmentFrozen, e.Name, window.End.UTC().Format(time.RFC3339), reason)
+}
+
+// AllowsHost reports whether the environment may be placed on the deploy host with the given name
+func (e Environment) AllowsHost(name string) bool {
+	return len(e.Hosts) == 0 || slices.Contains(e.Hosts, name)
+}
+
+// CheckDomain returns ErrDomainNotAllowed unless name is the DNS suffix of the environment
+// or a subdomain of it
+func (e Environment) CheckDomain(name string) error {
+	if e.DNSSuffix == "" {
+		return nil
+	}
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	suffix := strings.TrimSuffix(strings.ToLower(e.DNSSuffix), ".")
+	if name == suffix || strings.HasSuffix(name, "."+suffix) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is not in %s", ErrDomainNotAllowed, name, suffix)
+}
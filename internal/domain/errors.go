@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error categories wrapped by adapters so activities can decide whether a failure is worth retrying
+var (
+	// ErrUnauthorized means the credentials were rejected; retrying won't help
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrUnavailable means the service couldn't be reached or failed transiently
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrInvalidRequest means the service rejected the request itself, usually because of bad configuration
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// ErrorForStatus returns the error category of an unexpected HTTP response status
+func ErrorForStatus(statusCode int) error {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		return ErrUnavailable
+	default:
+		return ErrInvalidRequest
+	}
+}
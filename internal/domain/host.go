@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoHostAvailable is returned when every host of the deploy host group is draining
+var ErrNoHostAvailable = errors.New("no deploy host available")
+
+// HostDrain marks a deploy host as draining: new deployments are placed on other hosts
+type HostDrain struct {
+	Host      string    `json:"host"`
+	Reason    string    `json:"reason,omitempty"`
+	DrainedAt time.Time `json:"drained_at"`
+}
+
+// Placement records the deploy host an environment is deployed on
+type Placement struct {
+	// Key identifies the environment, see PlacementKey
+	Key  string `json:"key"`
+	Host string `json:"host"`
+	// TraceID is the last deployment of the environment
+	TraceID string `json:"trace_id"`
+	// Request is the last deploy request of the environment, replayed to migrate it
+	Request   DeployRequest `json:"request"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// QueuedDeployment is a deployment waiting for a deploy host to become available
+type QueuedDeployment struct {
+	TraceID  string        `json:"trace_id"`
+	Request  DeployRequest `json:"request"`
+	QueuedAt time.Time     `json:"queued_at"`
+}
+
+// PlacementKey identifies the environment a request deploys.
+// Preview environments of pull requests are keyed by their pull request.
+func PlacementKey(req DeployRequest) string {
+	key := req.Metadata.ProjectName + "/" + req.Metadata.Component + "/" + req.Metadata.Environment
+	if req.Source.PRNumber != "" {
+		key += "/pr-" + req.Source.PRNumber
+	}
+	return key
+}
+
+// HostLoad is the current load of a deploy host
+type HostLoad struct {
+	Host string `json:"host"`
+	// Load1 is the one-minute load average
+	Load1 float64 `json:"load1"`
+	CPUs  int     `json:"cpus"`
+	// Error is set when the load couldn't be queried
+	Error string `json:"error,omitempty"`
+}
+
+// Utilization returns the load average per CPU
+func (l HostLoad) Utilization() float64 {
+	if l.CPUs <= 0 {
+		return l.Load1
+	}
+	return l.Load1 / float64(l.CPUs)
+}
+
+// HostHealth is the resource usage of a deploy host, checked before deploying to it
+type HostHealth struct {
+	Host string `json:"host"`
+	// Load1 is the one-minute load average
+	Load1 float64 `json:"load1"`
+	CPUs  int     `json:"cpus"`
+	// DiskUsage is the fraction of the checked filesystem in use
+	DiskUsage float64 `json:"disk_usage"`
+	// Problems lists why the host is unhealthy; empty when it is healthy
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Healthy reports whether deployments may go to the host
+func (h HostHealth) Healthy() bool {
+	return len(h.Problems) == 0
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on the fields of a deployment request that end up in remote commands, directory
+// names, DNS records, and notifications
+const (
+	MaxRepoLength        = 140 // 39 characters of owner, "/", and 100 of name
+	MaxBranchLength      = 255
+	MaxCommitLength      = 64
+	MaxPRNumberLength    = 10
+	MaxEnvironmentLength = 32
+	MaxNameLength        = 100
+	MaxTitleLength       = 256
+	MaxPlatformLength    = 32
+)
+
+// FieldError reports a field of a deployment request that exceeds its limits
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. "source.branch"
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Reason)
+}
+
+// CheckLimits returns a *FieldError for the first field of the source, metadata, or worker
+// requirement of r that is too long or contains characters it can't
+func (r DeployRequest) CheckLimits() error {
+	checks := []struct {
+		field string
+		value string
+		max   int
+		check func(string) string
+	}{
+		{"source.repo", r.Source.Repo, MaxRepoLength, checkRepo},
+		{"source.branch", r.Source.Branch, MaxBranchLength, checkBranch},
+		{"source.commit", r.Source.Commit, MaxCommitLength, checkPrintable},
+		{"source.pr_number", r.Source.PRNumber, MaxPRNumberLength, checkDigits},
+		{"source.title", r.Source.Title, MaxTitleLength, checkPrintable},
+		{"source.pr_title", r.Source.PRTitle, MaxTitleLength, checkPrintable},
+		{"source.pr_type", r.Source.PRType, MaxNameLength, checkPrintable},
+		{"source.pr_purpose", r.Source.PRPurpose, MaxTitleLength, checkPrintable},
+		{"source.author", r.Source.Author, MaxTitleLength, checkPrintable},
+		{"metadata.project_name", r.Metadata.ProjectName, MaxNameLength, checkPrintable},
+		{"metadata.component", r.Metadata.Component, MaxNameLength, checkPrintable},
+		{"metadata.environment", r.Metadata.Environment, MaxEnvironmentLength, checkEnvironment},
+		{"setup.worker.os", r.Setup.Worker.OS, MaxPlatformLength, checkPlatform},
+		{"setup.worker.arch", r.Setup.Worker.Arch, MaxPlatformLength, checkPlatform},
+		{"setup.worker.region", r.Setup.Worker.Region, MaxPlatformLength, checkPlatform},
+	}
+	for _, c := range checks {
+		if len(c.value) > c.max {
+			return &FieldError{Field: c.field, Reason: fmt.Sprintf("is longer than %d characters", c.max)}
+		}
+		if c.value == "" {
+			continue
+		}
+		if reason := c.check(c.value); reason != "" {
+			return &FieldError{Field: c.field, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// checkRepo accepts "owner/name" repositories
+func checkRepo(repo string) string {
+	if !ValidRepoName(repo) {
+		return "must be an owner/name repository of letters, digits, '.', '-', and '_'"
+	}
+	return ""
+}
+
+// checkBranch accepts the branch names git accepts, except those starting with '-'
+func checkBranch(branch string) string {
+	if reason := checkPrintable(branch); reason != "" {
+		return reason
+	}
+	if i := strings.IndexAny(branch, " ~^:?*[\\"); i >= 0 {
+		return fmt.Sprintf("contains invalid character %q", branch[i])
+	}
+	switch {
+	case strings.HasPrefix(branch, "-"), strings.HasPrefix(branch, "/"):
+		return "must not start with '-' or '/'"
+	case strings.HasSuffix(branch, "/"), strings.HasSuffix(branch, "."), strings.HasSuffix(branch, ".lock"):
+		return "must not end with '/', '.', or '.lock'"
+	case strings.Contains(branch, ".."), strings.Contains(branch, "//"), strings.Contains(branch, "@{"):
+		return "must not contain '..', '//', or '@{'"
+	}
+	return ""
+}
+
+// checkDigits accepts decimal numbers
+func checkDigits(value string) string {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return "must be a number"
+		}
+	}
+	return ""
+}
+
+// checkEnvironment accepts lowercase names of letters, digits, '-', and '_'
+func checkEnvironment(environment string) string {
+	for _, c := range environment {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+			return fmt.Sprintf("contains invalid character %q", c)
+		}
+	}
+	return ""
+}
+
+// checkPlatform accepts the names of operating systems, architectures, and regions, which
+// end up in task queue names: lowercase letters, digits, '-', and '_'
+func checkPlatform(name string) string {
+	return checkEnvironment(name)
+}
+
+// checkPrintable accepts valid UTF-8 text without control characters such as newlines
+func checkPrintable(value string) string {
+	if !utf8.ValidString(value) {
+		return "must be valid UTF-8"
+	}
+	for _, c := range value {
+		if unicode.IsControl(c) {
+			return fmt.Sprintf("contains control character %q", c)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+// Deployment drivers
+const (
+	DriverScript  = "script"
+	DriverCompose = "compose"
+)
+
+// DeployManifest represents the .deploy/<env>/manifest.yaml file of a repository
+type DeployManifest struct {
+	Interpreter string `yaml:"interpreter" json:"interpreter,omitempty"`
+	Driver      string `yaml:"driver" json:"driver,omitempty"`
+	// MinimizeDowntime prepares the new version before the old one is stopped, see SetupConfig
+	MinimizeDowntime bool                `yaml:"minimize_downtime" json:"minimize_downtime,omitempty"`
+	Secrets          ManifestSecrets     `yaml:"secrets" json:"secrets"`
+	HealthCheck      ManifestHealthCheck `yaml:"health_check" json:"health_check"`
+	Domain           ManifestDomain      `yaml:"domain" json:"domain"`
+}
+
+// ManifestSecrets declares the secrets required by a deployment
+type ManifestSecrets struct {
+	Project     string          `yaml:"project" json:"project,omitempty"`
+	Environment string          `yaml:"environment" json:"environment,omitempty"`
+	Mappings    []SecretMapping `yaml:"mappings" json:"mappings,omitempty"`
+}
+
+// ManifestHealthCheck declares the health check of a deployment
+type ManifestHealthCheck struct {
+	URL     string        `yaml:"url" json:"url,omitempty"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout,omitempty"`
+}
+
+// ManifestDomain declares the DNS record of a deployment
+// Template is rendered with text/template, e.g. "pr-{{.PRNumber}}.{{.ProjectName}}.sdc.nycu.club"
+type ManifestDomain struct {
+	Template string `yaml:"template" json:"template,omitempty"`
+	Value    string `yaml:"value" json:"value,omitempty"`
+	// Name is the rendered template, filled in by the service
+	Name string `yaml:"-" json:"name,omitempty"`
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrThreadNotFound is returned when a notification thread was deleted
+var ErrThreadNotFound = errors.New("notification thread not found")
+
+// NotificationThread records the thread the notifications of a deployment are posted in
+type NotificationThread struct {
+	// Key identifies the deployment, see NotificationThreadKey
+	Key       string    `json:"key"`
+	ThreadID  string    `json:"thread_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotificationThreadKey identifies the thread of a deployment.
+// Preview environments share one thread per pull request; other deployments get their own.
+func NotificationThreadKey(req DeployRequest) string {
+	if req.Source.PRNumber != "" {
+		return req.Source.Repo + "#" + req.Source.PRNumber
+	}
+	return req.TraceID
+}
+
+// NotificationThreadName returns the name of a new thread for a deployment
+func NotificationThreadName(req DeployRequest) string {
+	if req.Source.PRNumber != "" {
+		name := req.Source.Repo + "#" + req.Source.PRNumber
+		if req.Source.PRTitle != "" {
+			name += " " + req.Source.PRTitle
+		}
+		return name
+	}
+	name := req.Metadata.ProjectName + "/" + req.Metadata.Component + " (" + req.Metadata.Environment + ")"
+	if req.Source.Commit != "" {
+		name += " " + shortCommit(req.Source.Commit)
+	}
+	return name
+}
+
+// shortCommit abbreviates a commit hash
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+// Notification channels deployments can be routed to
+const (
+	NotificationChannelDiscord = "discord"
+	NotificationChannelTeams   = "teams"
+)
+
+// Kinds of deployment notifications, filtered by the notification verbosity of the environment
+const (
+	NotificationKindStart    = "start"
+	NotificationKindProgress = "progress"
+	NotificationKindFinish   = "finish"
+	NotificationKindFailure  = "failure"
+)
+
+// ErrNotificationFailureNotFound is returned when a failed notification isn't stored
+var ErrNotificationFailureNotFound = errors.New("notification failure not found")
+
+// NotificationFailure records a notification that couldn't be delivered, so it can be
+// reviewed and resent once the channel works again
+type NotificationFailure struct {
+	// ID identifies the notification of a deployment, see NotificationFailureID
+	ID      string `json:"id"`
+	Channel string `json:"channel"`
+	TraceID string `json:"trace_id,omitempty"`
+
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	Success  bool              `json:"success"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Mentions []string          `json:"mentions,omitempty"`
+	// Request is the deployment the notification is about, used to find its thread on retry
+	Request DeployRequest `json:"request"`
+
+	// Error is the error of the last delivery attempt
+	Error         string    `json:"error"`
+	Attempts      int       `json:"attempts"`
+	FirstFailedAt time.Time `json:"first_failed_at"`
+	LastFailedAt  time.Time `json:"last_failed_at"`
+
+	// Worker is the base URL of the worker storing the failure, set by the API
+	Worker string `json:"worker,omitempty"`
+}
+
+// NotificationFailureID identifies a notification of a deployment. Failed attempts to
+// deliver the same notification share an ID.
+func NotificationFailureID(channel, traceID, title string) string {
+	sum := sha256.Sum256([]byte(channel + "\x00" + traceID + "\x00" + title))
+	return hex.EncodeToString(sum[:8])
+}
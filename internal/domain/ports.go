@@ -1,6 +1,13 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrFileNotFound is returned by RepositoryProvider when the requested file doesn't exist
+var ErrFileNotFound = errors.New("file not found")
 
 // SecretManager interface for managing secrets from Infisical
 type SecretManager interface {
@@ -15,10 +22,24 @@ type SecretManager interface {
 
 // SSHExecutor interface for executing SSH operations
 type SSHExecutor interface {
-	// Execute executes a command on a remote host via SSH
+	// Execute runs command on a remote host via SSH as a bash script that stops at the first failing line
 	Execute(ctx context.Context, host string, user string, privateKey []byte, command string, envVars map[string]string) (string, error)
 }
 
+// HostKeyManager interface for managing pinned SSH host keys
+type HostKeyManager interface {
+	// PinHostKey pins publicKey (authorized_keys format) for host, keeping the old key
+	// accepted for the grace period
+	PinHostKey(ctx context.Context, host, publicKey string, grace time.Duration) error
+}
+
+// CapabilityManager interface for turning off the capabilities and drivers of workers at runtime
+type CapabilityManager interface {
+	// SetCapabilities turns off the disabled capabilities ("dns" and "notifier") and accepts
+	// only drivers; nil drivers keeps the accepted drivers
+	SetCapabilities(ctx context.Context, disabled, drivers []string) error
+}
+
 // DNSProvider interface for managing DNS records
 type DNSProvider interface {
 	// EnsureRecord ensures a DNS A record exists with the given domain and IP
@@ -28,8 +49,224 @@ type DNSProvider interface {
 	RemoveRecord(ctx context.Context, domain string) error
 }
 
-// Notifier interface for sending notifications
+// CanaryDNSProvider interface for managing the per-deployment records set up next to a stable record
+type CanaryDNSProvider interface {
+	// EnsureCanaryRecord ensures the canary A record name of the stable record exists with the given IP,
+	// replacing the earlier canary records of the stable record
+	EnsureCanaryRecord(ctx context.Context, name, ip, stable string) error
+
+	// RemoveCanaryRecords removes every canary record of the stable record
+	RemoveCanaryRecords(ctx context.Context, stable string) error
+}
+
+// TrafficAnalytics interface for reading how many requests the records of the DNS zone received
+type TrafficAnalytics interface {
+	// CountRequests returns the number of requests to each of the hostnames in [since, until);
+	// hostnames without requests are missing from the result
+	CountRequests(ctx context.Context, hostnames []string, since, until time.Time) (map[string]int64, error)
+}
+
+// Notifier interface for sending notifications.
+// Mentions are the user IDs of the notifier to ping with the notification.
 type Notifier interface {
 	// SendNotification sends a notification with the given message and status
-	SendNotification(ctx context.Context, title, message string, success bool, metadata map[string]string) error
+	SendNotification(ctx context.Context, title, message string, success bool, metadata map[string]string, mentions []string) error
+}
+
+// NotificationTracker interface for tracking whether notifications have been acknowledged
+type NotificationTracker interface {
+	// SendTrackedNotification sends a notification and returns its ID for acknowledgement tracking
+	SendTrackedNotification(ctx context.Context, title, message string, success bool, metadata map[string]string, mentions []string) (string, error)
+
+	// IsAcknowledged reports whether someone acknowledged the notification
+	IsAcknowledged(ctx context.Context, notificationID string) (bool, error)
+
+	// SendReminder re-pings about an unacknowledged notification
+	SendReminder(ctx context.Context, notificationID, message string) error
+}
+
+// ThreadNotifier interface for sending the notifications of a deployment into its own thread.
+// Notification IDs returned can be tracked by the NotificationTracker.
+type ThreadNotifier interface {
+	// StartThread creates a thread named name with the notification as its first message.
+	// Returns the thread ID and the notification ID.
+	StartThread(ctx context.Context, name, title, message string, success bool, metadata map[string]string, mentions []string) (string, string, error)
+
+	// SendThreadNotification posts a notification into a thread and returns the notification ID.
+	// Returns ErrThreadNotFound if the thread was deleted.
+	SendThreadNotification(ctx context.Context, threadID, title, message string, success bool, metadata map[string]string, mentions []string) (string, error)
+}
+
+// ThreadStore interface for storing the notification thread of each deployment
+type ThreadStore interface {
+	// GetThread returns the thread of a deployment and whether it exists
+	GetThread(ctx context.Context, key string) (NotificationThread, bool, error)
+
+	// PutThread creates or replaces the thread of a deployment
+	PutThread(ctx context.Context, thread NotificationThread) error
+}
+
+// HealthChecker interface for checking that an external service is reachable with the configured credentials
+type HealthChecker interface {
+	// CheckHealth returns an error if the service can't be used
+	CheckHealth(ctx context.Context) error
+}
+
+// WorkerFleet interface for discovering the capabilities of the workers
+type WorkerFleet interface {
+	// ListWorkers returns the status of every known worker
+	ListWorkers(ctx context.Context) []WorkerStatus
+}
+
+// HostStore interface for storing the drain state of deploy hosts and the placement of environments
+type HostStore interface {
+	// GetDrain returns the drain of a host and whether the host is draining
+	GetDrain(ctx context.Context, host string) (HostDrain, bool, error)
+	// PutDrain marks a host as draining
+	PutDrain(ctx context.Context, drain HostDrain) error
+	// DeleteDrain marks a host as available again
+	DeleteDrain(ctx context.Context, host string) error
+	// ListDrains returns the draining hosts
+	ListDrains(ctx context.Context) ([]HostDrain, error)
+
+	// GetPlacement returns the placement of an environment and whether it exists
+	GetPlacement(ctx context.Context, key string) (Placement, bool, error)
+	// PutPlacement creates or replaces the placement of an environment
+	PutPlacement(ctx context.Context, placement Placement) error
+	// DeletePlacement removes the placement of an environment
+	DeletePlacement(ctx context.Context, key string) error
+	// ListPlacements returns the placements of all environments
+	ListPlacements(ctx context.Context) ([]Placement, error)
+	// ReservePlacement creates or replaces placement unless quota other environments of its
+	// environment are placed already, checking and writing atomically, and reports whether it did
+	ReservePlacement(ctx context.Context, placement Placement, quota int) (bool, error)
+
+	// QueueDeployment stores a deployment until a host becomes available
+	QueueDeployment(ctx context.Context, queued QueuedDeployment) error
+	// StartQueuedDeployment passes the oldest queued deployment to start and removes it from the
+	// queue once start succeeds, and reports whether there was one. The deployment is claimed
+	// while start runs, so concurrent callers get the next one, and it stays queued if start fails.
+	StartQueuedDeployment(ctx context.Context, start func(QueuedDeployment) error) (bool, error)
+	// ListQueuedDeployments returns the queued deployments, oldest first
+	ListQueuedDeployments(ctx context.Context) ([]QueuedDeployment, error)
+}
+
+// LoadProbe interface for querying the load of a deploy host
+type LoadProbe interface {
+	// QueryLoad returns the current load of the host at address (host:port)
+	QueryLoad(ctx context.Context, address string) (HostLoad, error)
+}
+
+// HostHealthProbe interface for querying the resource usage of a deploy host
+type HostHealthProbe interface {
+	// QueryHealth returns the load of the host at address (host:port) and the usage of the
+	// filesystem mounted at mountpoint; Problems is left to the caller
+	QueryHealth(ctx context.Context, address, mountpoint string) (HostHealth, error)
+}
+
+// DeploymentStore interface for storing the records of the deployments the API accepted
+type DeploymentStore interface {
+	// SaveDeployment creates or replaces the record of a deployment. A replaced record
+	// keeps its CreatedAt.
+	SaveDeployment(ctx context.Context, deployment Deployment) error
+	// GetDeployment returns the record of a deployment and whether it exists
+	GetDeployment(ctx context.Context, traceID string) (Deployment, bool, error)
+	// ListDeployments returns the records the filter selects, newest first
+	ListDeployments(ctx context.Context, filter DeploymentFilter) ([]Deployment, error)
+	// DeleteDeployment removes the record of a deployment
+	DeleteDeployment(ctx context.Context, traceID string) error
+	// Close releases the resources of the store
+	Close() error
+}
+
+// LeaseStore interface for the leases electing the API replica that runs a background job
+type LeaseStore interface {
+	// AcquireLease takes the lease with the given name for holder, or renews it if holder already
+	// holds it, until ttl from now. It reports whether holder holds the lease; it fails to take a
+	// lease another holder holds until that one expires.
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// ReleaseLease gives up the lease with the given name if holder holds it
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// EnvironmentStore interface for storing the environments catalog
+type EnvironmentStore interface {
+	// GetEnvironment returns the environment with the given name and whether it exists
+	GetEnvironment(ctx context.Context, name string) (Environment, bool, error)
+	// PutEnvironment creates or replaces an environment
+	PutEnvironment(ctx context.Context, environment Environment) error
+	// DeleteEnvironment removes an environment
+	DeleteEnvironment(ctx context.Context, name string) error
+	// ListEnvironments returns the environments, ordered by name
+	ListEnvironments(ctx context.Context) ([]Environment, error)
+}
+
+// TombstoneStore interface for storing tombstones of deleted deployment records
+type TombstoneStore interface {
+	// GetTombstone returns the tombstone of a deployment and whether it exists
+	GetTombstone(ctx context.Context, traceID string) (Tombstone, bool, error)
+
+	// PutTombstone creates or replaces the tombstone of a deployment
+	PutTombstone(ctx context.Context, tombstone Tombstone) error
+
+	// DeleteTombstone removes the tombstone of a deployment
+	DeleteTombstone(ctx context.Context, traceID string) error
+
+	// ListTombstones returns all tombstones
+	ListTombstones(ctx context.Context) ([]Tombstone, error)
+}
+
+// RepositoryProvider interface for reading repository content from a Git provider
+type RepositoryProvider interface {
+	// FetchFile fetches the raw content of a file in a repository at the given ref
+	FetchFile(ctx context.Context, repo, ref, path string) ([]byte, error)
+
+	// FetchCommitAuthor returns the author of a commit
+	FetchCommitAuthor(ctx context.Context, repo, sha string) (CommitAuthor, error)
+}
+
+// CommitStatusProvider interface for reading the CI results of commits from a Git provider
+type CommitStatusProvider interface {
+	// FetchCommitChecks returns the CI checks and statuses reported on a commit
+	FetchCommitChecks(ctx context.Context, repo, sha string) ([]CommitCheck, error)
+}
+
+// ReceiptSigner interface for signing deployment receipts
+type ReceiptSigner interface {
+	// Sign returns the signature of payload
+	Sign(payload []byte) ([]byte, error)
+
+	// PublicKey returns the key verifying the signatures
+	PublicKey() []byte
+}
+
+// CrashReporter interface for reporting recovered panics to an error tracker
+type CrashReporter interface {
+	// ReportCrash sends a recovered panic to the error tracker
+	ReportCrash(ctx context.Context, crash Crash) error
+}
+
+// NotificationFailureStore interface for storing notifications that couldn't be delivered
+type NotificationFailureStore interface {
+	// RecordFailure stores a failed delivery, counting the attempts of a notification
+	RecordFailure(ctx context.Context, failure NotificationFailure) error
+
+	// GetFailure returns a failed notification, or ErrNotificationFailureNotFound
+	GetFailure(ctx context.Context, id string) (NotificationFailure, error)
+
+	// DeleteFailure removes a failed notification once it was delivered
+	DeleteFailure(ctx context.Context, id string) error
+
+	// ListFailures returns the failed notifications, most recent first
+	ListFailures(ctx context.Context) ([]NotificationFailure, error)
+}
+
+// NotificationFailureSource interface for reading and retrying the failed notifications of the worker fleet
+type NotificationFailureSource interface {
+	// ListNotificationFailures returns the failed notifications stored by every reachable worker
+	ListNotificationFailures(ctx context.Context) ([]NotificationFailure, error)
+
+	// RetryNotificationFailure resends a failed notification through the worker storing it.
+	// Returns ErrNotificationFailureNotFound if no worker stores it.
+	RetryNotificationFailure(ctx context.Context, id string) error
 }
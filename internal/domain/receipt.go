@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// ReceiptAlgorithm is the signature algorithm of deployment receipts
+const ReceiptAlgorithm = "ed25519"
+
+// DeploymentReceipt records what a completed deployment deployed where
+type DeploymentReceipt struct {
+	TraceID     string       `json:"trace_id"`
+	Method      DeployMethod `json:"method"`
+	Status      DeployStatus `json:"status"`
+	Repo        string       `json:"repo"`
+	Branch      string       `json:"branch"`
+	Commit      string       `json:"commit"`
+	Project     string       `json:"project"`
+	Component   string       `json:"component"`
+	Environment string       `json:"environment"`
+	Host        string       `json:"host,omitempty"`
+	StartedAt   time.Time    `json:"started_at"`
+	FinishedAt  time.Time    `json:"finished_at"`
+	// OutputSHA256 is the hex SHA-256 hash of the output of the deploy script
+	OutputSHA256 string `json:"output_sha256"`
+	// Artifacts are the SBOMs and provenance attestations the deployment request referenced
+	Artifacts []ArtifactRef `json:"artifacts,omitempty"`
+}
+
+// SignedReceipt is a deployment receipt with its signature. Payload holds the exact bytes
+// signed, the JSON encoding of Receipt, so the signature can be verified without
+// re-encoding the receipt.
+type SignedReceipt struct {
+	Receipt   DeploymentReceipt `json:"receipt"`
+	Payload   []byte            `json:"payload"`
+	Algorithm string            `json:"algorithm"`
+	PublicKey []byte            `json:"public_key"`
+	Signature []byte            `json:"signature"`
+}
@@ -0,0 +1,74 @@
+package domain
+
+// Adapter health statuses
+const (
+	AdapterHealthy       = "healthy"
+	AdapterUnhealthy     = "unhealthy"
+	AdapterNotConfigured = "not_configured"
+)
+
+// AdapterHealth reports whether a worker can reach an external service
+type AdapterHealth struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WorkerInfo describes the build and capabilities of a worker
+type WorkerInfo struct {
+	Version    string `json:"version"`
+	CommitHash string `json:"commit_hash"`
+	BuildTime  string `json:"build_time"`
+	GoVersion  string `json:"go_version"`
+	Hostname   string `json:"hostname"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	Region     string `json:"region,omitempty"`
+	TaskQueue  string `json:"task_queue"`
+	// TaskQueues lists every task queue the worker polls, including those of the platform requirements it meets
+	TaskQueues []string        `json:"task_queues"`
+	Namespaces []string        `json:"namespaces"`
+	Workflows  []string        `json:"workflows"`
+	Activities []string        `json:"activities"`
+	Drivers    []string        `json:"drivers"`
+	Disabled   []string        `json:"disabled,omitempty"`
+	Adapters   []AdapterHealth `json:"adapters,omitempty"`
+}
+
+// WorkerStatus is the last known state of a worker in the fleet
+type WorkerStatus struct {
+	URL       string      `json:"url"`
+	Reachable bool        `json:"reachable"`
+	Error     string      `json:"error,omitempty"`
+	Info      *WorkerInfo `json:"info,omitempty"`
+}
+
+// WorkerPlatform describes the platform a worker runs on
+type WorkerPlatform struct {
+	Hostname string `json:"hostname"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Region   string `json:"region,omitempty"`
+}
+
+// WorkerRequirement selects the workers a deployment may run on; empty fields match every worker
+type WorkerRequirement struct {
+	// OS is the operating system of the worker as Go names it, e.g. "linux"
+	OS string `json:"os,omitempty"`
+	// Arch is the architecture of the worker as Go names it, e.g. "amd64" or "arm64"
+	Arch string `json:"arch,omitempty"`
+	// Region is the worker.region the worker is configured with
+	Region string `json:"region,omitempty"`
+}
+
+// IsZero reports whether the requirement matches every worker
+func (r WorkerRequirement) IsZero() bool {
+	return r == WorkerRequirement{}
+}
+
+// Matches reports whether a worker on platform meets the requirement
+func (r WorkerRequirement) Matches(platform WorkerPlatform) bool {
+	return (r.OS == "" || r.OS == platform.OS) &&
+		(r.Arch == "" || r.Arch == platform.Arch) &&
+		(r.Region == "" || r.Region == platform.Region)
+}
@@ -0,0 +1,259 @@
+package encryption
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// Payload metadata of encrypted payloads
+const (
+	MetadataEncoding = "encoding"
+	MetadataKeyID    = "encryption-key-id"
+	// MetadataTenant names the tenant whose key encrypted a payload; payloads without it are
+	// encrypted with a key of their namespace
+	MetadataTenant = "encryption-tenant"
+	// EncodingEncrypted marks a payload holding another payload encrypted with AES-256-GCM
+	EncodingEncrypted = "binary/encrypted"
+)
+
+// keySet is the keys of a namespace or a tenant. Data is encrypted with the first key and
+// decrypted with the key it names, so older keys keep working after a rotation.
+type keySet struct {
+	keyID string
+	keys  map[string]cipher.AEAD
+}
+
+// newKeySet loads the given keys; the first one encrypts
+func newKeySet(keys []config.EncryptionKeyConfig) (*keySet, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no encryption keys")
+	}
+
+	set := &keySet{
+		keyID: keys[0].ID,
+		keys:  make(map[string]cipher.AEAD, len(keys)),
+	}
+	for _, key := range keys {
+		aead, err := loadKey(key.File)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s: %w", key.ID, err)
+		}
+		set.keys[key.ID] = aead
+	}
+	return set, nil
+}
+
+// loadKey reads a base64 encoded AES-256 key from a file
+func loadKey(path string) (cipher.AEAD, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("key in %s is not base64 encoded: %w", path, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key in %s is %d bytes, expected 32", path, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds a ciphertext to the key and, if any, the tenant it was encrypted for,
+// so it can't be passed off as data of another tenant
+func additionalData(tenant, keyID string) []byte {
+	if tenant == "" {
+		return []byte(keyID)
+	}
+	return []byte(tenant + "\x00" + keyID)
+}
+
+// seal encrypts plaintext for tenant with the current key, returning the nonce followed by the ciphertext
+func (s *keySet) seal(tenant string, plaintext []byte) ([]byte, error) {
+	aead := s.keys[s.keyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData(tenant, s.keyID)), nil
+}
+
+// open decrypts data sealed for tenant with the key keyID
+func (s *keySet) open(tenant, keyID string, data []byte) ([]byte, error) {
+	aead, ok := s.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("payload is encrypted with unknown key %q", keyID)
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted payload is too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData(tenant, keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload with key %q: %w", keyID, err)
+	}
+	return plaintext, nil
+}
+
+// keyring holds the keys of each namespace and tenant
+type keyring struct {
+	namespaces map[string]*keySet
+	tenants    map[string]*keySet
+}
+
+// loadKeyring loads the keys of encryptionConfig
+func loadKeyring(encryptionConfig config.EncryptionConfig) (*keyring, error) {
+	ring := &keyring{
+		namespaces: make(map[string]*keySet, len(encryptionConfig.Namespaces)),
+		tenants:    make(map[string]*keySet, len(encryptionConfig.Tenants)),
+	}
+	for namespace, keys := range encryptionConfig.Namespaces {
+		set, err := newKeySet(keys)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+		ring.namespaces[namespace] = set
+	}
+	for tenant, keys := range encryptionConfig.Tenants {
+		set, err := newKeySet(keys)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+		ring.tenants[tenant] = set
+	}
+	return ring, nil
+}
+
+// encryptionKeys returns the keys encrypting the data of tenant in namespace and the tenant
+// they belong to: the keys of tenant if it has any, or else those of namespace, which are
+// nil if it has none either
+func (r *keyring) encryptionKeys(namespace, tenant string) (*keySet, string) {
+	if set, ok := r.tenants[tenant]; ok {
+		return set, tenant
+	}
+	return r.namespaces[namespace], ""
+}
+
+// decryptionKeys returns the keys decrypting data of namespace encrypted for tenant
+func (r *keyring) decryptionKeys(namespace, tenant string) (*keySet, error) {
+	if tenant != "" {
+		set, ok := r.tenants[tenant]
+		if !ok {
+			return nil, fmt.Errorf("payload is encrypted with the keys of tenant %s, which has none configured", tenant)
+		}
+		return set, nil
+	}
+	set, ok := r.namespaces[namespace]
+	if !ok {
+		return nil, fmt.Errorf("payload is encrypted with the keys of namespace %s, which has none configured", namespace)
+	}
+	return set, nil
+}
+
+// Codec encrypts the Temporal payloads of a namespace with the keys of the tenant they belong
+// to, if it has keys, or else with the keys of the namespace. Payloads are decrypted with the
+// key named in their metadata. Payloads written before encryption was enabled, and payloads
+// of a namespace and tenant without keys, are passed through.
+type Codec struct {
+	keys      *keyring
+	namespace string
+	// tenant is the tenant of the workflow or activity the payloads belong to, if known
+	tenant string
+}
+
+// Encode encrypts each payload with the current key of the tenant or namespace
+func (c *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	set, tenant := c.keys.encryptionKeys(c.namespace, c.tenant)
+	if set == nil {
+		return payloads, nil
+	}
+
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, payload := range payloads {
+		plaintext, err := payload.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode payload: %w", err)
+		}
+
+		data, err := set.seal(tenant, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		metadata := map[string][]byte{
+			MetadataEncoding: []byte(EncodingEncrypted),
+			MetadataKeyID:    []byte(set.keyID),
+		}
+		if tenant != "" {
+			metadata[MetadataTenant] = []byte(tenant)
+		}
+		result[i] = &commonpb.Payload{Metadata: metadata, Data: data}
+	}
+	return result, nil
+}
+
+// Decode decrypts the encrypted payloads with the key they were encrypted with
+func (c *Codec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, payload := range payloads {
+		metadata := payload.GetMetadata()
+		if string(metadata[MetadataEncoding]) != EncodingEncrypted {
+			result[i] = payload
+			continue
+		}
+
+		tenant := string(metadata[MetadataTenant])
+		set, err := c.keys.decryptionKeys(c.namespace, tenant)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := set.open(tenant, string(metadata[MetadataKeyID]), payload.GetData())
+		if err != nil {
+			return nil, err
+		}
+
+		decoded := &commonpb.Payload{}
+		if err := decoded.Unmarshal(plaintext); err != nil {
+			return nil, fmt.Errorf("failed to decode decrypted payload: %w", err)
+		}
+		result[i] = decoded
+	}
+	return result, nil
+}
+
+// DataConverters returns the data converter of each namespace of temporalConfig, encrypting
+// its payloads with the keys of their tenant or of the namespace. Without any keys configured,
+// no namespace has one and the default data converter is used.
+func DataConverters(encryptionConfig config.EncryptionConfig, temporalConfig config.TemporalConfig) (map[string]converter.DataConverter, error) {
+	keys, err := loadKeyring(encryptionConfig)
+	if err != nil {
+		return nil, err
+	}
+	converters := make(map[string]converter.DataConverter)
+	if len(keys.namespaces) == 0 && len(keys.tenants) == 0 {
+		return converters, nil
+	}
+
+	namespaces := []string{temporalConfig.Namespace}
+	for _, namespace := range temporalConfig.Namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	for _, namespace := range namespaces {
+		converters[namespace] = newDataConverter(&Codec{keys: keys, namespace: namespace})
+	}
+	return converters, nil
+}
+
+// Ensure Codec implements converter.PayloadCodec
+var _ converter.PayloadCodec = (*Codec)(nil)
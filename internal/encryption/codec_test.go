@@ -0,0 +1,291 @@
+package encryption
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// newTestCodec creates a codec of the namespace deploy for tenant
+func newTestCodec(t *testing.T, encryptionConfig config.EncryptionConfig, tenant string) *Codec {
+	t.Helper()
+	keys, err := loadKeyring(encryptionConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Codec{keys: keys, namespace: "deploy", tenant: tenant}
+}
+
+// encode encodes value with codec into a single payload
+func encode(t *testing.T, codec *Codec, value string) *commonpb.Payload {
+	t.Helper()
+	payload, err := converter.GetDefaultDataConverter().ToPayload(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := codec.Encode([]*commonpb.Payload{payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded[0]
+}
+
+// decode decodes payload with codec back into a string
+func decode(codec *Codec, payload *commonpb.Payload) (string, error) {
+	decoded, err := codec.Decode([]*commonpb.Payload{payload})
+	if err != nil {
+		return "", err
+	}
+	var value string
+	err = converter.GetDefaultDataConverter().FromPayload(decoded[0], &value)
+	return value, err
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	namespaceKey := config.EncryptionKeyConfig{ID: "ns", File: writeKey(t)}
+	tenantKey := config.EncryptionKeyConfig{ID: "app", File: writeKey(t)}
+	encryptionConfig := config.EncryptionConfig{
+		Namespaces: map[string][]config.EncryptionKeyConfig{"deploy": {namespaceKey}},
+		Tenants:    map[string][]config.EncryptionKeyConfig{"app": {tenantKey}},
+	}
+
+	tests := []struct {
+		name       string
+		tenant     string
+		wantKeyID  string
+		wantTenant string
+	}{
+		{"namespace", "", "ns", ""},
+		{"tenant", "app", "app", "app"},
+		{"tenant without keys", "other", "ns", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec := newTestCodec(t, encryptionConfig, tt.tenant)
+			payload := encode(t, codec, "hunter2-secret")
+
+			if bytes.Contains(payload.GetData(), []byte("hunter2-secret")) {
+				t.Fatal("encoded payload contains the plaintext")
+			}
+			metadata := payload.GetMetadata()
+			if string(metadata[MetadataEncoding]) != EncodingEncrypted {
+				t.Errorf("encoding %q, want %q", metadata[MetadataEncoding], EncodingEncrypted)
+			}
+			if string(metadata[MetadataKeyID]) != tt.wantKeyID || string(metadata[MetadataTenant]) != tt.wantTenant {
+				t.Errorf("encrypted with key %q of tenant %q, want key %q of tenant %q", metadata[MetadataKeyID], metadata[MetadataTenant], tt.wantKeyID, tt.wantTenant)
+			}
+
+			// Payloads are decrypted with the key they name, whatever the tenant of the decoder
+			value, err := decode(newTestCodec(t, encryptionConfig, ""), payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != "hunter2-secret" {
+				t.Errorf("decoded %q, want %q", value, "hunter2-secret")
+			}
+		})
+	}
+}
+
+func TestCodecPassesThroughWithoutKeys(t *testing.T) {
+	codec := newTestCodec(t, config.EncryptionConfig{
+		Tenants: map[string][]config.EncryptionKeyConfig{"app": {{ID: "app", File: writeKey(t)}}},
+	}, "other")
+
+	payload := encode(t, codec, "plain")
+	if _, ok := payload.GetMetadata()[MetadataKeyID]; ok {
+		t.Fatal("payload of a namespace and tenant without keys was encrypted")
+	}
+	if value, err := decode(codec, payload); err != nil || value != "plain" {
+		t.Errorf("decoded %q, %v, want %q", value, err, "plain")
+	}
+}
+
+func TestCodecRejectsWrongKeys(t *testing.T) {
+	file := writeKey(t)
+	encryptionConfig := config.EncryptionConfig{
+		Namespaces: map[string][]config.EncryptionKeyConfig{"deploy": {{ID: "k1", File: file}}},
+		Tenants: map[string][]config.EncryptionKeyConfig{
+			"app": {{ID: "k1", File: file}},
+			"web": {{ID: "k1", File: writeKey(t)}},
+		},
+	}
+	codec := newTestCodec(t, encryptionConfig, "app")
+
+	tests := []struct {
+		name string
+		// tamper changes the metadata of a payload of tenant app
+		tamper func(metadata map[string][]byte)
+		codec  *Codec
+	}{
+		{
+			name:   "other tenant",
+			tamper: func(metadata map[string][]byte) { metadata[MetadataTenant] = []byte("web") },
+			codec:  codec,
+		},
+		{
+			// The key of the namespace is the same, so only the additional data tells them apart
+			name:   "tenant removed",
+			tamper: func(metadata map[string][]byte) { delete(metadata, MetadataTenant) },
+			codec:  codec,
+		},
+		{
+			name:   "unknown tenant",
+			tamper: func(metadata map[string][]byte) { metadata[MetadataTenant] = []byte("api") },
+			codec:  codec,
+		},
+		{
+			name:   "unknown key",
+			tamper: func(metadata map[string][]byte) { metadata[MetadataKeyID] = []byte("k2") },
+			codec:  codec,
+		},
+		{
+			name:   "key of another deployment",
+			tamper: func(metadata map[string][]byte) {},
+			codec: newTestCodec(t, config.EncryptionConfig{
+				Tenants: map[string][]config.EncryptionKeyConfig{"app": {{ID: "k1", File: writeKey(t)}}},
+			}, "app"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := encode(t, codec, "secret")
+			tt.tamper(payload.Metadata)
+			if value, err := decode(tt.codec, payload); err == nil {
+				t.Errorf("decoded %q, expected an error", value)
+			}
+		})
+	}
+}
+
+func TestCodecKeyRotation(t *testing.T) {
+	oldKey := config.EncryptionKeyConfig{ID: "old", File: writeKey(t)}
+	newKey := config.EncryptionKeyConfig{ID: "new", File: writeKey(t)}
+	before := newTestCodec(t, config.EncryptionConfig{
+		Tenants: map[string][]config.EncryptionKeyConfig{"app": {oldKey}},
+	}, "app")
+	rotated := newTestCodec(t, config.EncryptionConfig{
+		Tenants: map[string][]config.EncryptionKeyConfig{"app": {newKey, oldKey}},
+	}, "app")
+	removed := newTestCodec(t, config.EncryptionConfig{
+		Tenants: map[string][]config.EncryptionKeyConfig{"app": {newKey}},
+	}, "app")
+
+	old := encode(t, before, "history")
+	if value, err := decode(rotated, old); err != nil || value != "history" {
+		t.Fatalf("payload of the old key isn't readable after a rotation: %q, %v", value, err)
+	}
+	if key := string(encode(t, rotated, "new").GetMetadata()[MetadataKeyID]); key != "new" {
+		t.Errorf("encrypted with key %q after a rotation, want new", key)
+	}
+	if _, err := decode(removed, old); err == nil {
+		t.Error("expected an error decoding a payload of a removed key")
+	}
+}
+
+func TestDataConverterUsesTenantOfContext(t *testing.T) {
+	encryptionConfig := config.EncryptionConfig{
+		Tenants: map[string][]config.EncryptionKeyConfig{"app": {{ID: "app", File: writeKey(t)}}},
+	}
+	converters, err := DataConverters(encryptionConfig, config.TemporalConfig{Namespace: "deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataConverter, ok := converters["deploy"].(workflow.ContextAware)
+	if !ok {
+		t.Fatalf("data converter %T isn't context aware", converters["deploy"])
+	}
+
+	for _, tt := range []struct {
+		ctx       context.Context
+		encrypted bool
+	}{
+		{context.Background(), false},
+		{WithTenant(context.Background(), "app"), true},
+		{WithTenant(context.Background(), "other"), false},
+	} {
+		payload, err := dataConverter.WithContext(tt.ctx).ToPayload("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tenant := tenantFromContext(tt.ctx)
+		if encrypted := string(payload.GetMetadata()[MetadataTenant]) == "app"; encrypted != tt.encrypted {
+			t.Errorf("tenant %q: encrypted %v, want %v", tenant, encrypted, tt.encrypted)
+		}
+		var value string
+		if err := converters["deploy"].FromPayload(payload, &value); err != nil || value != "secret" {
+			t.Errorf("tenant %q: decoded %q, %v", tenant, value, err)
+		}
+	}
+}
+
+func TestDataConvertersWithoutKeys(t *testing.T) {
+	converters, err := DataConverters(config.EncryptionConfig{}, testTemporalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(converters) != 0 {
+		t.Errorf("got data converters for %d namespaces without keys", len(converters))
+	}
+}
+
+// header is a Temporal header the tenant propagator writes to and reads from
+type header map[string]*commonpb.Payload
+
+func (h header) Set(key string, value *commonpb.Payload) { h[key] = value }
+
+func (h header) Get(key string) (*commonpb.Payload, bool) {
+	value, ok := h[key]
+	return value, ok
+}
+
+func (h header) ForEachKey(handler func(string, *commonpb.Payload) error) error {
+	for key, value := range h {
+		if err := handler(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestTenantPropagatorPassesTenantToActivities(t *testing.T) {
+	// The client starting the workflow writes the tenant of its context into the header
+	fields := header{}
+	if err := (TenantPropagator{}).Inject(WithTenant(context.Background(), "app"), fields); err != nil {
+		t.Fatal(err)
+	}
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetContextPropagators(ContextPropagators())
+	env.SetHeader(&commonpb.Header{Fields: fields})
+	env.RegisterActivityWithOptions(func(ctx context.Context) (string, error) {
+		return tenantFromContext(ctx), nil
+	}, activity.RegisterOptions{Name: "tenant"})
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context) ([]string, error) {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+		var tenant string
+		err := workflow.ExecuteActivity(ctx, "tenant").Get(ctx, &tenant)
+		return []string{tenantFromWorkflow(ctx), tenant}, err
+	}, workflow.RegisterOptions{Name: "tenant"})
+
+	env.ExecuteWorkflow("tenant")
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var tenants []string
+	if err := env.GetWorkflowResult(&tenants); err != nil {
+		t.Fatal(err)
+	}
+	if tenants[0] != "app" || tenants[1] != "app" {
+		t.Errorf("workflow has tenant %q and its activity %q, want app", tenants[0], tenants[1])
+	}
+}
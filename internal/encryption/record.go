@@ -0,0 +1,101 @@
+package encryption
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"encoding/json"
+	"fmt"
+)
+
+// RecordCipher encrypts the records the service keeps outside Temporal, such as the deploy
+// requests in the deployment store, with the keys of the tenant the record belongs to, if it
+// has keys, or else of its namespace. An encrypted record is stored as JSON naming its
+// namespace, tenant, and key, so it is decrypted without being told them. Records of
+// namespaces and tenants without keys, and records written before encryption was enabled,
+// are stored and read as plain JSON.
+type RecordCipher struct {
+	keys           *keyring
+	temporalConfig config.TemporalConfig
+}
+
+// encryptedRecord is the stored form of an encrypted record
+type encryptedRecord struct {
+	Encrypted *encryptedValue `json:"encrypted"`
+}
+
+// encryptedValue is a record encrypted with the key KeyID of Tenant, or of Namespace if it has no tenant
+type encryptedValue struct {
+	Namespace string `json:"namespace"`
+	Tenant    string `json:"tenant,omitempty"`
+	KeyID     string `json:"key_id"`
+	// Data is the nonce followed by the ciphertext of the JSON record
+	Data []byte `json:"data"`
+}
+
+// NewRecordCipher creates a record cipher with the keys of each namespace and tenant
+func NewRecordCipher(encryptionConfig config.EncryptionConfig, temporalConfig config.TemporalConfig) (*RecordCipher, error) {
+	keys, err := loadKeyring(encryptionConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordCipher{
+		keys:           keys,
+		temporalConfig: temporalConfig,
+	}, nil
+}
+
+// Namespace returns the namespace the workflows of environment run in, whose keys encrypt
+// the records of the environment
+func (c *RecordCipher) Namespace(environment string) string {
+	if namespace, ok := c.temporalConfig.Namespaces[environment]; ok {
+		return namespace
+	}
+	return c.temporalConfig.Namespace
+}
+
+// Seal encodes v as JSON, encrypted with the current key of tenant, e.g. the project of the
+// record, if it has keys, or else of namespace if it has keys
+func (c *RecordCipher) Seal(namespace, tenant string, v any) (json.RawMessage, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	set, tenant := c.keys.encryptionKeys(namespace, tenant)
+	if set == nil {
+		return plaintext, nil
+	}
+	data, err := set.seal(tenant, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encryptedRecord{Encrypted: &encryptedValue{
+		Namespace: namespace,
+		Tenant:    tenant,
+		KeyID:     set.keyID,
+		Data:      data,
+	}})
+}
+
+// Open decodes a record written by Seal into v, decrypting it if it is encrypted
+func (c *RecordCipher) Open(data []byte, v any) error {
+	var record encryptedRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("failed to decode record: %w", err)
+	}
+	if encrypted := record.Encrypted; encrypted != nil {
+		set, err := c.keys.decryptionKeys(encrypted.Namespace, encrypted.Tenant)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt record: %w", err)
+		}
+		plaintext, err := set.open(encrypted.Tenant, encrypted.KeyID, encrypted.Data)
+		if err != nil {
+			return err
+		}
+		data = plaintext
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package encryption
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeKey writes a new random key to a file and returns its path
+func writeKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testRecord stands in for a record holding sensitive values
+type testRecord struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+}
+
+// testTemporalConfig serves the default namespace deploy and deploy-production for production
+var testTemporalConfig = config.TemporalConfig{
+	Namespace:  "deploy",
+	Namespaces: map[string]string{"production": "deploy-production"},
+}
+
+func newTestCipher(t *testing.T, keys map[string][]config.EncryptionKeyConfig) *RecordCipher {
+	t.Helper()
+	return newTenantTestCipher(t, keys, nil)
+}
+
+func newTenantTestCipher(t *testing.T, keys, tenants map[string][]config.EncryptionKeyConfig) *RecordCipher {
+	t.Helper()
+	cipher, err := NewRecordCipher(config.EncryptionConfig{Namespaces: keys, Tenants: tenants}, testTemporalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher
+}
+
+func TestRecordCipherRoundTrip(t *testing.T) {
+	cipher := newTestCipher(t, map[string][]config.EncryptionKeyConfig{
+		"deploy-production": {{ID: "k1", File: writeKey(t)}},
+	})
+	record := testRecord{Name: "app", Secret: "hunter2-secret"}
+
+	sealed, err := cipher.Seal(cipher.Namespace("production"), "", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte(record.Secret)) {
+		t.Fatalf("sealed record contains the plaintext: %s", sealed)
+	}
+
+	var opened testRecord
+	if err := cipher.Open(sealed, &opened); err != nil {
+		t.Fatal(err)
+	}
+	if opened != record {
+		t.Errorf("got %+v, want %+v", opened, record)
+	}
+}
+
+func TestRecordCipherPlaintextNamespaces(t *testing.T) {
+	cipher := newTestCipher(t, map[string][]config.EncryptionKeyConfig{
+		"deploy-production": {{ID: "k1", File: writeKey(t)}},
+	})
+	record := testRecord{Name: "app", Secret: "staging-secret"}
+
+	// The default namespace has no keys, so its records stay plain JSON
+	sealed, err := cipher.Seal(cipher.Namespace("staging"), "", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(sealed, []byte(record.Secret)) {
+		t.Fatalf("record of a namespace without keys was encrypted: %s", sealed)
+	}
+
+	// Plain records, e.g. written before encryption was enabled, are read as is
+	var opened testRecord
+	if err := cipher.Open([]byte(`{"name":"old","secret":"plain"}`), &opened); err != nil {
+		t.Fatal(err)
+	}
+	if opened.Name != "old" || opened.Secret != "plain" {
+		t.Errorf("unexpected record %+v", opened)
+	}
+}
+
+func TestRecordCipherKeyRotation(t *testing.T) {
+	oldKey := config.EncryptionKeyConfig{ID: "old", File: writeKey(t)}
+	before := newTestCipher(t, map[string][]config.EncryptionKeyConfig{"deploy": {oldKey}})
+	sealed, err := before.Seal("deploy", "", testRecord{Name: "app", Secret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newTestCipher(t, map[string][]config.EncryptionKeyConfig{
+		"deploy": {{ID: "new", File: writeKey(t)}, oldKey},
+	})
+	var opened testRecord
+	if err := rotated.Open(sealed, &opened); err != nil {
+		t.Fatalf("record of the old key isn't readable after a rotation: %v", err)
+	}
+
+	removed := newTestCipher(t, map[string][]config.EncryptionKeyConfig{
+		"deploy": {{ID: "new", File: writeKey(t)}},
+	})
+	if err := removed.Open(sealed, &opened); err == nil {
+		t.Error("expected an error opening a record of a removed key")
+	}
+
+	unconfigured := newTestCipher(t, nil)
+	if err := unconfigured.Open(sealed, &opened); err == nil {
+		t.Error("expected an error opening a record of a namespace without keys")
+	}
+}
+
+func TestRecordCipherTenantKeys(t *testing.T) {
+	namespaceKey := config.EncryptionKeyConfig{ID: "ns", File: writeKey(t)}
+	tenantKey := config.EncryptionKeyConfig{ID: "app", File: writeKey(t)}
+	cipher := newTenantTestCipher(t,
+		map[string][]config.EncryptionKeyConfig{"deploy": {namespaceKey}},
+		map[string][]config.EncryptionKeyConfig{"app": {tenantKey}},
+	)
+	record := testRecord{Name: "app", Secret: "tenant-secret"}
+
+	sealed, err := cipher.Seal("deploy", "app", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored encryptedRecord
+	if err := json.Unmarshal(sealed, &stored); err != nil || stored.Encrypted == nil {
+		t.Fatalf("record isn't encrypted: %s, %v", sealed, err)
+	}
+	if stored.Encrypted.Tenant != "app" || stored.Encrypted.KeyID != "app" {
+		t.Errorf("record encrypted for tenant %q with key %q, want the key app of tenant app", stored.Encrypted.Tenant, stored.Encrypted.KeyID)
+	}
+	var opened testRecord
+	if err := cipher.Open(sealed, &opened); err != nil || opened != record {
+		t.Fatalf("got %+v, %v, want %+v", opened, err, record)
+	}
+
+	// A tenant without keys falls back to the keys of the namespace
+	sealed, err = cipher.Seal("deploy", "other", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored = encryptedRecord{}
+	if err := json.Unmarshal(sealed, &stored); err != nil || stored.Encrypted == nil {
+		t.Fatalf("record isn't encrypted: %s, %v", sealed, err)
+	}
+	if stored.Encrypted.Tenant != "" || stored.Encrypted.KeyID != "ns" {
+		t.Errorf("record encrypted for tenant %q with key %q, want the key ns of the namespace", stored.Encrypted.Tenant, stored.Encrypted.KeyID)
+	}
+
+	// A record of another tenant can't be passed off as one of app by relabeling it
+	sealed, err = cipher.Seal("deploy", "app", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relabeled := bytes.Replace(sealed, []byte(`"tenant":"app"`), []byte(`"tenant":""`), 1)
+	if err := cipher.Open(relabeled, &opened); err == nil {
+		t.Error("expected an error opening a record whose tenant was changed")
+	}
+}
@@ -0,0 +1,134 @@
+package encryption
+
+import (
+	"context"
+
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+// tenantHeader is the Temporal header carrying the tenant of a workflow to its activities and
+// child workflows
+const tenantHeader = "encryption-tenant"
+
+// tenantKey is the context key of the tenant
+type tenantKey struct{}
+
+// WithTenant returns a context whose workflow starts, and the activities and child workflows
+// they run, encrypt their payloads with the keys of tenant, e.g. the project of a deployment
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantFromContext returns the tenant of ctx, or an empty string if it has none
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// tenantFromWorkflow returns the tenant of the workflow of ctx, or an empty string if it has none
+func tenantFromWorkflow(ctx workflow.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// TenantPropagator passes the tenant set with WithTenant from the client starting a workflow
+// to the workflow, and from the workflow to its activities and child workflows. The API and
+// the workers must all use it.
+type TenantPropagator struct{}
+
+// ContextPropagators returns the context propagators of the clients of the API and the workers
+func ContextPropagators() []workflow.ContextPropagator {
+	return []workflow.ContextPropagator{TenantPropagator{}}
+}
+
+// Inject writes the tenant of ctx into the headers
+func (TenantPropagator) Inject(ctx context.Context, writer workflow.HeaderWriter) error {
+	return injectTenant(tenantFromContext(ctx), writer)
+}
+
+// Extract reads the tenant from the headers into the activity context
+func (TenantPropagator) Extract(ctx context.Context, reader workflow.HeaderReader) (context.Context, error) {
+	tenant, err := extractTenant(reader)
+	if err != nil || tenant == "" {
+		return ctx, err
+	}
+	return WithTenant(ctx, tenant), nil
+}
+
+// InjectFromWorkflow writes the tenant of the workflow into the headers
+func (TenantPropagator) InjectFromWorkflow(ctx workflow.Context, writer workflow.HeaderWriter) error {
+	return injectTenant(tenantFromWorkflow(ctx), writer)
+}
+
+// ExtractToWorkflow reads the tenant from the headers into the workflow context
+func (TenantPropagator) ExtractToWorkflow(ctx workflow.Context, reader workflow.HeaderReader) (workflow.Context, error) {
+	tenant, err := extractTenant(reader)
+	if err != nil || tenant == "" {
+		return ctx, err
+	}
+	return workflow.WithValue(ctx, tenantKey{}, tenant), nil
+}
+
+func injectTenant(tenant string, writer workflow.HeaderWriter) error {
+	if tenant == "" {
+		return nil
+	}
+	payload, err := converter.GetDefaultDataConverter().ToPayload(tenant)
+	if err != nil {
+		return err
+	}
+	writer.Set(tenantHeader, payload)
+	return nil
+}
+
+func extractTenant(reader workflow.HeaderReader) (string, error) {
+	payload, ok := reader.Get(tenantHeader)
+	if !ok {
+		return "", nil
+	}
+	var tenant string
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &tenant); err != nil {
+		return "", err
+	}
+	return tenant, nil
+}
+
+// dataConverter encrypts the payloads of the default data converter with a codec, switching
+// the codec to the tenant of the workflow or activity context it is used in
+type dataConverter struct {
+	converter.DataConverter
+	codec *Codec
+}
+
+func newDataConverter(codec *Codec) *dataConverter {
+	return &dataConverter{
+		DataConverter: converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), codec),
+		codec:         codec,
+	}
+}
+
+// WithContext returns the data converter for the tenant of an activity or client context
+func (c *dataConverter) WithContext(ctx context.Context) converter.DataConverter {
+	return c.withTenant(tenantFromContext(ctx))
+}
+
+// WithWorkflowContext returns the data converter for the tenant of a workflow context
+func (c *dataConverter) WithWorkflowContext(ctx workflow.Context) converter.DataConverter {
+	return c.withTenant(tenantFromWorkflow(ctx))
+}
+
+func (c *dataConverter) withTenant(tenant string) converter.DataConverter {
+	if tenant == c.codec.tenant {
+		return c
+	}
+	codec := *c.codec
+	codec.tenant = tenant
+	return newDataConverter(&codec)
+}
+
+// Ensure the data converter is told the workflow and activity contexts
+var (
+	_ workflow.ContextAware      = (*dataConverter)(nil)
+	_ workflow.ContextPropagator = TenantPropagator{}
+)
@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultHostKeyGracePeriod is how long the old host key stays accepted when the request doesn't say
+const defaultHostKeyGracePeriod = 24 * time.Hour
+
+// AdminHandler handles administrative requests
+type AdminHandler struct {
+	temporalClient client.Client
+	catalog        domain.EnvironmentStore
+	// workerHostKeys pins host keys on every worker of the fleet; nil means there is a
+	// single worker, reached through the task queue
+	workerHostKeys domain.HostKeyManager
+	validator      *validator.Validate
+	logger         *zap.Logger
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(temporalClient client.Client, catalog domain.EnvironmentStore, workerHostKeys domain.HostKeyManager, validator *validator.Validate, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		temporalClient: temporalClient,
+		catalog:        catalog,
+		workerHostKeys: workerHostKeys,
+		validator:      validator,
+		logger:         logger,
+	}
+}
+
+// RotateHostKeyRequest represents the host key rotation request payload
+type RotateHostKeyRequest struct {
+	// Host is host:port; empty means the configured deploy host
+	Host      string `json:"host"`
+	PublicKey string `json:"public_key" validate:"required"`
+	// GracePeriodSeconds is how long the old key stays accepted (default 24h)
+	GracePeriodSeconds *int `json:"grace_period_seconds" validate:"omitempty,min=0,max=2592000"`
+}
+
+// RotateHostKeyResponse represents the host key rotation response
+type RotateHostKeyResponse struct {
+	// WorkflowID is the rotation workflow; empty when the key was pinned on the fleet directly
+	WorkflowID  string    `json:"workflow_id,omitempty"`
+	Fingerprint string    `json:"fingerprint"`
+	GraceUntil  time.Time `json:"grace_until"`
+}
+
+// HandleRotateHostKey pins a new SSH host key for the deploy host on every worker.
+// Both the old and the new key are accepted during the grace period.
+func (h *AdminHandler) HandleRotateHostKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	var payload RotateHostKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(payload); err != nil {
+		logger.Error("Request validation failed", zap.Error(err))
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(payload.PublicKey))
+	if err != nil {
+		logger.Error("Invalid public key", zap.Error(err))
+		http.Error(w, "Invalid public key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	grace := defaultHostKeyGracePeriod
+	if payload.GracePeriodSeconds != nil {
+		grace = time.Duration(*payload.GracePeriodSeconds) * time.Second
+	}
+
+	// Every worker keeps its own pinned keys, so a fleet gets the key pinned on each worker;
+	// the workflow would reach only the one worker that picks up its activity
+	if h.workerHostKeys != nil {
+		graceUntil := time.Now().Add(grace)
+		logger = logger.With(zap.String("host", payload.Host))
+		if err := h.workerHostKeys.PinHostKey(ctx, payload.Host, payload.PublicKey, grace); err != nil {
+			logger.Error("Host key rotation failed", zap.Error(err))
+			http.Error(w, "Host key rotation failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		response := RotateHostKeyResponse{
+			Fingerprint: ssh.FingerprintSHA256(key),
+			GraceUntil:  graceUntil,
+		}
+		logger.Info("SSH host key rotated on every worker",
+			zap.String("fingerprint", response.Fingerprint),
+			zap.Time("grace_until", response.GraceUntil),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+		}
+		return
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        "host-key-" + uuid.New().String(),
+		TaskQueue: workflow.TaskQueue,
+	}
+	input := workflow.HostKeyRotationInput{
+		Host:      payload.Host,
+		PublicKey: payload.PublicKey,
+		Grace:     grace,
+	}
+	logger = logger.With(zap.String("workflow_id", workflowOptions.ID), zap.String("host", payload.Host))
+
+	workflowRun, err := h.temporalClient.ExecuteWorkflow(ctx, workflowOptions, workflow.WorkflowHostKeyRotation, input)
+	if err != nil {
+		logger.Error("Failed to start workflow", zap.Error(err))
+		http.Error(w, "Failed to start workflow", http.StatusInternalServerError)
+		return
+	}
+
+	// Rotation is quick, so wait for it and report the pinned fingerprint
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	var result workflow.HostKeyRotationResult
+	if err := workflowRun.Get(waitCtx, &result); err != nil {
+		logger.Error("Host key rotation failed", zap.Error(err))
+		http.Error(w, "Host key rotation failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("SSH host key rotated",
+		zap.String("fingerprint", result.Fingerprint),
+		zap.Time("grace_until", result.GraceUntil),
+	)
+
+	response := RotateHostKeyResponse{
+		WorkflowID:  workflowRun.GetID(),
+		Fingerprint: result.Fingerprint,
+		GraceUntil:  result.GraceUntil,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// TestNotificationRequest represents the test notification request payload
+type TestNotificationRequest struct {
+	// Channel is the notification channel to test; empty sends to the channels the sample
+	// deployment is routed to
+	Channel string `json:"channel" validate:"omitempty,oneof=discord teams"`
+	// Environment, Project, and Repo of the sample deployment select the notification route
+	Environment string `json:"environment"`
+	Project     string `json:"project"`
+	Repo        string `json:"repo"`
+	// Success sends a success notification; the default is a failure notification, which mentions Author
+	Success bool `json:"success"`
+	// Author is the commit author of the sample deployment, to check the mention mapping
+	Author string `json:"author"`
+}
+
+// TestNotificationResponse represents the test notification response
+type TestNotificationResponse struct {
+	WorkflowID string `json:"workflow_id"`
+	Channel    string `json:"channel,omitempty"`
+	Delivered  bool   `json:"delivered"`
+	Error      string `json:"error,omitempty"`
+}
+
+// HandleTestNotification sends a sample notification through a channel, so a new webhook
+// URL or template can be checked without deploying anything
+func (h *AdminHandler) HandleTestNotification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	var payload TestNotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(payload); err != nil {
+		logger.Error("Request validation failed", zap.Error(err))
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if payload.Environment == "" {
+		payload.Environment = "dev"
+	}
+	if _, ok, err := h.catalog.GetEnvironment(ctx, payload.Environment); err != nil {
+		logger.Error("Failed to get environment", zap.Error(err))
+		http.Error(w, "Failed to get environment", http.StatusInternalServerError)
+		return
+	} else if !ok {
+		http.Error(w, fmt.Sprintf("Validation failed: %v %q", domain.ErrUnknownEnvironment, payload.Environment), http.StatusBadRequest)
+		return
+	}
+	if payload.Project == "" {
+		payload.Project = "deployment-service"
+	}
+	if payload.Repo == "" {
+		payload.Repo = "NYCU-SDC/deployment-service"
+	}
+
+	traceID := "notify-test-" + uuid.New().String()
+	input := workflow.TestNotificationInput{
+		Request: domain.DeployRequest{
+			Source: domain.SourceInfo{
+				Title:  "Test notification",
+				Repo:   payload.Repo,
+				Branch: "main",
+				Author: payload.Author,
+			},
+			Method: domain.MethodDeploy,
+			Metadata: domain.MetadataInfo{
+				ProjectName: payload.Project,
+				Component:   "notification-test",
+				Environment: payload.Environment,
+			},
+			TraceID: traceID,
+		},
+		Channel: payload.Channel,
+		Success: payload.Success,
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        traceID,
+		TaskQueue: workflow.TaskQueue,
+	}
+	logger = logger.With(zap.String("workflow_id", workflowOptions.ID), zap.String("channel", payload.Channel))
+
+	workflowRun, err := h.temporalClient.ExecuteWorkflow(ctx, workflowOptions, workflow.WorkflowTestNotification, input)
+	if err != nil {
+		logger.Error("Failed to start workflow", zap.Error(err))
+		http.Error(w, "Failed to start workflow", http.StatusInternalServerError)
+		return
+	}
+
+	response := TestNotificationResponse{
+		WorkflowID: workflowRun.GetID(),
+		Channel:    payload.Channel,
+		Delivered:  true,
+	}
+	status := http.StatusOK
+
+	// Sending is quick, so wait for it and report whether the notification was delivered
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if err := workflowRun.Get(waitCtx, nil); err != nil {
+		logger.Warn("Test notification failed", zap.Error(err))
+		response.Delivered = false
+		response.Error = err.Error()
+		status = http.StatusBadGateway
+	} else {
+		logger.Info("Test notification sent")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// CapabilityHandler turns capabilities and drivers off at runtime: on the worker it runs in,
+// or, in the API, on every worker of the fleet
+type CapabilityHandler struct {
+	// capabilities is the capability switch of the worker, or the fleet client in the API;
+	// nil means the API has no worker URLs to reach the workers through
+	capabilities domain.CapabilityManager
+	logger       *zap.Logger
+}
+
+// NewCapabilityHandler creates a new capability handler
+func NewCapabilityHandler(capabilities domain.CapabilityManager, logger *zap.Logger) *CapabilityHandler {
+	return &CapabilityHandler{
+		capabilities: capabilities,
+		logger:       logger,
+	}
+}
+
+// SetCapabilitiesRequest represents the capabilities request payload
+type SetCapabilitiesRequest struct {
+	// Disabled lists the capabilities to turn off, "dns" and "notifier"; empty turns all of them on
+	Disabled []string `json:"disabled"`
+	// Drivers lists the drivers to accept; omitted keeps the accepted drivers
+	Drivers []string `json:"drivers"`
+}
+
+// HandleSet replaces the disabled capabilities and accepted drivers. Deployments started
+// afterwards use them, until they are set again or the worker rereads its config on SIGHUP.
+func (h *CapabilityHandler) HandleSet(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	if h.capabilities == nil {
+		http.Error(w, "No workers configured; set worker.urls", http.StatusNotImplemented)
+		return
+	}
+
+	var payload SetCapabilitiesRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	disabled := payload.Disabled
+	if disabled == nil {
+		disabled = []string{}
+	}
+	if err := (config.WorkerConfig{Disabled: disabled, Drivers: payload.Drivers}).ValidateCapabilities(); err != nil {
+		logger.Error("Request validation failed", zap.Error(err))
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger = logger.With(zap.Strings("disabled", disabled), zap.Strings("drivers", payload.Drivers))
+	if err := h.capabilities.SetCapabilities(r.Context(), disabled, payload.Drivers); err != nil {
+		logger.Error("Failed to set capabilities", zap.Error(err))
+		status := http.StatusBadGateway
+		if errors.Is(err, domain.ErrInvalidRequest) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, "Failed to set capabilities: "+err.Error(), status)
+		return
+	}
+
+	logger.Info("Capabilities set")
+	w.WriteHeader(http.StatusNoContent)
+}
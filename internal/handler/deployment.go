@@ -0,0 +1,734 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/hosts"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"NYCU-SDC/deployment-service/internal/namespace"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/converter"
+	"go.uber.org/zap"
+)
+
+// DeploymentHandler handles requests that operate on existing deployments
+type DeploymentHandler struct {
+	namespaces  *namespace.Router
+	catalog     domain.EnvironmentStore
+	hosts       *hosts.Selector
+	tombstones  domain.TombstoneStore
+	deployments domain.DeploymentStore
+	validator   *validator.Validate
+	logger      *zap.Logger
+}
+
+// NewDeploymentHandler creates a new deployment handler
+func NewDeploymentHandler(namespaces *namespace.Router, catalog domain.EnvironmentStore, hosts *hosts.Selector, tombstones domain.TombstoneStore, deployments domain.DeploymentStore, validator *validator.Validate, logger *zap.Logger) *DeploymentHandler {
+	return &DeploymentHandler{
+		namespaces:  namespaces,
+		catalog:     catalog,
+		hosts:       hosts,
+		tombstones:  tombstones,
+		deployments: deployments,
+		validator:   validator,
+		logger:      logger,
+	}
+}
+
+// DeploymentStatusResponse represents the deployment status response
+type DeploymentStatusResponse struct {
+	WorkflowID     string               `json:"workflow_id"`
+	RunID          string               `json:"run_id"`
+	TraceID        string               `json:"trace_id"`
+	Namespace      string               `json:"namespace"`
+	WorkflowStatus string               `json:"workflow_status"`
+	Result         *domain.DeployResult `json:"result,omitempty"`
+	// Deployment is the record of a deployment that has no workflow in Temporal
+	Deployment *domain.Deployment `json:"deployment,omitempty"`
+}
+
+// Workflow statuses of deployments known only from their record
+const (
+	workflowStatusQueued   = "Queued"
+	workflowStatusRejected = "Rejected"
+	// workflowStatusUnknown is a started deployment whose history was purged by the namespace retention
+	workflowStatusUnknown = "Unknown"
+)
+
+// DeploymentListResponse represents the deployment list response
+type DeploymentListResponse struct {
+	Deployments []domain.Deployment `json:"deployments"`
+}
+
+// Limits of the deployment list
+const (
+	defaultDeploymentListLimit = 50
+	maxDeploymentListLimit     = 500
+)
+
+// HandleGetStatus returns the status and per-step result of a deployment
+func (h *DeploymentHandler) HandleGetStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID := r.PathValue("trace_id")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("trace_id", traceID),
+	)
+
+	if h.writeTombstone(w, r, logger, traceID) {
+		return
+	}
+
+	workflowID := workflow.CDWorkflowID(traceID)
+	namespace, description, err := h.namespaces.Find(ctx, workflowID)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			h.writeRecordedStatus(ctx, w, logger, traceID)
+			return
+		}
+		logger.Error("Failed to describe workflow", zap.Error(err), zap.String("workflow_id", workflowID))
+		http.Error(w, "Failed to get deployment status", http.StatusInternalServerError)
+		return
+	}
+	temporalClient := h.namespaces.NamespaceClient(namespace)
+
+	info := description.GetWorkflowExecutionInfo()
+	response := DeploymentStatusResponse{
+		WorkflowID:     workflowID,
+		RunID:          info.GetExecution().GetRunId(),
+		TraceID:        traceID,
+		Namespace:      namespace,
+		WorkflowStatus: info.GetStatus().String(),
+	}
+
+	var result domain.DeployResult
+	if info.GetStatus() == enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED {
+		// Completed workflows carry the final result
+		err = temporalClient.GetWorkflow(ctx, workflowID, response.RunID).Get(ctx, &result)
+	} else {
+		// Running or failed workflows expose their progress through the query handler
+		var value converter.EncodedValue
+		value, err = temporalClient.QueryWorkflow(ctx, workflowID, response.RunID, workflow.QueryDeployResult)
+		if err == nil {
+			err = value.Get(&result)
+		}
+	}
+	if err != nil {
+		logger.Warn("Failed to load deployment result", zap.Error(err), zap.String("workflow_id", workflowID))
+	} else {
+		response.Result = &result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// writeRecordedStatus responds with the record of a deployment that has no workflow in Temporal,
+// either because it is still queued or because its history was purged
+func (h *DeploymentHandler) writeRecordedStatus(ctx context.Context, w http.ResponseWriter, logger *zap.Logger, traceID string) {
+	deployment, ok, err := h.deployments.GetDeployment(ctx, traceID)
+	if err != nil {
+		logger.Error("Failed to get deployment record", zap.Error(err))
+		http.Error(w, "Failed to get deployment status", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+
+	response := DeploymentStatusResponse{
+		WorkflowID:     workflow.CDWorkflowID(traceID),
+		TraceID:        traceID,
+		Namespace:      deployment.Namespace,
+		WorkflowStatus: workflowStatusUnknown,
+		Deployment:     &deployment,
+	}
+	switch deployment.State {
+	case domain.DeploymentStateQueued:
+		response.WorkflowStatus = workflowStatusQueued
+	case domain.DeploymentStateRejected:
+		response.WorkflowStatus = workflowStatusRejected
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleList returns the recorded deployments, newest first, optionally filtered by the
+// project, component, environment, and repo query parameters. Soft-deleted deployments are left out.
+func (h *DeploymentHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	query := r.URL.Query()
+	filter := domain.DeploymentFilter{
+		Project:     query.Get("project"),
+		Component:   query.Get("component"),
+		Environment: query.Get("environment"),
+		Repo:        query.Get("repo"),
+		Limit:       defaultDeploymentListLimit,
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxDeploymentListLimit {
+			http.Error(w, fmt.Sprintf("Validation failed: limit must be between 1 and %d", maxDeploymentListLimit), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	deployments, err := h.deployments.ListDeployments(ctx, filter)
+	if err != nil {
+		logger.Error("Failed to list deployments", zap.Error(err))
+		http.Error(w, "Failed to list deployments", http.StatusInternalServerError)
+		return
+	}
+
+	response := DeploymentListResponse{Deployments: []domain.Deployment{}}
+	for _, deployment := range deployments {
+		if _, deleted, err := h.tombstones.GetTombstone(ctx, deployment.TraceID); err != nil {
+			logger.Error("Failed to get tombstone", zap.Error(err), zap.String("trace_id", deployment.TraceID))
+			http.Error(w, "Failed to list deployments", http.StatusInternalServerError)
+			return
+		} else if deleted {
+			continue
+		}
+		response.Deployments = append(response.Deployments, deployment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleGetReceipt returns the signed receipt of a completed deployment. The receipt is
+// returned as the worker signed it; clients verify Signature over Payload with PublicKey.
+func (h *DeploymentHandler) HandleGetReceipt(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID := r.PathValue("trace_id")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("trace_id", traceID),
+	)
+
+	if h.writeTombstone(w, r, logger, traceID) {
+		return
+	}
+
+	workflowID := workflow.CDWorkflowID(traceID)
+	namespace, description, err := h.namespaces.Find(ctx, workflowID)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, "Deployment not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to describe workflow", zap.Error(err), zap.String("workflow_id", workflowID))
+		http.Error(w, "Failed to get deployment receipt", http.StatusInternalServerError)
+		return
+	}
+
+	// Only completed deployments are signed
+	info := description.GetWorkflowExecutionInfo()
+	if info.GetStatus() != enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED {
+		http.Error(w, "Deployment has not completed", http.StatusConflict)
+		return
+	}
+
+	var result domain.DeployResult
+	temporalClient := h.namespaces.NamespaceClient(namespace)
+	if err := temporalClient.GetWorkflow(ctx, workflowID, info.GetExecution().GetRunId()).Get(ctx, &result); err != nil {
+		logger.Error("Failed to load deployment result", zap.Error(err), zap.String("workflow_id", workflowID))
+		http.Error(w, "Failed to get deployment receipt", http.StatusInternalServerError)
+		return
+	}
+	if result.Receipt == nil {
+		http.Error(w, "Deployment has no receipt", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result.Receipt); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// DeploymentArtifactsResponse represents the supply-chain artifacts of a deployment
+type DeploymentArtifactsResponse struct {
+	TraceID     string               `json:"trace_id"`
+	Repo        string               `json:"repo"`
+	Commit      string               `json:"commit"`
+	Project     string               `json:"project"`
+	Component   string               `json:"component"`
+	Environment string               `json:"environment"`
+	Artifacts   []domain.ArtifactRef `json:"artifacts"`
+}
+
+// HandleGetArtifacts returns the SBOMs and provenance attestations the request of a deployment
+// referenced, read from the start of its workflow history
+func (h *DeploymentHandler) HandleGetArtifacts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID := r.PathValue("trace_id")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("trace_id", traceID),
+	)
+
+	if h.writeTombstone(w, r, logger, traceID) {
+		return
+	}
+
+	deployReq, err := h.loadDeployRequest(ctx, traceID)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, "Deployment not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to load deployment request", zap.Error(err))
+		http.Error(w, "Failed to get deployment artifacts", http.StatusInternalServerError)
+		return
+	}
+
+	response := DeploymentArtifactsResponse{
+		TraceID:     traceID,
+		Repo:        deployReq.Source.Repo,
+		Commit:      deployReq.Source.Commit,
+		Project:     deployReq.Metadata.ProjectName,
+		Component:   deployReq.Metadata.Component,
+		Environment: deployReq.Metadata.Environment,
+		Artifacts:   deployReq.Artifacts,
+	}
+	if response.Artifacts == nil {
+		response.Artifacts = []domain.ArtifactRef{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// DeleteDeploymentRequest represents the delete deployment request payload
+type DeleteDeploymentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// HandleDelete soft-deletes a finished deployment. The deployment is hidden behind a tombstone
+// right away, and its history is purged by the retention pruner after the grace period.
+func (h *DeploymentHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID := r.PathValue("trace_id")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("trace_id", traceID),
+	)
+
+	// Body is optional
+	var payload DeleteDeploymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.Reason == "" {
+		payload.Reason = "deleted via api"
+	}
+
+	if h.writeTombstone(w, r, logger, traceID) {
+		return
+	}
+
+	workflowID := workflow.CDWorkflowID(traceID)
+	_, description, err := h.namespaces.Find(ctx, workflowID)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, "Deployment not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to describe workflow", zap.Error(err), zap.String("workflow_id", workflowID))
+		http.Error(w, "Failed to delete deployment", http.StatusInternalServerError)
+		return
+	}
+
+	info := description.GetWorkflowExecutionInfo()
+	if info.GetStatus() == enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		http.Error(w, "Deployment is still running", http.StatusConflict)
+		return
+	}
+
+	tombstone := workflow.DeploymentTombstone(info, payload.Reason, time.Now().UTC())
+	if err := h.tombstones.PutTombstone(ctx, tombstone); err != nil {
+		logger.Error("Failed to store tombstone", zap.Error(err))
+		http.Error(w, "Failed to delete deployment", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Deployment soft-deleted", zap.String("reason", payload.Reason))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tombstone); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleRestore removes the tombstone of a soft-deleted deployment whose history hasn't been purged yet
+func (h *DeploymentHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID := r.PathValue("trace_id")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("trace_id", traceID),
+	)
+
+	tombstone, ok, err := h.tombstones.GetTombstone(ctx, traceID)
+	if err != nil {
+		logger.Error("Failed to load tombstone", zap.Error(err))
+		http.Error(w, "Failed to restore deployment", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Deployment is not deleted", http.StatusNotFound)
+		return
+	}
+	if tombstone.PurgedAt != nil {
+		http.Error(w, "Deployment history has already been purged", http.StatusGone)
+		return
+	}
+
+	if err := h.tombstones.DeleteTombstone(ctx, traceID); err != nil {
+		logger.Error("Failed to delete tombstone", zap.Error(err))
+		http.Error(w, "Failed to restore deployment", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Deployment restored", zap.String("reason", tombstone.Reason))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeTombstone responds with 410 Gone and the tombstone if the deployment was deleted.
+// It reports whether a response was written.
+func (h *DeploymentHandler) writeTombstone(w http.ResponseWriter, r *http.Request, logger *zap.Logger, traceID string) bool {
+	tombstone, ok, err := h.tombstones.GetTombstone(r.Context(), traceID)
+	if err != nil {
+		logger.Error("Failed to load tombstone", zap.Error(err))
+		http.Error(w, "Failed to load deployment", http.StatusInternalServerError)
+		return true
+	}
+	if !ok {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGone)
+	if err := json.NewEncoder(w).Encode(tombstone); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+	return true
+}
+
+// SkipDNSRequest represents the skip DNS request payload
+type SkipDNSRequest struct {
+	Reason string `json:"reason"`
+}
+
+// HandleSkipDNS signals the DNS child workflow of a deployment to skip the DNS step
+func (h *DeploymentHandler) HandleSkipDNS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID := r.PathValue("trace_id")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("trace_id", traceID),
+	)
+
+	// Body is optional
+	var payload SkipDNSRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	workflowID := workflow.DNSWorkflowID(traceID)
+	err := h.signal(ctx, workflowID, workflow.SignalSkipDNS, workflow.DNSSkipSignal{Reason: payload.Reason})
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			logger.Warn("DNS workflow not found or already completed", zap.String("workflow_id", workflowID))
+			http.Error(w, "DNS workflow not found or already completed", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to signal DNS workflow", zap.Error(err), zap.String("workflow_id", workflowID))
+		http.Error(w, "Failed to signal DNS workflow", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("DNS skip signal sent", zap.String("workflow_id", workflowID), zap.String("reason", payload.Reason))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// AckNotificationRequest represents the notification acknowledgement request payload
+type AckNotificationRequest struct {
+	By string `json:"by"`
+}
+
+// HandleAckNotification acknowledges the failure notification of a deployment, which stops the reminders
+func (h *DeploymentHandler) HandleAckNotification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID := r.PathValue("trace_id")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("trace_id", traceID),
+	)
+
+	// Body is optional
+	var payload AckNotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.By == "" {
+		payload.By = "api"
+	}
+
+	workflowID := workflow.NotificationAckWorkflowID(traceID)
+	err := h.signal(ctx, workflowID, workflow.SignalAckNotification, workflow.AckSignal{By: payload.By})
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			logger.Warn("Notification ack workflow not found or already completed", zap.String("workflow_id", workflowID))
+			http.Error(w, "No unacknowledged notification for this deployment", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to signal notification ack workflow", zap.Error(err), zap.String("workflow_id", workflowID))
+		http.Error(w, "Failed to acknowledge notification", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Notification acknowledged", zap.String("workflow_id", workflowID), zap.String("by", payload.By))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ApproveDeploymentRequest represents the deployment approval request payload
+type ApproveDeploymentRequest struct {
+	By string `json:"by"`
+}
+
+// HandleApprove approves a deployment held by an environment requiring approval
+func (h *DeploymentHandler) HandleApprove(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	traceID := r.PathValue("trace_id")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("trace_id", traceID),
+	)
+
+	// Body is optional
+	var payload ApproveDeploymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.By == "" {
+		payload.By = "api"
+	}
+
+	workflowID := workflow.CDWorkflowID(traceID)
+	err := h.signal(ctx, workflowID, workflow.SignalApprove, workflow.ApprovalSignal{By: payload.By})
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			logger.Warn("Deployment not found or already completed", zap.String("workflow_id", workflowID))
+			http.Error(w, "Deployment not found or already completed", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to signal deployment", zap.Error(err), zap.String("workflow_id", workflowID))
+		http.Error(w, "Failed to approve deployment", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Deployment approved", zap.String("workflow_id", workflowID), zap.String("by", payload.By))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// signal signals a running workflow in whichever namespace it runs in
+func (h *DeploymentHandler) signal(ctx context.Context, workflowID, signalName string, arg interface{}) error {
+	namespace, _, err := h.namespaces.Find(ctx, workflowID)
+	if err != nil {
+		return err
+	}
+	return h.namespaces.NamespaceClient(namespace).SignalWorkflow(ctx, workflowID, "", signalName, arg)
+}
+
+// RedeployRequest represents the redeploy request payload
+type RedeployRequest struct {
+	Repo         string `json:"repo" validate:"required"`
+	Environment  string `json:"environment" validate:"required"`
+	DeploymentID string `json:"deployment_id" validate:"required"`
+}
+
+// HandleRedeploy replays the request payload of a historical deployment.
+// Secrets are not part of the payload, so the new run fetches them fresh.
+func (h *DeploymentHandler) HandleRedeploy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	var payload RedeployRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(payload); err != nil {
+		logger.Error("Request validation failed", zap.Error(err))
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	logger = logger.With(zap.String("deployment_id", payload.DeploymentID))
+
+	if h.writeTombstone(w, r, logger, payload.DeploymentID) {
+		return
+	}
+
+	deployReq, err := h.loadDeployRequest(ctx, payload.DeploymentID)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, "Deployment not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to load historical deployment", zap.Error(err))
+		http.Error(w, "Failed to load historical deployment", http.StatusInternalServerError)
+		return
+	}
+
+	if deployReq.Source.Repo != payload.Repo || deployReq.Metadata.Environment != payload.Environment {
+		logger.Warn("Historical deployment does not match repo and environment",
+			zap.String("repo", deployReq.Source.Repo),
+			zap.String("environment", deployReq.Metadata.Environment),
+		)
+		http.Error(w, "Deployment does not belong to the given repo and environment", http.StatusBadRequest)
+		return
+	}
+	if deployReq.Method != domain.MethodDeploy {
+		http.Error(w, "Only deploy requests can be redeployed", http.StatusBadRequest)
+		return
+	}
+
+	// Replay the exact payload under a new trace ID
+	traceID := uuid.New().String()
+	deployReq.TraceID = traceID
+	deployReq.RedeployOf = payload.DeploymentID
+	logger = applog.ForDeployment(logger, deployReq)
+
+	// The request may predate the field limits, so check it like a new one
+	if err := deployReq.CheckLimits(); err != nil {
+		logger.Warn("Historical deployment exceeds the request limits", zap.Error(err))
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	workflowRun, err := startDeployment(ctx, h.namespaces, h.catalog, h.hosts, h.deployments, deployReq, logger)
+	if err != nil {
+		writeStartError(w, logger, err)
+		return
+	}
+
+	if workflowRun == nil {
+		logger.Warn("Every deploy host is draining, redeploy queued", zap.String("commit", deployReq.Source.Commit))
+	} else {
+		logger.Info("Redeploy workflow started",
+			zap.String("workflow_id", workflowRun.GetID()),
+			zap.String("run_id", workflowRun.GetRunID()),
+			zap.String("commit", deployReq.Source.Commit),
+		)
+	}
+
+	response := deploymentResponse(traceID, workflowRun)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// loadDeployRequest reads the original DeployRequest from the workflow start event, or from
+// the record of the deployment once its history was purged
+func (h *DeploymentHandler) loadDeployRequest(ctx context.Context, traceID string) (domain.DeployRequest, error) {
+	var req domain.DeployRequest
+
+	workflowID := workflow.CDWorkflowID(traceID)
+	namespace, _, err := h.namespaces.Find(ctx, workflowID)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		deployment, ok, recordErr := h.deployments.GetDeployment(ctx, traceID)
+		if recordErr != nil {
+			return req, recordErr
+		}
+		if ok && deployment.State == domain.DeploymentStateStarted {
+			return deployment.Request, nil
+		}
+		return req, err
+	}
+	if err != nil {
+		return req, err
+	}
+
+	iter := h.namespaces.NamespaceClient(namespace).GetWorkflowHistory(ctx, workflowID, "", false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	if !iter.HasNext() {
+		return req, fmt.Errorf("workflow history is empty")
+	}
+	event, err := iter.Next()
+	if err != nil {
+		return req, err
+	}
+
+	attributes := event.GetWorkflowExecutionStartedEventAttributes()
+	if attributes == nil {
+		return req, fmt.Errorf("first history event is %s, expected WorkflowExecutionStarted", event.GetEventType())
+	}
+
+	if err := h.namespaces.DataConverter(namespace).FromPayloads(attributes.GetInput(), &req); err != nil {
+		return req, fmt.Errorf("failed to decode workflow input: %w", err)
+	}
+
+	return req, nil
+}
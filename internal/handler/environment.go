@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/hosts"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EnvironmentHandler manages the environments catalog deployments are checked against
+type EnvironmentHandler struct {
+	catalog domain.EnvironmentStore
+	hosts   *hosts.Selector
+	store   domain.HostStore
+	logger  *zap.Logger
+}
+
+// NewEnvironmentHandler creates a new environment handler
+func NewEnvironmentHandler(catalog domain.EnvironmentStore, hosts *hosts.Selector, store domain.HostStore, logger *zap.Logger) *EnvironmentHandler {
+	return &EnvironmentHandler{
+		catalog: catalog,
+		hosts:   hosts,
+		store:   store,
+		logger:  logger,
+	}
+}
+
+// EnvironmentStatus represents an environment of the catalog with its current state
+type EnvironmentStatus struct {
+	domain.Environment
+	// Deployed is the number of deployed instances of the environment, counted against its quota
+	Deployed int `json:"deployed"`
+	// Frozen is the freeze window the environment is in, if any
+	Frozen *domain.FreezeWindow `json:"frozen,omitempty"`
+}
+
+// EnvironmentListResponse represents the environment list response
+type EnvironmentListResponse struct {
+	Environments []EnvironmentStatus `json:"environments"`
+}
+
+// EnvironmentRequest represents the payload creating or replacing an environment;
+// the name is taken from the path
+type EnvironmentRequest struct {
+	Hosts           []string              `json:"hosts"`
+	DNSSuffix       string                `json:"dns_suffix"`
+	Quota           int                   `json:"quota"`
+	RequireApproval bool                  `json:"require_approval"`
+	FreezeWindows   []domain.FreezeWindow `json:"freeze_windows"`
+	Retention       string                `json:"retention"`
+}
+
+// HandleList returns the environments of the catalog
+func (h *EnvironmentHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	environments, err := h.catalog.ListEnvironments(ctx)
+	if err != nil {
+		logger.Error("Failed to list environments", zap.Error(err))
+		http.Error(w, "Failed to list environments", http.StatusInternalServerError)
+		return
+	}
+	deployed, err := h.deployed(ctx)
+	if err != nil {
+		logger.Error("Failed to list placements", zap.Error(err))
+		http.Error(w, "Failed to list environments", http.StatusInternalServerError)
+		return
+	}
+
+	response := EnvironmentListResponse{Environments: []EnvironmentStatus{}}
+	for _, environment := range environments {
+		response.Environments = append(response.Environments, environmentStatus(environment, deployed))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleGet returns an environment of the catalog
+func (h *EnvironmentHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := r.PathValue("name")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("environment", name),
+	)
+
+	environment, ok, err := h.catalog.GetEnvironment(ctx, name)
+	if err != nil {
+		logger.Error("Failed to get environment", zap.Error(err))
+		http.Error(w, "Failed to get environment", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Unknown environment", http.StatusNotFound)
+		return
+	}
+	deployed, err := h.deployed(ctx)
+	if err != nil {
+		logger.Error("Failed to list placements", zap.Error(err))
+		http.Error(w, "Failed to get environment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(environmentStatus(environment, deployed)); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandlePut creates or replaces an environment. Running deployments keep the entry they
+// started with; new deployments, queued ones, and migrations use the new one.
+func (h *EnvironmentHandler) HandlePut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := r.PathValue("name")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("environment", name),
+	)
+
+	var payload EnvironmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	environment := domain.Environment{
+		Name:            name,
+		Hosts:           payload.Hosts,
+		DNSSuffix:       payload.DNSSuffix,
+		Quota:           payload.Quota,
+		RequireApproval: payload.RequireApproval,
+		FreezeWindows:   payload.FreezeWindows,
+		Retention:       payload.Retention,
+		UpdatedAt:       time.Now().UTC(),
+	}
+	if err := h.validate(environment); err != nil {
+		logger.Warn("Environment validation failed", zap.Error(err))
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.catalog.PutEnvironment(ctx, environment); err != nil {
+		logger.Error("Failed to store environment", zap.Error(err))
+		http.Error(w, "Failed to store environment", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Environment stored",
+		zap.Strings("hosts", environment.Hosts),
+		zap.String("dns_suffix", environment.DNSSuffix),
+		zap.Int("quota", environment.Quota),
+		zap.Bool("require_approval", environment.RequireApproval),
+		zap.Int("freeze_windows", len(environment.FreezeWindows)),
+		zap.String("retention", environment.Retention),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(environment); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleDelete removes an environment from the catalog. Environments that are still
+// deployed can't be removed, so their cleanups keep being accepted.
+func (h *EnvironmentHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := r.PathValue("name")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("environment", name),
+	)
+
+	if _, ok, err := h.catalog.GetEnvironment(ctx, name); err != nil {
+		logger.Error("Failed to get environment", zap.Error(err))
+		http.Error(w, "Failed to delete environment", http.StatusInternalServerError)
+		return
+	} else if !ok {
+		http.Error(w, "Unknown environment", http.StatusNotFound)
+		return
+	}
+
+	deployed, err := h.deployed(ctx)
+	if err != nil {
+		logger.Error("Failed to list placements", zap.Error(err))
+		http.Error(w, "Failed to delete environment", http.StatusInternalServerError)
+		return
+	}
+	if count := deployed[name]; count > 0 {
+		http.Error(w, fmt.Sprintf("Environment is still deployed %d times; clean them up first", count), http.StatusConflict)
+		return
+	}
+
+	if err := h.catalog.DeleteEnvironment(ctx, name); err != nil {
+		logger.Error("Failed to delete environment", zap.Error(err))
+		http.Error(w, "Failed to delete environment", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Environment deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validate checks an environment and that its hosts are deploy hosts of the group
+func (h *EnvironmentHandler) validate(environment domain.Environment) error {
+	if err := environment.Validate(); err != nil {
+		return err
+	}
+	for _, host := range environment.Hosts {
+		if _, ok := h.hosts.Lookup(host); !ok {
+			return fmt.Errorf("%w %q", hosts.ErrUnknownHost, host)
+		}
+	}
+	return nil
+}
+
+// deployed counts the deployed instances of each environment
+func (h *EnvironmentHandler) deployed(ctx context.Context) (map[string]int, error) {
+	placements, err := h.store.ListPlacements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deployed := make(map[string]int)
+	for _, placement := range placements {
+		deployed[placement.Request.Metadata.Environment]++
+	}
+	return deployed, nil
+}
+
+// environmentStatus returns the current state of an environment
+func environmentStatus(environment domain.Environment, deployed map[string]int) EnvironmentStatus {
+	status := EnvironmentStatus{
+		Environment: environment,
+		Deployed:    deployed[environment.Name],
+	}
+	if window, frozen := environment.FrozenAt(time.Now()); frozen {
+		status.Frozen = &window
+	}
+	return status
+}
@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.uber.org/zap"
+)
+
+// defaultExportPeriod is the report period when the request doesn't set from
+const defaultExportPeriod = 30 * 24 * time.Hour
+
+// DeploymentRecord is a row of the deployment report
+type DeploymentRecord struct {
+	TraceID         string     `json:"trace_id"`
+	Namespace       string     `json:"namespace"`
+	Project         string     `json:"project"`
+	Component       string     `json:"component"`
+	Environment     string     `json:"environment"`
+	Repo            string     `json:"repo"`
+	Branch          string     `json:"branch"`
+	Commit          string     `json:"commit"`
+	Method          string     `json:"method"`
+	WorkflowStatus  string     `json:"workflow_status"`
+	DeployStatus    string     `json:"deploy_status"`
+	StartedAt       time.Time  `json:"started_at"`
+	ClosedAt        *time.Time `json:"closed_at,omitempty"`
+	DurationSeconds *float64   `json:"duration_seconds,omitempty"`
+}
+
+// deploymentRecordHeader is the CSV header of the deployment report
+var deploymentRecordHeader = []string{
+	"trace_id", "namespace", "project", "component", "environment", "repo", "branch", "commit",
+	"method", "workflow_status", "deploy_status", "started_at", "closed_at", "duration_seconds",
+}
+
+// csvRow returns the record as a CSV row matching deploymentRecordHeader
+func (r DeploymentRecord) csvRow() []string {
+	closedAt, duration := "", ""
+	if r.ClosedAt != nil {
+		closedAt = r.ClosedAt.Format(time.RFC3339)
+	}
+	if r.DurationSeconds != nil {
+		duration = strconv.FormatFloat(*r.DurationSeconds, 'f', 0, 64)
+	}
+	row := []string{
+		r.TraceID, r.Namespace, r.Project, r.Component, r.Environment, r.Repo, r.Branch, r.Commit,
+		r.Method, r.WorkflowStatus, r.DeployStatus, r.StartedAt.Format(time.RFC3339), closedAt, duration,
+	}
+	for i, cell := range row {
+		row[i] = escapeFormula(cell)
+	}
+	return row
+}
+
+// escapeFormula prefixes cells spreadsheets would read as a formula with a quote, since
+// branches and project names come from the callers, e.g. a branch named =HYPERLINK(...)
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// HandleExport streams a report of the deployments started in [from, to) as CSV or JSON.
+// Soft-deleted deployments are left out.
+func (h *DeploymentHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "Invalid format: must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	to := time.Now().UTC()
+	if value := query.Get("to"); value != "" {
+		parsed, err := parseReportTime(value, true)
+		if err != nil {
+			http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-defaultExportPeriod)
+	if value := query.Get("from"); value != "" {
+		parsed, err := parseReportTime(value, false)
+		if err != nil {
+			http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+	logger = logger.With(zap.String("format", format), zap.Time("from", from), zap.Time("to", to))
+
+	visibilityQuery := fmt.Sprintf("WorkflowType = '%s' AND StartTime >= '%s' AND StartTime < '%s'",
+		workflow.WorkflowCD, from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	tombstones, err := h.tombstones.ListTombstones(ctx)
+	if err != nil {
+		logger.Error("Failed to list tombstones", zap.Error(err))
+		http.Error(w, "Failed to list deployments", http.StatusInternalServerError)
+		return
+	}
+	deleted := make(map[string]bool, len(tombstones))
+	for _, tombstone := range tombstones {
+		deleted[tombstone.TraceID] = true
+	}
+
+	// Deployments of each environment are listed from its own namespace
+	namespaces := h.namespaces.Namespaces()
+	listPage := func(index int, nextPageToken []byte) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+		return h.namespaces.NamespaceClient(namespaces[index]).ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         visibilityQuery,
+			NextPageToken: nextPageToken,
+		})
+	}
+
+	// Fetch the first page before writing anything so errors still get a proper status code
+	index := 0
+	resp, err := listPage(index, nil)
+	if err != nil {
+		logger.Error("Failed to list workflows", zap.Error(err))
+		http.Error(w, "Failed to list deployments", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("deployments-%s-%s.%s", from.Format("20060102"), to.Format("20060102"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var writeRecord func(DeploymentRecord) error
+	var finish func() error
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(deploymentRecordHeader); err != nil {
+			logger.Error("Failed to write report", zap.Error(err))
+			return
+		}
+		writeRecord = func(record DeploymentRecord) error {
+			return csvWriter.Write(record.csvRow())
+		}
+		finish = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		first := true
+		if _, err := w.Write([]byte("[")); err != nil {
+			logger.Error("Failed to write report", zap.Error(err))
+			return
+		}
+		writeRecord = func(record DeploymentRecord) error {
+			if !first {
+				if _, err := w.Write([]byte(",")); err != nil {
+					return err
+				}
+			}
+			first = false
+			return encoder.Encode(record)
+		}
+		finish = func() error {
+			_, err := w.Write([]byte("]\n"))
+			return err
+		}
+	}
+
+	count := 0
+	flusher, _ := w.(http.Flusher)
+	for {
+		for _, info := range resp.GetExecutions() {
+			record := deploymentRecordFrom(namespaces[index], info)
+			if deleted[record.TraceID] {
+				continue
+			}
+			if err := writeRecord(record); err != nil {
+				logger.Error("Failed to write report", zap.Error(err))
+				return
+			}
+			count++
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(resp.GetNextPageToken()) > 0 {
+			resp, err = listPage(index, resp.GetNextPageToken())
+		} else if index+1 < len(namespaces) {
+			index++
+			resp, err = listPage(index, nil)
+		} else {
+			break
+		}
+		if err != nil {
+			// Headers are already sent, so the report is cut short
+			logger.Error("Failed to list workflows", zap.Error(err), zap.Int("written", count))
+			return
+		}
+	}
+
+	if err := finish(); err != nil {
+		logger.Error("Failed to write report", zap.Error(err))
+		return
+	}
+	logger.Info("Deployment report exported", zap.Int("count", count))
+}
+
+// deploymentRecordFrom builds a report row from a workflow execution and its memo
+func deploymentRecordFrom(namespace string, info *workflowpb.WorkflowExecutionInfo) DeploymentRecord {
+	memo := info.GetMemo()
+	record := DeploymentRecord{
+		TraceID:        strings.TrimPrefix(info.GetExecution().GetWorkflowId(), workflow.CDWorkflowID("")),
+		Namespace:      namespace,
+		Project:        workflow.MemoValue(memo, workflow.MemoProject),
+		Component:      workflow.MemoValue(memo, workflow.MemoComponent),
+		Environment:    workflow.MemoValue(memo, workflow.MemoEnvironment),
+		Repo:           workflow.MemoValue(memo, workflow.MemoRepo),
+		Branch:         workflow.MemoValue(memo, workflow.MemoBranch),
+		Commit:         workflow.MemoValue(memo, workflow.MemoCommit),
+		Method:         workflow.MemoValue(memo, workflow.MemoMethod),
+		WorkflowStatus: info.GetStatus().String(),
+		DeployStatus:   workflow.MemoValue(memo, workflow.MemoDeployStatus),
+		StartedAt:      info.GetStartTime().AsTime(),
+	}
+
+	if info.GetCloseTime() != nil {
+		closedAt := info.GetCloseTime().AsTime()
+		duration := closedAt.Sub(record.StartedAt).Seconds()
+		record.ClosedAt = &closedAt
+		record.DurationSeconds = &duration
+	}
+
+	return record
+}
+
+// parseReportTime parses an RFC 3339 timestamp or a YYYY-MM-DD date.
+// A date used as the end of a period includes the whole day.
+func parseReportTime(value string, endOfPeriod bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 timestamp or YYYY-MM-DD date")
+	}
+	if endOfPeriod {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeploymentRecordCSVRowEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"main", "main"},
+		{"", ""},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"feature/=x", "feature/=x"},
+	}
+	for _, tt := range tests {
+		record := DeploymentRecord{Branch: tt.branch, StartedAt: time.Unix(0, 0).UTC()}
+		if got := record.csvRow()[6]; got != tt.want {
+			t.Errorf("branch %q: got %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+}
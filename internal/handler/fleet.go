@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"go.uber.org/zap"
+)
+
+// FleetHandler reports the capabilities of the worker fleet
+type FleetHandler struct {
+	fleet  domain.WorkerFleet
+	logger *zap.Logger
+}
+
+// NewFleetHandler creates a new fleet handler
+func NewFleetHandler(fleet domain.WorkerFleet, logger *zap.Logger) *FleetHandler {
+	return &FleetHandler{
+		fleet:  fleet,
+		logger: logger,
+	}
+}
+
+// FleetResponse represents the worker fleet response
+type FleetResponse struct {
+	Workers []domain.WorkerStatus `json:"workers"`
+	// Drivers lists the drivers accepted by at least one reachable worker
+	Drivers []string `json:"drivers"`
+}
+
+// HandleListWorkers returns the status and capabilities of every configured worker
+func (h *FleetHandler) HandleListWorkers(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	response := FleetResponse{
+		Workers: []domain.WorkerStatus{},
+		Drivers: []string{},
+	}
+	if h.fleet != nil {
+		response.Workers = h.fleet.ListWorkers(r.Context())
+		response.Drivers = fleetDrivers(response.Workers)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// fleetDrivers returns the drivers accepted by at least one reachable worker
+func fleetDrivers(workers []domain.WorkerStatus) []string {
+	drivers := []string{}
+	for _, worker := range workers {
+		if worker.Info == nil {
+			continue
+		}
+		for _, driver := range worker.Info.Drivers {
+			if !slices.Contains(drivers, driver) {
+				drivers = append(drivers, driver)
+			}
+		}
+	}
+	slices.Sort(drivers)
+	return drivers
+}
+
+// fleetSupportsDriver reports whether a reachable worker accepts driver. When no worker
+// can be reached the fleet's capabilities are unknown, and the driver is assumed to be supported.
+func fleetSupportsDriver(ctx context.Context, fleet domain.WorkerFleet, driver string) bool {
+	if fleet == nil {
+		return true
+	}
+	if driver == "" {
+		driver = domain.DriverScript
+	}
+
+	workers := fleet.ListWorkers(ctx)
+	reachable := false
+	for _, worker := range workers {
+		if worker.Info == nil {
+			continue
+		}
+		reachable = true
+		if slices.Contains(worker.Info.Drivers, driver) {
+			return true
+		}
+	}
+	return !reachable
+}
+
+// fleetSupportsPlatform reports whether a reachable worker meets requirement. When no worker
+// can be reached the fleet's platforms are unknown, and the requirement is assumed to be met.
+func fleetSupportsPlatform(ctx context.Context, fleet domain.WorkerFleet, requirement domain.WorkerRequirement) bool {
+	if fleet == nil || requirement.IsZero() {
+		return true
+	}
+
+	reachable := false
+	for _, worker := range fleet.ListWorkers(ctx) {
+		if worker.Info == nil {
+			continue
+		}
+		reachable = true
+		platform := domain.WorkerPlatform{OS: worker.Info.OS, Arch: worker.Info.Arch, Region: worker.Info.Region}
+		if requirement.Matches(platform) {
+			return true
+		}
+	}
+	return !reachable
+}
@@ -0,0 +1,363 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/hosts"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"NYCU-SDC/deployment-service/internal/namespace"
+	"NYCU-SDC/deployment-service/internal/resolver"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// maxGitHubPayloadSize limits the size of GitHub webhook payloads (GitHub caps them at 25 MB)
+const maxGitHubPayloadSize = 25 << 20
+
+// GitHubHandler handles GitHub webhook events
+type GitHubHandler struct {
+	namespaces   *namespace.Router
+	catalog      domain.EnvironmentStore
+	hosts        *hosts.Selector
+	deployments  domain.DeploymentStore
+	githubConfig config.GitHubConfig
+	environments *resolver.EnvironmentResolver
+	logger       *zap.Logger
+}
+
+// NewGitHubHandler creates a new GitHub webhook handler picking the environments of pushes
+// and pull requests with environments
+func NewGitHubHandler(namespaces *namespace.Router, catalog domain.EnvironmentStore, hosts *hosts.Selector, deployments domain.DeploymentStore, githubConfig config.GitHubConfig, environments *resolver.EnvironmentResolver, logger *zap.Logger) *GitHubHandler {
+	return &GitHubHandler{
+		namespaces:   namespaces,
+		catalog:      catalog,
+		hosts:        hosts,
+		deployments:  deployments,
+		githubConfig: githubConfig,
+		environments: environments,
+		logger:       logger,
+	}
+}
+
+// pullRequestEvent represents the fields of a GitHub pull_request event used by the service
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title  string `json:"title"`
+		Merged bool   `json:"merged"`
+		Head   struct {
+			Ref  string `json:"ref"`
+			SHA  string `json:"sha"`
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository githubRepository `json:"repository"`
+}
+
+// pushEvent represents the fields of a GitHub push event used by the service
+type pushEvent struct {
+	// Ref is the pushed ref, e.g. "refs/heads/main" or "refs/tags/v1.0.0"
+	Ref string `json:"ref"`
+	// After is the commit the ref points to after the push
+	After      string           `json:"after"`
+	Deleted    bool             `json:"deleted"`
+	Repository githubRepository `json:"repository"`
+}
+
+// githubRepository represents the repository of a GitHub event
+type githubRepository struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+}
+
+// HandleWebhook handles GitHub webhook deliveries.
+// Opening or reopening a pull request deploys its preview environment, new pushes
+// redeploy it in place, and closing or merging it cleans it up. Pushes of branches
+// and tags matching github.environments deploy the environment of the rule.
+func (h *GitHubHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	event := r.Header.Get("X-GitHub-Event")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("github_event", event),
+		zap.String("github_delivery", r.Header.Get("X-GitHub-Delivery")),
+	)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxGitHubPayloadSize))
+	if err != nil {
+		logger.Error("Failed to read request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !h.verifySignature(body, r.Header.Get("X-Hub-Signature-256")) {
+		logger.Warn("Invalid GitHub webhook signature")
+		http.Error(w, "Unauthorized: invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	switch event {
+	case "ping":
+		w.WriteHeader(http.StatusOK)
+	case "pull_request":
+		h.handlePullRequest(w, r, body, logger)
+	case "push":
+		h.handlePush(w, r, body, logger)
+	default:
+		logger.Debug("Ignoring GitHub event")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handlePullRequest deploys or cleans up the preview environment of a pull request
+func (h *GitHubHandler) handlePullRequest(w http.ResponseWriter, r *http.Request, body []byte, logger *zap.Logger) {
+	var payload pullRequestEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		logger.Error("Failed to decode pull_request event", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	eventLogger := logger.With(
+		zap.String(applog.FieldRepo, payload.Repository.FullName),
+		zap.Int("pr_number", payload.Number),
+		zap.String("action", payload.Action),
+	)
+
+	var method domain.DeployMethod
+	switch payload.Action {
+	case "opened", "reopened", "synchronize":
+		method = domain.MethodDeploy
+	case "closed":
+		method = domain.MethodCleanup
+	default:
+		eventLogger.Debug("Ignoring pull_request action")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	repoConfig, ok := h.githubConfig.Preview.Repositories[payload.Repository.FullName]
+	if !ok {
+		eventLogger.Debug("Repository has no preview environments configured")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Never deploy code from forks with the service's credentials
+	if payload.PullRequest.Head.Repo.FullName != payload.Repository.FullName {
+		eventLogger.Warn("Ignoring pull request from fork", zap.String("head_repo", payload.PullRequest.Head.Repo.FullName))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	deployReq := h.buildDeployRequest(payload, repoConfig, method)
+	logger = logger.With(
+		zap.Int("pr_number", payload.Number),
+		zap.String("action", payload.Action),
+	)
+	h.start(w, r, deployReq, logger)
+}
+
+// handlePush deploys a pushed branch or tag to the environment of the first rule matching it
+func (h *GitHubHandler) handlePush(w http.ResponseWriter, r *http.Request, body []byte, logger *zap.Logger) {
+	var payload pushEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		logger.Error("Failed to decode push event", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	eventLogger := logger.With(
+		zap.String(applog.FieldRepo, payload.Repository.FullName),
+		zap.String("ref", payload.Ref),
+	)
+
+	// Deleting a branch or tag leaves its environment running
+	if payload.Deleted {
+		eventLogger.Debug("Ignoring deleted ref")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	repoConfig, ok := h.githubConfig.Preview.Repositories[payload.Repository.FullName]
+	if !ok {
+		eventLogger.Debug("Repository has no environments configured")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	branch, isBranch := strings.CutPrefix(payload.Ref, "refs/heads/")
+	tag, isTag := strings.CutPrefix(payload.Ref, "refs/tags/")
+	if !isBranch && !isTag {
+		eventLogger.Debug("Ignoring push of other ref")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	environment, ok := h.environments.ForPush(branch, tag)
+	if !ok {
+		eventLogger.Debug("No environment rule matches the pushed ref")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// git clone --branch accepts tags too
+	ref := branch
+	if isTag {
+		ref = tag
+	}
+	deployReq := domain.DeployRequest{
+		Source: domain.SourceInfo{
+			Title:  payload.Repository.Name,
+			Repo:   payload.Repository.FullName,
+			Branch: ref,
+			Commit: payload.After,
+		},
+		Method:   domain.MethodDeploy,
+		Metadata: repositoryMetadata(payload.Repository, repoConfig, environment),
+		Setup: domain.SetupConfig{
+			WaitForCI: ciGate(repoConfig.WaitForCI),
+			Supersede: repoConfig.Supersede,
+		},
+		Post: domain.PostActions{
+			NotifyDiscord: domain.DiscordConfig{
+				Enable: repoConfig.NotifyDiscord,
+			},
+		},
+	}
+	h.start(w, r, deployReq, logger.With(zap.String("ref", payload.Ref)))
+}
+
+// start starts the deployment of a GitHub event and responds with the started or queued deployment
+func (h *GitHubHandler) start(w http.ResponseWriter, r *http.Request, deployReq domain.DeployRequest, logger *zap.Logger) {
+	ctx := r.Context()
+	method := deployReq.Method
+	traceID := uuid.New().String()
+	deployReq.TraceID = traceID
+	logger = applog.ForDeployment(logger, deployReq)
+
+	// Branch names and titles are chosen by the author of the pull request or push
+	if err := deployReq.CheckLimits(); err != nil {
+		logger.Warn("GitHub event exceeds the request limits", zap.Error(err))
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	workflowRun, err := startDeployment(ctx, h.namespaces, h.catalog, h.hosts, h.deployments, deployReq, logger)
+	if err != nil {
+		writeStartError(w, logger, err)
+		return
+	}
+
+	if workflowRun == nil {
+		logger.Warn("Every deploy host is draining, deployment queued", zap.String("deploy_method", string(method)))
+	} else {
+		logger.Info("GitHub workflow started",
+			zap.String("workflow_id", workflowRun.GetID()),
+			zap.String("run_id", workflowRun.GetRunID()),
+			zap.String("deploy_method", string(method)),
+		)
+	}
+
+	response := deploymentResponse(traceID, workflowRun)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// buildDeployRequest builds the deploy request of a pull request preview environment.
+// Secrets, DNS, and health checks come from the repository's .deploy manifest.
+func (h *GitHubHandler) buildDeployRequest(payload pullRequestEvent, repoConfig config.PreviewRepository, method domain.DeployMethod) domain.DeployRequest {
+	prPurpose := "preview"
+	if method == domain.MethodCleanup {
+		prPurpose = "closed"
+		if payload.PullRequest.Merged {
+			prPurpose = "merged"
+		}
+	}
+
+	return domain.DeployRequest{
+		Source: domain.SourceInfo{
+			Title:     payload.Repository.Name,
+			Repo:      payload.Repository.FullName,
+			Branch:    payload.PullRequest.Head.Ref,
+			Commit:    payload.PullRequest.Head.SHA,
+			PRNumber:  strconv.Itoa(payload.Number),
+			PRTitle:   payload.PullRequest.Title,
+			PRPurpose: prPurpose,
+		},
+		Method:   method,
+		Metadata: repositoryMetadata(payload.Repository, repoConfig, h.environments.ForPullRequest(payload.PullRequest.Base.Ref)),
+		Setup: domain.SetupConfig{
+			WaitForCI: ciGate(repoConfig.WaitForCI),
+			Supersede: repoConfig.Supersede,
+		},
+		Post: domain.PostActions{
+			NotifyDiscord: domain.DiscordConfig{
+				Enable: repoConfig.NotifyDiscord,
+			},
+		},
+	}
+}
+
+// ciGate returns the CI gate of the deployments of a repository
+func ciGate(gate config.CIGateConfig) domain.CIGateConfig {
+	return domain.CIGateConfig{
+		Enable:         gate.Enable,
+		RequiredChecks: gate.RequiredChecks,
+		TimeoutSeconds: int(gate.Timeout.Seconds()),
+	}
+}
+
+// repositoryMetadata returns the metadata of a deployment of repo to environment; the project
+// and component default to the name of the repository
+func repositoryMetadata(repo githubRepository, repoConfig config.PreviewRepository, environment string) domain.MetadataInfo {
+	projectName := repoConfig.ProjectName
+	if projectName == "" {
+		projectName = repo.Name
+	}
+	component := repoConfig.Component
+	if component == "" {
+		component = repo.Name
+	}
+	return domain.MetadataInfo{
+		ProjectName: projectName,
+		Component:   component,
+		Environment: environment,
+	}
+}
+
+// verifySignature verifies the X-Hub-Signature-256 header against the webhook secret
+func (h *GitHubHandler) verifySignature(body []byte, signature string) bool {
+	if h.githubConfig.WebhookSecret == "" {
+		return false
+	}
+
+	expected, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	expectedMAC, err := hex.DecodeString(expected)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.githubConfig.WebhookSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expectedMAC)
+}
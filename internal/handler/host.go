@@ -0,0 +1,448 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"NYCU-SDC/deployment-service/internal/hosts"
+	"NYCU-SDC/deployment-service/internal/namespace"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+	"go.uber.org/zap"
+)
+
+// migrationStagger spaces out the migrations scheduled by a drain, so the new hosts
+// aren't hit by every preview environment at once
+const migrationStagger = time.Minute
+
+// HostHandler handles requests on the deploy hosts
+type HostHandler struct {
+	namespaces  *namespace.Router
+	catalog     domain.EnvironmentStore
+	hosts       *hosts.Selector
+	store       domain.HostStore
+	deployments domain.DeploymentStore
+	// previewEnvironment is the environment of pull request previews, which are migrated off draining hosts
+	previewEnvironment string
+	logger             *zap.Logger
+}
+
+// NewHostHandler creates a new host handler
+func NewHostHandler(namespaces *namespace.Router, catalog domain.EnvironmentStore, hosts *hosts.Selector, store domain.HostStore, deployments domain.DeploymentStore, previewEnvironment string, logger *zap.Logger) *HostHandler {
+	return &HostHandler{
+		namespaces:         namespaces,
+		catalog:            catalog,
+		hosts:              hosts,
+		store:              store,
+		deployments:        deployments,
+		previewEnvironment: previewEnvironment,
+		logger:             logger,
+	}
+}
+
+// HostStatus represents a deploy host in the host list
+type HostStatus struct {
+	Name         string            `json:"name"`
+	Draining     bool              `json:"draining"`
+	Drain        *domain.HostDrain `json:"drain,omitempty"`
+	Environments []string          `json:"environments"`
+}
+
+// HostListResponse represents the host list response
+type HostListResponse struct {
+	Hosts  []HostStatus              `json:"hosts"`
+	Queued []domain.QueuedDeployment `json:"queued"`
+}
+
+// DrainHostRequest represents the drain request payload
+type DrainHostRequest struct {
+	Reason string `json:"reason"`
+}
+
+// MigrateRequest represents the migration request payload
+type MigrateRequest struct {
+	// Environment is the placement key of the environment, e.g. "project/component/snapshot/pr-42"
+	Environment string `json:"environment"`
+	// To is the destination host; empty picks the first other host that isn't draining
+	To string `json:"to,omitempty"`
+}
+
+// HostMigration represents a scheduled migration
+type HostMigration struct {
+	Environment string    `json:"environment"`
+	WorkflowID  string    `json:"workflow_id"`
+	TraceID     string    `json:"trace_id"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	StartAt     time.Time `json:"start_at"`
+}
+
+// DrainHostResponse represents the drain response
+type DrainHostResponse struct {
+	Drain      domain.HostDrain `json:"drain"`
+	Migrations []HostMigration  `json:"migrations"`
+	// Unmigrated lists the preview environments that couldn't be migrated
+	Unmigrated []string `json:"unmigrated,omitempty"`
+}
+
+// UndrainHostResponse represents the undrain response
+type UndrainHostResponse struct {
+	Host string `json:"host"`
+	// Started lists the queued deployments started now that a host is available
+	Started []DeployResponse `json:"started"`
+	// Rejected lists the trace IDs of queued deployments the environments catalog no longer
+	// allows, e.g. because their environment was frozen meanwhile; they are dropped
+	Rejected []string `json:"rejected,omitempty"`
+}
+
+// HandleListHosts returns the deploy hosts with their drain state, the environments
+// deployed on them, and the deployments waiting for a host
+func (h *HostHandler) HandleListHosts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	drains, err := h.store.ListDrains(ctx)
+	if err != nil {
+		logger.Error("Failed to list host drains", zap.Error(err))
+		http.Error(w, "Failed to list hosts", http.StatusInternalServerError)
+		return
+	}
+	placements, err := h.store.ListPlacements(ctx)
+	if err != nil {
+		logger.Error("Failed to list placements", zap.Error(err))
+		http.Error(w, "Failed to list hosts", http.StatusInternalServerError)
+		return
+	}
+	queued, err := h.store.ListQueuedDeployments(ctx)
+	if err != nil {
+		logger.Error("Failed to list queued deployments", zap.Error(err))
+		http.Error(w, "Failed to list hosts", http.StatusInternalServerError)
+		return
+	}
+
+	response := HostListResponse{
+		Hosts:  []HostStatus{},
+		Queued: queued,
+	}
+	if response.Queued == nil {
+		response.Queued = []domain.QueuedDeployment{}
+	}
+	for _, host := range h.hosts.Hosts() {
+		status := HostStatus{Name: host.Name, Environments: []string{}}
+		for _, drain := range drains {
+			if drain.Host == host.Name {
+				status.Draining = true
+				status.Drain = &drain
+			}
+		}
+		for _, placement := range placements {
+			if placement.Host == host.Name {
+				status.Environments = append(status.Environments, placement.Key)
+			}
+		}
+		response.Hosts = append(response.Hosts, status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleDrain marks a deploy host as draining. New deployments are placed on the other
+// hosts of the group (or queued while every host is draining), and the preview
+// environments on the host are migrated to other hosts.
+func (h *HostHandler) HandleDrain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hostName := r.PathValue("host")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("host", hostName),
+	)
+
+	if _, ok := h.hosts.Lookup(hostName); !ok {
+		http.Error(w, "Unknown deploy host", http.StatusNotFound)
+		return
+	}
+
+	// The body is optional
+	var payload DrainHostRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	drain, draining, err := h.store.GetDrain(ctx, hostName)
+	if err != nil {
+		logger.Error("Failed to get host drain", zap.Error(err))
+		http.Error(w, "Failed to drain host", http.StatusInternalServerError)
+		return
+	}
+	if !draining {
+		drain = domain.HostDrain{
+			Host:      hostName,
+			Reason:    payload.Reason,
+			DrainedAt: time.Now().UTC(),
+		}
+		if err := h.store.PutDrain(ctx, drain); err != nil {
+			logger.Error("Failed to store host drain", zap.Error(err))
+			http.Error(w, "Failed to drain host", http.StatusInternalServerError)
+			return
+		}
+		logger.Info("Deploy host draining", zap.String("reason", drain.Reason))
+	}
+
+	migrations, unmigrated, err := h.scheduleMigrations(ctx, hostName, logger)
+	if err != nil {
+		logger.Error("Failed to schedule migrations", zap.Error(err))
+		http.Error(w, "Host is draining, but scheduling migrations failed", http.StatusInternalServerError)
+		return
+	}
+
+	response := DrainHostResponse{
+		Drain:      drain,
+		Migrations: migrations,
+		Unmigrated: unmigrated,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleUndrain makes a deploy host available again and starts the queued deployments
+func (h *HostHandler) HandleUndrain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hostName := r.PathValue("host")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("host", hostName),
+	)
+
+	if _, ok := h.hosts.Lookup(hostName); !ok {
+		http.Error(w, "Unknown deploy host", http.StatusNotFound)
+		return
+	}
+
+	if err := h.store.DeleteDrain(ctx, hostName); err != nil {
+		logger.Error("Failed to delete host drain", zap.Error(err))
+		http.Error(w, "Failed to undrain host", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Deploy host available again")
+
+	// The queued deployments outlive the request, so a client disconnecting mustn't stop them
+	// from being started
+	ctx = context.WithoutCancel(ctx)
+	response := UndrainHostResponse{Host: hostName, Started: []DeployResponse{}}
+	for {
+		ok, err := h.store.StartQueuedDeployment(ctx, func(deployment domain.QueuedDeployment) error {
+			workflowRun, err := startDeployment(ctx, h.namespaces, h.catalog, h.hosts, h.deployments, deployment.Request, logger)
+			if isEnvironmentRejection(err) {
+				logger.Warn("Queued deployment rejected by the environments catalog", zap.String("trace_id", deployment.TraceID), zap.Error(err))
+				recordDeployment(ctx, h.namespaces, h.deployments, deployment.Request, domain.DeploymentStateRejected, logger)
+				response.Rejected = append(response.Rejected, deployment.TraceID)
+				return nil
+			}
+			if err != nil {
+				// The deployment stays queued, so the next undrain retries it
+				return fmt.Errorf("deployment %s: %w", deployment.TraceID, err)
+			}
+			if workflowRun != nil {
+				logger.Info("Queued deployment started", zap.String("trace_id", deployment.TraceID))
+				response.Started = append(response.Started, deploymentResponse(deployment.TraceID, workflowRun))
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error("Failed to start queued deployment", zap.Error(err))
+			http.Error(w, "Host is available, but starting queued deployments failed", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleMigrate moves an environment to another deploy host, e.g. to rebalance the preview fleet.
+// The environment is redeployed on the new host and checked for health there before its
+// DNS record is moved and the old host is cleaned up.
+func (h *HostHandler) HandleMigrate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	var payload MigrateRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if payload.Environment == "" {
+		http.Error(w, "environment is required", http.StatusBadRequest)
+		return
+	}
+
+	placement, placed, err := h.store.GetPlacement(ctx, payload.Environment)
+	if err != nil {
+		logger.Error("Failed to get placement", zap.Error(err))
+		http.Error(w, "Failed to migrate environment", http.StatusInternalServerError)
+		return
+	}
+	if !placed {
+		http.Error(w, "Unknown environment", http.StatusNotFound)
+		return
+	}
+
+	migration, err := h.startMigration(ctx, placement, payload.To, 0, logger)
+	switch {
+	case errors.Is(err, hosts.ErrUnknownHost):
+		http.Error(w, "Unknown deploy host", http.StatusNotFound)
+		return
+	case errors.Is(err, hosts.ErrSameHost), errors.Is(err, hosts.ErrHostDraining), errors.Is(err, hosts.ErrHostNotAllowed), errors.Is(err, domain.ErrNoHostAvailable):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		logger.Error("Failed to start migration", zap.Error(err))
+		http.Error(w, "Failed to migrate environment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(migration); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// scheduleMigrations starts a migration workflow for each preview environment on the host.
+// The workflows are started with increasing delays, so the environments move one at a time.
+func (h *HostHandler) scheduleMigrations(ctx context.Context, hostName string, logger *zap.Logger) ([]HostMigration, []string, error) {
+	placements, err := h.store.ListPlacements(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	migrations := []HostMigration{}
+	var unmigrated []string
+	for _, placement := range placements {
+		if placement.Host != hostName || placement.Request.Metadata.Environment != h.previewEnvironment {
+			continue
+		}
+
+		delay := time.Duration(len(migrations)) * migrationStagger
+		migration, err := h.startMigration(ctx, placement, "", delay, logger)
+		if errors.Is(err, domain.ErrNoHostAvailable) {
+			logger.Warn("No host to migrate preview environment to", zap.String("environment", placement.Key))
+			unmigrated = append(unmigrated, placement.Key)
+			continue
+		}
+		if err != nil {
+			return migrations, unmigrated, err
+		}
+		migrations = append(migrations, migration)
+	}
+	return migrations, unmigrated, nil
+}
+
+// startMigration starts a migration workflow moving the environment of placement to
+// the host named to, or to the first other host that isn't draining if to is empty
+func (h *HostHandler) startMigration(ctx context.Context, placement domain.Placement, to string, delay time.Duration, logger *zap.Logger) (HostMigration, error) {
+	input, err := h.migrationInput(ctx, placement, to)
+	if err != nil {
+		return HostMigration{}, err
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:         workflow.MigrationWorkflowID(input.TraceID),
+		TaskQueue:  workflow.TaskQueue,
+		StartDelay: delay,
+	}
+	ctx = encryption.WithTenant(ctx, placement.Request.Metadata.ProjectName)
+	workflowRun, err := h.namespaces.Client(placement.Request.Metadata.Environment).ExecuteWorkflow(ctx, workflowOptions, workflow.WorkflowMigration, input)
+	if err != nil {
+		return HostMigration{}, err
+	}
+	h.hosts.Record(ctx, input.Deploy)
+
+	logger.Info("Environment migration scheduled",
+		zap.String("environment", placement.Key),
+		zap.String("workflow_id", workflowRun.GetID()),
+		zap.String("from", placement.Host),
+		zap.String("to", input.Deploy.Host.Name),
+		zap.Duration("delay", delay),
+	)
+	return HostMigration{
+		Environment: placement.Key,
+		WorkflowID:  workflowRun.GetID(),
+		TraceID:     input.TraceID,
+		From:        placement.Host,
+		To:          input.Deploy.Host.Name,
+		StartAt:     time.Now().UTC().Add(delay),
+	}, nil
+}
+
+// migrationInput builds the migration of an environment off its current host.
+// The environment is redeployed with the same commit and secrets as its last deployment,
+// on a host of the current host group of its environment.
+func (h *HostHandler) migrationInput(ctx context.Context, placement domain.Placement, to string) (workflow.MigrationInput, error) {
+	policy := placement.Request.EnvironmentPolicy
+	environment, ok, err := h.catalog.GetEnvironment(ctx, placement.Request.Metadata.Environment)
+	if err != nil {
+		return workflow.MigrationInput{}, err
+	}
+	if ok {
+		policy = &environment
+	}
+
+	destination, err := h.hosts.Destination(ctx, placement.Request.Metadata.Environment, policy, placement.Host, to)
+	if err != nil {
+		return workflow.MigrationInput{}, err
+	}
+
+	deploy := placement.Request
+	deploy.EnvironmentPolicy = policy
+	deploy.TraceID = uuid.New().String()
+	deploy.RedeployOf = placement.TraceID
+	deploy.Host = destination
+	deploy.KeepDomain = true
+
+	cleanup := placement.Request
+	cleanup.TraceID = uuid.New().String()
+	cleanup.Method = domain.MethodCleanup
+	cleanup.Post = domain.PostActions{}
+	cleanup.KeepDomain = true
+	cleanup.Host, err = h.hosts.Target(placement.Host)
+	if err != nil {
+		return workflow.MigrationInput{}, err
+	}
+
+	return workflow.MigrationInput{
+		TraceID: uuid.New().String(),
+		Deploy:  deploy,
+		Cleanup: cleanup,
+	}, nil
+}
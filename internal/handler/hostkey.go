@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKeyHandler pins SSH host keys on the worker it runs in. The API sends every rotation
+// to each worker of the fleet, so all of them accept the new key.
+type HostKeyHandler struct {
+	hostKeys domain.HostKeyManager
+	// defaultHost is the deploy host pinned when the request names none
+	defaultHost string
+	validator   *validator.Validate
+	logger      *zap.Logger
+}
+
+// NewHostKeyHandler creates a new host key handler
+func NewHostKeyHandler(hostKeys domain.HostKeyManager, defaultHost string, validator *validator.Validate, logger *zap.Logger) *HostKeyHandler {
+	return &HostKeyHandler{
+		hostKeys:    hostKeys,
+		defaultHost: defaultHost,
+		validator:   validator,
+		logger:      logger,
+	}
+}
+
+// PinHostKeyResponse represents the response of a host key pinned on a worker
+type PinHostKeyResponse struct {
+	Host        string `json:"host"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// HandlePin pins a new SSH host key on this worker
+func (h *HostKeyHandler) HandlePin(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	var payload RotateHostKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		logger.Error("Failed to decode request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.validator.Struct(payload); err != nil {
+		logger.Error("Request validation failed", zap.Error(err))
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(payload.PublicKey))
+	if err != nil {
+		logger.Error("Invalid public key", zap.Error(err))
+		http.Error(w, "Invalid public key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	host := payload.Host
+	if host == "" {
+		host = h.defaultHost
+	}
+	grace := defaultHostKeyGracePeriod
+	if payload.GracePeriodSeconds != nil {
+		grace = time.Duration(*payload.GracePeriodSeconds) * time.Second
+	}
+	logger = logger.With(zap.String("host", host))
+
+	if err := h.hostKeys.PinHostKey(r.Context(), host, payload.PublicKey, grace); err != nil {
+		logger.Error("Failed to pin host key", zap.Error(err))
+		http.Error(w, "Failed to pin host key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := PinHostKeyResponse{
+		Host:        host,
+		Fingerprint: ssh.FingerprintSHA256(key),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
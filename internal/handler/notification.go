@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// NotificationHandler reports the notifications that couldn't be delivered and resends them.
+// The worker serves the failures it stores; the API serves those of the whole fleet.
+type NotificationHandler struct {
+	failures domain.NotificationFailureSource
+	logger   *zap.Logger
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(failures domain.NotificationFailureSource, logger *zap.Logger) *NotificationHandler {
+	return &NotificationHandler{
+		failures: failures,
+		logger:   logger,
+	}
+}
+
+// NotificationFailuresResponse represents the failed notifications response
+type NotificationFailuresResponse struct {
+	Failures []domain.NotificationFailure `json:"failures"`
+}
+
+// HandleListFailures returns the notifications that couldn't be delivered
+func (h *NotificationHandler) HandleListFailures(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	response := NotificationFailuresResponse{Failures: []domain.NotificationFailure{}}
+	if h.failures != nil {
+		failures, err := h.failures.ListNotificationFailures(r.Context())
+		if err != nil {
+			logger.Error("Failed to list notification failures", zap.Error(err))
+			status := http.StatusInternalServerError
+			if errors.Is(err, domain.ErrUnavailable) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, "Failed to list notification failures", status)
+			return
+		}
+		response.Failures = append(response.Failures, failures...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleRetry resends a notification that couldn't be delivered
+func (h *NotificationHandler) HandleRetry(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("notification_failure_id", id),
+	)
+
+	if h.failures == nil {
+		http.Error(w, "Notification failure not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.failures.RetryNotificationFailure(r.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrNotificationFailureNotFound) {
+			http.Error(w, "Notification failure not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to resend notification", zap.Error(err))
+		http.Error(w, "Failed to resend notification: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	logger.Info("Failed notification resent")
+	w.WriteHeader(http.StatusNoContent)
+}
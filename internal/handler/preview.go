@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/namespace"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/converter"
+	"go.uber.org/zap"
+)
+
+// Usage report periods in days
+const (
+	defaultUsageDays = 7
+	maxUsageDays     = 30
+)
+
+// PreviewHandler reports how much the preview environments of pull requests are used,
+// so unused ones can be cleaned up
+type PreviewHandler struct {
+	namespaces *namespace.Router
+	store      domain.HostStore
+	// analytics is nil when Cloudflare is not configured
+	analytics          domain.TrafficAnalytics
+	previewEnvironment string
+	logger             *zap.Logger
+}
+
+// NewPreviewHandler creates a new preview handler
+func NewPreviewHandler(namespaces *namespace.Router, store domain.HostStore, analytics domain.TrafficAnalytics, previewEnvironment string, logger *zap.Logger) *PreviewHandler {
+	return &PreviewHandler{
+		namespaces:         namespaces,
+		store:              store,
+		analytics:          analytics,
+		previewEnvironment: previewEnvironment,
+		logger:             logger,
+	}
+}
+
+// PreviewUsage represents a preview environment in the usage report
+type PreviewUsage struct {
+	// Environment is the placement key of the environment, e.g. "project/component/snapshot/pr-42"
+	Environment string `json:"environment"`
+	Repo        string `json:"repo"`
+	PRNumber    string `json:"pr_number,omitempty"`
+	Host        string `json:"host"`
+	// TraceID is the last deployment of the environment
+	TraceID string `json:"trace_id"`
+	Domain  string `json:"domain,omitempty"`
+	// Requests is the number of requests to Domain in the period; missing without a domain
+	Requests *int64 `json:"requests,omitempty"`
+	// Hint suggests cleaning up an environment nobody used in the period
+	Hint string `json:"hint,omitempty"`
+}
+
+// PreviewUsageResponse represents the preview usage report
+type PreviewUsageResponse struct {
+	Since        time.Time      `json:"since"`
+	Until        time.Time      `json:"until"`
+	Environments []PreviewUsage `json:"environments"`
+}
+
+// HandleUsage reports the requests each preview environment received in the last days
+// (default 7), from the Cloudflare analytics of its DNS record
+func (h *PreviewHandler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	if h.analytics == nil {
+		http.Error(w, "Preview usage requires cloudflare.api_token and cloudflare.zone_id", http.StatusServiceUnavailable)
+		return
+	}
+
+	days := defaultUsageDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxUsageDays {
+			http.Error(w, fmt.Sprintf("Invalid days: must be between 1 and %d", maxUsageDays), http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	placements, err := h.store.ListPlacements(ctx)
+	if err != nil {
+		logger.Error("Failed to list placements", zap.Error(err))
+		http.Error(w, "Failed to report preview usage", http.StatusInternalServerError)
+		return
+	}
+
+	until := time.Now().UTC().Truncate(time.Minute)
+	response := PreviewUsageResponse{
+		Since:        until.Add(-time.Duration(days) * 24 * time.Hour),
+		Until:        until,
+		Environments: []PreviewUsage{},
+	}
+	var hostnames []string
+	for _, placement := range placements {
+		if placement.Request.Metadata.Environment != h.previewEnvironment {
+			continue
+		}
+		usage := PreviewUsage{
+			Environment: placement.Key,
+			Repo:        placement.Request.Source.Repo,
+			PRNumber:    placement.Request.Source.PRNumber,
+			Host:        placement.Host,
+			TraceID:     placement.TraceID,
+			Domain:      h.previewDomain(ctx, placement, logger),
+		}
+		if usage.Domain != "" {
+			hostnames = append(hostnames, usage.Domain)
+		}
+		response.Environments = append(response.Environments, usage)
+	}
+
+	counts, err := h.analytics.CountRequests(ctx, hostnames, response.Since, response.Until)
+	if err != nil {
+		logger.Error("Failed to count preview requests", zap.Error(err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, "Failed to report preview usage", status)
+		return
+	}
+	for i, usage := range response.Environments {
+		if usage.Domain == "" {
+			continue
+		}
+		requests := counts[strings.ToLower(usage.Domain)]
+		response.Environments[i].Requests = &requests
+		if requests == 0 {
+			response.Environments[i].Hint = fmt.Sprintf("0 requests in %d days, consider cleaning up this preview environment", days)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// previewDomain returns the DNS record of the last deployment of a preview environment: the
+// record set up by the request, or else the one from its .deploy manifest, read from the
+// result of the deployment. It returns "" if the environment has no record.
+func (h *PreviewHandler) previewDomain(ctx context.Context, placement domain.Placement, logger *zap.Logger) string {
+	if setupDomain := placement.Request.Post.SetupDomain; setupDomain.Enable && setupDomain.Name != "" {
+		return setupDomain.Name
+	}
+
+	workflowID := workflow.CDWorkflowID(placement.TraceID)
+	namespace, description, err := h.namespaces.Find(ctx, workflowID)
+	if err != nil {
+		logger.Warn("Failed to find the last deployment of a preview environment", zap.Error(err), zap.String("workflow_id", workflowID))
+		return ""
+	}
+	temporalClient := h.namespaces.NamespaceClient(namespace)
+	runID := description.GetWorkflowExecutionInfo().GetExecution().GetRunId()
+
+	var result domain.DeployResult
+	if description.GetWorkflowExecutionInfo().GetStatus() == enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED {
+		err = temporalClient.GetWorkflow(ctx, workflowID, runID).Get(ctx, &result)
+	} else {
+		var value converter.EncodedValue
+		value, err = temporalClient.QueryWorkflow(ctx, workflowID, runID, workflow.QueryDeployResult)
+		if err == nil {
+			err = value.Get(&result)
+		}
+	}
+	if err != nil {
+		logger.Warn("Failed to load the last deployment of a preview environment", zap.Error(err), zap.String("workflow_id", workflowID))
+		return ""
+	}
+	if result.Domain == nil {
+		return ""
+	}
+	return result.Domain.Name
+}
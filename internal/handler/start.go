@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"NYCU-SDC/deployment-service/internal/hosts"
+	"NYCU-SDC/deployment-service/internal/namespace"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.uber.org/zap"
+)
+
+// Statuses of deployments accepted by the API
+const (
+	deploymentStarted = "started"
+	deploymentQueued  = "queued"
+)
+
+// startCDWorkflow starts a CDWorkflow for the given request in the namespace of its environment.
+// A deployment with Setup.Supersede takes over from the running deployments it supersedes.
+func startCDWorkflow(ctx context.Context, namespaces *namespace.Router, req domain.DeployRequest) (client.WorkflowRun, error) {
+	req.Supersedes = nil
+	if req.Setup.Supersede && req.Method == domain.MethodDeploy {
+		running, err := supersededDeployments(ctx, namespaces, req)
+		if err != nil {
+			return nil, err
+		}
+		req.Supersedes = running
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        workflow.CDWorkflowID(req.TraceID),
+		TaskQueue: workflow.TaskQueueFor(req.Setup.Worker),
+		Memo:      workflow.DeploymentMemo(req),
+	}
+
+	// The payloads of the deployment are encrypted with the keys of its project, if it has any
+	ctx = encryption.WithTenant(ctx, req.Metadata.ProjectName)
+	return namespaces.Client(req.Metadata.Environment).ExecuteWorkflow(ctx, workflowOptions, workflow.WorkflowCD, req)
+}
+
+// supersededDeployments returns the trace IDs of the running deployments of the same repository,
+// pull request, component, and environment as req. The workflow of req asks them to stop.
+func supersededDeployments(ctx context.Context, namespaces *namespace.Router, req domain.DeployRequest) ([]string, error) {
+	query := fmt.Sprintf("WorkflowType = '%s' AND ExecutionStatus = 'Running'", workflow.WorkflowCD)
+	temporalClient := namespaces.Client(req.Metadata.Environment)
+
+	var traceIDs []string
+	var nextPageToken []byte
+	for {
+		resp, err := temporalClient.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         query,
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list running deployments: %w", err)
+		}
+		for _, info := range resp.GetExecutions() {
+			traceID := strings.TrimPrefix(info.GetExecution().GetWorkflowId(), workflow.CDWorkflowID(""))
+			if traceID != req.TraceID && supersedes(req, info.GetMemo()) {
+				traceIDs = append(traceIDs, traceID)
+			}
+		}
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return traceIDs, nil
+		}
+	}
+}
+
+// supersedes reports whether req supersedes the running deployment with memo
+func supersedes(req domain.DeployRequest, memo *commonpb.Memo) bool {
+	return workflow.MemoValue(memo, workflow.MemoMethod) == string(domain.MethodDeploy) &&
+		workflow.MemoValue(memo, workflow.MemoRepo) == req.Source.Repo &&
+		workflow.MemoValue(memo, workflow.MemoPRNumber) == req.Source.PRNumber &&
+		workflow.MemoValue(memo, workflow.MemoComponent) == req.Metadata.Component &&
+		workflow.MemoValue(memo, workflow.MemoEnvironment) == req.Metadata.Environment
+}
+
+// startDeployment checks req against its environment in the catalog, places it on a deploy
+// host, starts its CDWorkflow, and records it in the deployment store.
+// When every host is draining, req is queued until a host becomes available and the returned run is nil.
+func startDeployment(ctx context.Context, namespaces *namespace.Router, catalog domain.EnvironmentStore, selector *hosts.Selector, deployments domain.DeploymentStore, req domain.DeployRequest, logger *zap.Logger) (client.WorkflowRun, error) {
+	req, err := applyEnvironment(ctx, catalog, req)
+	if err != nil {
+		return nil, err
+	}
+
+	placed, err := selector.Place(ctx, req)
+	if errors.Is(err, domain.ErrNoHostAvailable) {
+		if err := selector.Queue(ctx, req); err != nil {
+			return nil, err
+		}
+		recordDeployment(ctx, namespaces, deployments, req, domain.DeploymentStateQueued, logger)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	workflowRun, err := startCDWorkflow(ctx, namespaces, placed)
+	if err != nil {
+		selector.Release(ctx, placed)
+		return nil, err
+	}
+	selector.Record(ctx, placed)
+	recordDeployment(ctx, namespaces, deployments, placed, domain.DeploymentStateStarted, logger)
+	return workflowRun, nil
+}
+
+// recordDeployment saves the record of an accepted deployment. The deployment already went
+// ahead, so a failure is only logged.
+func recordDeployment(ctx context.Context, namespaces *namespace.Router, deployments domain.DeploymentStore, req domain.DeployRequest, state string, logger *zap.Logger) {
+	deployment := domain.NewDeployment(req, state, namespaces.Namespace(req.Metadata.Environment), time.Now().UTC())
+	if err := deployments.SaveDeployment(ctx, deployment); err != nil {
+		logger.Error("Failed to record deployment", zap.Error(err), zap.String("state", state))
+	}
+}
+
+// applyEnvironment checks req against the catalog entry of its environment and records the
+// entry in req.EnvironmentPolicy, which the workflow and the host selector enforce.
+// Deployments are rejected during a freeze window; cleanups, which only free resources, aren't.
+func applyEnvironment(ctx context.Context, catalog domain.EnvironmentStore, req domain.DeployRequest) (domain.DeployRequest, error) {
+	environment, ok, err := catalog.GetEnvironment(ctx, req.Metadata.Environment)
+	if err != nil {
+		return req, fmt.Errorf("failed to get environment: %w", err)
+	}
+	if !ok {
+		return req, fmt.Errorf("%w %q", domain.ErrUnknownEnvironment, req.Metadata.Environment)
+	}
+
+	if req.Method == domain.MethodDeploy {
+		if err := environment.CheckFrozen(time.Now()); err != nil {
+			return req, err
+		}
+		if req.Post.SetupDomain.Enable {
+			if err := environment.CheckDomain(req.Post.SetupDomain.Name); err != nil {
+				return req, err
+			}
+		}
+	}
+	if req.Method == domain.MethodCleanup && req.Post.CleanupDomain.Enable {
+		if err := environment.CheckDomain(req.Post.CleanupDomain.Name); err != nil {
+			return req, err
+		}
+	}
+
+	req.EnvironmentPolicy = &environment
+	return req, nil
+}
+
+// isEnvironmentRejection reports whether err is a request the environments catalog rejected
+func isEnvironmentRejection(err error) bool {
+	return errors.Is(err, domain.ErrUnknownEnvironment) ||
+		errors.Is(err, domain.ErrDomainNotAllowed) ||
+		errors.Is(err, domain.ErrEnvironmentFrozen) ||
+		errors.Is(err, domain.ErrQuotaExceeded)
+}
+
+// writeStartError responds to a deployment that couldn't be started. Requests outside the
+// catalog are invalid (400); deployments to a frozen or full environment conflict with it (409).
+func writeStartError(w http.ResponseWriter, logger *zap.Logger, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUnknownEnvironment), errors.Is(err, domain.ErrDomainNotAllowed):
+		logger.Warn("Request rejected by the environments catalog", zap.Error(err))
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrEnvironmentFrozen), errors.Is(err, domain.ErrQuotaExceeded):
+		logger.Warn("Request rejected by the environments catalog", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.Error("Failed to start workflow", zap.Error(err))
+		http.Error(w, "Failed to start workflow", http.StatusInternalServerError)
+	}
+}
+
+// deploymentResponse builds the response of a started or queued deployment
+func deploymentResponse(traceID string, workflowRun client.WorkflowRun) DeployResponse {
+	if workflowRun == nil {
+		return DeployResponse{
+			WorkflowID: workflow.CDWorkflowID(traceID),
+			TraceID:    traceID,
+			Status:     deploymentQueued,
+		}
+	}
+	return DeployResponse{
+		WorkflowID: workflowRun.GetID(),
+		RunID:      workflowRun.GetRunID(),
+		TraceID:    traceID,
+		Status:     deploymentStarted,
+	}
+}
@@ -2,47 +2,76 @@ package handler
 
 import (
 	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/hosts"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"NYCU-SDC/deployment-service/internal/namespace"
+	"NYCU-SDC/deployment-service/internal/resolver"
+	"NYCU-SDC/deployment-service/internal/schema"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
-	"go.temporal.io/sdk/client"
 	"go.uber.org/zap"
 )
 
 // WebhookHandler handles webhook requests
 type WebhookHandler struct {
-	temporalClient client.Client
-	validator      *validator.Validate
-	logger         *zap.Logger
+	namespaces *namespace.Router
+	catalog    domain.EnvironmentStore
+	hosts      *hosts.Selector
+	// deployments records the accepted deployments
+	deployments domain.DeploymentStore
+	// fleet reports the drivers accepted by the workers; nil skips the capability check
+	fleet domain.WorkerFleet
+	// domains rejects setup_domain and cleanup_domain names outside the allowed domains
+	domains   *resolver.DomainPolicy
+	validator *validator.Validate
+	logger    *zap.Logger
 }
 
 // NewWebhookHandler creates a new webhook handler
-func NewWebhookHandler(temporalClient client.Client, validator *validator.Validate, logger *zap.Logger) *WebhookHandler {
+func NewWebhookHandler(namespaces *namespace.Router, catalog domain.EnvironmentStore, hosts *hosts.Selector, deployments domain.DeploymentStore, fleet domain.WorkerFleet, domains *resolver.DomainPolicy, validator *validator.Validate, logger *zap.Logger) *WebhookHandler {
 	return &WebhookHandler{
-		temporalClient: temporalClient,
-		validator:      validator,
-		logger:         logger,
+		namespaces:  namespaces,
+		catalog:     catalog,
+		hosts:       hosts,
+		deployments: deployments,
+		fleet:       fleet,
+		domains:     domains,
+		validator:   validator,
+		logger:      logger,
 	}
 }
 
 // DeployRequest represents the webhook request payload
 type DeployRequestPayload struct {
+	// SchemaVersion is the deploy-request schema version the caller implements
+	SchemaVersion string `json:"schema_version,omitempty"`
+
 	Source   domain.SourceInfo   `json:"source" validate:"required"`
 	Method   domain.DeployMethod `json:"method" validate:"required,oneof=deploy cleanup"`
 	Metadata domain.MetadataInfo `json:"metadata" validate:"required"`
 	Setup    domain.SetupConfig  `json:"setup"`
 	Post     domain.PostActions  `json:"post"`
+	// Artifacts are the SBOMs and provenance attestations of the build being deployed
+	Artifacts []domain.ArtifactRef `json:"artifacts,omitempty" validate:"omitempty,max=10,dive"`
 }
 
+// maxDeployPayloadSize limits the size of deploy webhook payloads
+const maxDeployPayloadSize = 1 << 20
+
 // DeployResponse represents the webhook response
 type DeployResponse struct {
 	WorkflowID string `json:"workflow_id"`
 	RunID      string `json:"run_id"`
 	TraceID    string `json:"trace_id"`
 	Status     string `json:"status"`
+	// Warnings lists deprecated usages of the request schema, with upgrade hints
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // HandleDeploy handles the deployment webhook request
@@ -54,13 +83,45 @@ func (h *WebhookHandler) HandleDeploy(w http.ResponseWriter, r *http.Request) {
 	)
 
 	// Parse request body
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDeployPayloadSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		logger.Warn("Request body too large", zap.Int64("limit", tooLarge.Limit))
+		http.Error(w, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to read request body", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
 	var payload DeployRequestPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+	if err := json.Unmarshal(body, &payload); err != nil {
 		logger.Error("Failed to decode request body", zap.Error(err))
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	// Check the payload against the schema version advertised by the caller
+	schemaVersion := payload.SchemaVersion
+	if schemaVersion == "" {
+		schemaVersion = r.Header.Get(schema.VersionHeader)
+	}
+	schemaReport, err := schema.Check(schemaVersion, body)
+	if err != nil {
+		logger.Error("Failed to check request schema", zap.Error(err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := schemaReport.Err(); err != nil {
+		logger.Warn("Request schema incompatible", zap.String("schema_version", schemaVersion), zap.Error(err))
+		http.Error(w, "Schema validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if warnings := schemaReport.WarningMessages(); len(warnings) > 0 {
+		logger.Info("Request uses deprecated schema", zap.String("schema_version", schemaVersion), zap.Strings("warnings", warnings))
+	}
+
 	// Validate request
 	if err := h.validator.Struct(payload); err != nil {
 		logger.Error("Request validation failed", zap.Error(err))
@@ -75,45 +136,54 @@ func (h *WebhookHandler) HandleDeploy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject drivers no worker in the fleet accepts
+	if payload.Method == domain.MethodDeploy && !fleetSupportsDriver(ctx, h.fleet, payload.Setup.Driver) {
+		logger.Warn("No worker accepts the requested driver", zap.String("driver", payload.Setup.Driver))
+		http.Error(w, fmt.Sprintf("No worker accepts driver %q", payload.Setup.Driver), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Reject platforms no worker in the fleet runs on
+	if !fleetSupportsPlatform(ctx, h.fleet, payload.Setup.Worker) {
+		logger.Warn("No worker runs on the requested platform", zap.Any("worker", payload.Setup.Worker))
+		http.Error(w, "No worker runs on the requested setup.worker platform", http.StatusUnprocessableEntity)
+		return
+	}
+
 	// Generate trace ID
 	traceID := uuid.New().String()
-	logger = logger.With(zap.String("trace_id", traceID))
 
 	// Build deploy request
 	deployReq := domain.DeployRequest{
-		Source:   payload.Source,
-		Method:   payload.Method,
-		Metadata: payload.Metadata,
-		Setup:    payload.Setup,
-		Post:     payload.Post,
-		TraceID:  traceID,
+		Source:    payload.Source,
+		Method:    payload.Method,
+		Metadata:  payload.Metadata,
+		Setup:     payload.Setup,
+		Post:      payload.Post,
+		TraceID:   traceID,
+		Artifacts: payload.Artifacts,
 	}
+	logger = applog.ForDeployment(logger, deployReq)
 
-	// Start workflow
-	workflowOptions := client.StartWorkflowOptions{
-		ID:        "deploy-" + traceID,
-		TaskQueue: "cd-task-queue",
-	}
-
-	workflowRun, err := h.temporalClient.ExecuteWorkflow(ctx, workflowOptions, "CDWorkflow", deployReq)
+	// Start workflow on a deploy host, or queue it while every host is draining
+	workflowRun, err := startDeployment(ctx, h.namespaces, h.catalog, h.hosts, h.deployments, deployReq, logger)
 	if err != nil {
-		logger.Error("Failed to start workflow", zap.Error(err))
-		http.Error(w, "Failed to start workflow", http.StatusInternalServerError)
+		writeStartError(w, logger, err)
 		return
 	}
 
-	logger.Info("Workflow started",
-		zap.String("workflow_id", workflowRun.GetID()),
-		zap.String("run_id", workflowRun.GetRunID()),
-	)
+	if workflowRun == nil {
+		logger.Warn("Every deploy host is draining, deployment queued")
+	} else {
+		logger.Info("Workflow started",
+			zap.String("workflow_id", workflowRun.GetID()),
+			zap.String("run_id", workflowRun.GetRunID()),
+		)
+	}
 
 	// Return response
-	response := DeployResponse{
-		WorkflowID: workflowRun.GetID(),
-		RunID:      workflowRun.GetRunID(),
-		TraceID:    traceID,
-		Status:     "started",
-	}
+	response := deploymentResponse(traceID, workflowRun)
+	response.Warnings = schemaReport.WarningMessages()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
@@ -124,6 +194,17 @@ func (h *WebhookHandler) HandleDeploy(w http.ResponseWriter, r *http.Request) {
 
 // validateConditionalFields validates fields that are required conditionally
 func (h *WebhookHandler) validateConditionalFields(payload DeployRequestPayload) error {
+	// Fields used in remote commands, directory names, and notifications
+	if err := (domain.DeployRequest{Source: payload.Source, Metadata: payload.Metadata, Setup: payload.Setup}).CheckLimits(); err != nil {
+		return err
+	}
+
+	for i, artifact := range payload.Artifacts {
+		if artifact.Digest != "" && !domain.ValidArtifactDigest(artifact.Digest) {
+			return fmt.Errorf("artifacts[%d].digest must be sha256: followed by 64 lowercase hex digits", i)
+		}
+	}
+
 	// Validate InjectSecret: if enable=true, project, environment, and secrets are required
 	if payload.Setup.InjectSecret.Enable {
 		if payload.Setup.InjectSecret.Project == "" {
@@ -146,6 +227,9 @@ func (h *WebhookHandler) validateConditionalFields(payload DeployRequestPayload)
 			if secret.EnvName == "" {
 				return fmt.Errorf("secrets[%d].env_name is required", i)
 			}
+			if !domain.ValidEnvName(secret.EnvName) {
+				return fmt.Errorf("secrets[%d].env_name %q is not a valid environment variable name", i, secret.EnvName)
+			}
 		}
 	}
 
@@ -160,6 +244,9 @@ func (h *WebhookHandler) validateConditionalFields(payload DeployRequestPayload)
 		if payload.Post.SetupDomain.Value == "" {
 			return fmt.Errorf("value is required when setup_domain.enable is true")
 		}
+		if err := h.domains.Check(payload.Post.SetupDomain.Name); err != nil {
+			return fmt.Errorf("setup_domain.name: %w", err)
+		}
 	}
 
 	// Validate CleanupDomain: if enable=true, name is required (title and value are optional for cleanup)
@@ -167,6 +254,9 @@ func (h *WebhookHandler) validateConditionalFields(payload DeployRequestPayload)
 		if payload.Post.CleanupDomain.Name == "" {
 			return fmt.Errorf("name is required when cleanup_domain.enable is true")
 		}
+		if err := h.domains.Check(payload.Post.CleanupDomain.Name); err != nil {
+			return fmt.Errorf("cleanup_domain.name: %w", err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// adapterHealthTimeout bounds the health check of each adapter
+const adapterHealthTimeout = 10 * time.Second
+
+// WorkerInfoHandler serves the build info and capabilities of the worker it runs in
+type WorkerInfoHandler struct {
+	infoMu sync.RWMutex
+	info   domain.WorkerInfo
+	// adapters maps adapter names to their health checks; nil means the adapter isn't configured
+	adapters map[string]domain.HealthChecker
+	// required lists the adapters the worker isn't ready without
+	required []string
+	logger   *zap.Logger
+}
+
+// NewWorkerInfoHandler creates a new worker info handler
+func NewWorkerInfoHandler(info domain.WorkerInfo, adapters map[string]domain.HealthChecker, required []string, logger *zap.Logger) *WorkerInfoHandler {
+	return &WorkerInfoHandler{
+		info:     info,
+		adapters: adapters,
+		required: required,
+		logger:   logger,
+	}
+}
+
+// SetCapabilities replaces the drivers and disabled capabilities reported, e.g. after a config reload
+func (h *WorkerInfoHandler) SetCapabilities(drivers, disabled []string) {
+	h.infoMu.Lock()
+	defer h.infoMu.Unlock()
+	h.info.Drivers = drivers
+	h.info.Disabled = disabled
+}
+
+// ReadinessResponse represents the worker readiness response
+type ReadinessResponse struct {
+	Ready    bool                   `json:"ready"`
+	Adapters []domain.AdapterHealth `json:"adapters"`
+}
+
+// HandleInfo returns the worker info with the current health of its adapters
+func (h *WorkerInfoHandler) HandleInfo(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	h.infoMu.RLock()
+	info := h.info
+	h.infoMu.RUnlock()
+	info.Adapters = h.checkAdapters(r.Context(), h.adapters)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// HandleReady checks the required adapters, e.g. that the Cloudflare token can still edit
+// the zone, and returns 503 if any of them can't be used
+func (h *WorkerInfoHandler) HandleReady(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+
+	required := make(map[string]domain.HealthChecker, len(h.required))
+	for _, name := range h.required {
+		required[name] = h.adapters[name]
+	}
+
+	response := ReadinessResponse{Ready: true, Adapters: h.checkAdapters(r.Context(), required)}
+	for _, health := range response.Adapters {
+		if health.Status != domain.AdapterHealthy {
+			response.Ready = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !response.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// checkAdapters runs the health checks of adapters concurrently
+func (h *WorkerInfoHandler) checkAdapters(ctx context.Context, adapters map[string]domain.HealthChecker) []domain.AdapterHealth {
+	results := make([]domain.AdapterHealth, 0, len(adapters))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, checker := range adapters {
+		if checker == nil {
+			mu.Lock()
+			results = append(results, domain.AdapterHealth{Name: name, Status: domain.AdapterNotConfigured})
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, checker domain.HealthChecker) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, adapterHealthTimeout)
+			defer cancel()
+
+			health := domain.AdapterHealth{Name: name, Status: domain.AdapterHealthy}
+			if err := checker.CheckHealth(checkCtx); err != nil {
+				health.Status = domain.AdapterUnhealthy
+				health.Error = err.Error()
+				h.logger.Warn("Adapter health check failed", zap.String("adapter", name), zap.Error(err))
+			}
+
+			mu.Lock()
+			results = append(results, health)
+			mu.Unlock()
+		}(name, checker)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
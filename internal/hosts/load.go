@@ -0,0 +1,54 @@
+package hosts
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+// LoadQuerier queries the current load of deploy hosts
+type LoadQuerier interface {
+	// QueryLoad returns the load of the named hosts
+	QueryLoad(ctx context.Context, hosts []string) ([]domain.HostLoad, error)
+}
+
+// WorkflowLoadQuerier queries the load of the hosts through HostLoadWorkflow,
+// since only the workers can reach the hosts
+type WorkflowLoadQuerier struct {
+	temporalClient client.Client
+	timeout        time.Duration
+}
+
+// NewWorkflowLoadQuerier creates a new load querier that waits up to timeout for the load
+func NewWorkflowLoadQuerier(temporalClient client.Client, timeout time.Duration) *WorkflowLoadQuerier {
+	return &WorkflowLoadQuerier{
+		temporalClient: temporalClient,
+		timeout:        timeout,
+	}
+}
+
+// QueryLoad runs HostLoadWorkflow and waits for its result
+func (q *WorkflowLoadQuerier) QueryLoad(ctx context.Context, hosts []string) ([]domain.HostLoad, error) {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                       "host-load-" + uuid.New().String(),
+		TaskQueue:                workflow.TaskQueue,
+		WorkflowExecutionTimeout: q.timeout,
+	}
+	workflowRun, err := q.temporalClient.ExecuteWorkflow(ctx, workflowOptions, workflow.WorkflowHostLoad, hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	var loads []domain.HostLoad
+	if err := workflowRun.Get(ctx, &loads); err != nil {
+		return nil, err
+	}
+	return loads, nil
+}
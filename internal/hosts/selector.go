@@ -0,0 +1,359 @@
+package hosts
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Errors returned when choosing the destination of a migration
+var (
+	ErrUnknownHost  = errors.New("unknown deploy host")
+	ErrSameHost     = errors.New("environment is already deployed on host")
+	ErrHostDraining = errors.New("deploy host is draining")
+	// ErrHostNotAllowed is returned for a host outside the host group of the environment
+	ErrHostNotAllowed = errors.New("deploy host is not in the host group of the environment")
+)
+
+// Selector places deployments on the hosts of the deploy host group
+type Selector struct {
+	hosts []config.DeployHostConfig
+	store domain.HostStore
+	// load picks the least-loaded host for new preview environments; nil uses the first host
+	load               LoadQuerier
+	previewEnvironment string
+	logger             *zap.Logger
+}
+
+// NewSelector creates a new selector for the host group of sshConfig.
+// With a load querier, new environments of previewEnvironment go to the least-loaded host.
+func NewSelector(sshConfig config.SSHConfig, store domain.HostStore, load LoadQuerier, previewEnvironment string, logger *zap.Logger) *Selector {
+	return &Selector{
+		hosts:              sshConfig.HostGroup(),
+		store:              store,
+		load:               load,
+		previewEnvironment: previewEnvironment,
+		logger:             logger,
+	}
+}
+
+// Hosts returns the hosts of the group
+func (s *Selector) Hosts() []config.DeployHostConfig {
+	return s.hosts
+}
+
+// Lookup returns the host with the given name
+func (s *Selector) Lookup(name string) (config.DeployHostConfig, bool) {
+	for _, host := range s.hosts {
+		if host.Name == name {
+			return host, true
+		}
+	}
+	return config.DeployHostConfig{}, false
+}
+
+// Place chooses the deploy host of req.
+// Cleanups run on the host the environment is deployed on. Deployments stay on the host
+// they were placed on (or the environment is deployed on) unless it is draining, and
+// otherwise go to the first host that isn't draining, or the least-loaded one for preview
+// environments when a load querier is set. domain.ErrNoHostAvailable is returned when
+// every host is draining.
+// Deployments are only placed on the hosts of req.EnvironmentPolicy, and a deployment of a
+// new environment fails with domain.ErrQuotaExceeded once the quota of its policy is used up.
+// A new environment under a quota is reserved its placement right away, so concurrent
+// deployments can't exceed the quota; Release frees it if the deployment isn't started.
+func (s *Selector) Place(ctx context.Context, req domain.DeployRequest) (domain.DeployRequest, error) {
+	placement, placed, err := s.store.GetPlacement(ctx, domain.PlacementKey(req))
+	if err != nil {
+		return req, err
+	}
+
+	if req.Method == domain.MethodCleanup {
+		if placed {
+			if host, ok := s.Lookup(placement.Host); ok {
+				req.Host = target(host)
+				return req, nil
+			}
+		}
+		if req.Host == nil {
+			req.Host = target(s.hosts[0])
+		}
+		return req, nil
+	}
+
+	if !placed {
+		if err := s.checkQuota(ctx, req.EnvironmentPolicy); err != nil {
+			return req, err
+		}
+	}
+
+	var preferred []string
+	if req.Host != nil {
+		preferred = append(preferred, req.Host.Name)
+	}
+	if placed {
+		preferred = append(preferred, placement.Host)
+	}
+	for _, name := range preferred {
+		host, ok := s.Lookup(name)
+		if !ok || !allowsHost(req.EnvironmentPolicy, host.Name) {
+			continue
+		}
+		draining, err := s.draining(ctx, host.Name)
+		if err != nil {
+			return req, err
+		}
+		if !draining {
+			req.Host = target(host)
+			return s.reserve(ctx, req, placed)
+		}
+	}
+
+	available, err := s.available(ctx, req.EnvironmentPolicy, "")
+	if err != nil {
+		return req, err
+	}
+	if len(available) == 0 {
+		return req, domain.ErrNoHostAvailable
+	}
+	req.Host = target(s.pick(ctx, req.Metadata.Environment, available))
+	return s.reserve(ctx, req, placed)
+}
+
+// reserve records the placement of req if it deploys a new environment under a quota.
+// checkQuota rejects deployments over the quota early; the reservation enforces it atomically
+// against the deployments other requests and API replicas placed in the meantime.
+func (s *Selector) reserve(ctx context.Context, req domain.DeployRequest, placed bool) (domain.DeployRequest, error) {
+	policy := req.EnvironmentPolicy
+	if placed || policy == nil || policy.Quota == 0 {
+		return req, nil
+	}
+
+	reserved, err := s.store.ReservePlacement(ctx, newPlacement(req), policy.Quota)
+	if err != nil {
+		return req, err
+	}
+	if !reserved {
+		return req, fmt.Errorf("%w: all %d %s environments of the quota deployed", domain.ErrQuotaExceeded, policy.Quota, policy.Name)
+	}
+	return req, nil
+}
+
+// Release frees the placement Place reserved for req, after its deployment failed to start.
+// Failures are only logged; the placement is then freed by the next cleanup of the environment.
+func (s *Selector) Release(ctx context.Context, req domain.DeployRequest) {
+	key := domain.PlacementKey(req)
+	placement, placed, err := s.store.GetPlacement(ctx, key)
+	if err == nil && placed && placement.TraceID == req.TraceID {
+		err = s.store.DeletePlacement(ctx, key)
+	}
+	if err != nil {
+		s.logger.Error("Failed to release deployment placement",
+			zap.String("trace_id", req.TraceID),
+			zap.Error(err),
+		)
+	}
+}
+
+// Record records the host an environment is deployed on once req was started.
+// Failures are only logged, since the deployment is already running.
+func (s *Selector) Record(ctx context.Context, req domain.DeployRequest) {
+	if req.Host == nil {
+		return
+	}
+	if err := s.record(ctx, req); err != nil {
+		s.logger.Error("Failed to record deployment placement",
+			zap.String("trace_id", req.TraceID),
+			zap.String("host", req.Host.Name),
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *Selector) record(ctx context.Context, req domain.DeployRequest) error {
+	key := domain.PlacementKey(req)
+	if req.Method == domain.MethodCleanup {
+		placement, placed, err := s.store.GetPlacement(ctx, key)
+		if err != nil {
+			return err
+		}
+		// The old host of a migrated environment is cleaned up after it moved
+		if !placed || placement.Host != req.Host.Name {
+			return nil
+		}
+		return s.store.DeletePlacement(ctx, key)
+	}
+
+	return s.store.PutPlacement(ctx, newPlacement(req))
+}
+
+// newPlacement returns the placement of the environment of req on its host
+func newPlacement(req domain.DeployRequest) domain.Placement {
+	return domain.Placement{
+		Key:       domain.PlacementKey(req),
+		Host:      req.Host.Name,
+		TraceID:   req.TraceID,
+		Request:   req,
+		UpdatedAt: time.Now().UTC(),
+	}
+}
+
+// Queue stores req until a host becomes available
+func (s *Selector) Queue(ctx context.Context, req domain.DeployRequest) error {
+	return s.store.QueueDeployment(ctx, domain.QueuedDeployment{
+		TraceID:  req.TraceID,
+		Request:  req,
+		QueuedAt: time.Now().UTC(),
+	})
+}
+
+// Target returns the deploy host of req with the given name
+func (s *Selector) Target(name string) (*domain.DeployHost, error) {
+	host, ok := s.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownHost, name)
+	}
+	return target(host), nil
+}
+
+// Destination chooses the host an environment of the given environment type on the from host
+// is migrated to. An empty to picks another host of policy that isn't draining, like Place does.
+func (s *Selector) Destination(ctx context.Context, environment string, policy *domain.Environment, from, to string) (*domain.DeployHost, error) {
+	if to != "" {
+		host, ok := s.Lookup(to)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownHost, to)
+		}
+		if !allowsHost(policy, host.Name) {
+			return nil, fmt.Errorf("%w %q", ErrHostNotAllowed, to)
+		}
+		if host.Name == from {
+			return nil, fmt.Errorf("%w %q", ErrSameHost, to)
+		}
+		draining, err := s.draining(ctx, host.Name)
+		if err != nil {
+			return nil, err
+		}
+		if draining {
+			return nil, fmt.Errorf("%w %q", ErrHostDraining, to)
+		}
+		return target(host), nil
+	}
+
+	available, err := s.available(ctx, policy, from)
+	if err != nil {
+		return nil, err
+	}
+	if len(available) == 0 {
+		return nil, domain.ErrNoHostAvailable
+	}
+	return target(s.pick(ctx, environment, available)), nil
+}
+
+// available returns the hosts of policy that aren't draining, except the excluded one
+func (s *Selector) available(ctx context.Context, policy *domain.Environment, exclude string) ([]config.DeployHostConfig, error) {
+	var available []config.DeployHostConfig
+	for _, host := range s.hosts {
+		if host.Name == exclude || !allowsHost(policy, host.Name) {
+			continue
+		}
+		draining, err := s.draining(ctx, host.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !draining {
+			available = append(available, host)
+		}
+	}
+	return available, nil
+}
+
+// pick chooses among the available hosts: the least-loaded one for preview environments
+// when a load querier is set, otherwise the first one. When the load can't be queried,
+// the first host is used, so placement never fails because of the load query.
+func (s *Selector) pick(ctx context.Context, environment string, available []config.DeployHostConfig) config.DeployHostConfig {
+	if s.load == nil || environment != s.previewEnvironment || len(available) < 2 {
+		return available[0]
+	}
+
+	names := make([]string, len(available))
+	for i, host := range available {
+		names[i] = host.Name
+	}
+	loads, err := s.load.QueryLoad(ctx, names)
+	if err != nil {
+		s.logger.Warn("Failed to query host load, using the first host", zap.Error(err))
+		return available[0]
+	}
+
+	var best *domain.HostLoad
+	for i, load := range loads {
+		if load.Error != "" {
+			continue
+		}
+		if best == nil || load.Utilization() < best.Utilization() {
+			best = &loads[i]
+		}
+	}
+	if best == nil {
+		s.logger.Warn("No host load available, using the first host")
+		return available[0]
+	}
+
+	for _, host := range available {
+		if host.Name == best.Host {
+			s.logger.Info("Placing on the least-loaded host",
+				zap.String("host", host.Name),
+				zap.Float64("load1", best.Load1),
+				zap.Int("cpus", best.CPUs),
+			)
+			return host
+		}
+	}
+	return available[0]
+}
+
+func (s *Selector) draining(ctx context.Context, name string) (bool, error) {
+	_, draining, err := s.store.GetDrain(ctx, name)
+	return draining, err
+}
+
+// target converts a configured host to the host recorded in requests
+func target(host config.DeployHostConfig) *domain.DeployHost {
+	return &domain.DeployHost{
+		Name:     host.Name,
+		DNSValue: host.DNSValue,
+	}
+}
+
+// checkQuota returns domain.ErrQuotaExceeded if as many environments of policy are deployed
+// as its quota allows
+func (s *Selector) checkQuota(ctx context.Context, policy *domain.Environment) error {
+	if policy == nil || policy.Quota == 0 {
+		return nil
+	}
+	placements, err := s.store.ListPlacements(ctx)
+	if err != nil {
+		return err
+	}
+	deployed := 0
+	for _, placement := range placements {
+		if placement.Request.Metadata.Environment == policy.Name {
+			deployed++
+		}
+	}
+	if deployed >= policy.Quota {
+		return fmt.Errorf("%w: %d of %d %s environments deployed", domain.ErrQuotaExceeded, deployed, policy.Quota, policy.Name)
+	}
+	return nil
+}
+
+// allowsHost reports whether policy allows the host; requests without a policy may use every host
+func allowsHost(policy *domain.Environment, name string) bool {
+	return policy == nil || policy.AllowsHost(name)
+}
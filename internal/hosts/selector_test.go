@@ -0,0 +1,260 @@
+package hosts
+
+import (
+	"NYCU-SDC/deployment-service/internal/adapter/hoststore"
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// previewEnvironment is the environment placed on the least-loaded host
+const previewEnvironment = "snapshot"
+
+// fakeLoad reports fixed host loads, or fails with err
+type fakeLoad struct {
+	loads []domain.HostLoad
+	err   error
+}
+
+func (l fakeLoad) QueryLoad(ctx context.Context, hosts []string) ([]domain.HostLoad, error) {
+	return l.loads, l.err
+}
+
+// newTestSelector creates a selector of the hosts deploy-1 to deploy-3 with the given drained hosts
+func newTestSelector(t *testing.T, load LoadQuerier, drained ...string) (*Selector, domain.HostStore) {
+	t.Helper()
+	cipher, err := encryption.NewRecordCipher(config.EncryptionConfig{}, config.TemporalConfig{Namespace: "deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := hoststore.NewStore(filepath.Join(t.TempDir(), "hosts.json"), cipher)
+	for _, host := range drained {
+		if err := store.PutDrain(context.Background(), domain.HostDrain{Host: host}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sshConfig := config.SSHConfig{
+		Port: 22,
+		Hosts: []config.DeployHostConfig{
+			{Name: "deploy-1", Host: "10.0.0.1"},
+			{Name: "deploy-2", Host: "10.0.0.2"},
+			{Name: "deploy-3", Host: "10.0.0.3"},
+		},
+	}
+	return NewSelector(sshConfig, store, load, previewEnvironment, zap.NewNop()), store
+}
+
+// testRequest returns a request deploying pull request pr of app to environment
+func testRequest(method domain.DeployMethod, environment, pr string) domain.DeployRequest {
+	return domain.DeployRequest{
+		TraceID:  "trace-" + environment + "-" + pr,
+		Method:   method,
+		Source:   domain.SourceInfo{Repo: "org/app", PRNumber: pr},
+		Metadata: domain.MetadataInfo{ProjectName: "app", Component: "backend", Environment: environment},
+	}
+}
+
+func TestSelectorPlace(t *testing.T) {
+	tests := []struct {
+		name    string
+		drained []string
+		// placedOn is the host the environment is already deployed on
+		placedOn  string
+		requested string
+		method    domain.DeployMethod
+		policy    *domain.Environment
+		want      string
+		wantErr   error
+	}{
+		{name: "new environment", method: domain.MethodDeploy, want: "deploy-1"},
+		{name: "first host draining", drained: []string{"deploy-1"}, method: domain.MethodDeploy, want: "deploy-2"},
+		{name: "stays on its host", placedOn: "deploy-3", method: domain.MethodDeploy, want: "deploy-3"},
+		{name: "moves off a draining host", placedOn: "deploy-3", drained: []string{"deploy-3"}, method: domain.MethodDeploy, want: "deploy-1"},
+		{name: "requested host", requested: "deploy-2", placedOn: "deploy-3", method: domain.MethodDeploy, want: "deploy-2"},
+		{name: "requested host draining", requested: "deploy-2", drained: []string{"deploy-2"}, method: domain.MethodDeploy, want: "deploy-1"},
+		{name: "hosts of the policy", method: domain.MethodDeploy, policy: &domain.Environment{Name: "staging", Hosts: []string{"deploy-3"}}, want: "deploy-3"},
+		{name: "placed outside the policy", placedOn: "deploy-1", method: domain.MethodDeploy, policy: &domain.Environment{Name: "staging", Hosts: []string{"deploy-2"}}, want: "deploy-2"},
+		{name: "every host draining", drained: []string{"deploy-1", "deploy-2", "deploy-3"}, method: domain.MethodDeploy, wantErr: domain.ErrNoHostAvailable},
+		{name: "every host of the policy draining", drained: []string{"deploy-2"}, method: domain.MethodDeploy, policy: &domain.Environment{Name: "staging", Hosts: []string{"deploy-2"}}, wantErr: domain.ErrNoHostAvailable},
+		{name: "cleanup on its host", placedOn: "deploy-3", drained: []string{"deploy-3"}, method: domain.MethodCleanup, want: "deploy-3"},
+		{name: "cleanup without placement", method: domain.MethodCleanup, want: "deploy-1"},
+		{name: "cleanup on the requested host", requested: "deploy-2", method: domain.MethodCleanup, want: "deploy-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			selector, store := newTestSelector(t, nil, tt.drained...)
+			req := testRequest(tt.method, "staging", "")
+			req.EnvironmentPolicy = tt.policy
+			if tt.placedOn != "" {
+				placed := req
+				placed.Host = &domain.DeployHost{Name: tt.placedOn}
+				if err := store.PutPlacement(ctx, newPlacement(placed)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.requested != "" {
+				req.Host = &domain.DeployHost{Name: tt.requested}
+			}
+
+			placed, err := selector.Place(ctx, req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if placed.Host == nil || placed.Host.Name != tt.want {
+				t.Errorf("placed on %+v, want %s", placed.Host, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorPlacesPreviewsOnTheLeastLoadedHost(t *testing.T) {
+	loads := []domain.HostLoad{
+		{Host: "deploy-1", Load1: 3, CPUs: 2},
+		{Host: "deploy-2", Load1: 4, CPUs: 8},
+		{Host: "deploy-3", Load1: 0, Error: "unreachable"},
+	}
+	tests := []struct {
+		name        string
+		load        LoadQuerier
+		environment string
+		drained     []string
+		want        string
+	}{
+		{"least loaded", fakeLoad{loads: loads}, previewEnvironment, nil, "deploy-2"},
+		{"other environments", fakeLoad{loads: loads}, "staging", nil, "deploy-1"},
+		{"load unavailable", fakeLoad{err: errors.New("timeout")}, previewEnvironment, nil, "deploy-1"},
+		{"no load reported", fakeLoad{loads: loads[2:]}, previewEnvironment, nil, "deploy-1"},
+		{"least loaded draining", fakeLoad{loads: loads}, previewEnvironment, []string{"deploy-2"}, "deploy-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, _ := newTestSelector(t, tt.load, tt.drained...)
+			placed, err := selector.Place(context.Background(), testRequest(domain.MethodDeploy, tt.environment, "7"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if placed.Host.Name != tt.want {
+				t.Errorf("placed on %s, want %s", placed.Host.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorEnforcesQuota(t *testing.T) {
+	ctx := context.Background()
+	selector, _ := newTestSelector(t, nil)
+	policy := &domain.Environment{Name: previewEnvironment, Quota: 2}
+	request := func(pr string) domain.DeployRequest {
+		req := testRequest(domain.MethodDeploy, previewEnvironment, pr)
+		req.EnvironmentPolicy = policy
+		return req
+	}
+
+	// Placing reserves the quota before the deployments are recorded
+	for _, pr := range []string{"1", "2"} {
+		if _, err := selector.Place(ctx, request(pr)); err != nil {
+			t.Fatalf("pull request %s: %v", pr, err)
+		}
+	}
+	if _, err := selector.Place(ctx, request("3")); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("third environment: %v, want %v", err, domain.ErrQuotaExceeded)
+	}
+
+	// Environments already deployed are redeployed over the quota
+	if _, err := selector.Place(ctx, request("1")); err != nil {
+		t.Errorf("redeploying a placed environment: %v", err)
+	}
+
+	// A deployment that failed to start frees its reservation, but only its own
+	other := request("2")
+	other.TraceID = "another-run"
+	selector.Release(ctx, other)
+	if _, err := selector.Place(ctx, request("3")); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("release of another run freed the quota: %v", err)
+	}
+	selector.Release(ctx, request("2"))
+	if _, err := selector.Place(ctx, request("3")); err != nil {
+		t.Errorf("third environment after a release: %v", err)
+	}
+}
+
+func TestSelectorRecord(t *testing.T) {
+	ctx := context.Background()
+	selector, store := newTestSelector(t, nil)
+	deploy := testRequest(domain.MethodDeploy, "staging", "")
+	key := domain.PlacementKey(deploy)
+
+	deploy.Host = &domain.DeployHost{Name: "deploy-2"}
+	selector.Record(ctx, deploy)
+	placement, placed, err := store.GetPlacement(ctx, key)
+	if err != nil || !placed || placement.Host != "deploy-2" {
+		t.Fatalf("placement %+v, %v, %v, want deploy-2", placement, placed, err)
+	}
+
+	// The cleanup of the old host of a migrated environment keeps the placement
+	cleanup := testRequest(domain.MethodCleanup, "staging", "")
+	cleanup.Host = &domain.DeployHost{Name: "deploy-1"}
+	selector.Record(ctx, cleanup)
+	if _, placed, err := store.GetPlacement(ctx, key); err != nil || !placed {
+		t.Fatalf("cleanup of another host removed the placement: %v, %v", placed, err)
+	}
+
+	cleanup.Host = &domain.DeployHost{Name: "deploy-2"}
+	selector.Record(ctx, cleanup)
+	if _, placed, err := store.GetPlacement(ctx, key); err != nil || placed {
+		t.Errorf("cleanup kept the placement: %v, %v", placed, err)
+	}
+}
+
+func TestSelectorDestination(t *testing.T) {
+	policy := &domain.Environment{Name: "staging", Hosts: []string{"deploy-1", "deploy-2"}}
+	tests := []struct {
+		name    string
+		to      string
+		policy  *domain.Environment
+		drained []string
+		want    string
+		wantErr error
+	}{
+		{name: "named host", to: "deploy-3", want: "deploy-3"},
+		{name: "unknown host", to: "deploy-9", wantErr: ErrUnknownHost},
+		{name: "same host", to: "deploy-1", wantErr: ErrSameHost},
+		{name: "draining host", to: "deploy-2", drained: []string{"deploy-2"}, wantErr: ErrHostDraining},
+		{name: "host outside the policy", to: "deploy-3", policy: policy, wantErr: ErrHostNotAllowed},
+		{name: "another host", want: "deploy-2"},
+		{name: "another host not draining", drained: []string{"deploy-2"}, want: "deploy-3"},
+		{name: "no other host of the policy", drained: []string{"deploy-2"}, policy: policy, wantErr: domain.ErrNoHostAvailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, _ := newTestSelector(t, nil, tt.drained...)
+			host, err := selector.Destination(context.Background(), "staging", tt.policy, "deploy-1", tt.to)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if host.Name != tt.want {
+				t.Errorf("migrating to %s, want %s", host.Name, tt.want)
+			}
+		})
+	}
+}
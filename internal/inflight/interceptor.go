@@ -0,0 +1,61 @@
+package inflight
+
+import (
+	"context"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+)
+
+// callTimeout bounds a Temporal call once it no longer follows the cancellation of its caller
+const callTimeout = 30 * time.Second
+
+// clientInterceptor tracks the workflow starts and signals of a Temporal client
+type clientInterceptor struct {
+	interceptor.ClientInterceptorBase
+	tracker *Tracker
+}
+
+// NewClientInterceptor returns a client interceptor recording the workflow starts and
+// signals of a client in tracker. The calls are detached from the cancellation of their
+// context, bounded by callTimeout instead: a request the API accepted still starts its
+// workflow when the caller disconnects, e.g. a GitHub webhook delivery timing out during
+// a burst, and the API waits for them on shutdown before closing its clients.
+func NewClientInterceptor(tracker *Tracker) interceptor.ClientInterceptor {
+	return &clientInterceptor{tracker: tracker}
+}
+
+// InterceptClient wraps the outbound calls of a client
+func (i *clientInterceptor) InterceptClient(next interceptor.ClientOutboundInterceptor) interceptor.ClientOutboundInterceptor {
+	return &clientOutbound{
+		ClientOutboundInterceptorBase: interceptor.ClientOutboundInterceptorBase{Next: next},
+		tracker:                       i.tracker,
+	}
+}
+
+type clientOutbound struct {
+	interceptor.ClientOutboundInterceptorBase
+	tracker *Tracker
+}
+
+// ExecuteWorkflow starts a workflow, tracked and detached from the caller's cancellation
+func (o *clientOutbound) ExecuteWorkflow(ctx context.Context, in *interceptor.ClientExecuteWorkflowInput) (client.WorkflowRun, error) {
+	defer o.tracker.Start()()
+	ctx, cancel := detach(ctx)
+	defer cancel()
+	return o.Next.ExecuteWorkflow(ctx, in)
+}
+
+// SignalWorkflow signals a workflow, tracked and detached from the caller's cancellation
+func (o *clientOutbound) SignalWorkflow(ctx context.Context, in *interceptor.ClientSignalWorkflowInput) error {
+	defer o.tracker.Start()()
+	ctx, cancel := detach(ctx)
+	defer cancel()
+	return o.Next.SignalWorkflow(ctx, in)
+}
+
+// detach returns a context with the values of ctx that is only cancelled after callTimeout
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), callTimeout)
+}
@@ -0,0 +1,73 @@
+// Package inflight tracks the requests and Temporal calls the API is in the middle of, so a
+// shutdown can wait for them instead of dropping webhooks it already accepted.
+package inflight
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracker counts the operations in flight
+type Tracker struct {
+	mu     sync.Mutex
+	active int
+	// idle are closed once no operation is in flight
+	idle []chan struct{}
+}
+
+// NewTracker creates a new tracker
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// Start records an operation in flight; the returned function records that it finished
+func (t *Tracker) Start() func() {
+	t.mu.Lock()
+	t.active++
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(t.finish)
+	}
+}
+
+func (t *Tracker) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.active--
+	if t.active > 0 {
+		return
+	}
+	for _, idle := range t.idle {
+		close(idle)
+	}
+	t.idle = nil
+}
+
+// Active returns the number of operations in flight
+func (t *Tracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
+
+// Wait waits until no operation is in flight, or returns the error of ctx when it is done first
+func (t *Tracker) Wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.active == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	idle := make(chan struct{})
+	t.idle = append(t.idle, idle)
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
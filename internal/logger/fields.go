@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+
+	"go.temporal.io/sdk/log"
+	"go.uber.org/zap"
+)
+
+// Field names of the log schema shared by the API and the worker. They follow the
+// ECS and OpenTelemetry semantic conventions where those define a field, and use the
+// deployment and temporal namespaces otherwise.
+const (
+	FieldTimestamp = "@timestamp"
+	FieldLevel     = "log.level"
+	FieldMessage   = "message"
+	FieldLogger    = "log.logger"
+	FieldCaller    = "log.origin.file.name"
+	FieldStack     = "error.stack_trace"
+
+	FieldServiceName    = "service.name"
+	FieldServiceVersion = "service.version"
+
+	FieldTraceID     = "deployment.trace_id"
+	FieldRepo        = "vcs.repository.name"
+	FieldEnvironment = "deployment.environment.name"
+	FieldComponent   = "deployment.component"
+	FieldProject     = "deployment.project"
+
+	FieldError = "error.message"
+)
+
+// fieldNames maps the keys used at call sites to the names of the log schema.
+// Keys that aren't listed are logged as they are.
+var fieldNames = map[string]string{
+	// Deployment
+	"trace_id":      FieldTraceID,
+	"repo":          FieldRepo,
+	"environment":   FieldEnvironment,
+	"component":     FieldComponent,
+	"project":       FieldProject,
+	"deploy_method": "deployment.method",
+	"commit":        "vcs.ref.head.revision",
+	"branch":        "vcs.ref.head.name",
+
+	// HTTP
+	"method":      "http.request.method",
+	"path":        "url.path",
+	"url":         "url.full",
+	"status_code": "http.response.status_code",
+
+	// Errors, as logged by zap.Error
+	"error": FieldError,
+
+	// Build; the version is on every line as service.version
+	"commit_hash": "service.commit_hash",
+	"build_time":  "service.build_time",
+
+	// Temporal, including the keys of the Temporal SDK's own log lines
+	"workflow_id":  "temporal.workflow.id",
+	"run_id":       "temporal.run.id",
+	"Namespace":    "temporal.namespace",
+	"TaskQueue":    "temporal.task_queue",
+	"WorkflowID":   "temporal.workflow.id",
+	"RunID":        "temporal.run.id",
+	"WorkflowType": "temporal.workflow.type",
+	"ActivityID":   "temporal.activity.id",
+	"ActivityType": "temporal.activity.type",
+	"Attempt":      "temporal.attempt",
+}
+
+// DeploymentFields returns the fields identifying the deployment of req
+func DeploymentFields(req domain.DeployRequest) []zap.Field {
+	return []zap.Field{
+		zap.String(FieldTraceID, req.TraceID),
+		zap.String(FieldRepo, req.Source.Repo),
+		zap.String(FieldEnvironment, req.Metadata.Environment),
+		zap.String(FieldComponent, req.Metadata.Component),
+		zap.String(FieldProject, req.Metadata.ProjectName),
+	}
+}
+
+// ForDeployment returns a logger scoped to the deployment of req, e.g. in a handler
+func ForDeployment(logger *zap.Logger, req domain.DeployRequest) *zap.Logger {
+	return logger.With(DeploymentFields(req)...)
+}
+
+// WithDeployment returns a Temporal logger scoped to the deployment of req, for workflows
+// (workflow.GetLogger) and activities (activity.GetLogger)
+func WithDeployment(logger log.Logger, req domain.DeployRequest) log.Logger {
+	return log.With(logger,
+		FieldTraceID, req.TraceID,
+		FieldRepo, req.Source.Repo,
+		FieldEnvironment, req.Metadata.Environment,
+		FieldComponent, req.Metadata.Component,
+		FieldProject, req.Metadata.ProjectName,
+	)
+}
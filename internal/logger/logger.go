@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New creates the logger of a binary. JSON logs use the field names of the log schema
+// (see fields.go), so both binaries can be queried alike in Loki or Elasticsearch.
+// Logs are written to every sink in cfg.Outputs. The returned function syncs the logger and
+// stops the background pushes of the sinks, pushing the lines they buffered until ctx is done.
+func New(cfg config.LoggerConfig, service, version string) (*zap.Logger, func(ctx context.Context) error, error) {
+	console := cfg.Format == "console"
+
+	level := zapcore.InfoLevel
+	if console {
+		level = zapcore.DebugLevel
+	}
+	if cfg.Level != "" {
+		parsed, err := zapcore.ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+		}
+		level = parsed
+	}
+
+	var encoder zapcore.Encoder
+	if console {
+		encoder = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	} else {
+		encoder = zapcore.NewJSONEncoder(encoderConfig())
+	}
+
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{config.LogOutputStderr}
+	}
+	var writers []zapcore.WriteSyncer
+	var syslogEnabled, lokiEnabled bool
+	for _, output := range outputs {
+		switch output {
+		case config.LogOutputStderr:
+			writers = append(writers, zapcore.Lock(os.Stderr))
+		case config.LogOutputStdout:
+			writers = append(writers, zapcore.Lock(os.Stdout))
+		case config.LogOutputFile:
+			file, err := newRotatingFile(cfg.File)
+			if err != nil {
+				return nil, nil, err
+			}
+			writers = append(writers, file)
+		case config.LogOutputSyslog:
+			syslogEnabled = true
+		case config.LogOutputLoki:
+			lokiEnabled = true
+		default:
+			return nil, nil, fmt.Errorf("unknown log output %q", output)
+		}
+	}
+
+	var cores []zapcore.Core
+	var pushers []*lokiPusher
+	if len(writers) > 0 {
+		cores = append(cores, zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(writers...), level))
+	}
+	if syslogEnabled {
+		// syslog adds its own timestamp and severity
+		syslogConfig := encoderConfig()
+		syslogConfig.TimeKey = zapcore.OmitKey
+		syslogConfig.LevelKey = zapcore.OmitKey
+		syslogCore, err := newSyslogCore(cfg.Syslog, service, zapcore.NewJSONEncoder(syslogConfig), level)
+		if err != nil {
+			return nil, nil, err
+		}
+		cores = append(cores, syslogCore)
+	}
+	if lokiEnabled {
+		// Loki stores its own timestamp with each line
+		lokiConfig := encoderConfig()
+		lokiConfig.TimeKey = zapcore.OmitKey
+		lokiCore, pusher := newLokiCore(cfg.Loki, service, zapcore.NewJSONEncoder(lokiConfig), level)
+		cores = append(cores, lokiCore)
+		pushers = append(pushers, pusher)
+	}
+
+	// Sample after renaming, so the rename doesn't bypass the sampler
+	var core zapcore.Core = &renameCore{Core: zapcore.NewTee(cores...)}
+	options := []zap.Option{zap.AddCaller(), zap.ErrorOutput(zapcore.Lock(os.Stderr))}
+	if console {
+		options = append(options, zap.Development(), zap.AddStacktrace(zapcore.WarnLevel))
+	} else {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, 100, 100)
+		options = append(options, zap.AddStacktrace(zapcore.ErrorLevel))
+	}
+
+	logger := zap.New(core, options...).With(
+		zap.String(FieldServiceName, service),
+		zap.String(FieldServiceVersion, version),
+	)
+	closeLogger := func(ctx context.Context) error {
+		// Syncing stderr fails on some terminals, which isn't worth reporting
+		_ = logger.Sync()
+		var errs []error
+		for _, pusher := range pushers {
+			errs = append(errs, pusher.close(ctx))
+		}
+		return errors.Join(errs...)
+	}
+	return logger, closeLogger, nil
+}
+
+// encoderConfig encodes the fields zap adds itself under the names of the log schema
+func encoderConfig() zapcore.EncoderConfig {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = FieldTimestamp
+	encoderConfig.LevelKey = FieldLevel
+	encoderConfig.MessageKey = FieldMessage
+	encoderConfig.NameKey = FieldLogger
+	encoderConfig.CallerKey = FieldCaller
+	encoderConfig.StacktraceKey = FieldStack
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	return encoderConfig
+}
+
+// renameCore renames the fields of every log line to the names of the log schema
+type renameCore struct {
+	zapcore.Core
+}
+
+func (c *renameCore) With(fields []zapcore.Field) zapcore.Core {
+	return &renameCore{Core: c.Core.With(rename(fields))}
+}
+
+func (c *renameCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *renameCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, rename(fields))
+}
+
+// rename returns fields with the keys mapped by fieldNames
+func rename(fields []zapcore.Field) []zapcore.Field {
+	renamed := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		if name, ok := fieldNames[field.Key]; ok {
+			field.Key = name
+		}
+		renamed[i] = field
+	}
+	return renamed
+}
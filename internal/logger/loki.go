@@ -0,0 +1,281 @@
+package logger
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// lokiMaxBatch is the number of buffered lines that triggers a push before BatchWait has passed
+const lokiMaxBatch = 1000
+
+// lokiMaxBuffered bounds the lines buffered between pushes, across all streams; beyond it,
+// each new line drops the oldest one of its stream, or of the largest stream if its own is empty
+const lokiMaxBuffered = 10000
+
+// lokiSyncTimeout bounds the push of the buffered lines on Sync
+const lokiSyncTimeout = 5 * time.Second
+
+// lokiLabels maps the log schema fields that become Loki stream labels to their label names.
+// Only fields with a few values are labels, since every combination of labels is a stream of
+// its own; everything else, e.g. the project, the workflow ID, and the trace ID, stays in the
+// JSON line, where LogQL's json parser finds it.
+var lokiLabels = map[string]string{
+	FieldEnvironment: "environment",
+}
+
+// lokiCore pushes log entries to Loki, labeled by service, level, and environment
+type lokiCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	labels  map[string]string
+	pusher  *lokiPusher
+}
+
+// newLokiCore starts pushing the entries of the core to the Loki of cfg. The pusher is
+// returned so the logger can stop it on shutdown.
+func newLokiCore(cfg config.LogLokiConfig, service string, encoder zapcore.Encoder, level zapcore.LevelEnabler) (zapcore.Core, *lokiPusher) {
+	pusher := newLokiPusher(cfg)
+	go pusher.run()
+	return &lokiCore{
+		LevelEnabler: level,
+		encoder:      encoder,
+		labels:       map[string]string{"service": service},
+		pusher:       pusher,
+	}, pusher
+}
+
+func (c *lokiCore) With(fields []zapcore.Field) zapcore.Core {
+	encoder := c.encoder.Clone()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	return &lokiCore{
+		LevelEnabler: c.LevelEnabler,
+		encoder:      encoder,
+		labels:       withLabels(c.labels, fields),
+		pusher:       c.pusher,
+	}
+}
+
+func (c *lokiCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *lokiCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	line := strings.TrimSuffix(buf.String(), "\n")
+	buf.Free()
+
+	labels := withLabels(c.labels, fields)
+	labels["level"] = entry.Level.String()
+	c.pusher.add(labels, entry.Time, line)
+	return nil
+}
+
+// Sync pushes the buffered lines, giving up after lokiSyncTimeout
+func (c *lokiCore) Sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), lokiSyncTimeout)
+	defer cancel()
+	return c.pusher.push(ctx)
+}
+
+// withLabels returns labels with the stream labels among fields added
+func withLabels(labels map[string]string, fields []zapcore.Field) map[string]string {
+	result := make(map[string]string, len(labels)+len(lokiLabels)+1)
+	for name, value := range labels {
+		result[name] = value
+	}
+	for _, field := range fields {
+		if name, ok := lokiLabels[field.Key]; ok && field.Type == zapcore.StringType && field.String != "" {
+			result[name] = field.String
+		}
+	}
+	return result
+}
+
+// lokiStream is a stream of the Loki push API
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiPusher batches log lines by stream and pushes them to Loki
+type lokiPusher struct {
+	config config.LogLokiConfig
+	client *http.Client
+	flush  chan struct{}
+	// stop is closed to stop run, which closes done once it returned
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu      sync.Mutex
+	streams map[string]*lokiStream
+	count   int
+}
+
+// newLokiPusher creates a pusher to the Loki of cfg; run starts pushing
+func newLokiPusher(cfg config.LogLokiConfig) *lokiPusher {
+	return &lokiPusher{
+		config: cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		flush:  make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// add buffers a line of the stream with labels
+func (p *lokiPusher) add(labels map[string]string, at time.Time, line string) {
+	key := streamKey(labels)
+
+	p.mu.Lock()
+	if p.streams == nil {
+		p.streams = make(map[string]*lokiStream)
+	}
+	stream, ok := p.streams[key]
+	if !ok {
+		stream = &lokiStream{Stream: labels}
+		p.streams[key] = stream
+	}
+	if p.count >= lokiMaxBuffered {
+		p.dropOldest(stream)
+	}
+	stream.Values = append(stream.Values, [2]string{strconv.FormatInt(at.UnixNano(), 10), line})
+	p.count++
+	full := p.count >= lokiMaxBatch
+	p.mu.Unlock()
+
+	if full {
+		select {
+		case p.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// dropOldest drops the oldest line of stream, or of the largest stream if stream is empty,
+// so a new line keeps the buffer within lokiMaxBuffered. p.mu must be held.
+func (p *lokiPusher) dropOldest(stream *lokiStream) {
+	if len(stream.Values) == 0 {
+		for _, candidate := range p.streams {
+			if len(candidate.Values) > len(stream.Values) {
+				stream = candidate
+			}
+		}
+	}
+	if len(stream.Values) > 0 {
+		stream.Values = stream.Values[1:]
+		p.count--
+	}
+}
+
+// run pushes the buffered lines every BatchWait, or sooner when a batch is full, until close is called
+func (p *lokiPusher) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.config.BatchWait)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		case <-p.flush:
+		}
+		if err := p.push(context.Background()); err != nil {
+			// The logger can't log its own failures, so they go to stderr like zap's
+			fmt.Fprintf(os.Stderr, "failed to push logs to Loki: %v\n", err)
+		}
+	}
+}
+
+// close stops run and pushes the lines buffered since, until ctx is done. Lines written
+// afterwards are only pushed by Sync.
+func (p *lokiPusher) close(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop pushing logs to Loki: %w", ctx.Err())
+	}
+	if err := p.push(ctx); err != nil {
+		return fmt.Errorf("failed to push logs to Loki: %w", err)
+	}
+	return nil
+}
+
+// push sends the buffered lines to Loki; lines Loki rejects or that can't be sent are dropped
+func (p *lokiPusher) push(ctx context.Context) error {
+	p.mu.Lock()
+	streams := make([]*lokiStream, 0, len(p.streams))
+	for _, stream := range p.streams {
+		// Streams emptied by dropOldest
+		if len(stream.Values) > 0 {
+			streams = append(streams, stream)
+		}
+	}
+	p.streams = nil
+	p.count = 0
+	p.mu.Unlock()
+	if len(streams) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]any{"streams": streams})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.config.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", p.config.TenantID)
+	}
+	if p.config.Username != "" {
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("loki returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// streamKey identifies the stream of labels
+func streamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var key strings.Builder
+	for _, name := range names {
+		key.WriteString(name + "=" + labels[name] + ",")
+	}
+	return key.String()
+}
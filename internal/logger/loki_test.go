@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// lokiServer records the lines pushed to it
+type lokiServer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (s *lokiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stream := range body.Streams {
+		if len(stream.Values) == 0 {
+			http.Error(w, "stream without entries", http.StatusBadRequest)
+			return
+		}
+		for _, value := range stream.Values {
+			s.lines = append(s.lines, value[1])
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *lokiServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.lines)
+}
+
+func TestLokiPusherBufferCapAcrossStreams(t *testing.T) {
+	p := newLokiPusher(config.LogLokiConfig{BatchWait: time.Hour})
+	now := time.Now()
+
+	for i := 0; i < lokiMaxBuffered; i++ {
+		p.add(map[string]string{"stream": "busy"}, now, strconv.Itoa(i))
+	}
+	// Every new stream must still stay within the cap
+	for i := 0; i < 100; i++ {
+		p.add(map[string]string{"stream": strconv.Itoa(i)}, now, "new")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total := 0
+	for _, stream := range p.streams {
+		total += len(stream.Values)
+	}
+	if total != lokiMaxBuffered || p.count != lokiMaxBuffered {
+		t.Fatalf("buffered %d lines (count %d), want %d", total, p.count, lokiMaxBuffered)
+	}
+	if first := p.streams[streamKey(map[string]string{"stream": "busy"})].Values[0][1]; first != "100" {
+		t.Errorf("oldest remaining line of the busy stream is %q, want 100", first)
+	}
+}
+
+func TestLokiPusherCloseFlushes(t *testing.T) {
+	server := &lokiServer{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	p := newLokiPusher(config.LogLokiConfig{URL: httpServer.URL, BatchWait: time.Hour})
+	go p.run()
+	for i := 0; i < 10; i++ {
+		p.add(map[string]string{"service": "test"}, time.Now(), strconv.Itoa(i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.close(ctx); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	select {
+	case <-p.done:
+	default:
+		t.Fatal("run didn't exit after close")
+	}
+	if got := server.count(); got != 10 {
+		t.Errorf("Loki received %d lines, want 10", got)
+	}
+}
+
+func TestLokiPusherPushHonorsDeadline(t *testing.T) {
+	release := make(chan struct{})
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer httpServer.Close()
+	defer close(release)
+
+	p := newLokiPusher(config.LogLokiConfig{URL: httpServer.URL, BatchWait: time.Hour})
+	p.add(map[string]string{"service": "test"}, time.Now(), "line")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := p.push(ctx); err == nil {
+		t.Fatal("expected push to fail once its deadline passed")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("push took %v despite its deadline", elapsed)
+	}
+}
+
+func TestWithLabelsKeepsUnboundedFieldsInTheLine(t *testing.T) {
+	labels := withLabels(map[string]string{"service": "worker"}, []zapcore.Field{
+		zap.String(FieldEnvironment, "production"),
+		zap.String(FieldProject, "app"),
+		zap.String("temporal.workflow.id", "deploy-123"),
+		zap.String("trace_id", "123"),
+	})
+	want := map[string]string{"service": "worker", "environment": "production"}
+	if !maps.Equal(labels, want) {
+		t.Errorf("labels %v, want %v", labels, want)
+	}
+}
@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the suffix of rotated log files
+const backupTimeFormat = "20060102T150405.000"
+
+// rotatingFile is a log file that is rotated when it reaches its size limit.
+// Rotated files are renamed to <path>.<time> and removed once they exceed the age
+// or count limits.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// newRotatingFile opens the log file of cfg for appending
+func newRotatingFile(cfg config.LogFileConfig) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       cfg.Path,
+		maxSize:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxAge:     cfg.MaxAge,
+		maxBackups: cfg.MaxBackups,
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first if p would exceed the size limit
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Sync flushes the file to disk
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Sync()
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file, opens a new one, and removes expired backups
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	backup := f.path + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// prune removes the backups beyond the age and count limits. Failures are ignored,
+// the next rotation tries again.
+func (f *rotatingFile) prune() {
+	backups, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	// The time suffix sorts oldest first
+	sort.Strings(backups)
+
+	var keep []string
+	for _, backup := range backups {
+		suffix := backup[len(f.path)+1:]
+		rotatedAt, err := time.Parse(backupTimeFormat, suffix)
+		if err != nil {
+			continue
+		}
+		if f.maxAge > 0 && time.Since(rotatedAt) > f.maxAge {
+			os.Remove(backup)
+			continue
+		}
+		keep = append(keep, backup)
+	}
+	if f.maxBackups > 0 && len(keep) > f.maxBackups {
+		for _, backup := range keep[:len(keep)-f.maxBackups] {
+			os.Remove(backup)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows || plan9
+
+package logger
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// newSyslogCore fails, syslog isn't available on this platform
+func newSyslogCore(cfg config.LogSyslogConfig, tag string, encoder zapcore.Encoder, level zapcore.LevelEnabler) (zapcore.Core, error) {
+	return nil, fmt.Errorf("syslog is not supported on this platform")
+}
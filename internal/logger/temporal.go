@@ -40,32 +40,30 @@ func (z *ZapLoggerAdapter) Error(msg string, keyvals ...interface{}) {
 
 // With creates a new logger with additional key-value pairs
 func (z *ZapLoggerAdapter) With(keyvals ...interface{}) log.Logger {
-	fields := make([]zap.Field, 0, len(keyvals)/2)
-	for i := 0; i < len(keyvals)-1; i += 2 {
-		key, ok := keyvals[i].(string)
-		if !ok {
-			continue
-		}
-		value := keyvals[i+1]
-		fields = append(fields, zap.Any(key, value))
-	}
 	return &ZapLoggerAdapter{
-		logger: z.logger.With(fields...),
+		logger: z.logger.With(toFields(keyvals)...),
 	}
 }
 
 func (z *ZapLoggerAdapter) log(level zapcore.Level, msg string, keyvals ...interface{}) {
-	fields := make([]zap.Field, 0, len(keyvals)/2+1)
-	fields = append(fields, zap.String("message", msg))
+	z.logger.Log(level, msg, toFields(keyvals)...)
+}
 
-	for i := 0; i < len(keyvals)-1; i += 2 {
+// toFields converts key-value pairs to zap fields. zap fields passed as-is, as activities
+// do with activity.GetLogger, are kept.
+func toFields(keyvals []interface{}) []zap.Field {
+	fields := make([]zap.Field, 0, len(keyvals)/2)
+	for i := 0; i < len(keyvals); i++ {
+		if field, ok := keyvals[i].(zap.Field); ok {
+			fields = append(fields, field)
+			continue
+		}
 		key, ok := keyvals[i].(string)
-		if !ok {
+		if !ok || i+1 >= len(keyvals) {
 			continue
 		}
-		value := keyvals[i+1]
-		fields = append(fields, zap.Any(key, value))
+		fields = append(fields, zap.Any(key, keyvals[i+1]))
+		i++
 	}
-
-	z.logger.Log(level, msg, fields...)
+	return fields
 }
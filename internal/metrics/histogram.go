@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of histograms timing deployment steps,
+// from a DNS change to a long build
+var DurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// HistogramVec is a histogram partitioned by label values
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+// histogram holds the observations of one time series
+type histogram struct {
+	labelValues []string
+	// counts holds the number of observations of each bucket, not cumulated
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram with the given bucket upper bounds and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	vec := &HistogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+	r.register(vec)
+	return vec
+}
+
+// Observe adds value to the histogram of the given label values, in the order of the label names
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	checkLabelValues(h.name, h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := series(h.labels, labelValues)
+	values, ok := h.values[s]
+	if !ok {
+		values = &histogram{
+			labelValues: slices.Clone(labelValues),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[s] = values
+	}
+
+	if i, _ := slices.BinarySearch(h.buckets, value); i < len(h.buckets) {
+		values.counts[i]++
+	}
+	values.count++
+	values.sum += value
+}
+
+// write writes the histogram in the text exposition format
+func (h *HistogramVec) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(h.values))
+	for s := range h.values {
+		keys = append(keys, s)
+	}
+	slices.Sort(keys)
+	bucketLabels := append(slices.Clone(h.labels), "le")
+	for _, s := range keys {
+		values := h.values[s]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += values.counts[i]
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, series(bucketLabels, append(slices.Clone(values.labelValues), le)), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %g\n%s_count%s %d\n",
+			h.name, series(bucketLabels, append(slices.Clone(values.labelValues), "+Inf")), values.count,
+			h.name, s, values.sum,
+			h.name, s, values.count,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
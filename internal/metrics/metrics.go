@@ -0,0 +1,134 @@
+// Package metrics keeps in-process counters and histograms and serves them in the Prometheus text
+// exposition format, so the service can be scraped without a metrics client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Registry holds the metrics served by a process
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// metric is a counter or a histogram of the registry
+type metric interface {
+	// write writes the metric in the text exposition format
+	write(w io.Writer) error
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// CounterVec is a counter partitioned by label values
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec registers a counter with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	counter := &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+
+	r.register(counter)
+	return counter
+}
+
+// register adds m to the metrics served by the registry
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Inc increments the counter of the given label values, in the order of the label names
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta to the counter of the given label values
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	checkLabelValues(c.name, c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[series(c.labels, labelValues)] += delta
+}
+
+// checkLabelValues panics unless a label value is given for each label name of a metric
+func checkLabelValues(name string, labels, labelValues []string) {
+	if len(labelValues) != len(labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", name, len(labels), len(labelValues)))
+	}
+}
+
+// series formats the label set of a time series, e.g. {channel="discord"}
+func series(labels, labelValues []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf("%s=%q", label, labelValues[i])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// write writes the counter in the text exposition format
+func (c *CounterVec) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+		return err
+	}
+
+	series := make([]string, 0, len(c.values))
+	for s := range c.values {
+		series = append(series, s)
+	}
+	slices.Sort(series)
+	for _, s := range series {
+		if _, err := fmt.Fprintf(w, "%s%s %g\n", c.name, s, c.values[s]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteText writes every registered metric in the text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleMetrics serves the registered metrics to a Prometheus scraper
+func (r *Registry) HandleMetrics(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
@@ -1,27 +1,83 @@
 package middleware
 
 import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"crypto/subtle"
 	"net/http"
 
 	"go.uber.org/zap"
 )
 
-// AuthMiddleware validates the deploy token
+// Role is the permission level granted by a token
+type Role int
+
+const (
+	// RoleViewer can only read deployment status
+	RoleViewer Role = iota + 1
+	// RoleDeploy can start, redeploy, and operate on deployments
+	RoleDeploy
+	// RoleAdmin can additionally use the admin API
+	RoleAdmin
+)
+
+// String returns the name of the role
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleDeploy:
+		return "deploy"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// authToken is a token and the role it grants
+type authToken struct {
+	token []byte
+	role  Role
+}
+
+// AuthMiddleware validates tokens and the roles they grant
 type AuthMiddleware struct {
-	deployToken string
-	logger      *zap.Logger
+	tokens []authToken
+	logger *zap.Logger
 }
 
 // NewAuthMiddleware creates a new auth middleware
-func NewAuthMiddleware(deployToken string, logger *zap.Logger) *AuthMiddleware {
-	return &AuthMiddleware{
-		deployToken: deployToken,
-		logger:      logger,
+func NewAuthMiddleware(authConfig config.AuthConfig, logger *zap.Logger) *AuthMiddleware {
+	m := &AuthMiddleware{logger: logger}
+	m.addToken(authConfig.AdminToken, RoleAdmin)
+	m.addToken(authConfig.DeployToken, RoleDeploy)
+	for _, token := range authConfig.ViewerTokens {
+		m.addToken(token, RoleViewer)
+	}
+	return m
+}
+
+func (m *AuthMiddleware) addToken(token string, role Role) {
+	if token == "" {
+		return
 	}
+	m.tokens = append(m.tokens, authToken{token: []byte(token), role: role})
 }
 
-// Middleware validates the x-deploy-token header
-func (m *AuthMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+// roleOf returns the role granted by token, or 0 if the token is unknown
+func (m *AuthMiddleware) roleOf(token string) Role {
+	var role Role
+	for _, t := range m.tokens {
+		// Compare against every token so the response time doesn't reveal which one matched
+		if subtle.ConstantTimeCompare([]byte(token), t.token) == 1 && t.role > role {
+			role = t.role
+		}
+	}
+	return role
+}
+
+// Middleware validates the x-deploy-token header and requires a token granting at least role
+func (m *AuthMiddleware) Middleware(role Role, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token := r.Header.Get("x-deploy-token")
 		if token == "" {
@@ -30,12 +86,24 @@ func (m *AuthMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		if token != m.deployToken {
+		granted := m.roleOf(token)
+		if granted == 0 {
 			m.logger.Warn("Invalid deploy token")
 			http.Error(w, "Unauthorized: invalid deploy token", http.StatusUnauthorized)
 			return
 		}
 
+		if granted < role {
+			m.logger.Warn("Token role not allowed",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("role", granted.String()),
+				zap.String("required_role", role.String()),
+			)
+			http.Error(w, "Forbidden: "+granted.String()+" token cannot perform this operation", http.StatusForbidden)
+			return
+		}
+
 		next(w, r)
 	}
 }
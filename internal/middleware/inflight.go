@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"NYCU-SDC/deployment-service/internal/inflight"
+	"net/http"
+	"sync/atomic"
+)
+
+// InFlightMiddleware tracks the requests being served until their response is written,
+// and reports the API as unavailable once it is shutting down
+type InFlightMiddleware struct {
+	tracker      *inflight.Tracker
+	shuttingDown atomic.Bool
+}
+
+// NewInFlightMiddleware creates a new in-flight middleware recording requests in tracker
+func NewInFlightMiddleware(tracker *inflight.Tracker) *InFlightMiddleware {
+	return &InFlightMiddleware{tracker: tracker}
+}
+
+// Middleware records the request in flight until next returned
+func (m *InFlightMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer m.tracker.Start()()
+		next(w, r)
+	}
+}
+
+// ShutDown marks the API as shutting down; requests in flight and new ones are still served
+func (m *InFlightMiddleware) ShutDown() {
+	m.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether the API is shutting down, e.g. for readiness checks to fail
+// so the load balancer stops sending requests before the listener closes
+func (m *InFlightMiddleware) ShuttingDown() bool {
+	return m.shuttingDown.Load()
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RecoverMiddleware recovers panics of HTTP handlers, answering 500 instead of dropping the connection
+type RecoverMiddleware struct {
+	reporter domain.CrashReporter
+	logger   *zap.Logger
+}
+
+// NewRecoverMiddleware creates a new recover middleware. Panics are reported to reporter unless it is nil.
+func NewRecoverMiddleware(reporter domain.CrashReporter, logger *zap.Logger) *RecoverMiddleware {
+	return &RecoverMiddleware{
+		reporter: reporter,
+		logger:   logger,
+	}
+}
+
+// Middleware recovers a panic of next, logs it with its stack trace, and reports it
+func (m *RecoverMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// Raised by handlers to abort the response on purpose
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			crash := domain.Crash{
+				Message: fmt.Sprint(recovered),
+				Stack:   string(debug.Stack()),
+				Tags: map[string]string{
+					"http.request.method": r.Method,
+					"url.path":            r.URL.Path,
+				},
+				Time: time.Now(),
+			}
+			m.logger.Error("Recovered from panic in HTTP handler",
+				zap.String("panic", crash.Message),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+			)
+			m.report(crash)
+
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+
+		next(w, r)
+	}
+}
+
+// report sends crash to the reporter; the request context may already be cancelled
+func (m *RecoverMiddleware) report(crash domain.Crash) {
+	if m.reporter == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.reporter.ReportCrash(ctx, crash); err != nil {
+		m.logger.Warn("Failed to report panic", zap.Error(err))
+	}
+}
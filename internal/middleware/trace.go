@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -45,8 +46,17 @@ func (m *TraceMiddleware) Middleware(next http.HandlerFunc) http.HandlerFunc {
 		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
 		// Call next handler with trace context
+		start := time.Now()
 		next(rw, r.WithContext(ctx))
 
+		// Access log
+		m.logger.Info("Request completed",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status_code", rw.statusCode),
+			zap.Duration("duration", time.Since(start)),
+		)
+
 		// Set status code attribute
 		span.SetAttributes(attribute.Int("http.status_code", rw.statusCode))
 
@@ -0,0 +1,125 @@
+package namespace
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Router routes the workflows of each environment to its Temporal namespace.
+// Environments without a mapping use the default namespace.
+type Router struct {
+	defaultNamespace string
+	// environments maps environment names to namespaces
+	environments map[string]string
+	// clients maps namespaces to clients sharing the connection of the default client
+	clients map[string]client.Client
+	// converters maps namespaces to the data converters of their clients, if not the default one
+	converters map[string]converter.DataConverter
+}
+
+// NewRouter creates a new namespace router on top of the client of the default namespace.
+// The clients of the other namespaces use their data converter in converters, if any,
+// interceptors, and propagators; the default client must have been created with the same.
+func NewRouter(defaultClient client.Client, temporalConfig config.TemporalConfig, converters map[string]converter.DataConverter, interceptors []interceptor.ClientInterceptor, propagators []workflow.ContextPropagator, logger log.Logger) (*Router, error) {
+	router := &Router{
+		defaultNamespace: temporalConfig.Namespace,
+		environments:     temporalConfig.Namespaces,
+		clients:          map[string]client.Client{temporalConfig.Namespace: defaultClient},
+		converters:       converters,
+	}
+
+	for _, namespace := range temporalConfig.Namespaces {
+		if _, ok := router.clients[namespace]; ok {
+			continue
+		}
+		namespaceClient, err := client.NewClientFromExisting(defaultClient, client.Options{
+			Namespace:          namespace,
+			Logger:             logger,
+			DataConverter:      converters[namespace],
+			Interceptors:       interceptors,
+			ContextPropagators: propagators,
+		})
+		if err != nil {
+			router.Close()
+			return nil, fmt.Errorf("failed to create client for namespace %s: %w", namespace, err)
+		}
+		router.clients[namespace] = namespaceClient
+	}
+
+	return router, nil
+}
+
+// Namespace returns the namespace the workflows of environment run in
+func (r *Router) Namespace(environment string) string {
+	if namespace, ok := r.environments[environment]; ok {
+		return namespace
+	}
+	return r.defaultNamespace
+}
+
+// Client returns the client of the namespace the workflows of environment run in
+func (r *Router) Client(environment string) client.Client {
+	return r.clients[r.Namespace(environment)]
+}
+
+// NamespaceClient returns the client of namespace, or nil if the router doesn't use it
+func (r *Router) NamespaceClient(namespace string) client.Client {
+	return r.clients[namespace]
+}
+
+// DataConverter returns the data converter of the payloads of namespace, e.g. to decode
+// the workflow history read from it
+func (r *Router) DataConverter(namespace string) converter.DataConverter {
+	if dataConverter, ok := r.converters[namespace]; ok {
+		return dataConverter
+	}
+	return converter.GetDefaultDataConverter()
+}
+
+// Namespaces returns every namespace in use, starting with the default namespace
+func (r *Router) Namespaces() []string {
+	namespaces := make([]string, 0, len(r.clients))
+	for namespace := range r.clients {
+		if namespace != r.defaultNamespace {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	slices.Sort(namespaces)
+	return append([]string{r.defaultNamespace}, namespaces...)
+}
+
+// Find looks up a workflow by ID in every namespace, starting with the default namespace.
+// It returns the namespace the workflow was found in, or a NotFound error.
+func (r *Router) Find(ctx context.Context, workflowID string) (string, *workflowservice.DescribeWorkflowExecutionResponse, error) {
+	for _, namespace := range r.Namespaces() {
+		description, err := r.clients[namespace].DescribeWorkflowExecution(ctx, workflowID, "")
+		if err == nil {
+			return namespace, description, nil
+		}
+		var notFound *serviceerror.NotFound
+		if !errors.As(err, &notFound) {
+			return "", nil, err
+		}
+	}
+	return "", nil, serviceerror.NewNotFound(fmt.Sprintf("workflow %s not found in any namespace", workflowID))
+}
+
+// Close closes the clients created by the router. The default client is left to its owner.
+func (r *Router) Close() {
+	for namespace, namespaceClient := range r.clients {
+		if namespace != r.defaultNamespace {
+			namespaceClient.Close()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// maxDomainLength is the longest name DNS can represent
+const maxDomainLength = 253
+
+// DomainPolicy checks that the DNS records a deployment sets up or removes stay inside
+// the allowed domains, so a malformed request can't touch records outside the preview namespace
+type DomainPolicy struct {
+	patterns []string
+	logger   *zap.Logger
+}
+
+// NewDomainPolicy creates a new domain policy with the given patterns.
+// "*.example.com" allows any subdomain of example.com, "example.com" the name itself;
+// no patterns allow every name.
+func NewDomainPolicy(patterns []string, logger *zap.Logger) *DomainPolicy {
+	normalized := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern = normalizeDomain(pattern); pattern != "" {
+			normalized = append(normalized, pattern)
+		}
+	}
+	return &DomainPolicy{
+		patterns: normalized,
+		logger:   logger,
+	}
+}
+
+// Check returns an error if name isn't a valid FQDN inside the allowed domains
+func (p *DomainPolicy) Check(name string) error {
+	if len(p.patterns) == 0 {
+		return nil
+	}
+
+	normalized := normalizeDomain(name)
+	if err := validateFQDN(normalized); err != nil {
+		return fmt.Errorf("invalid domain name %q: %w", name, err)
+	}
+	for _, pattern := range p.patterns {
+		if domainMatches(pattern, normalized) {
+			return nil
+		}
+	}
+
+	p.logger.Warn("Domain name outside the allowed domains",
+		zap.String("domain", name),
+		zap.Strings("allowed_domains", p.patterns),
+	)
+	return fmt.Errorf("domain name %q is outside the allowed domains %s", name, strings.Join(p.patterns, ", "))
+}
+
+// domainMatches reports whether name matches pattern; both are normalized
+func domainMatches(pattern, name string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(name, "."+suffix)
+	}
+	return name == pattern
+}
+
+// validateFQDN checks that name is a hostname of letters, digits, and hyphens
+func validateFQDN(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if len(name) > maxDomainLength {
+		return fmt.Errorf("name is longer than %d characters", maxDomainLength)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("label %q must be 1 to 63 characters long", label)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("label %q must not start or end with a hyphen", label)
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return fmt.Errorf("label %q contains invalid character %q", label, c)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeDomain lowercases a name and strips the trailing dot of an absolute name
+func normalizeDomain(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
@@ -0,0 +1,73 @@
+package resolver
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"path"
+
+	"go.uber.org/zap"
+)
+
+// EnvironmentResolver picks the deployment environment of GitHub pushes and pull requests
+type EnvironmentResolver struct {
+	rules []config.EnvironmentRule
+	// preview is the environment of pull requests no rule matches
+	preview string
+	logger  *zap.Logger
+}
+
+// NewEnvironmentResolver creates a new environment resolver with the given rules; pull requests
+// no rule matches are deployed to preview
+func NewEnvironmentResolver(rules []config.EnvironmentRule, preview string, logger *zap.Logger) *EnvironmentResolver {
+	return &EnvironmentResolver{
+		rules:   rules,
+		preview: preview,
+		logger:  logger,
+	}
+}
+
+// ForPush returns the environment of a push of branch or tag (one of them is empty),
+// or false if no rule matches
+func (r *EnvironmentResolver) ForPush(branch, tag string) (string, bool) {
+	for i, rule := range r.rules {
+		if rule.PullRequest {
+			continue
+		}
+		if (branch != "" && patternMatches(rule.Branch, branch)) || (tag != "" && patternMatches(rule.Tag, tag)) {
+			r.logger.Debug("Environment rule matched push",
+				zap.Int("rule", i),
+				zap.String("branch", branch),
+				zap.String("tag", tag),
+				zap.String("environment", rule.Environment),
+			)
+			return rule.Environment, true
+		}
+	}
+	return "", false
+}
+
+// ForPullRequest returns the environment of a pull request into baseBranch
+func (r *EnvironmentResolver) ForPullRequest(baseBranch string) string {
+	for i, rule := range r.rules {
+		if !rule.PullRequest {
+			continue
+		}
+		if rule.Branch == "" || patternMatches(rule.Branch, baseBranch) {
+			r.logger.Debug("Environment rule matched pull request",
+				zap.Int("rule", i),
+				zap.String("base_branch", baseBranch),
+				zap.String("environment", rule.Environment),
+			)
+			return rule.Environment
+		}
+	}
+	return r.preview
+}
+
+// patternMatches reports whether name matches the glob pattern; an empty pattern matches nothing
+func patternMatches(pattern, name string) bool {
+	if pattern == "" {
+		return false
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
@@ -0,0 +1,62 @@
+package resolver
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// MentionResolver resolves the commit author of a deployment to the notifier users to mention
+type MentionResolver struct {
+	// users maps lowercase GitHub usernames and emails to user IDs
+	users   map[string]string
+	authors domain.RepositoryProvider
+	logger  *zap.Logger
+}
+
+// NewMentionResolver creates a new mention resolver with the given users.
+// With authors, the author of the deployed commit is looked up when the request doesn't name one.
+func NewMentionResolver(users map[string]string, authors domain.RepositoryProvider, logger *zap.Logger) *MentionResolver {
+	normalized := make(map[string]string, len(users))
+	for key, userID := range users {
+		normalized[strings.ToLower(strings.TrimSpace(key))] = userID
+	}
+	return &MentionResolver{
+		users:   normalized,
+		authors: authors,
+		logger:  logger,
+	}
+}
+
+// Resolve returns the user IDs of the author of the deployed commit.
+// Unknown authors and failed lookups resolve to no mentions.
+func (r *MentionResolver) Resolve(ctx context.Context, req domain.DeployRequest) []string {
+	if len(r.users) == 0 {
+		return nil
+	}
+
+	candidates := []string{req.Source.Author}
+	if req.Source.Author == "" && r.authors != nil && req.Source.Commit != "" {
+		author, err := r.authors.FetchCommitAuthor(ctx, req.Source.Repo, req.Source.Commit)
+		if err != nil {
+			r.logger.Warn("Failed to look up commit author",
+				zap.Error(err),
+				zap.String("repo", req.Source.Repo),
+				zap.String("commit", req.Source.Commit),
+			)
+			return nil
+		}
+		candidates = []string{author.Login, author.Email}
+	}
+
+	for _, candidate := range candidates {
+		if userID, ok := r.users[strings.ToLower(strings.TrimSpace(candidate))]; ok && candidate != "" {
+			return []string{userID}
+		}
+	}
+
+	r.logger.Debug("Commit author has no user to mention", zap.Strings("candidates", candidates))
+	return nil
+}
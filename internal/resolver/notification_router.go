@@ -0,0 +1,84 @@
+package resolver
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"slices"
+
+	"go.uber.org/zap"
+)
+
+// defaultNotificationChannels receive the notifications of deployments no route matches
+var defaultNotificationChannels = []string{domain.NotificationChannelDiscord}
+
+// verbosityKinds lists the notification kinds sent at each verbosity
+var verbosityKinds = map[string][]string{
+	config.NotificationVerbosityAll:      {domain.NotificationKindStart, domain.NotificationKindProgress, domain.NotificationKindFinish, domain.NotificationKindFailure},
+	config.NotificationVerbosityNormal:   {domain.NotificationKindStart, domain.NotificationKindFinish, domain.NotificationKindFailure},
+	config.NotificationVerbosityResults:  {domain.NotificationKindFinish, domain.NotificationKindFailure},
+	config.NotificationVerbosityFailures: {domain.NotificationKindFailure},
+}
+
+// NotificationRouter resolves the channels the notifications of a deployment are sent to
+type NotificationRouter struct {
+	routes []config.NotificationRoute
+	// verbosityFor returns the verbosity of an environment
+	verbosityFor func(environment string) string
+	logger       *zap.Logger
+}
+
+// NewNotificationRouter creates a new notification router with the given routes.
+// verbosityFor returns the notification verbosity of an environment, e.g. config.Config.VerbosityFor.
+func NewNotificationRouter(routes []config.NotificationRoute, verbosityFor func(environment string) string, logger *zap.Logger) *NotificationRouter {
+	return &NotificationRouter{
+		routes:       routes,
+		verbosityFor: verbosityFor,
+		logger:       logger,
+	}
+}
+
+// Route returns the channels a notification of kind about req is sent to: the channels of
+// req, or none if the verbosity of its environment leaves out kind
+func (r *NotificationRouter) Route(req domain.DeployRequest, kind string) []string {
+	verbosity := r.verbosityFor(req.Metadata.Environment)
+	kinds, ok := verbosityKinds[verbosity]
+	if !ok {
+		r.logger.Warn("Unknown notification verbosity, sending every notification",
+			zap.String("verbosity", verbosity),
+			zap.String("environment", req.Metadata.Environment),
+		)
+		kinds = verbosityKinds[config.NotificationVerbosityAll]
+	}
+	if !slices.Contains(kinds, kind) {
+		r.logger.Debug("Notification left out by verbosity",
+			zap.String("kind", kind),
+			zap.String("verbosity", verbosity),
+			zap.String("environment", req.Metadata.Environment),
+		)
+		return nil
+	}
+	return r.Channels(req)
+}
+
+// Channels returns the channels of the first route matching req, or Discord if none matches
+func (r *NotificationRouter) Channels(req domain.DeployRequest) []string {
+	for i, route := range r.routes {
+		if routeMatches(route.Environments, req.Metadata.Environment) &&
+			routeMatches(route.Projects, req.Metadata.ProjectName) &&
+			routeMatches(route.Repos, req.Source.Repo) {
+			r.logger.Debug("Notification route matched",
+				zap.Int("route", i),
+				zap.Strings("channels", route.Channels),
+				zap.String("environment", req.Metadata.Environment),
+				zap.String("project", req.Metadata.ProjectName),
+			)
+			return route.Channels
+		}
+	}
+	return defaultNotificationChannels
+}
+
+// routeMatches reports whether value is in values; empty values match everything
+func routeMatches(values []string, value string) bool {
+	return len(values) == 0 || slices.Contains(values, value)
+}
@@ -0,0 +1,524 @@
+package safety
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// CommandGuard checks the commands the worker generates for a deploy host before they run,
+// so a bug or a crafted request can't make them remove anything outside the deployment's
+// own directory under the base path
+type CommandGuard struct {
+	basePath string
+}
+
+// NewCommandGuard creates a guard allowing recursive removals only below basePath
+func NewCommandGuard(basePath string) *CommandGuard {
+	return &CommandGuard{basePath: path.Clean(basePath)}
+}
+
+// Check returns an error for the first recursive removal in command whose target isn't inside
+// basePath with environment as one of its path segments. Removals are rm -r, however rm is
+// invoked, and find -delete or find -exec rm. Relative targets are resolved against the
+// directory of the last cd before them in the same subshell; a relative target without one is
+// refused, and so is a recursive rm reading its targets from xargs.
+func (g *CommandGuard) Check(command, environment string) error {
+	if environment == "" || strings.Contains(environment, "/") || environment == "." || environment == ".." {
+		return fmt.Errorf("environment %q can't be a path segment", environment)
+	}
+	return g.check(command, "", environment)
+}
+
+// check checks the commands of script, which starts in cwd
+func (g *CommandGuard) check(script, cwd, environment string) error {
+	// The working directories outside the subshells command is in, innermost last
+	var outer []string
+	for _, cmd := range splitCommands(script) {
+		switch {
+		case cmd.subshell > 0:
+			outer = append(outer, cwd)
+			continue
+		case cmd.subshell < 0:
+			if len(outer) > 0 {
+				cwd = outer[len(outer)-1]
+				outer = outer[:len(outer)-1]
+			}
+			continue
+		}
+
+		words, xargs := commandWords(withoutRedirections(cmd.words))
+		if len(words) == 0 {
+			continue
+		}
+		switch path.Base(words[0]) {
+		case "cd":
+			switch {
+			case cmd.async:
+				// A cd in a pipeline or in the background runs in a subshell of its own, and
+				// leaves the directory of the commands after it as it was
+			case len(words) != 2 || hasExpansion(words[1]):
+				cwd = ""
+			default:
+				cwd = resolve(cwd, words[1])
+			}
+		case "rm":
+			targets, recursive := parseRm(words[1:])
+			if !recursive {
+				continue
+			}
+			if xargs {
+				return fmt.Errorf("refusing to run %q: targets read by xargs can't be checked", strings.Join(cmd.words, " "))
+			}
+			for _, target := range targets {
+				if err := g.checkTarget(cwd, target, environment); err != nil {
+					return fmt.Errorf("refusing to run %q: %w", strings.Join(cmd.words, " "), err)
+				}
+			}
+		case "find":
+			starts, removes := parseFind(words[1:])
+			if !removes {
+				continue
+			}
+			if xargs {
+				return fmt.Errorf("refusing to run %q: starting points read by xargs can't be checked", strings.Join(cmd.words, " "))
+			}
+			for _, start := range starts {
+				if err := g.checkTarget(cwd, start, environment); err != nil {
+					return fmt.Errorf("refusing to run %q: %w", strings.Join(cmd.words, " "), err)
+				}
+			}
+		case "sh", "bash", "dash":
+			// A script given to a shell with -c runs in the same directory
+			for i := 1; i+1 < len(words); i++ {
+				if words[i] == "-c" {
+					if err := g.check(words[i+1], cwd, environment); err != nil {
+						return err
+					}
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkTarget returns an error if target, relative to cwd, is outside the sandbox of environment
+func (g *CommandGuard) checkTarget(cwd, target, environment string) error {
+	if hasExpansion(target) {
+		return fmt.Errorf("target %q contains a shell expansion", target)
+	}
+	resolved := resolve(cwd, target)
+	if resolved == "" {
+		return fmt.Errorf("relative target %q without a known working directory", target)
+	}
+
+	rest, ok := strings.CutPrefix(resolved, g.basePath+"/")
+	if g.basePath == "/" {
+		rest, ok = strings.CutPrefix(resolved, "/")
+	}
+	if !ok || rest == "" {
+		return fmt.Errorf("target %s is not inside base path %s", resolved, g.basePath)
+	}
+	for _, segment := range strings.Split(rest, "/") {
+		if segment == environment {
+			return nil
+		}
+	}
+	return fmt.Errorf("target %s is not inside a directory of environment %s", resolved, environment)
+}
+
+// resolve returns the absolute, cleaned path of target relative to cwd, or an empty string if
+// target is relative and cwd is unknown
+func resolve(cwd, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return path.Clean(target)
+	}
+	if cwd == "" {
+		return ""
+	}
+	return path.Join(cwd, target)
+}
+
+// parseRm returns the operands of rm and whether it removes directories recursively
+func parseRm(args []string) ([]string, bool) {
+	var targets []string
+	recursive := false
+	options := true
+	for _, arg := range args {
+		switch {
+		case options && arg == "--":
+			options = false
+		case options && (arg == "--recursive" || arg == "-R"):
+			recursive = true
+		case options && strings.HasPrefix(arg, "--"):
+		case options && strings.HasPrefix(arg, "-") && arg != "-":
+			if strings.ContainsAny(arg, "rR") {
+				recursive = true
+			}
+		default:
+			targets = append(targets, arg)
+		}
+	}
+	return targets, recursive
+}
+
+// parseFind returns the starting points of find and whether its expression removes what it
+// finds, with -delete or by running rm
+func parseFind(args []string) ([]string, bool) {
+	var starts []string
+	i := 0
+	// Options before the starting points
+	for i < len(args) && (args[i] == "-H" || args[i] == "-L" || args[i] == "-P" || strings.HasPrefix(args[i], "-O") || strings.HasPrefix(args[i], "-D")) {
+		if args[i] == "-D" {
+			i++
+		}
+		i++
+	}
+	for ; i < len(args) && !strings.HasPrefix(args[i], "-") && args[i] != "(" && args[i] != "!"; i++ {
+		starts = append(starts, args[i])
+	}
+	if len(starts) == 0 {
+		starts = []string{"."}
+	}
+
+	removes := false
+	for j := i; j < len(args); j++ {
+		switch args[j] {
+		case "-delete":
+			removes = true
+		case "-exec", "-execdir", "-ok", "-okdir":
+			if j+1 < len(args) {
+				if words, _ := commandWords(args[j+1:]); len(words) > 0 && path.Base(words[0]) == "rm" {
+					removes = true
+				}
+			}
+		}
+	}
+	return starts, removes
+}
+
+// wrappers are commands that run the command in their arguments
+var wrappers = map[string]bool{
+	"command": true, "builtin": true, "exec": true, "env": true, "nice": true, "nohup": true,
+	"sudo": true, "doas": true, "stdbuf": true, "timeout": true, "time": true, "xargs": true,
+}
+
+// checkedCommands are the commands the guard looks at, besides the wrappers
+var checkedCommands = map[string]bool{
+	"cd": true, "rm": true, "find": true, "sh": true, "bash": true, "dash": true,
+}
+
+// commandWords returns the words of the command a simple command runs, without the reserved
+// words and variable assignments in front of it, and whether xargs runs it. A wrapper like env
+// or xargs is skipped up to the first of its arguments that is a checked command or another
+// wrapper, since the options taking a value differ between wrappers; a wrapper without one
+// runs nothing the guard checks.
+func commandWords(words []string) ([]string, bool) {
+	xargs := false
+	for len(words) > 0 {
+		word := words[0]
+		switch {
+		case word == "if" || word == "then" || word == "else" || word == "elif" || word == "while" ||
+			word == "until" || word == "do" || word == "!" || word == "{":
+			words = words[1:]
+		case strings.Contains(word, "=") && !strings.HasPrefix(word, "=") && !strings.HasPrefix(word, "-"):
+			// A variable assignment before the command
+			words = words[1:]
+		case wrappers[path.Base(word)]:
+			if path.Base(word) == "xargs" {
+				xargs = true
+			}
+			i := 1
+			for i < len(words) && !wrappers[path.Base(words[i])] && !checkedCommands[path.Base(words[i])] {
+				i++
+			}
+			words = words[i:]
+		default:
+			return words, xargs
+		}
+	}
+	return words, xargs
+}
+
+// withoutRedirections returns words without the redirections among them, like 2>&1 or > file
+func withoutRedirections(words []string) []string {
+	var result []string
+	for i := 0; i < len(words); i++ {
+		rest := strings.TrimLeft(words[i], "0123456789")
+		operator := ""
+		for _, op := range []string{">>", ">&", "<&", "&>", ">|", ">", "<"} {
+			if strings.HasPrefix(rest, op) {
+				operator = op
+				break
+			}
+		}
+		switch {
+		case operator == "":
+			result = append(result, words[i])
+		case rest == operator:
+			// The target is the next word
+			i++
+		}
+	}
+	return result
+}
+
+// hasExpansion reports whether a word would be expanded by the shell, so the path it
+// removes can't be told from the command
+func hasExpansion(word string) bool {
+	return strings.ContainsAny(word, "$`*?[~") || strings.HasPrefix(word, "{")
+}
+
+// command is a simple command of a script, or the start or the end of a subshell
+type command struct {
+	// words are the words of the command with quotes removed
+	words []string
+	// subshell is 1 for the start of a subshell and -1 for its end
+	subshell int
+	// async is set for a command in a pipeline or run in the background, which runs in a
+	// subshell of its own
+	async bool
+}
+
+// splitCommands splits a shell script into its simple commands and the subshells around them.
+// Commands are separated by newlines, ';', '&', and '|' outside quotes, subshells are delimited
+// by parentheses other than those ending the patterns of a case, and the scripts of command
+// substitutions are split into subshells before the command using them; comments and
+// here-documents are skipped. It understands the subset of the shell the worker generates,
+// not arbitrary scripts.
+func splitCommands(script string) []command {
+	var commands []command
+	var words []string
+	var word strings.Builder
+	inWord := false
+	heredoc := ""
+	// async is set for the current command, pipe once it is piped into the next one
+	async, pipe := false, false
+	// cases is the number of case commands the script is in, pattern set where a pattern may start
+	cases, pattern := 0, false
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			switch name, _ := commandWords(words); {
+			case len(name) > 0 && name[0] == "case":
+				cases++
+				pattern = true
+			case len(name) > 0 && name[0] == "esac" && cases > 0:
+				cases--
+				pattern = false
+			}
+			commands = append(commands, command{words: words, async: async || pipe})
+			words = nil
+		}
+		async, pipe = pipe, false
+	}
+	substitute := func(inner string) {
+		commands = append(commands, command{subshell: 1})
+		commands = append(commands, splitCommands(inner)...)
+		commands = append(commands, command{subshell: -1})
+	}
+	// substitution adds the command substitution starting at i in script to the word, and
+	// returns the index of its last byte
+	substitution := func(i int) int {
+		var end int
+		if script[i] == '`' {
+			end = closingBackquote(script, i)
+			substitute(script[i+1 : end])
+		} else {
+			end = matchingParen(script, i+1)
+			substitute(script[i+2 : end])
+		}
+		end = min(end, len(script)-1)
+		word.WriteString(script[i : end+1])
+		inWord = true
+		return end
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'':
+			end := strings.IndexByte(script[i+1:], '\'')
+			if end < 0 {
+				end = len(script) - i - 1
+			}
+			word.WriteString(script[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case c == '"':
+			j := i + 1
+			for ; j < len(script) && script[j] != '"'; j++ {
+				switch {
+				case script[j] == '\\' && j+1 < len(script):
+					j++
+					word.WriteByte(script[j])
+				case script[j] == '`' || strings.HasPrefix(script[j:], "$("):
+					j = substitution(j)
+				default:
+					word.WriteByte(script[j])
+				}
+			}
+			inWord = true
+			i = j
+		case c == '\\' && i+1 < len(script):
+			i++
+			if script[i] != '\n' {
+				word.WriteByte(script[i])
+				inWord = true
+			}
+		case c == '`' || strings.HasPrefix(script[i:], "$("):
+			// The substitution stays in the word as an expansion
+			i = substitution(i)
+		case c == '#' && !inWord:
+			// A comment, up to the end of the line
+			for i+1 < len(script) && script[i+1] != '\n' {
+				i++
+			}
+		case c == '<' && strings.HasPrefix(script[i:], "<<"):
+			// Remember the delimiter; the body starts on the next line
+			endWord()
+			j := i + 2
+			for j < len(script) && (script[j] == '-' || script[j] == ' ' || script[j] == '\t') {
+				j++
+			}
+			start := j
+			for j < len(script) && !strings.ContainsRune(" \t\n;&|()", rune(script[j])) {
+				j++
+			}
+			heredoc = strings.Trim(script[start:j], `'"`)
+			i = j - 1
+		case c == '\n':
+			endCommand()
+			if heredoc != "" {
+				// Skip the body of the here-document up to its delimiter line
+				for heredoc != "" && i+1 < len(script) {
+					line, _, found := strings.Cut(script[i+1:], "\n")
+					i += len(line) + 1
+					if strings.TrimSpace(line) == heredoc || !found {
+						heredoc = ""
+					}
+				}
+			}
+		case c == '&' && (strings.HasPrefix(script[i:], "&>") || (i > 0 && (script[i-1] == '>' || script[i-1] == '<'))):
+			// A redirection like 2>&1 or &>file
+			word.WriteByte(c)
+			inWord = true
+		case c == '&' || c == '|':
+			if i+1 < len(script) && script[i+1] == c {
+				// && and ||
+				i++
+			} else if c == '&' {
+				async = true
+			} else {
+				async, pipe = true, true
+			}
+			endCommand()
+		case c == ';':
+			endCommand()
+			if strings.HasPrefix(script[i:], ";;") {
+				// The end of the commands of a case pattern; the next pattern may follow
+				i++
+				pattern = cases > 0
+			}
+		case c == ')':
+			endWord()
+			name, _ := commandWords(words)
+			if len(name) > 0 && name[0] == "case" {
+				// The first pattern, on the line of the case
+				cases++
+				words = nil
+				pattern = false
+				continue
+			}
+			if pattern && !(len(name) == 1 && name[0] == "esac") {
+				// The end of a pattern
+				words = nil
+				pattern = false
+				continue
+			}
+			endCommand()
+			commands = append(commands, command{subshell: -1})
+		case c == '(':
+			endCommand()
+			commands = append(commands, command{subshell: 1})
+		case c == ' ' || c == '\t' || c == '\r':
+			endWord()
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endCommand()
+	return commands
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open in script, or
+// the length of script if it isn't closed
+func matchingParen(script string, open int) int {
+	depth := 0
+	for i := open; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := strings.IndexByte(script[i+1:], '\'')
+			if end < 0 {
+				return len(script)
+			}
+			i += end + 1
+		case '"':
+			i = closingQuote(script, i)
+		case '`':
+			i = closingBackquote(script, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(script)
+}
+
+// closingQuote returns the index of the double quote closing the one at open in script, or the
+// length of script if it isn't closed
+func closingQuote(script string, open int) int {
+	for i := open + 1; i < len(script); i++ {
+		switch {
+		case script[i] == '\\':
+			i++
+		case script[i] == '"':
+			return i
+		case strings.HasPrefix(script[i:], "$("):
+			i = matchingParen(script, i+1)
+		case script[i] == '`':
+			i = closingBackquote(script, i)
+		}
+	}
+	return len(script)
+}
+
+// closingBackquote returns the index of the backquote closing the one at open in script, or the
+// length of script if it isn't closed
+func closingBackquote(script string, open int) int {
+	for i := open + 1; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			i++
+		case '`':
+			return i
+		}
+	}
+	return len(script)
+}
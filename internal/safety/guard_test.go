@@ -0,0 +1,82 @@
+package safety
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandGuardCheck(t *testing.T) {
+	guard := NewCommandGuard("/srv/deploy")
+
+	tests := []struct {
+		name    string
+		command string
+		// refused is empty if the command is allowed, or a part of the error otherwise
+		refused string
+	}{
+		{"deployment directory", "rm -rf /srv/deploy/staging/org/app", ""},
+		{"relative to cd", "cd /srv/deploy/staging/org/app && rm -rf repo", ""},
+		{"not recursive", "rm -f /etc/passwd", ""},
+		{"quoted target", "rm -rf '/srv/deploy/staging/org/app'", ""},
+		{"here-document", "cat > f <<'EOF'\nrm -rf /\nEOF\nrm -rf /srv/deploy/staging/x", ""},
+		{"comment", "echo done # rm -rf /", ""},
+		{"find without removal", "find /tmp -name x -exec rmdir {} \\;", ""},
+		{"find -delete in the sandbox", "find /srv/deploy/staging -name '*.tmp' -delete", ""},
+		{"cd in subshell then rm in it", "(cd /srv/deploy/staging/org/app && rm -rf repo)", ""},
+		{"redirections", "cd /srv/deploy/staging 2>&1 && rm -rf app > /dev/null 2>/dev/null", ""},
+		{"case patterns", "cd /srv/deploy/staging\ncase \"$x\" in a) echo a;; *) echo b;; esac\nrm -rf app", ""},
+		{"substitution inside quotes", `cd /srv/deploy/staging && case "$(echo "a)" | tr -d "\"' ")" in a) true;; esac && rm -rf app`, ""},
+
+		{"outside base path", "rm -rf /", "not inside base path"},
+		{"other environment", "rm -rf /srv/deploy/production/org/app", "not inside a directory of environment staging"},
+		{"dot-dot", "rm -rf /srv/deploy/staging/../production", "not inside a directory"},
+		{"relative without cd", "rm -rf repo", "without a known working directory"},
+		{"expansion", "rm -rf $DIR", "shell expansion"},
+		{"long option", "rm --recursive --force /", "not inside base path"},
+		{"absolute rm", "/bin/rm -rf /", "not inside base path"},
+		{"escaped rm", "\\rm -rf /", "not inside base path"},
+		{"command rm", "command rm -rf /", "not inside base path"},
+		{"env rm", "env -i FOO=bar rm -rf /", "not inside base path"},
+		{"assignment before rm", "FOO=bar rm -rf /", "not inside base path"},
+		{"sudo rm", "sudo -u root rm -rf /", "not inside base path"},
+		{"rm after then", "if true; then rm -rf /; fi", "not inside base path"},
+		{"xargs rm", "echo / | xargs rm -rf", "xargs"},
+		{"xargs with options", "echo / | xargs -I {} rm -rf {}", "xargs"},
+		{"find -delete", "find / -delete", "not inside base path"},
+		{"find -exec rm", "find /srv/deploy/production -exec rm -rf {} +", "not inside a directory"},
+		{"find in the working directory", "cd / && find -delete", "not inside base path"},
+		{"find with an expansion", "find $(echo /) -delete", "shell expansion"},
+		{"subshell cd doesn't leak", "(cd /srv/deploy/staging); rm -rf app", "without a known working directory"},
+		{"nested subshell cd doesn't leak", "cd /; (cd /srv/deploy/staging; (cd /srv/deploy/staging/x)); rm -rf app", "not inside base path"},
+		{"piped cd doesn't leak", "cd /; cd /srv/deploy/staging | true; rm -rf app", "not inside base path"},
+		{"background cd doesn't leak", "cd /; cd /srv/deploy/staging & rm -rf app", "not inside base path"},
+		{"case pattern doesn't end the subshell", "cd /srv/deploy/staging; (cd /; case x in a) ;; esac; rm -rf app)", "not inside base path"},
+		{"command substitution", "echo $(rm -rf /)", "not inside base path"},
+		{"backquotes", "echo `rm -rf /`", "not inside base path"},
+		{"substitution in quotes", `echo "$(rm -rf /)"`, "not inside base path"},
+		{"shell -c", "bash -c 'rm -rf /'", "not inside base path"},
+		{"shell -c inherits cd", "cd /srv/deploy/staging && sh -c 'rm -rf app' && cd / && sh -c 'rm -rf app'", "not inside base path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guard.Check(tt.command, "staging")
+			switch {
+			case tt.refused == "" && err != nil:
+				t.Errorf("Check(%q) refused: %v", tt.command, err)
+			case tt.refused != "" && err == nil:
+				t.Errorf("Check(%q) allowed, want it refused", tt.command)
+			case tt.refused != "" && !strings.Contains(err.Error(), tt.refused):
+				t.Errorf("Check(%q) = %v, want an error containing %q", tt.command, err, tt.refused)
+			}
+		})
+	}
+}
+
+func TestCommandGuardRefusesEnvironmentsThatArentSegments(t *testing.T) {
+	guard := NewCommandGuard("/srv/deploy")
+	for _, environment := range []string{"", ".", "..", "a/b"} {
+		if err := guard.Check("rm -rf /srv/deploy/x", environment); err == nil {
+			t.Errorf("environment %q accepted", environment)
+		}
+	}
+}
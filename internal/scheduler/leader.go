@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// releaseTimeout bounds how long a stopping replica tries to release its leases
+const releaseTimeout = 5 * time.Second
+
+// LeaderElector runs each background job on one API replica at a time. The replicas compete
+// for a lease per job; the holder renews it every third of its TTL and runs the job while it
+// holds it. A replica that stops releases its leases, so another one takes over at once, e.g.
+// during a rolling upgrade; the leases of a replica that dies expire after the TTL.
+type LeaderElector struct {
+	leases domain.LeaseStore
+	// holder identifies this replica to the others
+	holder string
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+// NewLeaderElector creates a new leader elector taking leases for holder
+func NewLeaderElector(leases domain.LeaseStore, holder string, ttl time.Duration, logger *zap.Logger) *LeaderElector {
+	return &LeaderElector{
+		leases: leases,
+		holder: holder,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// Run runs job while this replica holds the lease with the given name, until ctx is done.
+// The context of job is cancelled when the lease is lost or can't be renewed, and job is
+// started again once the lease is taken back.
+func (e *LeaderElector) Run(ctx context.Context, name string, job func(ctx context.Context)) {
+	logger := e.logger.With(zap.String("lease", name), zap.String("holder", e.holder))
+	logger.Info("Competing for scheduler lease", zap.Duration("ttl", e.ttl))
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	var cancelJob context.CancelFunc
+	var jobDone chan struct{}
+	stopJob := func() {
+		if cancelJob == nil {
+			return
+		}
+		cancelJob()
+		<-jobDone
+		cancelJob = nil
+	}
+
+	defer func() {
+		stopJob()
+		// Detached from ctx, which is done by now
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+		defer cancel()
+		if err := e.leases.ReleaseLease(releaseCtx, name, e.holder); err != nil {
+			logger.Error("Failed to release scheduler lease", zap.Error(err))
+		}
+	}()
+
+	for {
+		leading, err := e.leases.AcquireLease(ctx, name, e.holder, e.ttl)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// Another replica may take over once the lease expires, so stop too
+			logger.Error("Failed to renew scheduler lease", zap.Error(err))
+		}
+
+		switch {
+		case leading && cancelJob == nil:
+			logger.Info("Took scheduler lease, starting job")
+			jobCtx, cancel := context.WithCancel(ctx)
+			cancelJob = cancel
+			jobDone = make(chan struct{})
+			go func() {
+				defer close(jobDone)
+				job(jobCtx)
+			}()
+		case !leading && cancelJob != nil:
+			logger.Warn("Lost scheduler lease, stopping job")
+			stopJob()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"NYCU-SDC/deployment-service/internal/adapter/deploymentstore"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// runningJobs records which holders run the job of a lease
+type runningJobs struct {
+	mu      sync.Mutex
+	running map[string]bool
+	// overlapped is set if two holders ever ran the job at the same time
+	overlapped bool
+	started    chan string
+}
+
+func (r *runningJobs) job(holder string) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		r.mu.Lock()
+		if len(r.running) > 0 {
+			r.overlapped = true
+		}
+		r.running[holder] = true
+		r.mu.Unlock()
+		r.started <- holder
+
+		<-ctx.Done()
+		r.mu.Lock()
+		delete(r.running, holder)
+		r.mu.Unlock()
+	}
+}
+
+func TestLeaderElectorRunsJobOnOneHolderAndHandsOverOnStop(t *testing.T) {
+	leases := deploymentstore.NewMemoryStore()
+	// The TTL is long enough that a handover within the test can only come from the release
+	ttl := time.Minute
+	jobs := &runningJobs{running: make(map[string]bool), started: make(chan string, 2)}
+
+	ctxs := make(map[string]context.CancelFunc)
+	done := make(map[string]chan struct{})
+	for _, holder := range []string{"a", "b"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stopped := make(chan struct{})
+		ctxs[holder] = cancel
+		done[holder] = stopped
+		go func() {
+			defer close(stopped)
+			NewLeaderElector(leases, holder, ttl, zap.NewNop()).Run(ctx, "retention", jobs.job(holder))
+		}()
+	}
+
+	var leader string
+	select {
+	case leader = <-jobs.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no holder started the job")
+	}
+	follower := map[string]string{"a": "b", "b": "a"}[leader]
+
+	ctxs[leader]()
+	<-done[leader]
+	// The follower retries every third of the TTL, so wait for it without waiting that long
+	deadline := time.After(5 * time.Second)
+	for {
+		ok, err := leases.AcquireLease(context.Background(), "retention", follower, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("lease of %s not released", leader)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	if jobs.overlapped {
+		t.Error("both holders ran the job at the same time")
+	}
+	if jobs.running[leader] {
+		t.Errorf("job of %s still running after it stopped", leader)
+	}
+}
+
+// losingLeaseStore fails to renew leases once lost is set, as if another holder took them
+type losingLeaseStore struct {
+	domain.LeaseStore
+	lost atomic.Bool
+}
+
+func (s *losingLeaseStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	if s.lost.Load() {
+		return false, nil
+	}
+	return s.LeaseStore.AcquireLease(ctx, name, holder, ttl)
+}
+
+func TestLeaderElectorStopsJobWhenLeaseIsLost(t *testing.T) {
+	leases := &losingLeaseStore{LeaseStore: deploymentstore.NewMemoryStore()}
+	ttl := 30 * time.Millisecond
+	jobs := &runningJobs{running: make(map[string]bool), started: make(chan string, 2)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewLeaderElector(leases, "a", ttl, zap.NewNop()).Run(ctx, "retention", jobs.job("a"))
+	}()
+	select {
+	case <-jobs.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job not started")
+	}
+
+	leases.lost.Store(true)
+
+	deadline := time.After(5 * time.Second)
+	for {
+		jobs.mu.Lock()
+		running := jobs.running["a"]
+		jobs.mu.Unlock()
+		if !running {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("job still running after the lease was lost")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+}
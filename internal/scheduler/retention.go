@@ -0,0 +1,309 @@
+package scheduler
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/namespace"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.uber.org/zap"
+)
+
+// TombstoneReasonRetention is the tombstone reason of records deleted by the retention policy
+const TombstoneReasonRetention = "retention"
+
+// RetentionLease is the lease electing the API replica that runs the retention pruner
+const RetentionLease = "retention"
+
+// PruneReport summarizes a pruning run
+type PruneReport struct {
+	// SoftDeleted is the number of records replaced by a tombstone
+	SoftDeleted int
+	// Purged is the number of soft-deleted records whose history was removed from Temporal
+	Purged int
+	// Expired is the number of tombstones removed after their TTL
+	Expired int
+}
+
+// RetentionPruner periodically deletes deployment records past the retention of their environment
+// in the environments catalog. Records are soft-deleted first: a tombstone hides them from the
+// API, and their history is only purged from Temporal after the grace period, so a deletion can
+// still be undone.
+type RetentionPruner struct {
+	namespaces *namespace.Router
+	catalog    domain.EnvironmentStore
+	tombstones domain.TombstoneStore
+	// deployments holds the records removed together with the history
+	deployments domain.DeploymentStore
+	config      config.RetentionConfig
+	logger      *zap.Logger
+}
+
+// NewRetentionPruner creates a new retention pruner
+func NewRetentionPruner(namespaces *namespace.Router, catalog domain.EnvironmentStore, tombstones domain.TombstoneStore, deployments domain.DeploymentStore, retentionConfig config.RetentionConfig, logger *zap.Logger) *RetentionPruner {
+	return &RetentionPruner{
+		namespaces:  namespaces,
+		catalog:     catalog,
+		tombstones:  tombstones,
+		deployments: deployments,
+		config:      retentionConfig,
+		logger:      logger,
+	}
+}
+
+// Run prunes once immediately and then every interval until ctx is done
+func (p *RetentionPruner) Run(ctx context.Context) {
+	p.logger.Info("Starting retention pruner", zap.Duration("interval", p.config.Interval))
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := p.Prune(ctx)
+		if err != nil {
+			p.logger.Error("Retention pruning failed", zap.Error(err))
+		} else {
+			p.logger.Info("Retention pruning finished",
+				zap.Int("soft_deleted", report.SoftDeleted),
+				zap.Int("purged", report.Purged),
+				zap.Int("expired_tombstones", report.Expired),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune soft-deletes expired records, purges soft-deleted records past the grace period,
+// and removes tombstones past their TTL
+func (p *RetentionPruner) Prune(ctx context.Context) (PruneReport, error) {
+	var report PruneReport
+	now := time.Now().UTC()
+
+	retentions, err := p.retentions(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, namespace := range p.namespaces.Namespaces() {
+		softDeleted, err := p.softDeleteExpired(ctx, namespace, retentions, now)
+		report.SoftDeleted += softDeleted
+		if err != nil {
+			return report, err
+		}
+	}
+	softDeleted, err := p.softDeleteExpiredRecords(ctx, retentions, now)
+	report.SoftDeleted += softDeleted
+	if err != nil {
+		return report, err
+	}
+
+	tombstones, err := p.tombstones.ListTombstones(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for _, tombstone := range tombstones {
+		if tombstone.PurgedAt == nil {
+			if now.Before(tombstone.DeletedAt.Add(p.config.GracePeriod)) {
+				continue
+			}
+			if err := p.purge(ctx, tombstone.TraceID); err != nil {
+				return report, err
+			}
+			purgedAt := now
+			tombstone.PurgedAt = &purgedAt
+			if err := p.tombstones.PutTombstone(ctx, tombstone); err != nil {
+				return report, err
+			}
+			report.Purged++
+			continue
+		}
+
+		if p.config.TombstoneTTL > 0 && now.After(tombstone.PurgedAt.Add(p.config.TombstoneTTL)) {
+			if err := p.tombstones.DeleteTombstone(ctx, tombstone.TraceID); err != nil {
+				return report, err
+			}
+			report.Expired++
+		}
+	}
+
+	return report, nil
+}
+
+// retentions returns the retention of each environment of the catalog that has one
+func (p *RetentionPruner) retentions(ctx context.Context) (map[string]time.Duration, error) {
+	environments, err := p.catalog.ListEnvironments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	retentions := make(map[string]time.Duration)
+	for _, environment := range environments {
+		if retention, ok := environment.RetentionPeriod(); ok {
+			retentions[environment.Name] = retention
+		}
+	}
+	return retentions, nil
+}
+
+// softDeleteExpired writes tombstones for closed deployments in namespace past the retention of their environment
+func (p *RetentionPruner) softDeleteExpired(ctx context.Context, namespace string, retentions map[string]time.Duration, now time.Time) (int, error) {
+	var shortest time.Duration
+	for _, retention := range retentions {
+		if shortest == 0 || retention < shortest {
+			shortest = retention
+		}
+	}
+	if shortest == 0 {
+		return 0, nil
+	}
+
+	// The environment is only known from the memo, so list everything past the shortest retention
+	query := fmt.Sprintf("WorkflowType = '%s' AND ExecutionStatus != 'Running' AND CloseTime < '%s'",
+		workflow.WorkflowCD, now.Add(-shortest).Format(time.RFC3339))
+
+	count := 0
+	var nextPageToken []byte
+	for {
+		resp, err := p.namespaces.NamespaceClient(namespace).ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         query,
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to list expired deployments in namespace %s: %w", namespace, err)
+		}
+
+		for _, info := range resp.GetExecutions() {
+			environment := workflow.MemoValue(info.GetMemo(), workflow.MemoEnvironment)
+			retention, ok := retentions[environment]
+			if !ok || now.Before(info.GetCloseTime().AsTime().Add(retention)) {
+				continue
+			}
+
+			tombstone := workflow.DeploymentTombstone(info, TombstoneReasonRetention, now)
+			if _, exists, err := p.tombstones.GetTombstone(ctx, tombstone.TraceID); err != nil {
+				return count, err
+			} else if exists {
+				continue
+			}
+			if err := p.tombstones.PutTombstone(ctx, tombstone); err != nil {
+				return count, err
+			}
+
+			p.logger.Info("Soft-deleted expired deployment",
+				zap.String("trace_id", tombstone.TraceID),
+				zap.String("environment", environment),
+				zap.Duration("retention", retention),
+			)
+			count++
+		}
+
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return count, nil
+		}
+	}
+}
+
+// softDeleteExpiredRecords writes tombstones for deployment records created before the retention
+// of their environment that have no workflow in Temporal, so the closed workflows listed by
+// softDeleteExpired miss them: queued and rejected deployments, which never started one, and
+// deployments whose history the namespace retention already removed.
+func (p *RetentionPruner) softDeleteExpiredRecords(ctx context.Context, retentions map[string]time.Duration, now time.Time) (int, error) {
+	count := 0
+	for environment, retention := range retentions {
+		records, err := p.deployments.ListDeployments(ctx, domain.DeploymentFilter{
+			Environment:   environment,
+			CreatedBefore: now.Add(-retention),
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to list expired deployment records of %s: %w", environment, err)
+		}
+
+		for _, record := range records {
+			if _, exists, err := p.tombstones.GetTombstone(ctx, record.TraceID); err != nil {
+				return count, err
+			} else if exists {
+				continue
+			}
+			if record.State == domain.DeploymentStateStarted {
+				// A deployment with a workflow is soft-deleted by the close time of the workflow
+				_, _, err := p.namespaces.Find(ctx, workflow.CDWorkflowID(record.TraceID))
+				var notFound *serviceerror.NotFound
+				switch {
+				case err == nil:
+					continue
+				case !errors.As(err, &notFound):
+					return count, fmt.Errorf("failed to look up deployment %s: %w", record.TraceID, err)
+				}
+			}
+
+			tombstone := domain.Tombstone{
+				TraceID:     record.TraceID,
+				Project:     record.Project,
+				Component:   record.Component,
+				Environment: record.Environment,
+				Commit:      record.Commit,
+				Reason:      TombstoneReasonRetention,
+				DeletedAt:   now,
+			}
+			if err := p.tombstones.PutTombstone(ctx, tombstone); err != nil {
+				return count, err
+			}
+
+			p.logger.Info("Soft-deleted expired deployment record",
+				zap.String("trace_id", record.TraceID),
+				zap.String("environment", environment),
+				zap.String("state", record.State),
+				zap.Duration("retention", retention),
+			)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// purge deletes the history of a deployment and its child workflows from Temporal, and its record.
+// Every namespace is tried, since the namespace of an environment may have changed since the deployment.
+func (p *RetentionPruner) purge(ctx context.Context, traceID string) error {
+	workflowIDs := []string{
+		workflow.CDWorkflowID(traceID),
+		workflow.DNSWorkflowID(traceID),
+		workflow.NotificationAckWorkflowID(traceID),
+	}
+
+	for _, namespace := range p.namespaces.Namespaces() {
+		workflowService := p.namespaces.NamespaceClient(namespace).WorkflowService()
+		for _, workflowID := range workflowIDs {
+			_, err := workflowService.DeleteWorkflowExecution(ctx, &workflowservice.DeleteWorkflowExecutionRequest{
+				Namespace:         namespace,
+				WorkflowExecution: &commonpb.WorkflowExecution{WorkflowId: workflowID},
+			})
+			if err != nil {
+				// Already removed by the namespace retention, or not in this namespace
+				var notFound *serviceerror.NotFound
+				if errors.As(err, &notFound) {
+					continue
+				}
+				return fmt.Errorf("failed to delete workflow %s in namespace %s: %w", workflowID, namespace, err)
+			}
+		}
+	}
+
+	if err := p.deployments.DeleteDeployment(ctx, traceID); err != nil {
+		return err
+	}
+
+	p.logger.Info("Purged deployment history", zap.String("trace_id", traceID))
+	return nil
+}
@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"NYCU-SDC/deployment-service/internal/adapter/deploymentstore"
+	"NYCU-SDC/deployment-service/internal/adapter/envstore"
+	"NYCU-SDC/deployment-service/internal/adapter/tombstone"
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"NYCU-SDC/deployment-service/internal/namespace"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeTemporal is a Temporal namespace holding CD workflows
+type fakeTemporal struct {
+	client.Client
+	mu sync.Mutex
+	// executions are the workflows of the namespace by workflow ID
+	executions map[string]*workflowpb.WorkflowExecutionInfo
+	deleted    []string
+}
+
+// add adds the CD workflow of a deployment into environment, closed at closed or running if zero
+func (f *fakeTemporal) add(t *testing.T, traceID, environment string, closed time.Time) {
+	t.Helper()
+	payload, err := converter.GetDefaultDataConverter().ToPayload(environment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &workflowpb.WorkflowExecutionInfo{
+		Execution: &commonpb.WorkflowExecution{WorkflowId: workflow.CDWorkflowID(traceID)},
+		Memo:      &commonpb.Memo{Fields: map[string]*commonpb.Payload{workflow.MemoEnvironment: payload}},
+		Status:    enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING,
+	}
+	if !closed.IsZero() {
+		info.Status = enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED
+		info.CloseTime = timestamppb.New(closed)
+	}
+	f.executions[info.Execution.WorkflowId] = info
+}
+
+// ListWorkflow returns the closed workflows; the query isn't parsed
+func (f *fakeTemporal) ListWorkflow(ctx context.Context, request *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	response := &workflowservice.ListWorkflowExecutionsResponse{}
+	for _, info := range f.executions {
+		if info.Status != enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING {
+			response.Executions = append(response.Executions, info)
+		}
+	}
+	return response, nil
+}
+
+func (f *fakeTemporal) DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info, ok := f.executions[workflowID]; ok {
+		return &workflowservice.DescribeWorkflowExecutionResponse{WorkflowExecutionInfo: info}, nil
+	}
+	return nil, serviceerror.NewNotFound("workflow not found")
+}
+
+func (f *fakeTemporal) WorkflowService() workflowservice.WorkflowServiceClient {
+	return &fakeWorkflowService{temporal: f}
+}
+
+type fakeWorkflowService struct {
+	workflowservice.WorkflowServiceClient
+	temporal *fakeTemporal
+}
+
+func (s *fakeWorkflowService) DeleteWorkflowExecution(ctx context.Context, request *workflowservice.DeleteWorkflowExecutionRequest, opts ...grpc.CallOption) (*workflowservice.DeleteWorkflowExecutionResponse, error) {
+	s.temporal.mu.Lock()
+	defer s.temporal.mu.Unlock()
+	workflowID := request.GetWorkflowExecution().GetWorkflowId()
+	if _, ok := s.temporal.executions[workflowID]; !ok {
+		return nil, serviceerror.NewNotFound("workflow not found")
+	}
+	delete(s.temporal.executions, workflowID)
+	s.temporal.deleted = append(s.temporal.deleted, workflowID)
+	return &workflowservice.DeleteWorkflowExecutionResponse{}, nil
+}
+
+func TestRetentionPrunerPrune(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+	dir := t.TempDir()
+
+	temporal := &fakeTemporal{executions: make(map[string]*workflowpb.WorkflowExecutionInfo)}
+	temporalConfig := config.TemporalConfig{Namespace: "deploy"}
+	namespaces, err := namespace.NewRouter(temporal, temporalConfig, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := encryption.NewRecordCipher(config.EncryptionConfig{}, temporalConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tombstones := tombstone.NewStore(filepath.Join(dir, "tombstones.json"), cipher)
+	deployments := deploymentstore.NewMemoryStore()
+
+	// Snapshot records are kept for 30 days, production records forever
+	catalog := envstore.NewStore(filepath.Join(dir, "environments.json"))
+	if err := catalog.PutEnvironment(ctx, domain.Environment{Name: "snapshot", Retention: "720h"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.PutEnvironment(ctx, domain.Environment{Name: "production"}); err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(traceID, environment, state string, created time.Time) {
+		t.Helper()
+		req := domain.DeployRequest{TraceID: traceID, Metadata: domain.MetadataInfo{ProjectName: "app", Environment: environment}}
+		if err := deployments.SaveDeployment(ctx, domain.NewDeployment(req, state, "deploy", created)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Past the retention: a closed workflow, records without one, and a workflow still running
+	temporal.add(t, "closed", "snapshot", now.Add(-40*day))
+	record("closed", "snapshot", domain.DeploymentStateStarted, now.Add(-41*day))
+	record("queued", "snapshot", domain.DeploymentStateQueued, now.Add(-40*day))
+	record("rejected", "snapshot", domain.DeploymentStateRejected, now.Add(-40*day))
+	record("history-gone", "snapshot", domain.DeploymentStateStarted, now.Add(-50*day))
+	temporal.add(t, "running", "snapshot", time.Time{})
+	record("running", "snapshot", domain.DeploymentStateStarted, now.Add(-40*day))
+	// Within the retention, or in an environment without one
+	temporal.add(t, "recent", "snapshot", now.Add(-day))
+	record("recent", "snapshot", domain.DeploymentStateStarted, now.Add(-31*day))
+	record("recent-queued", "snapshot", domain.DeploymentStateQueued, now.Add(-day))
+	temporal.add(t, "production", "production", now.Add(-400*day))
+	record("production", "production", domain.DeploymentStateStarted, now.Add(-400*day))
+
+	pruner := NewRetentionPruner(namespaces, catalog, tombstones, deployments, config.RetentionConfig{GracePeriod: day}, zap.NewNop())
+
+	report, err := pruner.Prune(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (PruneReport{SoftDeleted: 4}) {
+		t.Errorf("first run: %+v, want 4 soft-deleted", report)
+	}
+	list, err := tombstones.ListTombstones(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deleted []string
+	for _, tombstone := range list {
+		deleted = append(deleted, tombstone.TraceID)
+		if tombstone.Reason != TombstoneReasonRetention || tombstone.Environment != "snapshot" || tombstone.PurgedAt != nil {
+			t.Errorf("unexpected tombstone %+v", tombstone)
+		}
+	}
+	slices.Sort(deleted)
+	if want := []string{"closed", "history-gone", "queued", "rejected"}; !slices.Equal(deleted, want) {
+		t.Fatalf("soft-deleted %v, want %v", deleted, want)
+	}
+
+	// Nothing is purged before the grace period, and nothing is soft-deleted twice
+	report, err = pruner.Prune(ctx)
+	if err != nil || report != (PruneReport{}) {
+		t.Fatalf("second run: %+v, %v", report, err)
+	}
+
+	pruner.config.GracePeriod = 0
+	report, err = pruner.Prune(ctx)
+	if err != nil || report != (PruneReport{Purged: 4}) {
+		t.Fatalf("run after the grace period: %+v, %v", report, err)
+	}
+	if want := []string{workflow.CDWorkflowID("closed")}; !slices.Equal(temporal.deleted, want) {
+		t.Errorf("deleted workflows %v, want %v", temporal.deleted, want)
+	}
+	for _, traceID := range deleted {
+		if _, ok, err := deployments.GetDeployment(ctx, traceID); err != nil || ok {
+			t.Errorf("record %s not purged: %v, %v", traceID, ok, err)
+		}
+	}
+	for _, traceID := range []string{"running", "recent", "recent-queued", "production"} {
+		if _, ok, err := deployments.GetDeployment(ctx, traceID); err != nil || !ok {
+			t.Errorf("record %s removed: %v, %v", traceID, ok, err)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Versions of the deploy-request schema published with the shared GitHub Action
+const (
+	// MinimumVersion is the oldest schema version still accepted
+	MinimumVersion = "1.0"
+	// CurrentVersion is the schema version this service implements
+	CurrentVersion = "1.8"
+)
+
+// VersionHeader is the header callers can advertise their schema version in,
+// as an alternative to the schema_version field of the payload
+const VersionHeader = "X-Deploy-Schema-Version"
+
+// upgradeHint is appended to issues the caller fixes by upgrading the GitHub Action
+const upgradeHint = "upgrade the deploy GitHub Action to a release publishing deploy-request schema " + CurrentVersion
+
+// field is a payload field introduced after the first schema version
+type field struct {
+	path  string
+	since string
+}
+
+// deprecation is a payload field callers should stop sending
+type deprecation struct {
+	path  string
+	since string
+	hint  string
+}
+
+// fields lists the payload fields by the schema version that introduced them
+var fields = []field{
+	{path: "setup.script", since: "1.1"},
+	{path: "setup.clone", since: "1.1"},
+	{path: "setup.driver", since: "1.2"},
+	{path: "post.health_check", since: "1.2"},
+	{path: "source.author", since: "1.3"},
+	{path: "setup.wait_for_ci", since: "1.4"},
+	{path: "artifacts", since: "1.5"},
+	{path: "setup.worker", since: "1.6"},
+	{path: "setup.supersede", since: "1.7"},
+	{path: "setup.minimize_downtime", since: "1.8"},
+}
+
+// deprecations lists the payload fields that are still accepted but no longer used
+var deprecations = []deprecation{
+	{
+		path:  "post.notify_discord.channel",
+		since: "1.2",
+		hint:  "the channel is set by the service's Discord webhook; remove notify_discord.channel from the workflow inputs",
+	},
+}
+
+// Issue is a problem found in a payload
+type Issue struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// String formats the issue with its hint
+func (i Issue) String() string {
+	message := i.Message
+	if i.Field != "" {
+		message = i.Field + ": " + message
+	}
+	if i.Hint != "" {
+		message += " (" + i.Hint + ")"
+	}
+	return message
+}
+
+// Report is the result of checking a payload against the schema version advertised by its caller
+type Report struct {
+	// Version is the advertised schema version, empty if the caller didn't advertise one
+	Version string `json:"version,omitempty"`
+	// Errors reject the payload
+	Errors []Issue `json:"errors,omitempty"`
+	// Warnings are reported back to the caller, but the payload is accepted
+	Warnings []Issue `json:"warnings,omitempty"`
+}
+
+// Err returns an error listing the errors of the report, or nil if there are none
+func (r Report) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(r.Errors))
+	for _, issue := range r.Errors {
+		messages = append(messages, issue.String())
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
+
+// WarningMessages returns the warnings of the report formatted with their hints
+func (r Report) WarningMessages() []string {
+	if len(r.Warnings) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(r.Warnings))
+	for _, issue := range r.Warnings {
+		messages = append(messages, issue.String())
+	}
+	return messages
+}
+
+// Check checks a raw deploy-request payload against the schema version advertised by its caller.
+// An empty version skips the per-version checks, since callers predating versioning don't advertise one.
+func Check(version string, body []byte) (Report, error) {
+	report := Report{Version: version}
+
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return report, fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	if version == "" {
+		report.Warnings = append(report.Warnings, Issue{
+			Message: "schema version not advertised",
+			Hint:    fmt.Sprintf("send schema_version or the %s header; %s", VersionHeader, upgradeHint),
+		})
+	} else {
+		advertised, err := parseVersion(version)
+		if err != nil {
+			report.Errors = append(report.Errors, Issue{Field: "schema_version", Message: err.Error()})
+			return report, nil
+		}
+
+		current, _ := parseVersion(CurrentVersion)
+		minimum, _ := parseVersion(MinimumVersion)
+		switch {
+		case current.less(advertised):
+			report.Errors = append(report.Errors, Issue{
+				Field:   "schema_version",
+				Message: fmt.Sprintf("schema version %s is newer than the %s implemented by this service", version, CurrentVersion),
+				Hint:    "pin the deploy GitHub Action to a release publishing schema " + CurrentVersion + " until the service is upgraded",
+			})
+			return report, nil
+		case advertised.less(minimum):
+			report.Errors = append(report.Errors, Issue{
+				Field:   "schema_version",
+				Message: fmt.Sprintf("schema version %s is not supported, this service accepts %s to %s", version, MinimumVersion, CurrentVersion),
+				Hint:    upgradeHint,
+			})
+			return report, nil
+		}
+
+		for _, f := range fields {
+			since, _ := parseVersion(f.since)
+			if advertised.less(since) && present(payload, f.path) {
+				report.Errors = append(report.Errors, Issue{
+					Field:   f.path,
+					Message: fmt.Sprintf("introduced in schema %s, but the payload advertises %s", f.since, version),
+					Hint:    "advertise schema_version " + f.since + " or later, or " + upgradeHint,
+				})
+			}
+		}
+	}
+
+	for _, d := range deprecations {
+		if present(payload, d.path) {
+			report.Warnings = append(report.Warnings, Issue{
+				Field:   d.path,
+				Message: "deprecated since schema " + d.since,
+				Hint:    d.hint,
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// present reports whether the payload sets the field at the dotted path to a non-empty value
+func present(payload map[string]any, path string) bool {
+	var value any = payload
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		value, ok = object[key]
+		if !ok {
+			return false
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case map[string]any:
+		return len(v) > 0
+	case []any:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+// version is a major.minor schema version
+type version struct {
+	major, minor int
+}
+
+func (v version) less(other version) bool {
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	return v.minor < other.minor
+}
+
+func parseVersion(s string) (version, error) {
+	majorPart, minorPart, ok := strings.Cut(strings.TrimPrefix(s, "v"), ".")
+	if !ok {
+		minorPart = "0"
+	}
+	major, err := strconv.Atoi(majorPart)
+	if err != nil || major < 0 {
+		return version{}, fmt.Errorf("invalid schema version %q, expected MAJOR.MINOR", s)
+	}
+	minor, err := strconv.Atoi(minorPart)
+	if err != nil || minor < 0 {
+		return version{}, fmt.Errorf("invalid schema version %q, expected MAJOR.MINOR", s)
+	}
+	return version{major: major, minor: minor}, nil
+}
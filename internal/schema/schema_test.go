@@ -0,0 +1,189 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// examples are the payloads shipped in the repository root, with the fields newer than the
+// first schema version each one sets and the version that introduced them
+var examples = []struct {
+	file       string
+	introduced map[string]string
+}{
+	{"webhook-payload.deploy.json", map[string]string{"artifacts": "1.5"}},
+	{"webhook-payload.cleanup.json", map[string]string{}},
+}
+
+// supportedVersions returns every schema version from MinimumVersion to CurrentVersion
+func supportedVersions(t *testing.T) []string {
+	t.Helper()
+	minimum, err := parseVersion(MinimumVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := parseVersion(CurrentVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if minimum.major != current.major {
+		t.Fatalf("supported versions span major versions %d and %d", minimum.major, current.major)
+	}
+	var versions []string
+	for minor := minimum.minor; minor <= current.minor; minor++ {
+		versions = append(versions, fmt.Sprintf("%d.%d", current.major, minor))
+	}
+	return versions
+}
+
+// readExample reads a shipped example payload
+func readExample(t *testing.T, file string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("..", "..", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// issueFields returns the fields of issues, sorted
+func issueFields(issues []Issue) []string {
+	fields := []string{}
+	for _, issue := range issues {
+		fields = append(fields, issue.Field)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+func TestCheckExamplesAtEachSupportedVersion(t *testing.T) {
+	shipped, err := filepath.Glob(filepath.Join("..", "..", "webhook-payload.*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shipped) != len(examples) {
+		t.Fatalf("%d example payloads shipped, the test covers %d", len(shipped), len(examples))
+	}
+
+	for _, example := range examples {
+		body := readExample(t, example.file)
+		for _, version := range supportedVersions(t) {
+			t.Run(example.file+"@"+version, func(t *testing.T) {
+				advertised, _ := parseVersion(version)
+				want := []string{}
+				for path, since := range example.introduced {
+					if introduced, _ := parseVersion(since); advertised.less(introduced) {
+						want = append(want, path)
+					}
+				}
+				slices.Sort(want)
+
+				report, err := Check(version, body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := issueFields(report.Errors); !slices.Equal(got, want) {
+					t.Errorf("rejected fields %v, want %v", got, want)
+				}
+				if len(report.Warnings) != 0 {
+					t.Errorf("unexpected warnings %v", report.WarningMessages())
+				}
+			})
+		}
+	}
+}
+
+func TestCheckExamplesAtTheirAdvertisedVersion(t *testing.T) {
+	for _, example := range examples {
+		body := readExample(t, example.file)
+		var payload struct {
+			SchemaVersion string `json:"schema_version"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatal(err)
+		}
+		report, err := Check(payload.SchemaVersion, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := report.Err(); err != nil {
+			t.Errorf("%s is rejected at its schema version %s: %v", example.file, payload.SchemaVersion, err)
+		}
+	}
+}
+
+func TestCheckExamplesOutsideTheSupportedVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		// rejected is set when the payload is rejected for its schema version
+		rejected bool
+		warnings []string
+	}{
+		{"not advertised", "", false, []string{""}},
+		{"newer", "1.9", true, nil},
+		{"next major", "2.0", true, nil},
+		{"older", "0.9", true, nil},
+		{"invalid", "latest", true, nil},
+	}
+	for _, example := range examples {
+		body := readExample(t, example.file)
+		for _, tt := range tests {
+			t.Run(example.file+"/"+tt.name, func(t *testing.T) {
+				report, err := Check(tt.version, body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantErrors := []string{}
+				if tt.rejected {
+					wantErrors = []string{"schema_version"}
+				}
+				if got := issueFields(report.Errors); !slices.Equal(got, wantErrors) {
+					t.Errorf("rejected fields %v, want %v", got, wantErrors)
+				}
+				wantWarnings := tt.warnings
+				if wantWarnings == nil {
+					wantWarnings = []string{}
+				}
+				if got := issueFields(report.Warnings); !slices.Equal(got, wantWarnings) {
+					t.Errorf("warned about fields %v, want %v", got, wantWarnings)
+				}
+			})
+		}
+	}
+}
+
+func TestCheckExamplesWithDeprecatedFields(t *testing.T) {
+	for _, example := range examples {
+		var payload map[string]any
+		if err := json.Unmarshal(readExample(t, example.file), &payload); err != nil {
+			t.Fatal(err)
+		}
+		post := payload["post"].(map[string]any)
+		post["notify_discord"].(map[string]any)["channel"] = "deployments"
+		body, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Deprecated fields are reported at every version, without rejecting the payload
+		for _, version := range append(supportedVersions(t), "") {
+			report, err := Check(version, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Contains(issueFields(report.Warnings), "post.notify_discord.channel") {
+				t.Errorf("%s@%s: no deprecation warning, got %v", example.file, version, report.WarningMessages())
+			}
+			for _, issue := range report.Errors {
+				if issue.Field == "post.notify_discord.channel" {
+					t.Errorf("%s@%s: deprecated field rejected: %v", example.file, version, issue)
+				}
+			}
+		}
+	}
+}
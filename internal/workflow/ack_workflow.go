@@ -0,0 +1,111 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Workflow and signal name constants for type-safe workflow invocation
+const (
+	WorkflowNotificationAck = "NotificationAckWorkflow"
+	SignalAckNotification   = "ack-notification"
+)
+
+// NotificationAckInput is the input of the notification acknowledgement workflow
+type NotificationAckInput struct {
+	Request          domain.DeployRequest `json:"request"`
+	NotificationID   string               `json:"notification_id"`
+	ReminderInterval time.Duration        `json:"reminder_interval"`
+	MaxReminders     int                  `json:"max_reminders"`
+}
+
+// NotificationAckResult is the result of the notification acknowledgement workflow
+type NotificationAckResult struct {
+	Acknowledged   bool   `json:"acknowledged"`
+	AcknowledgedBy string `json:"acknowledged_by,omitempty"`
+	Reminders      int    `json:"reminders"`
+}
+
+// AckSignal is the payload of the ack-notification signal
+type AckSignal struct {
+	By string `json:"by,omitempty"`
+}
+
+// NotificationAckWorkflowID returns the workflow ID used for the given trace ID
+func NotificationAckWorkflowID(traceID string) string {
+	return "ack-" + traceID
+}
+
+// NotificationAckWorkflow waits for a failure notification to be acknowledged, either by
+// a reaction on the notification or by the ack-notification signal, and re-pings after
+// every reminder interval until it is or the reminders run out.
+// It outlives the CD workflow that started it.
+func NotificationAckWorkflow(ctx workflow.Context, input NotificationAckInput) (NotificationAckResult, error) {
+	logger := applog.WithDeployment(workflow.GetLogger(ctx), input.Request)
+	logger.Info("Notification ack workflow started", "notification_id", input.NotificationID)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	if input.ReminderInterval <= 0 {
+		input.ReminderInterval = 30 * time.Minute
+	}
+
+	var result NotificationAckResult
+	ackCh := workflow.GetSignalChannel(ctx, SignalAckNotification)
+
+	for {
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, input.ReminderInterval)
+
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(timer, func(f workflow.Future) {})
+		selector.AddReceive(ackCh, func(c workflow.ReceiveChannel, more bool) {
+			var signal AckSignal
+			c.Receive(ctx, &signal)
+			result.Acknowledged = true
+			result.AcknowledgedBy = signal.By
+		})
+		selector.Select(ctx)
+		cancelTimer()
+
+		if !result.Acknowledged {
+			var reacted bool
+			if err := workflow.ExecuteActivity(ctx, activity.ActivityCheckNotificationAck, input.NotificationID).Get(ctx, &reacted); err != nil {
+				logger.Warn("Failed to check notification reaction", "error", err)
+			}
+			if reacted {
+				result.Acknowledged = true
+				result.AcknowledgedBy = "reaction"
+			}
+		}
+
+		if result.Acknowledged {
+			logger.Info("Notification acknowledged", "by", result.AcknowledgedBy, "reminders", result.Reminders)
+			return result, nil
+		}
+
+		if result.Reminders >= input.MaxReminders {
+			logger.Warn("Notification was never acknowledged", "reminders", result.Reminders)
+			return result, nil
+		}
+
+		result.Reminders++
+		if err := workflow.ExecuteActivity(ctx, activity.ActivitySendNotificationReminder, input.Request, input.NotificationID, result.Reminders).Get(ctx, nil); err != nil {
+			logger.Error("Failed to send notification reminder", "error", err)
+		}
+	}
+}
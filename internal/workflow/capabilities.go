@@ -0,0 +1,93 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// SkipReasonNotConfigured is the detail of steps skipped because the worker lacks the capability
+const SkipReasonNotConfigured = "not configured"
+
+// Capabilities reports which optional integrations are configured on a worker.
+// Steps needing a missing capability are skipped instead of failing the deployment.
+type Capabilities struct {
+	// DNS is set when a DNS provider is configured
+	DNS bool `json:"dns"`
+	// Notifier is set when a notification channel is configured
+	Notifier bool `json:"notifier"`
+	// Drivers lists the deployment drivers the worker accepts; nil accepts every driver,
+	// as in runs started before the drivers were recorded
+	Drivers []string `json:"drivers"`
+}
+
+// CapabilitySwitch holds the capabilities and drivers an administrator turned off on a running
+// worker, e.g. to stop DNS changes fleet-wide during a Cloudflare incident. Deployments read it
+// when they start; running ones keep the capabilities they started with.
+type CapabilitySwitch struct {
+	mu       sync.RWMutex
+	disabled []string
+	drivers  []string
+	// onChange are told the new capabilities after each change
+	onChange []func(disabled, drivers []string)
+}
+
+// NewCapabilitySwitch creates a switch turning off the disabled capabilities (config.CapabilityDNS
+// and config.CapabilityNotifier) and accepting the given drivers
+func NewCapabilitySwitch(disabled, drivers []string) *CapabilitySwitch {
+	s := &CapabilitySwitch{}
+	s.Set(disabled, drivers)
+	return s
+}
+
+// OnChange calls fn with the disabled capabilities and the accepted drivers whenever they change,
+// e.g. to keep the drivers of the SSH activity in step
+func (s *CapabilitySwitch) OnChange(fn func(disabled, drivers []string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+// Set replaces the disabled capabilities and the accepted drivers
+func (s *CapabilitySwitch) Set(disabled, drivers []string) {
+	s.mu.Lock()
+	s.disabled = slices.Clone(disabled)
+	s.drivers = slices.Clone(drivers)
+	onChange := slices.Clone(s.onChange)
+	s.mu.Unlock()
+
+	for _, fn := range onChange {
+		fn(slices.Clone(disabled), slices.Clone(drivers))
+	}
+}
+
+// SetCapabilities replaces the disabled capabilities and, unless drivers is nil, the accepted
+// drivers, as requested through the API. A later SIGHUP replaces them with the config again.
+func (s *CapabilitySwitch) SetCapabilities(ctx context.Context, disabled, drivers []string) error {
+	if drivers == nil {
+		s.mu.RLock()
+		drivers = slices.Clone(s.drivers)
+		s.mu.RUnlock()
+	}
+	if err := (config.WorkerConfig{Disabled: disabled, Drivers: drivers}).ValidateCapabilities(); err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrInvalidRequest, err)
+	}
+	s.Set(disabled, drivers)
+	return nil
+}
+
+// Apply returns the configured capabilities without the disabled ones, accepting the current drivers
+func (s *CapabilitySwitch) Apply(capabilities Capabilities) Capabilities {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	capabilities.DNS = capabilities.DNS && !slices.Contains(s.disabled, config.CapabilityDNS)
+	capabilities.Notifier = capabilities.Notifier && !slices.Contains(s.disabled, config.CapabilityNotifier)
+	capabilities.Drivers = append([]string{}, s.drivers...)
+	return capabilities
+}
+
+// Ensure CapabilitySwitch implements domain.CapabilityManager
+var _ domain.CapabilityManager = (*CapabilitySwitch)(nil)
@@ -0,0 +1,45 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCapabilitySwitchSetCapabilities(t *testing.T) {
+	capabilitySwitch := NewCapabilitySwitch(nil, []string{"script", "compose"})
+	var notified [][]string
+	capabilitySwitch.OnChange(func(disabled, drivers []string) {
+		notified = append(notified, disabled, drivers)
+	})
+
+	// Without drivers the accepted drivers are kept
+	if err := capabilitySwitch.SetCapabilities(context.Background(), []string{"dns"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	capabilities := capabilitySwitch.Apply(Capabilities{DNS: true, Notifier: true})
+	if capabilities.DNS || !capabilities.Notifier || !slices.Equal(capabilities.Drivers, []string{"script", "compose"}) {
+		t.Errorf("got %+v, want DNS off and both drivers", capabilities)
+	}
+	if len(notified) != 2 || !slices.Equal(notified[0], []string{"dns"}) || !slices.Equal(notified[1], []string{"script", "compose"}) {
+		t.Errorf("listener told %v", notified)
+	}
+
+	if err := capabilitySwitch.SetCapabilities(context.Background(), []string{}, []string{"script"}); err != nil {
+		t.Fatal(err)
+	}
+	capabilities = capabilitySwitch.Apply(Capabilities{DNS: true, Notifier: true})
+	if !capabilities.DNS || !slices.Equal(capabilities.Drivers, []string{"script"}) {
+		t.Errorf("got %+v, want DNS back on and only the script driver", capabilities)
+	}
+
+	err := capabilitySwitch.SetCapabilities(context.Background(), []string{"dsn"}, nil)
+	if !errors.Is(err, domain.ErrInvalidRequest) {
+		t.Errorf("unknown capability: %v, want an invalid request", err)
+	}
+	if capabilities := capabilitySwitch.Apply(Capabilities{DNS: true}); !capabilities.DNS {
+		t.Error("rejected capabilities were applied")
+	}
+}
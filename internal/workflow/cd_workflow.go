@@ -2,23 +2,107 @@ package workflow
 
 import (
 	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/config"
+	"NYCU-SDC/deployment-service/internal/diagnosis"
 	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	enumspb "go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
-// CDWorkflow orchestrates the CD deployment process
-func CDWorkflow(ctx workflow.Context, req domain.DeployRequest) error {
-	logger := workflow.GetLogger(ctx)
+// Workflow and query name constants for type-safe workflow invocation
+const (
+	WorkflowCD        = "CDWorkflow"
+	QueryDeployResult = "deploy-result"
+)
+
+// ciPollInterval is how often the CI gate polls the checks of the deployed commit
+const ciPollInterval = 30 * time.Second
+
+// defaultCITimeout bounds the wait for CI checks when the request doesn't set a timeout
+const defaultCITimeout = 30 * time.Minute
+
+// CDWorkflowID returns the workflow ID used for the given trace ID
+func CDWorkflowID(traceID string) string {
+	return "deploy-" + traceID
+}
+
+// CDWorkflowOptions configures CDWorkflow on a worker
+type CDWorkflowOptions struct {
+	Retry config.RetryConfig
+	Ack   config.DiscordAckConfig
+	// Threads posts start and progress updates into the deployment's Discord thread
+	Threads bool
+	// Updates sends start and progress notifications, which the worker filters by the
+	// notification verbosity of the environment
+	Updates bool
+	// Canary sets up a per-deployment record next to the stable record of a deployment
+	Canary       config.CanaryConfig
+	Capabilities Capabilities
+	// HostHealth holds deployments while their deploy host is unhealthy, when its source is set
+	HostHealth config.HostHealthConfig
+	// Receipts signs a receipt of each completed deployment
+	Receipts bool
+	// Platform is the platform of the worker, recorded in the result of each deployment
+	Platform domain.WorkerPlatform
+	// Switch, if not nil, turns capabilities and drivers off when a deployment starts
+	Switch *CapabilitySwitch `json:"-"`
+}
+
+// current returns the options a deployment starting now runs with
+func (o CDWorkflowOptions) current() CDWorkflowOptions {
+	if o.Switch != nil {
+		o.Capabilities = o.Switch.Apply(o.Capabilities)
+	}
+	return o
+}
+
+// NewCDWorkflow returns CDWorkflow bound to the worker's options.
+// Register it under WorkflowCD.
+func NewCDWorkflow(options CDWorkflowOptions) func(workflow.Context, domain.DeployRequest) (domain.DeployResult, error) {
+	return func(ctx workflow.Context, req domain.DeployRequest) (domain.DeployResult, error) {
+		return cdWorkflow(ctx, req, options)
+	}
+}
+
+// cdWorkflow orchestrates the CD deployment process
+func cdWorkflow(ctx workflow.Context, req domain.DeployRequest, options CDWorkflowOptions) (domain.DeployResult, error) {
+	logger := applog.WithDeployment(workflow.GetLogger(ctx), req)
 	logger.Info("CD Workflow started",
 		"project", req.Metadata.ProjectName,
-		"environment", req.Metadata.Environment,
-		"method", string(req.Method),
-		"trace_id", req.TraceID,
+		"deploy_method", string(req.Method),
 	)
 
+	result := domain.DeployResult{
+		TraceID: req.TraceID,
+		Method:  req.Method,
+		Status:  domain.DeployStatusRunning,
+	}
+	if err := workflow.SetQueryHandler(ctx, QueryDeployResult, func() (domain.DeployResult, error) {
+		return result, nil
+	}); err != nil {
+		return result, err
+	}
+
+	// Runs started before the deployment steps were reworked finish on the original steps
+	if workflow.GetVersion(ctx, changeDeploySteps, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return legacyCDWorkflow(ctx, req, &result)
+	}
+
+	// Newer deployments of the environment may ask this one to stop; they wait until it has
+	supersession := newSupersession(ctx)
+	defer supersession.handOver(logger, req.TraceID)
+	result.Supersedes = supersedeRunning(ctx, logger, req)
+
 	// Configure Activity Options
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: 10 * time.Minute,
@@ -31,91 +115,567 @@ func CDWorkflow(ctx workflow.Context, req domain.DeployRequest) error {
 	}
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
-	// Step 1: Fetch Secrets (if enabled)
+	// Record the worker's options so a worker restarted with different settings
+	// replays the run with the settings it started with
+	if err := workflow.SideEffect(ctx, func(workflow.Context) interface{} {
+		return options.current()
+	}).Get(&options); err != nil {
+		return result, err
+	}
+	if options.Platform != (domain.WorkerPlatform{}) {
+		platform := options.Platform
+		result.Worker = &platform
+	}
+
+	// Retries of the steps below share one budget
+	retries := newRetryBudget(options.Retry)
+
+	// fail marks the deployment as failed and sends a failure notification
+	fail := func(status string, err error) (domain.DeployResult, error) {
+		result.Status = domain.DeployStatusFailed
+		result.Error = err.Error()
+		result.Timestamp = workflow.Now(ctx)
+		recordDeployStatus(ctx, result.Status)
+		if !options.Capabilities.Notifier {
+			logger.Warn("Skipping failure notification, no notifier configured")
+			return result, err
+		}
+		errMsg := err.Error()
+		if !options.Ack.Enable {
+			if notifyErr := retries.executeActivity(ctx, nil, activity.ActivitySendDiscordNotification, req, status, &errMsg); notifyErr != nil {
+				logger.Error("Failed to send failure notification", "error", notifyErr)
+			}
+			return result, err
+		}
+
+		var notificationID string
+		if notifyErr := retries.executeActivity(ctx, &notificationID, activity.ActivitySendTrackedDiscordNotification, req, status, &errMsg); notifyErr != nil {
+			logger.Error("Failed to send failure notification", "error", notifyErr)
+			return result, err
+		}
+		if notificationID == "" {
+			// Not routed to Discord, where acknowledgements are tracked
+			return result, err
+		}
+		if ackErr := startNotificationAck(ctx, req, notificationID, options.Ack); ackErr != nil {
+			logger.Error("Failed to start notification ack tracking", "error", ackErr)
+		}
+		return result, err
+	}
+
+	// Start messages and progress updates are best effort and don't count against the retry budget.
+	// Runs recorded before Updates only send them with threads.
+	updates := (options.Threads || options.Updates) && options.Capabilities.Notifier && req.Post.NotifyDiscord.Enable
+	progress := func(title string, steps ...domain.StepResult) {
+		if !updates {
+			return
+		}
+		lines := make([]string, 0, len(steps))
+		success := true
+		for _, step := range steps {
+			if step.Status == domain.StepStatusSkipped {
+				continue
+			}
+			lines = append(lines, describeStep(step))
+			success = success && step.Status != domain.StepStatusFailed
+		}
+		if len(lines) == 0 {
+			return
+		}
+		if err := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordProgress, req, title, strings.Join(lines, "\n"), success).Get(ctx, nil); err != nil {
+			logger.Warn("Failed to send progress notification", "error", err)
+		}
+	}
+	if updates {
+		if err := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordNotification, req, "Started", (*string)(nil)).Get(ctx, nil); err != nil {
+			logger.Warn("Failed to send start notification", "error", err)
+		}
+	}
+
+	// supersede stops the deployment in favor of the newer deployment by
+	supersede := func(by string) (domain.DeployResult, error) {
+		logger.Warn("Deployment superseded", "superseded_by", by)
+		result.Status = domain.DeployStatusSuperseded
+		result.SupersededBy = by
+		result.Timestamp = workflow.Now(ctx)
+		recordDeployStatus(ctx, result.Status)
+		if updates {
+			message := "Superseded by deployment " + by
+			if err := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordProgress, req, "Deployment Superseded", message, true).Get(ctx, nil); err != nil {
+				logger.Warn("Failed to send progress notification", "error", err)
+			}
+		}
+		return result, nil
+	}
+
+	// Hold the deployment until the CI checks of the commit pass
+	if req.Method == domain.MethodDeploy && req.Setup.WaitForCI.Enable {
+		logger.Info("Waiting for CI checks", "commit", req.Source.Commit)
+		step := domain.StepResult{Name: domain.StepWaitForCI, StartedAt: workflow.Now(ctx)}
+		err := waitForCI(ctx, req, retries)
+		result.AddStep(finishStep(ctx, step, err))
+		if err != nil {
+			logger.Error("CI checks did not pass", "error", err)
+			return fail("CI Checks Failed", err)
+		}
+	} else {
+		result.AddStep(skipStep(ctx, domain.StepWaitForCI, "CI gate not enabled"))
+	}
+
+	// Hold deployments to environments requiring approval until an admin approves them
+	if requiresApproval(req) {
+		logger.Info("Waiting for approval")
+		step := domain.StepResult{Name: domain.StepApproval, StartedAt: workflow.Now(ctx)}
+		if updates {
+			message := fmt.Sprintf("Deployments to %s require approval: POST /api/deployments/%s/approve", req.Metadata.Environment, req.TraceID)
+			if err := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordProgress, req, "Waiting for Approval", message, true).Get(ctx, nil); err != nil {
+				logger.Warn("Failed to send progress notification", "error", err)
+			}
+		}
+		by, err := waitForApproval(ctx)
+		step = finishStep(ctx, step, err)
+		if err != nil {
+			result.AddStep(step)
+			logger.Error("Deployment not approved", "error", err)
+			return fail("Deployment Not Approved", err)
+		}
+		logger.Info("Deployment approved", "approved_by", by)
+		step.Detail = "approved by " + by
+		result.ApprovedBy = by
+		result.AddStep(step)
+	} else {
+		result.AddStep(skipStep(ctx, domain.StepApproval, "approval not required"))
+	}
+
+	// Step 1: Fetch the .deploy manifest and fill in what the request left out
+	if by := supersession.superseded(); by != "" {
+		return supersede(by)
+	}
+	var manifest *domain.DeployManifest
+	step := domain.StepResult{Name: domain.StepFetchManifest, StartedAt: workflow.Now(ctx)}
+	err := retries.executeActivity(ctx, &manifest, activity.ActivityFetchDeployManifest, req)
+	result.AddStep(finishStep(ctx, step, err))
+	if err != nil {
+		logger.Error("Failed to fetch deploy manifest", "error", err)
+		return fail("Failed to fetch deploy manifest", err)
+	}
+	if manifest == nil {
+		manifest = &domain.DeployManifest{}
+	}
+	req = applyManifest(req, *manifest)
+
+	// Fail before fetching secrets if the driver was turned off on the worker
+	if err := checkDriver(req, options.Capabilities); err != nil {
+		step := domain.StepResult{Name: domain.StepRunScript, StartedAt: workflow.Now(ctx)}
+		result.AddStep(finishStep(ctx, step, err))
+		logger.Error("Deployment driver disabled", "error", err)
+		return fail("Deployment Failed", err)
+	}
+
+	// Hold the deployment while its deploy host is unhealthy; cleanups, which free its
+	// resources, go ahead
+	if by := supersession.superseded(); by != "" {
+		return supersede(by)
+	}
+	if req.Method == domain.MethodDeploy && options.HostHealth.Source != "" {
+		step := domain.StepResult{Name: domain.StepHostHealth, StartedAt: workflow.Now(ctx)}
+		health, err := waitForHealthyHost(ctx, req, options.HostHealth.Wait, retries, func(health domain.HostHealth) {
+			logger.Warn("Deploy host is unhealthy, waiting", "host", health.Host, "problems", health.Problems)
+			if !updates {
+				return
+			}
+			message := fmt.Sprintf("%s: %s", health.Host, strings.Join(health.Problems, "; "))
+			if err := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordProgress, req, "Waiting for Deploy Host", message, false).Get(ctx, nil); err != nil {
+				logger.Warn("Failed to send progress notification", "error", err)
+			}
+		})
+		switch {
+		case err == nil:
+			step = finishStep(ctx, step, nil)
+			step.Detail = describeHealth(health)
+		case isHostUnhealthy(err) || temporal.IsCanceledError(err):
+			result.AddStep(finishStep(ctx, step, err))
+			logger.Error("Deploy host is unhealthy", "error", err)
+			return fail("Deploy Host Unhealthy", err)
+		default:
+			// A host whose health can't be read is deployed to anyway
+			logger.Warn("Failed to check deploy host health, deploying anyway", "error", err)
+			step = skipStep(ctx, domain.StepHostHealth, "health unknown: "+err.Error())
+		}
+		result.AddStep(step)
+	} else {
+		result.AddStep(skipStep(ctx, domain.StepHostHealth, "host health not checked"))
+	}
+
+	// Step 2: Fetch Secrets (if enabled)
+	if by := supersession.superseded(); by != "" {
+		return supersede(by)
+	}
 	var secrets map[string]string
 	if req.Setup.InjectSecret.Enable {
 		logger.Info("Fetching secrets from Infisical")
-		err := workflow.ExecuteActivity(ctx, activity.ActivityFetchInfisicalSecrets,
+		step := domain.StepResult{Name: domain.StepFetchSecrets, StartedAt: workflow.Now(ctx)}
+		var fetched domain.FetchedSecrets
+		err = retries.executeActivity(ctx, &fetched, activity.ActivityFetchInfisicalSecrets,
 			req.Setup.InjectSecret.Project,
 			req.Setup.InjectSecret.Environment,
 			req.Setup.InjectSecret.Secrets,
-		).Get(ctx, &secrets)
+		)
+		result.AddStep(finishStep(ctx, step, err))
 		if err != nil {
 			logger.Error("Failed to fetch secrets", "error", err)
-			// Send failure notification
-			errMsg := err.Error()
-			if notifyErr := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordNotification, req, "Failed to fetch secrets", &errMsg).Get(ctx, nil); notifyErr != nil {
-				logger.Error("Failed to send failure notification", "error", notifyErr)
-			}
-			return err
+			return fail("Failed to fetch secrets", err)
 		}
+		secrets = fetched.Values
+		result.SecretChecksums = fetched.Checksums
 		logger.Info("Secrets fetched successfully", "count", len(secrets))
+	} else {
+		result.AddStep(skipStep(ctx, domain.StepFetchSecrets, "secret injection disabled"))
 	}
 
-	// Step 2: Execute SSH Deployment/Cleanup
+	// Take over from the deployments this one supersedes once they have stopped, so their
+	// scripts don't run next to this one
+	if len(result.Supersedes) > 0 {
+		logger.Info("Waiting for superseded deployments to stop", "supersedes", result.Supersedes)
+		if running := waitForSuperseded(ctx, result.Supersedes); len(running) > 0 {
+			logger.Warn("Superseded deployments still running, deploying anyway", "running", running)
+		}
+	}
+
+	// Step 3: Execute SSH Deployment/Cleanup
+	if by := supersession.superseded(); by != "" {
+		return supersede(by)
+	}
+	// The deployment may have waited for CI, approval, or its host into a freeze window
+	if err := checkFreeze(ctx, req); err != nil {
+		step := domain.StepResult{Name: domain.StepRunScript, StartedAt: workflow.Now(ctx)}
+		result.AddStep(finishStep(ctx, step, err))
+		logger.Error("Environment frozen", "error", err)
+		return fail("Environment Frozen", err)
+	}
 	var deployOutput string
-	err := workflow.ExecuteActivity(ctx, activity.ActivityRunSSHDeploy, req, secrets).Get(ctx, &deployOutput)
+	step = domain.StepResult{Name: domain.StepRunScript, StartedAt: workflow.Now(ctx)}
+	err = retries.executeActivity(ctx, &deployOutput, activity.ActivityRunSSHDeploy, req, secrets)
+	result.AddStep(finishStep(ctx, step, err))
+	result.Output = deployOutput
 	if err != nil {
 		logger.Error("SSH deployment failed", "error", err)
-		// Send failure notification
-		errMsg := err.Error()
-		if notifyErr := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordNotification, req, "Deployment Failed", &errMsg).Get(ctx, nil); notifyErr != nil {
-			logger.Error("Failed to send failure notification", "error", notifyErr)
-		}
-		return err
+		return fail("Deployment Failed", err)
 	}
 	logger.Info("SSH deployment completed successfully")
+	progress("Script Finished", result.Steps[len(result.Steps)-1])
 
-	// Step 3: Handle DNS (if enabled)
+	// Steps 4 and 5: DNS and health check run concurrently; the health check only waits
+	// for the DNS record when it reaches the service through it
+	var dnsInput *DNSWorkflowInput
 	if req.Method == domain.MethodDeploy && req.Post.SetupDomain.Enable {
-		if req.Post.SetupDomain.Name != "" && req.Post.SetupDomain.Value != "" {
-			logger.Info("Setting up DNS record",
-				"name", req.Post.SetupDomain.Name,
-				"value", req.Post.SetupDomain.Value,
-			)
-			// Extract IP from value (if it's a service:port format, we'll need to resolve it)
-			// For now, assume value is an IP address
-			ip := req.Post.SetupDomain.Value
-			err := workflow.ExecuteActivity(ctx, activity.ActivityEnsureDNSRecord,
-				req.Post.SetupDomain.Name,
-				ip,
-			).Get(ctx, nil)
-			if err != nil {
-				logger.Error("Failed to setup DNS record", "error", err)
-				// Send failure notification
-				errMsg := err.Error()
-				if notifyErr := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordNotification, req, "Deployment Failed", &errMsg).Get(ctx, nil); notifyErr != nil {
-					logger.Error("Failed to send failure notification", "error", notifyErr)
-				}
-				return err
-			}
+		setupDomain := req.Post.SetupDomain
+		result.Domain = &setupDomain
+	}
+	switch {
+	case req.KeepDomain:
+		// The record is set up or removed by whoever keeps it, e.g. a migration
+	case req.Method == domain.MethodDeploy && req.Post.SetupDomain.Enable:
+		dnsInput = &DNSWorkflowInput{
+			Method:      domain.MethodDeploy,
+			Domain:      req.Post.SetupDomain.Name,
+			Value:       req.Post.SetupDomain.Value,
+			Canary:      options.Canary.CanaryName(req.TraceID, req.Metadata.Environment),
+			TraceID:     req.TraceID,
+			RetryBudget: retries.window(dnsAttemptTimeout),
+		}
+	case req.Method == domain.MethodCleanup && req.Post.CleanupDomain.Enable:
+		dnsInput = &DNSWorkflowInput{
+			Method:         domain.MethodCleanup,
+			Domain:         req.Post.CleanupDomain.Name,
+			RemoveCanaries: options.Canary.Domain != "",
+			TraceID:        req.TraceID,
+			RetryBudget:    retries.window(dnsAttemptTimeout),
+		}
+	}
+	checkAtHost := req.Host != nil && req.Host.DNSValue != ""
+	dnsDone, setDNSDone := workflow.NewFuture(ctx)
+
+	// Step 4: Handle DNS (if enabled) in a child workflow with its own retry semantics
+	dnsStep := func(ctx workflow.Context) domain.StepResult {
+		defer setDNSDone.Set(nil, nil)
 
+		if req.KeepDomain {
+			logger.Info("Leaving DNS record unchanged")
+			return skipStep(ctx, domain.StepDNS, "DNS record left unchanged")
 		}
-	} else if req.Method == domain.MethodCleanup && req.Post.CleanupDomain.Enable {
-		if req.Post.CleanupDomain.Name != "" {
-			logger.Info("Cleaning up DNS record", "name", req.Post.CleanupDomain.Name)
-			err := workflow.ExecuteActivity(ctx, activity.ActivityRemoveDNSRecord, req.Post.CleanupDomain.Name).Get(ctx, nil)
-			if err != nil {
-				logger.Error("Failed to cleanup DNS record", "error", err)
-				// Send failure notification
-				errMsg := err.Error()
-				if notifyErr := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordNotification, req, "Deployment Failed", &errMsg).Get(ctx, nil); notifyErr != nil {
-					logger.Error("Failed to send failure notification", "error", notifyErr)
-				}
-				return err
-			}
+		if dnsInput == nil {
+			return skipStep(ctx, domain.StepDNS, "DNS not enabled")
+		}
+		if !options.Capabilities.DNS {
+			logger.Warn("Skipping DNS step, no DNS provider configured", "domain", dnsInput.Domain)
+			return skipStep(ctx, domain.StepDNS, SkipReasonNotConfigured)
+		}
+
+		if err := checkEnvironmentDomain(req, dnsInput.Domain); err != nil {
+			logger.Error("DNS record outside the environment", "domain", dnsInput.Domain, "error", err)
+			step := domain.StepResult{Name: domain.StepDNS, StartedAt: workflow.Now(ctx), Detail: dnsInput.Domain}
+			return finishStep(ctx, step, err)
+		}
+
+		logger.Info("Starting DNS child workflow",
+			"deploy_method", string(dnsInput.Method),
+			"domain", dnsInput.Domain,
+		)
+		cwo := workflow.ChildWorkflowOptions{
+			WorkflowID: DNSWorkflowID(req.TraceID),
+		}
+		records := dnsInput.Domain
+		if dnsInput.Canary != "" {
+			records = fmt.Sprintf("%s, canary %s", dnsInput.Domain, dnsInput.Canary)
+		}
+		step := domain.StepResult{Name: domain.StepDNS, StartedAt: workflow.Now(ctx), Detail: records}
+		var dnsResult DNSWorkflowResult
+		err := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), WorkflowDNS, *dnsInput).Get(ctx, &dnsResult)
+		step = finishStep(ctx, step, err)
+		switch {
+		case err == nil && dnsResult.Skipped:
+			step.Status = domain.StepStatusSkipped
+			step.Detail = fmt.Sprintf("%s: %s", records, dnsResult.SkipReason)
+			logger.Warn("DNS step was skipped", "reason", dnsResult.SkipReason)
+		case err == nil && dnsInput.Canary != "":
+			result.CanaryDomain = dnsInput.Canary
+		}
+		return step
+	}
+
+	// Step 5: Health check (if enabled)
+	healthStep := func(ctx workflow.Context) domain.StepResult {
+		if req.Method != domain.MethodDeploy || !req.Post.HealthCheck.Enable {
+			return skipStep(ctx, domain.StepHealthCheck, "health check not enabled")
+		}
+		if !checkAtHost {
+			// The URL resolves through the record the DNS step is setting up
+			_ = dnsDone.Get(ctx, nil)
+		}
+
+		timeout := time.Duration(req.Post.HealthCheck.TimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = 5 * time.Minute
+		}
+		hao := workflow.ActivityOptions{
+			StartToCloseTimeout:    30 * time.Second,
+			ScheduleToCloseTimeout: timeout,
+			RetryPolicy: &temporal.RetryPolicy{
+				InitialInterval:    5 * time.Second,
+				BackoffCoefficient: 1.5,
+				MaximumInterval:    30 * time.Second,
+				MaximumAttempts:    0, // unlimited within ScheduleToCloseTimeout
+			},
+		}
+		logger.Info("Checking deployment health", "url", req.Post.HealthCheck.URL)
+		step := domain.StepResult{Name: domain.StepHealthCheck, StartedAt: workflow.Now(ctx), Detail: req.Post.HealthCheck.URL}
+		hctx := workflow.WithActivityOptions(ctx, hao)
+		var err error
+		if checkAtHost {
+			// Check the host the service was deployed on, whatever its DNS record points at
+			err = workflow.ExecuteActivity(hctx, activity.ActivityCheckHealthAt, req.Post.HealthCheck.URL, req.Host.DNSValue).Get(ctx, nil)
+		} else {
+			err = workflow.ExecuteActivity(hctx, activity.ActivityCheckHealth, req.Post.HealthCheck.URL).Get(ctx, nil)
+		}
+		return finishStep(ctx, step, err)
+	}
+
+	steps, err := runConcurrently(ctx, dnsStep, healthStep)
+	for _, step := range steps {
+		result.AddStep(step)
+	}
+	if err != nil {
+		// The service itself is deployed, so post-deploy failures only degrade the result
+		logger.Error("Post-deploy steps failed", "error", err)
+	}
+	progress("Post-deploy Steps Finished", steps...)
+
+	if len(result.FailedSteps()) > 0 {
+		result.Status = domain.DeployStatusPartiallySucceeded
+	} else {
+		result.Status = domain.DeployStatusSucceeded
+	}
+
+	// Sign a receipt of what was deployed where; a deployment without one still completed
+	if options.Receipts {
+		var receipt domain.SignedReceipt
+		if err := retries.executeActivity(ctx, &receipt, activity.ActivitySignDeploymentReceipt, deploymentReceipt(ctx, req, result)); err != nil {
+			logger.Error("Failed to sign deployment receipt", "error", err)
+		} else {
+			result.Receipt = &receipt
+		}
+	}
+
+	// Step 6: Send result notification
+	// It reports the outcome of the steps above, so it runs after them.
+	// A failed notification is recorded as a step but doesn't change the deployment status
+	if req.Post.NotifyDiscord.Enable && !options.Capabilities.Notifier {
+		logger.Warn("Skipping result notification, no notifier configured")
+		result.AddStep(skipStep(ctx, domain.StepNotify, SkipReasonNotConfigured))
+	} else if req.Post.NotifyDiscord.Enable {
+		logger.Info("Sending result notification", "status", string(result.Status))
+		status := "Successful"
+		errMsg := (*string)(nil)
+		if result.Status == domain.DeployStatusPartiallySucceeded {
+			status = "Partially Successful"
+			summary := summarizeFailedSteps(result.FailedSteps())
+			errMsg = &summary
+		}
+		step := domain.StepResult{Name: domain.StepNotify, StartedAt: workflow.Now(ctx)}
+		err := retries.executeActivity(ctx, nil, activity.ActivitySendDiscordNotification, req, "Deployment "+status, errMsg)
+		result.AddStep(finishStep(ctx, step, err))
+		if err != nil {
+			logger.Error("Failed to send result notification", "error", err)
 		}
+	} else {
+		result.AddStep(skipStep(ctx, domain.StepNotify, "notification disabled"))
 	}
 
-	// Step 4: Send success notification
-	if req.Post.NotifyDiscord.Enable {
-		logger.Info("Sending success notification")
-		if err := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordNotification, req, "Deployment Successful", (*string)(nil)).Get(ctx, nil); err != nil {
-			logger.Error("Failed to send success notification", "error", err)
-			// Don't fail the workflow if notification fails, but log it
+	result.Timestamp = workflow.Now(ctx)
+	recordDeployStatus(ctx, result.Status)
+	logger.Info("CD Workflow completed", "status", string(result.Status))
+	return result, nil
+}
+
+// startNotificationAck starts the acknowledgement tracking of a failure notification.
+// The child workflow is abandoned so it keeps running after this workflow fails.
+func startNotificationAck(ctx workflow.Context, req domain.DeployRequest, notificationID string, ackConfig config.DiscordAckConfig) error {
+	cwo := workflow.ChildWorkflowOptions{
+		WorkflowID:        NotificationAckWorkflowID(req.TraceID),
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_ABANDON,
+	}
+	input := NotificationAckInput{
+		Request:          req,
+		NotificationID:   notificationID,
+		ReminderInterval: ackConfig.ReminderInterval,
+		MaxReminders:     ackConfig.MaxReminders,
+	}
+	future := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), WorkflowNotificationAck, input)
+
+	// Wait until the child has started, otherwise it is never started once this workflow completes
+	return future.GetChildWorkflowExecution().Get(ctx, nil)
+}
+
+// finishStep completes a step result based on the step error
+func finishStep(ctx workflow.Context, step domain.StepResult, err error) domain.StepResult {
+	step.FinishedAt = workflow.Now(ctx)
+	if err != nil {
+		step.Status = domain.StepStatusFailed
+		step.Error = err.Error()
+		step.Cause = diagnosis.Classify(step.Error)
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) {
+			step.ErrorType = appErr.Type()
+		}
+		return step
+	}
+	step.Status = domain.StepStatusSucceeded
+	return step
+}
+
+// checkDriver returns a non-retryable error if the worker doesn't accept the driver of req
+func checkDriver(req domain.DeployRequest, capabilities Capabilities) error {
+	if capabilities.Drivers == nil {
+		return nil
+	}
+	driver := req.Setup.Driver
+	if driver == "" {
+		driver = domain.DriverScript
+	}
+	if slices.Contains(capabilities.Drivers, driver) {
+		return nil
+	}
+	return temporal.NewNonRetryableApplicationError(
+		fmt.Sprintf("driver %q is disabled on this worker", driver),
+		activity.ErrorTypeValidation, nil,
+	)
+}
+
+// waitForCI polls the CI checks of the deployed commit until they pass, one of them fails,
+// or the timeout of the CI gate passes
+func waitForCI(ctx workflow.Context, req domain.DeployRequest, retries *retryBudget) error {
+	timeout := time.Duration(req.Setup.WaitForCI.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultCITimeout
+	}
+	deadline := workflow.Now(ctx).Add(timeout)
+
+	for {
+		var pending []string
+		if err := retries.executeActivity(ctx, &pending, activity.ActivityCheckCommitStatus, req); err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		remaining := deadline.Sub(workflow.Now(ctx))
+		if remaining <= 0 {
+			return temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("CI checks still pending after %s: %s", timeout, strings.Join(pending, ", ")),
+				activity.ErrorTypeCI, nil,
+			)
 		}
+		if err := workflow.Sleep(ctx, min(ciPollInterval, remaining)); err != nil {
+			return err
+		}
+	}
+}
+
+// deploymentReceipt returns the receipt of a completed deployment
+func deploymentReceipt(ctx workflow.Context, req domain.DeployRequest, result domain.DeployResult) domain.DeploymentReceipt {
+	outputHash := sha256.Sum256([]byte(result.Output))
+	receipt := domain.DeploymentReceipt{
+		TraceID:      req.TraceID,
+		Method:       req.Method,
+		Status:       result.Status,
+		Repo:         req.Source.Repo,
+		Branch:       req.Source.Branch,
+		Commit:       req.Source.Commit,
+		Project:      req.Metadata.ProjectName,
+		Component:    req.Metadata.Component,
+		Environment:  req.Metadata.Environment,
+		StartedAt:    workflow.GetInfo(ctx).WorkflowStartTime,
+		FinishedAt:   workflow.Now(ctx),
+		OutputSHA256: hex.EncodeToString(outputHash[:]),
+		Artifacts:    req.Artifacts,
 	}
+	if req.Host != nil {
+		receipt.Host = req.Host.Name
+	}
+	return receipt
+}
+
+// skipStep builds a skipped step result
+func skipStep(ctx workflow.Context, name, reason string) domain.StepResult {
+	now := workflow.Now(ctx)
+	return domain.StepResult{
+		Name:       name,
+		Status:     domain.StepStatusSkipped,
+		Detail:     reason,
+		StartedAt:  now,
+		FinishedAt: now,
+	}
+}
 
-	logger.Info("CD Workflow completed successfully")
-	return nil
+// describeStep formats the outcome of a step for progress updates
+func describeStep(step domain.StepResult) string {
+	if step.Status == domain.StepStatusFailed {
+		return fmt.Sprintf("%s failed: %s", step.Name, step.Error)
+	}
+	line := fmt.Sprintf("%s %s in %s", step.Name, step.Status, step.FinishedAt.Sub(step.StartedAt).Round(time.Second))
+	if step.Detail != "" {
+		line += " (" + step.Detail + ")"
+	}
+	return line
+}
+
+// summarizeFailedSteps formats failed steps for notifications
+func summarizeFailedSteps(steps []domain.StepResult) string {
+	lines := make([]string, 0, len(steps))
+	for _, step := range steps {
+		lines = append(lines, fmt.Sprintf("%s failed: %s", step.Name, step.Error))
+	}
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,134 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// changeDeploySteps is the change ID of the reworked deployment steps of cdWorkflow: the
+// recorded worker options, supersession, start and progress updates, the CI and approval
+// gates, the deploy manifest, the host health gate, the DNS child workflow running next to
+// the health check, receipts, and the result notification. Runs recorded before it replay
+// as workflow.DefaultVersion and finish on legacyCDWorkflow.
+//
+// Any later change to the commands cdWorkflow issues, or their order, needs a change ID and
+// workflow.GetVersion gate of its own, so runs in flight during a worker upgrade replay.
+const changeDeploySteps = "deploy-steps"
+
+// legacyCDWorkflow finishes a run started by a worker from before changeDeploySteps, issuing
+// the same commands in the same order as that worker did: fetch secrets, run the script,
+// set up or remove the DNS record, and notify. result is reported by the deploy-result query.
+func legacyCDWorkflow(ctx workflow.Context, req domain.DeployRequest, result *domain.DeployResult) (domain.DeployResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Replaying CD Workflow started before the deployment steps were reworked")
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	fail := func(status string, err error) (domain.DeployResult, error) {
+		errMsg := err.Error()
+		if notifyErr := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordNotification, req, status, &errMsg).Get(ctx, nil); notifyErr != nil {
+			logger.Error("Failed to send failure notification", "error", notifyErr)
+		}
+		result.Status = domain.DeployStatusFailed
+		result.Error = err.Error()
+		result.Timestamp = workflow.Now(ctx)
+		return *result, err
+	}
+
+	var secrets map[string]string
+	if req.Setup.InjectSecret.Enable {
+		var fetched json.RawMessage
+		err := workflow.ExecuteActivity(ctx, activity.ActivityFetchInfisicalSecrets,
+			req.Setup.InjectSecret.Project,
+			req.Setup.InjectSecret.Environment,
+			req.Setup.InjectSecret.Secrets,
+		).Get(ctx, &fetched)
+		if err == nil {
+			secrets, err = decodeLegacySecrets(fetched)
+		}
+		if err != nil {
+			logger.Error("Failed to fetch secrets", "error", err)
+			return fail("Failed to fetch secrets", err)
+		}
+	}
+
+	var deployOutput string
+	if err := workflow.ExecuteActivity(ctx, activity.ActivityRunSSHDeploy, req, secrets).Get(ctx, &deployOutput); err != nil {
+		logger.Error("SSH deployment failed", "error", err)
+		return fail("Deployment Failed", err)
+	}
+	result.Output = deployOutput
+
+	if err := legacyDNS(ctx, logger, req); err != nil {
+		logger.Error("Failed to update DNS record", "error", err)
+		return fail("Deployment Failed", err)
+	}
+
+	if req.Post.NotifyDiscord.Enable {
+		if err := workflow.ExecuteActivity(ctx, activity.ActivitySendDiscordNotification, req, "Deployment Successful", (*string)(nil)).Get(ctx, nil); err != nil {
+			logger.Error("Failed to send success notification", "error", err)
+		}
+	}
+
+	result.Status = domain.DeployStatusSucceeded
+	result.Timestamp = workflow.Now(ctx)
+	return *result, nil
+}
+
+// legacyDNS sets up or removes the DNS record of req with the activities legacyCDWorkflow used
+func legacyDNS(ctx workflow.Context, logger log.Logger, req domain.DeployRequest) error {
+	switch {
+	case req.Method == domain.MethodDeploy && req.Post.SetupDomain.Enable:
+		if req.Post.SetupDomain.Name == "" || req.Post.SetupDomain.Value == "" {
+			return nil
+		}
+		logger.Info("Setting up DNS record", "name", req.Post.SetupDomain.Name)
+		return workflow.ExecuteActivity(ctx, activity.ActivityEnsureDNSRecord, req.Post.SetupDomain.Name, req.Post.SetupDomain.Value).Get(ctx, nil)
+	case req.Method == domain.MethodCleanup && req.Post.CleanupDomain.Enable:
+		if req.Post.CleanupDomain.Name == "" {
+			return nil
+		}
+		logger.Info("Cleaning up DNS record", "name", req.Post.CleanupDomain.Name)
+		return workflow.ExecuteActivity(ctx, activity.ActivityRemoveDNSRecord, req.Post.CleanupDomain.Name).Get(ctx, nil)
+	}
+	return nil
+}
+
+// decodeLegacySecrets decodes the result of FetchInfisicalSecrets, which was the map of
+// secret values before it became domain.FetchedSecrets. A run replays the result its history
+// recorded, but activities it schedules after an upgrade return the new form.
+func decodeLegacySecrets(data json.RawMessage) (map[string]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets: %w", err)
+	}
+	if values, ok := fields["values"]; ok && (len(values) == 0 || values[0] == '{' || string(values) == "null") {
+		var fetched domain.FetchedSecrets
+		if err := json.Unmarshal(data, &fetched); err != nil {
+			return nil, fmt.Errorf("failed to decode secrets: %w", err)
+		}
+		return fetched.Values, nil
+	}
+
+	var secrets map[string]string
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets: %w", err)
+	}
+	return secrets, nil
+}
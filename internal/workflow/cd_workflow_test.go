@@ -0,0 +1,133 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"context"
+	"slices"
+	"testing"
+
+	sdkactivity "go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// recordedActivities registers stand-ins for the activities of CDWorkflow that record the
+// order they were called in
+type recordedActivities struct {
+	calls   []string
+	secrets map[string]string
+}
+
+func (r *recordedActivities) register(env *testsuite.TestWorkflowEnvironment, secretsResult any) {
+	record := func(name string) { r.calls = append(r.calls, name) }
+	env.RegisterActivityWithOptions(func(ctx context.Context, project, environment string, mappings []domain.SecretMapping) (any, error) {
+		record(activity.ActivityFetchInfisicalSecrets)
+		return secretsResult, nil
+	}, sdkactivity.RegisterOptions{Name: activity.ActivityFetchInfisicalSecrets})
+	env.RegisterActivityWithOptions(func(ctx context.Context, req domain.DeployRequest) (*domain.DeployManifest, error) {
+		record(activity.ActivityFetchDeployManifest)
+		return nil, nil
+	}, sdkactivity.RegisterOptions{Name: activity.ActivityFetchDeployManifest})
+	env.RegisterActivityWithOptions(func(ctx context.Context, req domain.DeployRequest, secrets map[string]string) (string, error) {
+		record(activity.ActivityRunSSHDeploy)
+		r.secrets = secrets
+		return "deployed", nil
+	}, sdkactivity.RegisterOptions{Name: activity.ActivityRunSSHDeploy})
+	env.RegisterActivityWithOptions(func(ctx context.Context, name, value string) error {
+		record(activity.ActivityEnsureDNSRecord)
+		return nil
+	}, sdkactivity.RegisterOptions{Name: activity.ActivityEnsureDNSRecord})
+	env.RegisterActivityWithOptions(func(ctx context.Context, req domain.DeployRequest, status string, errMsg *string) error {
+		record(activity.ActivitySendDiscordNotification)
+		return nil
+	}, sdkactivity.RegisterOptions{Name: activity.ActivitySendDiscordNotification})
+}
+
+func testDeployRequest() domain.DeployRequest {
+	req := domain.DeployRequest{
+		TraceID:  "trace-1",
+		Method:   domain.MethodDeploy,
+		Source:   domain.SourceInfo{Repo: "org/app", Branch: "main", Commit: "abc"},
+		Metadata: domain.MetadataInfo{ProjectName: "app", Component: "api", Environment: "dev"},
+	}
+	req.Setup.InjectSecret.Enable = true
+	req.Setup.InjectSecret.Project = "project"
+	req.Setup.InjectSecret.Environment = "dev"
+	req.Post.SetupDomain.Enable = true
+	req.Post.SetupDomain.Name = "app.example.com"
+	req.Post.SetupDomain.Value = "203.0.113.1"
+	req.Post.NotifyDiscord.Enable = true
+	return req
+}
+
+// TestCDWorkflowReplaysLegacySteps checks that a run recorded before changeDeploySteps issues
+// the commands of the original workflow, in the original order
+func TestCDWorkflowReplaysLegacySteps(t *testing.T) {
+	for name, secretsResult := range map[string]any{
+		"recorded map":         map[string]string{"TOKEN": "secret"},
+		"fetched after update": domain.FetchedSecrets{Values: map[string]string{"TOKEN": "secret"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestWorkflowEnvironment()
+			env.OnGetVersion(changeDeploySteps, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+			var activities recordedActivities
+			activities.register(env, secretsResult)
+
+			env.ExecuteWorkflow(NewCDWorkflow(CDWorkflowOptions{}), testDeployRequest())
+
+			if !env.IsWorkflowCompleted() {
+				t.Fatal("workflow did not complete")
+			}
+			if err := env.GetWorkflowError(); err != nil {
+				t.Fatal(err)
+			}
+			want := []string{
+				activity.ActivityFetchInfisicalSecrets,
+				activity.ActivityRunSSHDeploy,
+				activity.ActivityEnsureDNSRecord,
+				activity.ActivitySendDiscordNotification,
+			}
+			if !slices.Equal(activities.calls, want) {
+				t.Errorf("activities %v, want %v", activities.calls, want)
+			}
+			if activities.secrets["TOKEN"] != "secret" {
+				t.Errorf("script got secrets %v", activities.secrets)
+			}
+
+			var result domain.DeployResult
+			if err := env.GetWorkflowResult(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != domain.DeployStatusSucceeded || result.Output != "deployed" {
+				t.Errorf("unexpected result %+v", result)
+			}
+		})
+	}
+}
+
+// TestCDWorkflowRunsCurrentSteps checks that new runs take the reworked steps
+func TestCDWorkflowRunsCurrentSteps(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	var activities recordedActivities
+	activities.register(env, domain.FetchedSecrets{Values: map[string]string{"TOKEN": "secret"}})
+
+	req := testDeployRequest()
+	req.Post.SetupDomain.Enable = false
+	req.Post.NotifyDiscord.Enable = false
+	env.ExecuteWorkflow(NewCDWorkflow(CDWorkflowOptions{}), req)
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		activity.ActivityFetchDeployManifest,
+		activity.ActivityFetchInfisicalSecrets,
+		activity.ActivityRunSSHDeploy,
+	}
+	if !slices.Equal(activities.calls, want) {
+		t.Errorf("activities %v, want %v", activities.calls, want)
+	}
+}
@@ -0,0 +1,36 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// concurrentStep is a deployment step that runs alongside other steps
+type concurrentStep func(ctx workflow.Context) domain.StepResult
+
+// runConcurrently runs the steps in workflow coroutines and waits for all of them.
+// The results are returned in the order of the steps, whichever finished first, and the
+// failures of all failed steps are joined into the returned error.
+func runConcurrently(ctx workflow.Context, steps ...concurrentStep) ([]domain.StepResult, error) {
+	results := make([]domain.StepResult, len(steps))
+	wg := workflow.NewWaitGroup(ctx)
+	for i, step := range steps {
+		wg.Add(1)
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			defer wg.Done()
+			results[i] = step(ctx)
+		})
+	}
+	wg.Wait(ctx)
+
+	var errs []error
+	for _, result := range results {
+		if result.Status == domain.StepStatusFailed {
+			errs = append(errs, fmt.Errorf("%s: %s", result.Name, result.Error))
+		}
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,172 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Workflow and signal name constants for type-safe workflow invocation
+const (
+	WorkflowDNS   = "DNSWorkflow"
+	SignalSkipDNS = "skip-dns"
+)
+
+// dnsAttemptTimeout is the timeout of a single DNS activity attempt
+const dnsAttemptTimeout = 2 * time.Minute
+
+// DNSWorkflowInput is the input of the DNS child workflow
+type DNSWorkflowInput struct {
+	Method domain.DeployMethod `json:"method"`
+	Domain string              `json:"domain"`
+	Value  string              `json:"value,omitempty"`
+	// Canary is the per-deployment record set up next to Domain on deploy, if any
+	Canary string `json:"canary,omitempty"`
+	// RemoveCanaries removes the canary records of Domain on cleanup
+	RemoveCanaries bool   `json:"remove_canaries,omitempty"`
+	TraceID        string `json:"trace_id"`
+	// RetryBudget caps the retry window when the parent workflow has a retry budget
+	RetryBudget time.Duration `json:"retry_budget,omitempty"`
+}
+
+// DNSWorkflowResult is the result of the DNS child workflow
+type DNSWorkflowResult struct {
+	Skipped    bool   `json:"skipped"`
+	SkipReason string `json:"skip_reason,omitempty"`
+}
+
+// DNSSkipSignal is the payload of the skip-dns signal
+type DNSSkipSignal struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// DNSWorkflowID returns the child workflow ID used for the given trace ID
+func DNSWorkflowID(traceID string) string {
+	return "dns-" + traceID
+}
+
+// DNSWorkflow sets up or cleans up a DNS record.
+// It runs as a child of CDWorkflow with a much longer retry window than the
+// deployment itself, so a Cloudflare outage is waited out instead of failing the
+// deployment. Sending the skip-dns signal abandons the DNS step.
+func DNSWorkflow(ctx workflow.Context, input DNSWorkflowInput) (DNSWorkflowResult, error) {
+	logger := log.With(workflow.GetLogger(ctx), applog.FieldTraceID, input.TraceID)
+	logger.Info("DNS Workflow started",
+		"deploy_method", string(input.Method),
+		"domain", input.Domain,
+	)
+
+	if input.Domain == "" {
+		return DNSWorkflowResult{}, temporal.NewNonRetryableApplicationError("DNS domain name is required", activity.ErrorTypeValidation, nil)
+	}
+	if input.Method == domain.MethodDeploy && input.Value == "" {
+		return DNSWorkflowResult{}, temporal.NewNonRetryableApplicationError("DNS record value is required", activity.ErrorTypeValidation, nil)
+	}
+
+	// Retry for up to 6 hours so transient provider outages don't fail the deployment,
+	// unless the parent's retry budget is shorter
+	retryWindow := 6 * time.Hour
+	if input.RetryBudget > 0 && input.RetryBudget < retryWindow {
+		retryWindow = input.RetryBudget
+	}
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout:    dnsAttemptTimeout,
+		ScheduleToCloseTimeout: retryWindow,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    10 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    10 * time.Minute,
+			MaximumAttempts:    0, // unlimited within ScheduleToCloseTimeout
+		},
+	}
+	activityCtx, cancelActivity := workflow.WithCancel(workflow.WithActivityOptions(ctx, ao))
+	defer cancelActivity()
+
+	// The canary record follows the stable record, so the skip signal abandons both
+	var future workflow.Future
+	switch input.Method {
+	case domain.MethodDeploy:
+		future = executeInOrder(activityCtx,
+			func(ctx workflow.Context) workflow.Future {
+				return workflow.ExecuteActivity(ctx, activity.ActivityEnsureDNSRecord, input.Domain, input.Value)
+			},
+			func(ctx workflow.Context) workflow.Future {
+				if input.Canary == "" {
+					return nil
+				}
+				return workflow.ExecuteActivity(ctx, activity.ActivityEnsureCanaryDNSRecord, input.Canary, input.Value, input.Domain)
+			},
+		)
+	case domain.MethodCleanup:
+		future = executeInOrder(activityCtx,
+			func(ctx workflow.Context) workflow.Future {
+				return workflow.ExecuteActivity(ctx, activity.ActivityRemoveDNSRecord, input.Domain)
+			},
+			func(ctx workflow.Context) workflow.Future {
+				if !input.RemoveCanaries {
+					return nil
+				}
+				return workflow.ExecuteActivity(ctx, activity.ActivityRemoveCanaryDNSRecords, input.Domain)
+			},
+		)
+	default:
+		return DNSWorkflowResult{}, temporal.NewNonRetryableApplicationError(fmt.Sprintf("unsupported method %q", input.Method), activity.ErrorTypeValidation, nil)
+	}
+
+	var result DNSWorkflowResult
+	var activityErr error
+
+	skipCh := workflow.GetSignalChannel(ctx, SignalSkipDNS)
+	selector := workflow.NewSelector(ctx)
+	selector.AddFuture(future, func(f workflow.Future) {
+		activityErr = f.Get(ctx, nil)
+	})
+	selector.AddReceive(skipCh, func(c workflow.ReceiveChannel, more bool) {
+		var signal DNSSkipSignal
+		c.Receive(ctx, &signal)
+		result.Skipped = true
+		result.SkipReason = signal.Reason
+		if result.SkipReason == "" {
+			result.SkipReason = "skipped by signal"
+		}
+	})
+	selector.Select(ctx)
+
+	if result.Skipped {
+		logger.Warn("DNS step skipped by signal", "domain", input.Domain, "reason", result.SkipReason)
+		return result, nil
+	}
+	if activityErr != nil {
+		logger.Error("DNS Workflow failed", "domain", input.Domain, "error", activityErr)
+		return result, activityErr
+	}
+
+	logger.Info("DNS Workflow completed successfully", "domain", input.Domain)
+	return result, nil
+}
+
+// executeInOrder runs the activities started by steps one after another and returns a future
+// settled with the first error. A step returning nil is skipped.
+func executeInOrder(ctx workflow.Context, steps ...func(workflow.Context) workflow.Future) workflow.Future {
+	future, settable := workflow.NewFuture(ctx)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for _, step := range steps {
+			f := step(ctx)
+			if f == nil {
+				continue
+			}
+			if err := f.Get(ctx, nil); err != nil {
+				settable.Set(nil, err)
+				return
+			}
+		}
+		settable.Set(nil, nil)
+	})
+	return future
+}
@@ -0,0 +1,77 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// SignalApprove approves a deployment held for approval by its environment
+const SignalApprove = "approve"
+
+// approvalWait bounds how long a deployment waits for approval before it fails
+const approvalWait = 24 * time.Hour
+
+// Application error types of deployments the catalog entry of their environment stopped
+const (
+	// ErrorTypeApproval is the error type of deployments that weren't approved in time
+	ErrorTypeApproval = "ApprovalError"
+	// ErrorTypeEnvironmentPolicy is the error type of deployments into a freeze window,
+	// or with a DNS record outside the DNS suffix of their environment
+	ErrorTypeEnvironmentPolicy = "EnvironmentPolicyError"
+)
+
+// ApprovalSignal is the payload of the approve signal
+type ApprovalSignal struct {
+	// By identifies who approved the deployment
+	By string `json:"by"`
+}
+
+// waitForApproval waits for the approve signal and returns who approved the deployment.
+// It fails with an ApprovalError once approvalWait has passed.
+func waitForApproval(ctx workflow.Context) (string, error) {
+	var signal ApprovalSignal
+	received, _ := workflow.GetSignalChannel(ctx, SignalApprove).ReceiveWithTimeout(ctx, approvalWait, &signal)
+	if !received {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "", temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("deployment not approved within %s", approvalWait),
+			ErrorTypeApproval, nil,
+		)
+	}
+	return signal.By, nil
+}
+
+// requiresApproval reports whether req is held until it is approved
+func requiresApproval(req domain.DeployRequest) bool {
+	return req.Method == domain.MethodDeploy && req.EnvironmentPolicy != nil && req.EnvironmentPolicy.RequireApproval
+}
+
+// checkFreeze fails deployments (not cleanups) that reach their script during a freeze window
+// of their environment, e.g. after waiting for CI or approval into one
+func checkFreeze(ctx workflow.Context, req domain.DeployRequest) error {
+	if req.Method != domain.MethodDeploy || req.EnvironmentPolicy == nil {
+		return nil
+	}
+	if err := req.EnvironmentPolicy.CheckFrozen(workflow.Now(ctx)); err != nil {
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrorTypeEnvironmentPolicy, nil)
+	}
+	return nil
+}
+
+// checkEnvironmentDomain fails DNS records outside the DNS suffix of the environment,
+// including names the deploy manifest filled in after the API checked the request
+func checkEnvironmentDomain(req domain.DeployRequest, name string) error {
+	if req.EnvironmentPolicy == nil {
+		return nil
+	}
+	if err := req.EnvironmentPolicy.CheckDomain(name); err != nil {
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrorTypeEnvironmentPolicy, nil)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ErrorTypeHostUnhealthy is the application error type of deployments held off an unhealthy deploy host
+const ErrorTypeHostUnhealthy = "HostUnhealthy"
+
+// hostHealthPollInterval is how often the health of an unhealthy deploy host is checked again
+const hostHealthPollInterval = 30 * time.Second
+
+// waitForHealthyHost checks the health of the deploy host of req until it is healthy or wait
+// has passed. waiting is called once, when a deployment starts waiting for the host.
+func waitForHealthyHost(ctx workflow.Context, req domain.DeployRequest, wait time.Duration, retries *retryBudget, waiting func(domain.HostHealth)) (domain.HostHealth, error) {
+	deadline := workflow.Now(ctx).Add(wait)
+
+	for attempt := 0; ; attempt++ {
+		var health domain.HostHealth
+		if err := retries.executeActivity(ctx, &health, activity.ActivityCheckHostHealth, req); err != nil {
+			return health, err
+		}
+		if health.Healthy() {
+			return health, nil
+		}
+
+		remaining := deadline.Sub(workflow.Now(ctx))
+		if remaining <= 0 {
+			message := fmt.Sprintf("deploy host %s is unhealthy: %s", health.Host, strings.Join(health.Problems, "; "))
+			if wait > 0 {
+				message = fmt.Sprintf("deploy host %s is still unhealthy after %s: %s", health.Host, wait, strings.Join(health.Problems, "; "))
+			}
+			return health, temporal.NewNonRetryableApplicationError(message, ErrorTypeHostUnhealthy, nil, health)
+		}
+		if attempt == 0 {
+			waiting(health)
+		}
+		if err := workflow.Sleep(ctx, min(hostHealthPollInterval, remaining)); err != nil {
+			return health, err
+		}
+	}
+}
+
+// isHostUnhealthy reports whether err holds a deployment off an unhealthy deploy host
+func isHostUnhealthy(err error) bool {
+	var appErr *temporal.ApplicationError
+	return errors.As(err, &appErr) && appErr.Type() == ErrorTypeHostUnhealthy
+}
+
+// describeHealth formats the resource usage of a deploy host for step details
+func describeHealth(health domain.HostHealth) string {
+	return fmt.Sprintf("%s: disk %.0f%% full, load %.2f on %d CPUs", health.Host, health.DiskUsage*100, health.Load1, health.CPUs)
+}
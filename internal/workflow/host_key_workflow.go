@@ -0,0 +1,54 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Workflow name constant for type-safe workflow invocation
+const WorkflowHostKeyRotation = "HostKeyRotationWorkflow"
+
+// HostKeyRotationInput is the input of the host key rotation workflow
+type HostKeyRotationInput struct {
+	// Host is host:port; empty means the configured deploy host
+	Host      string        `json:"host,omitempty"`
+	PublicKey string        `json:"public_key"`
+	Grace     time.Duration `json:"grace"`
+}
+
+// HostKeyRotationResult is the result of the host key rotation workflow
+type HostKeyRotationResult struct {
+	Fingerprint string    `json:"fingerprint"`
+	GraceUntil  time.Time `json:"grace_until"`
+}
+
+// HostKeyRotationWorkflow pins a new SSH host key on the worker that runs deployments.
+// The old key stays accepted during the grace period, so deployments keep working
+// whether they reach the host before or after it is reinstalled.
+func HostKeyRotationWorkflow(ctx workflow.Context, input HostKeyRotationInput) (HostKeyRotationResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Host key rotation workflow started", "host", input.Host, "grace", input.Grace)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    10 * time.Second,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	result := HostKeyRotationResult{GraceUntil: workflow.Now(ctx).Add(input.Grace)}
+	if err := workflow.ExecuteActivity(ctx, activity.ActivityPinHostKey, input.Host, input.PublicKey, input.Grace).Get(ctx, &result.Fingerprint); err != nil {
+		logger.Error("Failed to pin host key", "error", err)
+		return result, err
+	}
+
+	logger.Info("Host key rotated", "fingerprint", result.Fingerprint)
+	return result, nil
+}
@@ -0,0 +1,36 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/domain"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Workflow name constant for type-safe workflow invocation
+const WorkflowHostLoad = "HostLoadWorkflow"
+
+// HostLoadWorkflow queries the current load of the named deploy hosts on a worker, which
+// can reach them. The API waits for it to place new preview environments on the least-loaded host.
+func HostLoadWorkflow(ctx workflow.Context, hosts []string) ([]domain.HostLoad, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Host load workflow started", "hosts", hosts)
+
+	// The API falls back to the first host rather than waiting for retries
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 1,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	var loads []domain.HostLoad
+	if err := workflow.ExecuteActivity(ctx, activity.ActivityQueryHostLoad, hosts).Get(ctx, &loads); err != nil {
+		logger.Error("Failed to query host load", "error", err)
+		return nil, err
+	}
+	return loads, nil
+}
@@ -0,0 +1,67 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+)
+
+// applyManifest fills in the parts of the request that the caller left out
+// with the values declared in the repository's .deploy manifest.
+// Values in the request always win over the manifest.
+func applyManifest(req domain.DeployRequest, manifest domain.DeployManifest) domain.DeployRequest {
+	if req.Setup.Script.Interpreter == "" {
+		req.Setup.Script.Interpreter = manifest.Interpreter
+	}
+	if req.Setup.Driver == "" {
+		req.Setup.Driver = manifest.Driver
+	}
+	if !req.Setup.MinimizeDowntime {
+		req.Setup.MinimizeDowntime = manifest.MinimizeDowntime
+	}
+
+	if !req.Setup.InjectSecret.Enable && len(manifest.Secrets.Mappings) > 0 {
+		req.Setup.InjectSecret = domain.InjectSecretConfig{
+			Enable:      true,
+			Project:     manifest.Secrets.Project,
+			Environment: manifest.Secrets.Environment,
+			Secrets:     manifest.Secrets.Mappings,
+		}
+		if req.Setup.InjectSecret.Project == "" {
+			req.Setup.InjectSecret.Project = req.Metadata.ProjectName
+		}
+		if req.Setup.InjectSecret.Environment == "" {
+			req.Setup.InjectSecret.Environment = req.Metadata.Environment
+		}
+	}
+
+	if manifest.Domain.Name != "" {
+		if req.Method == domain.MethodDeploy && !req.Post.SetupDomain.Enable && manifest.Domain.Value != "" {
+			req.Post.SetupDomain = domain.DomainConfig{
+				Enable: true,
+				Title:  "Endpoint",
+				Name:   manifest.Domain.Name,
+				Value:  manifest.Domain.Value,
+			}
+		}
+		if req.Method == domain.MethodCleanup && !req.Post.CleanupDomain.Enable && !req.KeepDomain {
+			req.Post.CleanupDomain = domain.DomainConfig{
+				Enable: true,
+				Name:   manifest.Domain.Name,
+			}
+		}
+	}
+
+	if !req.Post.HealthCheck.Enable && manifest.HealthCheck.URL != "" {
+		req.Post.HealthCheck = domain.HealthCheckConfig{
+			Enable:         true,
+			URL:            manifest.HealthCheck.URL,
+			TimeoutSeconds: int(manifest.HealthCheck.Timeout.Seconds()),
+		}
+	}
+
+	// Point the record at the host the request is placed on
+	if req.Host != nil && req.Host.DNSValue != "" && req.Post.SetupDomain.Enable {
+		req.Post.SetupDomain.Value = req.Host.DNSValue
+	}
+
+	return req
+}
@@ -0,0 +1,80 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"strings"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Memo keys of CD workflows, listed by deployment reports without loading each workflow's result
+const (
+	MemoProject      = "project"
+	MemoComponent    = "component"
+	MemoEnvironment  = "environment"
+	MemoRepo         = "repo"
+	MemoBranch       = "branch"
+	MemoCommit       = "commit"
+	MemoPRNumber     = "pr_number"
+	MemoMethod       = "method"
+	MemoDeployStatus = "deploy_status"
+	MemoHost         = "host"
+)
+
+// DeploymentMemo returns the memo a CD workflow is started with
+func DeploymentMemo(req domain.DeployRequest) map[string]interface{} {
+	memo := map[string]interface{}{
+		MemoProject:      req.Metadata.ProjectName,
+		MemoComponent:    req.Metadata.Component,
+		MemoEnvironment:  req.Metadata.Environment,
+		MemoRepo:         req.Source.Repo,
+		MemoBranch:       req.Source.Branch,
+		MemoCommit:       req.Source.Commit,
+		MemoPRNumber:     req.Source.PRNumber,
+		MemoMethod:       string(req.Method),
+		MemoDeployStatus: string(domain.DeployStatusRunning),
+	}
+	if req.Host != nil {
+		memo[MemoHost] = req.Host.Name
+	}
+	return memo
+}
+
+// recordDeployStatus stores the outcome of the deployment in the workflow memo
+func recordDeployStatus(ctx workflow.Context, status domain.DeployStatus) {
+	if err := workflow.UpsertMemo(ctx, map[string]interface{}{MemoDeployStatus: string(status)}); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to record deploy status in memo", "error", err)
+	}
+}
+
+// MemoValue decodes a string memo field, or returns "" if it is missing
+func MemoValue(memo *commonpb.Memo, key string) string {
+	payload, ok := memo.GetFields()[key]
+	if !ok {
+		return ""
+	}
+	var value string
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// DeploymentTombstone summarizes a CD workflow execution as the tombstone that replaces it once deleted
+func DeploymentTombstone(info *workflowpb.WorkflowExecutionInfo, reason string, deletedAt time.Time) domain.Tombstone {
+	memo := info.GetMemo()
+	return domain.Tombstone{
+		TraceID:      strings.TrimPrefix(info.GetExecution().GetWorkflowId(), CDWorkflowID("")),
+		Project:      MemoValue(memo, MemoProject),
+		Component:    MemoValue(memo, MemoComponent),
+		Environment:  MemoValue(memo, MemoEnvironment),
+		Commit:       MemoValue(memo, MemoCommit),
+		DeployStatus: domain.DeployStatus(MemoValue(memo, MemoDeployStatus)),
+		Reason:       reason,
+		DeletedAt:    deletedAt,
+	}
+}
@@ -0,0 +1,84 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/metrics"
+
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+)
+
+// metricsInterceptor counts finished workflows and times the steps of deployments
+type metricsInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	completed    *metrics.CounterVec
+	failed       *metrics.CounterVec
+	stepDuration *metrics.HistogramVec
+}
+
+// NewMetricsInterceptor returns a worker interceptor recording in registry the workflows that
+// completed or failed, by workflow, project, and environment, and the duration of each step of
+// a deployment. Replayed workflows aren't counted again.
+func NewMetricsInterceptor(registry *metrics.Registry) interceptor.WorkerInterceptor {
+	return &metricsInterceptor{
+		completed: registry.NewCounterVec("workflows_completed_total",
+			"Workflows that completed, by workflow, project, and environment.",
+			"workflow", "project", "environment"),
+		failed: registry.NewCounterVec("workflows_failed_total",
+			"Workflows that failed, including deployments with a failed status, by workflow, project, and environment.",
+			"workflow", "project", "environment"),
+		stepDuration: registry.NewHistogramVec("deployment_step_duration_seconds",
+			"Duration of the steps of deployments, by step, status, project, and environment.",
+			metrics.DurationBuckets, "step", "status", "project", "environment"),
+	}
+}
+
+func (i *metricsInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	inbound := &metricsWorkflowInbound{root: i}
+	inbound.Next = next
+	return inbound
+}
+
+type metricsWorkflowInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+	root *metricsInterceptor
+}
+
+func (w *metricsWorkflowInbound) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (interface{}, error) {
+	result, err := w.Next.ExecuteWorkflow(ctx, in)
+	if workflow.IsReplaying(ctx) || workflow.IsContinueAsNewError(err) {
+		return result, err
+	}
+
+	// Workflows started with a deployment request are keyed by its project and environment
+	var project, environment string
+	if len(in.Args) > 0 {
+		if req, ok := in.Args[0].(domain.DeployRequest); ok {
+			project, environment = req.Metadata.ProjectName, req.Metadata.Environment
+		}
+	}
+
+	failed := err != nil
+	if deployResult, ok := result.(domain.DeployResult); ok {
+		failed = failed || deployResult.Status == domain.DeployStatusFailed
+		w.root.observeSteps(deployResult.Steps, project, environment)
+	}
+
+	name := workflow.GetInfo(ctx).WorkflowType.Name
+	if failed {
+		w.root.failed.Inc(name, project, environment)
+	} else {
+		w.root.completed.Inc(name, project, environment)
+	}
+	return result, err
+}
+
+// observeSteps records the duration of the steps that ran
+func (i *metricsInterceptor) observeSteps(steps []domain.StepResult, project, environment string) {
+	for _, step := range steps {
+		if step.StartedAt.IsZero() || step.FinishedAt.IsZero() {
+			continue
+		}
+		i.stepDuration.Observe(step.FinishedAt.Sub(step.StartedAt).Seconds(), step.Name, string(step.Status), project, environment)
+	}
+}
@@ -0,0 +1,152 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"fmt"
+
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// WorkflowMigration is the name of the migration workflow
+const WorkflowMigration = "MigrationWorkflow"
+
+// ErrorTypeMigrationFailed is the error type of migrations that were rolled back
+const ErrorTypeMigrationFailed = "MigrationFailed"
+
+// MigrationInput is the input of the migration workflow
+type MigrationInput struct {
+	// TraceID identifies the migration
+	TraceID string `json:"trace_id"`
+	// Deploy redeploys the environment on the new host
+	Deploy domain.DeployRequest `json:"deploy"`
+	// Cleanup removes the environment from the old host
+	Cleanup domain.DeployRequest `json:"cleanup"`
+}
+
+// MigrationResult is the result of the migration workflow
+type MigrationResult struct {
+	Deploy domain.DeployResult `json:"deploy"`
+	// DNSMoved is set once the DNS record of the environment points at the new host
+	DNSMoved bool                 `json:"dns_moved"`
+	Cleanup  *domain.DeployResult `json:"cleanup,omitempty"`
+	// RolledBack is set when the new host was cleaned up after a failed redeploy
+	RolledBack bool `json:"rolled_back,omitempty"`
+}
+
+// MigrationWorkflowID returns the workflow ID used for the given trace ID
+func MigrationWorkflowID(traceID string) string {
+	return "migrate-" + traceID
+}
+
+// MigrationWorkflow moves an environment to another deploy host:
+//  1. it redeploys the environment on the new host with the same commit and secrets,
+//     leaving the DNS record unchanged, and checks its health on the new host,
+//  2. points the DNS record at the new host,
+//  3. and cleans up the old host.
+//
+// When the redeploy or its health check fails, the new host is cleaned up again and the
+// environment keeps running on the old host.
+func MigrationWorkflow(ctx workflow.Context, input MigrationInput) (MigrationResult, error) {
+	logger := log.With(workflow.GetLogger(ctx), applog.FieldTraceID, input.TraceID)
+	logger.Info("Migration workflow started",
+		"from", hostName(input.Cleanup.Host),
+		"to", hostName(input.Deploy.Host),
+	)
+
+	var result MigrationResult
+	input.Deploy.KeepDomain = true
+	input.Cleanup.KeepDomain = true
+
+	// Step 1: Redeploy on the new host and verify its health there
+	err := executeCDChild(ctx, input.Deploy, &result.Deploy)
+	if err == nil {
+		if step, ok := findStep(result.Deploy.Steps, domain.StepHealthCheck); ok && step.Status == domain.StepStatusFailed {
+			err = fmt.Errorf("health check on %s failed: %s", hostName(input.Deploy.Host), step.Error)
+		}
+	}
+	if err != nil {
+		logger.Error("Migration redeploy failed, rolling back", "error", err)
+		result.RolledBack = true
+		if rollbackErr := rollbackMigration(ctx, input); rollbackErr != nil {
+			logger.Error("Failed to clean up the new host", "error", rollbackErr)
+			return result, fmt.Errorf("migration failed: %w (cleaning up %s also failed: %v)", err, hostName(input.Deploy.Host), rollbackErr)
+		}
+		return result, temporal.NewNonRetryableApplicationError("migration failed: "+err.Error(), ErrorTypeMigrationFailed, err)
+	}
+
+	// Step 2: Point the DNS record at the new host
+	if record := result.Deploy.Domain; record != nil {
+		logger.Info("Moving DNS record", "domain", record.Name, "value", record.Value)
+		dnsInput := DNSWorkflowInput{
+			Method:  domain.MethodDeploy,
+			Domain:  record.Name,
+			Value:   record.Value,
+			TraceID: input.TraceID,
+		}
+		cwo := workflow.ChildWorkflowOptions{
+			WorkflowID: DNSWorkflowID(input.TraceID),
+		}
+		var dnsResult DNSWorkflowResult
+		if err := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), WorkflowDNS, dnsInput).Get(ctx, &dnsResult); err != nil {
+			// Both hosts run the environment, so nothing is lost; the old host is kept until DNS is fixed
+			logger.Error("Failed to move DNS record", "error", err)
+			return result, fmt.Errorf("environment runs on %s, but moving its DNS record failed, so %s was not cleaned up: %w", hostName(input.Deploy.Host), hostName(input.Cleanup.Host), err)
+		}
+		result.DNSMoved = !dnsResult.Skipped
+	}
+
+	// Step 3: Clean up the old host
+	var cleanup domain.DeployResult
+	err = executeCDChild(ctx, input.Cleanup, &cleanup)
+	result.Cleanup = &cleanup
+	if err != nil {
+		// The environment already runs on the new host; the old host needs a manual cleanup
+		logger.Error("Migration cleanup of the old host failed", "error", err)
+		return result, fmt.Errorf("environment moved to %s, but cleaning up %s failed: %w", hostName(input.Deploy.Host), hostName(input.Cleanup.Host), err)
+	}
+
+	logger.Info("Migration workflow completed")
+	return result, nil
+}
+
+// rollbackMigration cleans up the new host of a failed migration
+func rollbackMigration(ctx workflow.Context, input MigrationInput) error {
+	rollback := input.Deploy
+	rollback.Method = domain.MethodCleanup
+	rollback.TraceID = input.TraceID + "-rollback"
+	rollback.Post = domain.PostActions{}
+
+	var result domain.DeployResult
+	return executeCDChild(ctx, rollback, &result)
+}
+
+// executeCDChild runs req as a child CDWorkflow and waits for its result
+func executeCDChild(ctx workflow.Context, req domain.DeployRequest, result *domain.DeployResult) error {
+	cwo := workflow.ChildWorkflowOptions{
+		WorkflowID: CDWorkflowID(req.TraceID),
+		TaskQueue:  TaskQueueFor(req.Setup.Worker),
+		Memo:       DeploymentMemo(req),
+	}
+	return workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), WorkflowCD, req).Get(ctx, result)
+}
+
+// findStep returns the step with the given name
+func findStep(steps []domain.StepResult, name string) (domain.StepResult, bool) {
+	for _, step := range steps {
+		if step.Name == name {
+			return step, true
+		}
+	}
+	return domain.StepResult{}, false
+}
+
+// hostName returns the name of a deploy host for logs, "default" for the first host of the group
+func hostName(host *domain.DeployHost) string {
+	if host == nil {
+		return "default"
+	}
+	return host.Name
+}
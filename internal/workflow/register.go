@@ -0,0 +1,71 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"slices"
+	"strings"
+
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// TaskQueue is the task queue the CD workers poll and the API starts workflows on
+const TaskQueue = "cd-task-queue"
+
+// TaskQueueFor returns the task queue of the deployments with the worker requirement:
+// TaskQueue without a requirement, e.g. "cd-task-queue@arch=arm64,os=linux" with one
+func TaskQueueFor(requirement domain.WorkerRequirement) string {
+	var parts []string
+	if requirement.Arch != "" {
+		parts = append(parts, "arch="+requirement.Arch)
+	}
+	if requirement.OS != "" {
+		parts = append(parts, "os="+requirement.OS)
+	}
+	if requirement.Region != "" {
+		parts = append(parts, "region="+requirement.Region)
+	}
+	if len(parts) == 0 {
+		return TaskQueue
+	}
+	return TaskQueue + "@" + strings.Join(parts, ",")
+}
+
+// TaskQueuesOf returns the task queues a worker on platform polls: TaskQueue, and the task
+// queue of every worker requirement the platform meets
+func TaskQueuesOf(platform domain.WorkerPlatform) []string {
+	queues := []string{TaskQueue}
+	for _, os := range []string{"", platform.OS} {
+		for _, arch := range []string{"", platform.Arch} {
+			for _, region := range []string{"", platform.Region} {
+				queue := TaskQueueFor(domain.WorkerRequirement{OS: os, Arch: arch, Region: region})
+				if !slices.Contains(queues, queue) {
+					queues = append(queues, queue)
+				}
+			}
+		}
+	}
+	return queues
+}
+
+// Names lists the workflows registered by Register
+var Names = []string{
+	WorkflowCD,
+	WorkflowDNS,
+	WorkflowHostKeyRotation,
+	WorkflowNotificationAck,
+	WorkflowMigration,
+	WorkflowHostLoad,
+	WorkflowTestNotification,
+}
+
+// Register registers the workflows of the service on a worker, with CDWorkflow bound to options
+func Register(r worker.WorkflowRegistry, options CDWorkflowOptions) {
+	r.RegisterWorkflowWithOptions(NewCDWorkflow(options), workflow.RegisterOptions{Name: WorkflowCD})
+	r.RegisterWorkflow(DNSWorkflow)
+	r.RegisterWorkflow(HostKeyRotationWorkflow)
+	r.RegisterWorkflow(NotificationAckWorkflow)
+	r.RegisterWorkflow(MigrationWorkflow)
+	r.RegisterWorkflow(HostLoadWorkflow)
+	r.RegisterWorkflow(TestNotificationWorkflow)
+}
@@ -0,0 +1,146 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ErrorTypeRetryBudgetExhausted is the application error type returned once a workflow has spent its retry budget
+const ErrorTypeRetryBudgetExhausted = "RetryBudgetExhausted"
+
+// retryBudget retries activities with jittered exponential backoff and tracks
+// the total time a workflow run spends retrying across all of them
+type retryBudget struct {
+	config config.RetryConfig
+	spent  time.Duration
+}
+
+// newRetryBudget creates a retry budget for a workflow run
+func newRetryBudget(retryConfig config.RetryConfig) *retryBudget {
+	return &retryBudget{config: retryConfig}
+}
+
+// remaining returns the unspent budget, or 0 if the budget is disabled
+func (b *retryBudget) remaining() time.Duration {
+	if b.config.Budget <= 0 {
+		return 0
+	}
+	return max(b.config.Budget-b.spent, 0)
+}
+
+// window returns the retry window left for work that retries on its own, such as a child
+// workflow. It is at least minimum so that work still gets one attempt; 0 means unbounded.
+func (b *retryBudget) window(minimum time.Duration) time.Duration {
+	if b.config.Budget <= 0 {
+		return 0
+	}
+	return max(b.config.Budget-b.spent, minimum)
+}
+
+// executeActivity runs an activity with the retry policy of the activity options in ctx.
+// Retries are scheduled by the workflow instead of the Temporal server so the backoff can
+// be jittered and stopped once the budget is spent. Time from a failed attempt until the
+// next attempt finishes is charged to the budget.
+func (b *retryBudget) executeActivity(ctx workflow.Context, valuePtr interface{}, activityName string, args ...interface{}) error {
+	options := workflow.GetActivityOptions(ctx)
+	policy := temporal.RetryPolicy{}
+	if options.RetryPolicy != nil {
+		policy = *options.RetryPolicy
+	}
+	if policy.InitialInterval <= 0 {
+		policy.InitialInterval = time.Second
+	}
+	if policy.BackoffCoefficient < 1 {
+		policy.BackoffCoefficient = 2.0
+	}
+
+	attemptCtx := workflow.WithRetryPolicy(ctx, temporal.RetryPolicy{MaximumAttempts: 1})
+	interval := policy.InitialInterval
+	var failedAt time.Time
+
+	for attempt := int32(1); ; attempt++ {
+		err := workflow.ExecuteActivity(attemptCtx, activityName, args...).Get(ctx, valuePtr)
+		if !failedAt.IsZero() {
+			b.spent += workflow.Now(ctx).Sub(failedAt)
+		}
+		if err == nil || !isRetryable(err, policy) {
+			return err
+		}
+		if policy.MaximumAttempts > 0 && attempt >= policy.MaximumAttempts {
+			return err
+		}
+
+		delay := b.jitter(ctx, interval)
+		if b.config.Budget > 0 && b.spent+delay >= b.config.Budget {
+			workflow.GetLogger(ctx).Warn("Retry budget exhausted",
+				"activity", activityName,
+				"attempt", attempt,
+				"spent", b.spent,
+				"budget", b.config.Budget,
+			)
+			return temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("retry budget of %s exhausted after %d attempts of %s", b.config.Budget, attempt, activityName),
+				ErrorTypeRetryBudgetExhausted,
+				err,
+			)
+		}
+
+		workflow.GetLogger(ctx).Info("Retrying activity",
+			"activity", activityName,
+			"attempt", attempt,
+			"delay", delay,
+			"error", err,
+		)
+		failedAt = workflow.Now(ctx)
+		if err := workflow.Sleep(ctx, delay); err != nil {
+			return err
+		}
+
+		// Don't let a single hanging attempt outlive the budget
+		if b.config.Budget > 0 {
+			if left := b.remaining() - delay; left < options.StartToCloseTimeout {
+				attemptCtx = workflow.WithStartToCloseTimeout(attemptCtx, max(left, time.Second))
+			}
+		}
+
+		interval = time.Duration(float64(interval) * policy.BackoffCoefficient)
+		if policy.MaximumInterval > 0 && interval > policy.MaximumInterval {
+			interval = policy.MaximumInterval
+		}
+	}
+}
+
+// jitter randomizes interval by up to ±Jitter
+func (b *retryBudget) jitter(ctx workflow.Context, interval time.Duration) time.Duration {
+	if b.config.Jitter <= 0 {
+		return interval
+	}
+
+	var r float64
+	if err := workflow.SideEffect(ctx, func(workflow.Context) interface{} {
+		return rand.Float64()
+	}).Get(&r); err != nil {
+		return interval
+	}
+	return time.Duration(float64(interval) * (1 + b.config.Jitter*(2*r-1)))
+}
+
+// isRetryable reports whether a failed activity should be retried under policy
+func isRetryable(err error, policy temporal.RetryPolicy) bool {
+	if temporal.IsCanceledError(err) {
+		return false
+	}
+
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		return !appErr.NonRetryable() && !slices.Contains(policy.NonRetryableErrorTypes, appErr.Type())
+	}
+	return true
+}
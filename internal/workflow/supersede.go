@@ -0,0 +1,114 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"time"
+
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Signal names of superseding deployments
+const (
+	// SignalSupersede asks a running deployment to stop after its current step
+	SignalSupersede = "supersede"
+	// SignalSuperseded tells the deployment taking over that a superseded deployment stopped
+	SignalSuperseded = "superseded"
+)
+
+// supersedeWait bounds how long a deployment waits for the deployments it supersedes to stop
+const supersedeWait = 15 * time.Minute
+
+// SupersedeSignal is the payload of the supersede and superseded signals
+type SupersedeSignal struct {
+	// TraceID is the deployment taking over with supersede, the stopped one with superseded
+	TraceID string `json:"trace_id"`
+}
+
+// supersession tracks the supersede signals of a running deployment
+type supersession struct {
+	ch  workflow.ReceiveChannel
+	by  []string
+	ctx workflow.Context
+}
+
+// newSupersession starts receiving the supersede signals of the deployment
+func newSupersession(ctx workflow.Context) *supersession {
+	return &supersession{ch: workflow.GetSignalChannel(ctx, SignalSupersede), ctx: ctx}
+}
+
+// superseded returns the trace ID of the latest deployment taking over, or "" if there is none.
+// The deployment checks it between steps, so a step that started always finishes.
+func (s *supersession) superseded() string {
+	var signal SupersedeSignal
+	for s.ch.ReceiveAsync(&signal) {
+		s.by = append(s.by, signal.TraceID)
+	}
+	if len(s.by) == 0 {
+		return ""
+	}
+	return s.by[len(s.by)-1]
+}
+
+// handOver tells every deployment that asked to take over that this one has stopped
+func (s *supersession) handOver(logger log.Logger, traceID string) {
+	s.superseded()
+	ctx, _ := workflow.NewDisconnectedContext(s.ctx)
+	for _, by := range s.by {
+		err := workflow.SignalExternalWorkflow(ctx, CDWorkflowID(by), "", SignalSuperseded, SupersedeSignal{TraceID: traceID}).Get(ctx, nil)
+		if err != nil {
+			logger.Warn("Failed to hand over to superseding deployment", "superseded_by", by, "error", err)
+		}
+	}
+}
+
+// supersedeRunning asks the deployments req supersedes to stop and returns the trace IDs of
+// those still running; deployments that finished since the API listed them are left out
+func supersedeRunning(ctx workflow.Context, logger log.Logger, req domain.DeployRequest) []string {
+	var running []string
+	for _, traceID := range req.Supersedes {
+		err := workflow.SignalExternalWorkflow(ctx, CDWorkflowID(traceID), "", SignalSupersede, SupersedeSignal{TraceID: req.TraceID}).Get(ctx, nil)
+		if err != nil {
+			logger.Info("Deployment to supersede is no longer running", "trace_id", traceID, "error", err)
+			continue
+		}
+		running = append(running, traceID)
+	}
+	return running
+}
+
+// waitForSuperseded waits until the superseded deployments have stopped, or supersedeWait
+// has passed; it returns the trace IDs of those still running
+func waitForSuperseded(ctx workflow.Context, superseded []string) []string {
+	running := make(map[string]bool, len(superseded))
+	for _, traceID := range superseded {
+		running[traceID] = true
+	}
+	if len(running) == 0 {
+		return nil
+	}
+
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	defer cancelTimer()
+	timedOut := false
+	selector := workflow.NewSelector(ctx)
+	selector.AddFuture(workflow.NewTimer(timerCtx, supersedeWait), func(workflow.Future) {
+		timedOut = true
+	})
+	selector.AddReceive(workflow.GetSignalChannel(ctx, SignalSuperseded), func(c workflow.ReceiveChannel, more bool) {
+		var signal SupersedeSignal
+		c.Receive(ctx, &signal)
+		delete(running, signal.TraceID)
+	})
+	for len(running) > 0 && !timedOut {
+		selector.Select(ctx)
+	}
+
+	var remaining []string
+	for _, traceID := range superseded {
+		if running[traceID] {
+			remaining = append(remaining, traceID)
+		}
+	}
+	return remaining
+}
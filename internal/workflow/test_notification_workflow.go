@@ -0,0 +1,47 @@
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/internal/domain"
+	applog "NYCU-SDC/deployment-service/internal/logger"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Workflow name constant for type-safe workflow invocation
+const WorkflowTestNotification = "TestNotificationWorkflow"
+
+// TestNotificationInput is the input of the test notification workflow
+type TestNotificationInput struct {
+	// Request is the sample deployment the notification is about
+	Request domain.DeployRequest `json:"request"`
+	// Channel is the channel to test; empty sends to the channels the request is routed to
+	Channel string `json:"channel,omitempty"`
+	// Success sends a success notification instead of a failure notification
+	Success bool `json:"success"`
+}
+
+// TestNotificationWorkflow sends a sample notification through a channel on a worker.
+// It isn't retried, so the operator learns right away whether the channel works.
+func TestNotificationWorkflow(ctx workflow.Context, input TestNotificationInput) error {
+	logger := applog.WithDeployment(workflow.GetLogger(ctx), input.Request)
+	logger.Info("Test notification workflow started", "channel", input.Channel)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 1,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	if err := workflow.ExecuteActivity(ctx, activity.ActivitySendTestNotification, input.Request, input.Channel, input.Success).Get(ctx, nil); err != nil {
+		logger.Error("Failed to send test notification", "error", err)
+		return err
+	}
+
+	logger.Info("Test notification sent")
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package activity exports the activities of the standard worker. A custom worker creates
+// them with the adapters of its choice and registers their methods next to its own activities;
+// CDWorkflow calls them by the names below.
+package activity
+
+import (
+	"NYCU-SDC/deployment-service/internal/activity"
+	"NYCU-SDC/deployment-service/pkg/config"
+	"NYCU-SDC/deployment-service/pkg/domain"
+	"NYCU-SDC/deployment-service/pkg/metrics"
+	"NYCU-SDC/deployment-service/pkg/resolver"
+
+	"go.temporal.io/sdk/interceptor"
+	"go.uber.org/zap"
+)
+
+type (
+	SecretActivity      = activity.SecretActivity
+	SSHActivity         = activity.SSHActivity
+	DNSActivity         = activity.DNSActivity
+	NotifyActivity      = activity.NotifyActivity
+	NotificationChannel = activity.NotificationChannel
+	ManifestActivity    = activity.ManifestActivity
+	HealthActivity      = activity.HealthActivity
+	HostKeyActivity     = activity.HostKeyActivity
+	LoadActivity        = activity.LoadActivity
+	CIActivity          = activity.CIActivity
+	HostHealthActivity  = activity.HostHealthActivity
+	ReceiptActivity     = activity.ReceiptActivity
+)
+
+// Names the workflows execute the activities under
+const (
+	ActivityFetchInfisicalSecrets          = activity.ActivityFetchInfisicalSecrets
+	ActivityRunSSHDeploy                   = activity.ActivityRunSSHDeploy
+	ActivityEnsureDNSRecord                = activity.ActivityEnsureDNSRecord
+	ActivityRemoveDNSRecord                = activity.ActivityRemoveDNSRecord
+	ActivityEnsureCanaryDNSRecord          = activity.ActivityEnsureCanaryDNSRecord
+	ActivityRemoveCanaryDNSRecords         = activity.ActivityRemoveCanaryDNSRecords
+	ActivitySendDiscordNotification        = activity.ActivitySendDiscordNotification
+	ActivitySendTrackedDiscordNotification = activity.ActivitySendTrackedDiscordNotification
+	ActivitySendDiscordProgress            = activity.ActivitySendDiscordProgress
+	ActivityCheckNotificationAck           = activity.ActivityCheckNotificationAck
+	ActivitySendNotificationReminder       = activity.ActivitySendNotificationReminder
+	ActivitySendTestNotification           = activity.ActivitySendTestNotification
+	ActivityFetchDeployManifest            = activity.ActivityFetchDeployManifest
+	ActivityCheckHealth                    = activity.ActivityCheckHealth
+	ActivityCheckHealthAt                  = activity.ActivityCheckHealthAt
+	ActivityPinHostKey                     = activity.ActivityPinHostKey
+	ActivityQueryHostLoad                  = activity.ActivityQueryHostLoad
+	ActivityCheckCommitStatus              = activity.ActivityCheckCommitStatus
+	ActivityCheckHostHealth                = activity.ActivityCheckHostHealth
+	ActivitySignDeploymentReceipt          = activity.ActivitySignDeploymentReceipt
+)
+
+// Application error types returned by the activities
+const (
+	ErrorTypeAuth       = activity.ErrorTypeAuth
+	ErrorTypeNetwork    = activity.ErrorTypeNetwork
+	ErrorTypeScript     = activity.ErrorTypeScript
+	ErrorTypeValidation = activity.ErrorTypeValidation
+	ErrorTypePanic      = activity.ErrorTypePanic
+	ErrorTypeCI         = activity.ErrorTypeCI
+)
+
+// NewSecretActivity creates the activity injecting secrets; an empty checksumSalt disables checksums
+func NewSecretActivity(secretManager domain.SecretManager, checksumSalt string, logger *zap.Logger) *SecretActivity {
+	return activity.NewSecretActivity(secretManager, checksumSalt, logger)
+}
+
+// NewSSHActivity creates the activity running the deploy and cleanup scripts with the given drivers
+func NewSSHActivity(sshExecutor domain.SSHExecutor, sshConfig config.SSHConfig, drivers []string, logger *zap.Logger) *SSHActivity {
+	return activity.NewSSHActivity(sshExecutor, sshConfig, drivers, logger)
+}
+
+// NewDNSActivity creates the activity setting up and removing DNS records
+func NewDNSActivity(dnsProvider domain.DNSProvider, canaries domain.CanaryDNSProvider, ipResolver *resolver.IPResolver, domains *resolver.DomainPolicy, logger *zap.Logger) *DNSActivity {
+	return activity.NewDNSActivity(dnsProvider, canaries, ipResolver, domains, logger)
+}
+
+// NewNotifyActivity creates the activity sending deployment notifications to the channels
+// the router picks. tracker is used for acknowledgement tracking, threads and threadStore only
+// with Discord threads, where they may be nil otherwise; a nil failures store keeps no failures.
+func NewNotifyActivity(channels map[string]NotificationChannel, router *resolver.NotificationRouter, tracker domain.NotificationTracker, threads domain.ThreadNotifier, threadStore domain.ThreadStore, failures domain.NotificationFailureStore, registry *metrics.Registry, logger *zap.Logger) *NotifyActivity {
+	return activity.NewNotifyActivity(channels, router, tracker, threads, threadStore, failures, registry, logger)
+}
+
+// NewManifestActivity creates the activity fetching the .deploy manifest of a repository
+func NewManifestActivity(repositoryProvider domain.RepositoryProvider, logger *zap.Logger) *ManifestActivity {
+	return activity.NewManifestActivity(repositoryProvider, logger)
+}
+
+// NewHealthActivity creates the activity checking the health of a deployed service
+func NewHealthActivity(ipResolver *resolver.IPResolver, logger *zap.Logger) *HealthActivity {
+	return activity.NewHealthActivity(ipResolver, logger)
+}
+
+// NewHostKeyActivity creates the activity pinning the host keys of the deploy hosts
+func NewHostKeyActivity(hostKeyManager domain.HostKeyManager, sshConfig config.SSHConfig, logger *zap.Logger) *HostKeyActivity {
+	return activity.NewHostKeyActivity(hostKeyManager, sshConfig, logger)
+}
+
+// NewLoadActivity creates the activity querying the load of the deploy hosts; probe may be nil
+func NewLoadActivity(probe domain.LoadProbe, sshConfig config.SSHConfig, logger *zap.Logger) *LoadActivity {
+	return activity.NewLoadActivity(probe, sshConfig, logger)
+}
+
+// NewCIActivity creates the activity checking the CI checks of the deployed commit
+func NewCIActivity(statusProvider domain.CommitStatusProvider, logger *zap.Logger) *CIActivity {
+	return activity.NewCIActivity(statusProvider, logger)
+}
+
+// NewHostHealthActivity creates the activity checking the health of the deploy host; probe may be nil
+func NewHostHealthActivity(probe domain.HostHealthProbe, sshConfig config.SSHConfig, logger *zap.Logger) *HostHealthActivity {
+	return activity.NewHostHealthActivity(probe, sshConfig, logger)
+}
+
+// NewReceiptActivity creates the activity signing the receipts of completed deployments; signer may be nil
+func NewReceiptActivity(signer domain.ReceiptSigner, logger *zap.Logger) *ReceiptActivity {
+	return activity.NewReceiptActivity(signer, logger)
+}
+
+// NewRecoverInterceptor creates a worker interceptor failing the activity of a panic instead of
+// the worker process; reporter may be nil
+func NewRecoverInterceptor(reporter domain.CrashReporter, logger *zap.Logger) interceptor.WorkerInterceptor {
+	return activity.NewRecoverInterceptor(reporter, logger)
+}
@@ -0,0 +1,118 @@
+// Package adapter exports the constructors of the adapters the standard worker uses, so a
+// custom worker can pass them to the activities or replace some with its own implementations
+// of the ports in package domain.
+package adapter
+
+import (
+	"NYCU-SDC/deployment-service/internal/adapter/chaos"
+	"NYCU-SDC/deployment-service/internal/adapter/cloudflare"
+	"NYCU-SDC/deployment-service/internal/adapter/discord"
+	"NYCU-SDC/deployment-service/internal/adapter/github"
+	"NYCU-SDC/deployment-service/internal/adapter/infisical"
+	"NYCU-SDC/deployment-service/internal/adapter/nodeexporter"
+	"NYCU-SDC/deployment-service/internal/adapter/notificationstore"
+	"NYCU-SDC/deployment-service/internal/adapter/sentry"
+	"NYCU-SDC/deployment-service/internal/adapter/signer"
+	"NYCU-SDC/deployment-service/internal/adapter/ssh"
+	"NYCU-SDC/deployment-service/internal/adapter/teams"
+	"NYCU-SDC/deployment-service/internal/adapter/threadstore"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"NYCU-SDC/deployment-service/pkg/config"
+	"NYCU-SDC/deployment-service/pkg/domain"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type (
+	CloudflareClient         = cloudflare.Client
+	DiscordClient            = discord.Client
+	TeamsClient              = teams.Client
+	GitHubClient             = github.Client
+	InfisicalClient          = infisical.Client
+	SSHClient                = ssh.Client
+	NodeExporterClient       = nodeexporter.Client
+	SentryClient             = sentry.Client
+	ReceiptSigner            = signer.Signer
+	ChaosSSHExecutor         = chaos.SSHExecutor
+	ChaosDNSProvider         = chaos.DNSProvider
+	ThreadStore              = threadstore.Store
+	NotificationFailureStore = notificationstore.Store
+	RecordCipher             = encryption.RecordCipher
+)
+
+// NewCloudflareClient creates a DNS provider for a Cloudflare zone.
+// An empty apiURL uses the Cloudflare API; a nil httpClient uses a client with a 30 second timeout.
+func NewCloudflareClient(apiURL, apiToken, zoneID string, httpClient *http.Client, logger *zap.Logger) *CloudflareClient {
+	return cloudflare.NewClient(apiURL, apiToken, zoneID, httpClient, logger)
+}
+
+// NewDiscordClient creates a notifier posting to a Discord webhook
+func NewDiscordClient(discordConfig config.DiscordConfig, logger *zap.Logger) *DiscordClient {
+	return discord.NewClient(discordConfig, logger)
+}
+
+// NewTeamsClient creates a notifier posting to a Microsoft Teams webhook
+func NewTeamsClient(teamsConfig config.TeamsConfig, logger *zap.Logger) *TeamsClient {
+	return teams.NewClient(teamsConfig, logger)
+}
+
+// NewGitHubClient creates a repository provider reading deploy manifests;
+// an empty token is allowed for public repositories
+func NewGitHubClient(apiURL, token string, logger *zap.Logger) *GitHubClient {
+	return github.NewClient(apiURL, token, logger)
+}
+
+// NewInfisicalClient creates a secret manager reading Infisical secrets
+func NewInfisicalClient(baseURL, serviceToken string, logger *zap.Logger) *InfisicalClient {
+	return infisical.NewClient(baseURL, serviceToken, logger)
+}
+
+// NewSSHClient creates an executor running deployments on the deploy hosts
+func NewSSHClient(sshConfig config.SSHConfig, logger *zap.Logger) *SSHClient {
+	return ssh.NewClient(sshConfig, logger)
+}
+
+// NewNodeExporterClient creates a probe of the deploy host load through node_exporter
+func NewNodeExporterClient(logger *zap.Logger) *NodeExporterClient {
+	return nodeexporter.NewClient(logger)
+}
+
+// NewSentryClient creates a reporter of recovered panics
+func NewSentryClient(dsn, environment, release string, logger *zap.Logger) (*SentryClient, error) {
+	return sentry.NewClient(dsn, environment, release, logger)
+}
+
+// NewReceiptSigner creates a signer of deployment receipts with the Ed25519 private key in a PEM file
+func NewReceiptSigner(path string) (*ReceiptSigner, error) {
+	return signer.NewSigner(path)
+}
+
+// NewChaosSSHExecutor wraps an SSH executor, failing failureRate (0 to 1) of the executions by
+// activities of namespaces as an unavailable host, to exercise retries on a staging worker
+func NewChaosSSHExecutor(next domain.SSHExecutor, namespaces []string, failureRate float64, logger *zap.Logger) *ChaosSSHExecutor {
+	return chaos.NewSSHExecutor(next, namespaces, failureRate, logger)
+}
+
+// NewChaosDNSProvider wraps DNS providers, delaying each record change by activities of namespaces by latency
+func NewChaosDNSProvider(records domain.DNSProvider, canaries domain.CanaryDNSProvider, namespaces []string, latency time.Duration, logger *zap.Logger) *ChaosDNSProvider {
+	return chaos.NewDNSProvider(records, canaries, namespaces, latency, logger)
+}
+
+// NewThreadStore creates a store of the Discord threads of deployments backed by the given file
+func NewThreadStore(path string) *ThreadStore {
+	return threadstore.NewStore(path)
+}
+
+// NewRecordCipher creates a cipher encrypting the records kept outside Temporal with the keys
+// of the namespace of their environment
+func NewRecordCipher(encryptionConfig config.EncryptionConfig, temporalConfig config.TemporalConfig) (*RecordCipher, error) {
+	return encryption.NewRecordCipher(encryptionConfig, temporalConfig)
+}
+
+// NewNotificationFailureStore creates a store of undelivered notifications backed by the given
+// file, encrypted with cipher
+func NewNotificationFailureStore(path string, cipher *RecordCipher) *NotificationFailureStore {
+	return notificationstore.NewStore(path, cipher)
+}
@@ -0,0 +1,277 @@
+package pkg_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// update rewrites api.txt with the current API: go test ./pkg -update
+var update = flag.Bool("update", false, "update api.txt")
+
+// modulePath is the path of the module; its packages are listed in api.txt
+const modulePath = "NYCU-SDC/deployment-service"
+
+// TestAPI checks that the exported API of the packages in pkg matches api.txt.
+//
+// Most of pkg aliases types of internal packages, so a change to an internal type can change
+// the API of pkg without touching it. api.txt therefore lists the aliased types of the module
+// in full, with their fields, tags, and methods, and every change to them shows up in review
+// as a change to api.txt. Removing or changing an entry breaks custom workers.
+func TestAPI(t *testing.T) {
+	got := renderAPI(t)
+
+	if *update {
+		if err := os.WriteFile("api.txt", []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile("api.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == string(want) {
+		return
+	}
+
+	gotLines := lineSet(got)
+	wantLines := lineSet(string(want))
+	var diff strings.Builder
+	for _, line := range strings.Split(string(want), "\n") {
+		if line != "" && !gotLines[line] {
+			fmt.Fprintf(&diff, "- %s\n", line)
+		}
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if line != "" && !wantLines[line] {
+			fmt.Fprintf(&diff, "+ %s\n", line)
+		}
+	}
+	t.Errorf("the API of pkg changed; check the changes are compatible and run go test ./pkg -update:\n%s", diff.String())
+}
+
+func lineSet(s string) map[string]bool {
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(s, "\n") {
+		lines[line] = true
+	}
+	return lines
+}
+
+// listedPackage is the part of the output of go list the test reads
+type listedPackage struct {
+	ImportPath string
+	Export     string
+}
+
+// renderAPI returns the API of the packages in pkg, one sorted line per declaration
+func renderAPI(t *testing.T) string {
+	t.Helper()
+
+	cmd := exec.Command("go", "list", "-export", "-deps", "-json", "./...")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go list: %v\n%s", err, stderr.String())
+	}
+
+	exports := make(map[string]string)
+	var roots []string
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var pkg listedPackage
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		exports[pkg.ImportPath] = pkg.Export
+		if strings.HasPrefix(pkg.ImportPath, modulePath+"/pkg/") {
+			roots = append(roots, pkg.ImportPath)
+		}
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := exports[path]
+		if !ok || file == "" {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(file)
+	})
+
+	r := &apiRenderer{seen: make(map[*types.TypeName]bool)}
+	for _, path := range roots {
+		pkg, err := imp.Import(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			if obj := scope.Lookup(name); obj.Exported() {
+				r.object(path, obj)
+			}
+		}
+	}
+	// Types of the module reached from the API, e.g. through aliases, are part of it too
+	for len(r.pending) > 0 {
+		name := r.pending[0]
+		r.pending = r.pending[1:]
+		r.typeDefinition(name)
+	}
+
+	sort.Strings(r.lines)
+	return strings.Join(r.lines, "\n") + "\n"
+}
+
+// apiRenderer renders declarations and collects the types of the module they reach
+type apiRenderer struct {
+	lines   []string
+	seen    map[*types.TypeName]bool
+	pending []*types.TypeName
+}
+
+func (r *apiRenderer) add(format string, args ...any) {
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+// qualifier writes types with their full package path
+func (r *apiRenderer) qualifier(pkg *types.Package) string {
+	return pkg.Path()
+}
+
+// typeString renders typ and queues the types of the module it mentions
+func (r *apiRenderer) typeString(typ types.Type) string {
+	r.reach(typ)
+	return types.TypeString(typ, r.qualifier)
+}
+
+// reach queues the named types of the module in typ
+func (r *apiRenderer) reach(typ types.Type) {
+	switch typ := typ.(type) {
+	case *types.Alias:
+		r.reach(types.Unalias(typ))
+	case *types.Named:
+		name := typ.Obj()
+		if name.Pkg() != nil && strings.HasPrefix(name.Pkg().Path(), modulePath+"/") && !r.seen[name] {
+			r.seen[name] = true
+			r.pending = append(r.pending, name)
+		}
+		for i := 0; i < typ.TypeArgs().Len(); i++ {
+			r.reach(typ.TypeArgs().At(i))
+		}
+	case *types.Pointer:
+		r.reach(typ.Elem())
+	case *types.Slice:
+		r.reach(typ.Elem())
+	case *types.Array:
+		r.reach(typ.Elem())
+	case *types.Map:
+		r.reach(typ.Key())
+		r.reach(typ.Elem())
+	case *types.Chan:
+		r.reach(typ.Elem())
+	case *types.Signature:
+		for i := 0; i < typ.Params().Len(); i++ {
+			r.reach(typ.Params().At(i).Type())
+		}
+		for i := 0; i < typ.Results().Len(); i++ {
+			r.reach(typ.Results().At(i).Type())
+		}
+	case *types.Struct:
+		for i := 0; i < typ.NumFields(); i++ {
+			if typ.Field(i).Exported() {
+				r.reach(typ.Field(i).Type())
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < typ.NumMethods(); i++ {
+			r.reach(typ.Method(i).Type())
+		}
+	}
+}
+
+// object renders an exported declaration of the package at path
+func (r *apiRenderer) object(path string, obj types.Object) {
+	switch obj := obj.(type) {
+	case *types.Const:
+		r.add("pkg %s, const %s %s = %s", path, obj.Name(), r.typeString(obj.Type()), obj.Val().ExactString())
+	case *types.Var:
+		r.add("pkg %s, var %s %s", path, obj.Name(), r.typeString(obj.Type()))
+	case *types.Func:
+		r.add("pkg %s, func %s%s", path, obj.Name(), strings.TrimPrefix(r.typeString(obj.Type()), "func"))
+	case *types.TypeName:
+		if obj.IsAlias() {
+			r.add("pkg %s, type %s = %s", path, obj.Name(), r.typeString(obj.Type()))
+			return
+		}
+		if !r.seen[obj] {
+			r.seen[obj] = true
+			r.typeDefinition(obj)
+		}
+	}
+}
+
+// typeDefinition renders a defined type with its exported fields and methods
+func (r *apiRenderer) typeDefinition(name *types.TypeName) {
+	path := name.Pkg().Path()
+	named, ok := name.Type().(*types.Named)
+	if !ok {
+		return
+	}
+
+	switch underlying := named.Underlying().(type) {
+	case *types.Struct:
+		r.add("pkg %s, type %s struct", path, name.Name())
+		for i := 0; i < underlying.NumFields(); i++ {
+			field := underlying.Field(i)
+			if !field.Exported() {
+				continue
+			}
+			line := fmt.Sprintf("pkg %s, type %s struct, %s %s", path, name.Name(), field.Name(), r.typeString(field.Type()))
+			if tag := underlying.Tag(i); tag != "" {
+				line += " `" + tag + "`"
+			}
+			if field.Embedded() {
+				line += " embedded"
+			}
+			r.add("%s", line)
+		}
+	case *types.Interface:
+		r.add("pkg %s, type %s interface", path, name.Name())
+		for i := 0; i < underlying.NumMethods(); i++ {
+			method := underlying.Method(i)
+			if method.Exported() {
+				r.add("pkg %s, type %s interface, %s%s", path, name.Name(), method.Name(), strings.TrimPrefix(r.typeString(method.Type()), "func"))
+			}
+		}
+	default:
+		r.add("pkg %s, type %s %s", path, name.Name(), r.typeString(underlying))
+	}
+
+	for i := 0; i < named.NumMethods(); i++ {
+		method := named.Method(i)
+		if !method.Exported() {
+			continue
+		}
+		signature := method.Type().(*types.Signature)
+		receiver := name.Name()
+		if _, ok := signature.Recv().Type().(*types.Pointer); ok {
+			receiver = "*" + receiver
+		}
+		r.add("pkg %s, method (%s) %s%s", path, receiver, method.Name(), strings.TrimPrefix(r.typeString(signature), "func"))
+	}
+}
@@ -0,0 +1,39 @@
+// Package config exports the configuration of the service, so a custom worker reads the
+// same config.yaml, .env, and environment variables as the standard one.
+package config
+
+import (
+	"NYCU-SDC/deployment-service/internal/config"
+)
+
+// Configuration sections used by the SDK constructors
+type (
+	Config               = config.Config
+	InfisicalConfig      = config.InfisicalConfig
+	CloudflareConfig     = config.CloudflareConfig
+	CanaryConfig         = config.CanaryConfig
+	DiscordConfig        = config.DiscordConfig
+	DiscordAckConfig     = config.DiscordAckConfig
+	DiscordThreadsConfig = config.DiscordThreadsConfig
+	DiscordMentionConfig = config.DiscordMentionConfig
+	TeamsConfig          = config.TeamsConfig
+	NotificationConfig   = config.NotificationConfig
+	NotificationRoute    = config.NotificationRoute
+	GitHubConfig         = config.GitHubConfig
+	SSHConfig            = config.SSHConfig
+	HostLoadConfig       = config.HostLoadConfig
+	DeployHostConfig     = config.DeployHostConfig
+	RepoCacheConfig      = config.RepoCacheConfig
+	RetryConfig          = config.RetryConfig
+	WorkerConfig         = config.WorkerConfig
+	ReceiptsConfig       = config.ReceiptsConfig
+	EncryptionConfig     = config.EncryptionConfig
+	EncryptionKeyConfig  = config.EncryptionKeyConfig
+	TemporalConfig       = config.TemporalConfig
+	ChaosConfig          = config.ChaosConfig
+)
+
+// Load reads the configuration from config.yaml, .env, the environment, and the flags
+func Load() (*Config, error) {
+	return config.Load()
+}
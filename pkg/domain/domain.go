@@ -0,0 +1,143 @@
+// Package domain exports the deployment models and the ports adapters implement, for
+// services embedding a custom worker. The types are aliases of the ones the service uses,
+// so values pass between this package and the rest of the SDK unchanged.
+package domain
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+)
+
+// Deployment requests and results
+type (
+	DeployMethod       = domain.DeployMethod
+	DeployRequest      = domain.DeployRequest
+	DeployHost         = domain.DeployHost
+	SourceInfo         = domain.SourceInfo
+	CommitAuthor       = domain.CommitAuthor
+	MetadataInfo       = domain.MetadataInfo
+	SetupConfig        = domain.SetupConfig
+	ScriptConfig       = domain.ScriptConfig
+	CloneConfig        = domain.CloneConfig
+	SecretMapping      = domain.SecretMapping
+	InjectSecretConfig = domain.InjectSecretConfig
+	FetchedSecrets     = domain.FetchedSecrets
+	PostActions        = domain.PostActions
+	DomainConfig       = domain.DomainConfig
+	HealthCheckConfig  = domain.HealthCheckConfig
+	CIGateConfig       = domain.CIGateConfig
+	DeployStatus       = domain.DeployStatus
+	StepStatus         = domain.StepStatus
+	StepResult         = domain.StepResult
+	FailureCause       = domain.FailureCause
+	DeployResult       = domain.DeployResult
+	DeployManifest     = domain.DeployManifest
+	DeploymentReceipt  = domain.DeploymentReceipt
+	ArtifactRef        = domain.ArtifactRef
+	SignedReceipt      = domain.SignedReceipt
+	FieldError         = domain.FieldError
+)
+
+// Models used by the ports
+type (
+	HostLoad            = domain.HostLoad
+	HostHealth          = domain.HostHealth
+	CommitCheck         = domain.CommitCheck
+	HostDrain           = domain.HostDrain
+	Placement           = domain.Placement
+	QueuedDeployment    = domain.QueuedDeployment
+	Tombstone           = domain.Tombstone
+	Crash               = domain.Crash
+	NotificationThread  = domain.NotificationThread
+	NotificationFailure = domain.NotificationFailure
+	AdapterHealth       = domain.AdapterHealth
+	WorkerInfo          = domain.WorkerInfo
+	WorkerStatus        = domain.WorkerStatus
+	WorkerPlatform      = domain.WorkerPlatform
+	WorkerRequirement   = domain.WorkerRequirement
+	Environment         = domain.Environment
+	FreezeWindow        = domain.FreezeWindow
+	Deployment          = domain.Deployment
+	DeploymentFilter    = domain.DeploymentFilter
+)
+
+// Ports implemented by the adapters
+type (
+	SecretManager             = domain.SecretManager
+	SSHExecutor               = domain.SSHExecutor
+	HostKeyManager            = domain.HostKeyManager
+	DNSProvider               = domain.DNSProvider
+	CanaryDNSProvider         = domain.CanaryDNSProvider
+	TrafficAnalytics          = domain.TrafficAnalytics
+	Notifier                  = domain.Notifier
+	NotificationTracker       = domain.NotificationTracker
+	ThreadNotifier            = domain.ThreadNotifier
+	ThreadStore               = domain.ThreadStore
+	HealthChecker             = domain.HealthChecker
+	WorkerFleet               = domain.WorkerFleet
+	HostStore                 = domain.HostStore
+	LoadProbe                 = domain.LoadProbe
+	HostHealthProbe           = domain.HostHealthProbe
+	TombstoneStore            = domain.TombstoneStore
+	RepositoryProvider        = domain.RepositoryProvider
+	CommitStatusProvider      = domain.CommitStatusProvider
+	CrashReporter             = domain.CrashReporter
+	ReceiptSigner             = domain.ReceiptSigner
+	NotificationFailureStore  = domain.NotificationFailureStore
+	NotificationFailureSource = domain.NotificationFailureSource
+	EnvironmentStore          = domain.EnvironmentStore
+	DeploymentStore           = domain.DeploymentStore
+	LeaseStore                = domain.LeaseStore
+)
+
+// Deployment methods
+const (
+	MethodDeploy  = domain.MethodDeploy
+	MethodCleanup = domain.MethodCleanup
+)
+
+// Types of the supply-chain artifacts of a deployment
+const (
+	ArtifactTypeSBOM       = domain.ArtifactTypeSBOM
+	ArtifactTypeProvenance = domain.ArtifactTypeProvenance
+)
+
+// Deployment outcomes
+const (
+	DeployStatusRunning            = domain.DeployStatusRunning
+	DeployStatusSucceeded          = domain.DeployStatusSucceeded
+	DeployStatusPartiallySucceeded = domain.DeployStatusPartiallySucceeded
+	DeployStatusFailed             = domain.DeployStatusFailed
+)
+
+// Step outcomes
+const (
+	StepStatusSucceeded = domain.StepStatusSucceeded
+	StepStatusFailed    = domain.StepStatusFailed
+	StepStatusSkipped   = domain.StepStatusSkipped
+)
+
+// States of the CI checks of a commit
+const (
+	CheckStateSuccess = domain.CheckStateSuccess
+	CheckStatePending = domain.CheckStatePending
+	CheckStateFailure = domain.CheckStateFailure
+)
+
+// Notification channels deployments can be routed to
+const (
+	NotificationChannelDiscord = domain.NotificationChannelDiscord
+	NotificationChannelTeams   = domain.NotificationChannelTeams
+)
+
+// Error categories adapters wrap, so activities can tell whether a failure is worth retrying
+var (
+	ErrUnauthorized   = domain.ErrUnauthorized
+	ErrUnavailable    = domain.ErrUnavailable
+	ErrInvalidRequest = domain.ErrInvalidRequest
+	ErrFileNotFound   = domain.ErrFileNotFound
+)
+
+// ErrorForStatus returns the error category of an unexpected HTTP response status
+func ErrorForStatus(statusCode int) error {
+	return domain.ErrorForStatus(statusCode)
+}
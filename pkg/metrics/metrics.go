@@ -0,0 +1,21 @@
+// Package metrics exports the registry the notification activity and the workflow metrics
+// interceptor record in.
+package metrics
+
+import (
+	"NYCU-SDC/deployment-service/internal/metrics"
+)
+
+type (
+	Registry     = metrics.Registry
+	CounterVec   = metrics.CounterVec
+	HistogramVec = metrics.HistogramVec
+)
+
+// DurationBuckets are the histogram buckets, in seconds, the deployment steps are timed with
+var DurationBuckets = metrics.DurationBuckets
+
+// NewRegistry creates an empty registry; serve it with Registry.HandleMetrics
+func NewRegistry() *Registry {
+	return metrics.NewRegistry()
+}
@@ -0,0 +1,39 @@
+// Package resolver exports the resolvers the activities depend on.
+package resolver
+
+import (
+	"NYCU-SDC/deployment-service/internal/resolver"
+	"NYCU-SDC/deployment-service/pkg/config"
+	"NYCU-SDC/deployment-service/pkg/domain"
+
+	"go.uber.org/zap"
+)
+
+type (
+	IPResolver         = resolver.IPResolver
+	DomainPolicy       = resolver.DomainPolicy
+	MentionResolver    = resolver.MentionResolver
+	NotificationRouter = resolver.NotificationRouter
+)
+
+// NewIPResolver creates a resolver of the ip_mappings placeholders DNS records point at
+func NewIPResolver(mappings map[string]string, logger *zap.Logger) *IPResolver {
+	return resolver.NewIPResolver(mappings, logger)
+}
+
+// NewDomainPolicy creates a policy keeping DNS records inside the allowed domains
+func NewDomainPolicy(patterns []string, logger *zap.Logger) *DomainPolicy {
+	return resolver.NewDomainPolicy(patterns, logger)
+}
+
+// NewMentionResolver creates a resolver of the users failure notifications mention;
+// authors may be nil to skip the commit author lookup
+func NewMentionResolver(users map[string]string, authors domain.RepositoryProvider, logger *zap.Logger) *MentionResolver {
+	return resolver.NewMentionResolver(users, authors, logger)
+}
+
+// NewNotificationRouter creates a router of notifications to channels, e.g. with
+// config.Config.VerbosityFor as verbosityFor
+func NewNotificationRouter(routes []config.NotificationRoute, verbosityFor func(environment string) string, logger *zap.Logger) *NotificationRouter {
+	return resolver.NewNotificationRouter(routes, verbosityFor, logger)
+}
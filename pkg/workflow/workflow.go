@@ -0,0 +1,82 @@
+// Package workflow exports the workflows of the service for a custom worker. The worker polls
+// TaskQueue, registers the workflows with Register, and registers the activities of package
+// activity next to its own:
+//
+//	w := worker.New(temporalClient, workflow.TaskQueue, worker.Options{})
+//	workflow.Register(w, workflow.CDWorkflowOptions{Retry: cfg.Retry, Capabilities: capabilities})
+//	w.RegisterActivity(dnsActivity.EnsureDNSRecord)
+//	w.RegisterActivity(myActivity.Run)
+//
+// Each activity the workflows execute must be registered, under the name in package activity.
+package workflow
+
+import (
+	"NYCU-SDC/deployment-service/internal/domain"
+	"NYCU-SDC/deployment-service/internal/encryption"
+	"NYCU-SDC/deployment-service/internal/metrics"
+	"NYCU-SDC/deployment-service/internal/workflow"
+	"NYCU-SDC/deployment-service/pkg/config"
+
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/worker"
+	sdkworkflow "go.temporal.io/sdk/workflow"
+)
+
+type (
+	CDWorkflowOptions = workflow.CDWorkflowOptions
+	Capabilities      = workflow.Capabilities
+)
+
+// TaskQueue is the task queue the API starts workflows on
+const TaskQueue = workflow.TaskQueue
+
+// TaskQueueFor returns the task queue of the deployments with the worker requirement
+func TaskQueueFor(requirement domain.WorkerRequirement) string {
+	return workflow.TaskQueueFor(requirement)
+}
+
+// TaskQueuesOf returns the task queues a worker on platform polls. A custom worker meant to
+// run deployments with a setup.worker requirement polls each of them.
+func TaskQueuesOf(platform domain.WorkerPlatform) []string {
+	return workflow.TaskQueuesOf(platform)
+}
+
+// Names the workflows are registered under
+const (
+	WorkflowCD               = workflow.WorkflowCD
+	WorkflowDNS              = workflow.WorkflowDNS
+	WorkflowHostKeyRotation  = workflow.WorkflowHostKeyRotation
+	WorkflowNotificationAck  = workflow.WorkflowNotificationAck
+	WorkflowMigration        = workflow.WorkflowMigration
+	WorkflowHostLoad         = workflow.WorkflowHostLoad
+	WorkflowTestNotification = workflow.WorkflowTestNotification
+)
+
+// Names lists the workflows registered by Register
+var Names = workflow.Names
+
+// NewMetricsInterceptor returns a worker interceptor counting finished workflows and timing
+// the steps of deployments in registry
+func NewMetricsInterceptor(registry *metrics.Registry) interceptor.WorkerInterceptor {
+	return workflow.NewMetricsInterceptor(registry)
+}
+
+// DataConverters returns the data converters encrypting the workflow payloads of the namespaces
+// with the keys of their tenant or namespace. A worker of such a namespace must use its data
+// converter and ContextPropagators, in the client.Options it connects with, to read the
+// payloads the API and other workers write.
+func DataConverters(encryptionConfig config.EncryptionConfig, temporalConfig config.TemporalConfig) (map[string]converter.DataConverter, error) {
+	return encryption.DataConverters(encryptionConfig, temporalConfig)
+}
+
+// ContextPropagators returns the context propagators passing the tenant of a deployment, whose
+// keys encrypt its payloads, to its activities and child workflows
+func ContextPropagators() []sdkworkflow.ContextPropagator {
+	return encryption.ContextPropagators()
+}
+
+// Register registers the workflows of the service on a worker, with CDWorkflow bound to options
+func Register(r worker.WorkflowRegistry, options CDWorkflowOptions) {
+	workflow.Register(r, options)
+}